@@ -3,12 +3,14 @@ package cli
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,8 +20,76 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// checkpointOptions controls how doCheckpoint captures sessions.
+type checkpointOptions struct {
+	// ContentIDs derives session IDs from conversation content instead of
+	// time-ordered ULIDs, so the same session gets the same ID on every machine.
+	// It also skips the turns a resumed session replays (see replayedTurns).
+	// Git config rekal.contentIds turns it on as well.
+	ContentIDs bool
+	// IncludeThinking captures assistant thinking blocks as "thinking" turns.
+	IncludeThinking bool
+	// SessionDir reads transcripts from this directory instead of the one
+	// discoverSessionDir locates for the repo.
+	SessionDir string
+	// File, an absolute path, captures this one transcript instead of
+	// those in a session directory.
+	File string
+	// Source, when set, is read instead of File, SessionDir or the
+	// discovered directory. No flag sets it.
+	Source session.SessionSource
+	// Format names the transcript format, one of session.Formats(). Empty
+	// or "auto" detects it per file.
+	Format string
+	// CmdPrefixLen is how many bytes of a tool's command are kept as
+	// cmd_prefix, as session.ParseOptions.CmdPrefixLen: zero for the
+	// default, negative for the whole command.
+	CmdPrefixLen int
+	// Quiet drops the informational summary; warnings are still written.
+	Quiet bool
+	// DryRun parses and dedups as usual but writes nothing, reporting the
+	// sessions it would capture as a dryRunReport instead.
+	DryRun bool
+	// CaptureDiffs stores each changed file's diff hunks with the
+	// checkpoint. Git config rekal.captureDiffs turns it on as well.
+	CaptureDiffs bool
+}
+
+// dryRunReport is what checkpoint --dry-run prints to stderr.
+type dryRunReport struct {
+	Sessions       []dryRunSession `json:"sessions"`
+	TotalSessions  int             `json:"total_sessions"`
+	TotalTurns     int             `json:"total_turns"`
+	TotalToolCalls int             `json:"total_tool_calls"`
+}
+
+type dryRunSession struct {
+	File            string `json:"file"`
+	SessionID       string `json:"session_id,omitempty"` // only with --content-ids; otherwise a new ULID
+	SourceSessionID string `json:"source_session_id,omitempty"`
+	ParentSessionID string `json:"parent_session_id,omitempty"` // Claude Code ID of the session it resumes
+	ActorType       string `json:"actor_type"`
+	Turns           int    `json:"turns"`
+	ToolCalls       int    `json:"tool_calls"`
+}
+
+// contentIDsConfigKey is the git config key that makes every checkpoint
+// derive session IDs from content, as --content-ids does.
+const contentIDsConfigKey = "rekal.contentIds"
+
+// captureDiffsConfigKey is the git config key that makes every checkpoint
+// capture diff hunks, as --capture-diffs does.
+const captureDiffsConfigKey = "rekal.captureDiffs"
+
+// sessionDirConfigKey is the git config key where checkpoint remembers a
+// session directory found by cwd rather than by name.
+const sessionDirConfigKey = "rekal.sessionDir"
+
 func newCheckpointCmd() *cobra.Command {
-	return &cobra.Command{
+	var opts checkpointOptions
+	var cmdPrefixLen int
+
+	cmd := &cobra.Command{
 		Use:   "checkpoint",
 		Short: "Capture the current session after a commit",
 		Long: `Snapshot the active AI session into the local data DB.
@@ -30,7 +100,39 @@ into .rekal/data.db. Each checkpoint is linked to the current HEAD commit and
 records which files were changed.
 
 Normally runs automatically via the post-commit hook installed by 'rekal init'.
-Run manually to capture a session without committing.`,
+Run manually to capture a session without committing.
+
+Use --content-ids to derive session IDs from the conversation content instead
+of time-ordered ULIDs. The same conversation then gets the same ID on every
+machine, so 'rekal sync --self' recognizes it instead of importing a copy.
+'git config rekal.contentIds true' (set by 'rekal init --content-ids') turns
+this on for every checkpoint, including the hook's.
+
+Use --include-thinking to also capture the assistant's thinking blocks as
+turns with role "thinking", so the reasoning behind a change is searchable.
+
+Transcripts are read from <config>/projects/<repo path>/, where <config> is
+$CLAUDE_CONFIG_DIR, $XDG_CONFIG_HOME/claude or ~/.claude, whichever has a
+directory for this repo. Use --session-dir to read from another directory,
+or --file to capture one transcript from anywhere, such as an exported session
+or one that predates rekal. Already captured content is skipped either way.
+
+Transcripts in Claude Code's JSONL and in a generic OpenAI-style chat JSON
+(a "messages" array, alone or in an object with "id", "cwd" and
+"git_branch") are read; --format auto (the default) detects each file's.
+Name one with --format to read only that format's files. Chat transcripts
+have no standard directory, so give --session-dir or --file for them.
+
+Use --cmd-prefix-len to keep more (or, with 0, all) of each command as the tool
+call's cmd_prefix; the default is 100 bytes, cut back to a whole character.
+
+Use --dry-run to see what would be captured without writing anything: the
+sessions, with their turn and tool call counts, are printed to stderr as JSON.
+
+Use --capture-diffs (or 'git config rekal.captureDiffs true', which the hook
+picks up) to also store the diff hunks HEAD made to each changed file, so
+"how did we change this file last time" has the actual change. Diffs are
+pushed with the checkpoint and can make the rekal branch much larger.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -44,37 +146,175 @@ Run manually to capture a session without committing.`,
 				return NewSilentError(err)
 			}
 
-			return runCheckpoint(cmd, gitRoot)
+			switch {
+			case cmdPrefixLen < 0:
+				return fmt.Errorf("--cmd-prefix-len must be 0 or more, got %d", cmdPrefixLen)
+			case cmdPrefixLen == 0:
+				opts.CmdPrefixLen = -1
+			default:
+				opts.CmdPrefixLen = cmdPrefixLen
+			}
+			if opts.Format != session.FormatAuto && session.ParserFor(opts.Format) == nil {
+				return fmt.Errorf("--format must be one of %s, got %q", strings.Join(session.Formats(), ", "), opts.Format)
+			}
+			if opts.File != "" {
+				if opts.SessionDir != "" {
+					return fmt.Errorf("--file and --session-dir are mutually exclusive")
+				}
+				if opts.File, err = filepath.Abs(opts.File); err != nil {
+					return err
+				}
+			}
+			opts.Quiet = isQuiet(cmd)
+			return runCheckpoint(cmd, gitRoot, opts)
 		},
 	}
+
+	cmd.Flags().BoolVar(&opts.ContentIDs, "content-ids", false, "Derive session IDs from conversation content instead of ULIDs")
+	cmd.Flags().BoolVar(&opts.IncludeThinking, "include-thinking", false, "Capture assistant thinking blocks as \"thinking\" turns")
+	cmd.Flags().StringVar(&opts.SessionDir, "session-dir", "", "Read session transcripts from this directory")
+	cmd.Flags().StringVar(&opts.File, "file", "", "Capture this transcript instead of the session directory's")
+	cmd.Flags().StringVar(&opts.Format, "format", session.FormatAuto, "Transcript format: "+strings.Join(session.Formats(), ", "))
+	cmd.Flags().IntVar(&cmdPrefixLen, "cmd-prefix-len", session.DefaultCmdPrefixLen, "Bytes of each tool command kept as cmd_prefix, 0 for the whole command")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print the sessions that would be captured as JSON to stderr without writing")
+	cmd.Flags().BoolVar(&opts.CaptureDiffs, "capture-diffs", false, "Store each changed file's diff hunks with the checkpoint")
+	_ = cmd.MarkFlagDirname("session-dir")
+	_ = cmd.MarkFlagFilename("file", "jsonl", "json")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(session.Formats(), cobra.ShellCompDirectiveNoFileComp))
+	return cmd
 }
 
-func runCheckpoint(cmd *cobra.Command, gitRoot string) error {
-	return doCheckpoint(gitRoot, cmd.ErrOrStderr())
+func runCheckpoint(cmd *cobra.Command, gitRoot string, opts checkpointOptions) error {
+	return doCheckpoint(gitRoot, cmd.ErrOrStderr(), opts)
 }
 
-// doCheckpoint captures the current session after a commit.
-// Extracted so sync can call it without a cobra.Command.
-func doCheckpoint(gitRoot string, w io.Writer) error {
-	// Find session directory for this repo.
-	sessionDir := session.FindSessionDir(gitRoot)
-	if sessionDir == "" {
-		return nil
+// maxToolResultBytes returns the tool_result size cap from git config
+// rekal.maxToolResultBytes, or 0 for session.DefaultMaxToolResultBytes.
+func maxToolResultBytes() int {
+	if v := gitConfigValue("rekal.maxToolResultBytes"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
 	}
+	return 0
+}
+
+// contentIDsConfig reports whether git config rekal.contentIds is true.
+func contentIDsConfig() bool {
+	on, _ := strconv.ParseBool(gitConfigValue(contentIDsConfigKey))
+	return on
+}
 
-	files, err := session.FindSessionFiles(sessionDir)
+// captureDiffsConfig reports whether git config rekal.captureDiffs is true.
+func captureDiffsConfig() bool {
+	on, _ := strconv.ParseBool(gitConfigValue(captureDiffsConfigKey))
+	return on
+}
+
+// discoverSessionDir returns the Claude Code session directory for gitRoot.
+// When the directory named after the repo path has no transcripts, it uses
+// the one remembered in git config rekal.sessionDir, or else scans for a
+// directory whose transcripts ran in gitRoot, remembering that unless
+// remember is false.
+func discoverSessionDir(gitRoot string, remember bool) string {
+	dir := session.FindSessionDir(gitRoot)
+	if files, err := session.FindSessionFiles(dir); err == nil && len(files) > 0 {
+		return dir
+	}
+	if remembered := gitConfigValue(sessionDirConfigKey); remembered != "" {
+		if info, err := os.Stat(remembered); err == nil && info.IsDir() {
+			return remembered
+		}
+	}
+	if found := session.FindSessionDirByCWD(gitRoot); found != "" {
+		if !remember {
+			return found
+		}
+		_ = exec.Command("git", "-C", gitRoot, "config", sessionDirConfigKey, found).Run()
+		return found
+	}
+	return dir
+}
+
+// transcripts returns the source checkpoint reads and the transcripts it
+// holds: opts.Source, opts.File, or the files in opts.SessionDir or the
+// discovered session directory with opts.Format's extension (any known
+// format's in opts.SessionDir when it is auto). An explicit --file or
+// --session-dir must exist; the discovered directory may not yet.
+func transcripts(gitRoot string, opts checkpointOptions) (session.SessionSource, []session.SessionRef, error) {
+	src, err := transcriptSource(gitRoot, opts)
+	if err != nil || src == nil {
+		return nil, nil, err
+	}
+	refs, err := src.List()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		if os.IsNotExist(err) && opts.Source == nil && opts.SessionDir == "" {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("find session files: %w", err)
+	}
+	return src, refs, nil
+}
+
+// transcriptSource picks the SessionSource for opts, or nil when the
+// directory discovery finds none.
+func transcriptSource(gitRoot string, opts checkpointOptions) (session.SessionSource, error) {
+	if opts.Source != nil {
+		return opts.Source, nil
+	}
+	if opts.File != "" {
+		info, err := os.Stat(opts.File)
+		if err != nil {
+			return nil, fmt.Errorf("transcript: %w", err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("transcript %s is a directory; use --session-dir", opts.File)
+		}
+		return session.FileSource{opts.File}, nil
+	}
+
+	parser := session.ParserFor(opts.Format)
+	sessionDir := opts.SessionDir
+	switch {
+	case sessionDir != "":
+	case parser == nil || parser.Name() == session.FormatClaude:
+		// Auto discovers Claude Code's directory, the only standard one.
+		parser = session.ParserFor(session.FormatClaude)
+		if sessionDir = discoverSessionDir(gitRoot, !opts.DryRun); sessionDir == "" {
+			return nil, nil
+		}
+	default:
+		if sessionDir = parser.SessionDir(gitRoot); sessionDir == "" {
+			return nil, fmt.Errorf("%s transcripts have no standard directory; use --session-dir or --file", parser.Name())
 		}
-		return fmt.Errorf("find session files: %w", err)
 	}
-	if len(files) == 0 {
+	return session.DirSource{Dir: sessionDir, Parser: parser}, nil
+}
+
+// doCheckpoint captures the current session after a commit.
+// Extracted so sync can call it without a cobra.Command.
+func doCheckpoint(gitRoot string, w io.Writer, opts checkpointOptions) error {
+	src, refs, err := transcripts(gitRoot, opts)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
 		return nil
 	}
 
-	// Open data DB.
-	dataDB, err := db.OpenData(gitRoot)
+	// Serialize with a concurrent checkpoint, push or sync.
+	unlock, err := lockRepo(gitRoot)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Open data DB; a dry run only reads it.
+	openData := db.OpenData
+	if opts.DryRun {
+		openData = db.OpenDataReadOnly
+	}
+	dataDB, err := openData(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open data DB: %w", err)
 	}
@@ -86,55 +326,104 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 	}
 
 	email := gitConfigValue("user.email")
+	opts.ContentIDs = opts.ContentIDs || contentIDsConfig()
+	parser := session.ParserFor(opts.Format)
+	parseOpts := session.ParseOptions{
+		IncludeThinking:    opts.IncludeThinking,
+		MaxToolResultBytes: maxToolResultBytes(),
+		CmdPrefixLen:       opts.CmdPrefixLen,
+	}
 	entropy := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
 	newID := func() string {
 		return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 	}
 
+	// Everything the run writes — sessions, checkpoint_state, parent links
+	// and the checkpoint — goes through one transaction, so a failure
+	// anywhere leaves nothing behind for the hash dedup to skip: no session
+	// without its checkpoint, and no cached file without its session. The
+	// lookups read through it too, to see the sessions captured so far.
+	var q db.Querier = dataDB
+	var batch *db.Batch
+	if !opts.DryRun {
+		if batch, err = db.BeginBatch(dataDB); err != nil {
+			return err
+		}
+		defer batch.Rollback()
+		q = batch
+	}
+
 	var sessionIDs []string
 	var inserted int
+	var report dryRunReport
+	// Resumed sessions, by the Claude Code ID of the session each resumes.
+	// Linked after the loop so a parent captured in this same run is found.
+	parentSources := make(map[string]string)
 	// Collect unique relative file paths from file-modifying tool_calls across all sessions.
 	toolCallPaths := make(map[string]struct{})
 
-	for _, f := range files {
-		// Incremental: check checkpoint_state to skip unchanged files.
-		info, statErr := os.Stat(f)
-		if statErr != nil {
-			continue
-		}
-
-		data, err := os.ReadFile(f)
+	for _, ref := range refs {
+		f := ref.Key
+		data, err := src.Read(ref)
 		if err != nil {
 			continue
 		}
 		if len(data) == 0 {
 			continue
 		}
+		size := int64(len(data))
 
 		hash := sha256Hex(data)
 
 		// Check cached state — skip if size + hash match.
-		cachedSize, cachedHash, found, csErr := db.GetCheckpointState(dataDB, f)
+		cachedSize, cachedHash, found, csErr := db.GetCheckpointState(q, f)
 		if csErr != nil {
 			return fmt.Errorf("check checkpoint state: %w", csErr)
 		}
-		if found && cachedSize == info.Size() && cachedHash == hash {
+		if found && cachedSize == size && cachedHash == hash {
 			continue
 		}
+		// A renamed transcript: cache it under its new name and skip. The
+		// old name's row is pruned below.
+		if !found {
+			renamed, err := db.CheckpointStateHasContent(q, size, hash)
+			if err != nil {
+				return fmt.Errorf("check checkpoint state: %w", err)
+			}
+			if renamed {
+				if !opts.DryRun {
+					if err := db.UpsertCheckpointState(batch, f, size, hash); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
 
-		exists, err := db.SessionExistsByHash(dataDB, hash)
+		exists, err := db.SessionExistsByHash(q, hash)
 		if err != nil {
 			return fmt.Errorf("dedup check: %w", err)
 		}
 		if exists {
 			// File changed but session already exists (re-parse produced same hash).
 			// Update state cache and skip.
-			_ = db.UpsertCheckpointState(dataDB, f, info.Size(), hash)
+			if !opts.DryRun {
+				if err := db.UpsertCheckpointState(batch, f, size, hash); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
-		payload, err := session.ParseTranscript(data)
+		p := parser
+		if p == nil {
+			p = session.DetectParser(data)
+		}
+		payload, err := p.Parse(data, parseOpts)
 		if err != nil {
+			if opts.File != "" {
+				return fmt.Errorf("parse %s: %w", f, err)
+			}
 			continue
 		}
 
@@ -143,32 +432,62 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 		}
 
 		sessionID := newID()
-		capturedAt := time.Now().UTC()
-
-		// Insert session into DuckDB.
-		if err := db.InsertSession(
-			dataDB, sessionID, "", hash,
-			payload.ActorType, payload.AgentID, email, payload.Branch, capturedAt.Format(time.RFC3339),
-		); err != nil {
-			return fmt.Errorf("insert session: %w", err)
+		if opts.ContentIDs {
+			sessionID = session.ContentID(payload)
+			// Same conversation already captured or imported under this ID.
+			exists, err := db.SessionExistsByID(q, sessionID)
+			if err != nil {
+				return fmt.Errorf("dedup check: %w", err)
+			}
+			if exists {
+				if !opts.DryRun {
+					if err := db.UpsertCheckpointState(batch, f, size, hash); err != nil {
+						return err
+					}
+				}
+				continue
+			}
 		}
 
-		// Insert turns into DuckDB.
-		for i, t := range payload.Turns {
-			ts := ""
-			if !t.Timestamp.IsZero() {
-				ts = t.Timestamp.UTC().Format(time.RFC3339)
+		if opts.DryRun {
+			entry := dryRunSession{
+				File:            f,
+				SourceSessionID: payload.SessionID,
+				ParentSessionID: payload.ParentSessionID,
+				ActorType:       payload.ActorType,
+				Turns:           len(payload.Turns),
+				ToolCalls:       len(payload.ToolCalls),
 			}
-			if err := db.InsertTurn(dataDB, newID(), sessionID, i, t.Role, t.Content, ts); err != nil {
-				return fmt.Errorf("insert turn: %w", err)
+			if opts.ContentIDs {
+				entry.SessionID = sessionID
 			}
+			report.Sessions = append(report.Sessions, entry)
+			report.TotalTurns += entry.Turns
+			report.TotalToolCalls += entry.ToolCalls
+			continue
 		}
+		capturedAt := time.Now().UTC()
 
-		// Insert tool calls into DuckDB.
-		for i, tc := range payload.ToolCalls {
-			if err := db.InsertToolCall(dataDB, newID(), sessionID, i, tc.Tool, tc.Path, tc.CmdPrefix); err != nil {
-				return fmt.Errorf("insert tool_call: %w", err)
-			}
+		// Store each tool call path under one canonical spelling so
+		// "./src/a.go" and "src/a.go" are the same file.
+		cwd := payload.CWD
+		if cwd == "" {
+			cwd = gitRoot
+		}
+		for i := range payload.ToolCalls {
+			payload.ToolCalls[i].Path = db.CanonicalToolPath(gitRoot, cwd, payload.ToolCalls[i].Path)
+		}
+
+		// A failed insert rolls back the whole run, so the next run
+		// tries every file again.
+		if err := insertCapturedSession(batch, gitRoot, sessionID, hash, email, capturedAt, payload, newID, opts.ContentIDs); err != nil {
+			return fmt.Errorf("capture %s: %w", f, err)
+		}
+		if err := db.UpsertCheckpointState(batch, f, size, hash); err != nil {
+			return fmt.Errorf("capture %s: %w", f, err)
+		}
+		if payload.ParentSessionID != "" {
+			parentSources[sessionID] = payload.ParentSessionID
 		}
 
 		// Collect file-modifying tool_call paths for files_touched supplementation.
@@ -181,48 +500,93 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 			default:
 				continue
 			}
-			rel := strings.TrimPrefix(tc.Path, gitRoot+"/")
-			if rel == tc.Path {
+			rel, ok := db.RepoRelativePath(gitRoot, tc.Path)
+			if !ok {
 				// Path is not under gitRoot — external file, skip.
 				continue
 			}
 			toolCallPaths[rel] = struct{}{}
 		}
 
-		// Update checkpoint state cache.
-		_ = db.UpsertCheckpointState(dataDB, f, info.Size(), hash)
-
 		sessionIDs = append(sessionIDs, sessionID)
 		inserted++
 	}
 
+	if opts.DryRun {
+		report.TotalSessions = len(report.Sessions)
+		if report.Sessions == nil {
+			report.Sessions = []dryRunSession{}
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal dry run: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	if inserted > 0 {
+		for id, parentSource := range parentSources {
+			if _, err := db.LinkSessionParent(batch, id, parentSource); err != nil {
+				return err
+			}
+		}
+
+		// Get git state for checkpoint.
+		gitSHA := gitHeadSHA(gitRoot)
+		gitBranch := gitCurrentBranch(gitRoot)
+		filesTouched := gitFilesChanged(gitRoot)
+
+		// Insert the checkpoint, its files and its session links
+		// (exported = FALSE by default).
+		checkpointID := newID()
+		if err := insertCheckpointRows(batch, gitRoot, checkpointID, gitSHA, gitBranch, email, filesTouched, toolCallPaths, sessionIDs, opts.CaptureDiffs || captureDiffsConfig(), newID); err != nil {
+			return err
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	// Drop cached state for transcripts that were deleted or renamed, so
+	// the cache does not grow forever. Failure only costs a re-hash later.
+	_, _ = db.PruneCheckpointState(dataDB)
+
 	if inserted == 0 {
 		return nil
 	}
 
-	// Get git state for checkpoint.
-	gitSHA := gitHeadSHA(gitRoot)
-	gitBranch := gitCurrentBranch(gitRoot)
-	filesTouched := gitFilesChanged(gitRoot)
+	// Incrementally update the index for newly captured sessions.
+	info := w
+	if opts.Quiet {
+		info = io.Discard
+	}
+	if err := updateIndexIncremental(gitRoot, w, info); err != nil {
+		// Non-fatal — index can be rebuilt later with 'rekal index'.
+		fmt.Fprintf(w, "rekal: warning: incremental index update failed: %v\n", err)
+	}
 
-	// Generate checkpoint ULID.
-	checkpointID := newID()
+	fmt.Fprintf(info, "rekal: %d session(s) captured\n", inserted)
+	return nil
+}
 
-	// Insert checkpoint into DuckDB (exported = FALSE by default).
+// insertCheckpointRows writes a checkpoint row, its files_touched (from git
+// diff, then tool call paths git did not report) and its
+// checkpoint_sessions through x.
+func insertCheckpointRows(x db.Execer, gitRoot, checkpointID, gitSHA, gitBranch, email string, filesTouched []fileChange, toolCallPaths map[string]struct{}, sessionIDs []string, captureDiffs bool, newID func() string) error {
 	now := time.Now().UTC()
-	if err := db.InsertCheckpoint(dataDB, checkpointID, gitSHA, gitBranch, email, now.Format(time.RFC3339), "human", ""); err != nil {
+	if err := db.InsertCheckpoint(x, checkpointID, gitSHA, gitBranch, email, now.Format(time.RFC3339), "human", ""); err != nil {
 		return fmt.Errorf("insert checkpoint: %w", err)
 	}
 
-	// Insert files_touched from git diff.
 	gitTouchedSet := make(map[string]struct{})
 	for _, ft := range filesTouched {
-		parts := strings.SplitN(ft, "\t", 2)
-		if len(parts) != 2 {
-			continue
+		gitTouchedSet[ft.path] = struct{}{}
+		diff := ""
+		if captureDiffs {
+			diff = gitFileDiff(gitRoot, ft.path)
 		}
-		gitTouchedSet[parts[1]] = struct{}{}
-		if err := db.InsertFileTouched(dataDB, newID(), checkpointID, parts[1], parts[0]); err != nil {
+		if err := db.InsertFileTouchedDiff(x, newID(), checkpointID, ft.path, ft.changeType, diff); err != nil {
 			return fmt.Errorf("insert file_touched: %w", err)
 		}
 	}
@@ -232,25 +596,78 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 		if _, exists := gitTouchedSet[p]; exists {
 			continue
 		}
-		if err := db.InsertFileTouched(dataDB, newID(), checkpointID, p, "T"); err != nil {
+		if err := db.InsertFileTouched(x, newID(), checkpointID, p, "T"); err != nil {
 			return fmt.Errorf("insert file_touched (tool_call): %w", err)
 		}
 	}
 
-	// Insert checkpoint_sessions junction rows.
 	for _, sid := range sessionIDs {
-		if err := db.InsertCheckpointSession(dataDB, checkpointID, sid); err != nil {
+		if err := db.InsertCheckpointSession(x, checkpointID, sid); err != nil {
 			return fmt.Errorf("insert checkpoint_session: %w", err)
 		}
 	}
+	return nil
+}
 
-	// Incrementally update the index for newly captured sessions.
-	if err := updateIndexIncremental(gitRoot, sessionIDs, checkpointID, w); err != nil {
-		// Non-fatal — index can be rebuilt later with 'rekal index'.
-		fmt.Fprintf(w, "rekal: warning: incremental index update failed: %v\n", err)
+// replayedTurns returns how many of a session's turns, given by content
+// hash, are already in the data DB from the start: the earlier conversation
+// a resumed session replays. Only the leading run counts, so a short reply
+// like "yes" later on is kept even if another session has it too.
+func replayedTurns(q db.Querier, hashes []string) (int, error) {
+	for i, h := range hashes {
+		exists, err := db.TurnExists(q, h)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return i, nil
+		}
 	}
+	return len(hashes), nil
+}
 
-	fmt.Fprintf(w, "rekal: %d session(s) captured\n", inserted)
+// insertCapturedSession writes a parsed transcript's session row, turns and
+// tool calls through x. With skipReplayed, the leading turns already in the
+// data DB are left out; the rest keep their turn_index.
+func insertCapturedSession(x db.Querier, gitRoot, sessionID, hash, email string, capturedAt time.Time, payload *session.SessionPayload, newID func() string, skipReplayed bool) error {
+	if err := db.InsertSession(
+		x, sessionID, "", hash,
+		payload.ActorType, payload.AgentID, email, payload.Branch, db.RepoRelativeDir(gitRoot, payload.CWD), capturedAt.Format(time.RFC3339),
+		payload.TotalCost, payload.TotalDurationMs,
+	); err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	if payload.SessionID != "" && payload.AgentID == "" {
+		if err := db.SetSessionSource(x, sessionID, payload.SessionID); err != nil {
+			return err
+		}
+	}
+	first := 0
+	if skipReplayed {
+		hashes := make([]string, len(payload.Turns))
+		for i, t := range payload.Turns {
+			hashes[i] = db.TurnContentHash(t.Role, t.Content)
+		}
+		var err error
+		if first, err = replayedTurns(x, hashes); err != nil {
+			return err
+		}
+	}
+	for i := first; i < len(payload.Turns); i++ {
+		t := payload.Turns[i]
+		ts := ""
+		if !t.Timestamp.IsZero() {
+			ts = t.Timestamp.UTC().Format(time.RFC3339)
+		}
+		if err := db.InsertTurn(x, newID(), sessionID, i, t.Role, t.Content, ts); err != nil {
+			return fmt.Errorf("insert turn: %w", err)
+		}
+	}
+	for i, tc := range payload.ToolCalls {
+		if err := db.InsertToolCall(x, newID(), sessionID, i, tc.Tool, tc.Server, tc.Path, tc.CmdPrefix, tc.Failed, tc.ErrorSnippet); err != nil {
+			return fmt.Errorf("insert tool_call: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -270,17 +687,93 @@ func gitCurrentBranch(gitRoot string) string {
 	return strings.TrimSpace(string(out))
 }
 
-func gitFilesChanged(gitRoot string) []string {
+// fileChange is one line of `git diff --name-status`.
+type fileChange struct {
+	path       string
+	changeType string // single status letter, e.g. "M" or "R"
+}
+
+// gitFilesChanged returns the files changed by HEAD.
+func gitFilesChanged(gitRoot string) []fileChange {
 	out, err := exec.Command("git", "-C", gitRoot, "diff", "--name-status", "HEAD~1", "HEAD").Output()
 	if err != nil {
 		return nil
 	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	var result []string
-	for _, line := range lines {
-		if strings.TrimSpace(line) != "" {
-			result = append(result, line)
+	return parseNameStatus(string(out))
+}
+
+// maxHunkBytes caps one captured diff hunk. Hunks travel inline in the
+// checkpoint frame, so a generated or minified file's diff must not bloat it.
+const maxHunkBytes = 1<<16 - 1
+
+// gitFileDiff returns the unified diff hunks HEAD made to path, from its
+// first "@@" line on, or "" when there are none (binary or mode-only
+// changes, or no parent commit). A hunk over maxHunkBytes keeps its header
+// line and has its body replaced with a placeholder such as
+// "[hunk elided: 81234 bytes]".
+func gitFileDiff(gitRoot, path string) string {
+	out, err := exec.Command("git", "-C", gitRoot, "--literal-pathspecs", "diff", "--no-color", "--no-ext-diff", "HEAD~1", "HEAD", "--", path).Output()
+	if err != nil {
+		return ""
+	}
+	hunks := splitHunks(strings.ToValidUTF8(string(out), "\uFFFD"))
+	for i, h := range hunks {
+		if len(h) > maxHunkBytes {
+			header, body, _ := strings.Cut(h, "\n")
+			hunks[i] = fmt.Sprintf("%s\n[hunk elided: %d bytes]\n", header, len(body))
+		}
+	}
+	return strings.Join(hunks, "")
+}
+
+// splitHunks splits unified diff output into its hunks, each from an "@@"
+// line up to the next, dropping the file header before the first. Joining
+// the hunks gives back the diff from its first "@@" on.
+func splitHunks(diff string) []string {
+	var hunks []string
+	start := -1
+	for pos := 0; pos < len(diff); {
+		next := len(diff)
+		if i := strings.IndexByte(diff[pos:], '\n'); i >= 0 {
+			next = pos + i + 1
+		}
+		if strings.HasPrefix(diff[pos:], "@@") {
+			if start >= 0 {
+				hunks = append(hunks, diff[start:pos])
+			}
+			start = pos
+		}
+		pos = next
+	}
+	if start >= 0 {
+		hunks = append(hunks, diff[start:])
+	}
+	return hunks
+}
+
+// parseNameStatus parses `git diff --name-status` output. Renames and
+// copies carry a similarity score and two paths ("R100\told\tnew"); they
+// are recorded under the new path, with copies stored as additions since
+// the wire format has no copy change type.
+func parseNameStatus(out string) []fileChange {
+	var result []fileChange
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		status := fields[0][:1]
+		path := fields[1]
+		if status == "R" || status == "C" {
+			if len(fields) < 3 {
+				continue
+			}
+			path = fields[2]
+			if status == "C" {
+				status = "A"
+			}
 		}
+		result = append(result, fileChange{path: path, changeType: status})
 	}
 	return result
 }
@@ -294,17 +787,47 @@ func gitShowFile(gitRoot, ref, path string) []byte {
 	return out
 }
 
+// gitShowStream is the stdout of a running `git show`. Close waits for git to
+// exit and reports its error, e.g. when the path does not exist on the ref.
+type gitShowStream struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (s *gitShowStream) Close() error {
+	if s.cmd == nil {
+		return nil
+	}
+	_ = s.ReadCloser.Close() // unblocks git if the caller stopped early
+	err := s.cmd.Wait()
+	s.cmd = nil
+	return err
+}
+
+// gitShowReader streams a file from a git ref without buffering it in memory.
+// The caller must Close the returned reader.
+func gitShowReader(gitRoot, ref, path string) (io.ReadCloser, error) {
+	cmd := exec.Command("git", "-C", gitRoot, "show", ref+":"+path)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &gitShowStream{ReadCloser: out, cmd: cmd}, nil
+}
+
 func sha256Hex(data []byte) string {
 	h := sha256.Sum256(data)
 	return hex.EncodeToString(h[:])
 }
 
-// updateIndexIncremental adds newly captured sessions to the index DB
-// without a full rebuild. Handles: turns_ft, tool_calls_index, session_facets,
-// files_index, and nomic embeddings. LSA is skipped (requires full corpus).
-// FTS pragma_create_fts_index is not re-run — new rows in turns_ft are
-// automatically indexed by DuckDB's FTS.
-func updateIndexIncremental(gitRoot string, sessionIDs []string, checkpointID string, w io.Writer) error {
+// updateIndexIncremental adds the newly captured sessions, and any others
+// the index lacks, to a built index DB (see indexMissingSessions). An index
+// that has not been built yet is left for 'rekal index' or recall to build
+// in full.
+func updateIndexIncremental(gitRoot string, w, info io.Writer) error {
 	indexPath := filepath.Join(gitRoot, ".rekal", "index.db")
 	if _, err := os.Stat(indexPath); err != nil {
 		// No index DB yet — skip incremental update. Next 'rekal index' or 'rekal sync' will build it.
@@ -317,20 +840,12 @@ func updateIndexIncremental(gitRoot string, sessionIDs []string, checkpointID st
 	}
 	defer indexDB.Close()
 
-	// Populate index tables for new sessions.
-	if err := db.PopulateIndexIncremental(indexDB, gitRoot, sessionIDs, checkpointID); err != nil {
-		return fmt.Errorf("populate index: %w", err)
-	}
-
-	// Nomic embeddings for new sessions (non-fatal).
-	sessionContent, err := db.QuerySessionContentByIDs(indexDB, sessionIDs)
-	if err != nil || len(sessionContent) == 0 {
-		return err
+	if !db.IsIndexPopulated(indexDB) {
+		return nil
 	}
-
-	if err := buildNomicEmbeddings(indexDB, sessionContent, w); err != nil {
-		fmt.Fprintf(w, "rekal: warning: nomic embeddings skipped: %v\n", err)
+	if err := db.LoadFTSExtension(indexDB); err != nil {
+		return fmt.Errorf("load fts extension: %w", err)
 	}
-
-	return nil
+	_, err = indexMissingSessions(indexDB, gitRoot, w, info)
+	return err
 }
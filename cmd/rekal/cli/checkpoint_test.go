@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	t.Parallel()
+	out := "M\tsrc/auth.go\nA\tsrc/new.go\nD\told.go\nR100\tsrc/a.go\tsrc/b.go\nC075\tsrc/tmpl.go\tsrc/copy.go\nR087\tbroken\n\n"
+	want := []fileChange{
+		{path: "src/auth.go", changeType: "M"},
+		{path: "src/new.go", changeType: "A"},
+		{path: "old.go", changeType: "D"},
+		{path: "src/b.go", changeType: "R"},
+		{path: "src/copy.go", changeType: "A"},
+	}
+	if got := parseNameStatus(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNameStatus:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSplitHunks(t *testing.T) {
+	t.Parallel()
+	diff := "diff --git a/a.go b/a.go\nindex 1..2 100644\n--- a/a.go\n+++ b/a.go\n" +
+		"@@ -1,2 +1,2 @@ func a()\n-old\n+new\n ctx\n" +
+		"@@ -9 +9 @@\n-x\n+y\n\\ No newline at end of file"
+	want := []string{
+		"@@ -1,2 +1,2 @@ func a()\n-old\n+new\n ctx\n",
+		"@@ -9 +9 @@\n-x\n+y\n\\ No newline at end of file",
+	}
+	if got := splitHunks(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitHunks:\n got %q\nwant %q", got, want)
+	}
+	if got := splitHunks("Binary files a/x.png and b/x.png differ\n"); got != nil {
+		t.Errorf("splitHunks of a binary diff = %q, want none", got)
+	}
+}
+
+// memSource is a SessionSource holding transcripts in memory, keyed by name.
+type memSource map[string]string
+
+func (s memSource) List() ([]session.SessionRef, error) {
+	var refs []session.SessionRef
+	for k := range s {
+		refs = append(refs, session.SessionRef{Key: k})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Key < refs[j].Key })
+	return refs, nil
+}
+
+func (s memSource) Read(ref session.SessionRef) ([]byte, error) {
+	return []byte(s[ref.Key]), nil
+}
+
+func TestDoCheckpoint_SessionSource(t *testing.T) {
+	gitRoot := t.TempDir()
+	if err := exec.Command("git", "init", "-q", gitRoot).Run(); err != nil {
+		t.Fatalf("git init: %v", err)
+	}
+	commit := exec.Command("git", "-C", gitRoot, "commit", "-q", "--allow-empty", "-m", "initial")
+	commit.Env = append(commit.Environ(),
+		"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+	if err := commit.Run(); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(gitRoot, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitDataSchema(dataDB); err != nil {
+		t.Fatal(err)
+	}
+	dataDB.Close()
+
+	src := memSource{
+		"db://sessions/1": `{"type":"user","sessionId":"s1","message":{"role":"user","content":"rotate the signing key"},"timestamp":"2026-03-01T10:00:00Z"}` + "\n",
+		"db://sessions/2": `[{"role":"user","content":"why is the cache cold?"},{"role":"assistant","content":"The TTL is zero."}]`,
+	}
+	var out strings.Builder
+	if err := doCheckpoint(gitRoot, &out, checkpointOptions{Source: src}); err != nil {
+		t.Fatalf("doCheckpoint: %v (output: %s)", err, out.String())
+	}
+	if !strings.Contains(out.String(), "2 session(s) captured") {
+		t.Errorf("output = %q, want 2 sessions captured", out.String())
+	}
+
+	dataDB, err = db.OpenData(gitRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataDB.Close()
+	var sessions, turns, cached int
+	if err := dataDB.QueryRow("SELECT count(*) FROM sessions").Scan(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if err := dataDB.QueryRow("SELECT count(*) FROM turns").Scan(&turns); err != nil {
+		t.Fatal(err)
+	}
+	if err := dataDB.QueryRow("SELECT count(*) FROM checkpoint_state WHERE file_path LIKE 'db://sessions/%'").Scan(&cached); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 2 || turns != 3 || cached != 2 {
+		t.Errorf("sessions = %d, turns = %d, cached = %d; want 2, 3 and 2", sessions, turns, cached)
+	}
+}
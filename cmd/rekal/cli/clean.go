@@ -2,7 +2,9 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -20,6 +22,12 @@ Removes:
   .rekal/           Data DB, index DB, and all local state
   post-commit hook   Only if it contains the rekal marker
   pre-push hook      Only if it contains the rekal marker
+  rekal.sessionDir   Session directory remembered in git config by checkpoint
+
+Linked worktrees share their hooks, so the hooks are kept while another
+worktree of the repository still has a .rekal/ directory. Hooks in a
+core.hooksPath outside the repository may be shared with other
+repositories, so they are left alone with a warning.
 
 Run 'rekal init' to reinitialize after cleaning.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -31,7 +39,7 @@ Run 'rekal init' to reinitialize after cleaning.`,
 				return NewSilentError(err)
 			}
 
-			if err := runClean(gitRoot); err != nil {
+			if err := runClean(gitRoot, cmd.ErrOrStderr()); err != nil {
 				fmt.Fprintln(cmd.ErrOrStderr(), err)
 				return NewSilentError(err)
 			}
@@ -42,18 +50,85 @@ Run 'rekal init' to reinitialize after cleaning.`,
 	}
 }
 
-// runClean removes .rekal/ and Rekal hooks. Idempotent.
-func runClean(gitRoot string) error {
+// runClean removes .rekal/ and Rekal hooks, warning to w about hooks it
+// leaves in a shared directory. Idempotent.
+func runClean(gitRoot string, w io.Writer) error {
 	rekalDir := RekalDir(gitRoot)
 	if err := os.RemoveAll(rekalDir); err != nil {
 		return fmt.Errorf("remove .rekal/: %w", err)
 	}
-	removeHook(filepath.Join(gitRoot, ".git", "hooks", "post-commit"))
-	removeHook(filepath.Join(gitRoot, ".git", "hooks", "pre-push"))
+	if hooksDir, err := gitHooksDir(gitRoot); err == nil && !otherWorktreeInitialized(gitRoot) {
+		if inRepository(gitRoot, hooksDir) {
+			removeHook(filepath.Join(hooksDir, "post-commit"))
+			removeHook(filepath.Join(hooksDir, "pre-push"))
+		} else {
+			fmt.Fprintf(w, "rekal: warning: hooks directory %s (core.hooksPath) is outside this repository and may be shared; its rekal hooks were left in place\n", hooksDir)
+		}
+	}
+	// Forget a session directory checkpoint found by cwd; unset fails
+	// harmlessly when there is none.
+	_ = exec.Command("git", "-C", gitRoot, "config", "--unset", sessionDirConfigKey).Run()
 	return nil
 }
 
-// removeHook deletes a hook file only if it contains the rekal marker.
+// otherWorktreeInitialized reports whether a worktree of gitRoot's
+// repository other than gitRoot itself has a .rekal/ directory, and so still
+// needs the shared hooks.
+func otherWorktreeInitialized(gitRoot string) bool {
+	out, err := exec.Command("git", "-C", gitRoot, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return false
+	}
+	self, err := filepath.EvalSymlinks(gitRoot)
+	if err != nil {
+		self = gitRoot
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		dir, ok := strings.CutPrefix(line, "worktree ")
+		if !ok {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if dir == self {
+			continue
+		}
+		if info, err := os.Stat(RekalDir(dir)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// inRepository reports whether dir is inside gitRoot's work tree or its
+// repository's git dir, rather than a hooks directory core.hooksPath shares
+// between repositories (a global one in ~/.config, say).
+func inRepository(gitRoot, dir string) bool {
+	roots := []string{gitRoot}
+	if out, err := exec.Command("git", "-C", gitRoot, "rev-parse", "--git-common-dir").Output(); err == nil {
+		common := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitRoot, common)
+		}
+		roots = append(roots, common)
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeHook deletes a hook file only if it contains the rekal marker. A
+// hook rekal added a block to keeps everything but the block.
 func removeHook(path string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -61,5 +136,13 @@ func removeHook(path string) {
 	}
 	if strings.Contains(string(data), rekalHookMarker) {
 		_ = os.Remove(path)
+		return
+	}
+	if stripped, ok := stripHookBlock(string(data)); ok {
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		_ = os.WriteFile(path, []byte(stripped), info.Mode().Perm())
 	}
 }
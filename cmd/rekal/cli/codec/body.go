@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 )
 
 const (
@@ -102,3 +103,154 @@ func ScanFrames(body []byte) ([]FrameSlice, error) {
 func ExtractFramePayload(body []byte, fs FrameSlice) []byte {
 	return body[fs.PayloadOffset : fs.PayloadOffset+fs.CompressedLen]
 }
+
+// FrameReader reads frames from a rekal.body stream one at a time, so large
+// bodies can be decoded without holding the whole blob in memory.
+type FrameReader struct {
+	r          io.Reader
+	headerRead bool
+	offset     int // byte offset of the next envelope
+}
+
+// NewFrameReader returns a FrameReader that reads the body header and frames from r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: r}
+}
+
+// Next returns the type and compressed payload of the next frame.
+// It returns io.EOF after the last frame. As with ScanFrames, trailing bytes
+// too short to hold an envelope are ignored.
+func (fr *FrameReader) Next() (FrameType, []byte, error) {
+	if !fr.headerRead {
+		hdr := make([]byte, bodyHdrSize)
+		if _, err := io.ReadFull(fr.r, hdr); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, nil, errors.New("body: data too short for header")
+			}
+			return 0, nil, fmt.Errorf("body: read header: %w", err)
+		}
+		if magic := string(hdr[0:7]); magic != bodyMagic {
+			return 0, nil, fmt.Errorf("body: bad magic %q, want %q", magic, bodyMagic)
+		}
+		fr.headerRead = true
+		fr.offset = bodyHdrSize
+	}
+
+	var env [frameEnvSize]byte
+	if _, err := io.ReadFull(fr.r, env[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, fmt.Errorf("body: read envelope at offset %d: %w", fr.offset, err)
+	}
+	ft := FrameType(env[0])
+	compLen := int(env[1]) | int(env[2])<<8 | int(env[3])<<16
+
+	payload := make([]byte, compLen)
+	if n, err := io.ReadFull(fr.r, payload); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return 0, nil, fmt.Errorf("body: frame at offset %d truncated (need %d bytes, have %d)",
+				fr.offset, compLen, n)
+		}
+		return 0, nil, fmt.Errorf("body: read frame at offset %d: %w", fr.offset, err)
+	}
+
+	fr.offset += frameEnvSize + compLen
+	return ft, payload, nil
+}
+
+// DecodedBody holds the decoded frames of a rekal.body, grouped by type.
+// Each Offsets slice is parallel to its frame slice and gives the frame's
+// byte offset in the body, so callers can recover the original order.
+type DecodedBody struct {
+	Sessions          []*SessionFrame
+	SessionOffsets    []int
+	Checkpoints       []*CheckpointFrame
+	CheckpointOffsets []int
+	Metas             []*MetaFrame
+	MetaOffsets       []int
+	// Skipped counts frames that failed to decode or whose session or
+	// checkpoint ref is not in the dictionary. Tombstones are not counted.
+	Skipped int
+}
+
+// DecodeBody scans and decodes every frame in body. Malformed frames are
+// skipped rather than failing the whole body, matching how import treats
+// them. If dict is non-nil, session and checkpoint frames whose ref it
+// cannot resolve are skipped too.
+func DecodeBody(body []byte, dict *Dict) (*DecodedBody, error) {
+	dec, err := NewDecoder()
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return DecodeBodyWith(dec, body, dict)
+}
+
+// DecodeBodyWith is DecodeBody decoding with dec, which must know the zstd
+// dictionaries body's frames were compressed with.
+func DecodeBodyWith(dec *Decoder, body []byte, dict *Dict) (*DecodedBody, error) {
+	frames, err := ScanFrames(body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &DecodedBody{}
+	for _, fs := range frames {
+		f, ok := DecodeFrame(dec, fs.Type, ExtractFramePayload(body, fs), dict)
+		switch {
+		case !ok:
+			out.Skipped++
+		case f.Session != nil:
+			out.Sessions = append(out.Sessions, f.Session)
+			out.SessionOffsets = append(out.SessionOffsets, fs.Offset)
+		case f.Checkpoint != nil:
+			out.Checkpoints = append(out.Checkpoints, f.Checkpoint)
+			out.CheckpointOffsets = append(out.CheckpointOffsets, fs.Offset)
+		case f.Meta != nil:
+			out.Metas = append(out.Metas, f.Meta)
+			out.MetaOffsets = append(out.MetaOffsets, fs.Offset)
+		}
+	}
+	return out, nil
+}
+
+// DecodedFrame is one decoded frame. At most one field is set; none is for
+// tombstones and frame types this version doesn't know.
+type DecodedFrame struct {
+	Session    *SessionFrame
+	Checkpoint *CheckpointFrame
+	Meta       *MetaFrame
+}
+
+// DecodeFrame decodes one frame of type ft from its compressed payload, as
+// DecodeBody does for each frame in a body; it is the step FrameReader
+// callers use. ok is false for a frame DecodeBody would skip: one that fails
+// to decode, or, if dict is non-nil, a session or checkpoint frame whose ref
+// dict cannot resolve.
+func DecodeFrame(dec *Decoder, ft FrameType, compressed []byte, dict *Dict) (f DecodedFrame, ok bool) {
+	resolves := func(ref uint64) bool {
+		if dict == nil {
+			return true
+		}
+		_, err := dict.Get(NSSessions, ref)
+		return err == nil
+	}
+
+	var err error
+	switch ft {
+	case FrameSession:
+		if f.Session, err = dec.DecodeSessionFrame(compressed); err != nil || !resolves(f.Session.SessionRef) {
+			return DecodedFrame{}, false
+		}
+	case FrameCheckpoint:
+		if f.Checkpoint, err = dec.DecodeCheckpointFrame(compressed); err != nil || !resolves(f.Checkpoint.CheckpointRef) {
+			return DecodedFrame{}, false
+		}
+	case FrameMeta:
+		if f.Meta, err = dec.DecodeMetaFrame(compressed); err != nil {
+			return DecodedFrame{}, false
+		}
+	}
+	return f, true
+}
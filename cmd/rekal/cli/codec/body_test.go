@@ -1,7 +1,10 @@
 package codec
 
 import (
+	"bytes"
+	"io"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -268,3 +271,190 @@ func BenchmarkBodyAppendAndScan(b *testing.B) {
 		_, _ = ScanFrames(body)
 	}
 }
+
+func TestFrameReader_OneByteReads(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	body := NewBody()
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "hello"}},
+	}))
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{
+		FormatVersion: 0x01,
+		Timestamp:     time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		NSessions:     1,
+	}))
+
+	want, err := ScanFrames(body)
+	if err != nil {
+		t.Fatalf("ScanFrames: %v", err)
+	}
+
+	fr := NewFrameReader(iotest.OneByteReader(bytes.NewReader(body)))
+	for i, fs := range want {
+		ft, payload, err := fr.Next()
+		if err != nil {
+			t.Fatalf("frame %d: Next: %v", i, err)
+		}
+		if ft != fs.Type {
+			t.Errorf("frame %d: got type %x, want %x", i, ft, fs.Type)
+		}
+		if !bytes.Equal(payload, ExtractFramePayload(body, fs)) {
+			t.Errorf("frame %d: payload mismatch", i)
+		}
+	}
+	if _, _, err := fr.Next(); err != io.EOF {
+		t.Errorf("after last frame: got %v, want io.EOF", err)
+	}
+}
+
+func TestFrameReader_Truncated(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	body := AppendFrame(NewBody(), enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 0x01}))
+	fr := NewFrameReader(bytes.NewReader(body[:len(body)-1]))
+	if _, _, err := fr.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected truncation error, got %v", err)
+	}
+}
+
+func TestFrameReader_BadMagic(t *testing.T) {
+	fr := NewFrameReader(bytes.NewReader([]byte("BADMAGIC\x00")))
+	if _, _, err := fr.Next(); err == nil {
+		t.Error("expected error for bad magic")
+	}
+}
+
+func TestDecodeBody_Mixed(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	dict := NewDict()
+	sessionRef := dict.LookupOrAdd(NSSessions, "01JNQX0000000000000000SES1")
+	checkpointRef := dict.LookupOrAdd(NSSessions, "01JNQX0000000000000000CKP1")
+	ts := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	body := NewBody()
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{
+		SessionRef: sessionRef,
+		CapturedAt: ts,
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "fix the bug"}},
+	}))
+	body = AppendFrame(body, enc.EncodeCheckpointFrame(&CheckpointFrame{
+		GitSHA:        "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		CheckpointRef: checkpointRef,
+		Timestamp:     ts,
+		SessionRefs:   []uint64{sessionRef},
+	}))
+	// Session frame with a ref the dict does not know — skipped.
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{SessionRef: 99, CapturedAt: ts}))
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{
+		FormatVersion: 0x01,
+		Timestamp:     ts,
+		NSessions:     1,
+		NCheckpoints:  1,
+	}))
+
+	decoded, err := DecodeBody(body, dict)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	if len(decoded.Sessions) != 1 || len(decoded.Checkpoints) != 1 || len(decoded.Metas) != 1 {
+		t.Fatalf("got %d sessions, %d checkpoints, %d metas; want 1 each",
+			len(decoded.Sessions), len(decoded.Checkpoints), len(decoded.Metas))
+	}
+	if decoded.Skipped != 1 {
+		t.Errorf("skipped: got %d, want 1", decoded.Skipped)
+	}
+	if decoded.Sessions[0].Turns[0].Text != "fix the bug" {
+		t.Errorf("session turn text: %q", decoded.Sessions[0].Turns[0].Text)
+	}
+	if decoded.Checkpoints[0].GitSHA != "aaa111bbb222ccc333ddd444eee555fff666aaa1" {
+		t.Errorf("checkpoint git_sha: %q", decoded.Checkpoints[0].GitSHA)
+	}
+	if decoded.Metas[0].NCheckpoints != 1 {
+		t.Errorf("meta n_checkpoints: %d", decoded.Metas[0].NCheckpoints)
+	}
+
+	// Offsets preserve body order.
+	if decoded.SessionOffsets[0] != bodyHdrSize {
+		t.Errorf("session offset: got %d, want %d", decoded.SessionOffsets[0], bodyHdrSize)
+	}
+	if !(decoded.SessionOffsets[0] < decoded.CheckpointOffsets[0] && decoded.CheckpointOffsets[0] < decoded.MetaOffsets[0]) {
+		t.Errorf("offsets out of order: session %d, checkpoint %d, meta %d",
+			decoded.SessionOffsets[0], decoded.CheckpointOffsets[0], decoded.MetaOffsets[0])
+	}
+
+	// Without a dict nothing is filtered by ref.
+	all, err := DecodeBody(body, nil)
+	if err != nil {
+		t.Fatalf("DecodeBody nil dict: %v", err)
+	}
+	if len(all.Sessions) != 2 || all.Skipped != 0 {
+		t.Errorf("nil dict: got %d sessions, %d skipped", len(all.Sessions), all.Skipped)
+	}
+}
+
+func TestDecodeFrame_FrameReader(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	dict := NewDict()
+	ref := dict.LookupOrAdd(NSSessions, "01JNQX0000000000000000SES1")
+	ts := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	body := NewBody()
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{SessionRef: ref, CapturedAt: ts}))
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{SessionRef: 99, CapturedAt: ts}))
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 0x01, Timestamp: ts}))
+
+	var sessions, metas, skipped int
+	fr := NewFrameReader(bytes.NewReader(body))
+	for {
+		ft, compressed, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		f, ok := DecodeFrame(dec, ft, compressed, dict)
+		switch {
+		case !ok:
+			skipped++
+		case f.Session != nil:
+			sessions++
+		case f.Meta != nil:
+			metas++
+		}
+	}
+	if sessions != 1 || metas != 1 || skipped != 1 {
+		t.Errorf("got %d sessions, %d metas, %d skipped; want 1 each", sessions, metas, skipped)
+	}
+}
+
+func TestDecodeBody_BadMagic(t *testing.T) {
+	if _, err := DecodeBody([]byte("BADMAGIC\x00"), nil); err == nil {
+		t.Error("expected error for bad magic")
+	}
+}
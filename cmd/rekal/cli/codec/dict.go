@@ -1,9 +1,11 @@
 package codec
 
 import (
+	"cmp"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"slices"
 )
 
 // Namespace identifies a section in dict.bin.
@@ -35,8 +37,17 @@ type Dict struct {
 	branchIdx map[string]uint64
 	emailIdx  map[string]uint64
 	pathIdx   map[string]uint64
+
+	// limits caps namespaces for Intern; asks counts Intern calls per value
+	// that were not interned yet.
+	limits map[Namespace]int
+	asks   map[Namespace]map[string]int
 }
 
+// internHotRefs is how many times Intern must be asked for a new value
+// before adding it to a namespace near its limit.
+const internHotRefs = 2
+
 // NewDict creates an empty dictionary.
 func NewDict() *Dict {
 	return &Dict{
@@ -60,6 +71,87 @@ func (d *Dict) LookupOrAdd(ns Namespace, value string) uint64 {
 	return i
 }
 
+// SetLimit caps how many entries Intern lets namespace ns grow to; n <= 0
+// removes the cap. LookupOrAdd ignores it.
+func (d *Dict) SetLimit(ns Namespace, n int) {
+	if d.limits == nil {
+		d.limits = make(map[Namespace]int)
+	}
+	if n <= 0 {
+		delete(d.limits, ns)
+		return
+	}
+	d.limits[ns] = n
+}
+
+// Intern is LookupOrAdd for refs that have an inline fallback: everything
+// in NSPaths, that is tool call and files touched paths and MCP tool names.
+// A value already in ns is always returned. A new one is added while ns is
+// below 90% of its limit; past that only once Intern has been asked for it
+// internHotRefs times, so the remaining room goes to values referenced
+// again, and not at all once ns is at its limit. ok is false when the
+// caller should inline value instead. Call Admit first when the values to
+// be written are known, so the room goes to the most referenced ones.
+//
+// There is no eviction. Frames already pushed refer to entries by index
+// and dict.bin is append-only, so dropping or replacing an entry would
+// change what those frames decode to. The cap therefore only decides which
+// new values get the remaining room; a full namespace stays full. Only
+// NSPaths has an inline form, so it is the one namespace worth capping;
+// sessions, branches and emails are always refs.
+func (d *Dict) Intern(ns Namespace, value string) (index uint64, ok bool) {
+	if i, ok := d.Lookup(ns, value); ok {
+		return i, true
+	}
+	limit, capped := d.limits[ns]
+	n := d.Len(ns)
+	if capped && n >= limit-limit/10 {
+		if d.asks == nil {
+			d.asks = make(map[Namespace]map[string]int)
+		}
+		if d.asks[ns] == nil {
+			d.asks[ns] = make(map[string]int)
+		}
+		d.asks[ns][value]++
+		if n >= limit || d.asks[ns][value] < internHotRefs {
+			return 0, false
+		}
+		delete(d.asks[ns], value)
+	}
+	return d.LookupOrAdd(ns, value), true
+}
+
+// Admit adds the new values in refs, a count of how many times each is
+// about to be referenced, to ns in order of that count, most referenced
+// first (ties by value, for a stable dict.bin). Under ns's limit it admits
+// them as Intern would, except that the room past 90% goes to the values
+// counted internHotRefs times or more in rank order, instead of whichever
+// Intern is asked for twice first. Values left out are inlined by Intern.
+func (d *Dict) Admit(ns Namespace, refs map[string]int) {
+	values := make([]string, 0, len(refs))
+	for v := range refs {
+		if _, ok := d.Lookup(ns, v); !ok {
+			values = append(values, v)
+		}
+	}
+	slices.SortFunc(values, func(a, b string) int {
+		if c := cmp.Compare(refs[b], refs[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	limit, capped := d.limits[ns]
+	for _, v := range values {
+		// Counts only fall from here, so once a value is turned away
+		// every later one would be too.
+		n := d.Len(ns)
+		if capped && (n >= limit || (n >= limit-limit/10 && refs[v] < internHotRefs)) {
+			break
+		}
+		d.LookupOrAdd(ns, v)
+	}
+}
+
 // Lookup returns the index for value without adding it.
 // Returns (index, true) if found, (0, false) if not.
 func (d *Dict) Lookup(ns Namespace, value string) (uint64, bool) {
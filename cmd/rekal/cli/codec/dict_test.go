@@ -1,7 +1,10 @@
 package codec
 
 import (
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestNewDict_Empty(t *testing.T) {
@@ -151,6 +154,86 @@ func TestDict_EncodeLoadRoundtrip(t *testing.T) {
 	}
 }
 
+func TestDict_InternLimit(t *testing.T) {
+	d := NewDict()
+	d.SetLimit(NSPaths, 20)
+
+	// Below 90% of the limit every new path is interned.
+	for i := 0; i < 18; i++ {
+		if _, ok := d.Intern(NSPaths, fmt.Sprintf("src/base%d.go", i)); !ok {
+			t.Fatalf("path %d not interned below the soft limit", i)
+		}
+	}
+
+	// Past it, a path asked for once is inlined and one asked for again is
+	// interned, until the limit.
+	wantRef := map[string]bool{}
+	var paths []string
+	for i := 0; i < 5; i++ {
+		cold := fmt.Sprintf("tmp/scratch%d.txt", i)
+		hot := fmt.Sprintf("src/hot%d.go", i)
+		paths = append(paths, cold, hot, hot, hot)
+		wantRef[hot] = i < 2
+	}
+	paths = append(paths, "src/base3.go")
+	wantRef["src/base3.go"] = true
+
+	sf := &SessionFrame{CapturedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), ActorType: ActorHuman}
+	for _, p := range paths {
+		tc := ToolCallRecord{Tool: ToolRead}
+		if ref, ok := d.Intern(NSPaths, p); ok {
+			tc.PathFlag, tc.PathRef = PathDictRef, ref
+		} else {
+			tc.PathFlag, tc.PathInline = PathInline, p
+		}
+		sf.ToolCalls = append(sf.ToolCalls, tc)
+	}
+	if d.Len(NSPaths) != 20 {
+		t.Fatalf("paths = %d, want the limit of 20", d.Len(NSPaths))
+	}
+	for p, ref := range wantRef {
+		if _, ok := d.Lookup(NSPaths, p); ok != ref {
+			t.Errorf("%s in dict = %v, want %v", p, ok, ref)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := d.Lookup(NSPaths, fmt.Sprintf("tmp/scratch%d.txt", i)); ok {
+			t.Errorf("cold path %d was interned", i)
+		}
+	}
+
+	// Every path still resolves after the dict and frame roundtrip.
+	d2, err := LoadDict(d.Encode())
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	got, err := dec.DecodeSessionFrame(enc.EncodeSessionFrame(sf)[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for i, tc := range got.ToolCalls {
+		path := tc.PathInline
+		if tc.PathFlag == PathDictRef {
+			if path, err = d2.Get(NSPaths, tc.PathRef); err != nil {
+				t.Fatalf("tool call %d: %v", i, err)
+			}
+		}
+		if path != paths[i] {
+			t.Errorf("tool call %d path = %q, want %q", i, path, paths[i])
+		}
+	}
+}
+
 func TestDict_EmptyEncode(t *testing.T) {
 	d := NewDict()
 	encoded := d.Encode()
@@ -218,3 +301,47 @@ func BenchmarkDictLoad(b *testing.B) {
 		_, _ = LoadDict(encoded)
 	}
 }
+
+func TestDict_AdmitRanksByReferences(t *testing.T) {
+	d := NewDict()
+	d.SetLimit(NSPaths, 20)
+	for i := 0; i < 15; i++ {
+		d.LookupOrAdd(NSPaths, fmt.Sprintf("src/base%d.go", i))
+	}
+
+	// Three slots up to 90% go to the most referenced values, the two past
+	// it only to values referenced at least twice.
+	refs := map[string]int{
+		"src/base0.go": 50, // already in the dict
+		"a.go":         1,
+		"b.go":         9,
+		"c.go":         2,
+		"d.go":         7,
+		"e.go":         2,
+		"f.go":         1,
+		"g.go":         3,
+	}
+	d.Admit(NSPaths, refs)
+	if d.Len(NSPaths) != 20 {
+		t.Fatalf("paths = %d, want the limit of 20", d.Len(NSPaths))
+	}
+	want := []string{"b.go", "d.go", "g.go", "c.go", "e.go"}
+	if got := d.Paths[15:]; !slices.Equal(got, want) {
+		t.Errorf("admitted %v, want %v", got, want)
+	}
+	if _, ok := d.Intern(NSPaths, "a.go"); ok {
+		t.Error("a value left out by Admit was interned at the limit")
+	}
+}
+
+func TestDict_AdmitKeepsColdValuesOutPastSoftLimit(t *testing.T) {
+	d := NewDict()
+	d.SetLimit(NSPaths, 20)
+	for i := 0; i < 18; i++ {
+		d.LookupOrAdd(NSPaths, fmt.Sprintf("src/base%d.go", i))
+	}
+	d.Admit(NSPaths, map[string]int{"hot.go": 4, "cold1.go": 1, "cold2.go": 1})
+	if !slices.Equal(d.Paths[18:], []string{"hot.go"}) {
+		t.Errorf("admitted %v, want only hot.go", d.Paths[18:])
+	}
+}
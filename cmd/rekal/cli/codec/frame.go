@@ -10,14 +10,19 @@ import (
 
 // Tool codes for binary encoding.
 const (
-	ToolWrite   byte = 0x00
-	ToolRead    byte = 0x01
-	ToolBash    byte = 0x02
-	ToolEdit    byte = 0x03
-	ToolGlob    byte = 0x04
-	ToolGrep    byte = 0x05
-	ToolTask    byte = 0x06
-	ToolUnknown byte = 0xFF
+	ToolWrite        byte = 0x00
+	ToolRead         byte = 0x01
+	ToolBash         byte = 0x02
+	ToolEdit         byte = 0x03
+	ToolGlob         byte = 0x04
+	ToolGrep         byte = 0x05
+	ToolTask         byte = 0x06
+	ToolMCP          byte = 0x07 // MCP server tool; the full name follows as a dict ref or inline
+	ToolNotebookEdit byte = 0x08
+	ToolMultiEdit    byte = 0x09
+	ToolWebFetch     byte = 0x0A
+	ToolWebSearch    byte = 0x0B
+	ToolUnknown      byte = 0xFF
 )
 
 // Path flag values.
@@ -37,8 +42,31 @@ const (
 const (
 	RoleHuman     byte = 0x00
 	RoleAssistant byte = 0x01
+	RoleThinking  byte = 0x02 // assistant thinking, captured with --include-thinking
 )
 
+// RoleCode returns the wire code for a turn role name. Unknown roles map to RoleHuman.
+func RoleCode(name string) byte {
+	switch name {
+	case "assistant":
+		return RoleAssistant
+	case "thinking":
+		return RoleThinking
+	}
+	return RoleHuman
+}
+
+// RoleName returns the turn role name for a wire code. Unknown codes map to "human".
+func RoleName(code byte) string {
+	switch code {
+	case RoleAssistant:
+		return "assistant"
+	case RoleThinking:
+		return "thinking"
+	}
+	return "human"
+}
+
 // Change type values (ASCII bytes).
 const (
 	ChangeAdded    byte = 'A'
@@ -53,7 +81,34 @@ var (
 	metaMagic       = []byte("RKLM")
 )
 
-const payloadVersion = 0x01
+const (
+	payloadVersion = 0x01
+
+	// sessionPayloadVersion is the current session frame layout. Version 0x05
+	// puts a path flag before the ToolMCP name, so a name past the dict.bin
+	// paths cap can be inline. Version 0x04 encodes turn ts_delta as a signed
+	// (zigzag) varint. Version 0x03 adds a name ref after ToolMCP tool codes.
+	// Version 0x02 encodes n_turns and n_tools as uvarints; 0x01 used single
+	// bytes. All are still accepted on decode.
+	sessionPayloadVersion   = 0x05
+	sessionPayloadVersionV4 = 0x04
+	sessionPayloadVersionV3 = 0x03
+	sessionPayloadVersionV2 = 0x02
+	sessionPayloadVersionV1 = 0x01
+
+	// checkpointPayloadVersion is the checkpoint frame layout when any file
+	// path is inline: version 0x04 puts a path flag before each file's path,
+	// as tool calls have, so a path past the dict.bin paths cap can be
+	// inline. Otherwise a frame where any file carries diff hunks is written
+	// as version 0x03, which appends each file's hunks inline after the file
+	// records, where older readers stop; version 0x04 appends them too.
+	// Version 0x02 appended NSPaths refs to the hunks instead and is still
+	// accepted on decode. Frames with neither are still written as
+	// payloadVersion (0x01).
+	checkpointPayloadVersion   = 0x04
+	checkpointPayloadVersionV3 = 0x03
+	checkpointPayloadVersionV2 = 0x02
+)
 
 // SessionFrame is the decoded content of a session frame (0x01).
 type SessionFrame struct {
@@ -69,7 +124,7 @@ type SessionFrame struct {
 // TurnRecord is a single conversation turn.
 type TurnRecord struct {
 	Role      byte
-	TsDelta   uint64 // seconds since previous turn
+	TsDelta   int64 // seconds since previous turn; negative if its timestamp is earlier (clock skew)
 	BranchRef uint64
 	Text      string
 }
@@ -77,6 +132,9 @@ type TurnRecord struct {
 // ToolCallRecord is a single tool invocation.
 type ToolCallRecord struct {
 	Tool       byte
+	NameFlag   byte   // PathDictRef or PathInline, valid if Tool == ToolMCP
+	NameRef    uint64 // NSPaths ref to the mcp__<server>__<tool> name, valid if NameFlag == PathDictRef
+	NameInline string // the name, valid if NameFlag == PathInline
 	PathFlag   byte
 	PathRef    uint64 // valid if PathFlag == PathDictRef
 	PathInline string // valid if PathFlag == PathInline
@@ -98,8 +156,29 @@ type CheckpointFrame struct {
 
 // FileTouchedRecord is a file changed in a checkpoint.
 type FileTouchedRecord struct {
-	PathRef    uint64
+	PathFlag   byte   // PathDictRef or PathInline
+	PathRef    uint64 // valid if PathFlag == PathDictRef
+	PathInline string // valid if PathFlag == PathInline
 	ChangeType byte
+	Hunks      []string // the file's unified diff hunks, captured with --capture-diffs
+	HunkRefs   []uint64 // NSPaths refs to the hunks in version 0x02 frames; decode only
+}
+
+// MCPName returns a ToolMCP call's mcp__<server>__<tool> name, inline or
+// from d's NSPaths.
+func (tc *ToolCallRecord) MCPName(d *Dict) (string, error) {
+	if tc.NameFlag == PathInline {
+		return tc.NameInline, nil
+	}
+	return d.Get(NSPaths, tc.NameRef)
+}
+
+// Path returns the file's path, inline or from d's NSPaths.
+func (f *FileTouchedRecord) Path(d *Dict) (string, error) {
+	if f.PathFlag == PathInline {
+		return f.PathInline, nil
+	}
+	return d.Get(NSPaths, f.PathRef)
 }
 
 // MetaFrame is the decoded content of a meta frame (0x03).
@@ -116,25 +195,35 @@ type MetaFrame struct {
 
 // toolNameToCode maps tool name strings to binary codes.
 var toolNameToCode = map[string]byte{
-	"Write": ToolWrite,
-	"Read":  ToolRead,
-	"Bash":  ToolBash,
-	"Edit":  ToolEdit,
-	"Glob":  ToolGlob,
-	"Grep":  ToolGrep,
-	"Task":  ToolTask,
+	"Write":        ToolWrite,
+	"Read":         ToolRead,
+	"Bash":         ToolBash,
+	"Edit":         ToolEdit,
+	"Glob":         ToolGlob,
+	"Grep":         ToolGrep,
+	"Task":         ToolTask,
+	"MCP":          ToolMCP,
+	"NotebookEdit": ToolNotebookEdit,
+	"MultiEdit":    ToolMultiEdit,
+	"WebFetch":     ToolWebFetch,
+	"WebSearch":    ToolWebSearch,
 }
 
 // toolCodeToName maps binary codes back to tool name strings.
 var toolCodeToName = map[byte]string{
-	ToolWrite:   "Write",
-	ToolRead:    "Read",
-	ToolBash:    "Bash",
-	ToolEdit:    "Edit",
-	ToolGlob:    "Glob",
-	ToolGrep:    "Grep",
-	ToolTask:    "Task",
-	ToolUnknown: "Unknown",
+	ToolWrite:        "Write",
+	ToolRead:         "Read",
+	ToolBash:         "Bash",
+	ToolEdit:         "Edit",
+	ToolGlob:         "Glob",
+	ToolGrep:         "Grep",
+	ToolTask:         "Task",
+	ToolMCP:          "MCP",
+	ToolNotebookEdit: "NotebookEdit",
+	ToolMultiEdit:    "MultiEdit",
+	ToolWebFetch:     "WebFetch",
+	ToolWebSearch:    "WebSearch",
+	ToolUnknown:      "Unknown",
 }
 
 // ToolCode returns the binary code for a tool name.
@@ -160,11 +249,18 @@ type Encoder struct {
 
 // NewEncoder creates a new frame encoder with zstd preset dictionary support.
 func NewEncoder() (*Encoder, error) {
+	return NewEncoderDict(presetDict)
+}
+
+// NewEncoderDict creates a frame encoder that compresses with dict, such as
+// one from TrainDict, instead of the preset dictionary. The zstd header of
+// each frame names the dictionary's ID, so a decoder that knows it picks it.
+func NewEncoderDict(dict []byte) (*Encoder, error) {
 	opts := []zstd.EOption{
 		zstd.WithEncoderLevel(zstd.SpeedDefault), // level 3
 	}
-	if len(presetDict) > 0 {
-		opts = append(opts, zstd.WithEncoderDict(presetDict))
+	if len(dict) > 0 {
+		opts = append(opts, zstd.WithEncoderDict(dict))
 	}
 	zw, err := zstd.NewWriter(nil, opts...)
 	if err != nil {
@@ -207,14 +303,14 @@ func encodeSessionPayload(sf *SessionFrame) []byte {
 
 	// Header: magic + payload_version + dict_flags + n_turns + n_tools
 	buf = append(buf, sessionMagic...)
-	buf = append(buf, payloadVersion)
+	buf = append(buf, sessionPayloadVersion)
 	dictFlags := byte(0x00)
 	if len(presetDict) > 0 {
 		dictFlags = 0x01
 	}
 	buf = append(buf, dictFlags)
-	buf = append(buf, byte(len(sf.Turns)))
-	buf = append(buf, byte(len(sf.ToolCalls)))
+	buf = appendUvarint(buf, uint64(len(sf.Turns)))
+	buf = appendUvarint(buf, uint64(len(sf.ToolCalls)))
 
 	// Session meta.
 	buf = appendUvarint(buf, sf.SessionRef)
@@ -228,7 +324,7 @@ func encodeSessionPayload(sf *SessionFrame) []byte {
 	// Turns.
 	for _, t := range sf.Turns {
 		buf = append(buf, t.Role)
-		buf = appendUvarint(buf, t.TsDelta)
+		buf = appendVarint(buf, t.TsDelta)
 		buf = appendUvarint(buf, t.BranchRef)
 		buf = appendUvarint(buf, uint64(len(t.Text)))
 		buf = append(buf, []byte(t.Text)...)
@@ -237,6 +333,15 @@ func encodeSessionPayload(sf *SessionFrame) []byte {
 	// Tool calls.
 	for _, tc := range sf.ToolCalls {
 		buf = append(buf, tc.Tool)
+		if tc.Tool == ToolMCP {
+			buf = append(buf, tc.NameFlag)
+			if tc.NameFlag == PathInline {
+				buf = appendUvarint(buf, uint64(len(tc.NameInline)))
+				buf = append(buf, tc.NameInline...)
+			} else {
+				buf = appendUvarint(buf, tc.NameRef)
+			}
+		}
 		buf = append(buf, tc.PathFlag)
 		switch tc.PathFlag {
 		case PathDictRef:
@@ -260,9 +365,20 @@ func encodeSessionPayload(sf *SessionFrame) []byte {
 func encodeCheckpointPayload(cf *CheckpointFrame) []byte {
 	buf := make([]byte, 0, 128)
 
+	version := byte(payloadVersion)
+	for _, f := range cf.Files {
+		if f.PathFlag == PathInline {
+			version = checkpointPayloadVersion
+			break
+		}
+		if len(f.Hunks) > 0 {
+			version = checkpointPayloadVersionV3
+		}
+	}
+
 	// Header: magic + payload_version + n_files
 	buf = append(buf, checkpointMagic...)
-	buf = append(buf, payloadVersion)
+	buf = append(buf, version)
 	buf = append(buf, byte(len(cf.Files)))
 
 	// Checkpoint ULID dict ref (before GitSHA).
@@ -290,10 +406,29 @@ func encodeCheckpointPayload(cf *CheckpointFrame) []byte {
 
 	// Files touched.
 	for _, f := range cf.Files {
-		buf = appendUvarint(buf, f.PathRef)
+		if version >= checkpointPayloadVersion {
+			buf = append(buf, f.PathFlag)
+		}
+		if f.PathFlag == PathInline {
+			buf = appendUvarint(buf, uint64(len(f.PathInline)))
+			buf = append(buf, f.PathInline...)
+		} else {
+			buf = appendUvarint(buf, f.PathRef)
+		}
 		buf = append(buf, f.ChangeType)
 	}
 
+	// Diff hunks, per file in the same order.
+	if version >= checkpointPayloadVersionV3 {
+		for _, f := range cf.Files {
+			buf = appendUvarint(buf, uint64(len(f.Hunks)))
+			for _, hunk := range f.Hunks {
+				buf = appendUvarint(buf, uint64(len(hunk)))
+				buf = append(buf, hunk...)
+			}
+		}
+	}
+
 	return buf
 }
 
@@ -328,11 +463,26 @@ type Decoder struct {
 	zr *zstd.Decoder
 }
 
+// maxDecodedPayload caps how far one frame may decompress. A frame's
+// compressed size is at most 16 MiB (24-bit length), and real payloads are
+// far smaller; the cap stops a crafted frame from expanding without bound.
+const maxDecodedPayload = 256 << 20
+
 // NewDecoder creates a new frame decoder.
 func NewDecoder() (*Decoder, error) {
-	opts := []zstd.DOption{}
+	return NewDecoderDicts()
+}
+
+// NewDecoderDicts creates a frame decoder that knows dicts, such as those in
+// a branch's TrainedDictsFile, besides the preset dictionary. Each frame is
+// decompressed with the dictionary its zstd header names.
+func NewDecoderDicts(dicts ...[]byte) (*Decoder, error) {
+	opts := []zstd.DOption{zstd.WithDecoderMaxMemory(maxDecodedPayload)}
 	if len(presetDict) > 0 {
-		opts = append(opts, zstd.WithDecoderDicts(presetDict))
+		dicts = append([][]byte{presetDict}, dicts...)
+	}
+	if len(dicts) > 0 {
+		opts = append(opts, zstd.WithDecoderDicts(dicts...))
 	}
 	zr, err := zstd.NewReader(nil, opts...)
 	if err != nil {
@@ -373,6 +523,17 @@ func (d *Decoder) DecodeMetaFrame(compressed []byte) (*MetaFrame, error) {
 	return parseMetaPayload(payload)
 }
 
+// PayloadLen returns the decompressed length of a compressed frame payload.
+// The envelope's uncompressed_len is 16 bits and wraps for payloads of
+// 64 KiB or more, so the payload is decompressed to measure it.
+func (d *Decoder) PayloadLen(compressed []byte) (int, error) {
+	payload, err := d.zr.DecodeAll(compressed, nil)
+	if err != nil {
+		return 0, fmt.Errorf("decode payload: zstd: %w", err)
+	}
+	return len(payload), nil
+}
+
 func parseSessionPayload(data []byte) (*SessionFrame, error) {
 	if len(data) < 8 {
 		return nil, fmt.Errorf("session payload too short: %d bytes", len(data))
@@ -380,12 +541,32 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 	if string(data[0:4]) != string(sessionMagic) {
 		return nil, fmt.Errorf("session payload bad magic: %x", data[0:4])
 	}
-	// data[4] = payload_version
+	version := data[4]
 	// data[5] = dict_flags
-	nTurns := int(data[6])
-	nTools := int(data[7])
 
-	pos := 8
+	var nTurns, nTools, pos int
+	switch version {
+	case sessionPayloadVersionV1:
+		nTurns = int(data[6])
+		nTools = int(data[7])
+		pos = 8
+	case sessionPayloadVersionV2, sessionPayloadVersionV3, sessionPayloadVersionV4, sessionPayloadVersion:
+		pos = 6
+		turns, n := readUvarint(data[pos:])
+		pos += n
+		tools, n := readUvarint(data[pos:])
+		pos += n
+		// Every turn and tool call occupies at least one byte, so counts
+		// larger than the payload can only come from a corrupt frame.
+		if turns > uint64(len(data)) || tools > uint64(len(data)) {
+			return nil, fmt.Errorf("session payload counts exceed payload size: %d turns, %d tools", turns, tools)
+		}
+		nTurns = int(turns)
+		nTools = int(tools)
+	default:
+		return nil, fmt.Errorf("session payload unsupported version: %d", version)
+	}
+
 	sf := &SessionFrame{}
 
 	var n int
@@ -417,13 +598,19 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		var t TurnRecord
 		t.Role = data[pos]
 		pos++
-		t.TsDelta, n = readUvarint(data[pos:])
+		if version >= sessionPayloadVersionV4 {
+			t.TsDelta, n = readVarint(data[pos:])
+		} else {
+			var delta uint64
+			delta, n = readUvarint(data[pos:])
+			t.TsDelta = int64(delta)
+		}
 		pos += n
 		t.BranchRef, n = readUvarint(data[pos:])
 		pos += n
 		textLen, n2 := readUvarint(data[pos:])
 		pos += n2
-		if pos+int(textLen) > len(data) {
+		if !fits(data, pos, textLen) {
 			return nil, fmt.Errorf("session payload truncated at turn %d text", i)
 		}
 		t.Text = string(data[pos : pos+int(textLen)])
@@ -440,6 +627,27 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		var tc ToolCallRecord
 		tc.Tool = data[pos]
 		pos++
+		if tc.Tool == ToolMCP && version >= sessionPayloadVersionV3 {
+			if version >= sessionPayloadVersion {
+				tc.NameFlag = data[pos]
+				pos++
+			}
+			if tc.NameFlag == PathInline {
+				nameLen, n2 := readUvarint(data[pos:])
+				pos += n2
+				if !fits(data, pos, nameLen) {
+					return nil, fmt.Errorf("session payload truncated at tool %d inline name", i)
+				}
+				tc.NameInline = string(data[pos : pos+int(nameLen)])
+				pos += int(nameLen)
+			} else {
+				tc.NameRef, n = readUvarint(data[pos:])
+				pos += n
+			}
+			if pos >= len(data) {
+				return nil, fmt.Errorf("session payload truncated at tool %d path flag", i)
+			}
+		}
 		tc.PathFlag = data[pos]
 		pos++
 		switch tc.PathFlag {
@@ -449,7 +657,7 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		case PathInline:
 			pathLen, n2 := readUvarint(data[pos:])
 			pos += n2
-			if pos+int(pathLen) > len(data) {
+			if !fits(data, pos, pathLen) {
 				return nil, fmt.Errorf("session payload truncated at tool %d inline path", i)
 			}
 			tc.PathInline = string(data[pos : pos+int(pathLen)])
@@ -460,7 +668,7 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		cmdLen, n2 := readUvarint(data[pos:])
 		pos += n2
 		if cmdLen > 0 {
-			if pos+int(cmdLen) > len(data) {
+			if !fits(data, pos, cmdLen) {
 				return nil, fmt.Errorf("session payload truncated at tool %d cmd", i)
 			}
 			tc.CmdPrefix = string(data[pos : pos+int(cmdLen)])
@@ -479,7 +687,7 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 	if string(data[0:4]) != string(checkpointMagic) {
 		return nil, fmt.Errorf("checkpoint payload bad magic: %x", data[0:4])
 	}
-	// data[4] = payload_version
+	version := data[4]
 	nFiles := int(data[5])
 
 	pos := 6
@@ -516,8 +724,15 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 
 	nSess, n2 := readUvarint(data[pos:])
 	pos += n2
+	// Each session ref takes at least one byte.
+	if !fits(data, pos, nSess) {
+		return nil, fmt.Errorf("checkpoint payload session count %d exceeds remaining %d bytes", nSess, len(data)-pos)
+	}
 	cf.SessionRefs = make([]uint64, 0, nSess)
 	for i := uint64(0); i < nSess; i++ {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("checkpoint payload truncated at session ref %d", i)
+		}
 		ref, n3 := readUvarint(data[pos:])
 		pos += n3
 		cf.SessionRefs = append(cf.SessionRefs, ref)
@@ -527,8 +742,25 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 	cf.Files = make([]FileTouchedRecord, 0, nFiles)
 	for i := 0; i < nFiles; i++ {
 		var f FileTouchedRecord
-		f.PathRef, n = readUvarint(data[pos:])
-		pos += n
+		if version >= checkpointPayloadVersion {
+			if pos >= len(data) {
+				return nil, fmt.Errorf("checkpoint payload truncated at file %d path flag", i)
+			}
+			f.PathFlag = data[pos]
+			pos++
+		}
+		if f.PathFlag == PathInline {
+			pathLen, n2 := readUvarint(data[pos:])
+			pos += n2
+			if !fits(data, pos, pathLen) {
+				return nil, fmt.Errorf("checkpoint payload truncated at file %d inline path", i)
+			}
+			f.PathInline = string(data[pos : pos+int(pathLen)])
+			pos += int(pathLen)
+		} else {
+			f.PathRef, n = readUvarint(data[pos:])
+			pos += n
+		}
 		if pos >= len(data) {
 			return nil, fmt.Errorf("checkpoint payload truncated at file %d change_type", i)
 		}
@@ -537,6 +769,45 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 		cf.Files = append(cf.Files, f)
 	}
 
+	// Diff hunks.
+	if version >= checkpointPayloadVersionV2 {
+		for i := range cf.Files {
+			if pos >= len(data) {
+				return nil, fmt.Errorf("checkpoint payload truncated at file %d hunk count", i)
+			}
+			nHunks, n2 := readUvarint(data[pos:])
+			pos += n2
+			// Each hunk takes at least one byte.
+			if !fits(data, pos, nHunks) {
+				return nil, fmt.Errorf("checkpoint payload file %d hunk count %d exceeds remaining %d bytes", i, nHunks, len(data)-pos)
+			}
+			if nHunks == 0 {
+				continue
+			}
+			if version >= checkpointPayloadVersionV3 {
+				hunks := make([]string, 0, nHunks)
+				for j := uint64(0); j < nHunks; j++ {
+					hunkLen, n3 := readUvarint(data[pos:])
+					pos += n3
+					if !fits(data, pos, hunkLen) {
+						return nil, fmt.Errorf("checkpoint payload truncated at file %d hunk %d", i, j)
+					}
+					hunks = append(hunks, string(data[pos:pos+int(hunkLen)]))
+					pos += int(hunkLen)
+				}
+				cf.Files[i].Hunks = hunks
+				continue
+			}
+			refs := make([]uint64, 0, nHunks)
+			for j := uint64(0); j < nHunks; j++ {
+				ref, n3 := readUvarint(data[pos:])
+				pos += n3
+				refs = append(refs, ref)
+			}
+			cf.Files[i].HunkRefs = refs
+		}
+	}
+
 	return cf, nil
 }
 
@@ -591,12 +862,34 @@ func appendUvarint(buf []byte, x uint64) []byte {
 	return append(buf, tmp[:n]...)
 }
 
+// appendVarint appends a signed (zigzag) LEB128 varint to buf.
+func appendVarint(buf []byte, x int64) []byte {
+	return binary.AppendVarint(buf, x)
+}
+
+// readVarint reads a signed (zigzag) LEB128 varint from data.
+// Returns the value and the number of bytes consumed.
+func readVarint(data []byte) (int64, int) {
+	v, n := binary.Varint(data)
+	if n <= 0 {
+		return 0, min(1, len(data)) // consume a byte on error, but never past the end
+	}
+	return v, n
+}
+
 // readUvarint reads an unsigned LEB128 varint from data.
 // Returns the value and the number of bytes consumed.
 func readUvarint(data []byte) (uint64, int) {
 	v, n := binary.Uvarint(data)
 	if n <= 0 {
-		return 0, 1 // consume at least 1 byte on error
+		return 0, min(1, len(data)) // consume a byte on error, but never past the end
 	}
 	return v, n
 }
+
+// fits reports whether n more bytes remain in data after pos. n comes off
+// the wire, so it is compared rather than added to pos, which could
+// overflow into a negative slice bound.
+func fits(data []byte, pos int, n uint64) bool {
+	return pos <= len(data) && n <= uint64(len(data)-pos)
+}
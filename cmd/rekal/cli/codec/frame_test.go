@@ -1,6 +1,10 @@
 package codec
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
 	"testing"
 	"time"
 )
@@ -172,6 +176,222 @@ func TestSessionFrame_InlinePath(t *testing.T) {
 	}
 }
 
+func TestSessionFrame_MCPTool(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	sf := &SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 12, 0, 0, 0, time.UTC),
+		ActorType:  ActorHuman,
+		ToolCalls: []ToolCallRecord{
+			{Tool: ToolMCP, NameRef: 300, PathFlag: PathNull, CmdPrefix: "make test"},
+			{Tool: ToolRead, PathFlag: PathDictRef, PathRef: 2},
+		},
+	}
+
+	encoded := enc.EncodeSessionFrame(sf)
+	decoded, err := dec.DecodeSessionFrame(encoded[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(decoded.ToolCalls) != 2 {
+		t.Fatalf("tool calls: %d", len(decoded.ToolCalls))
+	}
+	mcp := decoded.ToolCalls[0]
+	if mcp.Tool != ToolMCP || mcp.NameRef != 300 || mcp.PathFlag != PathNull || mcp.CmdPrefix != "make test" {
+		t.Errorf("mcp tool call: got %+v", mcp)
+	}
+	if read := decoded.ToolCalls[1]; read.Tool != ToolRead || read.PathRef != 2 {
+		t.Errorf("read tool call: got %+v", read)
+	}
+}
+
+func TestSessionFrame_ManyTurns(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	sf := &SessionFrame{
+		SessionRef: 0,
+		CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		EmailRef:   0,
+		ActorType:  ActorHuman,
+	}
+	for i := 0; i < 1000; i++ {
+		role := RoleHuman
+		if i%2 == 1 {
+			role = RoleAssistant
+		}
+		sf.Turns = append(sf.Turns, TurnRecord{
+			Role:    role,
+			TsDelta: int64(i),
+			Text:    fmt.Sprintf("turn %d", i),
+		})
+		sf.ToolCalls = append(sf.ToolCalls, ToolCallRecord{
+			Tool:     ToolRead,
+			PathFlag: PathDictRef,
+			PathRef:  uint64(i),
+		})
+	}
+
+	encoded := enc.EncodeSessionFrame(sf)
+	decoded, err := dec.DecodeSessionFrame(encoded[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(decoded.Turns) != 1000 {
+		t.Fatalf("Turns: got %d, want 1000", len(decoded.Turns))
+	}
+	if len(decoded.ToolCalls) != 1000 {
+		t.Fatalf("ToolCalls: got %d, want 1000", len(decoded.ToolCalls))
+	}
+	for i, turn := range decoded.Turns {
+		if turn.Text != sf.Turns[i].Text || turn.TsDelta != sf.Turns[i].TsDelta || turn.Role != sf.Turns[i].Role {
+			t.Fatalf("turn %d: got %+v, want %+v", i, turn, sf.Turns[i])
+		}
+	}
+	for i, tc := range decoded.ToolCalls {
+		if tc.PathRef != uint64(i) {
+			t.Fatalf("tool %d path_ref: got %d, want %d", i, tc.PathRef, i)
+		}
+	}
+}
+
+func TestDecoder_PayloadLen(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	// 70000 bytes of text overflows the envelope's 16-bit uncompressed_len.
+	for _, n := range []int{10, 70000} {
+		sf := &SessionFrame{
+			CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+			Turns:      []TurnRecord{{Role: RoleHuman, Text: strings.Repeat("x", n)}},
+		}
+		want := len(encodeSessionPayload(sf))
+		got, err := dec.PayloadLen(enc.EncodeSessionFrame(sf)[frameEnvSize:])
+		if err != nil {
+			t.Fatalf("PayloadLen: %v", err)
+		}
+		if got != want {
+			t.Errorf("%d-byte turn: PayloadLen = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestSessionFrame_DecodeV1(t *testing.T) {
+	sf := &SessionFrame{
+		SessionRef: 3,
+		CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		EmailRef:   1,
+		ActorType:  ActorHuman,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, TsDelta: 0, Text: "fix the bug"},
+			{Role: RoleAssistant, TsDelta: 15, Text: "Done."},
+		},
+		ToolCalls: []ToolCallRecord{
+			{Tool: ToolEdit, PathFlag: PathDictRef, PathRef: 0},
+		},
+	}
+
+	// Counts below 128 encode as a single uvarint byte, and the zigzag
+	// varint of 15 is 0x1E, the uvarint a v1 writer used for a 30s delta. So
+	// a payload without MCP tool calls and with the version byte rewritten is
+	// byte-identical to a v1 payload whose second turn came 30s later.
+	payload := encodeSessionPayload(sf)
+	payload[4] = sessionPayloadVersionV1
+
+	decoded, err := parseSessionPayload(payload)
+	if err != nil {
+		t.Fatalf("parse v1: %v", err)
+	}
+	if decoded.SessionRef != 3 {
+		t.Errorf("SessionRef: got %d, want 3", decoded.SessionRef)
+	}
+	if len(decoded.Turns) != 2 || decoded.Turns[1].Text != "Done." || decoded.Turns[1].TsDelta != 30 {
+		t.Errorf("Turns: got %+v", decoded.Turns)
+	}
+	if len(decoded.ToolCalls) != 1 || decoded.ToolCalls[0].Tool != ToolEdit {
+		t.Errorf("ToolCalls: got %+v", decoded.ToolCalls)
+	}
+}
+
+func TestSessionFrame_NegativeTsDelta(t *testing.T) {
+	sf := &SessionFrame{
+		ActorType: ActorHuman,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, TsDelta: 0, Text: "start"},
+			{Role: RoleAssistant, TsDelta: 0, Text: "simultaneous"},
+			{Role: RoleHuman, TsDelta: -90, Text: "clock went backwards"},
+			{Role: RoleAssistant, TsDelta: 300, Text: "later"},
+		},
+	}
+
+	decoded, err := parseSessionPayload(encodeSessionPayload(sf))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for i, turn := range decoded.Turns {
+		if turn.TsDelta != sf.Turns[i].TsDelta {
+			t.Errorf("turn %d ts_delta: got %d, want %d", i, turn.TsDelta, sf.Turns[i].TsDelta)
+		}
+	}
+}
+
+func TestSessionFrame_DecodeV3UnsignedDelta(t *testing.T) {
+	// Before v4, ts_delta was a uvarint. 0x2C is 44 as a uvarint but 22 as a
+	// zigzag varint, so the decoded value shows which reading was used.
+	payload := encodeSessionPayload(&SessionFrame{
+		ActorType: ActorHuman,
+		Turns:     []TurnRecord{{Role: RoleHuman, TsDelta: 22, Text: "hi"}},
+	})
+	payload[4] = sessionPayloadVersionV3
+
+	decoded, err := parseSessionPayload(payload)
+	if err != nil {
+		t.Fatalf("parse v3: %v", err)
+	}
+	if got := decoded.Turns[0].TsDelta; got != 44 {
+		t.Errorf("ts_delta: got %d, want 44", got)
+	}
+}
+
+func TestSessionFrame_UnsupportedVersion(t *testing.T) {
+	payload := encodeSessionPayload(&SessionFrame{ActorType: ActorHuman})
+	payload[4] = 0x7F
+
+	if _, err := parseSessionPayload(payload); err == nil {
+		t.Error("expected error for unsupported payload version")
+	}
+}
+
 func TestCheckpointFrame_Roundtrip(t *testing.T) {
 	enc, err := NewEncoder()
 	if err != nil {
@@ -234,6 +454,85 @@ func TestCheckpointFrame_Roundtrip(t *testing.T) {
 	}
 }
 
+func TestCheckpointFrame_Hunks(t *testing.T) {
+	cf := &CheckpointFrame{
+		GitSHA:    "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		Timestamp: time.Date(2026, 2, 25, 10, 30, 0, 0, time.UTC),
+		Files: []FileTouchedRecord{
+			{PathRef: 0, ChangeType: ChangeModified, Hunks: []string{"@@ -1 +1 @@\n-a\n+b\n", "@@ -9 +9 @@\n-c\n+d\n"}},
+			{PathRef: 1, ChangeType: ChangeAdded},
+			{PathRef: 2, ChangeType: ChangeModified, Hunks: []string{"@@ -1 +1 @@\n-a\n+b\n"}},
+		},
+	}
+
+	payload := encodeCheckpointPayload(cf)
+	if payload[4] != checkpointPayloadVersionV3 {
+		t.Errorf("payload version with hunks: got %d, want %d", payload[4], checkpointPayloadVersionV3)
+	}
+	decoded, err := parseCheckpointPayload(payload)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(decoded.Files) != 3 {
+		t.Fatalf("files: %d", len(decoded.Files))
+	}
+	for i, f := range cf.Files {
+		if fmt.Sprintf("%q", decoded.Files[i].Hunks) != fmt.Sprintf("%q", f.Hunks) {
+			t.Errorf("file %d hunks: got %q, want %q", i, decoded.Files[i].Hunks, f.Hunks)
+		}
+	}
+
+	// Without hunks the frame keeps the version 0x01 layout, byte for byte.
+	for i := range cf.Files {
+		cf.Files[i].Hunks = nil
+	}
+	if v1 := encodeCheckpointPayload(cf); v1[4] != payloadVersion || len(v1) >= len(payload) {
+		t.Errorf("payload without hunks: version %d, %d bytes (with hunks %d)", v1[4], len(v1), len(payload))
+	}
+
+	// A hunk longer than the payload is rejected.
+	corrupt := append([]byte{}, payload[:len(payload)-len("@@ -1 +1 @@\n-a\n+b\n")-1]...)
+	corrupt = appendUvarint(corrupt, 1<<20)
+	if _, err := parseCheckpointPayload(corrupt); err == nil {
+		t.Error("expected error for a hunk past the end of the payload")
+	}
+}
+
+func TestCheckpointFrame_DecodeV2HunkRefs(t *testing.T) {
+	cf := &CheckpointFrame{
+		GitSHA:    "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		Timestamp: time.Date(2026, 2, 25, 10, 30, 0, 0, time.UTC),
+		Files: []FileTouchedRecord{
+			{PathRef: 0, ChangeType: ChangeModified},
+			{PathRef: 1, ChangeType: ChangeAdded},
+		},
+	}
+	// A version 0x02 payload: the 0x01 layout plus hunk refs per file.
+	payload := encodeCheckpointPayload(cf)
+	payload[4] = checkpointPayloadVersionV2
+	payload = appendUvarint(payload, 2)
+	payload = appendUvarint(payload, 3)
+	payload = appendUvarint(payload, 200)
+	payload = appendUvarint(payload, 0)
+
+	decoded, err := parseCheckpointPayload(payload)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := fmt.Sprint(decoded.Files[0].HunkRefs); got != "[3 200]" {
+		t.Errorf("file 0 hunk refs: got %s, want [3 200]", got)
+	}
+	if decoded.Files[1].HunkRefs != nil || decoded.Files[0].Hunks != nil {
+		t.Errorf("unexpected hunks: %+v", decoded.Files)
+	}
+
+	// A hunk count larger than the payload is rejected.
+	corrupt := appendUvarint(payload[:len(payload)-4], 1<<20)
+	if _, err := parseCheckpointPayload(corrupt); err == nil {
+		t.Error("expected error for a hunk count past the end of the payload")
+	}
+}
+
 func TestMetaFrame_Roundtrip(t *testing.T) {
 	enc, err := NewEncoder()
 	if err != nil {
@@ -301,6 +600,11 @@ func TestToolCode_Mapping(t *testing.T) {
 		{"Glob", ToolGlob},
 		{"Grep", ToolGrep},
 		{"Task", ToolTask},
+		{"MCP", ToolMCP},
+		{"NotebookEdit", ToolNotebookEdit},
+		{"MultiEdit", ToolMultiEdit},
+		{"WebFetch", ToolWebFetch},
+		{"WebSearch", ToolWebSearch},
 	}
 	for _, tt := range tests {
 		if got := ToolCode(tt.name); got != tt.code {
@@ -313,6 +617,35 @@ func TestToolCode_Mapping(t *testing.T) {
 	if ToolCode("SomethingNew") != ToolUnknown {
 		t.Error("unknown tool should map to ToolUnknown")
 	}
+
+	// Every code maps back to exactly one name, so no two tools collide.
+	if len(toolCodeToName) != len(toolNameToCode)+1 { // +1 for ToolUnknown
+		t.Errorf("toolCodeToName has %d codes, want %d", len(toolCodeToName), len(toolNameToCode)+1)
+	}
+	for name, code := range toolNameToCode {
+		if got := toolCodeToName[code]; got != name {
+			t.Errorf("code 0x%02x: maps back to %q, want %q", code, got, name)
+		}
+	}
+}
+
+func TestRoleCode_Mapping(t *testing.T) {
+	tests := []struct {
+		name string
+		code byte
+	}{
+		{"human", RoleHuman},
+		{"assistant", RoleAssistant},
+		{"thinking", RoleThinking},
+	}
+	for _, tt := range tests {
+		if got := RoleCode(tt.name); got != tt.code {
+			t.Errorf("RoleCode(%q) = %d, want %d", tt.name, got, tt.code)
+		}
+		if got := RoleName(tt.code); got != tt.name {
+			t.Errorf("RoleName(%d) = %q, want %q", tt.code, got, tt.name)
+		}
+	}
 }
 
 func TestCompressionRatio(t *testing.T) {
@@ -422,3 +755,148 @@ func BenchmarkDecodeSessionFrame(b *testing.B) {
 		_, _ = dec.DecodeSessionFrame(compressed)
 	}
 }
+
+// sampleSessionPayload returns an uncompressed session payload with turns,
+// an inline path and a command, so every length field is exercised.
+func sampleSessionPayload() []byte {
+	return encodeSessionPayload(&SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 10, 30, 0, 0, time.UTC),
+		ActorType:  ActorAgent,
+		AgentIDRef: 3,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, Text: "fix the bug"},
+			{Role: RoleAssistant, TsDelta: -5, Text: "done"},
+		},
+		ToolCalls: []ToolCallRecord{
+			{Tool: ToolEdit, PathFlag: PathInline, PathInline: "/tmp/x.go"},
+			{Tool: ToolMCP, NameRef: 1, PathFlag: PathNull, CmdPrefix: "go test"},
+		},
+	})
+}
+
+func sampleCheckpointPayload() []byte {
+	return encodeCheckpointPayload(&CheckpointFrame{
+		GitSHA:      "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		Timestamp:   time.Date(2026, 2, 25, 10, 30, 0, 0, time.UTC),
+		ActorType:   ActorHuman,
+		SessionRefs: []uint64{0, 300},
+		Files:       []FileTouchedRecord{{PathRef: 1, ChangeType: ChangeModified}},
+	})
+}
+
+// parseAll runs every payload parser over data; a panic fails the test.
+func parseAll(t *testing.T, data []byte) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("parser panicked on %x: %v", data, r)
+		}
+	}()
+	_, _ = parseSessionPayload(data)
+	_, _ = parseCheckpointPayload(data)
+	_, _ = parseMetaPayload(data)
+}
+
+func TestParsePayloads_Truncated(t *testing.T) {
+	meta := encodeMetaPayload(&MetaFrame{FormatVersion: 1, CheckpointSHA: "aaa111bbb222ccc333ddd444eee555fff666aaa1"})
+	for _, full := range [][]byte{sampleSessionPayload(), sampleCheckpointPayload(), meta} {
+		for n := 0; n < len(full); n++ {
+			parseAll(t, full[:n])
+		}
+	}
+}
+
+func TestParsePayloads_Corrupted(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, full := range [][]byte{sampleSessionPayload(), sampleCheckpointPayload()} {
+		for i := 0; i < 5000; i++ {
+			data := append([]byte(nil), full...)
+			for j := rng.Intn(4) + 1; j > 0; j-- {
+				data[rng.Intn(len(data))] = byte(rng.Intn(256))
+			}
+			parseAll(t, data)
+		}
+	}
+}
+
+func TestParseSessionPayload_OversizedLength(t *testing.T) {
+	// One turn with an empty text; its text length is the payload's last byte.
+	data := encodeSessionPayload(&SessionFrame{Turns: []TurnRecord{{Role: RoleHuman}}})
+	for _, textLen := range []uint64{1 << 20, math.MaxInt64, math.MaxUint64} {
+		bad := appendUvarint(append([]byte(nil), data[:len(data)-1]...), textLen)
+		if _, err := parseSessionPayload(bad); err == nil || !strings.Contains(err.Error(), "truncated at turn 0 text") {
+			t.Errorf("text length %d: err = %v, want truncation error", textLen, err)
+		}
+	}
+
+	// Counts larger than the payload are rejected before anything is allocated.
+	bad := append([]byte(nil), data[:6]...)
+	bad = appendUvarint(bad, math.MaxUint64)
+	bad = appendUvarint(bad, 0)
+	if _, err := parseSessionPayload(append(bad, data[8:]...)); err == nil {
+		t.Error("expected an error for a turn count larger than the payload")
+	}
+}
+
+func TestParseCheckpointPayload_OversizedSessionCount(t *testing.T) {
+	// No sessions or files: the session count is the payload's last byte.
+	data := encodeCheckpointPayload(&CheckpointFrame{
+		GitSHA:    "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		ActorType: ActorHuman,
+	})
+	for _, nSess := range []uint64{2, 1 << 32, math.MaxUint64} {
+		bad := appendUvarint(append([]byte(nil), data[:len(data)-1]...), nSess)
+		if _, err := parseCheckpointPayload(bad); err == nil || !strings.Contains(err.Error(), "session count") {
+			t.Errorf("session count %d: err = %v, want session count error", nSess, err)
+		}
+	}
+}
+
+func FuzzParseSessionPayload(f *testing.F) {
+	f.Add(sampleSessionPayload())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = parseSessionPayload(data)
+	})
+}
+
+func FuzzParseCheckpointPayload(f *testing.F) {
+	f.Add(sampleCheckpointPayload())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = parseCheckpointPayload(data)
+	})
+}
+
+func FuzzDecodeFrame(f *testing.F) {
+	enc, err := NewEncoder()
+	if err != nil {
+		f.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		f.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	// kind picks a magic to put in front of body (0 = none), so the fuzzer
+	// spends its time past the magic check; compress runs the result
+	// through zstd first, as a real frame would be.
+	magics := [][]byte{nil, sessionMagic, checkpointMagic, metaMagic}
+	meta := encodeMetaPayload(&MetaFrame{FormatVersion: 1, CheckpointSHA: "aaa111bbb222ccc333ddd444eee555fff666aaa1"})
+	for _, payload := range [][]byte{sampleSessionPayload(), sampleCheckpointPayload(), meta} {
+		f.Add(uint8(0), payload, true)
+		f.Add(uint8(0), enc.EncodeSessionFrame(&SessionFrame{})[frameEnvSize:], false)
+	}
+	f.Add(uint8(1), sampleSessionPayload()[len(sessionMagic):], true)
+	f.Add(uint8(2), sampleCheckpointPayload()[len(checkpointMagic):], true)
+
+	f.Fuzz(func(t *testing.T, kind uint8, body []byte, compress bool) {
+		data := append(append([]byte(nil), magics[int(kind)%len(magics)]...), body...)
+		if compress {
+			data = enc.zw.EncodeAll(data, nil)
+		}
+		_, _ = dec.DecodeSessionFrame(data)
+		_, _ = dec.DecodeCheckpointFrame(data)
+		_, _ = dec.DecodeMetaFrame(data)
+	})
+}
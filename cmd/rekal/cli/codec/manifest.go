@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	manifestMagic   = "RKLMANI"
+	manifestVersion = 0x01
+	manifestHdrSize = 8 // 7 magic + 1 version
+
+	// ManifestFile is the manifest's path in the orphan branch tree. Branches
+	// without one have a single shard, rekal.body.
+	ManifestFile = "rekal.manifest"
+
+	bodyFile = "rekal.body"
+
+	// maxShards bounds the shard count LoadManifest accepts, so a corrupt
+	// manifest can't make Files allocate without limit.
+	maxShards = 1 << 16
+)
+
+// Manifest records how the body is split into shards. Each shard is a
+// complete body (header + frames) that decodes on its own against the shared
+// dict.bin. Frames are only ever appended to the last, active shard.
+type Manifest struct {
+	Shards int
+}
+
+// NewManifest returns the manifest of an unsharded body.
+func NewManifest() *Manifest {
+	return &Manifest{Shards: 1}
+}
+
+// ShardFile returns the tree path of shard n: rekal.body for shard 0, so
+// unsharded branches are unchanged, then rekal.body.1, rekal.body.2, ...
+func ShardFile(n int) string {
+	if n == 0 {
+		return bodyFile
+	}
+	return bodyFile + "." + strconv.Itoa(n)
+}
+
+// Active returns the index of the shard new frames are appended to.
+func (m *Manifest) Active() int {
+	return m.Shards - 1
+}
+
+// Files returns the shard paths in append order.
+func (m *Manifest) Files() []string {
+	files := make([]string, m.Shards)
+	for i := range files {
+		files[i] = ShardFile(i)
+	}
+	return files
+}
+
+// Encode serializes the manifest: 8-byte header + uvarint shard count.
+func (m *Manifest) Encode() []byte {
+	buf := make([]byte, manifestHdrSize, manifestHdrSize+binary.MaxVarintLen64)
+	copy(buf[0:7], manifestMagic)
+	buf[7] = manifestVersion
+	return binary.AppendUvarint(buf, uint64(m.Shards))
+}
+
+// LoadManifest parses a rekal.manifest blob.
+func LoadManifest(data []byte) (*Manifest, error) {
+	if len(data) < manifestHdrSize {
+		return nil, errors.New("manifest: data too short for header")
+	}
+	magic := string(data[0:7])
+	if magic != manifestMagic {
+		return nil, fmt.Errorf("manifest: bad magic %q, want %q", magic, manifestMagic)
+	}
+	if data[7] != manifestVersion {
+		return nil, fmt.Errorf("manifest: unsupported version %d", data[7])
+	}
+	n, size := binary.Uvarint(data[manifestHdrSize:])
+	if size <= 0 {
+		return nil, errors.New("manifest: truncated shard count")
+	}
+	if n == 0 {
+		return nil, errors.New("manifest: zero shards")
+	}
+	if n > maxShards {
+		return nil, fmt.Errorf("manifest: shard count %d exceeds %d", n, maxShards)
+	}
+	return &Manifest{Shards: int(n)}, nil
+}
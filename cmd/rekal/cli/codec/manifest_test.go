@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestManifest_Roundtrip(t *testing.T) {
+	m := &Manifest{Shards: 3}
+	loaded, err := LoadManifest(m.Encode())
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if loaded.Shards != 3 {
+		t.Errorf("Shards = %d, want 3", loaded.Shards)
+	}
+	if loaded.Active() != 2 {
+		t.Errorf("Active = %d, want 2", loaded.Active())
+	}
+	want := "rekal.body,rekal.body.1,rekal.body.2"
+	if got := strings.Join(loaded.Files(), ","); got != want {
+		t.Errorf("Files = %s, want %s", got, want)
+	}
+}
+
+func TestNewManifest_SingleBody(t *testing.T) {
+	m := NewManifest()
+	if m.Active() != 0 || ShardFile(m.Active()) != "rekal.body" {
+		t.Errorf("unsharded manifest should append to rekal.body, got %s", ShardFile(m.Active()))
+	}
+}
+
+func TestLoadManifest_Invalid(t *testing.T) {
+	valid := (&Manifest{Shards: 2}).Encode()
+
+	tests := map[string][]byte{
+		"short":     valid[:4],
+		"bad magic": append([]byte("XXXXXXX"), valid[7:]...),
+		"version":   append(append([]byte{}, valid[:7]...), 0x09, 0x02),
+		"no count":  valid[:manifestHdrSize],
+		"zero":      append(append([]byte{}, valid[:manifestHdrSize]...), 0x00),
+		"too many":  binary.AppendUvarint(append([]byte{}, valid[:manifestHdrSize]...), maxShards+1),
+	}
+	for name, data := range tests {
+		if _, err := LoadManifest(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
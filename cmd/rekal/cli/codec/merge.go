@@ -0,0 +1,268 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MergeBodies merges two bodies that grew apart from a shared history, as
+// happens when the same branch is pushed from two machines. Both bodies are
+// append-only, so they start with the same frames; the result is remote
+// followed by the frames only local has, with their dictionary refs
+// re-derived against the remote dict.
+//
+// dicts holds the local and remote dict.bin, in that order. A single dict
+// is used for both bodies. MergeBodies returns the merged body and dict.bin.
+//
+// A local-only session or checkpoint frame whose ID remote already holds is
+// dropped, so a session pushed from both machines appears once. Local meta
+// frames are kept with their counts recomputed for the merged body. Frames
+// of unknown type carry no refs and are copied as-is.
+func MergeBodies(local, remote []byte, dicts ...[]byte) ([]byte, []byte, error) {
+	dec, err := GetDecoder()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer PutDecoder(dec)
+	return MergeBodiesWith(dec, local, remote, dicts...)
+}
+
+// MergeBodiesWith is MergeBodies decoding with dec, which must know the zstd
+// dictionaries either body's frames were compressed with. Local frames are
+// re-encoded with the preset dictionary.
+func MergeBodiesWith(dec *Decoder, local, remote []byte, dicts ...[]byte) ([]byte, []byte, error) {
+	var localDictData, remoteDictData []byte
+	switch len(dicts) {
+	case 1:
+		localDictData, remoteDictData = dicts[0], dicts[0]
+	case 2:
+		localDictData, remoteDictData = dicts[0], dicts[1]
+	default:
+		return nil, nil, fmt.Errorf("merge: want local and remote dicts, got %d", len(dicts))
+	}
+
+	localDict, err := loadDictOrEmpty(localDictData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("merge: local %w", err)
+	}
+	merged, err := loadDictOrEmpty(remoteDictData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("merge: remote %w", err)
+	}
+
+	localFrames, err := ScanFrames(local)
+	if err != nil {
+		return nil, nil, fmt.Errorf("merge: local %w", err)
+	}
+	remoteFrames, err := ScanFrames(remote)
+	if err != nil {
+		return nil, nil, fmt.Errorf("merge: remote %w", err)
+	}
+
+	// Frames up to the first difference are shared history.
+	common := 0
+	for common < len(localFrames) && common < len(remoteFrames) &&
+		bytes.Equal(frameBytes(local, localFrames[common]), frameBytes(remote, remoteFrames[common])) {
+		common++
+	}
+
+	enc, err := GetEncoder()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer PutEncoder(enc)
+
+	// IDs remote already has a frame for.
+	haveSessions := make(map[string]bool)
+	haveCheckpoints := make(map[string]bool)
+	for _, fs := range remoteFrames[common:] {
+		switch fs.Type {
+		case FrameSession:
+			if sf, err := dec.DecodeSessionFrame(ExtractFramePayload(remote, fs)); err == nil {
+				if id, err := merged.Get(NSSessions, sf.SessionRef); err == nil {
+					haveSessions[id] = true
+				}
+			}
+		case FrameCheckpoint:
+			if cf, err := dec.DecodeCheckpointFrame(ExtractFramePayload(remote, fs)); err == nil {
+				if id, err := merged.Get(NSSessions, cf.CheckpointRef); err == nil {
+					haveCheckpoints[id] = true
+				}
+			}
+		}
+	}
+
+	body := append([]byte(nil), remote...)
+	nFrames := len(remoteFrames)
+	m := &refMapper{from: localDict, to: merged}
+
+	for _, fs := range localFrames[common:] {
+		payload := ExtractFramePayload(local, fs)
+		var frame []byte
+
+		switch fs.Type {
+		case FrameSession:
+			sf, err := dec.DecodeSessionFrame(payload)
+			if err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			id, err := localDict.Get(NSSessions, sf.SessionRef)
+			if err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			if haveSessions[id] {
+				continue
+			}
+			if err := m.session(sf); err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			frame = enc.EncodeSessionFrame(sf)
+
+		case FrameCheckpoint:
+			cf, err := dec.DecodeCheckpointFrame(payload)
+			if err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			id, err := localDict.Get(NSSessions, cf.CheckpointRef)
+			if err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			if haveCheckpoints[id] {
+				continue
+			}
+			if err := m.checkpoint(cf); err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			frame = enc.EncodeCheckpointFrame(cf)
+
+		case FrameMeta:
+			mf, err := dec.DecodeMetaFrame(payload)
+			if err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			if mf.EmailRef, err = m.ref(NSEmails, mf.EmailRef); err != nil {
+				return nil, nil, fmt.Errorf("merge: local frame at offset %d: %w", fs.Offset, err)
+			}
+			mf.NSessions = uint32(merged.Len(NSSessions))
+			mf.NFrames = uint32(nFrames + 1)
+			mf.NDictEntries = uint32(merged.TotalEntries())
+			frame = enc.EncodeMetaFrame(mf)
+
+		default:
+			frame = frameBytes(local, fs)
+		}
+
+		body = AppendFrame(body, frame)
+		nFrames++
+	}
+
+	return body, merged.Encode(), nil
+}
+
+// frameBytes returns a frame's envelope and payload.
+func frameBytes(body []byte, fs FrameSlice) []byte {
+	return body[fs.Offset : fs.PayloadOffset+fs.CompressedLen]
+}
+
+// loadDictOrEmpty parses dict.bin, treating an empty blob as an empty dict.
+func loadDictOrEmpty(data []byte) (*Dict, error) {
+	if len(data) == 0 {
+		return NewDict(), nil
+	}
+	return LoadDict(data)
+}
+
+// refMapper rewrites dictionary refs from one dict to another, adding
+// strings the target lacks.
+type refMapper struct {
+	from, to *Dict
+}
+
+func (m *refMapper) ref(ns Namespace, ref uint64) (uint64, error) {
+	s, err := m.from.Get(ns, ref)
+	if err != nil {
+		return 0, err
+	}
+	return m.to.LookupOrAdd(ns, s), nil
+}
+
+// branchRef maps a branch ref. Export writes 0 for a turn with no branch
+// even when the dict has no branches, so a ref the dict cannot resolve is
+// kept as-is.
+func (m *refMapper) branchRef(ref uint64) uint64 {
+	s, err := m.from.Get(NSBranches, ref)
+	if err != nil {
+		return ref
+	}
+	return m.to.LookupOrAdd(NSBranches, s)
+}
+
+func (m *refMapper) session(sf *SessionFrame) error {
+	var err error
+	if sf.SessionRef, err = m.ref(NSSessions, sf.SessionRef); err != nil {
+		return err
+	}
+	if sf.EmailRef, err = m.ref(NSEmails, sf.EmailRef); err != nil {
+		return err
+	}
+	if sf.ActorType == ActorAgent {
+		if sf.AgentIDRef, err = m.ref(NSEmails, sf.AgentIDRef); err != nil {
+			return err
+		}
+	}
+	for i := range sf.Turns {
+		sf.Turns[i].BranchRef = m.branchRef(sf.Turns[i].BranchRef)
+	}
+	for i := range sf.ToolCalls {
+		tc := &sf.ToolCalls[i]
+		if tc.Tool == ToolMCP && tc.NameFlag == PathDictRef {
+			if tc.NameRef, err = m.ref(NSPaths, tc.NameRef); err != nil {
+				return err
+			}
+		}
+		if tc.PathFlag == PathDictRef {
+			if tc.PathRef, err = m.ref(NSPaths, tc.PathRef); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *refMapper) checkpoint(cf *CheckpointFrame) error {
+	var err error
+	if cf.CheckpointRef, err = m.ref(NSSessions, cf.CheckpointRef); err != nil {
+		return err
+	}
+	cf.BranchRef = m.branchRef(cf.BranchRef)
+	if cf.EmailRef, err = m.ref(NSEmails, cf.EmailRef); err != nil {
+		return err
+	}
+	if cf.ActorType == ActorAgent {
+		if cf.AgentIDRef, err = m.ref(NSEmails, cf.AgentIDRef); err != nil {
+			return err
+		}
+	}
+	for i, ref := range cf.SessionRefs {
+		if cf.SessionRefs[i], err = m.ref(NSSessions, ref); err != nil {
+			return err
+		}
+	}
+	for i := range cf.Files {
+		if cf.Files[i].PathFlag == PathDictRef {
+			if cf.Files[i].PathRef, err = m.ref(NSPaths, cf.Files[i].PathRef); err != nil {
+				return err
+			}
+		}
+		// Hunk refs from version 0x02 frames are written back inline.
+		for _, ref := range cf.Files[i].HunkRefs {
+			hunk, err := m.from.Get(NSPaths, ref)
+			if err != nil {
+				return err
+			}
+			cf.Files[i].Hunks = append(cf.Files[i].Hunks, hunk)
+		}
+		cf.Files[i].HunkRefs = nil
+	}
+	return nil
+}
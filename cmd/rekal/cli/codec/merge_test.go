@@ -0,0 +1,223 @@
+package codec
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appendTestSession appends a session frame and a checkpoint frame for it,
+// interning strings in d the way push does.
+func appendTestSession(t *testing.T, body []byte, d *Dict, sessionID, cpID, branch, path string) []byte {
+	t.Helper()
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	ts := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	sessRef := d.LookupOrAdd(NSSessions, sessionID)
+	emailRef := d.LookupOrAdd(NSEmails, "dev@example.com")
+	branchRef := d.LookupOrAdd(NSBranches, branch)
+	pathRef := d.LookupOrAdd(NSPaths, path)
+
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{
+		SessionRef: sessRef,
+		CapturedAt: ts,
+		EmailRef:   emailRef,
+		ActorType:  ActorHuman,
+		Turns:      []TurnRecord{{Role: RoleHuman, BranchRef: branchRef, Text: "edit " + path}},
+		ToolCalls:  []ToolCallRecord{{Tool: ToolEdit, PathFlag: PathDictRef, PathRef: pathRef}},
+	}))
+	body = AppendFrame(body, enc.EncodeCheckpointFrame(&CheckpointFrame{
+		CheckpointRef: d.LookupOrAdd(NSSessions, cpID),
+		GitSHA:        "aaa111bbb222ccc333ddd444eee555fff666aaa1",
+		BranchRef:     branchRef,
+		EmailRef:      emailRef,
+		Timestamp:     ts,
+		ActorType:     ActorHuman,
+		SessionRefs:   []uint64{sessRef},
+		Files:         []FileTouchedRecord{{PathRef: pathRef, ChangeType: ChangeModified, Hunks: []string{"@@ -1 +1 @@\n-old " + path + "\n+new " + path + "\n"}}},
+	}))
+	return body
+}
+
+// copyDict returns an independent copy of d.
+func copyDict(t *testing.T, d *Dict) *Dict {
+	t.Helper()
+	c, err := LoadDict(d.Encode())
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	return c
+}
+
+// sessionPaths decodes body against dict and maps each session ID to the
+// path of its first tool call.
+func sessionPaths(t *testing.T, body, dictData []byte) map[string]string {
+	t.Helper()
+	d, err := LoadDict(dictData)
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	decoded, err := DecodeBody(body, d)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	if decoded.Skipped != 0 {
+		t.Errorf("skipped %d frames", decoded.Skipped)
+	}
+	out := make(map[string]string)
+	for _, sf := range decoded.Sessions {
+		id, _ := d.Get(NSSessions, sf.SessionRef)
+		path, err := d.Get(NSPaths, sf.ToolCalls[0].PathRef)
+		if err != nil {
+			t.Errorf("session %s: %v", id, err)
+		}
+		out[id] = path
+	}
+	return out
+}
+
+const (
+	mergeSessShared = "01JSHARED00000000000000000"
+	mergeSessLocal  = "01JLOCAL000000000000000000"
+	mergeSessRemote = "01JREMOTE00000000000000000"
+	mergeSessBoth   = "01JBOTH0000000000000000000"
+)
+
+func TestMergeBodies_Divergent(t *testing.T) {
+	shared := NewDict()
+	base := appendTestSession(t, NewBody(), shared, mergeSessShared, "01JCPSHARED000000000000000", "main", "shared.go")
+
+	localDict := copyDict(t, shared)
+	local := appendTestSession(t, append([]byte(nil), base...), localDict, mergeSessLocal, "01JCPLOCAL0000000000000000", "feat-local", "local.go")
+
+	remoteDict := copyDict(t, shared)
+	remote := appendTestSession(t, append([]byte(nil), base...), remoteDict, mergeSessRemote, "01JCPREMOTE000000000000000", "feat-remote", "remote.go")
+
+	body, dict, err := MergeBodies(local, remote, localDict.Encode(), remoteDict.Encode())
+	if err != nil {
+		t.Fatalf("MergeBodies: %v", err)
+	}
+
+	if !bytes.HasPrefix(body, remote) {
+		t.Error("merged body should start with the remote body unchanged")
+	}
+
+	got := sessionPaths(t, body, dict)
+	want := map[string]string{
+		mergeSessShared: "shared.go",
+		mergeSessRemote: "remote.go",
+		mergeSessLocal:  "local.go",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sessions: got %v, want %v", got, want)
+	}
+	for id, path := range want {
+		if got[id] != path {
+			t.Errorf("session %s: path %q, want %q", id, got[id], path)
+		}
+	}
+
+	d, _ := LoadDict(dict)
+	decoded, _ := DecodeBody(body, d)
+	if len(decoded.Checkpoints) != 3 {
+		t.Fatalf("checkpoints: got %d, want 3", len(decoded.Checkpoints))
+	}
+	var branches []string
+	for _, cf := range decoded.Checkpoints {
+		b, err := d.Get(NSBranches, cf.BranchRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		branches = append(branches, b)
+
+		// Hunks travel inline with their re-interned path refs.
+		path, _ := d.Get(NSPaths, cf.Files[0].PathRef)
+		if len(cf.Files[0].Hunks) != 1 || !strings.Contains(cf.Files[0].Hunks[0], "+new "+path+"\n") {
+			t.Errorf("checkpoint on %s: hunks %q do not match its file %s", b, cf.Files[0].Hunks, path)
+		}
+	}
+	sort.Strings(branches)
+	if want := []string{"feat-local", "feat-remote", "main"}; !slices.Equal(branches, want) {
+		t.Errorf("checkpoint branches: got %v, want %v", branches, want)
+	}
+}
+
+func TestMergeBodies_DropsFramesRemoteHas(t *testing.T) {
+	shared := NewDict()
+	base := appendTestSession(t, NewBody(), shared, mergeSessShared, "01JCPSHARED000000000000000", "main", "shared.go")
+
+	// Both sides pushed the same session, then each added its own.
+	localDict := copyDict(t, shared)
+	local := appendTestSession(t, append([]byte(nil), base...), localDict, mergeSessLocal, "01JCPLOCAL0000000000000000", "main", "local.go")
+	local = appendTestSession(t, local, localDict, mergeSessBoth, "01JCPBOTH00000000000000000", "main", "both.go")
+
+	remoteDict := copyDict(t, shared)
+	remote := appendTestSession(t, append([]byte(nil), base...), remoteDict, mergeSessBoth, "01JCPBOTH00000000000000000", "main", "both.go")
+
+	body, dict, err := MergeBodies(local, remote, localDict.Encode(), remoteDict.Encode())
+	if err != nil {
+		t.Fatalf("MergeBodies: %v", err)
+	}
+	d, _ := LoadDict(dict)
+	decoded, err := DecodeBody(body, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Sessions) != 3 || len(decoded.Checkpoints) != 3 {
+		t.Errorf("got %d sessions, %d checkpoints; want 3 and 3", len(decoded.Sessions), len(decoded.Checkpoints))
+	}
+}
+
+func TestMergeBodies_LocalBehindRemote(t *testing.T) {
+	d := NewDict()
+	local := appendTestSession(t, NewBody(), d, mergeSessShared, "01JCPSHARED000000000000000", "main", "shared.go")
+	remote := appendTestSession(t, append([]byte(nil), local...), d, mergeSessRemote, "01JCPREMOTE000000000000000", "main", "remote.go")
+
+	body, _, err := MergeBodies(local, remote, d.Encode())
+	if err != nil {
+		t.Fatalf("MergeBodies: %v", err)
+	}
+	if !bytes.Equal(body, remote) {
+		t.Error("merging a body that is a prefix of remote should return remote")
+	}
+}
+
+func TestMergeBodies_BadArgs(t *testing.T) {
+	if _, _, err := MergeBodies(NewBody(), NewBody()); err == nil {
+		t.Error("expected error with no dicts")
+	}
+	if _, _, err := MergeBodies([]byte("junk"), NewBody(), nil); err == nil {
+		t.Error("expected error for a bad local body")
+	}
+}
+
+func TestRefMapper_InlinesV2HunkRefs(t *testing.T) {
+	from := NewDict()
+	pathRef := from.LookupOrAdd(NSPaths, "a.go")
+	hunkRef := from.LookupOrAdd(NSPaths, "@@ -1 +1 @@\n-x\n+y\n")
+	to := NewDict()
+	m := &refMapper{from: from, to: to}
+
+	cf := &CheckpointFrame{
+		CheckpointRef: from.LookupOrAdd(NSSessions, "01JCP"),
+		EmailRef:      from.LookupOrAdd(NSEmails, "dev@example.com"),
+		Files:         []FileTouchedRecord{{PathRef: pathRef, ChangeType: ChangeModified, HunkRefs: []uint64{hunkRef}}},
+	}
+	if err := m.checkpoint(cf); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	f := cf.Files[0]
+	if f.HunkRefs != nil || len(f.Hunks) != 1 || f.Hunks[0] != "@@ -1 +1 @@\n-x\n+y\n" {
+		t.Errorf("file after mapping: %+v", f)
+	}
+	if to.Len(NSPaths) != 1 {
+		t.Errorf("target dict paths: got %v, want only a.go", to.Paths)
+	}
+}
@@ -0,0 +1,52 @@
+package codec
+
+import "sync"
+
+// Pools of encoders and decoders. Creating one allocates zstd state and
+// loads the preset dictionary, which is costly next to encoding or decoding
+// a single frame. Both only use EncodeAll/DecodeAll, so a pooled instance
+// that is dropped by the GC without Close leaks no goroutines.
+var (
+	encoderPool sync.Pool
+	decoderPool sync.Pool
+)
+
+// GetEncoder returns a pooled Encoder, creating one if the pool is empty.
+// Hand it back with PutEncoder instead of calling Close.
+func GetEncoder() (*Encoder, error) {
+	if e, ok := encoderPool.Get().(*Encoder); ok {
+		return e, nil
+	}
+	return NewEncoder()
+}
+
+// PutEncoder resets e and returns it to the pool. e must not be used after.
+func PutEncoder(e *Encoder) {
+	if e == nil {
+		return
+	}
+	e.zw.Reset(nil)
+	encoderPool.Put(e)
+}
+
+// GetDecoder returns a pooled Decoder, creating one if the pool is empty.
+// Hand it back with PutDecoder instead of calling Close.
+func GetDecoder() (*Decoder, error) {
+	if d, ok := decoderPool.Get().(*Decoder); ok {
+		return d, nil
+	}
+	return NewDecoder()
+}
+
+// PutDecoder resets d and returns it to the pool. d must not be used after.
+// A decoder that fails to reset is closed instead.
+func PutDecoder(d *Decoder) {
+	if d == nil {
+		return
+	}
+	if err := d.zr.Reset(nil); err != nil {
+		d.Close()
+		return
+	}
+	decoderPool.Put(d)
+}
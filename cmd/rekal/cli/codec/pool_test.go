@@ -0,0 +1,100 @@
+package codec
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func poolTestFrame(i int) *SessionFrame {
+	return &SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		ActorType:  ActorHuman,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, Text: fmt.Sprintf("fix bug %d in the auth handler", i)},
+			{Role: RoleAssistant, TsDelta: 30, Text: "Let me read the file and fix the issue."},
+		},
+		ToolCalls: []ToolCallRecord{
+			{Tool: ToolEdit, PathFlag: PathDictRef, PathRef: uint64(i)},
+		},
+	}
+}
+
+func TestPool_Reuse(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		enc, err := GetEncoder()
+		if err != nil {
+			t.Fatalf("GetEncoder: %v", err)
+		}
+		encoded := enc.EncodeSessionFrame(poolTestFrame(i))
+		PutEncoder(enc)
+
+		dec, err := GetDecoder()
+		if err != nil {
+			t.Fatalf("GetDecoder: %v", err)
+		}
+		decoded, err := dec.DecodeSessionFrame(encoded[frameEnvSize:])
+		PutDecoder(dec)
+		if err != nil {
+			t.Fatalf("round %d: DecodeSessionFrame: %v", i, err)
+		}
+		if got, want := decoded.Turns[0].Text, poolTestFrame(i).Turns[0].Text; got != want {
+			t.Errorf("round %d: text %q, want %q", i, got, want)
+		}
+		if decoded.ToolCalls[0].PathRef != uint64(i) {
+			t.Errorf("round %d: path ref %d, want %d", i, decoded.ToolCalls[0].PathRef, i)
+		}
+	}
+}
+
+// poolBenchFrames encodes n distinct session frames and returns their
+// compressed payloads.
+func poolBenchFrames(b *testing.B, n int) [][]byte {
+	b.Helper()
+	enc, err := NewEncoder()
+	if err != nil {
+		b.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	frames := make([][]byte, n)
+	for i := range frames {
+		frames[i] = enc.EncodeSessionFrame(poolTestFrame(i))[frameEnvSize:]
+	}
+	return frames
+}
+
+// BenchmarkDecode1000Frames_Fresh creates a decoder per frame, as callers
+// did before the pool.
+func BenchmarkDecode1000Frames_Fresh(b *testing.B) {
+	frames := poolBenchFrames(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range frames {
+			dec, err := NewDecoder()
+			if err != nil {
+				b.Fatalf("NewDecoder: %v", err)
+			}
+			if _, err := dec.DecodeSessionFrame(f); err != nil {
+				b.Fatalf("DecodeSessionFrame: %v", err)
+			}
+			dec.Close()
+		}
+	}
+}
+
+func BenchmarkDecode1000Frames_Pooled(b *testing.B) {
+	frames := poolBenchFrames(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range frames {
+			dec, err := GetDecoder()
+			if err != nil {
+				b.Fatalf("GetDecoder: %v", err)
+			}
+			if _, err := dec.DecodeSessionFrame(f); err != nil {
+				b.Fatalf("DecodeSessionFrame: %v", err)
+			}
+			PutDecoder(dec)
+		}
+	}
+}
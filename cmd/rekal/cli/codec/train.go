@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	zdict "github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TrainedDictsFile is the optional rekal branch file holding the zstd
+// dictionaries trained by 'rekal codec train-dict' that frames on the
+// branch were compressed with. Each entry is a u32 little-endian length and
+// the dictionary. Entries are only appended, so older frames stay
+// decodable after a retrain.
+const TrainedDictsFile = "zstd.dicts"
+
+// MinTrainSessions is the fewest sessions TrainDict trains on. The trainer
+// looks for content repeated across sessions, and a handful has too little.
+const MinTrainSessions = 8
+
+// TrainDict trains a zstd dictionary of at most size bytes on the payloads
+// of frames. Its ID is derived from the payloads, so the same sessions give
+// the same dictionary, and never clashes with the preset dictionary's.
+func TrainDict(frames []*SessionFrame, size int) (dict []byte, err error) {
+	if len(frames) < MinTrainSessions {
+		return nil, fmt.Errorf("codec: train dict: need at least %d sessions, have %d", MinTrainSessions, len(frames))
+	}
+	samples := make([][]byte, len(frames))
+	h := fnv.New32a()
+	for i, sf := range frames {
+		samples[i] = encodeSessionPayload(sf)
+		_, _ = h.Write(samples[i])
+	}
+
+	// User dictionary IDs start at 32768; lower ones are reserved.
+	id := 32768 + h.Sum32()%(1<<31-32768)
+	if preset, err := DictID(presetDict); err == nil && id == preset {
+		id++
+	}
+
+	// The trainer panics when no content repeats across samples.
+	defer func() {
+		if r := recover(); r != nil {
+			dict, err = nil, errors.New("codec: train dict: the sessions share too little content to train on")
+		}
+	}()
+	d, err := zdict.BuildZstdDict(samples, zdict.Options{
+		MaxDictSize: size,
+		HashBytes:   6,
+		ZstdDictID:  id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("codec: train dict: %w", err)
+	}
+	return d, nil
+}
+
+// DictID returns the ID a zstd dictionary stamps on the frames it
+// compresses.
+func DictID(dict []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		return 0, fmt.Errorf("codec: %w", err)
+	}
+	return info.ID(), nil
+}
+
+// LoadTrainedDicts parses a TrainedDictsFile. Empty data holds no
+// dictionaries.
+func LoadTrainedDicts(data []byte) ([][]byte, error) {
+	var dicts [][]byte
+	for off := 0; off < len(data); {
+		if len(data)-off < 4 {
+			return nil, fmt.Errorf("codec: %s: truncated length at offset %d", TrainedDictsFile, off)
+		}
+		n := int(binary.LittleEndian.Uint32(data[off:]))
+		off += 4
+		if n > len(data)-off {
+			return nil, fmt.Errorf("codec: %s: entry at offset %d overruns the file", TrainedDictsFile, off-4)
+		}
+		d := data[off : off+n]
+		if _, err := DictID(d); err != nil {
+			return nil, fmt.Errorf("codec: %s: entry at offset %d: %w", TrainedDictsFile, off-4, err)
+		}
+		dicts = append(dicts, d)
+		off += n
+	}
+	return dicts, nil
+}
+
+// AppendTrainedDict returns the TrainedDictsFile data with dict appended,
+// or data unchanged if it already holds a dictionary with dict's ID.
+func AppendTrainedDict(data, dict []byte) ([]byte, error) {
+	id, err := DictID(dict)
+	if err != nil {
+		return nil, err
+	}
+	dicts, err := LoadTrainedDicts(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dicts {
+		if have, _ := DictID(d); have == id {
+			return data, nil
+		}
+	}
+	out := binary.LittleEndian.AppendUint32(append([]byte(nil), data...), uint32(len(dict)))
+	return append(out, dict...), nil
+}
@@ -0,0 +1,144 @@
+package codec
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// trainingFrames returns n sessions about one project, whose vocabulary the
+// preset dictionary has never seen.
+func trainingFrames(n int) []*SessionFrame {
+	rng := rand.New(rand.NewSource(1))
+	services := []string{"ledger-reconciler", "payout-scheduler", "fx-rate-ingestor", "settlement-batcher"}
+	var frames []*SessionFrame
+	for i := 0; i < n; i++ {
+		svc := services[rng.Intn(len(services))]
+		frames = append(frames, &SessionFrame{
+			SessionRef: uint64(i),
+			CapturedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour),
+			ActorType:  ActorHuman,
+			Turns: []TurnRecord{
+				{Role: RoleHuman, Text: fmt.Sprintf("the %s job fails reconciliation for merchant %d after the nightly settlement window", svc, rng.Intn(10000))},
+				{Role: RoleAssistant, TsDelta: 30, Text: fmt.Sprintf("Checking %s: the settlement window closes before the fx-rate snapshot is ingested, so the ledger-reconciler sees a stale rate for batch %d.", svc, rng.Intn(1000))},
+				{Role: RoleHuman, TsDelta: 90, Text: "make the reconciler wait for the fx-rate snapshot and add a regression test for the settlement window"},
+			},
+			ToolCalls: []ToolCallRecord{
+				{Tool: ToolRead, PathFlag: PathDictRef, PathRef: uint64(rng.Intn(5))},
+				{Tool: ToolBash, PathFlag: PathNull, CmdPrefix: "go test ./internal/settlement/..."},
+			},
+		})
+	}
+	return frames
+}
+
+func TestTrainDict_RoundtripAndRatio(t *testing.T) {
+	frames := trainingFrames(300)
+	trained, err := TrainDict(frames, 16<<10)
+	if err != nil {
+		t.Fatalf("TrainDict: %v", err)
+	}
+	id, err := DictID(trained)
+	if err != nil {
+		t.Fatalf("DictID: %v", err)
+	}
+	if presetID, _ := DictID(presetDict); id == presetID || id < 32768 {
+		t.Errorf("trained dict ID %d clashes with the preset (%d) or the reserved range", id, presetID)
+	}
+
+	preset, err := NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer preset.Close()
+	enc, err := NewEncoderDict(trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoderDicts(trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	// Compare on sessions the dictionary was not trained on.
+	held := trainingFrames(350)[300:]
+	var presetSize, trainedSize int
+	for _, sf := range held {
+		presetFrame := preset.EncodeSessionFrame(sf)
+		trainedFrame := enc.EncodeSessionFrame(sf)
+		presetSize += len(presetFrame)
+		trainedSize += len(trainedFrame)
+
+		got, err := dec.DecodeSessionFrame(trainedFrame[frameEnvSize:])
+		if err != nil {
+			t.Fatalf("decode trained frame: %v", err)
+		}
+		if got.Turns[1].Text != sf.Turns[1].Text || got.ToolCalls[1].CmdPrefix != sf.ToolCalls[1].CmdPrefix {
+			t.Fatalf("trained roundtrip mismatch: %+v", got)
+		}
+		// The same decoder still reads preset frames.
+		if _, err := dec.DecodeSessionFrame(presetFrame[frameEnvSize:]); err != nil {
+			t.Fatalf("decode preset frame: %v", err)
+		}
+	}
+	if trainedSize >= presetSize {
+		t.Errorf("trained dict: %d bytes, preset: %d; want the trained dict smaller", trainedSize, presetSize)
+	}
+	t.Logf("held-out sessions: preset %d bytes, trained %d bytes", presetSize, trainedSize)
+
+	// A decoder without the trained dict cannot read its frames.
+	plain, err := NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.DecodeSessionFrame(enc.EncodeSessionFrame(held[0])[frameEnvSize:]); err == nil {
+		t.Error("decoder without the trained dict decoded a trained frame")
+	}
+}
+
+func TestTrainDict_TooFewSessions(t *testing.T) {
+	if _, err := TrainDict(trainingFrames(MinTrainSessions-1), 16<<10); err == nil {
+		t.Error("expected an error for too few sessions")
+	}
+}
+
+func TestAppendTrainedDict(t *testing.T) {
+	a, err := TrainDict(trainingFrames(100), 8<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := TrainDict(trainingFrames(120), 8<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := AppendTrainedDict(nil, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := AppendTrainedDict(data, a); err != nil || len(again) != len(data) {
+		t.Fatalf("re-adding a dict: %d bytes, %v; want it unchanged at %d", len(again), err, len(data))
+	}
+	if data, err = AppendTrainedDict(data, b); err != nil {
+		t.Fatal(err)
+	}
+
+	dicts, err := LoadTrainedDicts(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicts) != 2 || string(dicts[0]) != string(a) || string(dicts[1]) != string(b) {
+		t.Fatalf("loaded %d dicts, want a then b", len(dicts))
+	}
+
+	if _, err := LoadTrainedDicts(data[:len(data)-1]); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+	if dicts, err := LoadTrainedDicts(nil); err != nil || len(dicts) != 0 {
+		t.Errorf("empty file = %d dicts, %v", len(dicts), err)
+	}
+}
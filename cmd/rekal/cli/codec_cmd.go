@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+// trainedDictFile is the zstd dictionary in .rekal/ that push compresses
+// new frames with, written by 'rekal codec train-dict'.
+const trainedDictFile = "zstd.dict"
+
+func newCodecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "codec",
+		Short: "Tune the wire format's compression",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(newTrainDictCmd())
+	return cmd
+}
+
+func newTrainDictCmd() *cobra.Command {
+	var (
+		samples int
+		size    int
+	)
+	cmd := &cobra.Command{
+		Use:   "train-dict",
+		Short: "Train a zstd dictionary on your sessions for push to compress with",
+		Long: `Train a zstd dictionary on the newest --samples sessions in the data DB
+and write it to .rekal/zstd.dict. Frames are compressed with a preset
+dictionary built into rekal; one trained on your team's own sessions
+usually compresses them better.
+
+The sessions are compressed with both dictionaries and the totals printed.
+The trained dictionary is kept only if it does better.
+
+Push compresses new frames with .rekal/zstd.dict when it exists and adds
+the dictionary to zstd.dicts on the rekal branch, so teammates can decode
+them. Each frame names its dictionary's ID, and zstd.dicts keeps every
+dictionary ever pushed, so frames from before a retrain stay readable.
+Frames already pushed are not recompressed. Without .rekal/zstd.dict, push
+uses the preset dictionary.`,
+		Example: `  rekal codec train-dict
+  rekal codec train-dict --samples 5000 --size 65536`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if samples <= 0 {
+				return fmt.Errorf("--samples must be positive")
+			}
+			if size < 1024 {
+				return fmt.Errorf("--size must be at least 1024 bytes")
+			}
+			return runTrainDict(cmd, gitRoot, samples, size)
+		},
+	}
+	cmd.Flags().IntVar(&samples, "samples", 1000, "Train on the newest n sessions")
+	cmd.Flags().IntVar(&size, "size", 16<<10, "Maximum dictionary size in bytes")
+	return cmd
+}
+
+func runTrainDict(cmd *cobra.Command, gitRoot string, samples, size int) error {
+	w := cmd.ErrOrStderr()
+
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data DB: %w", err)
+	}
+	defer dataDB.Close()
+
+	sessions, err := db.QuerySessionAges(dataDB)
+	if err != nil {
+		return err
+	}
+	if len(sessions) > samples {
+		sessions = sessions[:samples]
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no sessions to train on (run 'rekal checkpoint' first)")
+	}
+
+	// Refs only need to be consistent within the samples, so a scratch dict
+	// stands in for dict.bin.
+	refs := codec.NewDict()
+	frames := make([]*codec.SessionFrame, 0, len(sessions))
+	for _, s := range sessions {
+		sf, err := buildSessionFrame(dataDB, gitRoot, refs, s.ID)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, sf)
+	}
+
+	trained, err := codec.TrainDict(frames, size)
+	if err != nil {
+		return err
+	}
+	id, err := codec.DictID(trained)
+	if err != nil {
+		return err
+	}
+
+	presetSize, err := compressedSize(frames, nil)
+	if err != nil {
+		return err
+	}
+	trainedSize, err := compressedSize(frames, trained)
+	if err != nil {
+		return err
+	}
+	if !isQuiet(cmd) {
+		fmt.Fprintf(w, "rekal: %d session(s) compress to %d bytes with the preset dictionary, %d with the trained one\n",
+			len(frames), presetSize, trainedSize)
+	}
+	if trainedSize >= presetSize {
+		fmt.Fprintln(w, "rekal: the trained dictionary is no better; keeping the current one")
+		return nil
+	}
+
+	path := filepath.Join(gitRoot, ".rekal", trainedDictFile)
+	if err := os.WriteFile(path, trained, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", trainedDictFile, err)
+	}
+	if !isQuiet(cmd) {
+		fmt.Fprintf(w, "rekal: wrote dictionary %d (%d bytes) to .rekal/%s; push compresses new frames with it\n",
+			id, len(trained), trainedDictFile)
+	}
+	return nil
+}
+
+// compressedSize returns the total size of frames compressed with dict, or
+// with the preset dictionary when dict is nil.
+func compressedSize(frames []*codec.SessionFrame, dict []byte) (int, error) {
+	var enc *codec.Encoder
+	var err error
+	if dict == nil {
+		enc, err = codec.NewEncoder()
+	} else {
+		enc, err = codec.NewEncoderDict(dict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("create encoder: %w", err)
+	}
+	defer enc.Close()
+	total := 0
+	for _, sf := range frames {
+		total += len(enc.EncodeSessionFrame(sf))
+	}
+	return total, nil
+}
+
+// loadTrainedDict reads .rekal/zstd.dict, or returns nil if there is none.
+func loadTrainedDict(gitRoot string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(gitRoot, ".rekal", trainedDictFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", trainedDictFile, err)
+	}
+	if _, err := codec.DictID(data); err != nil {
+		return nil, fmt.Errorf(".rekal/%s: %w", trainedDictFile, err)
+	}
+	return data, nil
+}
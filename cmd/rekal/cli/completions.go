@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newCompletionsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completions bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for rekal.
+
+Load it in the current shell:
+  bash:        source <(rekal completions bash)
+  zsh:         source <(rekal completions zsh)
+  fish:        rekal completions fish | source
+  powershell:  rekal completions powershell | Out-String | Invoke-Expression
+
+Session, checkpoint, and author values are completed from the local data DB.`,
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		// The script is usually sourced directly, so skip the root's update
+		// check rather than append its notice to the output.
+		PersistentPreRun:  func(*cobra.Command, []string) {},
+		PersistentPostRun: func(*cobra.Command, []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+			return fmt.Errorf("unsupported shell %q", args[0])
+		},
+	}
+}
+
+// completeFromData returns a flag completion function that lists the distinct
+// values of column in table from the data DB, filtered by the typed prefix.
+// The DB is opened read-only, so a TAB press doesn't contend with a running
+// checkpoint or push. Outside an initialized repo, or if the DB can't be
+// opened, it offers nothing.
+func completeFromData(table, column string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		gitRoot, err := EnsureGitRoot()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if err := EnsureInitDone(gitRoot); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		dataDB, err := db.OpenDataReadOnly(gitRoot)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		defer dataDB.Close()
+
+		values, err := db.QueryDistinctValues(dataDB, table, column, toComplete)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
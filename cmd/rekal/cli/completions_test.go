@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompletionsCmd_Shells(t *testing.T) {
+	t.Parallel()
+
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		root := NewRootCmd()
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetArgs([]string{"completions", shell})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("completions %s: %v", shell, err)
+		}
+		if out.Len() == 0 {
+			t.Errorf("completions %s: empty script", shell)
+		}
+	}
+}
+
+func TestCompletionsCmd_UnknownShell(t *testing.T) {
+	t.Parallel()
+
+	root := NewRootCmd()
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"completions", "tcsh"})
+	if err := root.Execute(); err == nil {
+		t.Error("completions tcsh should fail")
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AliasesFile is the name of the optional email alias file in .rekal/.
+const AliasesFile = "aliases"
+
+// LoadEmailAliases reads <gitRoot>/.rekal/aliases, which maps the other
+// emails a person commits under to one canonical email, one per line:
+//
+//	alice@users.noreply.github.com = alice@corp.com
+//
+// Blank lines and lines starting with # are ignored. Aliases match
+// case-insensitively. A missing file means no aliases.
+func LoadEmailAliases(gitRoot string) (map[string]string, error) {
+	path := filepath.Join(gitRoot, ".rekal", AliasesFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", AliasesFile, err)
+	}
+
+	aliases := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		alias, canonical, ok := strings.Cut(line, "=")
+		alias, canonical = strings.TrimSpace(alias), strings.TrimSpace(canonical)
+		if !ok || alias == "" || canonical == "" || strings.ContainsAny(alias+canonical, " \t") {
+			return nil, fmt.Errorf("%s:%d: want <email> = <canonical email>, got %q", AliasesFile, n, line)
+		}
+		aliases[strings.ToLower(alias)] = canonical
+	}
+	return aliases, scanner.Err()
+}
+
+// CanonicalEmail returns the canonical form of email under aliases, or email
+// itself if it has no alias.
+func CanonicalEmail(aliases map[string]string, email string) string {
+	if c, ok := aliases[strings.ToLower(email)]; ok {
+		return c
+	}
+	return email
+}
+
+// canonicalEmailSQL returns a SQL expression for the canonical form of the
+// email column col under aliases, or col itself when there are none. The
+// aliases are inlined as string literals, so callers load them once with
+// LoadEmailAliases and reuse the expression for every session.
+func canonicalEmailSQL(aliases map[string]string, col string) string {
+	if len(aliases) == 0 {
+		return col
+	}
+	keys := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		keys = append(keys, alias)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CASE lower(%s)", col)
+	for _, alias := range keys {
+		fmt.Fprintf(&b, " WHEN %s THEN %s", sqlString(alias), sqlString(aliases[alias]))
+	}
+	fmt.Fprintf(&b, " ELSE %s END", col)
+	return b.String()
+}
+
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
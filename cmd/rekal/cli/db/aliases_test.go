@@ -0,0 +1,58 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeAliases(t *testing.T, content string) string {
+	t.Helper()
+	gitRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(gitRoot, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitRoot, ".rekal", AliasesFile), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return gitRoot
+}
+
+func TestLoadEmailAliases(t *testing.T) {
+	gitRoot := writeAliases(t, `# alice
+Alice@Users.Noreply.GitHub.com = alice@corp.com
+
+alice@laptop.local=alice@corp.com
+`)
+	aliases, err := LoadEmailAliases(gitRoot)
+	if err != nil {
+		t.Fatalf("LoadEmailAliases: %v", err)
+	}
+	if len(aliases) != 2 {
+		t.Fatalf("got %d aliases, want 2: %v", len(aliases), aliases)
+	}
+	for _, email := range []string{"alice@users.noreply.github.com", "ALICE@laptop.local", "alice@corp.com"} {
+		if got := CanonicalEmail(aliases, email); got != "alice@corp.com" {
+			t.Errorf("CanonicalEmail(%q) = %q, want alice@corp.com", email, got)
+		}
+	}
+	if got := CanonicalEmail(aliases, "bob@corp.com"); got != "bob@corp.com" {
+		t.Errorf("unaliased email changed to %q", got)
+	}
+}
+
+func TestLoadEmailAliases_Missing(t *testing.T) {
+	aliases, err := LoadEmailAliases(t.TempDir())
+	if err != nil || aliases != nil {
+		t.Errorf("missing file: got %v, %v; want no aliases", aliases, err)
+	}
+}
+
+func TestLoadEmailAliases_Malformed(t *testing.T) {
+	gitRoot := writeAliases(t, "a@x = b@x\nc@x d@x\n")
+	_, err := LoadEmailAliases(gitRoot)
+	if err == nil || !strings.Contains(err.Error(), "aliases:2") {
+		t.Errorf("expected an error naming line 2, got %v", err)
+	}
+}
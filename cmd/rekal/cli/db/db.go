@@ -1,26 +1,32 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb"
 )
 
-// OpenData opens (or creates) the data DB at <gitRoot>/.rekal/data.db.
+// OpenData opens (or creates) the data DB at <gitRoot>/.rekal/data.db,
+// migrating tables created by older versions.
 func OpenData(gitRoot string) (*sql.DB, error) {
 	path := filepath.Join(gitRoot, ".rekal", "data.db")
-	return open(path)
+	return open(path, Migrate)
 }
 
-// OpenIndex opens (or creates) the index DB at <gitRoot>/.rekal/index.db.
+// OpenIndex opens (or creates) the index DB at <gitRoot>/.rekal/index.db,
+// migrating tables created by older versions.
 func OpenIndex(gitRoot string) (*sql.DB, error) {
 	path := filepath.Join(gitRoot, ".rekal", "index.db")
-	return open(path)
+	return open(path, MigrateIndex)
 }
 
-func open(path string) (*sql.DB, error) {
+func open(path string, migrate func(*sql.DB) error) (*sql.DB, error) {
 	db, err := sql.Open("duckdb", path)
 	if err != nil {
 		return nil, fmt.Errorf("open database %s: %w", path, err)
@@ -29,12 +35,145 @@ func open(path string) (*sql.DB, error) {
 		db.Close()
 		return nil, fmt.Errorf("ping database %s: %w", path, err)
 	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate database %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// OpenDataReadOnly opens the data DB read-only. A read-only connection
+// cannot migrate, so a data DB that does not exist yet or is behind
+// DataSchemaVersion is first opened read-write with OpenData. Statements
+// that write to the database fail; COPY ... TO a file still works, so
+// callers that take SQL from users must vet it too.
+func OpenDataReadOnly(gitRoot string) (*sql.DB, error) {
+	path := filepath.Join(gitRoot, ".rekal", "data.db")
+	return openReadOnlyUpgraded(path, dataBehind, func() (*sql.DB, error) { return OpenData(gitRoot) })
+}
+
+// OpenIndexReadOnly opens the index DB read-only, creating or migrating it
+// first with OpenIndex only when it does not exist yet or is behind
+// IndexSchemaVersion.
+func OpenIndexReadOnly(gitRoot string) (*sql.DB, error) {
+	path := filepath.Join(gitRoot, ".rekal", "index.db")
+	return openReadOnlyUpgraded(path, indexBehind, func() (*sql.DB, error) { return OpenIndex(gitRoot) })
+}
+
+// openReadOnlyUpgraded opens path read-only. Only when it does not exist
+// yet or behind reports its schema out of date is it first opened
+// read-write with upgrade.
+func openReadOnlyUpgraded(path string, behind func(*sql.DB) (bool, error), upgrade func() (*sql.DB, error)) (*sql.DB, error) {
+	d, err := openReadOnlyCurrent(path, behind)
+	if err != nil || d != nil {
+		return d, err
+	}
+	upgraded, err := upgrade()
+	if err != nil {
+		return nil, err
+	}
+	upgraded.Close()
+	return openReadOnly(path)
+}
+
+// openReadOnlyCurrent opens path read-only and returns it if behind
+// reports its schema current. It returns nil, with no error, when path
+// does not exist or is behind, for the caller to upgrade and reopen.
+func openReadOnlyCurrent(path string, behind func(*sql.DB) (bool, error)) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	d, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	stale, err := behind(d)
+	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("check schema of %s: %w", path, err)
+	}
+	if stale {
+		d.Close()
+		return nil, nil
+	}
+	return d, nil
+}
+
+// ensureCurrent opens path read-write with upgrade when it does not exist
+// yet or behind reports its schema out of date, so that it can then be
+// opened or attached read-only.
+func ensureCurrent(path string, behind func(*sql.DB) (bool, error), upgrade func() (*sql.DB, error)) error {
+	d, err := openReadOnlyCurrent(path, behind)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		d, err = upgrade()
+		if err != nil {
+			return err
+		}
+	}
+	d.Close()
+	return nil
+}
+
+func openReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("duckdb", path+"?access_mode=read_only")
+	if err != nil {
+		return nil, fmt.Errorf("open database %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database %s: %w", path, err)
+	}
 	return db, nil
 }
 
+// AttachData attaches the data DB to d read-only as data_db, migrating it
+// first if it is behind, since a read-only attach cannot. Detach it with
+// DETACH data_db.
+func AttachData(d *sql.DB, gitRoot string) error {
+	path := filepath.Join(gitRoot, ".rekal", "data.db")
+	if err := ensureCurrent(path, dataBehind, func() (*sql.DB, error) { return OpenData(gitRoot) }); err != nil {
+		return err
+	}
+	return attach(d, path, "data_db")
+}
+
+// AttachIndex attaches the index DB to d read-only as index_db, migrating
+// it first if it is behind. It fails if there is no index DB yet. Detach it
+// with DETACH index_db.
+func AttachIndex(d *sql.DB, gitRoot string) error {
+	path := filepath.Join(gitRoot, ".rekal", "index.db")
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("attach index_db: %w", err)
+	}
+	if err := ensureCurrent(path, indexBehind, func() (*sql.DB, error) { return OpenIndex(gitRoot) }); err != nil {
+		return err
+	}
+	return attach(d, path, "index_db")
+}
+
+func attach(d *sql.DB, path, name string) error {
+	if _, err := d.Exec(fmt.Sprintf("ATTACH '%s' AS %s (READ_ONLY)", path, name)); err != nil {
+		return fmt.Errorf("attach %s: %w", name, err)
+	}
+	return nil
+}
+
+// CountSessionsCapturedAfter returns how many sessions in the data DB were
+// captured after t.
+func CountSessionsCapturedAfter(d *sql.DB, t time.Time) (int, error) {
+	var n int
+	if err := d.QueryRow("SELECT count(*) FROM sessions WHERE captured_at > $1", t).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count sessions captured after %s: %w", t.Format(time.RFC3339), err)
+	}
+	return n, nil
+}
+
 // SessionExistsByHash reports whether a session with the given content hash
 // already exists in the data DB. Used for deduplication.
-func SessionExistsByHash(d *sql.DB, hash string) (bool, error) {
+func SessionExistsByHash(d Querier, hash string) (bool, error) {
 	var count int
 	err := d.QueryRow("SELECT count(*) FROM sessions WHERE session_hash = $1", hash).Scan(&count)
 	if err != nil {
@@ -43,12 +182,76 @@ func SessionExistsByHash(d *sql.DB, hash string) (bool, error) {
 	return count > 0, nil
 }
 
-// InsertSession inserts a new session row into the data DB.
-func InsertSession(d *sql.DB, id, parentSessionID, hash, actorType, agentID, userEmail, branch, capturedAt string) error {
+// Execer is what the Insert functions write through: a *sql.DB, or a Batch
+// to group rows in one transaction.
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Querier is what lookups that must see a Batch's own uncommitted rows
+// read through: a *sql.DB, or a Batch.
+type Querier interface {
+	Execer
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Batch runs inserts in one transaction, preparing each distinct statement
+// once and reusing it for every row. Checkpoint and import write a session's
+// turns and tool calls through one, which is far faster than a DuckDB
+// commit per row.
+type Batch struct {
+	tx    *sql.Tx
+	stmts map[string]*sql.Stmt
+}
+
+// BeginBatch starts a Batch on d. Finish it with Commit or Rollback.
+func BeginBatch(d *sql.DB) (*Batch, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin batch: %w", err)
+	}
+	return &Batch{tx: tx, stmts: make(map[string]*sql.Stmt)}, nil
+}
+
+// Exec runs query in the batch's transaction, preparing it on first use.
+func (b *Batch) Exec(query string, args ...any) (sql.Result, error) {
+	stmt, ok := b.stmts[query]
+	if !ok {
+		var err error
+		if stmt, err = b.tx.Prepare(query); err != nil {
+			return nil, err
+		}
+		b.stmts[query] = stmt
+	}
+	return stmt.Exec(args...)
+}
+
+// QueryRow runs query in the batch's transaction, so it sees the rows the
+// batch has written.
+func (b *Batch) QueryRow(query string, args ...any) *sql.Row {
+	return b.tx.QueryRow(query, args...)
+}
+
+// Commit commits the batch. Its prepared statements close with it.
+func (b *Batch) Commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the batch. It is a no-op after Commit.
+func (b *Batch) Rollback() {
+	_ = b.tx.Rollback()
+}
+
+// InsertSession inserts a new session row into the data DB. totalCost and
+// totalDurationMs are zero when the transcript has no summary line.
+func InsertSession(d Execer, id, parentSessionID, hash, actorType, agentID, userEmail, branch, cwd, capturedAt string, totalCost float64, totalDurationMs int64) error {
 	_, err := d.Exec(
-		`INSERT INTO sessions (id, parent_session_id, session_hash, captured_at, actor_type, agent_id, user_email, branch)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		id, nullIfEmpty(parentSessionID), hash, capturedAt, actorType, agentID, userEmail, branch,
+		`INSERT INTO sessions (id, parent_session_id, session_hash, captured_at, actor_type, agent_id, user_email, branch, cwd, total_cost, total_duration_ms)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		id, nullIfEmpty(parentSessionID), hash, capturedAt, actorType, agentID, userEmail, branch, nullIfEmpty(cwd), totalCost, totalDurationMs,
 	)
 	if err != nil {
 		return fmt.Errorf("insert session: %w", err)
@@ -65,12 +268,12 @@ func nullIfEmpty(s string) interface{} {
 	return s
 }
 
-// InsertTurn inserts a turn row into the data DB.
-func InsertTurn(d *sql.DB, id, sessionID string, turnIndex int, role, content, ts string) error {
+// InsertTurn inserts a turn row into the data DB, with its content hash.
+func InsertTurn(d Execer, id, sessionID string, turnIndex int, role, content, ts string) error {
 	_, err := d.Exec(
-		`INSERT INTO turns (id, session_id, turn_index, role, content, ts)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		id, sessionID, turnIndex, role, content, nullIfEmpty(ts),
+		`INSERT INTO turns (id, session_id, turn_index, role, content, ts, content_hash)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id, sessionID, turnIndex, role, content, nullIfEmpty(ts), TurnContentHash(role, content),
 	)
 	if err != nil {
 		return fmt.Errorf("insert turn: %w", err)
@@ -78,12 +281,34 @@ func InsertTurn(d *sql.DB, id, sessionID string, turnIndex int, role, content, t
 	return nil
 }
 
+// TurnContentHash returns the hex SHA-256 of a turn's role and content, the
+// key for spotting the same turn replayed in another session file. A NUL
+// separates the two so no role/content split collides with another.
+func TurnContentHash(role, content string) string {
+	h := sha256.New()
+	h.Write([]byte(role))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TurnExists reports whether a turn with the given content hash is in the
+// data DB, looked up through the turns_content_hash index.
+func TurnExists(d Querier, hash string) (bool, error) {
+	var count int
+	err := d.QueryRow("SELECT count(*) FROM turns WHERE content_hash = $1", hash).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check turn hash: %w", err)
+	}
+	return count > 0, nil
+}
+
 // InsertToolCall inserts a tool_call row into the data DB.
-func InsertToolCall(d *sql.DB, id, sessionID string, callOrder int, tool, path, cmdPrefix string) error {
+func InsertToolCall(d Execer, id, sessionID string, callOrder int, tool, server, path, cmdPrefix string, failed bool, errorSnippet string) error {
 	_, err := d.Exec(
-		`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		id, sessionID, callOrder, tool, path, cmdPrefix,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix, failed, error_snippet, server)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		id, sessionID, callOrder, tool, path, cmdPrefix, failed, nullIfEmpty(errorSnippet), nullIfEmpty(server),
 	)
 	if err != nil {
 		return fmt.Errorf("insert tool_call: %w", err)
@@ -92,7 +317,7 @@ func InsertToolCall(d *sql.DB, id, sessionID string, callOrder int, tool, path,
 }
 
 // InsertCheckpoint inserts a new checkpoint row into the data DB.
-func InsertCheckpoint(d *sql.DB, id, gitSHA, branch, email, ts, actorType, agentID string) error {
+func InsertCheckpoint(d Execer, id, gitSHA, branch, email, ts, actorType, agentID string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoints (id, git_sha, git_branch, user_email, ts, actor_type, agent_id)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
@@ -105,11 +330,17 @@ func InsertCheckpoint(d *sql.DB, id, gitSHA, branch, email, ts, actorType, agent
 }
 
 // InsertFileTouched inserts a file_touched row.
-func InsertFileTouched(d *sql.DB, id, checkpointID, filePath, changeType string) error {
+func InsertFileTouched(d Execer, id, checkpointID, filePath, changeType string) error {
+	return InsertFileTouchedDiff(d, id, checkpointID, filePath, changeType, "")
+}
+
+// InsertFileTouchedDiff inserts a file_touched row with the file's unified
+// diff hunks, stored as NULL when diff is empty.
+func InsertFileTouchedDiff(d Execer, id, checkpointID, filePath, changeType, diff string) error {
 	_, err := d.Exec(
-		`INSERT INTO files_touched (id, checkpoint_id, file_path, change_type)
-		 VALUES ($1, $2, $3, $4)`,
-		id, checkpointID, filePath, changeType,
+		`INSERT INTO files_touched (id, checkpoint_id, file_path, change_type, diff)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		id, checkpointID, filePath, changeType, nullIfEmpty(diff),
 	)
 	if err != nil {
 		return fmt.Errorf("insert file_touched: %w", err)
@@ -118,7 +349,7 @@ func InsertFileTouched(d *sql.DB, id, checkpointID, filePath, changeType string)
 }
 
 // InsertCheckpointSession inserts a checkpoint_sessions junction row.
-func InsertCheckpointSession(d *sql.DB, checkpointID, sessionID string) error {
+func InsertCheckpointSession(d Execer, checkpointID, sessionID string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoint_sessions (checkpoint_id, session_id)
 		 VALUES ($1, $2)`,
@@ -132,7 +363,7 @@ func InsertCheckpointSession(d *sql.DB, checkpointID, sessionID string) error {
 
 // GetCheckpointState returns the cached state for a session file path.
 // Returns found=false if no entry exists.
-func GetCheckpointState(d *sql.DB, filePath string) (byteSize int64, fileHash string, found bool, err error) {
+func GetCheckpointState(d Querier, filePath string) (byteSize int64, fileHash string, found bool, err error) {
 	err = d.QueryRow(
 		"SELECT byte_size, file_hash FROM checkpoint_state WHERE file_path = $1",
 		filePath,
@@ -147,7 +378,7 @@ func GetCheckpointState(d *sql.DB, filePath string) (byteSize int64, fileHash st
 }
 
 // UpsertCheckpointState inserts or updates the cached state for a session file.
-func UpsertCheckpointState(d *sql.DB, filePath string, byteSize int64, fileHash string) error {
+func UpsertCheckpointState(d Execer, filePath string, byteSize int64, fileHash string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoint_state (file_path, byte_size, file_hash)
 		 VALUES ($1, $2, $3)
@@ -160,6 +391,58 @@ func UpsertCheckpointState(d *sql.DB, filePath string, byteSize int64, fileHash
 	return nil
 }
 
+// CheckpointStateHasContent reports whether any session file, under any
+// path, is cached with this size and hash. Claude Code can rename a
+// transcript; a match means its content was already handled under the old
+// name.
+func CheckpointStateHasContent(d Querier, byteSize int64, fileHash string) (bool, error) {
+	var n int
+	err := d.QueryRow(
+		"SELECT count(*) FROM checkpoint_state WHERE byte_size = $1 AND file_hash = $2",
+		byteSize, fileHash,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("get checkpoint_state: %w", err)
+	}
+	return n > 0, nil
+}
+
+// PruneCheckpointState deletes the cached state of session files that no
+// longer exist, and returns how many rows it removed. Rows whose key is not
+// an absolute path belong to a session source other than files and are
+// kept.
+func PruneCheckpointState(d *sql.DB) (int, error) {
+	rows, err := d.Query("SELECT file_path FROM checkpoint_state")
+	if err != nil {
+		return 0, fmt.Errorf("list checkpoint_state: %w", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close() //nolint:errcheck
+			return 0, fmt.Errorf("scan checkpoint_state: %w", err)
+		}
+		if !filepath.IsAbs(p) {
+			continue
+		}
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			stale = append(stale, p)
+		}
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("list checkpoint_state: %w", err)
+	}
+
+	for _, p := range stale {
+		if _, err := d.Exec("DELETE FROM checkpoint_state WHERE file_path = $1", p); err != nil {
+			return 0, fmt.Errorf("prune checkpoint_state: %w", err)
+		}
+	}
+	return len(stale), nil
+}
+
 // CheckpointRow represents a row from the checkpoints table.
 type CheckpointRow struct {
 	ID        string
@@ -193,6 +476,26 @@ func QueryUnexportedCheckpoints(d *sql.DB) ([]CheckpointRow, error) {
 	return result, rows.Err()
 }
 
+// QueryExportedCheckpointIDs returns the IDs of checkpoints where
+// exported = TRUE, ordered by ts.
+func QueryExportedCheckpointIDs(d *sql.DB) ([]string, error) {
+	rows, err := d.Query("SELECT id FROM checkpoints WHERE exported = TRUE ORDER BY ts")
+	if err != nil {
+		return nil, fmt.Errorf("query exported checkpoints: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan checkpoint: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // MarkCheckpointsExported sets exported = TRUE for the given checkpoint IDs.
 func MarkCheckpointsExported(d *sql.DB, ids []string) error {
 	for _, id := range ids {
@@ -225,15 +528,116 @@ func QuerySessionsByCheckpoint(d *sql.DB, checkpointID string) ([]string, error)
 	return ids, rows.Err()
 }
 
+// QuerySessionIDsByCheckpointSHA returns the set of session IDs linked to any
+// checkpoint whose git SHA starts with shaPrefix.
+func QuerySessionIDsByCheckpointSHA(d *sql.DB, shaPrefix string) (map[string]bool, error) {
+	rows, err := d.Query(`
+		SELECT DISTINCT cs.session_id
+		FROM checkpoint_sessions cs
+		JOIN checkpoints c ON c.id = cs.checkpoint_id
+		WHERE starts_with(c.git_sha, $1)
+	`, shaPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions by checkpoint sha: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
+// QuerySessionIDsByDir returns the set of session IDs whose working
+// directory is dir or below it. dir is git-root-relative, in slash form.
+func QuerySessionIDsByDir(d *sql.DB, dir string) (map[string]bool, error) {
+	rows, err := d.Query("SELECT id FROM sessions WHERE cwd = $1 OR starts_with(cwd, $1 || '/')", dir)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions by dir: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
+// TagSession attaches tag to a session. It reports false if the session
+// already had the tag.
+func TagSession(d *sql.DB, sessionID, tag string) (bool, error) {
+	res, err := d.Exec(
+		`INSERT INTO session_tags (session_id, tag, tagged_at) VALUES ($1, $2, current_timestamp)
+		 ON CONFLICT (session_id, tag) DO NOTHING`,
+		sessionID, tag,
+	)
+	if err != nil {
+		return false, fmt.Errorf("tag session: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("tag session: %w", err)
+	}
+	return n > 0, nil
+}
+
+// UntagSession removes tag from a session. It reports false if the session
+// did not have the tag.
+func UntagSession(d *sql.DB, sessionID, tag string) (bool, error) {
+	res, err := d.Exec("DELETE FROM session_tags WHERE session_id = $1 AND tag = $2", sessionID, tag)
+	if err != nil {
+		return false, fmt.Errorf("untag session: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("untag session: %w", err)
+	}
+	return n > 0, nil
+}
+
+// QuerySessionIDsByTag returns the set of session IDs carrying tag.
+func QuerySessionIDsByTag(d *sql.DB, tag string) (map[string]bool, error) {
+	rows, err := d.Query("SELECT session_id FROM session_tags WHERE tag = $1", tag)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions by tag: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
 // SessionRow represents a session with its turns and tool calls.
 type SessionRow struct {
-	ID         string
-	Hash       string
-	CapturedAt string
-	ActorType  string
-	AgentID    string
-	Email      string
-	Branch     string
+	ID              string
+	ParentSessionID string
+	Hash            string
+	CapturedAt      string
+	ActorType       string
+	AgentID         string
+	Email           string
+	Branch          string
+	CWD             string // relative to the git root; "" if unknown or outside it
+
+	TotalCost       float64
+	TotalDurationMs int64
 }
 
 // TurnRow represents a turn from the turns table.
@@ -246,19 +650,23 @@ type TurnRow struct {
 
 // ToolCallRow represents a tool call from the tool_calls table.
 type ToolCallRow struct {
-	CallOrder int
-	Tool      string
-	Path      string
-	CmdPrefix string
+	CallOrder    int
+	Tool         string
+	Server       string // MCP server; empty for built-in tools
+	Path         string
+	CmdPrefix    string
+	Failed       bool
+	ErrorSnippet string
 }
 
 // QuerySession returns a session row by ID.
 func QuerySession(d *sql.DB, id string) (*SessionRow, error) {
 	r := &SessionRow{}
 	err := d.QueryRow(
-		`SELECT id, session_hash, captured_at, actor_type, COALESCE(agent_id, ''), COALESCE(user_email, ''), COALESCE(branch, '')
+		`SELECT id, COALESCE(parent_session_id, ''), session_hash, captured_at, actor_type, COALESCE(agent_id, ''), COALESCE(user_email, ''), COALESCE(branch, ''),
+		        COALESCE(cwd, ''), COALESCE(total_cost, 0), COALESCE(total_duration_ms, 0)
 		 FROM sessions WHERE id = $1`, id,
-	).Scan(&r.ID, &r.Hash, &r.CapturedAt, &r.ActorType, &r.AgentID, &r.Email, &r.Branch)
+	).Scan(&r.ID, &r.ParentSessionID, &r.Hash, &r.CapturedAt, &r.ActorType, &r.AgentID, &r.Email, &r.Branch, &r.CWD, &r.TotalCost, &r.TotalDurationMs)
 	if err != nil {
 		return nil, fmt.Errorf("query session: %w", err)
 	}
@@ -269,7 +677,7 @@ func QuerySession(d *sql.DB, id string) (*SessionRow, error) {
 type TurnPageOptions struct {
 	Offset int
 	Limit  int
-	Role   string // "" = all, "human", "assistant"
+	Role   string // "" = all, "human", "assistant", "thinking"
 }
 
 // QueryTurnsPage returns a page of turns for a session with optional role filtering.
@@ -340,7 +748,8 @@ func QueryTurns(d *sql.DB, sessionID string) ([]TurnRow, error) {
 // QueryToolCalls returns tool calls for a session, ordered by call_order.
 func QueryToolCalls(d *sql.DB, sessionID string) ([]ToolCallRow, error) {
 	rows, err := d.Query(
-		`SELECT call_order, tool, COALESCE(path, ''), COALESCE(cmd_prefix, '')
+		`SELECT call_order, tool, COALESCE(server, ''), COALESCE(path, ''), COALESCE(cmd_prefix, ''),
+		        COALESCE(failed, FALSE), COALESCE(error_snippet, '')
 		 FROM tool_calls WHERE session_id = $1 ORDER BY call_order`, sessionID,
 	)
 	if err != nil {
@@ -351,7 +760,7 @@ func QueryToolCalls(d *sql.DB, sessionID string) ([]ToolCallRow, error) {
 	var result []ToolCallRow
 	for rows.Next() {
 		var r ToolCallRow
-		if err := rows.Scan(&r.CallOrder, &r.Tool, &r.Path, &r.CmdPrefix); err != nil {
+		if err := rows.Scan(&r.CallOrder, &r.Tool, &r.Server, &r.Path, &r.CmdPrefix, &r.Failed, &r.ErrorSnippet); err != nil {
 			return nil, fmt.Errorf("scan tool_call: %w", err)
 		}
 		result = append(result, r)
@@ -381,6 +790,29 @@ func QueryFilesTouched(d *sql.DB, checkpointID string) ([]struct{ Path, ChangeTy
 	return result, rows.Err()
 }
 
+// QueryFileDiffs returns the captured diff of each file in a checkpoint
+// that has one, by path.
+func QueryFileDiffs(d *sql.DB, checkpointID string) (map[string]string, error) {
+	rows, err := d.Query(
+		"SELECT file_path, diff FROM files_touched WHERE checkpoint_id = $1 AND diff IS NOT NULL",
+		checkpointID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query file diffs: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	diffs := make(map[string]string)
+	for rows.Next() {
+		var path, diff string
+		if err := rows.Scan(&path, &diff); err != nil {
+			return nil, fmt.Errorf("scan file diff: %w", err)
+		}
+		diffs[path] = diff
+	}
+	return diffs, rows.Err()
+}
+
 // CheckpointExists reports whether a checkpoint with the given ID exists.
 func CheckpointExists(d *sql.DB, id string) (bool, error) {
 	var count int
@@ -391,8 +823,40 @@ func CheckpointExists(d *sql.DB, id string) (bool, error) {
 	return count > 0, nil
 }
 
+// SetSessionSource records the ID Claude Code gave a session, so a session
+// resuming it can be linked to it later.
+func SetSessionSource(d Execer, id, sourceID string) error {
+	if _, err := d.Exec("UPDATE sessions SET source_session_id = $2 WHERE id = $1", id, sourceID); err != nil {
+		return fmt.Errorf("set session source: %w", err)
+	}
+	return nil
+}
+
+// LinkSessionParent sets a session's parent_session_id to the session whose
+// Claude Code ID is parentSourceID, preferring the latest capture of it.
+// Agent sessions share their main session's Claude Code ID and are never
+// parents. It reports whether a parent was found.
+func LinkSessionParent(d Querier, id, parentSourceID string) (bool, error) {
+	var parentID string
+	err := d.QueryRow(
+		`SELECT id FROM sessions
+		 WHERE source_session_id = $1 AND COALESCE(agent_id, '') = '' AND id <> $2
+		 ORDER BY captured_at DESC, id DESC LIMIT 1`, parentSourceID, id,
+	).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find parent session: %w", err)
+	}
+	if _, err := d.Exec("UPDATE sessions SET parent_session_id = $2 WHERE id = $1", id, parentID); err != nil {
+		return false, fmt.Errorf("link parent session: %w", err)
+	}
+	return true, nil
+}
+
 // SessionExistsByID reports whether a session with the given ID exists.
-func SessionExistsByID(d *sql.DB, id string) (bool, error) {
+func SessionExistsByID(d Querier, id string) (bool, error) {
 	var count int
 	err := d.QueryRow("SELECT count(*) FROM sessions WHERE id = $1", id).Scan(&count)
 	if err != nil {
@@ -400,3 +864,142 @@ func SessionExistsByID(d *sql.DB, id string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// QueryDistinctValues returns the distinct non-empty values of column in
+// table that start with prefix, sorted. table and column are interpolated
+// into the SQL and must be trusted identifiers, never user input.
+func QueryDistinctValues(d *sql.DB, table, column, prefix string) ([]string, error) {
+	rows, err := d.Query(
+		fmt.Sprintf(`SELECT DISTINCT %[2]s FROM %[1]s
+		 WHERE %[2]s IS NOT NULL AND %[2]s != '' AND starts_with(%[2]s, $1)
+		 ORDER BY %[2]s`, table, column),
+		prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query %s.%s: %w", table, column, err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var result []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("scan %s.%s: %w", table, column, err)
+		}
+		result = append(result, v)
+	}
+	return result, rows.Err()
+}
+
+// SessionAge is a session's ID and capture time, for choosing what to prune.
+type SessionAge struct {
+	ID         string
+	CapturedAt time.Time
+	Email      string
+	Turns      int
+}
+
+// QuerySessionAges returns every session, newest first.
+func QuerySessionAges(d *sql.DB) ([]SessionAge, error) {
+	rows, err := d.Query(`
+		SELECT s.id, s.captured_at, COALESCE(s.user_email, ''),
+			(SELECT count(*) FROM turns t WHERE t.session_id = s.id)
+		FROM sessions s
+		ORDER BY s.captured_at DESC, s.id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var result []SessionAge
+	for rows.Next() {
+		var s SessionAge
+		if err := rows.Scan(&s.ID, &s.CapturedAt, &s.Email, &s.Turns); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+// QueryOrphanedCheckpoints returns the checkpoints linked to at least one of
+// sessionIDs and to no other session, i.e. those deleting sessionIDs would
+// leave empty.
+func QueryOrphanedCheckpoints(d *sql.DB, sessionIDs []string) ([]string, error) {
+	prune := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		prune[id] = true
+	}
+
+	rows, err := d.Query("SELECT checkpoint_id, session_id FROM checkpoint_sessions ORDER BY checkpoint_id")
+	if err != nil {
+		return nil, fmt.Errorf("query checkpoint_sessions: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var order []string
+	kept := make(map[string]bool)
+	linked := make(map[string]bool)
+	for rows.Next() {
+		var checkpointID, sessionID string
+		if err := rows.Scan(&checkpointID, &sessionID); err != nil {
+			return nil, fmt.Errorf("scan checkpoint_sessions: %w", err)
+		}
+		if !prune[sessionID] {
+			kept[checkpointID] = true
+		} else if !linked[checkpointID] {
+			linked[checkpointID] = true
+			order = append(order, checkpointID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query checkpoint_sessions: %w", err)
+	}
+
+	var result []string
+	for _, id := range order {
+		if !kept[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// DeleteSessions deletes sessionIDs with their tool calls, turns, checkpoint
+// links and tags, then each checkpoint left with no session and its
+// files_touched rows. It returns the deleted checkpoint IDs. checkpoint_state
+// is kept, so checkpoint does not recapture the same transcripts. Rows are
+// deleted children before parents, one statement at a time, so the foreign
+// keys hold after each: DuckDB rejects deleting a referenced row in the
+// transaction that deleted the rows referencing it.
+func DeleteSessions(d *sql.DB, sessionIDs []string) ([]string, error) {
+	checkpoints, err := QueryOrphanedCheckpoints(d, sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range []string{"tool_calls", "turns", "checkpoint_sessions", "session_tags"} {
+		for _, id := range sessionIDs {
+			if _, err := d.Exec("DELETE FROM "+table+" WHERE session_id = $1", id); err != nil {
+				return nil, fmt.Errorf("delete %s of session %s: %w", table, id, err)
+			}
+		}
+	}
+	for _, id := range checkpoints {
+		if _, err := d.Exec("DELETE FROM files_touched WHERE checkpoint_id = $1", id); err != nil {
+			return nil, fmt.Errorf("delete files_touched of checkpoint %s: %w", id, err)
+		}
+	}
+	for _, id := range sessionIDs {
+		if _, err := d.Exec("DELETE FROM sessions WHERE id = $1", id); err != nil {
+			return nil, fmt.Errorf("delete session %s: %w", id, err)
+		}
+	}
+	for _, id := range checkpoints {
+		if _, err := d.Exec("DELETE FROM checkpoints WHERE id = $1", id); err != nil {
+			return nil, fmt.Errorf("delete checkpoint %s: %w", id, err)
+		}
+	}
+	return checkpoints, nil
+}
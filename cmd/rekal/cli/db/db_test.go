@@ -1,8 +1,11 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +49,142 @@ func TestOpenIndex_CreateAndPing(t *testing.T) {
 	}
 }
 
+func TestOpenDataReadOnly_RejectsWrites(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	if err := InitDataSchema(rw); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	rw.Close()
+
+	db, err := OpenDataReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenDataReadOnly: %v", err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sessions").Scan(&n); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	for _, stmt := range []string{
+		"DELETE FROM sessions",
+		"CREATE TABLE x (a INTEGER)",
+		"UPDATE checkpoint_state SET byte_size = 0",
+	} {
+		if _, err := db.Exec(stmt); err == nil {
+			t.Errorf("%s succeeded on a read-only connection", stmt)
+		}
+	}
+}
+
+func TestOpenIndexReadOnly_RejectsWrites(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	if err := InitIndexSchema(rw); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+	rw.Close()
+
+	db, err := OpenIndexReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenIndexReadOnly: %v", err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM turns_ft").Scan(&n); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM turns_ft"); err == nil {
+		t.Error("DELETE succeeded on a read-only connection")
+	}
+}
+
+func TestOpenIndexReadOnly_MigratesOldIndex(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An index from before the server column and index_state.
+	rw, err := sql.Open("duckdb", filepath.Join(dir, ".rekal", "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.Exec("CREATE TABLE tool_calls_index (id VARCHAR, session_id VARCHAR, tool VARCHAR, path VARCHAR)"); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+
+	db, err := OpenIndexReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenIndexReadOnly: %v", err)
+	}
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT count(server) FROM tool_calls_index").Scan(&n); err != nil {
+		t.Errorf("server column should be migrated: %v", err)
+	}
+	if behind, err := indexBehind(db); err != nil || behind {
+		t.Errorf("indexBehind = %v, %v after migrating; want false", behind, err)
+	}
+}
+
+func TestOpenIndexReadOnly_MarksVersion1IndexForRebuild(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	if err := InitIndexSchema(rw); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+	// A built index from before file_cooccurrence paths were relative.
+	for _, stmt := range []string{
+		"UPDATE index_state SET value = '1' WHERE key = 'schema_version'",
+		"INSERT INTO index_state (key, value) VALUES ('last_indexed_at', '2026-01-01T00:00:00Z')",
+		"INSERT INTO file_cooccurrence (file_a, file_b, count) VALUES ('/repo/a.go', '/repo/b.go', 1)",
+	} {
+		if _, err := rw.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	rw.Close()
+
+	db, err := OpenIndexReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenIndexReadOnly: %v", err)
+	}
+	defer db.Close()
+	if IsIndexPopulated(db) {
+		t.Error("a version 1 index should need a rebuild")
+	}
+}
+
 func TestInitDataSchema(t *testing.T) {
 	t.Parallel()
 
@@ -96,3 +235,502 @@ func TestInitIndexSchema(t *testing.T) {
 		t.Fatalf("InitIndexSchema: %v", err)
 	}
 }
+
+func TestOpenData_MigratesToolCalls(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A tool_calls table as created before failed/error_snippet existed.
+	old, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	if _, err := old.Exec(`CREATE TABLE tool_calls (
+		id VARCHAR PRIMARY KEY, session_id VARCHAR NOT NULL, call_order INTEGER NOT NULL,
+		tool VARCHAR NOT NULL, path VARCHAR, cmd_prefix VARCHAR)`); err != nil {
+		t.Fatalf("create old tool_calls: %v", err)
+	}
+	if _, err := old.Exec(`INSERT INTO tool_calls VALUES ('t1', 's1', 0, 'Bash', NULL, 'go test')`); err != nil {
+		t.Fatalf("insert old row: %v", err)
+	}
+	old.Close()
+
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	var failed bool
+	if err := db.QueryRow("SELECT failed FROM tool_calls WHERE id = 't1'").Scan(&failed); err != nil {
+		t.Fatalf("select failed after migration: %v", err)
+	}
+	if failed {
+		t.Error("existing rows should default to failed = false")
+	}
+	if err := InsertToolCall(db, "t2", "s1", 1, "Bash", "", "", "go vet", true, "exit status 1"); err != nil {
+		t.Fatalf("InsertToolCall after migration: %v", err)
+	}
+	if err := InsertToolCall(db, "t3", "s1", 2, "create_issue", "github", "", "", false, ""); err != nil {
+		t.Fatalf("InsertToolCall with server: %v", err)
+	}
+
+	calls, err := QueryToolCalls(db, "s1")
+	if err != nil {
+		t.Fatalf("QueryToolCalls: %v", err)
+	}
+	if len(calls) != 3 || calls[0].Server != "" || calls[2].Server != "github" {
+		t.Errorf("servers after migration: got %+v", calls)
+	}
+}
+
+func TestOpenData_MigratesSessions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sessions table as created before total_cost/total_duration_ms existed.
+	old, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	if _, err := old.Exec(`CREATE TABLE sessions (
+		id VARCHAR PRIMARY KEY, parent_session_id VARCHAR, session_hash VARCHAR NOT NULL,
+		captured_at TIMESTAMP NOT NULL, actor_type VARCHAR NOT NULL DEFAULT 'human',
+		agent_id VARCHAR, user_email VARCHAR, branch VARCHAR)`); err != nil {
+		t.Fatalf("create old sessions: %v", err)
+	}
+	if _, err := old.Exec(`INSERT INTO sessions (id, session_hash, captured_at) VALUES ('s1', 'h1', '2025-01-15 10:00:00')`); err != nil {
+		t.Fatalf("insert old row: %v", err)
+	}
+	old.Close()
+
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	s1, err := QuerySession(db, "s1")
+	if err != nil {
+		t.Fatalf("QuerySession after migration: %v", err)
+	}
+	if s1.TotalCost != 0 || s1.TotalDurationMs != 0 {
+		t.Errorf("existing rows should default to zero cost/duration, got %v/%d", s1.TotalCost, s1.TotalDurationMs)
+	}
+
+	if err := InsertSession(db, "s2", "", "h2", "human", "", "", "main", "", "2025-01-15T11:00:00Z", 0.25, 60000); err != nil {
+		t.Fatalf("InsertSession after migration: %v", err)
+	}
+	s2, err := QuerySession(db, "s2")
+	if err != nil {
+		t.Fatalf("QuerySession: %v", err)
+	}
+	if s2.TotalCost != 0.25 || s2.TotalDurationMs != 60000 {
+		t.Errorf("got cost/duration %v/%d, want 0.25/60000", s2.TotalCost, s2.TotalDurationMs)
+	}
+
+	// session_tags did not exist before; tagging works after migration.
+	if added, err := TagSession(db, "s1", "bug"); err != nil || !added {
+		t.Fatalf("TagSession after migration: added=%v err=%v", added, err)
+	}
+	if added, err := TagSession(db, "s1", "bug"); err != nil || added {
+		t.Errorf("TagSession again: added=%v err=%v, want no-op", added, err)
+	}
+	tagged, err := QuerySessionIDsByTag(db, "bug")
+	if err != nil {
+		t.Fatalf("QuerySessionIDsByTag: %v", err)
+	}
+	if len(tagged) != 1 || !tagged["s1"] {
+		t.Errorf("tagged sessions = %v, want s1", tagged)
+	}
+	if removed, err := UntagSession(db, "s1", "bug"); err != nil || !removed {
+		t.Errorf("UntagSession: removed=%v err=%v", removed, err)
+	}
+}
+
+func TestTurnExists(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer db.Close()
+	if err := InitDataSchema(db); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	for _, sid := range []string{"s1", "s2"} {
+		if err := InsertSession(db, sid, "", "hash-"+sid, "human", "", "", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("InsertSession: %v", err)
+		}
+	}
+
+	hash := TurnContentHash("human", "fix the JWT expiry bug")
+	if ok, err := TurnExists(db, hash); err != nil || ok {
+		t.Fatalf("TurnExists before insert = %v, %v; want false", ok, err)
+	}
+	// The same turn replayed by a resumed session lands in two session files.
+	if err := InsertTurn(db, "t1", "s1", 0, "human", "fix the JWT expiry bug", ""); err != nil {
+		t.Fatalf("InsertTurn: %v", err)
+	}
+	if err := InsertTurn(db, "t2", "s2", 3, "human", "fix the JWT expiry bug", ""); err != nil {
+		t.Fatalf("InsertTurn: %v", err)
+	}
+	if ok, err := TurnExists(db, hash); err != nil || !ok {
+		t.Fatalf("TurnExists after insert = %v, %v; want true", ok, err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT count(DISTINCT content_hash) FROM turns").Scan(&n); err != nil {
+		t.Fatalf("count hashes: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("identical turns should share one content hash, got %d", n)
+	}
+	if ok, _ := TurnExists(db, TurnContentHash("assistant", "fix the JWT expiry bug")); ok {
+		t.Error("the same text under another role should not match")
+	}
+}
+
+// openTestData opens a fresh data DB with the schema applied.
+func openTestData(tb testing.TB) *sql.DB {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	d, err := OpenData(dir)
+	if err != nil {
+		tb.Fatalf("OpenData: %v", err)
+	}
+	tb.Cleanup(func() { d.Close() })
+	if err := InitDataSchema(d); err != nil {
+		tb.Fatalf("InitDataSchema: %v", err)
+	}
+	return d
+}
+
+func TestBatch_CommitAndRollback(t *testing.T) {
+	t.Parallel()
+	d := openTestData(t)
+
+	insert := func(sid string) *Batch {
+		t.Helper()
+		b, err := BeginBatch(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := InsertSession(b, sid, "", "hash-"+sid, "human", "", "", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("InsertSession: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := InsertTurn(b, fmt.Sprintf("%s-t%d", sid, i), sid, i, "human", fmt.Sprintf("turn %d", i), ""); err != nil {
+				t.Fatalf("InsertTurn: %v", err)
+			}
+		}
+		return b
+	}
+
+	kept := insert("kept")
+	if err := kept.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	kept.Rollback() // no-op after Commit
+	insert("dropped").Rollback()
+
+	var sessions, turns int
+	if err := d.QueryRow("SELECT count(*), (SELECT count(*) FROM turns) FROM sessions").Scan(&sessions, &turns); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 1 || turns != 3 {
+		t.Errorf("sessions = %d, turns = %d; want only the committed batch's 1 and 3", sessions, turns)
+	}
+}
+
+// benchmarkInsertTurns inserts a 1000-turn session per iteration, through a
+// Batch or with one Exec per row.
+func benchmarkInsertTurns(b *testing.B, batched bool) {
+	d := openTestData(b)
+	content := strings.Repeat("a turn of a long session, ", 20)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sid := fmt.Sprintf("s%d", n)
+		var x Execer = d
+		var batch *Batch
+		if batched {
+			var err error
+			if batch, err = BeginBatch(d); err != nil {
+				b.Fatal(err)
+			}
+			x = batch
+		}
+		if err := InsertSession(x, sid, "", "hash-"+sid, "human", "", "", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			if err := InsertTurn(x, fmt.Sprintf("%s-%d", sid, i), sid, i, "human", content, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if batch != nil {
+			if err := batch.Commit(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkInsertTurns_PerRow(b *testing.B)  { benchmarkInsertTurns(b, false) }
+func BenchmarkInsertTurns_Batched(b *testing.B) { benchmarkInsertTurns(b, true) }
+
+func TestPruneCheckpointState(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer db.Close()
+	if err := InitDataSchema(db); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+
+	kept := filepath.Join(dir, "kept.jsonl")
+	if err := os.WriteFile(kept, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deleted := filepath.Join(dir, "deleted.jsonl")
+	// Not a path: another session source's key.
+	other := "sqlite://sessions/7"
+	for _, p := range []string{kept, deleted, other} {
+		if err := UpsertCheckpointState(db, p, 3, "hash-"+filepath.Base(p)); err != nil {
+			t.Fatalf("UpsertCheckpointState: %v", err)
+		}
+	}
+
+	n, err := PruneCheckpointState(db)
+	if err != nil {
+		t.Fatalf("PruneCheckpointState: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("pruned %d rows, want 1", n)
+	}
+	if _, _, found, err := GetCheckpointState(db, deleted); err != nil || found {
+		t.Errorf("state for deleted file: found=%v err=%v, want pruned", found, err)
+	}
+	if _, _, found, err := GetCheckpointState(db, other); err != nil || !found {
+		t.Errorf("state for a non-file key: found=%v err=%v, want kept", found, err)
+	}
+	if _, _, found, err := GetCheckpointState(db, kept); err != nil || !found {
+		t.Errorf("state for existing file: found=%v err=%v, want kept", found, err)
+	}
+
+	// The deleted file's content is no longer known under any name.
+	if ok, err := CheckpointStateHasContent(db, 3, "hash-deleted.jsonl"); err != nil || ok {
+		t.Errorf("CheckpointStateHasContent after prune = %v, %v; want false", ok, err)
+	}
+	if ok, err := CheckpointStateHasContent(db, 3, "hash-kept.jsonl"); err != nil || !ok {
+		t.Errorf("CheckpointStateHasContent = %v, %v; want true", ok, err)
+	}
+}
+
+func TestMigrate_FromVersion0(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A data DB as an early init created it: no schema_version, no
+	// session_tags or checkpoint_state, and sessions without later columns.
+	old, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	if _, err := old.Exec(`CREATE TABLE sessions (
+		id VARCHAR PRIMARY KEY, parent_session_id VARCHAR, session_hash VARCHAR NOT NULL,
+		captured_at TIMESTAMP NOT NULL, actor_type VARCHAR NOT NULL DEFAULT 'human',
+		agent_id VARCHAR, user_email VARCHAR, branch VARCHAR)`); err != nil {
+		t.Fatalf("create old sessions: %v", err)
+	}
+	if v, err := SchemaVersion(old); err != nil || v != 0 {
+		t.Fatalf("SchemaVersion before migrating = %d, %v; want 0", v, err)
+	}
+
+	if err := Migrate(old); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, err := SchemaVersion(old); err != nil || v != DataSchemaVersion {
+		t.Fatalf("SchemaVersion after Migrate = %d, %v; want %d", v, err, DataSchemaVersion)
+	}
+	for _, table := range []string{"turns", "tool_calls", "checkpoints", "checkpoint_state", "session_tags"} {
+		var n int
+		if err := old.QueryRow("SELECT count(*) FROM information_schema.tables WHERE table_name = $1", table).Scan(&n); err != nil || n != 1 {
+			t.Errorf("table %s after Migrate: count %d, err %v", table, n, err)
+		}
+	}
+	if err := InsertSession(old, "s1", "", "h1", "human", "", "", "main", "sub", "2025-01-15T11:00:00Z", 0.25, 60000); err != nil {
+		t.Fatalf("InsertSession after Migrate: %v", err)
+	}
+
+	// Migrating again is a no-op, and reopening does not re-run steps.
+	if err := Migrate(old); err != nil {
+		t.Fatalf("Migrate again: %v", err)
+	}
+	old.Close()
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	var steps int
+	if err := db.QueryRow("SELECT count(*) FROM schema_version").Scan(&steps); err != nil || steps != DataSchemaVersion {
+		t.Errorf("schema_version rows = %d, %v; want %d", steps, err, DataSchemaVersion)
+	}
+}
+
+func TestMigrate_Version3DropsSessionTagsForeignKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer db.Close()
+	if err := InitDataSchema(db); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+
+	// Roll back to version 2, when session_tags referenced sessions.
+	for _, stmt := range []string{
+		"DROP TABLE session_tags",
+		`CREATE TABLE session_tags (
+			session_id VARCHAR NOT NULL REFERENCES sessions(id), tag VARCHAR NOT NULL,
+			tagged_at TIMESTAMP NOT NULL, PRIMARY KEY (session_id, tag))`,
+		"DELETE FROM schema_version WHERE version >= 3",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := InsertSession(db, "s1", "", "h1", "human", "", "", "main", "", "2025-01-15T11:00:00Z", 0, 0); err != nil {
+		t.Fatalf("InsertSession: %v", err)
+	}
+	if _, err := TagSession(db, "s1", "bug"); err != nil {
+		t.Fatalf("TagSession: %v", err)
+	}
+	if _, err := TagSession(db, "teammate-session", "bug"); err == nil {
+		t.Fatal("version 2 session_tags should reject an unknown session")
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	ids, err := QuerySessionIDsByTag(db, "bug")
+	if err != nil || !ids["s1"] {
+		t.Errorf("tags after Migrate = %v, %v; want s1 kept", ids, err)
+	}
+	if ok, err := TagSession(db, "teammate-session", "bug"); err != nil || !ok {
+		t.Errorf("TagSession on an index-only session = %v, %v; want tagged", ok, err)
+	}
+	// sessions keeps its other references; deleting still works.
+	if _, err := DeleteSessions(db, []string{"s1"}); err != nil {
+		t.Fatalf("DeleteSessions after Migrate: %v", err)
+	}
+	var left int
+	if err := db.QueryRow("SELECT count(*) FROM sessions").Scan(&left); err != nil || left != 0 {
+		t.Errorf("sessions left = %d, %v; want 0", left, err)
+	}
+}
+
+func TestMigrate_Version4BackfillsContentHash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer db.Close()
+	if err := InitDataSchema(db); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+
+	// Roll back to version 3: no index, and a turn captured before
+	// content_hash existed.
+	if err := InsertSession(db, "s1", "", "h-s1", "human", "", "", "main", "", "2025-01-15T11:00:00Z", 0, 0); err != nil {
+		t.Fatalf("InsertSession: %v", err)
+	}
+	for _, stmt := range []string{
+		"DROP INDEX turns_content_hash",
+		"INSERT INTO turns (id, session_id, turn_index, role, content) VALUES ('t1', 's1', 0, 'human', 'fix the JWT expiry bug')",
+		"DELETE FROM schema_version WHERE version >= 4",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	hash := TurnContentHash("human", "fix the JWT expiry bug")
+	if ok, err := TurnExists(db, hash); err != nil || ok {
+		t.Fatalf("TurnExists before Migrate = %v, %v; want false", ok, err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if ok, err := TurnExists(db, hash); err != nil || !ok {
+		t.Errorf("TurnExists after Migrate = %v, %v; want the backfilled turn", ok, err)
+	}
+	var indexes int
+	if err := db.QueryRow("SELECT count(*) FROM duckdb_indexes() WHERE index_name = 'turns_content_hash'").Scan(&indexes); err != nil || indexes != 1 {
+		t.Errorf("turns_content_hash indexes = %d, %v; want 1", indexes, err)
+	}
+}
+
+func TestInitDataSchema_RecordsVersion(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer db.Close()
+
+	if v, err := SchemaVersion(db); err != nil || v != 0 {
+		t.Fatalf("SchemaVersion of an empty DB = %d, %v; want 0", v, err)
+	}
+	if err := InitDataSchema(db); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	if v, err := SchemaVersion(db); err != nil || v != DataSchemaVersion {
+		t.Errorf("SchemaVersion after InitDataSchema = %d, %v; want %d", v, err, DataSchemaVersion)
+	}
+}
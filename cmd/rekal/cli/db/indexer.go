@@ -9,6 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
 )
 
 // LoadFTSExtension loads the DuckDB FTS extension.
@@ -84,9 +87,12 @@ func ftsCacheDir() (string, error) {
 func DropIndexTables(d *sql.DB) error {
 	tables := []string{
 		"index_state",
+		"fts_stopwords",
 		"session_embeddings",
+		"lsa_model",
 		"file_cooccurrence",
 		"session_facets",
+		"file_access",
 		"files_index",
 		"tool_calls_index",
 		"turns_ft",
@@ -101,10 +107,8 @@ func DropIndexTables(d *sql.DB) error {
 
 // PopulateIndex attaches the data DB and bulk-populates all index tables.
 func PopulateIndex(d *sql.DB, gitRoot string) error {
-	dataPath := filepath.Join(gitRoot, ".rekal", "data.db")
-
-	if _, err := d.Exec(fmt.Sprintf("ATTACH '%s' AS data_db (READ_ONLY)", dataPath)); err != nil {
-		return fmt.Errorf("attach data_db: %w", err)
+	if err := AttachData(d, gitRoot); err != nil {
+		return err
 	}
 	defer d.Exec("DETACH data_db") //nolint:errcheck
 
@@ -119,8 +123,8 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 
 	// tool_calls_index
 	if _, err := d.Exec(`
-		INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix)
-		SELECT id, session_id, call_order, tool, path, cmd_prefix
+		INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix, failed, error_snippet, server)
+		SELECT id, session_id, call_order, tool, path, cmd_prefix, failed, error_snippet, server
 		FROM data_db.tool_calls
 	`); err != nil {
 		return fmt.Errorf("populate tool_calls_index: %w", err)
@@ -159,7 +163,16 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		return fmt.Errorf("populate files_index from tool_calls: %w", err)
 	}
 
-	// session_facets — aggregation
+	if err := populateFileAccess(d, gitRoot, ""); err != nil {
+		return err
+	}
+
+	// session_facets — aggregation, with emails canonicalized by .rekal/aliases
+	aliases, err := LoadEmailAliases(gitRoot)
+	if err != nil {
+		return err
+	}
+	email := canonicalEmailSQL(aliases, "s.user_email")
 	if _, err := d.Exec(`
 		INSERT INTO session_facets (
 			session_id, user_email, git_branch, actor_type, agent_id,
@@ -168,7 +181,7 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		)
 		SELECT
 			s.id,
-			s.user_email,
+			` + email + `,
 			COALESCE(c.git_branch, s.branch),
 			s.actor_type,
 			s.agent_id,
@@ -190,28 +203,90 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 	`); err != nil {
 		return fmt.Errorf("populate session_facets: %w", err)
 	}
+	return populateFileCooccurrence(d, gitRoot, "")
+}
 
-	// file_cooccurrence — self-join on tool_calls paths within same session
-	if _, err := d.Exec(`
-		INSERT INTO file_cooccurrence (file_a, file_b, count)
-		SELECT a.path, b.path, count(*) AS cnt
-		FROM data_db.tool_calls a
-		JOIN data_db.tool_calls b ON a.session_id = b.session_id AND a.path < b.path
-		WHERE a.path IS NOT NULL AND a.path != ''
-		  AND b.path IS NOT NULL AND b.path != ''
-		GROUP BY a.path, b.path
-	`); err != nil {
-		return fmt.Errorf("populate file_cooccurrence: %w", err)
+// CreateFTSIndex creates the DuckDB full-text search index on turns_ft,
+// configured to match the LSA tokenizer as closely as DuckDB allows: text is
+// lowercased without accent stripping and split on anything that is not a
+// letter or digit, and stopwords come from lsa.Stopwords via fts_stopwords.
+//
+// Two differences remain. DuckDB's Snowball stemmer is not lsa's suffix
+// stripping, so stemmed terms can differ; and FTS has no minimum token
+// length, so short tokens are indexed even though LSA drops them.
+func CreateFTSIndex(d *sql.DB, tok lsa.TokenizerConfig) error {
+	stopwords := "none"
+	if tok.Stopwords {
+		if err := createFTSStopwords(d); err != nil {
+			return err
+		}
+		stopwords = "fts_stopwords"
 	}
+	stemmer := FTSStemmer(tok)
 
+	_, err := d.Exec(fmt.Sprintf(`PRAGMA create_fts_index('turns_ft', 'id', 'content',
+		stemmer='%s', stopwords='%s', ignore='[^\p{L}\p{N}]+', strip_accents=0, lower=1, overwrite=1)`,
+		stemmer, stopwords))
+	if err != nil {
+		return fmt.Errorf("create fts index: %w", err)
+	}
 	return nil
 }
 
-// CreateFTSIndex creates the DuckDB full-text search index on turns_ft.
-func CreateFTSIndex(d *sql.DB) error {
-	_, err := d.Exec(`PRAGMA create_fts_index('turns_ft', 'id', 'content', stemmer='english', stopwords='english', overwrite=1)`)
-	if err != nil {
-		return fmt.Errorf("create fts index: %w", err)
+// ftsStateKey is the index_state key recording what the FTS index was last
+// built over: the turns_ft row count and the tokenizer.
+const ftsStateKey = "fts_state"
+
+// EnsureFTSIndex runs CreateFTSIndex unless the index was already built over
+// the current turns_ft with tok. DuckDB does not update an FTS index as rows
+// are added, and rebuilding it is the slowest part of indexing a large
+// corpus, so the row count and tokenizer are recorded in index_state and the
+// rebuild is skipped while both are unchanged. turns_ft is append-only
+// between full rebuilds, which drop index_state with it, so the count is
+// enough to tell new turns apart. It reports whether the index was rebuilt;
+// with no turns there is nothing to index and it returns false.
+func EnsureFTSIndex(d *sql.DB, tok lsa.TokenizerConfig) (bool, error) {
+	var turns int
+	if err := d.QueryRow("SELECT count(*) FROM turns_ft").Scan(&turns); err != nil {
+		return false, fmt.Errorf("count turns: %w", err)
+	}
+	if turns == 0 {
+		return false, nil
+	}
+	state := fmt.Sprintf("turns=%d,%s", turns, tok)
+	if built, err := ReadIndexState(d, ftsStateKey); err != nil {
+		return false, err
+	} else if built == state {
+		return false, nil
+	}
+	if err := CreateFTSIndex(d, tok); err != nil {
+		return false, err
+	}
+	return true, WriteIndexState(d, ftsStateKey, state)
+}
+
+// FTSStemmer returns the DuckDB stemmer CreateFTSIndex uses for tok.
+func FTSStemmer(tok lsa.TokenizerConfig) string {
+	if tok.Stem {
+		return "english"
+	}
+	return "none"
+}
+
+// createFTSStopwords (re)creates the fts_stopwords table from lsa.Stopwords.
+func createFTSStopwords(d *sql.DB) error {
+	if _, err := d.Exec(`CREATE OR REPLACE TABLE fts_stopwords (sw VARCHAR)`); err != nil {
+		return fmt.Errorf("create fts_stopwords: %w", err)
+	}
+	words := lsa.Stopwords()
+	placeholders := make([]string, len(words))
+	args := make([]interface{}, len(words))
+	for i, w := range words {
+		placeholders[i] = fmt.Sprintf("($%d)", i+1)
+		args[i] = w
+	}
+	if _, err := d.Exec("INSERT INTO fts_stopwords VALUES "+strings.Join(placeholders, ", "), args...); err != nil {
+		return fmt.Errorf("insert fts_stopwords: %w", err)
 	}
 	return nil
 }
@@ -223,6 +298,29 @@ func IsIndexPopulated(d *sql.DB) bool {
 	return err == nil && count > 0
 }
 
+// NewestIndexedCapture returns the latest captured_at among the indexed
+// sessions, or the zero time if the index has none.
+func NewestIndexedCapture(d *sql.DB) (time.Time, error) {
+	var newest sql.NullTime
+	if err := d.QueryRow("SELECT max(captured_at) FROM session_facets").Scan(&newest); err != nil {
+		return time.Time{}, fmt.Errorf("query newest indexed session: %w", err)
+	}
+	return newest.Time, nil
+}
+
+// ReadIndexState returns the value for key in index_state, or "" if unset.
+func ReadIndexState(d *sql.DB, key string) (string, error) {
+	var value string
+	err := d.QueryRow("SELECT value FROM index_state WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read index_state: %w", err)
+	}
+	return value, nil
+}
+
 // WriteIndexState writes a key-value pair to the index_state table.
 func WriteIndexState(d *sql.DB, key, value string) error {
 	_, err := d.Exec(`
@@ -252,6 +350,33 @@ func StoreEmbeddings(d *sql.DB, vectors map[string][]float64, model string) erro
 	return nil
 }
 
+// StoreLSAModel saves a serialized LSA model (lsa.Model.MarshalBinary) under
+// the given model name, replacing any previous one.
+func StoreLSAModel(d *sql.DB, model string, data []byte) error {
+	_, err := d.Exec(`
+		INSERT INTO lsa_model (model, data) VALUES ($1, $2)
+		ON CONFLICT (model) DO UPDATE SET data = $2
+	`, model, data)
+	if err != nil {
+		return fmt.Errorf("store lsa model: %w", err)
+	}
+	return nil
+}
+
+// LoadLSAModel returns the serialized LSA model stored under model, or nil if
+// there is none.
+func LoadLSAModel(d *sql.DB, model string) ([]byte, error) {
+	var data []byte
+	err := d.QueryRow("SELECT data FROM lsa_model WHERE model = $1", model).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load lsa model: %w", err)
+	}
+	return data, nil
+}
+
 // float64SliceToDuckDB serializes a float64 slice as a DuckDB list literal
 // (e.g. "[0.1, 0.2, 0.3]") because the database/sql driver does not support
 // passing Go slices for FLOAT[] columns.
@@ -268,6 +393,57 @@ func float64SliceToDuckDB(v []float64) string {
 	return b.String()
 }
 
+// QueryIndexTurns returns a session's turns from turns_ft, ordered by
+// turn_index. Unlike QueryTurns it also covers team sessions imported by
+// sync, which are only in the index.
+func QueryIndexTurns(d *sql.DB, sessionID string) ([]TurnRow, error) {
+	rows, err := d.Query(
+		`SELECT turn_index, role, content, COALESCE(ts, '')
+		 FROM turns_ft WHERE session_id = $1 ORDER BY turn_index`, sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query index turns: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var result []TurnRow
+	for rows.Next() {
+		var r TurnRow
+		if err := rows.Scan(&r.TurnIndex, &r.Role, &r.Content, &r.Ts); err != nil {
+			return nil, fmt.Errorf("scan index turn: %w", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// EmbeddingStat summarizes the stored embeddings of one model.
+type EmbeddingStat struct {
+	Model     string
+	Count     int
+	Dimension int
+}
+
+// QueryEmbeddingStats returns per-model embedding counts and dimensions,
+// ordered by model.
+func QueryEmbeddingStats(d *sql.DB) ([]EmbeddingStat, error) {
+	rows, err := d.Query("SELECT model, count(*), COALESCE(max(len(embedding)), 0) FROM session_embeddings GROUP BY model ORDER BY model")
+	if err != nil {
+		return nil, fmt.Errorf("query embedding stats: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var stats []EmbeddingStat
+	for rows.Next() {
+		var s EmbeddingStat
+		if err := rows.Scan(&s.Model, &s.Count, &s.Dimension); err != nil {
+			return nil, fmt.Errorf("scan embedding stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
 // QueryEmbeddings returns session_id → embedding vector for a given model.
 func QueryEmbeddings(d *sql.DB, model string) (map[string][]float64, error) {
 	rows, err := d.Query("SELECT session_id, embedding FROM session_embeddings WHERE model = $1", model)
@@ -316,80 +492,227 @@ func toFloat64Slice(v interface{}) ([]float64, error) {
 	}
 }
 
-// PopulateIndexIncremental adds new sessions to the index without a full rebuild.
-// sessionIDs are the newly captured sessions. checkpointID is the new checkpoint.
-func PopulateIndexIncremental(d *sql.DB, gitRoot string, sessionIDs []string, checkpointID string) error {
-	dataPath := filepath.Join(gitRoot, ".rekal", "data.db")
-
-	if _, err := d.Exec(fmt.Sprintf("ATTACH '%s' AS data_db (READ_ONLY)", dataPath)); err != nil {
-		return fmt.Errorf("attach data_db: %w", err)
+// PopulateIndexMissing adds every data DB session that has no session_facets
+// row yet, with its turns, tool calls, file access, checkpoint files, and
+// file co-occurrence counts. Sessions already indexed are left alone. It
+// returns the added session IDs in capture order. The FTS index and
+// embeddings are not touched; callers refresh them for the new sessions.
+func PopulateIndexMissing(d *sql.DB, gitRoot string) ([]string, error) {
+	if err := AttachData(d, gitRoot); err != nil {
+		return nil, err
 	}
 	defer d.Exec("DETACH data_db") //nolint:errcheck
 
+	rows, err := d.Query(`
+		SELECT id FROM data_db.sessions
+		WHERE id NOT IN (SELECT session_id FROM session_facets)
+		ORDER BY captured_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query missing sessions: %w", err)
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close() //nolint:errcheck
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query missing sessions: %w", err)
+	}
+
+	aliases, err := LoadEmailAliases(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	email := canonicalEmailSQL(aliases, "s.user_email")
 	for _, sid := range sessionIDs {
-		// turns_ft
-		if _, err := d.Exec(`
-			INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
-			SELECT id, session_id, turn_index, role, content, CAST(ts AS VARCHAR)
-			FROM data_db.turns WHERE session_id = $1
-		`, sid); err != nil {
-			return fmt.Errorf("incremental turns_ft: %w", err)
+		if err := populateIndexSession(d, gitRoot, sid, email); err != nil {
+			return nil, err
 		}
 
-		// tool_calls_index
+		// files_index for the session's checkpoints
 		if _, err := d.Exec(`
-			INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix)
-			SELECT id, session_id, call_order, tool, path, cmd_prefix
-			FROM data_db.tool_calls WHERE session_id = $1
+			INSERT INTO files_index (checkpoint_id, session_id, file_path, change_type)
+			SELECT ft.checkpoint_id, cs.session_id, ft.file_path, ft.change_type
+			FROM data_db.files_touched ft
+			JOIN data_db.checkpoint_sessions cs ON cs.checkpoint_id = ft.checkpoint_id
+			WHERE cs.session_id = $1
 		`, sid); err != nil {
-			return fmt.Errorf("incremental tool_calls_index: %w", err)
+			return nil, fmt.Errorf("incremental files_index: %w", err)
 		}
+	}
 
-		// session_facets
-		if _, err := d.Exec(`
-			INSERT INTO session_facets (
-				session_id, user_email, git_branch, actor_type, agent_id,
-				captured_at, turn_count, tool_call_count, file_count,
-				checkpoint_id, git_sha
-			)
-			SELECT
-				s.id, s.user_email,
-				COALESCE(c.git_branch, s.branch),
-				s.actor_type, s.agent_id, s.captured_at,
-				(SELECT count(*) FROM data_db.turns t WHERE t.session_id = s.id),
-				(SELECT count(*) FROM data_db.tool_calls tc WHERE tc.session_id = s.id),
-				COALESCE(fc.cnt, 0),
-				c.id, c.git_sha
-			FROM data_db.sessions s
-			LEFT JOIN data_db.checkpoint_sessions cs ON cs.session_id = s.id
-			LEFT JOIN data_db.checkpoints c ON c.id = cs.checkpoint_id
-			LEFT JOIN (
-				SELECT cs2.session_id, count(DISTINCT ft.file_path) AS cnt
-				FROM data_db.checkpoint_sessions cs2
-				JOIN data_db.files_touched ft ON ft.checkpoint_id = cs2.checkpoint_id
-				WHERE cs2.session_id = $1
-				GROUP BY cs2.session_id
-			) fc ON fc.session_id = s.id
-			WHERE s.id = $1
-		`, sid); err != nil {
-			return fmt.Errorf("incremental session_facets: %w", err)
+	if err := upsertFileCooccurrence(d, gitRoot, sessionIDs); err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+// upsertFileCooccurrence adds the file pairs of the given sessions to the
+// running file_cooccurrence counts. Pairs are only computed within each new
+// session, so the cost does not grow with the rest of the index, and the
+// totals match what PopulateIndex's full self-join produces.
+func upsertFileCooccurrence(d *sql.DB, gitRoot string, sessionIDs []string) error {
+	for _, sid := range sessionIDs {
+		if err := populateFileCooccurrence(d, gitRoot, sid); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// files_index for the new checkpoint
+// populateFileCooccurrence self-joins the tool_calls paths of each session
+// in the attached data DB and adds the pairs to file_cooccurrence. Paths are
+// made relative to gitRoot first, like files_index and file_access, so a
+// locally captured file (absolute path) and the same file imported from a
+// teammate (repo-relative path) count as one. An empty sessionID populates
+// every session.
+func populateFileCooccurrence(d *sql.DB, gitRoot, sessionID string) error {
 	if _, err := d.Exec(`
-		INSERT INTO files_index (checkpoint_id, session_id, file_path, change_type)
-		SELECT ft.checkpoint_id, cs.session_id, ft.file_path, ft.change_type
-		FROM data_db.files_touched ft
-		JOIN data_db.checkpoint_sessions cs ON cs.checkpoint_id = ft.checkpoint_id
-		WHERE ft.checkpoint_id = $1
-	`, checkpointID); err != nil {
-		return fmt.Errorf("incremental files_index: %w", err)
+		INSERT INTO file_cooccurrence (file_a, file_b, count)
+		WITH paths AS (
+			SELECT session_id, replace(path, $1, '') AS path
+			FROM data_db.tool_calls
+			WHERE path IS NOT NULL AND path != ''
+			  AND ($2 = '' OR session_id = $2)
+		)
+		SELECT a.path, b.path, count(*) AS cnt
+		FROM paths a
+		JOIN paths b ON a.session_id = b.session_id AND a.path < b.path
+		GROUP BY a.path, b.path
+		ON CONFLICT (file_a, file_b) DO UPDATE SET count = file_cooccurrence.count + EXCLUDED.count
+	`, gitRoot+"/", sessionID); err != nil {
+		return fmt.Errorf("populate file_cooccurrence: %w", err)
+	}
+	return nil
+}
+
+// populateIndexSession inserts one session's turns, tool calls, file access,
+// and facets from the attached data DB. email is the canonicalEmailSQL
+// expression for s.user_email.
+func populateIndexSession(d *sql.DB, gitRoot, sid, email string) error {
+	// turns_ft
+	if _, err := d.Exec(`
+		INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
+		SELECT id, session_id, turn_index, role, content, CAST(ts AS VARCHAR)
+		FROM data_db.turns WHERE session_id = $1
+	`, sid); err != nil {
+		return fmt.Errorf("incremental turns_ft: %w", err)
+	}
+
+	// tool_calls_index
+	if _, err := d.Exec(`
+		INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix, failed, error_snippet, server)
+		SELECT id, session_id, call_order, tool, path, cmd_prefix, failed, error_snippet, server
+		FROM data_db.tool_calls WHERE session_id = $1
+	`, sid); err != nil {
+		return fmt.Errorf("incremental tool_calls_index: %w", err)
+	}
+
+	if err := populateFileAccess(d, gitRoot, sid); err != nil {
+		return err
 	}
 
+	// session_facets
+	if _, err := d.Exec(`
+		INSERT INTO session_facets (
+			session_id, user_email, git_branch, actor_type, agent_id,
+			captured_at, turn_count, tool_call_count, file_count,
+			checkpoint_id, git_sha
+		)
+		SELECT
+			s.id, `+email+`,
+			COALESCE(c.git_branch, s.branch),
+			s.actor_type, s.agent_id, s.captured_at,
+			(SELECT count(*) FROM data_db.turns t WHERE t.session_id = s.id),
+			(SELECT count(*) FROM data_db.tool_calls tc WHERE tc.session_id = s.id),
+			COALESCE(fc.cnt, 0),
+			c.id, c.git_sha
+		FROM data_db.sessions s
+		LEFT JOIN data_db.checkpoint_sessions cs ON cs.session_id = s.id
+		LEFT JOIN data_db.checkpoints c ON c.id = cs.checkpoint_id
+		LEFT JOIN (
+			SELECT cs2.session_id, count(DISTINCT ft.file_path) AS cnt
+			FROM data_db.checkpoint_sessions cs2
+			JOIN data_db.files_touched ft ON ft.checkpoint_id = cs2.checkpoint_id
+			WHERE cs2.session_id = $1
+			GROUP BY cs2.session_id
+		) fc ON fc.session_id = s.id
+		WHERE s.id = $1
+	`, sid); err != nil {
+		return fmt.Errorf("incremental session_facets: %w", err)
+	}
 	return nil
 }
 
+// populateFileAccess records the repo files each session read, from the
+// attached data DB: every Read path, and the Grep and Glob paths that name a
+// file rather than a directory to search. Paths get the spelling checkpoint
+// stores (CanonicalToolPath, relative ones against the session's working
+// directory, or gitRoot when that is unknown, as for imported sessions) and
+// are made relative to gitRoot; paths outside it are skipped. An empty
+// sessionID populates every session.
+func populateFileAccess(d *sql.DB, gitRoot, sessionID string) error {
+	rows, err := d.Query(`
+		SELECT tc.session_id, tc.tool, tc.path, COALESCE(s.cwd, '')
+		FROM data_db.tool_calls tc
+		JOIN data_db.sessions s ON s.id = tc.session_id
+		WHERE tc.tool IN ('Read', 'Grep', 'Glob')
+		  AND tc.path IS NOT NULL AND length(tc.path) > 0
+		  AND ($1 = '' OR tc.session_id = $1)
+	`, sessionID)
+	if err != nil {
+		return fmt.Errorf("populate file_access: %w", err)
+	}
+	type access struct{ sessionID, path, tool string }
+	var order []access
+	counts := make(map[access]int)
+	for rows.Next() {
+		var sid, tool, path, cwd string
+		if err := rows.Scan(&sid, &tool, &path, &cwd); err != nil {
+			rows.Close() //nolint:errcheck
+			return fmt.Errorf("scan tool call: %w", err)
+		}
+		path = CanonicalToolPath(gitRoot, filepath.Join(gitRoot, filepath.FromSlash(cwd)), path)
+		rel, ok := RepoRelativePath(gitRoot, path)
+		if !ok || rel == "." {
+			continue
+		}
+		if tool != "Read" {
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+		}
+		a := access{sid, rel, tool}
+		if counts[a] == 0 {
+			order = append(order, a)
+		}
+		counts[a]++
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("populate file_access: %w", err)
+	}
+
+	batch, err := BeginBatch(d)
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+	for _, a := range order {
+		if _, err := batch.Exec("INSERT INTO file_access (session_id, file_path, tool, count) VALUES ($1, $2, $3, $4)", a.sessionID, a.path, a.tool, counts[a]); err != nil {
+			return fmt.Errorf("populate file_access: %w", err)
+		}
+	}
+	return batch.Commit()
+}
+
 // QuerySessionContentByIDs returns session_id → concatenated turn content for specific sessions.
 func QuerySessionContentByIDs(d *sql.DB, sessionIDs []string) (map[string]string, error) {
 	result := make(map[string]string, len(sessionIDs))
@@ -409,6 +732,59 @@ func QuerySessionContentByIDs(d *sql.DB, sessionIDs []string) (map[string]string
 	return result, nil
 }
 
+// IndexSessionExists reports whether the index has facets for the session,
+// which covers team sessions imported by sync.
+func IndexSessionExists(d Querier, id string) (bool, error) {
+	var count int
+	err := d.QueryRow("SELECT count(*) FROM session_facets WHERE session_id = $1", id).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check index session id: %w", err)
+	}
+	return count > 0, nil
+}
+
+// QueryIndexSessionIDsByCheckpointSHA returns the set of session IDs whose
+// facets name a checkpoint at a commit starting with shaPrefix. Facets keep
+// one checkpoint per session, the latest, so it complements the data DB's
+// links with team sessions imported by sync.
+func QueryIndexSessionIDsByCheckpointSHA(d *sql.DB, shaPrefix string) (map[string]bool, error) {
+	rows, err := d.Query("SELECT session_id FROM session_facets WHERE starts_with(git_sha, $1)", shaPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("query index sessions by checkpoint sha: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
+// QueryIndexedSessionIDs returns the set of session IDs with turns in turns_ft,
+// the same sessions QuerySessionContent returns.
+func QueryIndexedSessionIDs(d *sql.DB) (map[string]bool, error) {
+	rows, err := d.Query("SELECT DISTINCT session_id FROM turns_ft")
+	if err != nil {
+		return nil, fmt.Errorf("query indexed sessions: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
 // QuerySessionContent returns session_id → concatenated turn content for LSA.
 func QuerySessionContent(d *sql.DB) (map[string]string, error) {
 	rows, err := d.Query(`
@@ -431,3 +807,22 @@ func QuerySessionContent(d *sql.DB) (map[string]string, error) {
 	}
 	return result, rows.Err()
 }
+
+// QuerySessionIDsByEmail returns the set of indexed session IDs authored by email.
+func QuerySessionIDsByEmail(d *sql.DB, email string) (map[string]bool, error) {
+	rows, err := d.Query("SELECT session_id FROM session_facets WHERE user_email = $1", email)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions by email: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan session id: %w", err)
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
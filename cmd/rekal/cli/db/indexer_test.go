@@ -0,0 +1,142 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
+)
+
+// ftsTerms builds the FTS index over a single turn and returns the indexed terms.
+func ftsTerms(t *testing.T, content string, tok lsa.TokenizerConfig) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	defer d.Close()
+
+	if err := LoadFTSExtension(d); err != nil {
+		t.Skipf("fts extension unavailable: %v", err)
+	}
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+	if _, err := d.Exec(`INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
+		VALUES ('t1', 's1', 0, 'human', $1, '')`, content); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	if err := CreateFTSIndex(d, tok); err != nil {
+		t.Fatalf("CreateFTSIndex: %v", err)
+	}
+
+	rows, err := d.Query("SELECT term FROM fts_main_turns_ft.dict ORDER BY term")
+	if err != nil {
+		t.Fatalf("query fts dict: %v", err)
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			t.Fatal(err)
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+func TestCreateFTSIndex_TokenizerMatchesLSA(t *testing.T) {
+	t.Parallel()
+
+	// No single-character words: FTS has no minimum token length.
+	const sample = "The deploy script was failing on café-42 because it used the stale build_cache; rerun tests."
+
+	for _, stopwords := range []bool{true, false} {
+		tok := lsa.TokenizerConfig{Stopwords: stopwords, MinLength: 2}
+
+		seen := make(map[string]bool)
+		var want []string
+		for _, term := range tok.Tokenize(sample) {
+			if !seen[term] {
+				seen[term] = true
+				want = append(want, term)
+			}
+		}
+		sort.Strings(want)
+
+		if seen["the"] == stopwords {
+			t.Fatalf("stopwords=%v: lsa terms %v", stopwords, want)
+		}
+
+		got := ftsTerms(t, sample, tok)
+		if len(got) != len(want) {
+			t.Fatalf("stopwords=%v: fts terms %v, lsa terms %v", stopwords, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("stopwords=%v: fts terms %v, lsa terms %v", stopwords, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestEnsureFTSIndex_SkipsWhenUnchanged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	defer d.Close()
+	if err := LoadFTSExtension(d); err != nil {
+		t.Skipf("fts extension unavailable: %v", err)
+	}
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+
+	ensure := func(tok lsa.TokenizerConfig, want bool, step string) {
+		t.Helper()
+		rebuilt, err := EnsureFTSIndex(d, tok)
+		if err != nil {
+			t.Fatalf("%s: EnsureFTSIndex: %v", step, err)
+		}
+		if rebuilt != want {
+			t.Errorf("%s: rebuilt = %v, want %v", step, rebuilt, want)
+		}
+	}
+	insertTurn := func(id, content string) {
+		t.Helper()
+		if _, err := d.Exec(`INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
+			VALUES ($1, 's1', 0, 'human', $2, '')`, id, content); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+
+	ensure(lsa.DefaultTokenizer, false, "no turns")
+	insertTurn("t1", "deploy script")
+	ensure(lsa.DefaultTokenizer, true, "first build")
+	ensure(lsa.DefaultTokenizer, false, "unchanged")
+	insertTurn("t2", "zeppelin landing")
+	ensure(lsa.DefaultTokenizer, true, "new turn")
+	ensure(lsa.DefaultTokenizer, false, "unchanged again")
+	ensure(lsa.TokenizerConfig{MinLength: 1}, true, "other tokenizer")
+
+	var n int
+	if err := d.QueryRow("SELECT count(*) FROM fts_main_turns_ft.dict WHERE term = 'zeppelin'").Scan(&n); err != nil || n != 1 {
+		t.Errorf("new turn not in fts dict: n=%d err=%v", n, err)
+	}
+}
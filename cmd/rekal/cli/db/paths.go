@@ -0,0 +1,66 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RepoRelativeDir returns a session's working directory relative to the
+// git root, in slash form ("." for the root itself). It returns "" when cwd
+// is empty or outside the repo.
+func RepoRelativeDir(gitRoot, cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	rel := func(p string) (string, bool) {
+		r, err := filepath.Rel(gitRoot, p)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		return filepath.ToSlash(r), true
+	}
+	if r, ok := rel(cwd); ok {
+		return r
+	}
+	// git reports the root with symlinks resolved; the transcript may not.
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		if r, ok := rel(resolved); ok {
+			return r
+		}
+	}
+	return ""
+}
+
+// CanonicalToolPath returns the one spelling a tool call path is stored
+// under. Relative paths are resolved against cwd, the session's absolute
+// working directory, and "." and ".." are collapsed. A path into the repo
+// through a symlink is rewritten under gitRoot; paths outside the repo are
+// only cleaned. An empty path stays empty.
+func CanonicalToolPath(gitRoot, cwd, p string) string {
+	if p == "" {
+		return ""
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(cwd, p)
+	}
+	p = filepath.Clean(p)
+	if rel := RepoRelativeDir(gitRoot, p); rel != "" {
+		return filepath.Join(gitRoot, filepath.FromSlash(rel))
+	}
+	// A deleted file can't be resolved; its directory may still be.
+	if rel := RepoRelativeDir(gitRoot, filepath.Dir(p)); rel != "" {
+		return filepath.Join(gitRoot, filepath.FromSlash(rel), filepath.Base(p))
+	}
+	return p
+}
+
+// RepoRelativePath returns a canonical path (see CanonicalToolPath)
+// relative to gitRoot in slash form, the form files_touched uses. ok is
+// false for paths outside the repo; the root itself is ".".
+func RepoRelativePath(gitRoot, p string) (rel string, ok bool) {
+	if p == gitRoot {
+		return ".", true
+	}
+	rel, ok = strings.CutPrefix(p, gitRoot+string(filepath.Separator))
+	return filepath.ToSlash(rel), ok
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoRelativeDir(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	tests := []struct {
+		cwd, want string
+	}{
+		{root, "."},
+		{filepath.Join(root, "services", "api"), "services/api"},
+		{filepath.Dir(root), ""},
+		{filepath.Join(filepath.Dir(root), "elsewhere"), ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RepoRelativeDir(root, tt.cwd); got != tt.want {
+			t.Errorf("RepoRelativeDir(%q) = %q, want %q", tt.cwd, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalToolPath(t *testing.T) {
+	t.Parallel()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "a.go"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+
+	a := filepath.Join(root, "src", "a.go")
+	tests := []struct {
+		cwd, path, want string
+	}{
+		{root, "./src/a.go", a},
+		{root, "src/a.go", a},
+		{root, a, a},
+		{filepath.Join(root, "src"), "../src/./a.go", a},
+		{root, filepath.Join(link, "src", "a.go"), a},
+		{root, filepath.Join(link, "src", "deleted.go"), filepath.Join(root, "src", "deleted.go")},
+		{root, "/etc//hosts", "/etc/hosts"},
+		{root, "", ""},
+	}
+	for _, tt := range tests {
+		if got := CanonicalToolPath(root, tt.cwd, tt.path); got != tt.want {
+			t.Errorf("CanonicalToolPath(%q, %q) = %q, want %q", tt.cwd, tt.path, got, tt.want)
+		}
+	}
+}
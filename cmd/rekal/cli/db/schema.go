@@ -1,22 +1,284 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
-// InitDataSchema creates the data DB tables if they do not exist.
-// Data DB is the source of truth — append-only, never rebuilt.
+// InitDataSchema creates the data DB tables if they do not exist and
+// records the schema version. Data DB is the source of truth — append-only,
+// never rebuilt.
 func InitDataSchema(d *sql.DB) error {
-	_, err := d.Exec(dataDDL)
-	return err
+	if _, err := d.Exec(dataDDL); err != nil {
+		return err
+	}
+	return Migrate(d)
 }
 
-// InitIndexSchema creates the index DB tables if they do not exist.
-// Index DB is derived — can be dropped and rebuilt from data DB.
+// InitIndexSchema creates the index DB tables if they do not exist and
+// records IndexSchemaVersion. Index DB is derived — can be dropped and
+// rebuilt from data DB.
 func InitIndexSchema(d *sql.DB) error {
-	if indexDDL == "" {
+	if _, err := d.Exec(indexDDL); err != nil {
+		return err
+	}
+	return WriteIndexState(d, indexSchemaKey, strconv.Itoa(IndexSchemaVersion))
+}
+
+// migration alters a table created by an older version of the DDL. Each
+// statement must be idempotent: migrations run on every open.
+type migration struct {
+	table string
+	stmt  string
+}
+
+// dataMigrations bring data DBs created before schema_version existed up to
+// dataDDL. They are schema version 1.
+var dataMigrations = []migration{
+	{"sessions", "ALTER TABLE sessions ADD COLUMN IF NOT EXISTS total_cost DOUBLE DEFAULT 0"},
+	{"sessions", "ALTER TABLE sessions ADD COLUMN IF NOT EXISTS total_duration_ms BIGINT DEFAULT 0"},
+	{"sessions", "ALTER TABLE sessions ADD COLUMN IF NOT EXISTS cwd VARCHAR"},
+	{"sessions", "ALTER TABLE sessions ADD COLUMN IF NOT EXISTS source_session_id VARCHAR"},
+	{"turns", "ALTER TABLE turns ADD COLUMN IF NOT EXISTS content_hash VARCHAR"},
+	{"tool_calls", "ALTER TABLE tool_calls ADD COLUMN IF NOT EXISTS failed BOOLEAN DEFAULT FALSE"},
+	{"tool_calls", "ALTER TABLE tool_calls ADD COLUMN IF NOT EXISTS error_snippet VARCHAR"},
+	{"tool_calls", "ALTER TABLE tool_calls ADD COLUMN IF NOT EXISTS server VARCHAR"},
+	{"sessions", sessionTagsDDL},
+}
+
+// IndexSchemaVersion is the index DB schema version this build writes,
+// recorded in index_state under indexSchemaKey. indexMigrations bring an
+// index without it up to version 1. Version 2 stores file_cooccurrence
+// paths relative to the git root; an older index is marked unbuilt, so the
+// next read rebuilds it instead of missing its absolute paths.
+const IndexSchemaVersion = 2
+
+const indexSchemaKey = "schema_version"
+
+// indexMigrations bring existing index DBs up to indexDDL, so incremental
+// updates work before the next full rebuild.
+var indexMigrations = []migration{
+	{"tool_calls_index", "ALTER TABLE tool_calls_index ADD COLUMN IF NOT EXISTS failed BOOLEAN DEFAULT FALSE"},
+	{"tool_calls_index", "ALTER TABLE tool_calls_index ADD COLUMN IF NOT EXISTS error_snippet VARCHAR"},
+	{"tool_calls_index", "ALTER TABLE tool_calls_index ADD COLUMN IF NOT EXISTS server VARCHAR"},
+}
+
+// schemaStep upgrades the data DB to version from the version before it.
+// Steps should be idempotent, so one interrupted before its version was
+// recorded can run again.
+type schemaStep struct {
+	version int
+	apply   func(d *sql.DB) error
+}
+
+// dataSchemaSteps are the data DB upgrades in order. To change the schema,
+// update dataDDL for new databases and append a step that brings existing
+// ones to the same shape.
+var dataSchemaSteps = []schemaStep{
+	{1, func(d *sql.DB) error {
+		if err := migrate(d, dataMigrations); err != nil {
+			return err
+		}
+		_, err := d.Exec(dataDDL)
+		return err
+	}},
+	{2, func(d *sql.DB) error {
+		_, err := d.Exec("ALTER TABLE files_touched ADD COLUMN IF NOT EXISTS diff VARCHAR")
+		return err
+	}},
+	{3, dropSessionTagsForeignKey},
+	{4, func(d *sql.DB) error {
+		// The hash is SHA-256 over role, a NUL and content, as
+		// TurnContentHash computes it. DuckDB can't update an indexed
+		// column, so the index comes after the backfill.
+		if _, err := d.Exec("UPDATE turns SET content_hash = sha256(role || chr(0) || content) WHERE content_hash IS NULL"); err != nil {
+			return fmt.Errorf("backfill turn content hashes: %w", err)
+		}
+		_, err := d.Exec(turnsContentHashIndexDDL)
+		return err
+	}},
+}
+
+// turnsContentHashIndexDDL indexes turns by content hash for TurnExists.
+// Version 4 creates it, for new databases too: dataDDL runs before the
+// steps, and an indexed column could not be backfilled.
+const turnsContentHashIndexDDL = "CREATE INDEX IF NOT EXISTS turns_content_hash ON turns (content_hash)"
+
+// dropSessionTagsForeignKey rebuilds session_tags without its reference to
+// sessions, so a teammate's session that is only in the index can be
+// tagged. DuckDB can't drop a constraint, so the table is copied in one
+// transaction.
+func dropSessionTagsForeignKey(d *sql.DB) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS session_tags_v3",
+		strings.Replace(sessionTagsDDL, "session_tags", "session_tags_v3", 1),
+		"INSERT INTO session_tags_v3 SELECT session_id, tag, tagged_at FROM session_tags",
+		"DROP TABLE session_tags",
+		"ALTER TABLE session_tags_v3 RENAME TO session_tags",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DataSchemaVersion is the data DB schema version this build writes.
+var DataSchemaVersion = dataSchemaSteps[len(dataSchemaSteps)-1].version
+
+// Migrate brings the data DB up to DataSchemaVersion, applying each step
+// newer than the version recorded in schema_version (0 when the table does
+// not exist) and recording it. OpenData calls it, so every command —
+// init and checkpoint included — sees the current schema. A database with
+// no tables yet is left alone; InitDataSchema creates it at the current
+// version.
+func Migrate(d *sql.DB) error {
+	var tables int
+	if err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.tables WHERE table_schema = 'main' AND table_name != 'schema_version'",
+	).Scan(&tables); err != nil {
+		return fmt.Errorf("check tables: %w", err)
+	}
+	if tables == 0 {
 		return nil
 	}
-	_, err := d.Exec(indexDDL)
-	return err
+
+	if _, err := d.Exec(schemaVersionDDL); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+	current, err := SchemaVersion(d)
+	if err != nil {
+		return err
+	}
+	for _, step := range dataSchemaSteps {
+		if step.version <= current {
+			continue
+		}
+		if err := step.apply(d); err != nil {
+			return fmt.Errorf("schema version %d: %w", step.version, err)
+		}
+		if _, err := d.Exec("INSERT INTO schema_version (version, applied_at) VALUES ($1, current_timestamp)", step.version); err != nil {
+			return fmt.Errorf("record schema version %d: %w", step.version, err)
+		}
+	}
+	return nil
+}
+
+// dataBehind reports whether the data DB has tables at a schema version
+// older than DataSchemaVersion, so Migrate would change it.
+func dataBehind(d *sql.DB) (bool, error) {
+	var tables int
+	if err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.tables WHERE table_schema = 'main' AND table_name != 'schema_version'",
+	).Scan(&tables); err != nil {
+		return false, fmt.Errorf("check tables: %w", err)
+	}
+	if tables == 0 {
+		return false, nil
+	}
+	version, err := SchemaVersion(d)
+	if err != nil {
+		return false, err
+	}
+	return version < DataSchemaVersion, nil
+}
+
+// MigrateIndex brings an index DB behind IndexSchemaVersion up to date:
+// it applies indexMigrations, creates tables added since, and records the
+// version. An index DB with no tables yet is left alone.
+func MigrateIndex(d *sql.DB) error {
+	version, empty, err := indexVersion(d)
+	if err != nil || empty || version >= IndexSchemaVersion {
+		return err
+	}
+	if version < 1 {
+		if err := migrate(d, indexMigrations); err != nil {
+			return err
+		}
+	}
+	if err := InitIndexSchema(d); err != nil {
+		return err
+	}
+	if version < 2 {
+		if _, err := d.Exec("DELETE FROM index_state WHERE key = 'last_indexed_at'"); err != nil {
+			return fmt.Errorf("mark index for rebuild: %w", err)
+		}
+	}
+	return nil
+}
+
+// indexBehind reports whether the index DB has tables but records a schema
+// version older than IndexSchemaVersion, or none.
+func indexBehind(d *sql.DB) (bool, error) {
+	version, empty, err := indexVersion(d)
+	return !empty && version < IndexSchemaVersion, err
+}
+
+// indexVersion returns the schema version the index DB records, 0 when it
+// predates versioning, and whether it has no tables at all.
+func indexVersion(d *sql.DB) (version int, empty bool, err error) {
+	var tables, state int
+	if err := d.QueryRow(`
+		SELECT count(*), count(*) FILTER (WHERE table_name = 'index_state')
+		FROM information_schema.tables WHERE table_schema = 'main'
+	`).Scan(&tables, &state); err != nil {
+		return 0, false, fmt.Errorf("check tables: %w", err)
+	}
+	if tables == 0 || state == 0 {
+		return 0, tables == 0, nil
+	}
+	recorded, err := ReadIndexState(d, indexSchemaKey)
+	if err != nil {
+		return 0, false, err
+	}
+	version, _ = strconv.Atoi(recorded)
+	return version, false, nil
+}
+
+// SchemaVersion returns the highest version recorded in schema_version, or
+// 0 for a database that predates it.
+func SchemaVersion(d *sql.DB) (int, error) {
+	var n int
+	if err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.tables WHERE table_schema = 'main' AND table_name = 'schema_version'",
+	).Scan(&n); err != nil {
+		return 0, fmt.Errorf("check schema_version: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	var version int
+	if err := d.QueryRow("SELECT coalesce(max(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema_version: %w", err)
+	}
+	return version, nil
+}
+
+// migrate applies migrations whose table exists. Tables that don't exist yet
+// are created with the current columns by the DDL.
+func migrate(d *sql.DB, migrations []migration) error {
+	for _, m := range migrations {
+		var n int
+		if err := d.QueryRow(
+			"SELECT count(*) FROM information_schema.tables WHERE table_schema = 'main' AND table_name = $1", m.table,
+		).Scan(&n); err != nil {
+			return fmt.Errorf("check table %s: %w", m.table, err)
+		}
+		if n == 0 {
+			continue
+		}
+		if _, err := d.Exec(m.stmt); err != nil {
+			return fmt.Errorf("migrate %s: %w", m.table, err)
+		}
+	}
+	return nil
 }
 
 const dataDDL = `
@@ -28,7 +290,11 @@ CREATE TABLE IF NOT EXISTS sessions (
 	actor_type        VARCHAR NOT NULL DEFAULT 'human',
 	agent_id          VARCHAR,
 	user_email        VARCHAR,
-	branch            VARCHAR
+	branch            VARCHAR,
+	total_cost        DOUBLE DEFAULT 0,
+	total_duration_ms BIGINT DEFAULT 0,
+	cwd               VARCHAR,
+	source_session_id VARCHAR
 );
 
 CREATE TABLE IF NOT EXISTS turns (
@@ -37,7 +303,8 @@ CREATE TABLE IF NOT EXISTS turns (
 	turn_index      INTEGER NOT NULL,
 	role            VARCHAR NOT NULL,
 	content         VARCHAR NOT NULL,
-	ts              TIMESTAMP
+	ts              TIMESTAMP,
+	content_hash    VARCHAR
 );
 
 CREATE TABLE IF NOT EXISTS tool_calls (
@@ -46,7 +313,10 @@ CREATE TABLE IF NOT EXISTS tool_calls (
 	call_order      INTEGER NOT NULL,
 	tool            VARCHAR NOT NULL,
 	path            VARCHAR,
-	cmd_prefix      VARCHAR
+	cmd_prefix      VARCHAR,
+	failed          BOOLEAN DEFAULT FALSE,
+	error_snippet   VARCHAR,
+	server          VARCHAR
 );
 
 CREATE TABLE IF NOT EXISTS checkpoints (
@@ -64,7 +334,8 @@ CREATE TABLE IF NOT EXISTS files_touched (
 	id              VARCHAR PRIMARY KEY,
 	checkpoint_id   VARCHAR NOT NULL REFERENCES checkpoints(id),
 	file_path       VARCHAR NOT NULL,
-	change_type     VARCHAR NOT NULL
+	change_type     VARCHAR NOT NULL,
+	diff            VARCHAR
 );
 
 CREATE TABLE IF NOT EXISTS checkpoint_sessions (
@@ -78,6 +349,26 @@ CREATE TABLE IF NOT EXISTS checkpoint_state (
 	byte_size   BIGINT NOT NULL,
 	file_hash   VARCHAR NOT NULL
 );
+` + schemaVersionDDL + sessionTagsDDL
+
+// schemaVersionDDL records each data DB schema version applied by Migrate.
+const schemaVersionDDL = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version     INTEGER PRIMARY KEY,
+	applied_at  TIMESTAMP NOT NULL
+);
+`
+
+// sessionTagsDDL is local-only: tags added with `rekal tag` are never
+// exported to the wire format. session_id has no foreign key: a tagged
+// session may be a teammate's, known only to the index.
+const sessionTagsDDL = `
+CREATE TABLE IF NOT EXISTS session_tags (
+	session_id  VARCHAR NOT NULL,
+	tag         VARCHAR NOT NULL,
+	tagged_at   TIMESTAMP NOT NULL,
+	PRIMARY KEY (session_id, tag)
+);
 `
 
 // Index DDL defines the derived index tables — rebuilt from data DB.
@@ -97,7 +388,10 @@ CREATE TABLE IF NOT EXISTS tool_calls_index (
 	call_order      INTEGER NOT NULL,
 	tool            VARCHAR NOT NULL,
 	path            VARCHAR,
-	cmd_prefix      VARCHAR
+	cmd_prefix      VARCHAR,
+	failed          BOOLEAN DEFAULT FALSE,
+	error_snippet   VARCHAR,
+	server          VARCHAR
 );
 CREATE INDEX IF NOT EXISTS idx_tci_tool ON tool_calls_index(tool);
 CREATE INDEX IF NOT EXISTS idx_tci_path ON tool_calls_index(path);
@@ -112,6 +406,15 @@ CREATE TABLE IF NOT EXISTS files_index (
 CREATE INDEX IF NOT EXISTS idx_fi_path ON files_index(file_path);
 CREATE INDEX IF NOT EXISTS idx_fi_session ON files_index(session_id);
 
+CREATE TABLE IF NOT EXISTS file_access (
+	session_id      VARCHAR NOT NULL,
+	file_path       VARCHAR NOT NULL,
+	tool            VARCHAR NOT NULL,
+	count           INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_fa_path ON file_access(file_path);
+CREATE INDEX IF NOT EXISTS idx_fa_session ON file_access(session_id);
+
 CREATE TABLE IF NOT EXISTS session_facets (
 	session_id      VARCHAR PRIMARY KEY,
 	user_email      VARCHAR,
@@ -145,6 +448,11 @@ CREATE TABLE IF NOT EXISTS session_embeddings (
 	PRIMARY KEY (session_id, model)
 );
 
+CREATE TABLE IF NOT EXISTS lsa_model (
+	model           VARCHAR PRIMARY KEY,
+	data            BLOB NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS index_state (
 	key             VARCHAR PRIMARY KEY,
 	value           VARCHAR NOT NULL
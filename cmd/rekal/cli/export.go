@@ -1,36 +1,100 @@
 package cli
 
 import (
+	"database/sql"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
 )
 
-// exportNewFrames reads existing wire format from the orphan branch, appends
-// frames for any unexported checkpoints from DuckDB, and returns the updated
-// body + dict. Returns (nil, nil, nil) if there are no unexported checkpoints.
-func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
+// defaultShardSize is the rekal.body shard size at which push starts a new
+// shard. Override with git config rekal.shardSize (bytes).
+const defaultShardSize = 8 << 20
+
+// defaultDictMaxPaths caps the dict.bin paths namespace, which holds tool
+// call and files touched paths and MCP tool names; past it they are sent
+// inline. Override with git config rekal.dictMaxPaths.
+const defaultDictMaxPaths = 16384
+
+// shardSizeLimit returns the shard roll-over size in bytes.
+func shardSizeLimit() int {
+	if v := gitConfigValue("rekal.shardSize"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultShardSize
+}
+
+// dictPathLimit returns the cap on the dict.bin paths namespace.
+func dictPathLimit() int {
+	if v := gitConfigValue("rekal.dictMaxPaths"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultDictMaxPaths
+}
+
+// loadManifest reads the body shard manifest from ref. A ref without one
+// has a single shard, rekal.body.
+func loadManifest(gitRoot, ref string) (*codec.Manifest, error) {
+	data := gitShowFile(gitRoot, ref, codec.ManifestFile)
+	if len(data) == 0 {
+		return codec.NewManifest(), nil
+	}
+	return codec.LoadManifest(data)
+}
+
+// parseTurnTs parses a turn timestamp as returned by db.QueryTurns, which
+// casts the TIMESTAMP column to DuckDB's "2006-01-02 15:04:05" text form.
+// RFC3339 is accepted too. Unparseable values return the zero time.
+func parseTurnTs(s string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339} {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
+
+// exportNewFrames reads the active body shard and dict from the orphan
+// branch, appends frames for any unexported checkpoints from DuckDB, and
+// returns the manifest, the updated shard, the dict, and the branch's
+// zstd.dicts (nil if unchanged). A full shard is left as-is and the frames go
+// to a new one. Frames are compressed with the dictionary from 'rekal codec
+// train-dict' if there is one, which is then added to zstd.dicts. Returns
+// nil if there are no unexported checkpoints.
+func exportNewFrames(gitRoot string) (*codec.Manifest, []byte, []byte, []byte, error) {
 	dataDB, err := db.OpenData(gitRoot)
 	if err != nil {
-		return nil, nil, fmt.Errorf("open data DB: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("open data DB: %w", err)
 	}
 	defer dataDB.Close()
 
 	checkpoints, err := db.QueryUnexportedCheckpoints(dataDB)
 	if err != nil {
-		return nil, nil, fmt.Errorf("query unexported checkpoints: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("query unexported checkpoints: %w", err)
 	}
 	if len(checkpoints) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil, nil
 	}
 
 	// Load existing wire format from orphan branch.
 	branch := rekalBranchName()
-	bodyData := gitShowFile(gitRoot, branch, "rekal.body")
+	manifest, err := loadManifest(gitRoot, branch)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("load manifest: %w", err)
+	}
+	bodyData := gitShowFile(gitRoot, branch, codec.ShardFile(manifest.Active()))
 	dictData := gitShowFile(gitRoot, branch, "dict.bin")
 
 	dict := codec.NewDict()
@@ -40,112 +104,62 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 			dict = loaded
 		}
 	}
+	dict.SetLimit(codec.NSPaths, dictPathLimit())
 	body := bodyData
 	if len(body) == 0 {
 		body = codec.NewBody()
 	}
+	if len(body) > 9 && len(body) >= shardSizeLimit() {
+		manifest.Shards++
+		body = codec.NewBody()
+	}
 
-	enc, err := codec.NewEncoder()
+	trained, err := loadTrainedDict(gitRoot)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create encoder: %w", err)
+		return nil, nil, nil, nil, err
+	}
+	var enc *codec.Encoder
+	var trainedDicts []byte
+	if trained == nil {
+		if enc, err = codec.GetEncoder(); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("create encoder: %w", err)
+		}
+		defer codec.PutEncoder(enc)
+	} else {
+		if enc, err = codec.NewEncoderDict(trained); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("create encoder: %w", err)
+		}
+		defer enc.Close()
+		trainedDicts, err = codec.AppendTrainedDict(gitShowFile(gitRoot, branch, codec.TrainedDictsFile), trained)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("add trained dict: %w", err)
+		}
 	}
-	defer enc.Close()
-
-	var exportedIDs []string
 
+	// Read everything first, so the paths namespace admits the paths this
+	// export references most before any frame claims a slot.
+	pending := make([]*exportCheckpoint, 0, len(checkpoints))
+	refs := make(map[string]int)
 	for _, cp := range checkpoints {
-		// Query sessions linked to this checkpoint.
-		sessionIDs, err := db.QuerySessionsByCheckpoint(dataDB, cp.ID)
+		ec, err := loadExportCheckpoint(dataDB, cp)
 		if err != nil {
-			return nil, nil, fmt.Errorf("query sessions for checkpoint %s: %w", cp.ID, err)
+			return nil, nil, nil, nil, err
 		}
+		ec.countPaths(gitRoot, refs)
+		pending = append(pending, ec)
+	}
+	dict.Admit(codec.NSPaths, refs)
 
-		var sessionRefs []uint64
-
-		for _, sid := range sessionIDs {
-			sess, err := db.QuerySession(dataDB, sid)
-			if err != nil {
-				return nil, nil, fmt.Errorf("query session %s: %w", sid, err)
-			}
-			turns, err := db.QueryTurns(dataDB, sid)
-			if err != nil {
-				return nil, nil, fmt.Errorf("query turns for %s: %w", sid, err)
-			}
-			toolCalls, err := db.QueryToolCalls(dataDB, sid)
-			if err != nil {
-				return nil, nil, fmt.Errorf("query tool_calls for %s: %w", sid, err)
-			}
-
-			sessRef := dict.LookupOrAdd(codec.NSSessions, sid)
-			emailRef := dict.LookupOrAdd(codec.NSEmails, sess.Email)
-			branchRef := uint64(0)
-			if sess.Branch != "" {
-				branchRef = dict.LookupOrAdd(codec.NSBranches, sess.Branch)
-			}
-
-			actorType := codec.ActorHuman
-			agentIDRef := uint64(0)
-			if sess.ActorType == "agent" {
-				actorType = codec.ActorAgent
-				if sess.AgentID != "" {
-					agentIDRef = dict.LookupOrAdd(codec.NSEmails, sess.AgentID)
-				}
-			}
-
-			capturedAt, _ := time.Parse(time.RFC3339, sess.CapturedAt)
-			sf := &codec.SessionFrame{
-				SessionRef: sessRef,
-				CapturedAt: capturedAt,
-				EmailRef:   emailRef,
-				ActorType:  actorType,
-				AgentIDRef: agentIDRef,
-			}
-
-			// Build turn records with delta timestamps.
-			var prevTs time.Time
-			for _, t := range turns {
-				role := codec.RoleHuman
-				if t.Role == "assistant" {
-					role = codec.RoleAssistant
-				}
-				var tsDelta uint64
-				if t.Ts != "" {
-					ts, _ := time.Parse(time.RFC3339, t.Ts)
-					if !prevTs.IsZero() && !ts.IsZero() {
-						delta := ts.Sub(prevTs)
-						if delta > 0 {
-							tsDelta = uint64(delta.Seconds())
-						}
-					}
-					prevTs = ts
-				}
-				sf.Turns = append(sf.Turns, codec.TurnRecord{
-					Role:      role,
-					TsDelta:   tsDelta,
-					BranchRef: branchRef,
-					Text:      t.Content,
-				})
-			}
+	var exportedIDs []string
 
-			// Build tool call records.
-			for _, tc := range toolCalls {
-				toolCode := codec.ToolCode(tc.Tool)
-				tcr := codec.ToolCallRecord{
-					Tool: toolCode,
-				}
-				if tc.Path == "" {
-					tcr.PathFlag = codec.PathNull
-				} else {
-					pathRef := dict.LookupOrAdd(codec.NSPaths, tc.Path)
-					tcr.PathFlag = codec.PathDictRef
-					tcr.PathRef = pathRef
-				}
-				tcr.CmdPrefix = tc.CmdPrefix
-				sf.ToolCalls = append(sf.ToolCalls, tcr)
-			}
+	for _, ec := range pending {
+		cp := ec.cp
+		var sessionRefs []uint64
 
+		for _, es := range ec.sessions {
+			sf := sessionFrame(gitRoot, dict, es)
 			body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
-			sessionRefs = append(sessionRefs, sessRef)
+			sessionRefs = append(sessionRefs, sf.SessionRef)
 		}
 
 		// Build checkpoint frame.
@@ -164,22 +178,25 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 			}
 		}
 
-		// Query files touched.
-		filesTouched, err := db.QueryFilesTouched(dataDB, cp.ID)
-		if err != nil {
-			return nil, nil, fmt.Errorf("query files_touched for %s: %w", cp.ID, err)
-		}
 		var fileRecords []codec.FileTouchedRecord
-		for _, ft := range filesTouched {
-			pathRef := dict.LookupOrAdd(codec.NSPaths, ft.Path)
+		for _, ft := range ec.files {
 			changeType := byte('M')
 			if len(ft.ChangeType) > 0 {
 				changeType = ft.ChangeType[0]
 			}
-			fileRecords = append(fileRecords, codec.FileTouchedRecord{
-				PathRef:    pathRef,
+			// Hunks are sent inline: they are rarely repeated, and in the
+			// dict they would crowd out paths.
+			rec := codec.FileTouchedRecord{
 				ChangeType: changeType,
-			})
+				Hunks:      splitHunks(ec.diffs[ft.Path]),
+			}
+			if pathRef, ok := dict.Intern(codec.NSPaths, ft.Path); ok {
+				rec.PathRef = pathRef
+			} else {
+				rec.PathFlag = codec.PathInline
+				rec.PathInline = ft.Path
+			}
+			fileRecords = append(fileRecords, rec)
 		}
 
 		cf := &codec.CheckpointFrame{
@@ -219,15 +236,199 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 
 	// Mark checkpoints as exported.
 	if err := db.MarkCheckpointsExported(dataDB, exportedIDs); err != nil {
-		return nil, nil, fmt.Errorf("mark exported: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("mark exported: %w", err)
 	}
 
-	return body, dict.Encode(), nil
+	return manifest, body, dict.Encode(), trainedDicts, nil
+}
+
+// exportCheckpoint is a checkpoint's rows, read before any of its refs are
+// interned.
+type exportCheckpoint struct {
+	cp       db.CheckpointRow
+	sessions []*exportSession
+	files    []struct{ Path, ChangeType string }
+	diffs    map[string]string
+}
+
+// exportSession is a session's rows, read before any of its refs are
+// interned.
+type exportSession struct {
+	id        string
+	sess      *db.SessionRow
+	turns     []db.TurnRow
+	toolCalls []db.ToolCallRow
 }
 
-// commitWireFormat commits rekal.body and dict.bin to the orphan branch.
-// Returns the new commit SHA.
-func commitWireFormat(gitRoot string, bodyData, dictData []byte) (string, error) {
+// loadExportCheckpoint reads cp's sessions, files touched and diffs.
+func loadExportCheckpoint(dataDB *sql.DB, cp db.CheckpointRow) (*exportCheckpoint, error) {
+	sessionIDs, err := db.QuerySessionsByCheckpoint(dataDB, cp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions for checkpoint %s: %w", cp.ID, err)
+	}
+	ec := &exportCheckpoint{cp: cp}
+	for _, sid := range sessionIDs {
+		es, err := loadExportSession(dataDB, sid)
+		if err != nil {
+			return nil, err
+		}
+		ec.sessions = append(ec.sessions, es)
+	}
+	if ec.files, err = db.QueryFilesTouched(dataDB, cp.ID); err != nil {
+		return nil, fmt.Errorf("query files_touched for %s: %w", cp.ID, err)
+	}
+	if ec.diffs, err = db.QueryFileDiffs(dataDB, cp.ID); err != nil {
+		return nil, fmt.Errorf("query file diffs for %s: %w", cp.ID, err)
+	}
+	return ec, nil
+}
+
+// countPaths adds one to refs for each NSPaths value ec's frames refer to.
+func (ec *exportCheckpoint) countPaths(gitRoot string, refs map[string]int) {
+	for _, es := range ec.sessions {
+		for _, tc := range es.toolCalls {
+			if tc.Server != "" {
+				refs[session.MCPToolName(tc.Server, tc.Tool)]++
+			}
+			if path := es.wirePath(gitRoot, tc.Path); path != "" {
+				refs[path]++
+			}
+		}
+	}
+	for _, ft := range ec.files {
+		refs[ft.Path]++
+	}
+}
+
+// loadExportSession reads session sid with its turns and tool calls.
+func loadExportSession(dataDB *sql.DB, sid string) (*exportSession, error) {
+	sess, err := db.QuerySession(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query session %s: %w", sid, err)
+	}
+	turns, err := db.QueryTurns(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query turns for %s: %w", sid, err)
+	}
+	toolCalls, err := db.QueryToolCalls(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query tool_calls for %s: %w", sid, err)
+	}
+	return &exportSession{id: sid, sess: sess, turns: turns, toolCalls: toolCalls}, nil
+}
+
+// wirePath returns a tool call path as it is sent. Sessions captured before
+// paths were canonicalized may still spell one file several ways. Repo
+// paths are sent relative to the root, as files_touched paths are, so a
+// file has one NSPaths entry.
+func (es *exportSession) wirePath(gitRoot, path string) string {
+	sessionCWD := filepath.Join(gitRoot, filepath.FromSlash(es.sess.CWD))
+	path = db.CanonicalToolPath(gitRoot, sessionCWD, path)
+	if rel, ok := db.RepoRelativePath(gitRoot, path); ok {
+		path = rel
+	}
+	return path
+}
+
+// buildSessionFrame builds the session frame for sid from the data DB,
+// adding its IDs, emails, branch and paths to dict.
+func buildSessionFrame(dataDB *sql.DB, gitRoot string, dict *codec.Dict, sid string) (*codec.SessionFrame, error) {
+	es, err := loadExportSession(dataDB, sid)
+	if err != nil {
+		return nil, err
+	}
+	return sessionFrame(gitRoot, dict, es), nil
+}
+
+// sessionFrame builds the session frame for es, adding its IDs, emails,
+// branch and paths to dict.
+func sessionFrame(gitRoot string, dict *codec.Dict, es *exportSession) *codec.SessionFrame {
+	sess := es.sess
+	sessRef := dict.LookupOrAdd(codec.NSSessions, es.id)
+	emailRef := dict.LookupOrAdd(codec.NSEmails, sess.Email)
+	branchRef := uint64(0)
+	if sess.Branch != "" {
+		branchRef = dict.LookupOrAdd(codec.NSBranches, sess.Branch)
+	}
+
+	actorType := codec.ActorHuman
+	agentIDRef := uint64(0)
+	if sess.ActorType == "agent" {
+		actorType = codec.ActorAgent
+		if sess.AgentID != "" {
+			agentIDRef = dict.LookupOrAdd(codec.NSEmails, sess.AgentID)
+		}
+	}
+
+	capturedAt, _ := time.Parse(time.RFC3339, sess.CapturedAt)
+	sf := &codec.SessionFrame{
+		SessionRef: sessRef,
+		CapturedAt: capturedAt,
+		EmailRef:   emailRef,
+		ActorType:  actorType,
+		AgentIDRef: agentIDRef,
+	}
+
+	// Build turn records with delta timestamps. Deltas are signed, so a
+	// turn stamped earlier than the one before it (clock skew,
+	// out-of-order writes) keeps its negative delta instead of
+	// collapsing into a simultaneous 0.
+	var prevTs time.Time
+	for _, t := range es.turns {
+		role := codec.RoleCode(t.Role)
+		var tsDelta int64
+		if t.Ts != "" {
+			ts := parseTurnTs(t.Ts)
+			if !prevTs.IsZero() && !ts.IsZero() {
+				tsDelta = int64(ts.Sub(prevTs) / time.Second)
+			}
+			prevTs = ts
+		}
+		sf.Turns = append(sf.Turns, codec.TurnRecord{
+			Role:      role,
+			TsDelta:   tsDelta,
+			BranchRef: branchRef,
+			Text:      t.Content,
+		})
+	}
+
+	// Build tool call records.
+	for _, tc := range es.toolCalls {
+		tcr := codec.ToolCallRecord{
+			Tool: codec.ToolCode(tc.Tool),
+		}
+		if tc.Server != "" {
+			tcr.Tool = codec.ToolMCP
+			name := session.MCPToolName(tc.Server, tc.Tool)
+			if nameRef, ok := dict.Intern(codec.NSPaths, name); ok {
+				tcr.NameRef = nameRef
+			} else {
+				tcr.NameFlag = codec.PathInline
+				tcr.NameInline = name
+			}
+		}
+		// Near the paths cap, paths seen once are sent inline.
+		path := es.wirePath(gitRoot, tc.Path)
+		if path == "" {
+			tcr.PathFlag = codec.PathNull
+		} else if pathRef, ok := dict.Intern(codec.NSPaths, path); ok {
+			tcr.PathFlag = codec.PathDictRef
+			tcr.PathRef = pathRef
+		} else {
+			tcr.PathFlag = codec.PathInline
+			tcr.PathInline = path
+		}
+		tcr.CmdPrefix = tc.CmdPrefix
+		sf.ToolCalls = append(sf.ToolCalls, tcr)
+	}
+	return sf
+}
+
+// commitWireFormat commits the active body shard, dict.bin, zstd.dicts when
+// trainedDicts is not nil and, once the body is sharded, rekal.manifest to
+// the orphan branch. Earlier shards are carried over from the parent tree
+// unchanged. Returns the new commit SHA.
+func commitWireFormat(gitRoot string, manifest *codec.Manifest, bodyData, dictData, trainedDicts []byte) (string, error) {
 	branch := rekalBranchName()
 
 	// Get the current tip of the orphan branch.
@@ -235,20 +436,63 @@ func commitWireFormat(gitRoot string, bodyData, dictData []byte) (string, error)
 	if err != nil {
 		return "", fmt.Errorf("resolve branch %s: %w", branch, err)
 	}
-	parent := strings.TrimSpace(string(parentOut))
+	return commitWireTree(gitRoot, []string{strings.TrimSpace(string(parentOut))}, manifest, bodyData, dictData, trainedDicts)
+}
+
+// commitWireTree is commitWireFormat with explicit parents. The tree starts
+// from the first parent's, and the rekal branch is moved to the new commit.
+func commitWireTree(gitRoot string, parents []string, manifest *codec.Manifest, bodyData, dictData, trainedDicts []byte) (string, error) {
+	branch := rekalBranchName()
 
+	// Start from the parent tree so earlier shards are kept.
+	entries, err := lsTree(gitRoot, parents[0])
+	if err != nil {
+		return "", err
+	}
+	blobs := make(map[string]string, len(entries)+2)
+	for name, e := range entries {
+		if e.Type == "blob" {
+			blobs[name] = e.Hash
+		}
+	}
+
+	shardFile := codec.ShardFile(manifest.Active())
 	bodyHash, err := gitHashObject(gitRoot, bodyData)
 	if err != nil {
-		return "", fmt.Errorf("hash rekal.body: %w", err)
+		return "", fmt.Errorf("hash %s: %w", shardFile, err)
 	}
+	blobs[shardFile] = bodyHash
 	dictHash, err := gitHashObject(gitRoot, dictData)
 	if err != nil {
 		return "", fmt.Errorf("hash dict.bin: %w", err)
 	}
+	blobs["dict.bin"] = dictHash
+	if trainedDicts != nil {
+		dictsHash, err := gitHashObject(gitRoot, trainedDicts)
+		if err != nil {
+			return "", fmt.Errorf("hash %s: %w", codec.TrainedDictsFile, err)
+		}
+		blobs[codec.TrainedDictsFile] = dictsHash
+	}
+	if manifest.Shards > 1 {
+		manifestHash, err := gitHashObject(gitRoot, manifest.Encode())
+		if err != nil {
+			return "", fmt.Errorf("hash %s: %w", codec.ManifestFile, err)
+		}
+		blobs[codec.ManifestFile] = manifestHash
+	}
 
-	treeEntry := fmt.Sprintf("100644 blob %s\tdict.bin\n100644 blob %s\trekal.body\n", dictHash, bodyHash)
+	names := make([]string, 0, len(blobs))
+	for name := range blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var treeEntry strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&treeEntry, "%s blob %s\t%s\n", wireFileMode, blobs[name], name)
+	}
 	mktreeCmd := exec.Command("git", "-C", gitRoot, "mktree")
-	mktreeCmd.Stdin = strings.NewReader(treeEntry)
+	mktreeCmd.Stdin = strings.NewReader(treeEntry.String())
 	treeOut, err := mktreeCmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("mktree: %w", err)
@@ -263,9 +507,11 @@ func commitWireFormat(gitRoot string, bodyData, dictData []byte) (string, error)
 		}
 	}
 
-	commitOut, err := exec.Command("git", "-C", gitRoot,
-		"commit-tree", treeHash, "-p", parent, "-m", msg,
-	).Output()
+	args := []string{"-C", gitRoot, "commit-tree", treeHash}
+	for _, p := range parents {
+		args = append(args, "-p", p)
+	}
+	commitOut, err := exec.Command("git", append(args, "-m", msg)...).Output()
 	if err != nil {
 		return "", fmt.Errorf("commit-tree: %w", err)
 	}
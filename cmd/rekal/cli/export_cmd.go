@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+// exportFilters selects which sessions `rekal export` writes.
+type exportFilters struct {
+	Author string
+	Actor  string
+	Since  time.Time
+	Until  time.Time
+}
+
+// exportedSession is one session in `rekal export` output: the full
+// conversation plus the checkpoints it was captured in.
+type exportedSession struct {
+	SessionID       string               `json:"session_id"`
+	ParentSessionID string               `json:"parent_session_id,omitempty"`
+	Author          string               `json:"author"`
+	Actor           string               `json:"actor"`
+	AgentID         string               `json:"agent_id,omitempty"`
+	Branch          string               `json:"branch"`
+	CapturedAt      string               `json:"captured_at"`
+	TotalCost       float64              `json:"total_cost,omitempty"`
+	DurationMs      int64                `json:"total_duration_ms,omitempty"`
+	Turns           []turnOutput         `json:"turns"`
+	ToolCalls       []exportedToolCall   `json:"tool_calls"`
+	Checkpoints     []exportedCheckpoint `json:"checkpoints"`
+}
+
+// exportedToolCall is a tool call as query --session --full shows it, plus
+// the command prefix.
+type exportedToolCall struct {
+	Order     int    `json:"order"`
+	Tool      string `json:"tool"`
+	Server    string `json:"server,omitempty"`
+	Path      string `json:"path,omitempty"`
+	CmdPrefix string `json:"cmd_prefix,omitempty"`
+	Failed    bool   `json:"failed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type exportedCheckpoint struct {
+	CheckpointID string         `json:"checkpoint_id"`
+	GitSHA       string         `json:"git_sha"`
+	Ts           string         `json:"ts"`
+	Files        []exportedFile `json:"files"`
+}
+
+type exportedFile struct {
+	Path       string `json:"path"`
+	ChangeType string `json:"change_type"`
+}
+
+func newExportCmd() *cobra.Command {
+	var (
+		format, out, author, actor, since, until string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump sessions from the data DB as JSON or JSONL",
+		Long: `Dump sessions from the data DB as full JSON objects: turns, tool calls,
+and the checkpoints (commit and files) each session was captured in.
+
+--format json (default) writes one JSON array; --format jsonl writes one
+session per line. Output goes to stdout unless --out is set.
+
+Sessions are ordered by captured_at and can be filtered with --author,
+--actor, --since and --until, which work as they do for recall.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			if format != "json" && format != "jsonl" {
+				return fmt.Errorf("--format must be json or jsonl, got %q", format)
+			}
+			filters := exportFilters{Author: author, Actor: actor}
+			now := time.Now()
+			if since != "" {
+				if filters.Since, err = parseTimeBound(since, now); err != nil {
+					return fmt.Errorf("--since: %w", err)
+				}
+			}
+			if until != "" {
+				if filters.Until, err = parseTimeBound(until, now); err != nil {
+					return fmt.Errorf("--until: %w", err)
+				}
+			}
+
+			if out == "" {
+				_, err := runExport(gitRoot, cmd.OutOrStdout(), format, filters)
+				return err
+			}
+
+			// Export to a temp file beside --out and rename it over on
+			// success, so a failed export leaves an existing file as it was.
+			f, err := os.CreateTemp(filepath.Dir(out), ".rekal-export-*")
+			if err != nil {
+				return fmt.Errorf("create %s: %w", out, err)
+			}
+			defer func() { _ = os.Remove(f.Name()) }()
+
+			n, err := runExport(gitRoot, f, format, filters)
+			if err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Chmod(0o644); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if err := os.Rename(f.Name(), out); err != nil {
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "rekal: exported %d session(s) to %s\n", n, out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "json", "Output format: json (array) or jsonl (one session per line)")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "Write to this file instead of stdout")
+	cmd.Flags().StringVar(&author, "author", "", "Filter by author email")
+	cmd.Flags().StringVar(&actor, "actor", "", "Filter by actor type (human|agent)")
+	cmd.Flags().StringVar(&since, "since", "", "Only sessions captured at or after this time (RFC3339 or relative, e.g. 7d, 24h)")
+	cmd.Flags().StringVar(&until, "until", "", "Only sessions captured at or before this time (RFC3339 or relative, e.g. 7d, 24h)")
+
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"json", "jsonl"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("author", completeFromData("sessions", "user_email"))
+	_ = cmd.RegisterFlagCompletionFunc("actor", cobra.FixedCompletions([]string{"human", "agent"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+// runExport writes the matching sessions to w and returns how many it wrote.
+// Sessions are loaded and written one at a time so memory stays flat.
+func runExport(gitRoot string, w io.Writer, format string, filters exportFilters) (int, error) {
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		return 0, fmt.Errorf("open data db: %w", err)
+	}
+	defer dataDB.Close()
+
+	ids, err := queryExportSessionIDs(dataDB, filters)
+	if err != nil {
+		return 0, fmt.Errorf("query sessions: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if format == "json" {
+		bw.WriteString("[") //nolint:errcheck
+	}
+	for i, id := range ids {
+		s, err := loadExportedSession(dataDB, id)
+		if err != nil {
+			return i, err
+		}
+		var data []byte
+		if format == "json" {
+			if i > 0 {
+				bw.WriteString(",") //nolint:errcheck
+			}
+			bw.WriteString("\n  ") //nolint:errcheck
+			data, err = json.MarshalIndent(s, "  ", "  ")
+		} else {
+			data, err = json.Marshal(s)
+		}
+		if err != nil {
+			return i, fmt.Errorf("marshal session %s: %w", id, err)
+		}
+		bw.Write(data) //nolint:errcheck
+		if format == "jsonl" {
+			bw.WriteString("\n") //nolint:errcheck
+		}
+	}
+	if format == "json" {
+		if len(ids) > 0 {
+			bw.WriteString("\n") //nolint:errcheck
+		}
+		bw.WriteString("]\n") //nolint:errcheck
+	}
+	if err := bw.Flush(); err != nil {
+		return len(ids), fmt.Errorf("write: %w", err)
+	}
+	return len(ids), nil
+}
+
+// queryExportSessionIDs returns the IDs of sessions matching filters, oldest first.
+func queryExportSessionIDs(dataDB *sql.DB, filters exportFilters) ([]string, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if filters.Author != "" {
+		add("user_email = $%d", filters.Author)
+	}
+	if filters.Actor != "" {
+		add("actor_type = $%d", filters.Actor)
+	}
+	if !filters.Since.IsZero() {
+		add("captured_at >= $%d", filters.Since.UTC())
+	}
+	if !filters.Until.IsZero() {
+		add("captured_at <= $%d", filters.Until.UTC())
+	}
+
+	q := "SELECT id FROM sessions"
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	q += " ORDER BY captured_at, id"
+
+	rows, err := dataDB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// loadExportedSession assembles one session with its turns, tool calls, and checkpoints.
+func loadExportedSession(dataDB *sql.DB, id string) (*exportedSession, error) {
+	sess, err := db.QuerySession(dataDB, id)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", id, err)
+	}
+	out := &exportedSession{
+		SessionID:       sess.ID,
+		ParentSessionID: sess.ParentSessionID,
+		Author:          sess.Email,
+		Actor:           sess.ActorType,
+		AgentID:         sess.AgentID,
+		Branch:          sess.Branch,
+		CapturedAt:      sess.CapturedAt,
+		TotalCost:       sess.TotalCost,
+		DurationMs:      sess.TotalDurationMs,
+		Turns:           []turnOutput{},
+		ToolCalls:       []exportedToolCall{},
+		Checkpoints:     []exportedCheckpoint{},
+	}
+
+	turns, err := db.QueryTurns(dataDB, id)
+	if err != nil {
+		return nil, fmt.Errorf("turns for %s: %w", id, err)
+	}
+	for _, t := range turns {
+		out.Turns = append(out.Turns, turnOutput{
+			Index:   t.TurnIndex,
+			Role:    t.Role,
+			Content: t.Content,
+			Ts:      t.Ts,
+		})
+	}
+
+	toolCalls, err := db.QueryToolCalls(dataDB, id)
+	if err != nil {
+		return nil, fmt.Errorf("tool calls for %s: %w", id, err)
+	}
+	for _, tc := range toolCalls {
+		out.ToolCalls = append(out.ToolCalls, exportedToolCall{
+			Order:     tc.CallOrder,
+			Tool:      tc.Tool,
+			Server:    tc.Server,
+			Path:      tc.Path,
+			CmdPrefix: tc.CmdPrefix,
+			Failed:    tc.Failed,
+			Error:     tc.ErrorSnippet,
+		})
+	}
+
+	rows, err := dataDB.Query(`
+		SELECT c.id, c.git_sha, CAST(c.ts AS VARCHAR)
+		FROM checkpoint_sessions cs
+		JOIN checkpoints c ON c.id = cs.checkpoint_id
+		WHERE cs.session_id = $1
+		ORDER BY c.ts, c.id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints for %s: %w", id, err)
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var cp exportedCheckpoint
+		if err := rows.Scan(&cp.CheckpointID, &cp.GitSHA, &cp.Ts); err != nil {
+			return nil, fmt.Errorf("scan checkpoint: %w", err)
+		}
+		out.Checkpoints = append(out.Checkpoints, cp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("checkpoints for %s: %w", id, err)
+	}
+
+	for i := range out.Checkpoints {
+		files, err := db.QueryFilesTouched(dataDB, out.Checkpoints[i].CheckpointID)
+		if err != nil {
+			return nil, fmt.Errorf("files for %s: %w", out.Checkpoints[i].CheckpointID, err)
+		}
+		out.Checkpoints[i].Files = []exportedFile{}
+		for _, f := range files {
+			out.Checkpoints[i].Files = append(out.Checkpoints[i].Files, exportedFile{Path: f.Path, ChangeType: f.ChangeType})
+		}
+	}
+
+	return out, nil
+}
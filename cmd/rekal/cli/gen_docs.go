@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+func newGenDocsCmd() *cobra.Command {
+	var format, out string
+	cmd := &cobra.Command{
+		Use:   "gen-docs",
+		Short: "Generate man pages or Markdown for every command",
+		Long: `Generate reference docs for rekal and every subcommand, one file per
+command, with all flags documented.
+
+--format man (default) writes section 1 man pages (rekal.1, rekal-init.1, ...);
+--format markdown writes rekal.md, rekal_init.md, ... The --out directory is
+created if needed. Meant for packagers.`,
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		// Usually run from a packaging script; skip the update check.
+		PersistentPreRun:  func(*cobra.Command, []string) {},
+		PersistentPostRun: func(*cobra.Command, []string) {},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			if format != "man" && format != "markdown" {
+				return fmt.Errorf("--format must be man or markdown, got %q", format)
+			}
+			if out == "" {
+				return fmt.Errorf("--out is required")
+			}
+			if err := os.MkdirAll(out, 0o755); err != nil {
+				return fmt.Errorf("create %s: %w", out, err)
+			}
+
+			root := cmd.Root()
+			// Keep output reproducible across builds.
+			root.DisableAutoGenTag = true
+
+			if format == "man" {
+				header := &doc.GenManHeader{
+					Title:   "REKAL",
+					Section: "1",
+					Source:  "rekal " + Version,
+					Manual:  "Rekal Manual",
+				}
+				if err := doc.GenManTree(root, header, out); err != nil {
+					return fmt.Errorf("generate man pages: %w", err)
+				}
+			} else if err := doc.GenMarkdownTree(root, out); err != nil {
+				return fmt.Errorf("generate markdown: %w", err)
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "rekal: wrote %s docs to %s\n", format, out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "man", "Output format: man or markdown")
+	cmd.Flags().StringVar(&out, "out", "", "Directory to write the files to")
+
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"man", "markdown"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.MarkFlagDirname("out")
+
+	return cmd
+}
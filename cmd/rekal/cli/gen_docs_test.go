@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// documentedCommands returns the command paths gen-docs should emit a file for.
+func documentedCommands(c *cobra.Command) []string {
+	paths := []string{c.CommandPath()}
+	for _, sub := range c.Commands() {
+		if sub.IsAvailableCommand() && !sub.IsAdditionalHelpTopicCommand() {
+			paths = append(paths, documentedCommands(sub)...)
+		}
+	}
+	return paths
+}
+
+func TestGenDocsCmd(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format string
+		sep    string
+		ext    string
+	}{
+		{"man", "-", ".1"},
+		{"markdown", "_", ".md"},
+	}
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "docs")
+		root := NewRootCmd()
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs([]string{"gen-docs", "--format", tt.format, "--out", dir})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("gen-docs --format %s: %v", tt.format, err)
+		}
+
+		var want []string
+		for _, path := range documentedCommands(root) {
+			want = append(want, strings.ReplaceAll(path, " ", tt.sep)+tt.ext)
+		}
+		sort.Strings(want)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("read %s: %v", dir, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name())
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s files:\n got %v\nwant %v", tt.format, got, want)
+		}
+		for _, name := range got {
+			if strings.Contains(name, "gen"+tt.sep+"docs") {
+				t.Errorf("%s: hidden gen-docs command was documented", tt.format)
+			}
+		}
+
+		// Flags are documented in each command's file.
+		data, err := os.ReadFile(filepath.Join(dir, "rekal"+tt.sep+"export"+tt.ext))
+		if err != nil {
+			t.Fatalf("read export doc: %v", err)
+		}
+		for _, flag := range []string{"format", "out", "since", "until"} {
+			if !strings.Contains(string(data), flag) {
+				t.Errorf("%s export doc missing --%s", tt.format, flag)
+			}
+		}
+	}
+}
+
+func TestGenDocsCmd_InvalidArgs(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{
+		{"gen-docs", "--format", "html", "--out", t.TempDir()},
+		{"gen-docs"},
+	} {
+		root := NewRootCmd()
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs(args)
+		if err := root.Execute(); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+}
@@ -4,42 +4,59 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
 )
 
 // importBranch decodes wire format from an orphan branch and imports
 // sessions + checkpoints into DuckDB. Returns the number of sessions imported.
-// Deduplicates by session ID and checkpoint ID.
+// Deduplicates by session ID and checkpoint ID and, with git config
+// rekal.contentIds, leaves out the turns a resumed session replays, as
+// checkpoint does.
 func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
-	bodyData := gitShowFile(gitRoot, branch, "rekal.body")
-	if len(bodyData) <= 9 {
-		return 0, nil // empty body (header only)
+	if err := validateBranchTree(gitRoot, branch); err != nil {
+		return 0, err
 	}
 
-	dictData := gitShowFile(gitRoot, branch, "dict.bin")
-	if len(dictData) == 0 {
-		return 0, nil
+	manifest, err := loadManifest(gitRoot, branch)
+	if err != nil {
+		return 0, fmt.Errorf("load manifest: %w", err)
 	}
 
-	dict, err := codec.LoadDict(dictData)
+	dict, err := codec.LoadDict(gitShowFile(gitRoot, branch, "dict.bin"))
 	if err != nil {
 		return 0, fmt.Errorf("load dict: %w", err)
 	}
 
-	frames, err := codec.ScanFrames(bodyData)
+	dec, release, err := branchDecoder(gitRoot, branch)
 	if err != nil {
-		return 0, fmt.Errorf("scan frames: %w", err)
+		return 0, err
 	}
-
-	dec, err := codec.NewDecoder()
-	if err != nil {
-		return 0, fmt.Errorf("create decoder: %w", err)
+	defer release()
+
+	// Decode every shard in order. Malformed frames and frames with unknown
+	// refs are skipped.
+	var (
+		sessions    []*codec.SessionFrame
+		checkpoints []*codec.CheckpointFrame
+	)
+	for _, file := range manifest.Files() {
+		bodyData := gitShowFile(gitRoot, branch, file)
+		if len(bodyData) <= 9 {
+			continue // empty shard (header only)
+		}
+		shard, err := codec.DecodeBodyWith(dec, bodyData, dict)
+		if err != nil {
+			return 0, fmt.Errorf("decode %s: %w", file, err)
+		}
+		sessions = append(sessions, shard.Sessions...)
+		checkpoints = append(checkpoints, shard.Checkpoints...)
 	}
-	defer dec.Close()
 
 	entropy := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
 	newID := func() string {
@@ -47,146 +64,185 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 	}
 
 	var imported int
+	skipReplayed := contentIDsConfig()
 
-	for _, fs := range frames {
-		compressed := codec.ExtractFramePayload(bodyData, fs)
+	// Sessions first, so checkpoint_sessions can link to them.
+	for _, sf := range sessions {
+		sessionID, _ := dict.Get(codec.NSSessions, sf.SessionRef)
 
-		switch fs.Type {
-		case codec.FrameSession:
-			sf, err := dec.DecodeSessionFrame(compressed)
-			if err != nil {
-				continue // skip malformed frames
-			}
+		// Dedup by session ID.
+		exists, err := db.SessionExistsByID(dataDB, sessionID)
+		if err != nil {
+			return imported, fmt.Errorf("check session: %w", err)
+		}
+		if exists {
+			continue
+		}
 
-			sessionID, err := dict.Get(codec.NSSessions, sf.SessionRef)
-			if err != nil {
-				continue
-			}
+		// One transaction per session.
+		batch, err := db.BeginBatch(dataDB)
+		if err != nil {
+			return imported, err
+		}
+		if err := insertWireSession(batch, dict, sf, sessionID, newID, skipReplayed); err != nil {
+			batch.Rollback()
+			return imported, err
+		}
+		if err := batch.Commit(); err != nil {
+			return imported, err
+		}
 
-			// Dedup by session ID.
-			exists, err := db.SessionExistsByID(dataDB, sessionID)
-			if err != nil {
-				return imported, fmt.Errorf("check session: %w", err)
-			}
-			if exists {
-				continue
-			}
+		imported++
+	}
 
-			email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
-			actorType := "human"
-			agentID := ""
-			if sf.ActorType == codec.ActorAgent {
-				actorType = "agent"
-				agentID, _ = dict.Get(codec.NSEmails, sf.AgentIDRef)
-			}
+	for _, cf := range checkpoints {
+		checkpointID, _ := dict.Get(codec.NSSessions, cf.CheckpointRef)
 
-			// Determine branch from first turn's BranchRef (all turns share the same branch).
-			branch := ""
-			if len(sf.Turns) > 0 {
-				branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
-			}
+		// Dedup by checkpoint ID.
+		exists, err := db.CheckpointExists(dataDB, checkpointID)
+		if err != nil {
+			return imported, fmt.Errorf("check checkpoint: %w", err)
+		}
+		if exists {
+			continue
+		}
 
-			sessionHash := "wire:" + sessionID
-			capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
+		branchName, _ := dict.Get(codec.NSBranches, cf.BranchRef)
+		email, _ := dict.Get(codec.NSEmails, cf.EmailRef)
+		actorType := "human"
+		agentID := ""
+		if cf.ActorType == codec.ActorAgent {
+			actorType = "agent"
+			agentID, _ = dict.Get(codec.NSEmails, cf.AgentIDRef)
+		}
 
-			if err := db.InsertSession(dataDB, sessionID, "", sessionHash, actorType, agentID, email, branch, capturedAt); err != nil {
-				return imported, fmt.Errorf("insert session: %w", err)
-			}
+		ts := cf.Timestamp.UTC().Format(time.RFC3339)
+		if err := db.InsertCheckpoint(dataDB, checkpointID, cf.GitSHA, branchName, email, ts, actorType, agentID); err != nil {
+			return imported, fmt.Errorf("insert checkpoint: %w", err)
+		}
 
-			// Insert turns.
-			for i, t := range sf.Turns {
-				role := "human"
-				if t.Role == codec.RoleAssistant {
-					role = "assistant"
-				}
-				if err := db.InsertTurn(dataDB, newID(), sessionID, i, role, t.Text, ""); err != nil {
-					return imported, fmt.Errorf("insert turn: %w", err)
-				}
+		// Insert files_touched.
+		for _, f := range cf.Files {
+			filePath, _ := f.Path(dict)
+			changeType := string(f.ChangeType)
+			var diff strings.Builder
+			for _, hunk := range f.Hunks {
+				diff.WriteString(hunk)
 			}
-
-			// Insert tool calls.
-			for i, tc := range sf.ToolCalls {
-				toolName := codec.ToolName(tc.Tool)
-				path := ""
-				switch tc.PathFlag {
-				case codec.PathDictRef:
-					path, _ = dict.Get(codec.NSPaths, tc.PathRef)
-				case codec.PathInline:
-					path = tc.PathInline
-				}
-				if err := db.InsertToolCall(dataDB, newID(), sessionID, i, toolName, path, tc.CmdPrefix); err != nil {
-					return imported, fmt.Errorf("insert tool_call: %w", err)
-				}
+			// Version 0x02 frames refer to their hunks in the dict.
+			for _, ref := range f.HunkRefs {
+				hunk, _ := dict.Get(codec.NSPaths, ref)
+				diff.WriteString(hunk)
 			}
-
-			imported++
-
-		case codec.FrameCheckpoint:
-			cf, err := dec.DecodeCheckpointFrame(compressed)
-			if err != nil {
-				continue
+			if err := db.InsertFileTouchedDiff(dataDB, newID(), checkpointID, filePath, changeType, diff.String()); err != nil {
+				return imported, fmt.Errorf("insert file_touched: %w", err)
 			}
+		}
 
-			checkpointID, err := dict.Get(codec.NSSessions, cf.CheckpointRef)
+		// Insert checkpoint_sessions junction rows.
+		for _, ref := range cf.SessionRefs {
+			sessionID, err := dict.Get(codec.NSSessions, ref)
 			if err != nil {
 				continue
 			}
-
-			// Dedup by checkpoint ID.
-			exists, err := db.CheckpointExists(dataDB, checkpointID)
-			if err != nil {
-				return imported, fmt.Errorf("check checkpoint: %w", err)
-			}
+			// Only link if the session exists in DB.
+			exists, _ := db.SessionExistsByID(dataDB, sessionID)
 			if exists {
-				continue
+				if err := db.InsertCheckpointSession(dataDB, checkpointID, sessionID); err != nil {
+					return imported, fmt.Errorf("insert checkpoint_session: %w", err)
+				}
 			}
+		}
 
-			branchName, _ := dict.Get(codec.NSBranches, cf.BranchRef)
-			email, _ := dict.Get(codec.NSEmails, cf.EmailRef)
-			actorType := "human"
-			agentID := ""
-			if cf.ActorType == codec.ActorAgent {
-				actorType = "agent"
-				agentID, _ = dict.Get(codec.NSEmails, cf.AgentIDRef)
-			}
+		// Mark as exported since it came from wire format.
+		_ = db.MarkCheckpointsExported(dataDB, []string{checkpointID})
+	}
 
-			ts := cf.Timestamp.UTC().Format(time.RFC3339)
-			if err := db.InsertCheckpoint(dataDB, checkpointID, cf.GitSHA, branchName, email, ts, actorType, agentID); err != nil {
-				return imported, fmt.Errorf("insert checkpoint: %w", err)
-			}
+	return imported, nil
+}
 
-			// Insert files_touched.
-			for _, f := range cf.Files {
-				filePath, _ := dict.Get(codec.NSPaths, f.PathRef)
-				changeType := string(f.ChangeType)
-				if err := db.InsertFileTouched(dataDB, newID(), checkpointID, filePath, changeType); err != nil {
-					return imported, fmt.Errorf("insert file_touched: %w", err)
-				}
-			}
+// wireTurnTimestamps rebuilds each turn's RFC3339 timestamp from the
+// frame's CapturedAt plus the running sum of turn deltas. A negative delta
+// marks a turn stamped earlier than the one before it (clock skew), so its
+// timestamp is "" (NULL); later turns keep counting from it.
+func wireTurnTimestamps(sf *codec.SessionFrame) []string {
+	stamps := make([]string, len(sf.Turns))
+	ts := sf.CapturedAt.UTC()
+	for i, t := range sf.Turns {
+		ts = ts.Add(time.Duration(t.TsDelta) * time.Second)
+		if t.TsDelta >= 0 {
+			stamps[i] = ts.Format(time.RFC3339)
+		}
+	}
+	return stamps
+}
 
-			// Insert checkpoint_sessions junction rows.
-			for _, ref := range cf.SessionRefs {
-				sessionID, err := dict.Get(codec.NSSessions, ref)
-				if err != nil {
-					continue
-				}
-				// Only link if the session exists in DB.
-				exists, _ := db.SessionExistsByID(dataDB, sessionID)
-				if exists {
-					if err := db.InsertCheckpointSession(dataDB, checkpointID, sessionID); err != nil {
-						return imported, fmt.Errorf("insert checkpoint_session: %w", err)
-					}
-				}
-			}
+// insertWireSession writes a decoded session frame's session row, turns and
+// tool calls through x, resolving its refs against dict.
+func insertWireSession(x db.Querier, dict *codec.Dict, sf *codec.SessionFrame, sessionID string, newID func() string, skipReplayed bool) error {
+	email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
+	actorType := "human"
+	agentID := ""
+	if sf.ActorType == codec.ActorAgent {
+		actorType = "agent"
+		agentID, _ = dict.Get(codec.NSEmails, sf.AgentIDRef)
+	}
 
-			// Mark as exported since it came from wire format.
-			_ = db.MarkCheckpointsExported(dataDB, []string{checkpointID})
+	// Determine branch from first turn's BranchRef (all turns share the same branch).
+	branch := ""
+	if len(sf.Turns) > 0 {
+		branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
+	}
 
-		case codec.FrameMeta:
-			// Skip meta frames during import.
-			continue
+	sessionHash := "wire:" + sessionID
+	capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
+
+	if err := db.InsertSession(x, sessionID, "", sessionHash, actorType, agentID, email, branch, "", capturedAt, 0, 0); err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+
+	// Insert turns, past any replayed ones.
+	first := 0
+	if skipReplayed {
+		hashes := make([]string, len(sf.Turns))
+		for i, t := range sf.Turns {
+			hashes[i] = db.TurnContentHash(codec.RoleName(t.Role), t.Text)
+		}
+		var err error
+		if first, err = replayedTurns(x, hashes); err != nil {
+			return err
+		}
+	}
+	stamps := wireTurnTimestamps(sf)
+	for i := first; i < len(sf.Turns); i++ {
+		t := sf.Turns[i]
+		role := codec.RoleName(t.Role)
+		if err := db.InsertTurn(x, newID(), sessionID, i, role, t.Text, stamps[i]); err != nil {
+			return fmt.Errorf("insert turn: %w", err)
 		}
 	}
 
-	return imported, nil
+	// Insert tool calls.
+	for i, tc := range sf.ToolCalls {
+		toolName := codec.ToolName(tc.Tool)
+		server := ""
+		if tc.Tool == codec.ToolMCP {
+			name, _ := tc.MCPName(dict)
+			if s, t, ok := session.SplitMCPTool(name); ok {
+				server, toolName = s, t
+			}
+		}
+		path := ""
+		switch tc.PathFlag {
+		case codec.PathDictRef:
+			path, _ = dict.Get(codec.NSPaths, tc.PathRef)
+		case codec.PathInline:
+			path = tc.PathInline
+		}
+		// Failure status is not carried on the wire.
+		if err := db.InsertToolCall(x, newID(), sessionID, i, toolName, server, path, tc.CmdPrefix, false, ""); err != nil {
+			return fmt.Errorf("insert tool_call: %w", err)
+		}
+	}
+	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
@@ -12,12 +14,60 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// indexOptions holds tokenizer and embedding overrides from `rekal index`
+// flags. Nil or empty fields keep the settings the existing index was built
+// with.
+type indexOptions struct {
+	Tokenizer      *lsa.TokenizerConfig // preset from --tokenizer
+	Stem           *bool
+	Stopwords      *bool
+	MinTokenLength *int
+	Embeddings     string // embeddingsLSA, embeddingsNomic or embeddingsBoth
+}
+
+// Embedding model selections for `rekal index --embeddings`.
+const (
+	embeddingsLSA   = "lsa"
+	embeddingsNomic = "nomic"
+	embeddingsBoth  = "both"
+)
+
+// apply returns tok with the set overrides applied. A --tokenizer preset
+// replaces tok, and the individual flags then adjust it.
+func (o indexOptions) apply(tok lsa.TokenizerConfig) lsa.TokenizerConfig {
+	if o.Tokenizer != nil {
+		tok = *o.Tokenizer
+	}
+	if o.Stem != nil {
+		tok.Stem = *o.Stem
+	}
+	if o.Stopwords != nil {
+		tok.Stopwords = *o.Stopwords
+	}
+	if o.MinTokenLength != nil {
+		tok.MinLength = *o.MinTokenLength
+	}
+	return tok
+}
+
 func newIndexCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		tokenizer         string
+		stem, stopwords   bool
+		minTokenLength    int
+		full, incremental bool
+		embeddings        string
+	)
+	cmd := &cobra.Command{
 		Use:   "index",
 		Short: "Rebuild the index DB from the data DB",
 		Long: `Drop and rebuild the index DB (.rekal/index.db) from the data DB.
 
+--incremental only adds the sessions the index doesn't have yet: their turns,
+tool calls, files and facets are inserted, the full-text index is refreshed,
+and they are folded into the saved LSA model without recomputing it. An
+index that has never been built is still built in full.
+
 The index is local-only and never synced. It contains:
   - Full-text search index (BM25) over conversation turns
   - LSA vector embeddings for lightweight semantic similarity
@@ -26,8 +76,29 @@ The index is local-only and never synced. It contains:
   - File co-occurrence graph
   - Tool call indexes
 
-Rebuild when the index is out of date or after importing new data.
-'rekal sync' rebuilds the index automatically.`,
+Full-text search and LSA share one tokenizer so a term that matches in one
+matches in the other. --tokenizer picks a preset: english (the default)
+stems English suffixes and drops English stopwords; none keeps every term
+as written, for sessions in other languages or full of identifiers.
+--stem, --stopwords and --min-token-length adjust the preset. The choice is
+recorded in the index and kept by later rebuilds, including 'rekal sync'.
+DuckDB stems with Snowball rather than LSA's suffix stripping and indexes
+tokens of any length, so the two can still differ slightly.
+
+--embeddings picks the semantic models to generate: lsa, nomic or both (the
+default). nomic is only available on some platforms; elsewhere --embeddings
+nomic says so and builds LSA embeddings instead. Like the tokenizer, the
+choice is recorded in the index and kept by later rebuilds and incremental
+updates.
+
+Checkpoint adds its sessions to a built index, 'rekal sync' rebuilds the
+index in full, and recall builds it if it has never been built. Rebuild
+after importing data by other means or editing the data DB.
+
+Each build writes .rekal/index.manifest.json with the session, turn and
+embedding counts, the embedding models and dimensions, the FTS config and
+the build time.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -41,12 +112,167 @@ Rebuild when the index is out of date or after importing new data.
 				return NewSilentError(err)
 			}
 
-			return runIndex(cmd, gitRoot)
+			var opts indexOptions
+			if cmd.Flags().Changed("tokenizer") {
+				tok, err := lsa.NamedTokenizer(tokenizer)
+				if err != nil {
+					return fmt.Errorf("--tokenizer: %w", err)
+				}
+				opts.Tokenizer = &tok
+			}
+			if cmd.Flags().Changed("stem") {
+				opts.Stem = &stem
+			}
+			if cmd.Flags().Changed("stopwords") {
+				opts.Stopwords = &stopwords
+			}
+			if cmd.Flags().Changed("min-token-length") {
+				if minTokenLength < 1 {
+					return fmt.Errorf("--min-token-length must be at least 1")
+				}
+				opts.MinTokenLength = &minTokenLength
+			}
+			if cmd.Flags().Changed("embeddings") {
+				if embeddings != embeddingsLSA && embeddings != embeddingsNomic && embeddings != embeddingsBoth {
+					return fmt.Errorf("--embeddings must be lsa, nomic or both, got %q", embeddings)
+				}
+				opts.Embeddings = embeddings
+			}
+			rebuild := opts.Tokenizer != nil || opts.Stem != nil || opts.Stopwords != nil || opts.MinTokenLength != nil || opts.Embeddings != ""
+			if incremental && rebuild {
+				return fmt.Errorf("--tokenizer, --stem, --stopwords, --min-token-length and --embeddings need a full rebuild; drop --incremental")
+			}
+
+			if incremental {
+				return runIndexIncremental(cmd, gitRoot)
+			}
+			return runIndex(cmd, gitRoot, opts)
 		},
 	}
+	cmd.Flags().StringVar(&tokenizer, "tokenizer", lsa.DefaultTokenizer.Name(), "Tokenizer preset: "+strings.Join(lsa.TokenizerNames(), " or "))
+	cmd.Flags().BoolVar(&stem, "stem", lsa.DefaultTokenizer.Stem, "Stem terms when tokenizing")
+	cmd.Flags().BoolVar(&stopwords, "stopwords", lsa.DefaultTokenizer.Stopwords, "Drop common English stopwords when tokenizing")
+	cmd.Flags().IntVar(&minTokenLength, "min-token-length", lsa.DefaultTokenizer.MinLength, "Minimum token length for LSA")
+	cmd.Flags().BoolVar(&full, "full", false, "Drop and rebuild the whole index (the default)")
+	cmd.Flags().BoolVar(&incremental, "incremental", false, "Only add sessions missing from the index")
+	cmd.Flags().StringVar(&embeddings, "embeddings", embeddingsBoth, "Semantic embeddings to generate: lsa, nomic or both")
+	cmd.MarkFlagsMutuallyExclusive("full", "incremental")
+	_ = cmd.Flags().MarkDeprecated("full", "a rebuild is already the default; run 'rekal index'")
+	_ = cmd.RegisterFlagCompletionFunc("tokenizer", cobra.FixedCompletions(lsa.TokenizerNames(), cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("embeddings", cobra.FixedCompletions([]string{embeddingsLSA, embeddingsNomic, embeddingsBoth}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+// lsaDimension returns the SVD rank full index builds ask for: git config
+// rekal.lsaDim, or lsa.DefaultDimension. Models over few sessions or terms
+// end up with fewer dimensions.
+func lsaDimension() int {
+	if v := gitConfigValue("rekal.lsaDim"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return lsa.DefaultDimension
+}
+
+// indexEmbeddingDim returns the dimension of the LSA vectors stored in the
+// index, or lsa.DefaultDimension if none is recorded.
+func indexEmbeddingDim(indexDB *sql.DB) int {
+	value, err := db.ReadIndexState(indexDB, "embedding_dim")
+	if err != nil {
+		return lsa.DefaultDimension
+	}
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
+	}
+	return lsa.DefaultDimension
+}
+
+// writeIndexFreshness records in index_state when the newest indexed
+// session was captured, for staleSessions to compare against.
+func writeIndexFreshness(indexDB *sql.DB) error {
+	newest, err := db.NewestIndexedCapture(indexDB)
+	if err != nil {
+		return err
+	}
+	value := ""
+	if !newest.IsZero() {
+		value = newest.UTC().Format(time.RFC3339Nano)
+	}
+	return db.WriteIndexState(indexDB, "newest_captured_at", value)
+}
+
+// staleSessions returns how many data DB sessions were captured after the
+// newest session the index holds, or 0 if the index has not recorded it.
+func staleSessions(indexDB *sql.DB, gitRoot string) (int, error) {
+	value, err := db.ReadIndexState(indexDB, "newest_captured_at")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	newest, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, fmt.Errorf("parse newest_captured_at: %w", err)
+	}
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
+	if err != nil {
+		return 0, err
+	}
+	defer dataDB.Close()
+	return db.CountSessionsCapturedAfter(dataDB, newest)
 }
 
-func runIndex(cmd *cobra.Command, gitRoot string) error {
+// indexEmbeddings returns the --embeddings choice the index was built with,
+// or embeddingsBoth if none is recorded.
+func indexEmbeddings(indexDB *sql.DB) string {
+	value, err := db.ReadIndexState(indexDB, "embeddings")
+	if err != nil || value == "" {
+		return embeddingsBoth
+	}
+	return value
+}
+
+// resolveEmbeddings returns embeddings, or embeddingsLSA with a note on w
+// when it asks for nomic alone and nomic is not available here.
+func resolveEmbeddings(embeddings string, w io.Writer) string {
+	if embeddings == embeddingsNomic && !nomic.Supported() {
+		fmt.Fprintln(w, "nomic embeddings are not available on this platform; building LSA embeddings instead")
+		return embeddingsLSA
+	}
+	return embeddings
+}
+
+// writeEmbeddingState records the --embeddings choice and the models
+// session_embeddings now holds, comma-separated, in index_state.
+func writeEmbeddingState(indexDB *sql.DB, embeddings string) error {
+	stats, err := db.QueryEmbeddingStats(indexDB)
+	if err != nil {
+		return err
+	}
+	models := make([]string, len(stats))
+	for i, s := range stats {
+		models[i] = s.Model
+	}
+	if err := db.WriteIndexState(indexDB, "embeddings", embeddings); err != nil {
+		return err
+	}
+	return db.WriteIndexState(indexDB, "embedding_models", strings.Join(models, ","))
+}
+
+// indexTokenizer returns the tokenizer the index was built with, or
+// lsa.DefaultTokenizer if none is recorded.
+func indexTokenizer(indexDB *sql.DB) lsa.TokenizerConfig {
+	value, err := db.ReadIndexState(indexDB, "tokenizer")
+	if err != nil || value == "" {
+		return lsa.DefaultTokenizer
+	}
+	tok, err := lsa.ParseTokenizerConfig(value)
+	if err != nil {
+		return lsa.DefaultTokenizer
+	}
+	return tok
+}
+
+func runIndex(cmd *cobra.Command, gitRoot string, opts indexOptions) error {
 	w := cmd.ErrOrStderr()
 
 	indexDB, err := db.OpenIndex(gitRoot)
@@ -60,6 +286,15 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 		return fmt.Errorf("load fts extension: %w", err)
 	}
 
+	// Keep the tokenizer and embedding models across rebuilds unless
+	// overridden.
+	tok := opts.apply(indexTokenizer(indexDB))
+	embeddings := opts.Embeddings
+	if embeddings == "" {
+		embeddings = indexEmbeddings(indexDB)
+	}
+	embeddings = resolveEmbeddings(embeddings, w)
+
 	// Clean slate.
 	fmt.Fprintln(w, "dropping existing index tables...")
 	if err := db.DropIndexTables(indexDB); err != nil {
@@ -89,7 +324,7 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 	// Create FTS index (only if there are turns).
 	if turnCount > 0 {
 		fmt.Fprintln(w, "creating full-text search index...")
-		if err := db.CreateFTSIndex(indexDB); err != nil {
+		if _, err := db.EnsureFTSIndex(indexDB, tok); err != nil {
 			return fmt.Errorf("create fts index: %w", err)
 		}
 	}
@@ -97,13 +332,16 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 	// LSA pass.
 	embeddingDim := 0
 	if sessionCount >= 2 {
-		fmt.Fprintln(w, "building LSA embeddings...")
 		sessionContent, err := db.QuerySessionContent(indexDB)
 		if err != nil {
 			return fmt.Errorf("query session content: %w", err)
 		}
 
-		model, err := lsa.Build(sessionContent, lsa.DefaultDimension)
+		var model *lsa.Model
+		if embeddings != embeddingsNomic {
+			fmt.Fprintln(w, "building LSA embeddings...")
+			model, err = lsa.BuildWith(sessionContent, lsaDimension(), tok)
+		}
 		if err != nil {
 			fmt.Fprintf(w, "warning: LSA build failed: %v\n", err)
 		} else if model != nil {
@@ -111,13 +349,18 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 			if err := db.StoreEmbeddings(indexDB, vectors, "lsa-v1"); err != nil {
 				return fmt.Errorf("store embeddings: %w", err)
 			}
+			if err := storeLSAModel(indexDB, model); err != nil {
+				return err
+			}
 			embeddingDim = model.Dim
 			fmt.Fprintf(w, "stored %d LSA embeddings (%d dimensions)\n", len(vectors), embeddingDim)
 		}
 
 		// Nomic pass (non-fatal).
-		if err := buildNomicEmbeddings(indexDB, sessionContent, w); err != nil {
-			fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
+		if embeddings != embeddingsLSA {
+			if err := buildNomicEmbeddings(indexDB, sessionContent, w); err != nil {
+				fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
+			}
 		}
 	}
 
@@ -131,14 +374,156 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 	if err := db.WriteIndexState(indexDB, "embedding_dim", strconv.Itoa(embeddingDim)); err != nil {
 		return err
 	}
+	if err := db.WriteIndexState(indexDB, "tokenizer", tok.String()); err != nil {
+		return err
+	}
+	if err := writeEmbeddingState(indexDB, embeddings); err != nil {
+		return err
+	}
+	if err := writeIndexFreshness(indexDB); err != nil {
+		return err
+	}
 	if err := db.WriteIndexState(indexDB, "last_indexed_at", "now"); err != nil {
 		return err
 	}
+	if err := writeIndexManifest(indexDB, gitRoot, "full"); err != nil {
+		fmt.Fprintf(w, "warning: %v\n", err)
+	}
 
 	fmt.Fprintf(w, "index rebuilt: %d sessions, %d turns\n", sessionCount, turnCount)
 	return nil
 }
 
+// runIndexIncremental adds data DB sessions missing from the index, or does
+// a full rebuild if the index has never been built.
+func runIndexIncremental(cmd *cobra.Command, gitRoot string) error {
+	w := cmd.ErrOrStderr()
+
+	indexDB, err := db.OpenIndex(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open index db: %w", err)
+	}
+	defer indexDB.Close()
+
+	if !db.IsIndexPopulated(indexDB) {
+		indexDB.Close()
+		return runIndex(cmd, gitRoot, indexOptions{})
+	}
+	if err := db.LoadFTSExtension(indexDB); err != nil {
+		return fmt.Errorf("load fts extension: %w", err)
+	}
+
+	added, err := indexMissingSessions(indexDB, gitRoot, w, w)
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		fmt.Fprintln(w, "index up to date")
+		return nil
+	}
+	fmt.Fprintf(w, "index updated: %d new session(s)\n", added)
+	return nil
+}
+
+// indexMissingSessions brings a built index up to date with the data DB
+// without dropping it: sessions the index lacks are added, the FTS index is
+// refreshed if turns_ft has changed since it was built, and the new
+// sessions are folded into the saved LSA model and embedded with nomic
+// (unless the index was built with --embeddings lsa). Progress goes to info
+// and warnings to w. It returns how many sessions were added.
+//
+// Without a saved LSA model (fewer than two sessions at the last full
+// build, or a different tokenizer) the new sessions get no LSA embeddings
+// until the next full rebuild.
+func indexMissingSessions(indexDB *sql.DB, gitRoot string, w, info io.Writer) (int, error) {
+	// Create any index tables added since the index was last rebuilt.
+	if err := db.InitIndexSchema(indexDB); err != nil {
+		return 0, fmt.Errorf("init index schema: %w", err)
+	}
+
+	added, err := db.PopulateIndexMissing(indexDB, gitRoot)
+	if err != nil {
+		return 0, fmt.Errorf("populate index: %w", err)
+	}
+	if len(added) > 0 {
+		fmt.Fprintf(info, "indexing %d new session(s)...\n", len(added))
+	}
+
+	tok := indexTokenizer(indexDB)
+	if rebuilt, err := db.EnsureFTSIndex(indexDB, tok); err != nil {
+		return 0, fmt.Errorf("create fts index: %w", err)
+	} else if rebuilt {
+		fmt.Fprintln(info, "full-text search index refreshed")
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	sessionContent, err := db.QuerySessionContentByIDs(indexDB, added)
+	if err != nil {
+		return 0, err
+	}
+
+	// LSA fold-in.
+	data, err := db.LoadLSAModel(indexDB, "lsa-v1")
+	if err != nil {
+		return 0, err
+	}
+	model := &lsa.Model{}
+	if data != nil && model.UnmarshalBinary(data) == nil && model.Tokenizer == tok {
+		if vectors := model.FoldIn(sessionContent); len(vectors) > 0 {
+			if err := db.StoreEmbeddings(indexDB, vectors, "lsa-v1"); err != nil {
+				return 0, fmt.Errorf("store embeddings: %w", err)
+			}
+		}
+		if err := storeLSAModel(indexDB, model); err != nil {
+			return 0, err
+		}
+	}
+
+	// Nomic pass (non-fatal).
+	embeddings := indexEmbeddings(indexDB)
+	if embeddings != embeddingsLSA {
+		if err := buildNomicEmbeddings(indexDB, sessionContent, info); err != nil {
+			fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
+		}
+	}
+	if err := writeEmbeddingState(indexDB, embeddings); err != nil {
+		return 0, err
+	}
+	if err := writeIndexFreshness(indexDB); err != nil {
+		return 0, err
+	}
+
+	var sessionCount, turnCount int
+	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&sessionCount); err != nil {
+		return 0, fmt.Errorf("count sessions: %w", err)
+	}
+	if err := indexDB.QueryRow("SELECT count(*) FROM turns_ft").Scan(&turnCount); err != nil {
+		return 0, fmt.Errorf("count turns: %w", err)
+	}
+	if err := db.WriteIndexState(indexDB, "session_count", strconv.Itoa(sessionCount)); err != nil {
+		return 0, err
+	}
+	if err := db.WriteIndexState(indexDB, "turn_count", strconv.Itoa(turnCount)); err != nil {
+		return 0, err
+	}
+	if err := writeIndexManifest(indexDB, gitRoot, "incremental"); err != nil {
+		fmt.Fprintf(w, "warning: %v\n", err)
+	}
+	return len(added), nil
+}
+
+// storeLSAModel saves the trained model so recall can project queries
+// without rebuilding it (see loadLSAModel).
+func storeLSAModel(indexDB *sql.DB, model *lsa.Model) error {
+	data, err := model.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("encode lsa model: %w", err)
+	}
+	return db.StoreLSAModel(indexDB, "lsa-v1", data)
+}
+
 // buildNomicEmbeddings generates nomic-embed-text embeddings for all sessions
 // and stores them in the index DB. Non-fatal: returns error on any failure.
 func buildNomicEmbeddings(indexDB *sql.DB, sessionContent map[string]string, w io.Writer) error {
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+// indexManifestFile is written next to index.db after every index build or
+// update, so index health can be inspected without opening DuckDB.
+const indexManifestFile = "index.manifest.json"
+
+// indexManifest is the content of .rekal/index.manifest.json.
+type indexManifest struct {
+	BuiltAt    string              `json:"built_at"`
+	Build      string              `json:"build"` // "full" | "incremental"
+	Sessions   int                 `json:"sessions"`
+	Turns      int                 `json:"turns"`
+	Embeddings []manifestEmbedding `json:"embeddings"`
+	FTS        manifestFTS         `json:"fts"`
+	// NewestCapturedAt is when the newest indexed session was captured.
+	NewestCapturedAt string `json:"newest_captured_at,omitempty"`
+}
+
+type manifestEmbedding struct {
+	Model     string `json:"model"`
+	Count     int    `json:"count"`
+	Dimension int    `json:"dimension"`
+}
+
+type manifestFTS struct {
+	Indexed        bool   `json:"indexed"`   // false when there were no turns to index
+	Tokenizer      string `json:"tokenizer"` // preset name, or "custom"
+	Stemmer        string `json:"stemmer"`
+	Stopwords      bool   `json:"stopwords"`
+	MinTokenLength int    `json:"min_token_length"`
+}
+
+// writeIndexManifest records what indexDB holds in .rekal/index.manifest.json.
+// build names the kind of pass that just ran: "full" or "incremental".
+func writeIndexManifest(indexDB *sql.DB, gitRoot, build string) error {
+	m := indexManifest{
+		BuiltAt:    time.Now().UTC().Format(time.RFC3339),
+		Build:      build,
+		Embeddings: []manifestEmbedding{},
+	}
+	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&m.Sessions); err != nil {
+		return fmt.Errorf("count sessions: %w", err)
+	}
+	if err := indexDB.QueryRow("SELECT count(*) FROM turns_ft").Scan(&m.Turns); err != nil {
+		return fmt.Errorf("count turns: %w", err)
+	}
+
+	newest, err := db.ReadIndexState(indexDB, "newest_captured_at")
+	if err != nil {
+		return err
+	}
+	m.NewestCapturedAt = newest
+
+	stats, err := db.QueryEmbeddingStats(indexDB)
+	if err != nil {
+		return err
+	}
+	for _, s := range stats {
+		m.Embeddings = append(m.Embeddings, manifestEmbedding{Model: s.Model, Count: s.Count, Dimension: s.Dimension})
+	}
+
+	tok := indexTokenizer(indexDB)
+	m.FTS = manifestFTS{
+		Indexed:        m.Turns > 0,
+		Tokenizer:      tok.Name(),
+		Stemmer:        db.FTSStemmer(tok),
+		Stopwords:      tok.Stopwords,
+		MinTokenLength: tok.MinLength,
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal index manifest: %w", err)
+	}
+	path := filepath.Join(gitRoot, ".rekal", indexManifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write index manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write index manifest: %w", err)
+	}
+	return nil
+}
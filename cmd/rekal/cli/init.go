@@ -1,11 +1,14 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
@@ -15,7 +18,18 @@ import (
 
 const rekalHookMarker = "# managed by rekal"
 
+// Markers around the rekal block appended to a hook rekal does not own.
+const (
+	rekalBlockStart = "# >>> rekal >>>"
+	rekalBlockEnd   = "# <<< rekal <<<"
+)
+
 func newInitCmd() *cobra.Command {
+	var (
+		timeout    time.Duration
+		contentIDs bool
+	)
+
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initialize Rekal in the current git repository",
@@ -29,7 +43,13 @@ Creates:
   agent skill        .claude/skills/rekal/SKILL.md for Claude Code
 
 If the remote already has data on your rekal branch, it is fetched and
-imported into the local data DB automatically.`,
+imported into the local data DB automatically. The fetch is aborted after
+--timeout (default 2m, or git config rekal.timeout).
+
+--content-ids sets git config rekal.contentIds, so every checkpoint from
+then on (the initial one included) derives session IDs from the
+conversation content: re-capturing the same transcripts after a reset, or
+on another machine, yields the same IDs. See 'rekal checkpoint --help'.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -79,28 +99,25 @@ imported into the local data DB automatically.`,
 			}
 
 			// Install hook stubs.
-			if err := installHooks(gitRoot); err != nil {
+			if err := installHooks(gitRoot, cmd.ErrOrStderr()); err != nil {
 				return fmt.Errorf("install hooks: %w", err)
 			}
 
 			// Create local orphan branch for checkpoint data.
-			if err := ensureOrphanBranch(gitRoot); err != nil {
+			if err := ensureOrphanBranch(gitRoot, networkTimeout(cmd, timeout)); err != nil {
 				return fmt.Errorf("create rekal branch: %w", err)
 			}
 
-			// Import existing data from orphan branch into DuckDB.
-			branch := rekalBranchName()
-			bodyData := gitShowFile(gitRoot, branch, "rekal.body")
-			if len(bodyData) > 9 { // more than empty header
-				importDB, err := db.OpenData(gitRoot)
-				if err == nil {
-					n, importErr := importBranch(gitRoot, importDB, branch)
-					importDB.Close()
-					if importErr != nil {
-						fmt.Fprintf(cmd.ErrOrStderr(), "rekal: import error: %v\n", importErr)
-					} else if n > 0 {
-						fmt.Fprintf(cmd.ErrOrStderr(), "rekal: imported %d session(s) from remote\n", n)
-					}
+			// Import existing data from orphan branch into DuckDB. importBranch
+			// validates the tree, so a malformed fetched branch is reported here.
+			importDB, err := db.OpenData(gitRoot)
+			if err == nil {
+				n, importErr := importBranch(gitRoot, importDB, rekalBranchName())
+				importDB.Close()
+				if importErr != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "rekal: import error: %v\n", importErr)
+				} else if n > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "rekal: imported %d session(s) from remote\n", n)
 				}
 			}
 
@@ -115,8 +132,14 @@ imported into the local data DB automatically.`,
 				return fmt.Errorf("update .gitignore for .claude: %w", err)
 			}
 
+			if contentIDs {
+				if err := exec.Command("git", "-C", gitRoot, "config", contentIDsConfigKey, "true").Run(); err != nil {
+					return fmt.Errorf("set %s: %w", contentIDsConfigKey, err)
+				}
+			}
+
 			// Run initial checkpoint to capture any existing sessions.
-			if err := doCheckpoint(gitRoot, cmd.ErrOrStderr()); err != nil {
+			if err := doCheckpoint(gitRoot, cmd.ErrOrStderr(), checkpointOptions{}); err != nil {
 				fmt.Fprintf(cmd.ErrOrStderr(), "rekal: warning: initial checkpoint failed: %v\n", err)
 			}
 
@@ -125,6 +148,8 @@ imported into the local data DB automatically.`,
 		},
 	}
 
+	addTimeoutFlag(cmd, &timeout)
+	cmd.Flags().BoolVar(&contentIDs, "content-ids", false, "Derive session IDs from conversation content in every checkpoint (sets git config rekal.contentIds)")
 	return cmd
 }
 
@@ -163,45 +188,154 @@ func appendGitignoreEntry(gitRoot, entry string) error {
 	return err
 }
 
-func installHooks(gitRoot string) error {
-	hooksDir := filepath.Join(gitRoot, ".git", "hooks")
-	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
-		return err
+// gitHooksDir returns the directory git runs gitRoot's hooks from:
+// core.hooksPath when set, else the hooks directory of the git dir. Linked
+// worktrees share their main repository's hooks, and a submodule's live
+// under the superproject's .git/modules, so neither is <gitRoot>/.git/hooks.
+func gitHooksDir(gitRoot string) (string, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("resolve hooks dir: %w", err)
 	}
-
-	postCommit := filepath.Join(hooksDir, "post-commit")
-	if err := writeHook(postCommit, hookScript("checkpoint")); err != nil {
-		return fmt.Errorf("post-commit hook: %w", err)
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitRoot, dir)
 	}
+	return dir, nil
+}
 
-	prePush := filepath.Join(hooksDir, "pre-push")
-	if err := writeHook(prePush, hookScript("push")); err != nil {
-		return fmt.Errorf("pre-push hook: %w", err)
+// installHooks writes the post-commit and pre-push hooks into gitHooksDir,
+// which honors core.hooksPath (Husky, pre-commit and similar frameworks). A
+// shell hook that already exists and is not rekal's gets a rekal block
+// inserted; any other existing hook is left alone, with a warning to w
+// saying what to call from it.
+func installHooks(gitRoot string, w io.Writer) error {
+	hooksDir, err := gitHooksDir(gitRoot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return err
 	}
 
+	for _, h := range []struct{ name, subcommand string }{
+		{"post-commit", "checkpoint"},
+		{"pre-push", "push"},
+	} {
+		path := filepath.Join(hooksDir, h.name)
+		written, err := writeHook(path, h.subcommand)
+		if err != nil {
+			return fmt.Errorf("%s hook: %w", h.name, err)
+		}
+		if !written {
+			fmt.Fprintf(w, "rekal: warning: %s is not a shell script and was left as is; add 'rekal %s --quiet' to it\n", path, h.subcommand)
+		}
+	}
 	return nil
 }
 
-// hookScript generates a shell hook that resolves the rekal binary at runtime.
-// Checks PATH first, then falls back to ~/.local/bin/rekal (the default install location).
+// hookScript generates a shell hook that runs hookCommands.
 func hookScript(subcommand string) string {
-	return `#!/bin/sh
-` + rekalHookMarker + `
-if command -v rekal >/dev/null 2>&1; then
-  rekal ` + subcommand + `
-elif [ -x "$HOME/.local/bin/rekal" ]; then
-  "$HOME/.local/bin/rekal" ` + subcommand + `
+	return "#!/bin/sh\n" + rekalHookMarker + "\n" + hookCommands(subcommand)
+}
+
+// hookCommands is the shell that runs a rekal subcommand from a hook,
+// resolving the binary at runtime. Checks PATH first, then falls back to
+// ~/.local/bin/rekal (the default install location). The subcommand runs
+// with --quiet so commits and pushes only show problems. Worktrees share
+// hooks but each has its own .rekal/, so nothing runs in a worktree where
+// rekal init has not been run.
+func hookCommands(subcommand string) string {
+	return `if [ -d "$(git rev-parse --show-toplevel)/.rekal" ]; then
+  if command -v rekal >/dev/null 2>&1; then
+    rekal ` + subcommand + ` --quiet
+  elif [ -x "$HOME/.local/bin/rekal" ]; then
+    "$HOME/.local/bin/rekal" ` + subcommand + ` --quiet
+  fi
 fi
 `
 }
 
-func writeHook(path, content string) error {
-	// If a hook already exists and is not ours, leave it alone.
+// hookBlock is the rekal block inserted into someone else's shell hook,
+// right after its shebang line. Running first, it still runs when the hook
+// ends in exit or exec, and it leaves the exit status that decides whether
+// the commit or push goes ahead to the rest of the hook.
+func hookBlock(subcommand string) string {
+	return rekalBlockStart + "\n" + hookCommands(subcommand) + rekalBlockEnd + "\n"
+}
+
+// writeHook installs the rekal hook for subcommand at path and reports
+// whether it did. A missing hook or one rekal wrote is (re)written whole. A
+// shell hook of someone else's gets hookBlock inserted after its shebang,
+// replacing an existing rekal block wherever it was. Any other hook is left
+// alone.
+func writeHook(path, subcommand string) (bool, error) {
 	existing, err := os.ReadFile(path)
-	if err == nil && !strings.Contains(string(existing), rekalHookMarker) {
-		return nil // not our hook; do not overwrite
+	if err != nil || strings.Contains(string(existing), rekalHookMarker) {
+		if err := os.WriteFile(path, []byte(hookScript(subcommand)), 0o755); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	content := string(existing)
+	if stripped, ok := stripHookBlock(content); ok {
+		content = stripped
+	} else if !isShellScript(content) {
+		return false, nil // not our hook and not shell; do not touch
+	}
+	shebang, rest, _ := strings.Cut(content, "\n")
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(shebang+"\n"+hookBlock(subcommand)+rest), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// stripHookBlock removes the rekal block from a hook's content and reports
+// whether there was one.
+func stripHookBlock(content string) (string, bool) {
+	start := strings.Index(content, rekalBlockStart+"\n")
+	if start < 0 {
+		return content, false
 	}
-	return os.WriteFile(path, []byte(content), 0o755)
+	end := strings.Index(content[start:], rekalBlockEnd)
+	if end < 0 {
+		return content, false
+	}
+	end += start + len(rekalBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start] + content[end:], true
+}
+
+// isShellScript reports whether content starts with a shebang for a POSIX
+// style shell (sh, bash, dash, ksh, zsh), directly or through env.
+func isShellScript(content string) bool {
+	first, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(first, "#!") {
+		return false
+	}
+	fields := strings.Fields(first[2:])
+	if len(fields) == 0 {
+		return false
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		if len(fields) < 2 {
+			return false
+		}
+		interp = filepath.Base(fields[1])
+	}
+	switch interp {
+	case "sh", "bash", "dash", "ksh", "zsh":
+		return true
+	}
+	return false
 }
 
 // rekalBranchName returns the orphan branch name for the current user.
@@ -227,7 +361,9 @@ func gitConfigValue(key string) string {
 // If the branch exists locally, it's left as-is.
 // If it exists on the remote, it's fetched.
 // Otherwise, a new orphan branch is created with empty rekal.body and dict.bin.
-func ensureOrphanBranch(gitRoot string) error {
+// A fetch that exceeds timeout fails rather than creating a branch that
+// would diverge from the remote.
+func ensureOrphanBranch(gitRoot string, timeout time.Duration) error {
 	branch := rekalBranchName()
 
 	// Check if local branch already exists.
@@ -239,7 +375,9 @@ func ensureOrphanBranch(gitRoot string) error {
 	remote := "origin"
 	remoteBranch := remote + "/" + branch
 	// Fetch the specific branch (ignore errors — remote may not exist or branch may not exist).
-	_ = exec.Command("git", "-C", gitRoot, "fetch", remote, branch).Run()
+	if _, err := runGitNetwork(gitRoot, timeout, "fetch", remote, branch); errors.Is(err, errNetworkTimeout) {
+		return err
+	}
 
 	// If remote branch now exists locally as a remote-tracking branch, create local from it.
 	if err := exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", remoteBranch).Run(); err == nil {
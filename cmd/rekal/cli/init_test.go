@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHook_InsertsIntoShellHook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "post-commit")
+	original := "#!/usr/bin/env bash\nset -e\nexec npx lint-staged\n"
+	if err := os.WriteFile(path, []byte(original), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := writeHook(path, "checkpoint")
+	if err != nil || !written {
+		t.Fatalf("writeHook: written=%v err=%v", written, err)
+	}
+	data, _ := os.ReadFile(path)
+	got := string(data)
+	// The hook ends in exec, so the block must come before it.
+	want := "#!/usr/bin/env bash\n" + hookBlock("checkpoint") + "set -e\nexec npx lint-staged\n"
+	if got != want {
+		t.Errorf("expected the rekal block right after the shebang\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o750 {
+		t.Errorf("hook mode changed to %v", info.Mode().Perm())
+	}
+
+	// Installing again refreshes the block instead of adding a second one.
+	if _, err := writeHook(path, "checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if n := strings.Count(string(data), rekalBlockStart); n != 1 {
+		t.Errorf("expected one rekal block after reinstall, got %d", n)
+	}
+
+	// Clean removes just the block.
+	removeHook(path)
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("clean should keep someone else's hook: %v", err)
+	}
+	if string(data) != original {
+		t.Errorf("clean should restore the original hook\ngot:  %q\nwant: %q", data, original)
+	}
+}
+
+func TestWriteHook_MovesAppendedBlockUp(t *testing.T) {
+	// Earlier versions appended the block, where an exit above skipped it.
+	path := filepath.Join(t.TempDir(), "pre-push")
+	old := "#!/bin/sh\nnpm test\nexit $?\n" + rekalBlockStart + "\nrekal_status=$?\n" +
+		hookCommands("push") + "(exit \"$rekal_status\")\n" + rekalBlockEnd + "\n"
+	if err := os.WriteFile(path, []byte(old), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeHook(path, "push"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "#!/bin/sh\n" + hookBlock("push") + "npm test\nexit $?\n"; string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestWriteHook_LeavesNonShellHook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pre-push")
+	original := "#!/usr/bin/env python3\nprint('hi')\n"
+	if err := os.WriteFile(path, []byte(original), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	written, err := writeHook(path, "push")
+	if err != nil || written {
+		t.Fatalf("writeHook on a python hook: written=%v err=%v", written, err)
+	}
+	removeHook(path)
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Errorf("non-shell hook should be untouched, got %q", data)
+	}
+}
+
+func TestWriteHook_OwnHookRemovedWhole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "post-commit")
+	if written, err := writeHook(path, "checkpoint"); err != nil || !written {
+		t.Fatalf("writeHook: written=%v err=%v", written, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), rekalHookMarker) || strings.Contains(string(data), rekalBlockStart) {
+		t.Errorf("a new hook should be rekal's own, got:\n%s", data)
+	}
+	removeHook(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("clean should delete rekal's own hook")
+	}
+}
+
+func TestIsShellScript(t *testing.T) {
+	tests := map[string]bool{
+		"#!/bin/sh\n":           true,
+		"#!/bin/bash -e\n":      true,
+		"#!/usr/bin/env zsh\n":  true,
+		"#! /bin/dash\n":        true,
+		"#!/usr/bin/env node\n": false,
+		"#!/usr/bin/python3\n":  false,
+		"echo no shebang\n":     false,
+		"":                      false,
+		"#!/usr/bin/env\n":      false,
+	}
+	for content, want := range tests {
+		if got := isShellScript(content); got != want {
+			t.Errorf("isShellScript(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
@@ -3,15 +3,21 @@
 package integration
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
 )
 
@@ -21,7 +27,7 @@ const testSessionJSONL = `{"type":"summary","sessionId":"test-session-001","tota
 {"type":"tool_result","parentMessageId":"m2","isSidechain":false,"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu-1","content":"package main\n\nfunc login() {}"}]},"timestamp":"2026-02-25T10:00:31Z"}
 {"type":"assistant","parentMessageId":"m3","isSidechain":false,"message":{"role":"assistant","content":[{"type":"text","text":"I see the issue. Let me fix it."},{"type":"tool_use","id":"tu-2","name":"Edit","input":{"file_path":"login.go","old_string":"func login() {}","new_string":"func login() error { return nil }"}}]},"timestamp":"2026-02-25T10:01:00Z"}
 {"type":"tool_result","parentMessageId":"m4","isSidechain":false,"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu-2","content":"File edited successfully."}]},"timestamp":"2026-02-25T10:01:01Z"}
-{"type":"assistant","parentMessageId":"m5","isSidechain":false,"message":{"role":"assistant","content":[{"type":"text","text":"Fixed. The login function now returns an error."},{"type":"tool_use","id":"tu-3","name":"Bash","input":{"command":"go test ./..."}}]},"timestamp":"2026-02-25T10:01:30Z"}
+{"type":"assistant","parentMessageId":"m5","isSidechain":false,"message":{"role":"assistant","content":[{"type":"text","text":"Fixed. The login function now returns an error."},{"type":"tool_use","id":"tu-3","name":"Bash","input":{"command":"go test ./..."}},{"type":"tool_use","id":"tu-5","name":"mcp__github__create_issue","input":{"title":"Add login tests"}}]},"timestamp":"2026-02-25T10:01:30Z"}
 {"type":"user","parentMessageId":"m7","isSidechain":false,"message":{"role":"user","content":[{"type":"text","text":"looks good, thanks"}]},"timestamp":"2026-02-25T10:02:00Z"}
 `
 
@@ -125,7 +131,7 @@ func TestCheckpoint_E2E_FullPipeline(t *testing.T) {
 	// Verify DuckDB state.
 	assertQueryContains(t, env, "SELECT count(*) as n FROM sessions", `"n":1`)
 	assertQueryContains(t, env, "SELECT count(*) as n FROM turns", `"n":5`)      // 2 user + 3 assistant
-	assertQueryContains(t, env, "SELECT count(*) as n FROM tool_calls", `"n":3`) // Read, Edit, Bash
+	assertQueryContains(t, env, "SELECT count(*) as n FROM tool_calls", `"n":4`) // Read, Edit, Bash, mcp__github__create_issue
 	assertQueryContains(t, env, "SELECT count(*) as n FROM checkpoints", `"n":1`)
 	assertQueryContains(t, env, "SELECT count(*) as n FROM checkpoint_sessions", `"n":1`)
 
@@ -224,7 +230,7 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 		t.Fatal("dict should have entries after push")
 	}
 
-	// Scan frames — expect 3: session + checkpoint + meta.
+	// Decode frames — expect 3: session + checkpoint + meta, in that order.
 	frames1, err := codec.ScanFrames(body1)
 	if err != nil {
 		t.Fatalf("ScanFrames: %v", err)
@@ -232,33 +238,29 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 	if len(frames1) != 3 {
 		t.Fatalf("expected 3 frames after push, got %d", len(frames1))
 	}
-	if frames1[0].Type != codec.FrameSession {
-		t.Errorf("frame 0: expected session (0x01), got 0x%02x", frames1[0].Type)
+	decoded1, err := codec.DecodeBody(body1, nil)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
 	}
-	if frames1[1].Type != codec.FrameCheckpoint {
-		t.Errorf("frame 1: expected checkpoint (0x02), got 0x%02x", frames1[1].Type)
+	if decoded1.Skipped != 0 {
+		t.Errorf("DecodeBody skipped %d frames, want 0", decoded1.Skipped)
 	}
-	if frames1[2].Type != codec.FrameMeta {
-		t.Errorf("frame 2: expected meta (0x03), got 0x%02x", frames1[2].Type)
+	if len(decoded1.Sessions) != 1 || len(decoded1.Checkpoints) != 1 || len(decoded1.Metas) != 1 {
+		t.Fatalf("expected 1 session, 1 checkpoint, 1 meta frame after push, got %d/%d/%d",
+			len(decoded1.Sessions), len(decoded1.Checkpoints), len(decoded1.Metas))
 	}
-
-	// Decode session frame and verify data.
-	dec, err := codec.NewDecoder()
-	if err != nil {
-		t.Fatalf("NewDecoder: %v", err)
+	if !(decoded1.SessionOffsets[0] < decoded1.CheckpointOffsets[0] && decoded1.CheckpointOffsets[0] < decoded1.MetaOffsets[0]) {
+		t.Errorf("frame order: session@%d checkpoint@%d meta@%d",
+			decoded1.SessionOffsets[0], decoded1.CheckpointOffsets[0], decoded1.MetaOffsets[0])
 	}
-	defer dec.Close()
 
-	payload0 := codec.ExtractFramePayload(body1, frames1[0])
-	sf, err := dec.DecodeSessionFrame(payload0)
-	if err != nil {
-		t.Fatalf("decode session: %v", err)
-	}
+	// Verify session frame data.
+	sf := decoded1.Sessions[0]
 	if len(sf.Turns) != 5 {
 		t.Errorf("session turns: got %d, want 5", len(sf.Turns))
 	}
-	if len(sf.ToolCalls) != 3 {
-		t.Errorf("session tool_calls: got %d, want 3", len(sf.ToolCalls))
+	if len(sf.ToolCalls) != 4 {
+		t.Fatalf("session tool_calls: got %d, want 4", len(sf.ToolCalls))
 	}
 	if sf.Turns[0].Text != "fix the auth bug in login.go" {
 		t.Errorf("turn 0 text: %q", sf.Turns[0].Text)
@@ -267,12 +269,8 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 		t.Errorf("tool 0: got %d, want Read (%d)", sf.ToolCalls[0].Tool, codec.ToolRead)
 	}
 
-	// Decode checkpoint frame — verify CheckpointRef is set.
-	payload1 := codec.ExtractFramePayload(body1, frames1[1])
-	cf, err := dec.DecodeCheckpointFrame(payload1)
-	if err != nil {
-		t.Fatalf("decode checkpoint: %v", err)
-	}
+	// Verify checkpoint frame links the session.
+	cf := decoded1.Checkpoints[0]
 	if len(cf.SessionRefs) != 1 {
 		t.Errorf("checkpoint session_refs: got %d, want 1", len(cf.SessionRefs))
 	}
@@ -288,6 +286,13 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 	if loadedDict.Len(codec.NSSessions) < 1 {
 		t.Errorf("dict sessions: %d", loadedDict.Len(codec.NSSessions))
 	}
+	mcp := sf.ToolCalls[3]
+	if mcp.Tool != codec.ToolMCP {
+		t.Errorf("tool 3: got %d, want MCP (%d)", mcp.Tool, codec.ToolMCP)
+	}
+	if name, _ := loadedDict.Get(codec.NSPaths, mcp.NameRef); name != "mcp__github__create_issue" {
+		t.Errorf("tool 3 name: got %q, want mcp__github__create_issue", name)
+	}
 
 	// Push again — should be no-op.
 	_, stderr2, err := env.RunCLI("push")
@@ -351,26 +356,19 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 		len(body1), len(body2), len(dict1), len(dict2))
 }
 
-func TestPush_E2E_ForceOnConflict(t *testing.T) {
+func TestPush_E2E_ShardsOversizedBody(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
-		t.Fatal(err)
+	// Any body with frames is over a 1-byte threshold.
+	if err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.shardSize", "1").Run(); err != nil {
+		t.Fatalf("git config: %v", err)
 	}
-	gitCommit(t, env.RepoDir, "initial")
 
-	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
-	defer cleanup()
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	gitCommit(t, env.RepoDir, "fix auth")
-
-	_, _, err := env.RunCLI("checkpoint")
-	if err != nil {
-		t.Fatalf("checkpoint: %v", err)
-	}
+	gitCommit(t, env.RepoDir, "initial")
 
 	bareDir := t.TempDir()
 	bareDir, _ = filepath.EvalSymlinks(bareDir)
@@ -380,104 +378,241 @@ func TestPush_E2E_ForceOnConflict(t *testing.T) {
 	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
 		t.Fatalf("git remote add: %v", err)
 	}
+	currentBranch, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err := exec.Command("git", "-C", env.RepoDir, "push", "--no-verify", "origin", strings.TrimSpace(string(currentBranch))).Run(); err != nil {
+		t.Fatalf("git push: %v", err)
+	}
 
-	_, _, err = env.RunCLI("push")
+	branch := "rekal/test@rekal.dev"
+
+	// First push fills the initially empty rekal.body.
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	shard0 := gitShow(env.RepoDir, branch, "rekal.body")
+	if len(shard0) <= 9 {
+		t.Fatal("rekal.body should have frames after first push")
+	}
+	if gitShow(env.RepoDir, branch, codec.ManifestFile) != nil {
+		t.Error("an unsharded body should have no manifest")
+	}
+
+	// Second push rolls over to rekal.body.1 and leaves rekal.body alone.
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanup2()
+	gitCommit(t, env.RepoDir, "add logging")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint 2: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push 2: %v (stderr: %s)", err, stderr)
+	}
+
+	manifest, err := codec.LoadManifest(gitShow(env.RepoDir, branch, codec.ManifestFile))
 	if err != nil {
-		t.Fatalf("initial push: %v", err)
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if manifest.Shards != 2 {
+		t.Fatalf("manifest shards = %d, want 2", manifest.Shards)
+	}
+	if sha256Hex(gitShow(env.RepoDir, branch, "rekal.body")) != sha256Hex(shard0) {
+		t.Error("full shard rekal.body changed after roll-over")
 	}
 
-	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	for i, file := range manifest.Files() {
+		decoded, err := codec.DecodeBody(gitShow(env.RepoDir, branch, file), dict)
+		if err != nil {
+			t.Fatalf("DecodeBody %s: %v", file, err)
+		}
+		if len(decoded.Sessions) != 1 || len(decoded.Checkpoints) != 1 || decoded.Skipped != 0 {
+			t.Errorf("shard %d (%s): got %d sessions, %d checkpoints, %d skipped; want 1, 1, 0",
+				i, file, len(decoded.Sessions), len(decoded.Checkpoints), decoded.Skipped)
+		}
+	}
 
-	// Simulate divergence.
+	// A fresh clone imports sessions from both shards.
 	cloneDir := t.TempDir()
 	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
 	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
 		t.Fatalf("git clone: %v", err)
 	}
 	for _, kv := range [][2]string{
-		{"user.email", "other@rekal.dev"},
-		{"user.name", "Other User"},
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Test User"},
 	} {
 		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
 	}
-	exec.Command("git", "-C", cloneDir, "fetch", "origin", branch).Run()
-	exec.Command("git", "-C", cloneDir, "checkout", "-b", branch, "origin/"+branch).Run()
-	exec.Command("git", "-C", cloneDir, "commit", "--allow-empty", "--amend", "-m", "divergent").Run()
-	exec.Command("git", "-C", cloneDir, "push", "--force", "origin", branch).Run()
+	env2 := NewTestEnvAt(t, cloneDir)
+	if _, stderr, err := env2.RunCLI("init"); err != nil {
+		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env2, "SELECT count(*) as n FROM sessions", `"n":2`)
+	assertQueryContains(t, env2, "SELECT count(*) as n FROM checkpoint_sessions", `"n":2`)
+}
 
-	// Second checkpoint + push should detect conflict.
-	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
-	defer cleanup2()
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { log.Println(\"ok\"); return nil }\n"), 0o644); err != nil {
+// testSessionSkewedJSONL has a turn stamped 90s before the one it follows.
+const testSessionSkewedJSONL = `{"type":"summary","sessionId":"test-session-skew"}
+{"type":"user","parentMessageId":"","isSidechain":false,"message":{"role":"user","content":[{"type":"text","text":"rename the config loader"}]},"timestamp":"2026-02-25T12:00:00Z","gitBranch":"main"}
+{"type":"assistant","parentMessageId":"m1","isSidechain":false,"message":{"role":"assistant","content":[{"type":"text","text":"Renaming it now."}]},"timestamp":"2026-02-25T12:00:00Z"}
+{"type":"user","parentMessageId":"m2","isSidechain":false,"message":{"role":"user","content":[{"type":"text","text":"also update the callers"}]},"timestamp":"2026-02-25T11:58:30Z"}
+{"type":"assistant","parentMessageId":"m3","isSidechain":false,"message":{"role":"assistant","content":[{"type":"text","text":"Done."}]},"timestamp":"2026-02-25T11:59:00Z"}
+`
+
+func TestPush_E2E_ClockSkewedTurns(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	gitCommit(t, env.RepoDir, "add logging")
+	gitCommit(t, env.RepoDir, "initial")
 
-	_, _, err = env.RunCLI("checkpoint")
-	if err != nil {
-		t.Fatalf("checkpoint 2: %v", err)
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
 	}
 
-	_, stderr, err := env.RunCLI("push")
-	if err != nil {
-		t.Fatalf("push (conflict): %v", err)
+	cleanup := writeSessionFile(t, env.RepoDir, "skew.jsonl", testSessionSkewedJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "rename config loader")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v", err)
 	}
-	if !strings.Contains(stderr, "non-fast-forward") && !strings.Contains(stderr, "rejected") {
-		if strings.Contains(stderr, "pushed to origin/") {
-			t.Errorf("conflicting push should not succeed without --force, got: %q", stderr)
-		}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
 	}
 
-	// Force push should succeed.
-	_, stderrForce, err := env.RunCLI("push", "--force")
+	decoded, err := codec.DecodeBody(gitShow(env.RepoDir, "rekal/test@rekal.dev", "rekal.body"), nil)
 	if err != nil {
-		t.Fatalf("push --force: %v", err)
+		t.Fatalf("DecodeBody: %v", err)
 	}
-	if !strings.Contains(stderrForce, "force pushed to origin/"+branch) {
-		t.Errorf("expected force push success message, got: %q", stderrForce)
+	if len(decoded.Sessions) != 1 {
+		t.Fatalf("sessions: got %d, want 1", len(decoded.Sessions))
 	}
 
-	localOut, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", branch).Output()
-	remoteOut, _ := exec.Command("git", "-C", bareDir, "rev-parse", branch).Output()
-	if strings.TrimSpace(string(localOut)) != strings.TrimSpace(string(remoteOut)) {
-		t.Error("local and remote should match after force push")
+	// Simultaneous turns stay 0; the backwards turn keeps its negative delta.
+	want := []int64{0, 0, -90, 30}
+	turns := decoded.Sessions[0].Turns
+	if len(turns) != len(want) {
+		t.Fatalf("turns: got %d, want %d", len(turns), len(want))
+	}
+	for i, turn := range turns {
+		if turn.TsDelta != want[i] {
+			t.Errorf("turn %d ts_delta: got %d, want %d", i, turn.TsDelta, want[i])
+		}
+	}
+
+	// A fresh clone rebuilds timestamps from the deltas; the skewed turn's
+	// is NULL, and the last turn still lands 60s before the first.
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
 	}
+	for _, kv := range [][2]string{
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Test User"},
+	} {
+		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
+	}
+	env2 := NewTestEnvAt(t, cloneDir)
+	if _, stderr, err := env2.RunCLI("init"); err != nil {
+		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env2, "SELECT list(turn_index ORDER BY turn_index) AS idx FROM turns WHERE ts IS NULL", `"idx":[2]`)
+	assertQueryContains(t, env2,
+		`SELECT CAST(epoch(t3.ts) - epoch(t0.ts) AS INTEGER) AS d, CAST(epoch(t1.ts) - epoch(t0.ts) AS INTEGER) AS d1
+		 FROM turns t0, turns t1, turns t3 WHERE t0.turn_index = 0 AND t1.turn_index = 1 AND t3.turn_index = 3`,
+		`"d":-60,"d1":0`)
 }
 
-func TestPush_NoBranch_Silent(t *testing.T) {
+func TestPush_E2E_CaptureDiffs(t *testing.T) {
 	env := NewTestEnv(t)
+	env.Init()
 
-	rekalDir := filepath.Join(env.RepoDir, ".rekal")
-	if err := os.MkdirAll(rekalDir, 0o755); err != nil {
+	login := filepath.Join(env.RepoDir, "login.go")
+	if err := os.WriteFile(login, []byte("package main\n\nfunc login() {}\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile(filepath.Join(rekalDir, "data.db"), []byte{}, 0o644); err != nil {
+	gitCommit(t, env.RepoDir, "initial")
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	if err := os.WriteFile(login, []byte("package main\n\nfunc login() error { return nil }\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint", "--capture-diffs"); err != nil {
+		t.Fatalf("checkpoint --capture-diffs: %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM files_touched WHERE file_path = 'login.go' AND diff LIKE '@@%+func login() error%'", `"n":1`)
 
-	_, stderr, err := env.RunCLI("push")
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
 	if err != nil {
-		t.Fatalf("push (no branch): %v", err)
+		t.Fatalf("LoadDict: %v", err)
 	}
-	if !strings.Contains(stderr, "no data to push") {
-		t.Errorf("push with no branch should report no data, got: %q", stderr)
+	decoded, err := codec.DecodeBody(gitShow(env.RepoDir, branch, "rekal.body"), dict)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	if len(decoded.Checkpoints) != 1 {
+		t.Fatalf("checkpoints: got %d, want 1", len(decoded.Checkpoints))
 	}
-}
-
-func TestPush_NoRemote_Silent(t *testing.T) {
-	env := NewTestEnv(t)
-	env.Init()
 
-	_, stderr, err := env.RunCLI("push")
+	// The hunks on the wire rebuild git's own diff of the file.
+	out, err := exec.Command("git", "-C", env.RepoDir, "diff", "HEAD~1", "HEAD", "--", "login.go").Output()
 	if err != nil {
-		t.Fatalf("push (no remote): %v", err)
+		t.Fatalf("git diff: %v", err)
 	}
-	if !strings.Contains(stderr, "no remote") {
-		t.Errorf("push with no remote should report it, got: %q", stderr)
+	want := string(out[strings.Index(string(out), "@@"):])
+	var got string
+	for _, f := range decoded.Checkpoints[0].Files {
+		if path, _ := dict.Get(codec.NSPaths, f.PathRef); path != "login.go" {
+			continue
+		}
+		if len(f.Hunks) == 0 {
+			t.Fatal("login.go has no hunks on the wire")
+		}
+		got = strings.Join(f.Hunks, "")
+	}
+	if got != want {
+		t.Errorf("diff from wire format:\n got %q\nwant %q", got, want)
+	}
+	// Hunks are inline, so the paths namespace holds only paths.
+	for _, p := range dict.Paths {
+		if strings.HasPrefix(p, "@@") {
+			t.Errorf("diff hunk interned in dict.bin paths: %q", p)
+		}
 	}
 }
 
-func TestLog_E2E(t *testing.T) {
+func TestPush_E2E_DictMaxPathsInlinesFilePaths(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
@@ -485,55 +620,64 @@ func TestLog_E2E(t *testing.T) {
 		t.Fatal(err)
 	}
 	gitCommit(t, env.RepoDir, "initial")
-
-	// Log with no checkpoints — should produce no output, no error.
-	stdout, _, err := env.RunCLI("log")
-	if err != nil {
-		t.Fatalf("log (empty): %v", err)
-	}
-	if strings.TrimSpace(stdout) != "" {
-		t.Errorf("log with no checkpoints should be empty, got: %q", stdout)
+	if err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.dictMaxPaths", "1").Run(); err != nil {
+		t.Fatal(err)
 	}
 
-	// Create a checkpoint.
 	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
 	defer cleanup()
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
-		t.Fatal(err)
+	for _, name := range []string{"login.go", "util.go"} {
+		if err := os.WriteFile(filepath.Join(env.RepoDir, name), []byte("package main\n\nfunc "+strings.TrimSuffix(name, ".go")+"() {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
 	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	addBareOrigin(t, env)
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
 
-	_, _, err = env.RunCLI("checkpoint")
+	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
 	if err != nil {
-		t.Fatalf("checkpoint: %v", err)
+		t.Fatalf("LoadDict: %v", err)
 	}
-
-	// Log should show the checkpoint.
-	stdout, _, err = env.RunCLI("log")
+	if n := dict.Len(codec.NSPaths); n > 1 {
+		t.Errorf("dict.bin paths = %d (%q), want at most the cap of 1", n, dict.Paths)
+	}
+	decoded, err := codec.DecodeBody(gitShow(env.RepoDir, branch, "rekal.body"), dict)
 	if err != nil {
-		t.Fatalf("log: %v", err)
+		t.Fatalf("DecodeBody: %v", err)
 	}
-	if !strings.Contains(stdout, "checkpoint ") {
-		t.Errorf("log should contain 'checkpoint', got: %q", stdout)
+	if len(decoded.Checkpoints) != 1 {
+		t.Fatalf("checkpoints: got %d, want 1", len(decoded.Checkpoints))
 	}
-	if !strings.Contains(stdout, "Sessions: 1") {
-		t.Errorf("log should show 'Sessions: 1', got: %q", stdout)
+	var paths []string
+	inline := 0
+	for _, f := range decoded.Checkpoints[0].Files {
+		path, err := f.Path(dict)
+		if err != nil {
+			t.Fatalf("file path: %v", err)
+		}
+		paths = append(paths, path)
+		if f.PathFlag == codec.PathInline {
+			inline++
+		}
 	}
-	if !strings.Contains(stdout, "Author:") {
-		t.Errorf("log should contain 'Author:', got: %q", stdout)
+	sort.Strings(paths)
+	if fmt.Sprint(paths) != "[login.go util.go]" || inline == 0 {
+		t.Errorf("files touched = %v with %d inline, want login.go and util.go, some inline", paths, inline)
 	}
 
-	// Log --limit 0 should show nothing.
-	stdout, _, err = env.RunCLI("log", "--limit", "0")
-	if err != nil {
-		t.Fatalf("log --limit 0: %v", err)
-	}
-	if strings.TrimSpace(stdout) != "" {
-		t.Errorf("log --limit 0 should be empty, got: %q", stdout)
+	if _, stderr, err := env.RunCLI("verify"); err != nil {
+		t.Errorf("verify: %v (stderr: %s)", err, stderr)
 	}
 }
 
-func TestImport_E2E_RoundTrip(t *testing.T) {
+func TestPush_E2E_ForceOnConflict(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
@@ -542,20 +686,18 @@ func TestImport_E2E_RoundTrip(t *testing.T) {
 	}
 	gitCommit(t, env.RepoDir, "initial")
 
-	// Checkpoint + push to populate orphan branch.
 	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
 	defer cleanup()
 	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	gitCommit(t, env.RepoDir, "fix auth bug")
+	gitCommit(t, env.RepoDir, "fix auth")
 
 	_, _, err := env.RunCLI("checkpoint")
 	if err != nil {
 		t.Fatalf("checkpoint: %v", err)
 	}
 
-	// Create bare remote and push.
 	bareDir := t.TempDir()
 	bareDir, _ = filepath.EvalSymlinks(bareDir)
 	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
@@ -564,92 +706,1368 @@ func TestImport_E2E_RoundTrip(t *testing.T) {
 	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
 		t.Fatalf("git remote add: %v", err)
 	}
-	// Push current branch so clone has a proper default branch.
-	// Use --no-verify to skip the pre-push hook (rekal binary not in PATH during tests).
-	currentBranch, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
-	branchName := strings.TrimSpace(string(currentBranch))
-	if err := exec.Command("git", "-C", env.RepoDir, "push", "--no-verify", "origin", branchName).Run(); err != nil {
-		t.Fatalf("git push %s: %v", branchName, err)
-	}
 
 	_, _, err = env.RunCLI("push")
 	if err != nil {
-		t.Fatalf("push: %v", err)
+		t.Fatalf("initial push: %v", err)
 	}
 
-	// Clone into a new repo and init — should import from remote.
+	branch := "rekal/test@rekal.dev"
+
+	// Simulate divergence.
 	cloneDir := t.TempDir()
 	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
 	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
 		t.Fatalf("git clone: %v", err)
 	}
 	for _, kv := range [][2]string{
-		{"user.email", "test@rekal.dev"},
-		{"user.name", "Test User"},
+		{"user.email", "other@rekal.dev"},
+		{"user.name", "Other User"},
 	} {
 		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
 	}
+	exec.Command("git", "-C", cloneDir, "fetch", "origin", branch).Run()
+	exec.Command("git", "-C", cloneDir, "checkout", "-b", branch, "origin/"+branch).Run()
+	exec.Command("git", "-C", cloneDir, "commit", "--allow-empty", "--amend", "-m", "divergent").Run()
+	exec.Command("git", "-C", cloneDir, "push", "--force", "origin", branch).Run()
 
-	env2 := NewTestEnvAt(t, cloneDir)
-	_, stderr, err := env2.RunCLI("init")
-	if err != nil {
-		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
-	}
-	if !strings.Contains(stderr, "imported") {
-		t.Errorf("init should report imported sessions, got: %q", stderr)
+	// Second checkpoint + push should detect conflict.
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanup2()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { log.Println(\"ok\"); return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
 	}
+	gitCommit(t, env.RepoDir, "add logging")
 
-	// Verify DuckDB in clone has the imported data.
-	assertQueryContains(t, env2, "SELECT count(*) as n FROM sessions", `"n":1`)
-	assertQueryContains(t, env2, "SELECT count(*) as n FROM checkpoints", `"n":1`)
-
-	// Log should work in the clone.
-	stdout, _, err := env2.RunCLI("log")
+	_, _, err = env.RunCLI("checkpoint")
 	if err != nil {
-		t.Fatalf("log (clone): %v", err)
+		t.Fatalf("checkpoint 2: %v", err)
+	}
+
+	// Force push overwrites the diverged remote without merging.
+	_, stderrForce, err := env.RunCLI("push", "--force")
+	if err != nil {
+		t.Fatalf("push --force: %v", err)
+	}
+	if !strings.Contains(stderrForce, "force pushed to origin/"+branch) {
+		t.Errorf("expected force push success message, got: %q", stderrForce)
+	}
+
+	localOut, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", branch).Output()
+	remoteOut, _ := exec.Command("git", "-C", bareDir, "rev-parse", branch).Output()
+	if strings.TrimSpace(string(localOut)) != strings.TrimSpace(string(remoteOut)) {
+		t.Error("local and remote should match after force push")
+	}
+}
+
+func TestPush_E2E_MergesDivergedBranch(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("initial push: %v (stderr: %s)", err, stderr)
+	}
+
+	branch := "rekal/test@rekal.dev"
+
+	// The same user pushes a second session from another machine.
+	otherDir := t.TempDir()
+	otherDir, _ = filepath.EvalSymlinks(otherDir)
+	if err := exec.Command("git", "clone", bareDir, otherDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Rekal Test"},
+	} {
+		if err := exec.Command("git", "-C", otherDir, "config", kv[0], kv[1]).Run(); err != nil {
+			t.Fatalf("git config: %v", err)
+		}
+	}
+	other := NewTestEnvAt(t, otherDir)
+	other.Init()
+	cleanupOther := writeSessionFile(t, otherDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanupOther()
+	gitCommit(t, otherDir, "add logging")
+	if _, _, err := other.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint on other machine: %v", err)
+	}
+	if _, stderr, err := other.RunCLI("push"); err != nil || !strings.Contains(stderr, "pushed to origin/"+branch) {
+		t.Fatalf("push from other machine: %v (stderr: %s)", err, stderr)
+	}
+
+	// Meanwhile this machine captures a third session.
+	session3 := strings.NewReplacer("test-session-002", "test-session-003", "add error logging", "add request tracing").Replace(testSessionJSONL2)
+	cleanup3 := writeSessionFile(t, env.RepoDir, "session3.jsonl", session3)
+	defer cleanup3()
+	gitCommit(t, env.RepoDir, "add tracing")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint 2: %v", err)
+	}
+
+	_, stderr, err := env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push (diverged): %v", err)
+	}
+	if !strings.Contains(stderr, "merged and pushed to origin/"+branch) {
+		t.Fatalf("expected merge, got: %q", stderr)
+	}
+
+	localOut, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", branch).Output()
+	remoteOut, _ := exec.Command("git", "-C", bareDir, "rev-parse", branch).Output()
+	if strings.TrimSpace(string(localOut)) != strings.TrimSpace(string(remoteOut)) {
+		t.Error("local and remote should match after merge")
+	}
+
+	dict, err := codec.LoadDict(gitShow(bareDir, branch, "dict.bin"))
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	decoded, err := codec.DecodeBody(gitShow(bareDir, branch, "rekal.body"), dict)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	if len(decoded.Sessions) != 3 || len(decoded.Checkpoints) != 3 {
+		t.Fatalf("merged remote: got %d sessions, %d checkpoints; want 3 and 3",
+			len(decoded.Sessions), len(decoded.Checkpoints))
+	}
+	var texts []string
+	for _, sf := range decoded.Sessions {
+		texts = append(texts, sf.Turns[0].Text)
+	}
+	sort.Strings(texts)
+	if want := "add error logging,add request tracing,fix the auth bug in login.go"; strings.Join(texts, ",") != want {
+		t.Errorf("merged sessions: got %v", texts)
+	}
+}
+
+func TestCheckpoint_Quiet(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth")
+
+	_, stderr, err := env.RunCLI("checkpoint", "--quiet")
+	if err != nil {
+		t.Fatalf("checkpoint --quiet: %v (stderr: %s)", err, stderr)
+	}
+	if stderr != "" {
+		t.Errorf("checkpoint --quiet should print nothing on success, got: %q", stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) as n FROM sessions", `"n":1`)
+
+	// REKAL_QUIET does the same for push, which has no remote to push to.
+	t.Setenv("REKAL_QUIET", "1")
+	_, stderr, err = env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if stderr != "" {
+		t.Errorf("push with REKAL_QUIET=1 should print nothing, got: %q", stderr)
+	}
+
+	// Errors still surface.
+	_, _, err = env.RunCLI("push", "--quiet", "--remote", "missing")
+	if err == nil {
+		t.Error("push --quiet --remote missing should still fail")
+	}
+}
+
+func TestPush_NoBranch_Silent(t *testing.T) {
+	env := NewTestEnv(t)
+
+	rekalDir := filepath.Join(env.RepoDir, ".rekal")
+	if err := os.MkdirAll(rekalDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rekalDir, "data.db"), []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push (no branch): %v", err)
+	}
+	if !strings.Contains(stderr, "no data to push") {
+		t.Errorf("push with no branch should report no data, got: %q", stderr)
+	}
+}
+
+func TestPush_NoRemote_Silent(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	_, stderr, err := env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push (no remote): %v", err)
+	}
+	if !strings.Contains(stderr, "no remote") {
+		t.Errorf("push with no remote should report it, got: %q", stderr)
+	}
+}
+
+func TestPush_E2E_RepoRelativeToolPaths(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// The session reads and edits login.go by absolute path; the commit
+	// puts login.go in files_touched relative to the root.
+	abs := filepath.Join(env.RepoDir, "login.go")
+	jsonl := strings.ReplaceAll(testSessionJSONL, `"file_path":"login.go"`, `"file_path":"`+abs+`"`)
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", jsonl)
+	defer cleanup()
+	if err := os.WriteFile(abs, []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	assertQueryContains(t, env, "SELECT DISTINCT path FROM tool_calls WHERE tool = 'Edit'", `"path":"`+abs+`"`)
+	assertQueryContains(t, env, "SELECT file_path FROM files_touched", `"file_path":"login.go"`)
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	var loginEntries []string
+	for i := 0; i < dict.Len(codec.NSPaths); i++ {
+		p, _ := dict.Get(codec.NSPaths, uint64(i))
+		if strings.HasSuffix(p, "login.go") {
+			loginEntries = append(loginEntries, p)
+		}
+	}
+	if len(loginEntries) != 1 || loginEntries[0] != "login.go" {
+		t.Errorf("NSPaths entries for login.go: got %q, want one \"login.go\"", loginEntries)
+	}
+
+	decoded, err := codec.DecodeBody(gitShow(env.RepoDir, branch, "rekal.body"), dict)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	ref, _ := dict.Lookup(codec.NSPaths, "login.go")
+	if edit := decoded.Sessions[0].ToolCalls[1]; edit.PathRef != ref {
+		t.Errorf("Edit tool call path ref = %d, want login.go (%d)", edit.PathRef, ref)
+	}
+	if file := decoded.Checkpoints[0].Files[0]; file.PathRef != ref {
+		t.Errorf("files_touched path ref = %d, want login.go (%d)", file.PathRef, ref)
+	}
+}
+
+func TestLog_E2E(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// Log with no checkpoints — should produce no output, no error.
+	stdout, _, err := env.RunCLI("log")
+	if err != nil {
+		t.Fatalf("log (empty): %v", err)
+	}
+	if strings.TrimSpace(stdout) != "" {
+		t.Errorf("log with no checkpoints should be empty, got: %q", stdout)
+	}
+
+	// Create a checkpoint.
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	_, _, err = env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	// Log should show the checkpoint.
+	stdout, _, err = env.RunCLI("log")
+	if err != nil {
+		t.Fatalf("log: %v", err)
 	}
 	if !strings.Contains(stdout, "checkpoint ") {
-		t.Errorf("log in clone should show checkpoint, got: %q", stdout)
+		t.Errorf("log should contain 'checkpoint', got: %q", stdout)
+	}
+	if !strings.Contains(stdout, "Sessions: 1") {
+		t.Errorf("log should show 'Sessions: 1', got: %q", stdout)
+	}
+	if !strings.Contains(stdout, "Author:") {
+		t.Errorf("log should contain 'Author:', got: %q", stdout)
+	}
+	if !strings.Contains(stdout, "Cost:     $0.05") {
+		t.Errorf("log should show the summary line's cost, got: %q", stdout)
+	}
+
+	// Log --limit 0 should show nothing.
+	stdout, _, err = env.RunCLI("log", "--limit", "0")
+	if err != nil {
+		t.Fatalf("log --limit 0: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "" {
+		t.Errorf("log --limit 0 should be empty, got: %q", stdout)
+	}
+}
+
+func TestLog_JSONAndFiles(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+
+	type entry struct {
+		ID        string `json:"id"`
+		GitSHA    string `json:"git_sha"`
+		NSessions int    `json:"n_sessions"`
+		Files     []struct {
+			Path       string `json:"path"`
+			ChangeType string `json:"change_type"`
+		} `json:"files"`
+	}
+	parse := func(stdout string) []entry {
+		t.Helper()
+		var entries []entry
+		for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+			var e entry
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("parse log line %q: %v", line, err)
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+
+	stdout, stderr, err := env.RunCLI("log", "--json")
+	if err != nil {
+		t.Fatalf("log --json: %v\nstderr: %s", err, stderr)
+	}
+	entries := parse(stdout)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 checkpoints, got %d: %s", len(entries), stdout)
+	}
+	// Newest first.
+	if entries[0].ID != "cp-2" || entries[0].GitSHA != "def456" || entries[0].NSessions != 1 {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].ID != "cp-1" || entries[1].GitSHA != "abc123" || entries[1].NSessions != 1 {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+	if len(entries[1].Files) != 0 {
+		t.Errorf("files should be omitted without --files, got: %+v", entries[1].Files)
+	}
+
+	stdout, _, err = env.RunCLI("log", "--json", "--files")
+	if err != nil {
+		t.Fatalf("log --json --files: %v", err)
+	}
+	entries = parse(stdout)
+	var paths []string
+	for _, f := range entries[1].Files {
+		if f.ChangeType != "M" {
+			t.Errorf("change_type for %s: got %q, want M", f.Path, f.ChangeType)
+		}
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "src/auth/jwt.go,src/auth/middleware.go" {
+		t.Errorf("cp-1 files: got %v", paths)
+	}
+	if len(entries[0].Files) != 0 {
+		t.Errorf("cp-2 touched no files, got: %+v", entries[0].Files)
+	}
+
+	// Text format lists them under the checkpoint.
+	stdout, _, err = env.RunCLI("log", "--files")
+	if err != nil {
+		t.Fatalf("log --files: %v", err)
+	}
+	if !strings.Contains(stdout, "    M  src/auth/jwt.go\n") || !strings.Contains(stdout, "    M  src/auth/middleware.go\n") {
+		t.Errorf("log --files should list cp-1's files, got: %q", stdout)
+	}
+}
+
+func TestLog_VerboseFrameSizes(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	gitCommit(t, env.RepoDir, "initial")
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	stdout, _, err := env.RunCLI("log", "--verbose")
+	if err != nil {
+		t.Fatalf("log --verbose: %v", err)
+	}
+	if !strings.Contains(stdout, "Wire:     not pushed") {
+		t.Errorf("an unpushed checkpoint should have no frames, got: %q", stdout)
+	}
+
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	stdout, stderr, err := env.RunCLI("log", "--verbose", "--json")
+	if err != nil {
+		t.Fatalf("log --verbose --json: %v (stderr: %s)", err, stderr)
+	}
+	var e struct {
+		Wire *struct {
+			Frames []struct {
+				Type         string `json:"type"`
+				Compressed   int    `json:"compressed_bytes"`
+				Uncompressed int    `json:"uncompressed_bytes"`
+			} `json:"frames"`
+			Compressed   int     `json:"compressed_bytes"`
+			Uncompressed int     `json:"uncompressed_bytes"`
+			Ratio        float64 `json:"ratio"`
+		} `json:"wire"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &e); err != nil {
+		t.Fatalf("parse log line: %v\n%s", err, stdout)
+	}
+	if e.Wire == nil {
+		t.Fatalf("pushed checkpoint has no wire sizes: %s", stdout)
+	}
+	var types []string
+	for _, f := range e.Wire.Frames {
+		types = append(types, f.Type)
+		if f.Compressed <= 0 || f.Uncompressed <= 0 {
+			t.Errorf("%s frame: compressed %d bytes, decompressed %d; want both positive", f.Type, f.Compressed, f.Uncompressed)
+		}
+		// A checkpoint frame is a few dozen bytes, which zstd can't shrink;
+		// a session frame's turns compress.
+		if f.Type == "session" && f.Compressed >= f.Uncompressed {
+			t.Errorf("session frame: compressed %d bytes, decompressed %d; want it smaller", f.Compressed, f.Uncompressed)
+		}
+	}
+	if strings.Join(types, ",") != "checkpoint,session" {
+		t.Errorf("frames: got %v, want the checkpoint frame and its session frame", types)
+	}
+	if e.Wire.Compressed <= 0 || e.Wire.Compressed >= e.Wire.Uncompressed || e.Wire.Ratio <= 1 {
+		t.Errorf("totals: compressed %d, decompressed %d, ratio %.2f", e.Wire.Compressed, e.Wire.Uncompressed, e.Wire.Ratio)
+	}
+
+	stdout, _, err = env.RunCLI("log", "--verbose")
+	if err != nil {
+		t.Fatalf("log --verbose: %v", err)
+	}
+	if !strings.Contains(stdout, "Wire:     2 frame(s), ") {
+		t.Errorf("text output should summarize the frames, got: %q", stdout)
+	}
+}
+
+func TestExport_E2E_JSONL(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanup2()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	if _, _, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	stdout, _, err := env.RunCLI("export", "--format", "jsonl")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	defer dataDB.Close()
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSONL lines, got %d: %q", len(lines), stdout)
+	}
+	for i, line := range lines {
+		var s struct {
+			SessionID   string            `json:"session_id"`
+			Turns       []json.RawMessage `json:"turns"`
+			ToolCalls   []json.RawMessage `json:"tool_calls"`
+			Checkpoints []struct {
+				GitSHA string `json:"git_sha"`
+				Files  []struct {
+					Path string `json:"path"`
+				} `json:"files"`
+			} `json:"checkpoints"`
+		}
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v", i, err)
+		}
+		var turns int
+		if err := dataDB.QueryRow("SELECT count(*) FROM turns WHERE session_id = $1", s.SessionID).Scan(&turns); err != nil {
+			t.Fatalf("count turns: %v", err)
+		}
+		if len(s.Turns) != turns {
+			t.Errorf("session %s: exported %d turns, DB has %d", s.SessionID, len(s.Turns), turns)
+		}
+		if len(s.ToolCalls) == 0 {
+			t.Errorf("session %s: expected tool calls", s.SessionID)
+		}
+		if len(s.Checkpoints) != 1 || len(s.Checkpoints[0].GitSHA) != 40 || len(s.Checkpoints[0].Files) == 0 {
+			t.Errorf("session %s: expected one checkpoint with files, got %+v", s.SessionID, s.Checkpoints)
+		}
+	}
+	dataDB.Close()
+
+	// JSON array to a file.
+	out := filepath.Join(t.TempDir(), "sessions.json")
+	_, stderr, err := env.RunCLI("export", "--out", out)
+	if err != nil {
+		t.Fatalf("export --out: %v", err)
+	}
+	if !strings.Contains(stderr, "exported 2 session(s)") {
+		t.Errorf("export --out should report the count, got: %q", stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read %s: %v", out, err)
+	}
+	var all []map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		t.Fatalf("export --out is not a JSON array: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 sessions in array, got %d", len(all))
+	}
+
+	// Filters apply: there are no agent sessions.
+	stdout, _, err = env.RunCLI("export", "--actor", "agent")
+	if err != nil {
+		t.Fatalf("export --actor agent: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("export with no matches should be an empty array, got: %q", stdout)
+	}
+
+	if _, _, err := env.RunCLI("export", "--format", "csv"); err == nil {
+		t.Error("export --format csv should fail")
+	}
+}
+
+func TestImport_E2E_RoundTrip(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// Checkpoint + push to populate orphan branch.
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	_, _, err := env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	// Create bare remote and push.
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	// Push current branch so clone has a proper default branch.
+	// Use --no-verify to skip the pre-push hook (rekal binary not in PATH during tests).
+	currentBranch, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	branchName := strings.TrimSpace(string(currentBranch))
+	if err := exec.Command("git", "-C", env.RepoDir, "push", "--no-verify", "origin", branchName).Run(); err != nil {
+		t.Fatalf("git push %s: %v", branchName, err)
+	}
+
+	_, _, err = env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+
+	// Clone into a new repo and init — should import from remote.
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Test User"},
+	} {
+		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
+	}
+
+	env2 := NewTestEnvAt(t, cloneDir)
+	_, stderr, err := env2.RunCLI("init")
+	if err != nil {
+		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "imported") {
+		t.Errorf("init should report imported sessions, got: %q", stderr)
+	}
+
+	// Verify DuckDB in clone has the imported data.
+	assertQueryContains(t, env2, "SELECT count(*) as n FROM sessions", `"n":1`)
+	assertQueryContains(t, env2, "SELECT count(*) as n FROM checkpoints", `"n":1`)
+	assertQueryContains(t, env2, "SELECT tool, server FROM tool_calls WHERE server IS NOT NULL", `"server":"github","tool":"create_issue"`)
+
+	// Log should work in the clone.
+	stdout, _, err := env2.RunCLI("log")
+	if err != nil {
+		t.Fatalf("log (clone): %v", err)
+	}
+	if !strings.Contains(stdout, "checkpoint ") {
+		t.Errorf("log in clone should show checkpoint, got: %q", stdout)
+	}
+}
+
+// testAgentSessionJSONL is a Task subagent's transcript, written by Claude
+// Code next to the main session's.
+const testAgentSessionJSONL = `{"type":"user","sessionId":"test-session-001","agentId":"7c1e04b2","isSidechain":true,"message":{"role":"user","content":"list the callers of login()"},"timestamp":"2026-02-25T10:00:40Z","gitBranch":"main"}
+{"type":"assistant","sessionId":"test-session-001","agentId":"7c1e04b2","isSidechain":true,"message":{"role":"assistant","content":[{"type":"text","text":"login() has no callers yet."},{"type":"tool_use","id":"tu-a1","name":"Grep","input":{"pattern":"login","path":"."}}]},"timestamp":"2026-02-25T10:00:45Z"}
+`
+
+func TestCheckpoint_E2E_AgentSession(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	cleanup2 := writeSessionFile(t, env.RepoDir, "agent-7c1e04b2.jsonl", testAgentSessionJSONL)
+	defer cleanup2()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	_, stderr, err := env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "2 session(s) captured") {
+		t.Errorf("expected '2 session(s) captured', got: %q", stderr)
+	}
+
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions WHERE actor_type = 'human' AND COALESCE(agent_id, '') = ''", `"n":1`)
+	assertQueryContains(t, env, "SELECT actor_type, agent_id FROM sessions WHERE actor_type = 'agent'", `"actor_type":"agent","agent_id":"7c1e04b2"`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns t JOIN sessions s ON s.id = t.session_id WHERE s.actor_type = 'agent'", `"n":2`)
+}
+
+func TestCheckpoint_E2E_Rename(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "auth.go"), []byte("package main\n\nfunc login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	if err := exec.Command("git", "-C", env.RepoDir, "mv", "auth.go", "login.go").Run(); err != nil {
+		t.Fatalf("git mv: %v", err)
+	}
+	gitCommit(t, env.RepoDir, "rename auth.go")
+
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	assertQueryContains(t, env, "SELECT change_type, file_path FROM files_touched WHERE change_type <> 'T'", `{"change_type":"R","file_path":"login.go"}`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM files_touched WHERE file_path = 'auth.go' OR change_type LIKE 'R_%'", `"n":0`)
+}
+
+func TestCheckpoint_ContentIDsConfigIsStableAcrossResets(t *testing.T) {
+	env := NewTestEnv(t)
+	gitCommit(t, env.RepoDir, "initial")
+	if _, stderr, err := env.RunCLI("init", "--content-ids"); err != nil {
+		t.Fatalf("init --content-ids: %v (stderr: %s)", err, stderr)
+	}
+	out, err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.contentIds").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		t.Fatalf("rekal.contentIds = %q (%v), want true", out, err)
+	}
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	sessionIDs := func() string {
+		t.Helper()
+		stdout, _, err := env.RunCLI("query", "SELECT id FROM sessions ORDER BY id")
+		if err != nil {
+			t.Fatalf("query sessions: %v", err)
+		}
+		return strings.TrimSpace(stdout)
+	}
+
+	// The hook runs plain checkpoint; the git config alone turns content IDs on.
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	first := sessionIDs()
+	if strings.Count(first, "\n") != 0 || !strings.Contains(first, `"id":"`) {
+		t.Fatalf("expected one session, got: %s", first)
+	}
+
+	// Reset the data DB and capture the same transcript again: init's
+	// initial checkpoint picks it up under the same ID.
+	if _, _, err := env.RunCLI("clean"); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	env.Init()
+	if second := sessionIDs(); second != first {
+		t.Errorf("session ID changed across a reset: %s, then %s", first, second)
+	}
+}
+
+func TestCheckpoint_ContentIDsSkipsReplayedTurns(t *testing.T) {
+	env := NewTestEnv(t)
+	gitCommit(t, env.RepoDir, "initial")
+	if _, stderr, err := env.RunCLI("init", "--content-ids"); err != nil {
+		t.Fatalf("init --content-ids: %v (stderr: %s)", err, stderr)
+	}
+
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	// Resuming the conversation writes a new session file that replays it.
+	resumed := strings.Replace(testSessionJSONL, "test-session-001", "test-session-003", 1) +
+		`{"type":"user","parentMessageId":"m8","isSidechain":false,"message":{"role":"user","content":[{"type":"text","text":"now add a test for login"}]},"timestamp":"2026-02-25T12:00:00Z"}` + "\n"
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session3.jsonl", resumed)
+	defer cleanup2()
+	gitCommit(t, env.RepoDir, "add login test")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	// Only the new turn is stored, at its place in the resumed transcript.
+	assertQueryContains(t, env,
+		"SELECT count(*) AS n, min(t.turn_index) = (SELECT count(*) FROM turns t1 JOIN sessions s1 ON s1.id = t1.session_id WHERE s1.source_session_id = 'test-session-001') AS at_end, any_value(t.content) AS content FROM turns t JOIN sessions s ON s.id = t.session_id WHERE s.source_session_id = 'test-session-003'",
+		`{"at_end":true,"content":"now add a test for login","n":1}`)
+}
+
+func TestCheckpoint_E2E_SessionDir(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// withCWD records cwd on the transcript's first line, as Claude Code does on every line.
+	withCWD := func(jsonl, cwd string) string {
+		return strings.Replace(jsonl, `"timestamp":`, `"cwd":"`+cwd+`","timestamp":`, 1)
+	}
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", withCWD(testSessionJSONL, filepath.Join(env.RepoDir, "services", "api")))
+	defer cleanup1()
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", withCWD(testSessionJSONL2, env.RepoDir))
+	defer cleanup2()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT list(cwd ORDER BY cwd) AS dirs FROM sessions", `"dirs":[".","services/api"]`)
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	for _, tt := range []struct {
+		dir  string
+		want int
+	}{
+		{"services", 1},
+		{"services/api/", 1},
+		{"services/ap", 0},
+		{"web", 0},
+	} {
+		stdout, _, err := env.RunCLI("--dir", tt.dir)
+		if err != nil {
+			t.Fatalf("recall --dir %s: %v", tt.dir, err)
+		}
+		var output struct {
+			Total   int               `json:"total"`
+			Filters map[string]string `json:"filters"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		if output.Total != tt.want {
+			t.Errorf("--dir %s: got %d results, want %d", tt.dir, output.Total, tt.want)
+		}
+		if tt.dir == "services/api/" && output.Filters["dir"] != "services/api" {
+			t.Errorf("filters.dir = %q, want services/api", output.Filters["dir"])
+		}
+	}
+
+	if _, _, err := env.RunCLI("--dir", "../elsewhere"); err == nil {
+		t.Error("--dir outside the repo should fail")
+	}
+}
+
+func TestCheckpoint_SessionDirFlag(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "session1.jsonl"), []byte(testSessionJSONL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := env.RunCLI("checkpoint", "--session-dir", dir)
+	if err != nil {
+		t.Fatalf("checkpoint --session-dir: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("expected '1 session(s) captured', got: %q", stderr)
+	}
+
+	if _, _, err := env.RunCLI("checkpoint", "--session-dir", filepath.Join(dir, "missing")); err == nil {
+		t.Error("checkpoint with a missing --session-dir should fail")
+	}
+}
+
+func TestCheckpoint_ChatFormat(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// A chat transcript next to a Claude Code one; auto detects each.
+	dir := t.TempDir()
+	chat := `{"id":"chat-001","git_branch":"main","messages":[
+  {"role":"user","content":"why is the retry budget exhausted?"},
+  {"role":"assistant","content":"The backoff never resets.","tool_calls":[{"id":"c1","type":"function","function":{"name":"Edit","arguments":"{\"file_path\":\"retry.go\"}"}}]}
+]}`
+	if err := os.WriteFile(filepath.Join(dir, "chat.json"), []byte(chat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "claude.jsonl"), []byte(testSessionJSONL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := env.RunCLI("checkpoint", "--session-dir", dir)
+	if err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "2 session(s) captured") {
+		t.Errorf("expected '2 session(s) captured', got: %q", stderr)
+	}
+	assertQueryContains(t, env, "SELECT source_session_id FROM sessions WHERE source_session_id = 'chat-001'", "chat-001")
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns WHERE content LIKE '%retry budget%' AND role = 'human'", `"n":1`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM tool_calls WHERE tool = 'Edit' AND path LIKE '%retry.go'", `"n":1`)
+
+	// A named format reads only its own files.
+	dir2 := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir2, "chat.json"), []byte(strings.Replace(chat, "chat-001", "chat-002", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir2, "claude.jsonl"), []byte(testSessionJSONL2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := env.RunCLI("checkpoint", "--session-dir", dir2, "--format", "openai-chat"); err != nil || !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("checkpoint --format openai-chat: err %v, stderr %q; want one session", err, stderr)
+	}
+
+	if _, _, err := env.RunCLI("checkpoint", "--format", "openai-chat"); err == nil {
+		t.Error("--format openai-chat without --session-dir or --file should fail")
+	}
+	if _, _, err := env.RunCLI("checkpoint", "--format", "gemini"); err == nil {
+		t.Error("an unknown --format should fail")
+	}
+}
+
+func TestCheckpoint_FileFlag(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// An exported transcript outside any session directory, next to one
+	// that must not be picked up.
+	dir := t.TempDir()
+	transcript := filepath.Join(dir, "exported.jsonl")
+	if err := os.WriteFile(transcript, []byte(testSessionJSONL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.jsonl"), []byte(testSessionJSONL2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := env.RunCLI("checkpoint", "--file", transcript)
+	if err != nil {
+		t.Fatalf("checkpoint --file: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("expected '1 session(s) captured', got: %q", stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":1`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns WHERE content LIKE '%auth bug in login.go%'", `"n":1`)
+
+	// The same content under another name is deduplicated by hash.
+	copied := filepath.Join(dir, "copy.jsonl")
+	if err := os.WriteFile(copied, []byte(testSessionJSONL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := env.RunCLI("checkpoint", "--file", copied); err != nil || strings.Contains(stderr, "captured") {
+		t.Errorf("re-capturing the same transcript: err %v, stderr %q", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":1`)
+
+	if _, _, err := env.RunCLI("checkpoint", "--file", filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Error("checkpoint with a missing --file should fail")
+	}
+	if _, _, err := env.RunCLI("checkpoint", "--file", transcript, "--session-dir", dir); err == nil {
+		t.Error("--file with --session-dir should fail")
+	}
+}
+
+func TestCheckpoint_SessionDirByCWD(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// The session directory is not named after the repo path, but its
+	// transcript ran in the repo.
+	dir := filepath.Join(os.Getenv("CLAUDE_CONFIG_DIR"), "projects", "-old-name-of-the-repo")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	jsonl := strings.Replace(testSessionJSONL, `"timestamp":`, `"cwd":"`+env.RepoDir+`","timestamp":`, 1)
+	if err := os.WriteFile(filepath.Join(dir, "session1.jsonl"), []byte(jsonl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run finds the directory but does not remember it.
+	_, stderr, err := env.RunCLI("checkpoint", "--dry-run")
+	if err != nil {
+		t.Fatalf("checkpoint --dry-run: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, `"total_sessions": 1`) {
+		t.Errorf("expected 1 session in the dry run, got: %q", stderr)
+	}
+	if out, err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.sessionDir").Output(); err == nil {
+		t.Errorf("rekal.sessionDir should be unset after --dry-run, got %q", out)
+	}
+
+	_, stderr, err = env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("expected '1 session(s) captured', got: %q", stderr)
+	}
+
+	out, err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.sessionDir").Output()
+	if err != nil || strings.TrimSpace(string(out)) != dir {
+		t.Errorf("rekal.sessionDir = %q (err: %v), want %q", out, err, dir)
+	}
+
+	// clean forgets the mapping.
+	if _, _, err := env.RunCLI("clean"); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	if out, err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.sessionDir").Output(); err == nil {
+		t.Errorf("rekal.sessionDir should be unset after clean, got %q", out)
+	}
+}
+
+func TestCheckpoint_Incremental(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	// First checkpoint with session1.
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	_, stderr, err := env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint 1: %v", err)
+	}
+	if !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("expected '1 session(s) captured', got: %q", stderr)
+	}
+
+	// Second checkpoint — same file unchanged, should skip.
+	gitCommit(t, env.RepoDir, "empty commit")
+	_, stderr2, err := env.RunCLI("checkpoint")
+	if err != nil {
+		t.Fatalf("checkpoint 2: %v", err)
+	}
+	if strings.Contains(stderr2, "session(s) captured") {
+		t.Error("incremental checkpoint should skip unchanged files")
+	}
+
+	// Verify checkpoint_state table has an entry.
+	assertQueryContains(t, env, "SELECT count(*) as n FROM checkpoint_state", `"n":1`)
+}
+
+func TestCheckpoint_ConcurrentProcessesSerialize(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	const sessions = 6
+	for i := 0; i < sessions; i++ {
+		content := strings.ReplaceAll(testSessionJSONL, "test-session-001", fmt.Sprintf("concurrent-%d", i))
+		content = strings.ReplaceAll(content, "fix the auth bug", fmt.Sprintf("fix the auth bug, take %d", i))
+		defer writeSessionFile(t, env.RepoDir, fmt.Sprintf("concurrent-%d.jsonl", i), content)()
+	}
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+
+	// A manual checkpoint races two from post-commit hooks on the data DB.
+	var runs []*exec.Cmd
+	var outs []*bytes.Buffer
+	for _, args := range [][]string{{"checkpoint"}, {"checkpoint", "--quiet"}, {"checkpoint"}} {
+		cmd, out := env.StartCLI(args...)
+		runs = append(runs, cmd)
+		outs = append(outs, out)
+	}
+	for i, cmd := range runs {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("checkpoint process %d: %v\n%s", i, err, outs[i])
+		}
+	}
+
+	// Every session is captured once, by whichever process got there first.
+	assertQueryContains(t, env, "SELECT count(*) AS n, count(DISTINCT id) AS d FROM sessions", fmt.Sprintf(`"d":%d,"n":%d`, sessions, sessions))
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoints", `"n":1`)
+	if _, _, err := env.RunCLI("verify"); err != nil {
+		t.Errorf("verify after concurrent checkpoints: %v", err)
+	}
+
+	// sync --self's import into the data DB takes its turn too.
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	for i := sessions; i < 2*sessions; i++ {
+		content := strings.ReplaceAll(testSessionJSONL, "test-session-001", fmt.Sprintf("concurrent-%d", i))
+		content = strings.ReplaceAll(content, "fix the auth bug", fmt.Sprintf("fix the auth bug, take %d", i))
+		defer writeSessionFile(t, env.RepoDir, fmt.Sprintf("concurrent-%d.jsonl", i), content)()
+	}
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return errNope }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "break auth")
+
+	runs, outs = nil, nil
+	for _, args := range [][]string{{"sync", "--self"}, {"checkpoint", "--quiet"}, {"sync", "--self"}, {"checkpoint"}} {
+		cmd, out := env.StartCLI(args...)
+		runs = append(runs, cmd)
+		outs = append(outs, out)
+	}
+	for i, cmd := range runs {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("process %d: %v\n%s", i, err, outs[i])
+		}
 	}
+	assertQueryContains(t, env, "SELECT count(*) AS n, count(DISTINCT id) AS d FROM sessions", fmt.Sprintf(`"d":%d,"n":%d`, 2*sessions, 2*sessions))
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoints", `"n":2`)
 }
 
-func TestCheckpoint_Incremental(t *testing.T) {
+// largeSessionJSONL returns a transcript of n user/assistant exchanges, each
+// assistant turn reading one file.
+func largeSessionJSONL(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"type":"summary","sessionId":"large-session"}` + "\n")
+	for i := 0; i < n; i++ {
+		ts := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+		fmt.Fprintf(&b, `{"type":"user","message":{"role":"user","content":[{"type":"text","text":"step %d: check the retry budget"}]},"timestamp":%q,"gitBranch":"main"}`+"\n", i, ts)
+		fmt.Fprintf(&b, `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Reading file %d."},{"type":"tool_use","id":"tu-%d","name":"Read","input":{"file_path":"src/f%d.go"}}]},"timestamp":%q}`+"\n", i, i, i, ts)
+	}
+	return b.String()
+}
+
+func TestCheckpoint_LargeSessionCountsThroughImport(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
-		t.Fatal(err)
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
 	}
+
+	const exchanges = 250
+	defer writeSessionFile(t, env.RepoDir, "large.jsonl", largeSessionJSONL(exchanges))()
 	gitCommit(t, env.RepoDir, "initial")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	wantTurns := fmt.Sprintf(`"n":%d`, 2*exchanges)
+	wantCalls := fmt.Sprintf(`"n":%d`, exchanges)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns", wantTurns)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM tool_calls", wantCalls)
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
 
-	// First checkpoint with session1.
-	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
-	defer cleanup1()
-	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { return nil }\n"), 0o644); err != nil {
-		t.Fatal(err)
+	clone := func(email string) *TestEnv {
+		t.Helper()
+		dir := t.TempDir()
+		dir, _ = filepath.EvalSymlinks(dir)
+		if err := exec.Command("git", "clone", bareDir, dir).Run(); err != nil {
+			t.Fatalf("git clone: %v", err)
+		}
+		for _, kv := range [][2]string{{"user.email", email}, {"user.name", "Test User"}} {
+			exec.Command("git", "-C", dir, "config", kv[0], kv[1]).Run()
+		}
+		env := NewTestEnvAt(t, dir)
+		if _, stderr, err := env.RunCLI("init"); err != nil {
+			t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+		}
+		return env
 	}
-	gitCommit(t, env.RepoDir, "fix auth bug")
 
-	_, stderr, err := env.RunCLI("checkpoint")
+	// The same user's clone imports the branch into its data DB.
+	self := clone("test@rekal.dev")
+	assertQueryContains(t, self, "SELECT count(*) AS n FROM turns", wantTurns)
+	assertQueryContains(t, self, "SELECT count(*) AS n FROM tool_calls", wantCalls)
+
+	// A teammate's sync imports it into the index.
+	mate := clone("mate@rekal.dev")
+	if _, stderr, err := mate.RunCLI("sync"); err != nil {
+		t.Fatalf("sync (teammate): %v (stderr: %s)", err, stderr)
+	}
+	stdout, _, err := mate.RunCLI("query", "--index", "SELECT count(*) AS n, count(DISTINCT session_id) AS s FROM turns_ft")
 	if err != nil {
-		t.Fatalf("checkpoint 1: %v", err)
+		t.Fatalf("query --index: %v", err)
 	}
-	if !strings.Contains(stderr, "1 session(s) captured") {
-		t.Errorf("expected '1 session(s) captured', got: %q", stderr)
+	if !strings.Contains(stdout, wantTurns) || !strings.Contains(stdout, `"s":1`) {
+		t.Errorf("teammate index turns: got %s, want %s from one session", stdout, wantTurns)
 	}
+}
 
-	// Second checkpoint — same file unchanged, should skip.
-	gitCommit(t, env.RepoDir, "empty commit")
-	_, stderr2, err := env.RunCLI("checkpoint")
+func TestCheckpoint_FailedSessionLeavesNoRows(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// Recreate turns with a constraint the 151st exchange's user turn
+	// (turn 300) violates.
+	dataDB, err := db.OpenData(env.RepoDir)
 	if err != nil {
-		t.Fatalf("checkpoint 2: %v", err)
+		t.Fatalf("open data DB: %v", err)
+	}
+	for _, stmt := range []string{
+		"DROP TABLE turns",
+		`CREATE TABLE turns (
+			id           VARCHAR PRIMARY KEY,
+			session_id   VARCHAR NOT NULL,
+			turn_index   INTEGER NOT NULL,
+			role         VARCHAR NOT NULL,
+			content      VARCHAR NOT NULL CHECK (content NOT LIKE '%poison%'),
+			ts           TIMESTAMP,
+			content_hash VARCHAR
+		)`,
+	} {
+		if _, err := dataDB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
 	}
-	if strings.Contains(stderr2, "session(s) captured") {
-		t.Error("incremental checkpoint should skip unchanged files")
+	dataDB.Close()
+
+	transcript := largeSessionJSONL(250)
+	poisoned := strings.Replace(transcript, "step 150:", "poison 150:", 1)
+	defer writeSessionFile(t, env.RepoDir, "large.jsonl", poisoned)()
+	defer writeSessionFile(t, env.RepoDir, "good.jsonl", testSessionJSONL)()
+	gitCommit(t, env.RepoDir, "initial")
+
+	// The failed insert fails the checkpoint and rolls back the whole run,
+	// the good session included.
+	_, _, err = env.RunCLI("checkpoint")
+	if err == nil || !strings.Contains(err.Error(), "large.jsonl") {
+		t.Fatalf("checkpoint: expected an error naming large.jsonl, got %v", err)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoints", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM tool_calls", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoint_state", `"n":0`)
+
+	// Uncached, both transcripts are tried again and captured whole once
+	// the large one no longer fails.
+	writeSessionFile(t, env.RepoDir, "large.jsonl", transcript)
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("second checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":2`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoint_sessions", `"n":2`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns WHERE content LIKE 'step %'", `"n":250`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM tool_calls WHERE path LIKE '%src/f%'", `"n":250`)
+}
+
+func TestCheckpoint_FailedCheckpointLeavesNoSessions(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// Recreate files_touched with a constraint the commit's file violates,
+	// so the checkpoint block fails after the session inserts.
+	recreate := func(check string) {
+		t.Helper()
+		dataDB, err := db.OpenData(env.RepoDir)
+		if err != nil {
+			t.Fatalf("open data DB: %v", err)
+		}
+		defer dataDB.Close()
+		for _, stmt := range []string{
+			"DROP TABLE files_touched",
+			`CREATE TABLE files_touched (
+				id            VARCHAR PRIMARY KEY,
+				checkpoint_id VARCHAR NOT NULL REFERENCES checkpoints(id),
+				file_path     VARCHAR NOT NULL ` + check + `,
+				change_type   VARCHAR NOT NULL,
+				diff          VARCHAR
+			)`,
+		} {
+			if _, err := dataDB.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
 	}
+	recreate("CHECK (file_path NOT LIKE '%poison%')")
 
-	// Verify checkpoint_state table has an entry.
-	assertQueryContains(t, env, "SELECT count(*) as n FROM checkpoint_state", `"n":1`)
+	// files_touched comes from git diff HEAD~1 HEAD, so commit twice.
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "poison.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)()
+	gitCommit(t, env.RepoDir, "add poison.go")
+
+	if _, _, err := env.RunCLI("checkpoint"); err == nil {
+		t.Fatal("checkpoint should fail when the checkpoint block fails")
+	}
+	// No session is left cached and captured without its checkpoint.
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoint_state", `"n":0`)
+
+	recreate("")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("second checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoint_sessions", `"n":1`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM checkpoints WHERE exported = false", `"n":1`)
 }
 
 func TestPush_NoNewCheckpoints(t *testing.T) {
@@ -689,3 +2107,333 @@ func assertQueryContains(t *testing.T, env *TestEnv, sql, expected string) {
 		t.Errorf("query %q: expected %q in output, got: %q", sql, expected, stdout)
 	}
 }
+
+func TestCheckpoint_ResumedSessionThread(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	original := `{"uuid":"a1","sessionId":"claude-1","timestamp":"2026-02-25T10:00:00Z","type":"user","message":{"role":"user","content":"Add a login page"}}
+{"uuid":"a2","sessionId":"claude-1","timestamp":"2026-02-25T10:00:05Z","type":"assistant","message":{"role":"assistant","content":"Added the login page."}}
+`
+	resumed := original + `{"uuid":"b1","sessionId":"claude-2","timestamp":"2026-02-26T09:00:00Z","type":"user","message":{"role":"user","content":"Now add a logout button"}}
+{"uuid":"b2","sessionId":"claude-2","timestamp":"2026-02-26T09:00:05Z","type":"assistant","message":{"role":"assistant","content":"Added the logout button."}}
+`
+	writeSessionFile(t, env.RepoDir, "claude-1.jsonl", original)
+	writeSessionFile(t, env.RepoDir, "claude-2.jsonl", resumed)
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	var parentID, childID string
+	if err := dataDB.QueryRow("SELECT id FROM sessions WHERE source_session_id = 'claude-1'").Scan(&parentID); err != nil {
+		t.Fatalf("original session: %v", err)
+	}
+	if err := dataDB.QueryRow("SELECT id FROM sessions WHERE source_session_id = 'claude-2'").Scan(&childID); err != nil {
+		t.Fatalf("resumed session: %v", err)
+	}
+	dataDB.Close()
+
+	stdout, _, err := env.RunCLI("query", "--session", childID)
+	if err != nil {
+		t.Fatalf("query --session: %v", err)
+	}
+	var sess struct {
+		Parent string `json:"parent_session_id"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &sess); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if sess.Parent != parentID {
+		t.Errorf("parent_session_id = %q, want %q", sess.Parent, parentID)
+	}
+
+	stdout, _, err = env.RunCLI("query", "--thread", childID)
+	if err != nil {
+		t.Fatalf("query --thread: %v", err)
+	}
+	var thread struct {
+		Sessions []string `json:"sessions"`
+		Turns    []struct {
+			SessionID string `json:"session_id"`
+			Content   string `json:"content"`
+		} `json:"turns"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &thread); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if len(thread.Sessions) != 2 || thread.Sessions[0] != parentID || thread.Sessions[1] != childID {
+		t.Errorf("sessions = %v, want [%s %s]", thread.Sessions, parentID, childID)
+	}
+	var got []string
+	for _, turn := range thread.Turns {
+		got = append(got, turn.Content)
+	}
+	want := []string{"Add a login page", "Added the login page.", "Now add a logout button", "Added the logout button."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("thread turns = %q, want %q (carried-over turns once)", got, want)
+	}
+	if len(thread.Turns) == 4 && thread.Turns[2].SessionID != childID {
+		t.Errorf("new turns should belong to the resumed session, got %s", thread.Turns[2].SessionID)
+	}
+}
+
+func TestCheckpoint_DryRun(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+	writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+
+	_, stderr, err := env.RunCLI("checkpoint", "--dry-run")
+	if err != nil {
+		t.Fatalf("checkpoint --dry-run: %v (stderr: %s)", err, stderr)
+	}
+	var report struct {
+		Sessions []struct {
+			SourceSessionID string `json:"source_session_id"`
+			Turns           int    `json:"turns"`
+			ToolCalls       int    `json:"tool_calls"`
+		} `json:"sessions"`
+		TotalSessions int `json:"total_sessions"`
+	}
+	if err := json.Unmarshal([]byte(stderr), &report); err != nil {
+		t.Fatalf("expected JSON on stderr: %v\nstderr: %s", err, stderr)
+	}
+	if report.TotalSessions != 1 || len(report.Sessions) != 1 {
+		t.Fatalf("expected 1 session in the report, got: %s", stderr)
+	}
+	if s := report.Sessions[0]; s.SourceSessionID != "test-session-001" || s.Turns == 0 || s.ToolCalls == 0 {
+		t.Errorf("unexpected session entry: %+v", s)
+	}
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for _, table := range []string{"sessions", "turns", "tool_calls", "checkpoints", "checkpoint_state"} {
+		var n int
+		if err := dataDB.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after --dry-run, want 0", table, n)
+		}
+	}
+	dataDB.Close()
+
+	// A real checkpoint afterwards still captures the session.
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil || !strings.Contains(stderr, "1 session(s) captured") {
+		t.Errorf("checkpoint after dry run: err=%v stderr=%q", err, stderr)
+	}
+}
+
+// replaceBranchFile commits data as name on top of branch, leaving the rest
+// of its tree as-is.
+func replaceBranchFile(t *testing.T, dir, branch, name string, data []byte) {
+	t.Helper()
+	hashCmd := exec.Command("git", "-C", dir, "hash-object", "-w", "--stdin")
+	hashCmd.Stdin = strings.NewReader(string(data))
+	blob, err := hashCmd.Output()
+	if err != nil {
+		t.Fatalf("git hash-object: %v", err)
+	}
+	tree, err := exec.Command("git", "-C", dir, "ls-tree", branch).Output()
+	if err != nil {
+		t.Fatalf("git ls-tree: %v", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(tree)), "\n") {
+		if strings.HasSuffix(line, "\t"+name) {
+			line = "100644 blob " + strings.TrimSpace(string(blob)) + "\t" + name
+		}
+		lines = append(lines, line)
+	}
+	mktree := exec.Command("git", "-C", dir, "mktree")
+	mktree.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	treeHash, err := mktree.Output()
+	if err != nil {
+		t.Fatalf("git mktree: %v", err)
+	}
+	commit, err := exec.Command("git", "-C", dir, "commit-tree", strings.TrimSpace(string(treeHash)), "-p", branch, "-m", "corrupt "+name).Output()
+	if err != nil {
+		t.Fatalf("git commit-tree: %v", err)
+	}
+	if err := exec.Command("git", "-C", dir, "update-ref", "refs/heads/"+branch, strings.TrimSpace(string(commit))).Run(); err != nil {
+		t.Fatalf("git update-ref: %v", err)
+	}
+}
+
+func TestCodecTrainDict_PushAndTeamSync(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	gitCommit(t, env.RepoDir, "initial")
+
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	// Training needs codec.MinTrainSessions sessions.
+	for i := 0; i < codec.MinTrainSessions; i++ {
+		id := fmt.Sprintf("test-session-1%02d", i)
+		cleanup := writeSessionFile(t, env.RepoDir, id+".jsonl", strings.Replace(testSessionJSONL, "test-session-001", id, 1))
+		defer cleanup()
+	}
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	_, stderr, err := env.RunCLI("codec", "train-dict")
+	if err != nil {
+		t.Fatalf("codec train-dict: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "wrote dictionary") {
+		t.Fatalf("expected the trained dictionary to be kept, got: %q", stderr)
+	}
+	if _, err := os.Stat(filepath.Join(env.RepoDir, ".rekal", "zstd.dict")); err != nil {
+		t.Fatalf("zstd.dict: %v", err)
+	}
+
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	branch := "rekal/test@rekal.dev"
+	dicts, err := codec.LoadTrainedDicts(gitShow(bareDir, branch, codec.TrainedDictsFile))
+	if err != nil || len(dicts) != 1 {
+		t.Fatalf("zstd.dicts on the pushed branch: %d dicts, %v; want 1", len(dicts), err)
+	}
+	if _, stderr, err := env.RunCLI("verify"); err != nil {
+		t.Fatalf("verify: %v (stderr: %s)", err, stderr)
+	}
+
+	// A teammate's clone decodes the frames with the dictionary from the branch.
+	otherDir := t.TempDir()
+	otherDir, _ = filepath.EvalSymlinks(otherDir)
+	if err := exec.Command("git", "clone", bareDir, otherDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{
+		{"user.email", "other@rekal.dev"},
+		{"user.name", "Other User"},
+	} {
+		if err := exec.Command("git", "-C", otherDir, "config", kv[0], kv[1]).Run(); err != nil {
+			t.Fatalf("git config: %v", err)
+		}
+	}
+	other := NewTestEnvAt(t, otherDir)
+	other.Init()
+	if _, stderr, err := other.RunCLI("sync"); err != nil {
+		t.Fatalf("teammate sync: %v (stderr: %s)", err, stderr)
+	}
+	stdout, _, err := other.RunCLI("query", "--index",
+		`SELECT count(*) AS n FROM session_facets WHERE user_email = 'test@rekal.dev'`)
+	if want := fmt.Sprintf(`"n":%d`, codec.MinTrainSessions); err != nil || !strings.Contains(stdout, want) {
+		t.Errorf("teammate index should hold every session: %q, %v", stdout, err)
+	}
+}
+
+func TestVerify_DetectsMissingDictEntry(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	gitCommit(t, env.RepoDir, "initial")
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	_, stderr, err := env.RunCLI("verify")
+	if err != nil {
+		t.Fatalf("verify on a fresh push: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "ok: ") || !strings.Contains(stderr, "1 session(s), 1 checkpoint(s)") {
+		t.Errorf("verify summary: got %q", stderr)
+	}
+
+	// Drop the last path entry, so the frame that interned it dangles.
+	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	if len(dict.Paths) == 0 {
+		t.Fatal("dict has no path entries")
+	}
+	dict.Paths = dict.Paths[:len(dict.Paths)-1]
+	replaceBranchFile(t, env.RepoDir, branch, "dict.bin", dict.Encode())
+
+	_, stderr, err = env.RunCLI("verify")
+	if err == nil {
+		t.Fatal("verify should fail on a dict missing a referenced entry")
+	}
+	msg := err.Error()
+	for _, want := range []string{"rekal.body", "frame", "not in dict.bin", "paths entries"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("verify error %q should mention %q (stderr: %s)", msg, want, stderr)
+		}
+	}
+}
+
+func TestVerify_DetectsExportedCheckpointMissingFromBranch(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	gitCommit(t, env.RepoDir, "initial")
+	addBareOrigin(t, env)
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	// An empty body is well-formed, but loses the exported checkpoint.
+	branch := "rekal/test@rekal.dev"
+	replaceBranchFile(t, env.RepoDir, branch, "rekal.body", codec.NewBody())
+
+	_, stderr, err := env.RunCLI("verify")
+	if err == nil {
+		t.Fatal("verify should fail when an exported checkpoint has no frame")
+	}
+	if !strings.Contains(err.Error(), "exported in the data DB but has no frame on the branch") {
+		t.Errorf("verify error: got %v (stderr: %s)", err, stderr)
+	}
+
+	// Another ref is not checked against the data DB.
+	if _, stderr, err := env.RunCLI("verify", "--branch", "origin/"+branch); err != nil {
+		t.Errorf("verify origin branch: %v (stderr: %s)", err, stderr)
+	}
+}
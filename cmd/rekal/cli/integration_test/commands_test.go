@@ -4,6 +4,8 @@ package integration
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,8 +13,21 @@ import (
 	"testing"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 )
 
+// runAsCLIEnv makes the test binary run as rekal instead of the tests, for
+// StartCLI.
+const runAsCLIEnv = "REKAL_TEST_RUN_AS_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runAsCLIEnv) == "1" {
+		cli.Run()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
 // TestEnv provides an isolated git repo for integration testing.
 type TestEnv struct {
 	T       *testing.T
@@ -59,9 +74,16 @@ func NewTestEnvAt(t *testing.T, dir string) *TestEnv {
 // RunCLI executes rekal with the given args from the test repo directory.
 // Returns stdout, stderr, and error.
 func (env *TestEnv) RunCLI(args ...string) (stdout, stderr string, err error) {
+	env.T.Helper()
+	return env.RunCLIWithStdin("", args...)
+}
+
+// RunCLIWithStdin is RunCLI with stdin read from the given string.
+func (env *TestEnv) RunCLIWithStdin(stdin string, args ...string) (stdout, stderr string, err error) {
 	env.T.Helper()
 	rootCmd := cli.NewRootCmd()
 	rootCmd.SetArgs(args)
+	rootCmd.SetIn(strings.NewReader(stdin))
 
 	outBuf := &bytes.Buffer{}
 	errBuf := &bytes.Buffer{}
@@ -77,6 +99,26 @@ func (env *TestEnv) RunCLI(args ...string) (stdout, stderr string, err error) {
 	return outBuf.String(), errBuf.String(), execErr
 }
 
+// StartCLI starts rekal with args as a separate process in the repo dir,
+// for tests that need several rekal processes at once. The caller waits on
+// the returned command; its combined output is in out.
+func (env *TestEnv) StartCLI(args ...string) (cmd *exec.Cmd, out *bytes.Buffer) {
+	env.T.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		env.T.Fatal(err)
+	}
+	out = &bytes.Buffer{}
+	cmd = exec.Command(self, args...)
+	cmd.Dir = env.RepoDir
+	cmd.Env = append(os.Environ(), runAsCLIEnv+"=1")
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		env.T.Fatalf("start rekal %v: %v", args, err)
+	}
+	return cmd, out
+}
+
 // Init runs `rekal init` and fails if it errors.
 func (env *TestEnv) Init() {
 	env.T.Helper()
@@ -139,8 +181,132 @@ func TestInit_InstallsHooks(t *testing.T) {
 	if !strings.Contains(postCommit, "# managed by rekal") {
 		t.Error("post-commit should contain rekal marker")
 	}
-	if !strings.Contains(postCommit, "rekal checkpoint") {
-		t.Error("post-commit should call rekal checkpoint")
+	if !strings.Contains(postCommit, "rekal checkpoint --quiet") {
+		t.Error("post-commit should call rekal checkpoint --quiet")
+	}
+	if prePush := env.ReadFile(".git/hooks/pre-push"); !strings.Contains(prePush, "rekal push --quiet") {
+		t.Error("pre-push should call rekal push --quiet")
+	}
+}
+
+func TestInit_WorktreeUsesCommonHooksDir(t *testing.T) {
+	env := NewTestEnv(t)
+	if err := exec.Command("git", "-C", env.RepoDir, "commit", "--allow-empty", "-m", "initial").Run(); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	if out, err := exec.Command("git", "-C", env.RepoDir, "worktree", "add", "-b", "feature", wtDir).CombinedOutput(); err != nil {
+		t.Fatalf("git worktree add: %v\n%s", err, out)
+	}
+	wtDir, err := filepath.EvalSymlinks(wtDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := NewTestEnvAt(t, wtDir)
+
+	// In a linked worktree .git is a file; the hooks git runs live in the
+	// main repository's .git/hooks.
+	wt.Init()
+	if !wt.FileExists(".rekal/data.db") {
+		t.Error("worktree should get its own .rekal/")
+	}
+	if postCommit := env.ReadFile(".git/hooks/post-commit"); !strings.Contains(postCommit, "rekal checkpoint --quiet") {
+		t.Errorf("post-commit should be installed in the common hooks dir, got: %q", postCommit)
+	}
+	if !env.FileExists(".git/hooks/pre-push") {
+		t.Error("pre-push should be installed in the common hooks dir")
+	}
+
+	// Cleaning one worktree keeps the shared hooks while another still uses
+	// rekal; cleaning the last one removes them.
+	env.Init()
+	if _, _, err := wt.RunCLI("clean"); err != nil {
+		t.Fatalf("clean worktree: %v", err)
+	}
+	if wt.FileExists(".rekal") {
+		t.Error("clean should remove the worktree's .rekal/")
+	}
+	if !env.FileExists(".git/hooks/post-commit") {
+		t.Error("clean in a worktree should keep hooks the main worktree still needs")
+	}
+	if _, _, err := env.RunCLI("clean"); err != nil {
+		t.Fatalf("clean main worktree: %v", err)
+	}
+	if env.FileExists(".git/hooks/post-commit") {
+		t.Error("cleaning the last initialized worktree should remove the hooks")
+	}
+}
+
+func TestInit_HonorsCoreHooksPath(t *testing.T) {
+	env := NewTestEnv(t)
+	hooksDir := t.TempDir()
+	if err := exec.Command("git", "-C", env.RepoDir, "config", "core.hooksPath", hooksDir).Run(); err != nil {
+		t.Fatalf("git config core.hooksPath: %v", err)
+	}
+	// A framework's own non-shell hook is kept, with a warning on how to
+	// chain rekal.
+	husky := "#!/usr/bin/env node\nrequire('lint-staged')\n"
+	if err := os.WriteFile(filepath.Join(hooksDir, "pre-push"), []byte(husky), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := env.RunCLI("init")
+	if err != nil {
+		t.Fatalf("rekal init: %v", err)
+	}
+
+	postCommit, err := os.ReadFile(filepath.Join(hooksDir, "post-commit"))
+	if err != nil {
+		t.Fatalf("post-commit should be installed in core.hooksPath: %v", err)
+	}
+	if !strings.Contains(string(postCommit), "rekal checkpoint --quiet") {
+		t.Errorf("post-commit should call rekal checkpoint --quiet, got: %q", postCommit)
+	}
+	if env.FileExists(".git/hooks/post-commit") {
+		t.Error("post-commit should not be installed in .git/hooks when core.hooksPath is set")
+	}
+	if prePush, _ := os.ReadFile(filepath.Join(hooksDir, "pre-push")); string(prePush) != husky {
+		t.Errorf("existing pre-push should be left as is, got: %q", prePush)
+	}
+	if !strings.Contains(stderr, "pre-push is not a shell script") || !strings.Contains(stderr, "rekal push --quiet") {
+		t.Errorf("expected a warning about the existing pre-push hook, got: %q", stderr)
+	}
+
+	// The hooks directory is outside the repository and may be shared, so
+	// clean leaves it alone.
+	_, stderr, err = env.RunCLI("clean")
+	if err != nil {
+		t.Fatalf("rekal clean: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(hooksDir, "post-commit")); err != nil {
+		t.Errorf("clean should keep the post-commit hook in a shared core.hooksPath: %v", err)
+	}
+	if !strings.Contains(stderr, "outside this repository and may be shared") {
+		t.Errorf("expected a warning about the shared hooks directory, got: %q", stderr)
+	}
+}
+
+func TestClean_RepoLocalCoreHooksPath(t *testing.T) {
+	env := NewTestEnv(t)
+	if err := exec.Command("git", "-C", env.RepoDir, "config", "core.hooksPath", ".githooks").Run(); err != nil {
+		t.Fatalf("git config core.hooksPath: %v", err)
+	}
+	if _, _, err := env.RunCLI("init"); err != nil {
+		t.Fatalf("rekal init: %v", err)
+	}
+	if !env.FileExists(".githooks/post-commit") {
+		t.Fatal("post-commit should be installed in core.hooksPath")
+	}
+
+	_, stderr, err := env.RunCLI("clean")
+	if err != nil {
+		t.Fatalf("rekal clean: %v", err)
+	}
+	if env.FileExists(".githooks/post-commit") {
+		t.Error("clean should remove the post-commit hook from a core.hooksPath inside the repository")
+	}
+	if strings.Contains(stderr, "warning") {
+		t.Errorf("unexpected warning: %q", stderr)
 	}
 }
 
@@ -221,6 +387,75 @@ func TestClean_Idempotent(t *testing.T) {
 
 // --- Stub command tests ---
 
+func TestPrune_DropsOldSessions(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	// test-session-1 (10:00, checkpoint cp-1) is older than the cutoff;
+	// test-session-2 (11:00) is not.
+	const cutoff = "2026-02-25T10:30:00Z"
+	_, stderr, err := env.RunCLI("prune", "--before", cutoff, "--dry-run")
+	if err != nil {
+		t.Fatalf("prune --dry-run: %v\nstderr: %s", err, stderr)
+	}
+	var report struct {
+		Sessions []struct {
+			SessionID string `json:"session_id"`
+		} `json:"sessions"`
+		Checkpoints []string `json:"checkpoints"`
+	}
+	if err := json.Unmarshal([]byte(stderr), &report); err != nil {
+		t.Fatalf("dry run output is not JSON: %v\n%s", err, stderr)
+	}
+	if len(report.Sessions) != 1 || report.Sessions[0].SessionID != "test-session-1" {
+		t.Errorf("dry run sessions = %+v, want test-session-1", report.Sessions)
+	}
+	if fmt.Sprint(report.Checkpoints) != "[cp-1]" {
+		t.Errorf("dry run checkpoints = %v, want [cp-1]", report.Checkpoints)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":2`)
+
+	if _, stderr, err := env.RunCLI("prune", "--before", cutoff); err != nil {
+		t.Fatalf("prune: %v\nstderr: %s", err, stderr)
+	} else if !strings.Contains(stderr, "pruned 1 session(s), 1 checkpoint(s)") {
+		t.Errorf("expected a prune summary, got: %s", stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":1`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM turns WHERE session_id = 'test-session-1'", `"n":0`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM tool_calls WHERE session_id = 'test-session-1'", `"n":0`)
+	assertQueryContains(t, env, "SELECT string_agg(id, ',') AS ids FROM checkpoints", `"ids":"cp-2"`)
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM files_touched", `"n":0`)
+
+	// The index is rebuilt without the pruned session.
+	stdout, _, err := env.RunCLI("query", "--index", "SELECT string_agg(session_id, ',') AS ids FROM session_facets")
+	if err != nil {
+		t.Fatalf("query index: %v", err)
+	}
+	if !strings.Contains(stdout, `"ids":"test-session-2"`) {
+		t.Errorf("index should only hold test-session-2, got: %s", stdout)
+	}
+	stdout, _, err = env.RunCLI("JWT expiry")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if strings.Contains(stdout, "test-session-1") {
+		t.Errorf("recall still finds the pruned session: %s", stdout)
+	}
+
+	if _, stderr, err := env.RunCLI("prune", "--keep-last", "0"); err != nil {
+		t.Fatalf("prune --keep-last 0: %v\nstderr: %s", err, stderr)
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM sessions", `"n":0`)
+
+	if _, _, err := env.RunCLI("prune"); err == nil {
+		t.Error("prune without --before or --keep-last should fail")
+	}
+}
+
 func TestStubCommands_RequirePreconditions(t *testing.T) {
 	commands := []string{"checkpoint", "push", "index", "log", "sync"}
 
@@ -291,6 +526,153 @@ func TestQuery_ExecutesSQL(t *testing.T) {
 	}
 }
 
+func TestQuery_RejectsMutations(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	out := filepath.Join(env.RepoDir, "turns.csv")
+	for _, q := range []string{
+		"WITH x AS (SELECT id FROM sessions) DELETE FROM turns WHERE session_id IN (SELECT id FROM x)",
+		"/* harmless */ DELETE FROM sessions",
+		"-- note\nDROP TABLE turns",
+		"COPY (SELECT * FROM turns) TO '" + out + "'",
+		"SELECT 1; DROP TABLE turns",
+		"PRAGMA database_list",
+	} {
+		if _, _, err := env.RunCLI("query", "--", q); err == nil || !strings.Contains(err.Error(), "allowed") {
+			t.Errorf("query %q: got %v, want it rejected", q, err)
+		}
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("COPY ... TO wrote a file")
+	}
+	assertQueryContains(t, env, "SELECT count(*) AS n FROM duckdb_tables() WHERE table_name = 'turns'", `"n":1`)
+
+	// A CTE feeding a SELECT is fine.
+	assertQueryContains(t, env, "WITH t AS (SELECT 41 AS v) SELECT v + 1 AS n FROM t", `"n":42`)
+}
+
+func TestQuery_CountAndMeta(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	const q = "SELECT * FROM range(7) t(i) -- seven rows\n;"
+	stdout, stderr, err := env.RunCLI("query", "--count", q)
+	if err != nil {
+		t.Fatalf("query --count: %v", err)
+	}
+	streamed := strings.Count(stdout, "\n")
+	if streamed != 7 {
+		t.Fatalf("streamed %d rows, want 7:\n%s", streamed, stdout)
+	}
+	if !strings.Contains(stderr, fmt.Sprintf("%d rows", streamed)) {
+		t.Errorf("stderr should report %d rows, got: %q", streamed, stderr)
+	}
+
+	stdout, _, err = env.RunCLI("query", "--count", "--json", q)
+	if err != nil {
+		t.Fatalf("query --count --json: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	var last struct {
+		Meta struct {
+			Rows  int    `json:"rows"`
+			Total *int64 `json:"total"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("last line is not JSON: %v\n%s", err, stdout)
+	}
+	if last.Meta.Rows != len(lines)-1 {
+		t.Errorf("_meta.rows = %d, streamed %d", last.Meta.Rows, len(lines)-1)
+	}
+	if last.Meta.Total == nil || *last.Meta.Total != int64(len(lines)-1) {
+		t.Errorf("_meta.total = %v, want %d", last.Meta.Total, len(lines)-1)
+	}
+
+	if _, _, err := env.RunCLI("query", "--session", "foo", "--count"); err == nil {
+		t.Error("--count with --session should fail")
+	}
+}
+
+func TestQuery_FormatTableAndCSV(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	const q = "SELECT * FROM (VALUES (1, 'alice@example.com', NULL), (22, 'bo', 'feature/x')) t(n, email, branch) ORDER BY n"
+	stdout, _, err := env.RunCLI("query", "--format", "table", q)
+	if err != nil {
+		t.Fatalf("query --format table: %v", err)
+	}
+	want := "n   email              branch\n" +
+		"--  -----------------  ---------\n" +
+		"1   alice@example.com  NULL\n" +
+		"22  bo                 feature/x\n"
+	if stdout != want {
+		t.Errorf("table output:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	stdout, _, err = env.RunCLI("query", "--format", "csv", q)
+	if err != nil {
+		t.Fatalf("query --format csv: %v", err)
+	}
+	if want := "n,email,branch\n1,alice@example.com,\n22,bo,feature/x\n"; stdout != want {
+		t.Errorf("csv output = %q, want %q", stdout, want)
+	}
+
+	if _, _, err := env.RunCLI("query", "--format", "yaml", q); err == nil {
+		t.Error("an unknown --format should fail")
+	}
+	if _, _, err := env.RunCLI("query", "--format", "csv", "--json", q); err == nil {
+		t.Error("--json with --format csv should fail")
+	}
+}
+
+func TestQuery_MaxRowsAndTimeout(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	const q = "SELECT range AS n FROM range(50)"
+	stdout, stderr, err := env.RunCLI("query", "--max-rows", "10", q)
+	if err != nil {
+		t.Fatalf("query --max-rows: %v", err)
+	}
+	if lines := strings.Count(stdout, "\n"); lines != 10 {
+		t.Errorf("got %d rows, want 10:\n%s", lines, stdout)
+	}
+	if !strings.Contains(stderr, "result truncated to 10 rows") {
+		t.Errorf("stderr should note the truncation, got: %q", stderr)
+	}
+
+	stdout, _, err = env.RunCLI("query", "--max-rows", "10", "--json", q)
+	if err != nil {
+		t.Fatalf("query --max-rows --json: %v", err)
+	}
+	if !strings.Contains(stdout, `{"_meta":{"rows":10,"truncated":true}}`) {
+		t.Errorf("expected a truncated _meta line, got: %q", stdout)
+	}
+
+	// Exactly at the cap is not truncated; 0 lifts it.
+	_, stderr, err = env.RunCLI("query", "--max-rows", "50", q)
+	if err != nil || strings.Contains(stderr, "truncated") {
+		t.Errorf("50 rows under --max-rows 50: err=%v stderr=%q", err, stderr)
+	}
+	stdout, stderr, err = env.RunCLI("query", "--max-rows", "0", "SELECT * FROM range(20000)")
+	if err != nil || strings.Contains(stderr, "truncated") || strings.Count(stdout, "\n") != 20000 {
+		t.Errorf("--max-rows 0: err=%v stderr=%q rows=%d", err, stderr, strings.Count(stdout, "\n"))
+	}
+
+	_, _, err = env.RunCLI("query", "--timeout", "100ms",
+		"SELECT count(*) FROM range(100000000) a, range(100000000) b")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got: %v", err)
+	}
+
+	if _, _, err := env.RunCLI("query", "--max-rows", "-1", q); err == nil {
+		t.Error("a negative --max-rows should fail")
+	}
+}
+
 func TestRecall_ProducesJSON(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
@@ -314,3 +696,35 @@ func TestRecall_NoArgsShowsHelp(t *testing.T) {
 		t.Errorf("expected help output, got: %q", stdout)
 	}
 }
+
+func TestCompletions_SessionAndAuthorValues(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for _, s := range [][2]string{{"01SESSIONA", "alice@example.com"}, {"01SESSIONB", "bob@example.com"}, {"02OTHER", "alice@example.com"}} {
+		if err := db.InsertSession(dataDB, s[0], "", "hash-"+s[0], "human", "", s[1], "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	stdout, _, err := env.RunCLI("__complete", "query", "--session", "01")
+	if err != nil {
+		t.Fatalf("complete --session: %v", err)
+	}
+	if !strings.Contains(stdout, "01SESSIONA\n01SESSIONB\n") || strings.Contains(stdout, "02OTHER") {
+		t.Errorf("--session completions should list IDs with the typed prefix, got: %q", stdout)
+	}
+
+	stdout, _, err = env.RunCLI("__complete", "--author", "")
+	if err != nil {
+		t.Fatalf("complete --author: %v", err)
+	}
+	if !strings.Contains(stdout, "alice@example.com\nbob@example.com\n") {
+		t.Errorf("--author completions should list distinct emails, got: %q", stdout)
+	}
+}
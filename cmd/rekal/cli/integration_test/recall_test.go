@@ -4,10 +4,17 @@ package integration
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/nomic"
 )
 
 func TestIndex_Rebuild(t *testing.T) {
@@ -26,81 +33,1793 @@ func TestIndex_Rebuild(t *testing.T) {
 	if !strings.Contains(stderr, "index rebuilt") {
 		t.Errorf("expected 'index rebuilt' in stderr, got: %q", stderr)
 	}
+
+	// The LSA model is saved so recall can load it instead of rebuilding.
+	indexDB, err := db.OpenIndex(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open index db: %v", err)
+	}
+	defer indexDB.Close()
+	data, err := db.LoadLSAModel(indexDB, "lsa-v1")
+	if err != nil || data == nil {
+		t.Fatalf("expected stored LSA model, got %d bytes (err: %v)", len(data), err)
+	}
+	var model lsa.Model
+	if err := model.UnmarshalBinary(data); err != nil {
+		t.Fatalf("stored LSA model: %v", err)
+	}
+	if len(model.SessionIDs) != 2 {
+		t.Errorf("stored LSA model sessions: got %d, want 2", len(model.SessionIDs))
+	}
+}
+
+func TestQuery_IndexEmbeddingsAsNumberArray(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, stderr, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v\nstderr: %s", err, stderr)
+	}
+
+	stdout, stderr, err := env.RunCLI("query", "--index",
+		"SELECT s.session_id, e.embedding FROM session_facets s JOIN session_embeddings e USING (session_id) WHERE e.model = 'lsa-v1' ORDER BY s.session_id")
+	if err != nil {
+		t.Fatalf("query --index: %v\nstderr: %s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatal("expected embedding rows")
+	}
+	for _, line := range lines {
+		var row struct {
+			SessionID string    `json:"session_id"`
+			Embedding []float64 `json:"embedding"`
+		}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("embedding should be a JSON number array: %v\nrow: %s", err, line)
+		}
+		if row.SessionID == "" || len(row.Embedding) == 0 {
+			t.Errorf("expected session_id and a non-empty embedding, got: %s", line)
+		}
+	}
+}
+
+func TestIndex_Incremental(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, stderr, err := env.RunCLI("index"); err != nil || !strings.Contains(stderr, "index rebuilt") {
+		t.Fatalf("first index should build in full: %v\nstderr: %s", err, stderr)
+	}
+
+	// Mark an existing indexed turn. A full rebuild would restore it from
+	// the data DB; an incremental update must leave it alone.
+	indexDB, err := db.OpenIndex(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open index db: %v", err)
+	}
+	if _, err := indexDB.Exec("UPDATE turns_ft SET content = 'sentinel' WHERE id = 'turn-1'"); err != nil {
+		t.Fatalf("mark turn: %v", err)
+	}
+	indexDB.Close()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "test-session-3", "", "hash3", "human", "", "carol@example.com", "feature/cache", "", "2026-02-25T12:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-5", "test-session-3", 0, "human", "add a redis cache in front of the session store", "2026-02-25T12:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-6", "test-session-3", 1, "assistant", "I'll wrap the session store with a redis cache.", "2026-02-25T12:01:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	dataDB.Close()
+
+	_, stderr, err := env.RunCLI("index", "--incremental")
+	if err != nil {
+		t.Fatalf("index --incremental: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "index updated: 1 new session(s)") {
+		t.Errorf("expected 1 new session in stderr, got: %q", stderr)
+	}
+
+	assertIndex := func(sql, expected string) {
+		t.Helper()
+		stdout, _, err := env.RunCLI("query", "--index", sql)
+		if err != nil {
+			t.Fatalf("query %q: %v", sql, err)
+		}
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("query %q: expected %q in output, got: %q", sql, expected, stdout)
+		}
+	}
+	assertIndex("SELECT count(*) AS n FROM turns_ft", `"n":8`)
+	assertIndex("SELECT count(*) AS n FROM turns_ft WHERE session_id = 'test-session-3'", `"n":2`)
+	assertIndex("SELECT content FROM turns_ft WHERE id = 'turn-1'", `"content":"sentinel"`)
+	assertIndex("SELECT count(*) AS n FROM session_embeddings WHERE model = 'lsa-v1'", `"n":3`)
+
+	// The new session is searchable without a full rebuild.
+	stdout, _, err := env.RunCLI("redis cache")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if !strings.Contains(stdout, "test-session-3") {
+		t.Errorf("expected test-session-3 in recall results, got: %s", stdout)
+	}
+
+	// Nothing new: no-op.
+	if _, stderr, err := env.RunCLI("index", "--incremental"); err != nil || !strings.Contains(stderr, "index up to date") {
+		t.Errorf("second incremental index: %v, stderr: %q", err, stderr)
+	}
+
+	// A bare index still drops and rebuilds, restoring the marked turn.
+	if _, stderr, err := env.RunCLI("index"); err != nil || !strings.Contains(stderr, "index rebuilt") {
+		t.Fatalf("index: %v\nstderr: %s", err, stderr)
+	}
+	assertIndex("SELECT content FROM turns_ft WHERE id = 'turn-1'", "JWT expiry")
+
+	// --full is deprecated: it still rebuilds, with a warning.
+	if stdout, stderr, err := env.RunCLI("index", "--full"); err != nil || !strings.Contains(stdout+stderr, "deprecated") || !strings.Contains(stderr, "index rebuilt") {
+		t.Errorf("index --full: %v, stdout: %q, stderr: %q", err, stdout, stderr)
+	}
+
+	if _, _, err := env.RunCLI("index", "--incremental", "--stem=false"); err == nil {
+		t.Error("expected error for --incremental with a tokenizer flag")
+	}
+}
+
+func TestIndex_FTSRebuiltOnlyWhenTurnsChange(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	if _, stderr, err := env.RunCLI("index"); err != nil || !strings.Contains(stderr, "creating full-text search index") {
+		t.Fatalf("first index should build the FTS index: %v\nstderr: %s", err, stderr)
+	}
+
+	// Nothing changed: the FTS index is not rebuilt.
+	if _, stderr, err := env.RunCLI("index", "--incremental"); err != nil {
+		t.Fatalf("index: %v", err)
+	} else if strings.Contains(stderr, "full-text search index") {
+		t.Errorf("unchanged index should not rebuild FTS, stderr: %q", stderr)
+	}
+
+	// checkpoint adds the new session's turns and refreshes the FTS index.
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanup2()
+	gitCommit(t, env.RepoDir, "add logging")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	} else if !strings.Contains(stderr, "full-text search index refreshed") {
+		t.Errorf("checkpoint should refresh FTS for its turns, stderr: %q", stderr)
+	}
+	assertIndex := func(sql, expected string) {
+		t.Helper()
+		stdout, _, err := env.RunCLI("query", "--index", sql)
+		if err != nil {
+			t.Fatalf("query %q: %v", sql, err)
+		}
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("query %q: expected %q in output, got: %q", sql, expected, stdout)
+		}
+	}
+	assertIndex("SELECT count(*) AS n FROM turns_ft", `"n":8`)
+	assertIndex("SELECT value FROM index_state WHERE key = 'fts_state'", "turns=8,")
+
+	stdout, _, err := env.RunCLI("--no-lsa", "logging")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if !strings.Contains(stdout, "add error logging") {
+		t.Errorf("checkpointed session should be BM25-searchable, got: %s", stdout)
+	}
+
+	if _, stderr, err := env.RunCLI("index", "--incremental"); err != nil {
+		t.Fatalf("index --incremental: %v", err)
+	} else if strings.Contains(stderr, "full-text search index") || !strings.Contains(stderr, "index up to date") {
+		t.Errorf("incremental index with no new turns should not rebuild FTS, stderr: %q", stderr)
+	}
+}
+
+func TestIndex_Manifest(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+
+	type manifest struct {
+		BuiltAt    string `json:"built_at"`
+		Build      string `json:"build"`
+		Sessions   int    `json:"sessions"`
+		Turns      int    `json:"turns"`
+		Embeddings []struct {
+			Model     string `json:"model"`
+			Count     int    `json:"count"`
+			Dimension int    `json:"dimension"`
+		} `json:"embeddings"`
+		FTS struct {
+			Indexed        bool   `json:"indexed"`
+			Tokenizer      string `json:"tokenizer"`
+			Stemmer        string `json:"stemmer"`
+			Stopwords      bool   `json:"stopwords"`
+			MinTokenLength int    `json:"min_token_length"`
+		} `json:"fts"`
+	}
+	readManifest := func() manifest {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(env.RepoDir, ".rekal", "index.manifest.json"))
+		if err != nil {
+			t.Fatalf("read manifest: %v", err)
+		}
+		var m manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("parse manifest: %v\n%s", err, data)
+		}
+		return m
+	}
+	lsaCount := func(m manifest) int {
+		for _, e := range m.Embeddings {
+			if e.Model == "lsa-v1" {
+				return e.Count
+			}
+		}
+		return 0
+	}
+
+	if _, stderr, err := env.RunCLI("index", "--stem=false"); err != nil {
+		t.Fatalf("index: %v\nstderr: %s", err, stderr)
+	}
+	m := readManifest()
+	if m.Build != "full" || m.Sessions != 2 || m.Turns != 6 {
+		t.Errorf("manifest after full build: build=%q sessions=%d turns=%d, want full/2/6", m.Build, m.Sessions, m.Turns)
+	}
+	if n := lsaCount(m); n != 2 {
+		t.Errorf("lsa-v1 embeddings: got %d, want 2", n)
+	}
+	if !m.FTS.Indexed || m.FTS.Tokenizer != "custom" || m.FTS.Stemmer != "none" || m.FTS.MinTokenLength != lsa.DefaultTokenizer.MinLength {
+		t.Errorf("unexpected fts config: %+v", m.FTS)
+	}
+	if m.BuiltAt == "" {
+		t.Error("expected built_at")
+	}
+
+	// An incremental update rewrites it with the new totals.
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "test-session-3", "", "hash3", "human", "", "carol@example.com", "main", "", "2026-02-25T12:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-5", "test-session-3", 0, "human", "add a redis cache", "2026-02-25T12:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	dataDB.Close()
+
+	if _, stderr, err := env.RunCLI("index", "--incremental"); err != nil {
+		t.Fatalf("index --incremental: %v\nstderr: %s", err, stderr)
+	}
+	m = readManifest()
+	if m.Build != "incremental" || m.Sessions != 3 || m.Turns != 7 {
+		t.Errorf("manifest after incremental update: build=%q sessions=%d turns=%d, want incremental/3/7", m.Build, m.Sessions, m.Turns)
+	}
+	if n := lsaCount(m); n != 3 {
+		t.Errorf("lsa-v1 embeddings after fold-in: got %d, want 3", n)
+	}
+}
+
+func TestIndex_TokenizerNone(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for _, s := range []struct{ id, text string }{
+		{"tok-exact", "the worker kept retrying the upload"},
+		{"tok-stem", "add a retry around the upload"},
+	} {
+		if err := db.InsertSession(dataDB, s.id, "", "hash-"+s.id, "human", "", "alice@example.com", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "turn-"+s.id, s.id, 0, "human", s.text, "2026-02-25T10:00:00Z"); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	if _, _, err := env.RunCLI("index", "--incremental", "--tokenizer", "none"); err == nil {
+		t.Error("expected --tokenizer to need a full rebuild")
+	}
+	if _, _, err := env.RunCLI("index", "--tokenizer", "klingon"); err == nil {
+		t.Error("expected error for unknown tokenizer")
+	}
+	if _, stderr, err := env.RunCLI("index", "--tokenizer", "none"); err != nil {
+		t.Fatalf("index: %v\nstderr: %s", err, stderr)
+	}
+
+	out, _, err := env.RunCLI("query", "--index", "SELECT value FROM index_state WHERE key = 'tokenizer'")
+	if err != nil {
+		t.Fatalf("query index_state: %v", err)
+	}
+	if want := (lsa.TokenizerConfig{MinLength: 1}).String(); !strings.Contains(out, want) {
+		t.Errorf("index_state tokenizer: got %q, want %q", out, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.RepoDir, ".rekal", "index.manifest.json"))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), `"tokenizer": "none"`) {
+		t.Errorf("manifest should name the none tokenizer:\n%s", data)
+	}
+
+	// Unstemmed, "retrying" only matches the session that says it.
+	stdout, _, err := env.RunCLI("--no-lsa", "retrying")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	var output struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if len(output.Results) != 1 || output.Results[0].SessionID != "tok-exact" {
+		t.Errorf("got %+v, want only tok-exact", output.Results)
+	}
+
+	// Later rebuilds keep the recorded tokenizer.
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if out, _, _ := env.RunCLI("query", "--index", "SELECT value FROM index_state WHERE key = 'tokenizer'"); !strings.Contains(out, "stem=0") {
+		t.Errorf("rebuild should keep the none tokenizer, got %q", out)
+	}
+}
+
+func TestIndex_EmbeddingsLSAOnly(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+
+	if _, _, err := env.RunCLI("index", "--embeddings", "bert"); err == nil {
+		t.Error("expected error for an unknown --embeddings value")
+	}
+	if _, _, err := env.RunCLI("index", "--incremental", "--embeddings", "lsa"); err == nil {
+		t.Error("expected --embeddings to need a full rebuild")
+	}
+	if _, stderr, err := env.RunCLI("index", "--embeddings", "lsa"); err != nil {
+		t.Fatalf("index --embeddings lsa: %v\nstderr: %s", err, stderr)
+	} else if strings.Contains(stderr, "nomic") {
+		t.Errorf("--embeddings lsa should not run the nomic pass, stderr: %s", stderr)
+	}
+
+	const models = "SELECT DISTINCT model FROM session_embeddings ORDER BY model"
+	out, _, err := env.RunCLI("query", "--index", models)
+	if err != nil {
+		t.Fatalf("query session_embeddings: %v", err)
+	}
+	if strings.TrimSpace(out) != `{"model":"lsa-v1"}` {
+		t.Errorf("session_embeddings models = %q, want only lsa-v1", out)
+	}
+	assertIndexState := func(key, want string) {
+		t.Helper()
+		out, _, err := env.RunCLI("query", "--index", "SELECT value FROM index_state WHERE key = '"+key+"'")
+		if err != nil || strings.TrimSpace(out) != `{"value":"`+want+`"}` {
+			t.Errorf("index_state %s = %q (%v), want %q", key, out, err, want)
+		}
+	}
+	assertIndexState("embeddings", "lsa")
+	assertIndexState("embedding_models", "lsa-v1")
+
+	// Later rebuilds keep the choice.
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	assertIndexState("embeddings", "lsa")
+
+	// Asking for nomic alone where it cannot run falls back to LSA.
+	if !nomic.Supported() {
+		_, stderr, err := env.RunCLI("index", "--embeddings", "nomic")
+		if err != nil {
+			t.Fatalf("index --embeddings nomic: %v", err)
+		}
+		if !strings.Contains(stderr, "nomic embeddings are not available on this platform") {
+			t.Errorf("expected a fallback note, stderr: %s", stderr)
+		}
+		assertIndexState("embeddings", "lsa")
+		assertIndexState("embedding_models", "lsa-v1")
+	}
+}
+
+func TestIndex_IncrementalCooccurrence(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	// A new session that touches both of test-session-1's files plus a new
+	// one, so existing pairs are incremented and new pairs are added.
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "test-session-3", "", "hash3", "human", "", "carol@example.com", "feature/auth", "", "2026-02-25T12:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-5", "test-session-3", 0, "human", "rotate the JWT signing key", "2026-02-25T12:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	for i, path := range []string{"src/auth/middleware.go", "src/auth/jwt.go", "src/auth/keys.go", "src/auth/jwt.go"} {
+		if err := db.InsertToolCall(dataDB, fmt.Sprintf("tc-3-%d", i), "test-session-3", i, "Edit", "", path, "", false, ""); err != nil {
+			t.Fatalf("insert tool call: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	const cooccurrence = "SELECT file_a, file_b, count FROM file_cooccurrence ORDER BY file_a, file_b"
+	if _, stderr, err := env.RunCLI("index", "--incremental"); err != nil || !strings.Contains(stderr, "index updated: 1 new session(s)") {
+		t.Fatalf("incremental index: %v\nstderr: %s", err, stderr)
+	}
+	incremental, _, err := env.RunCLI("query", "--index", cooccurrence)
+	if err != nil {
+		t.Fatalf("query incremental: %v", err)
+	}
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	full, _, err := env.RunCLI("query", "--index", cooccurrence)
+	if err != nil {
+		t.Fatalf("query full: %v", err)
+	}
+
+	if incremental != full {
+		t.Errorf("incremental co-occurrence differs from full rebuild:\nincremental: %s\nfull:        %s", incremental, full)
+	}
+	// jwt.go appears twice in the new session: 1 pair from test-session-1
+	// plus 2 from test-session-3.
+	if !strings.Contains(full, `{"count":3,"file_a":"src/auth/jwt.go","file_b":"src/auth/middleware.go"}`) {
+		t.Errorf("expected jwt.go/middleware.go count 3, got: %s", full)
+	}
+}
+
+func TestIndex_CooccurrenceRepoRelative(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// A local capture records absolute paths; an imported session records
+	// repo-relative ones. Both must land on the same co-occurrence keys.
+	insert := func(id string, paths ...string) {
+		t.Helper()
+		dataDB, err := db.OpenData(env.RepoDir)
+		if err != nil {
+			t.Fatalf("open data db: %v", err)
+		}
+		defer dataDB.Close()
+		if err := db.InsertSession(dataDB, id, "", "hash-"+id, "human", "", "alice@example.com", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "turn-"+id, id, 0, "human", "rename the config loader", "2026-02-25T10:00:00Z"); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+		for i, path := range paths {
+			if err := db.InsertToolCall(dataDB, fmt.Sprintf("tc-%s-%d", id, i), id, i, "Edit", "", path, "", false, ""); err != nil {
+				t.Fatalf("insert tool call: %v", err)
+			}
+		}
+	}
+	insert("local", env.RepoDir+"/cfg/load.go", env.RepoDir+"/cfg/load_test.go")
+	insert("imported", "cfg/load.go", "cfg/load_test.go")
+
+	const cooccurrence = "SELECT file_a, file_b, count FROM file_cooccurrence ORDER BY file_a, file_b"
+	want := `{"count":2,"file_a":"cfg/load.go","file_b":"cfg/load_test.go"}`
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	full, _, err := env.RunCLI("query", "--index", cooccurrence)
+	if err != nil {
+		t.Fatalf("query full: %v", err)
+	}
+	if strings.TrimSpace(full) != want {
+		t.Errorf("full build co-occurrence:\n got %s\nwant %s", full, want)
+	}
+
+	// The incremental path normalizes the same way.
+	insert("local-2", env.RepoDir+"/cfg/load.go", env.RepoDir+"/cfg/load_test.go")
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("incremental index: %v", err)
+	}
+	incremental, _, err := env.RunCLI("query", "--index", cooccurrence)
+	if err != nil {
+		t.Fatalf("query incremental: %v", err)
+	}
+	if !strings.Contains(incremental, `{"count":3,"file_a":"cfg/load.go","file_b":"cfg/load_test.go"}`) || strings.Contains(incremental, env.RepoDir) {
+		t.Errorf("expected one repo-relative pair with count 3 after incremental index, got: %s", incremental)
+	}
+}
+
+func TestRepl_RunsEachLine(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+
+	stdin := "JWT expiry\n\n--author bob@example.com --limit 1 connection pool\n--no-such-flag x\n"
+	stdout, stderr, err := env.RunCLIWithStdin(stdin, "repl")
+	if err != nil {
+		t.Fatalf("repl: %v\nstderr: %s", err, stderr)
+	}
+
+	// One JSON result per query line; the blank line is skipped and the bad
+	// line reports its error without ending the loop.
+	type replOutput struct {
+		Query   string `json:"query"`
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+	}
+	var outputs []replOutput
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	for dec.More() {
+		var out replOutput
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("decode repl output: %v\nstdout: %s", err, stdout)
+		}
+		outputs = append(outputs, out)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 result sets, got %d\nstdout: %s", len(outputs), stdout)
+	}
+	if outputs[0].Query != "JWT expiry" || len(outputs[0].Results) == 0 || outputs[0].Results[0].SessionID != "test-session-1" {
+		t.Errorf("first query: expected test-session-1 first, got %+v", outputs[0])
+	}
+	if outputs[1].Query != "connection pool" || len(outputs[1].Results) != 1 || outputs[1].Results[0].SessionID != "test-session-2" {
+		t.Errorf("second query: expected only test-session-2, got %+v", outputs[1])
+	}
+	if !strings.Contains(stderr, "error: unknown flag: --no-such-flag") {
+		t.Errorf("expected the bad line's error on stderr, got: %q", stderr)
+	}
+}
+
+func TestRecall_AuthorMatchesEmailAliases(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for i, email := range []string{"alice@corp.com", "Alice@users.noreply.github.com", "bob@corp.com"} {
+		sid := fmt.Sprintf("alias-session-%d", i)
+		if err := db.InsertSession(dataDB, sid, "", "alias-hash-"+sid, "human", "", email, "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "alias-turn-"+sid, sid, 0, "human", "tune the retry backoff", "2026-02-25T10:00:00Z"); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	aliases := "# one person, two emails\nalice@users.noreply.github.com = alice@corp.com\n"
+	if err := os.WriteFile(filepath.Join(env.RepoDir, ".rekal", "aliases"), []byte(aliases), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Either of alice's emails finds both her sessions, and only hers.
+	for _, author := range []string{"alice@corp.com", "alice@users.noreply.github.com"} {
+		stdout, stderr, err := env.RunCLI("--author", author, "retry backoff")
+		if err != nil {
+			t.Fatalf("recall --author %s: %v\nstderr: %s", author, err, stderr)
+		}
+		var out struct {
+			Results []struct {
+				SessionID string `json:"session_id"`
+				Session   struct {
+					Author string `json:"author"`
+				} `json:"session"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			t.Fatalf("parse recall output: %v\n%s", err, stdout)
+		}
+		var got []string
+		for _, r := range out.Results {
+			got = append(got, r.SessionID)
+			if r.Session.Author != "alice@corp.com" {
+				t.Errorf("--author %s: result author %q, want the canonical alice@corp.com", author, r.Session.Author)
+			}
+		}
+		sort.Strings(got)
+		if want := []string{"alias-session-0", "alias-session-1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("--author %s: got sessions %v, want %v", author, got, want)
+		}
+	}
+}
+
+func TestRelated_RanksCoEditedFiles(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	// auth.go is co-edited with jwt.go in three sessions (once under the
+	// absolute path a local capture records), with keys.go in two and with
+	// api.go, which sorts before it and so sits on the file_a side, in one.
+	// zeta.go is only ever touched without auth.go.
+	sessions := map[string][]string{
+		"rel-1": {"src/auth.go", "src/jwt.go", "src/keys.go", "src/api.go"},
+		"rel-2": {"src/auth.go", "src/jwt.go", "src/keys.go"},
+		"rel-3": {env.RepoDir + "/src/auth.go", env.RepoDir + "/src/jwt.go"},
+		"rel-4": {"src/jwt.go", "src/zeta.go"},
+	}
+	for id, paths := range sessions {
+		if err := db.InsertSession(dataDB, id, "", "hash-"+id, "human", "", "alice@example.com", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "turn-"+id, id, 0, "human", "tidy up the auth code", "2026-02-25T10:00:00Z"); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+		for i, path := range paths {
+			if err := db.InsertToolCall(dataDB, fmt.Sprintf("tc-%s-%d", id, i), id, i, "Edit", "", path, "", false, ""); err != nil {
+				t.Fatalf("insert tool call: %v", err)
+			}
+		}
+	}
+	dataDB.Close()
+
+	stdout, _, err := env.RunCLI("related", "src/auth.go")
+	if err != nil {
+		t.Fatalf("related: %v", err)
+	}
+	var out struct {
+		File    string `json:"file"`
+		Related []struct {
+			File  string `json:"file"`
+			Count int    `json:"count"`
+		} `json:"related"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout)
+	}
+	if out.File != "src/auth.go" {
+		t.Errorf("file = %q, want src/auth.go", out.File)
+	}
+	var got []string
+	for _, r := range out.Related {
+		got = append(got, fmt.Sprintf("%s:%d", r.File, r.Count))
+	}
+	if want := "src/jwt.go:3,src/keys.go:2,src/api.go:1"; strings.Join(got, ",") != want {
+		t.Errorf("related = %v, want %s", got, want)
+	}
+
+	stdout, _, err = env.RunCLI("related", "-n", "1", env.RepoDir+"/src/auth.go")
+	if err != nil {
+		t.Fatalf("related -n 1: %v", err)
+	}
+	if !strings.Contains(stdout, `"src/jwt.go"`) || strings.Contains(stdout, `"src/keys.go"`) {
+		t.Errorf("expected only src/jwt.go with -n 1, got: %s", stdout)
+	}
+
+	if _, _, err := env.RunCLI("related", "/elsewhere/auth.go"); err == nil {
+		t.Error("expected error for a file outside the repository")
+	}
+	if _, _, err := env.RunCLI("related", "-n", "0", "src/auth.go"); err == nil {
+		t.Error("expected error for --limit 0")
+	}
+}
+
+func TestRecall_HybridSearch(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	// Build index first.
+	_, _, err := env.RunCLI("index")
+	if err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	stdout, _, err := env.RunCLI("JWT auth")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v", err)
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+
+	if output["mode"] != "hybrid" {
+		t.Errorf("expected mode=hybrid, got %v", output["mode"])
+	}
+}
+
+func TestRecall_FormatText(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	stdout, _, err := env.RunCLI("--format", "text", "JWT expiry")
+	if err != nil {
+		t.Fatalf("recall --format text: %v", err)
+	}
+	for _, want := range []string{"session test-session-1  (score ", "Author:   alice@example.com", "Branch:   feature/auth"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected %q in text output, got:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "\x1b[") {
+		t.Errorf("text output to a non-terminal should not be colored:\n%q", stdout)
+	}
+
+	// Not a terminal: JSON by default.
+	stdout, _, err = env.RunCLI("JWT expiry")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if !json.Valid([]byte(stdout)) {
+		t.Errorf("expected JSON when stdout is not a terminal, got:\n%s", stdout)
+	}
+
+	if _, _, err := env.RunCLI("--format", "yaml", "JWT"); err == nil {
+		t.Error("expected error for --format yaml")
+	}
+}
+
+func TestRecall_SnippetLen(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	snippets := func(args ...string) []string {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var out struct {
+			Results []struct {
+				Snippet string `json:"snippet"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		if len(out.Results) == 0 {
+			t.Fatalf("recall %v: no results", args)
+		}
+		var s []string
+		for _, r := range out.Results {
+			s = append(s, r.Snippet)
+		}
+		return s
+	}
+
+	for _, args := range [][]string{{"JWT expiry"}, {"--actor", "human"}, {"--grep", "JWT"}} {
+		for _, s := range snippets(append([]string{"--snippet-len", "20"}, args...)...) {
+			if len(strings.Trim(s, ".")) > 20 {
+				t.Errorf("%v --snippet-len 20: snippet %q is longer", args, s)
+			}
+		}
+		for _, s := range snippets(append([]string{"--snippet-len", "0"}, args...)...) {
+			if s != "" {
+				t.Errorf("%v --snippet-len 0: snippet %q, want none", args, s)
+			}
+		}
+	}
+
+	for _, n := range []string{"5", "-1"} {
+		if _, _, err := env.RunCLI("--snippet-len", n, "JWT"); err == nil {
+			t.Errorf("expected error for --snippet-len %s", n)
+		}
+	}
+}
+
+func TestRecall_FilterOnly(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	_, _, err := env.RunCLI("index")
+	if err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	stdout, _, err := env.RunCLI("--actor", "human")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v", err)
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+
+	if output["mode"] != "filter" {
+		t.Errorf("expected mode=filter, got %v", output["mode"])
+	}
+}
+
+func TestRecall_TimeRange(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// test-session-1 is captured at 10:00, test-session-2 at 11:00.
+	seedData(t, env)
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	sessionIDs := func(args ...string) []string {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var output struct {
+			Results []struct {
+				SessionID string `json:"session_id"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		var ids []string
+		for _, r := range output.Results {
+			ids = append(ids, r.SessionID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"filter since", []string{"--since", "2026-02-25T10:30:00Z"}, []string{"test-session-2"}},
+		{"filter until", []string{"--until", "2026-02-25T10:30:00Z"}, []string{"test-session-1"}},
+		{"filter window", []string{"--since", "2026-02-25T09:00:00Z", "--until", "2026-02-25T12:00:00Z"}, []string{"test-session-2", "test-session-1"}},
+		{"filter relative", []string{"--since", "7d"}, nil},
+		{"hybrid until", []string{"--until", "2026-02-25T10:30:00Z", "JWT expiry"}, []string{"test-session-1"}},
+		{"hybrid since excludes match", []string{"--since", "2026-02-25T10:30:00Z", "JWT expiry"}, nil},
+	}
+	for _, tt := range tests {
+		got := sessionIDs(tt.args...)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got sessions %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, _, err := env.RunCLI("--since", "last week", "JWT"); err == nil {
+		t.Error("expected error for invalid --since")
+	}
+}
+
+func TestRecall_Offset(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// Seven sessions that all mention the deploy script, an hour apart.
+	const n = 7
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("page-session-%d", i)
+		ts := fmt.Sprintf("2026-02-25T%02d:00:00Z", 10+i)
+		if err := db.InsertSession(dataDB, id, "", "hash-"+id, "human", "", "alice@example.com", "main", "", ts, 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		text := fmt.Sprintf("fix the deploy script, attempt %d", i)
+		if err := db.InsertTurn(dataDB, "turn-"+id, id, 0, "human", text, ts); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	type page struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+		Total   int  `json:"total"`
+		Offset  int  `json:"offset"`
+		HasMore bool `json:"has_more"`
+	}
+	recall := func(args ...string) page {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var p page
+		if err := json.Unmarshal([]byte(stdout), &p); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		return p
+	}
+
+	for _, mode := range []struct {
+		name string
+		args []string
+	}{
+		{"filter", []string{"--actor", "human"}},
+		{"hybrid", []string{"deploy script"}},
+	} {
+		seen := map[string]bool{}
+		for offset := 0; offset < n; offset += 3 {
+			args := append([]string{"--limit", "3", "--offset", fmt.Sprint(offset)}, mode.args...)
+			p := recall(args...)
+
+			wantLen := min(3, n-offset)
+			if len(p.Results) != wantLen {
+				t.Fatalf("%s offset %d: got %d results, want %d", mode.name, offset, len(p.Results), wantLen)
+			}
+			if p.Total != n {
+				t.Errorf("%s offset %d: total = %d, want %d", mode.name, offset, p.Total, n)
+			}
+			if p.Offset != offset {
+				t.Errorf("%s offset %d: offset = %d", mode.name, offset, p.Offset)
+			}
+			if wantMore := offset+3 < n; p.HasMore != wantMore {
+				t.Errorf("%s offset %d: has_more = %v, want %v", mode.name, offset, p.HasMore, wantMore)
+			}
+			for _, r := range p.Results {
+				if seen[r.SessionID] {
+					t.Errorf("%s offset %d: session %s already on an earlier page", mode.name, offset, r.SessionID)
+				}
+				seen[r.SessionID] = true
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("%s: pages covered %d sessions, want %d", mode.name, len(seen), n)
+		}
+
+		if p := recall(append([]string{"--offset", "100"}, mode.args...)...); len(p.Results) != 0 || p.Total != n || p.HasMore {
+			t.Errorf("%s: offset past the end: got %d results, total %d, has_more %v", mode.name, len(p.Results), p.Total, p.HasMore)
+		}
+	}
+
+	if _, _, err := env.RunCLI("--offset", "-1", "deploy"); err == nil {
+		t.Error("expected error for negative --offset")
+	}
+}
+
+func TestRecall_LimitZeroReturnsAll(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// More sessions than the default limit of 20.
+	const n = 30
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("limit-session-%02d", i)
+		ts := fmt.Sprintf("2026-02-%02dT%02d:00:00Z", 1+i/24, i%24)
+		if err := db.InsertSession(dataDB, id, "", "hash-"+id, "human", "", "alice@example.com", "main", "", ts, 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		text := fmt.Sprintf("rotate the signing key, attempt %d", i)
+		if err := db.InsertTurn(dataDB, "turn-"+id, id, 0, "human", text, ts); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	type page struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+		Total   int  `json:"total"`
+		Limit   *int `json:"limit"`
+		HasMore bool `json:"has_more"`
+	}
+	recall := func(args ...string) page {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var p page
+		if err := json.Unmarshal([]byte(stdout), &p); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		return p
+	}
+
+	for _, mode := range []struct {
+		name string
+		args []string
+	}{
+		{"filter", []string{"--actor", "human"}},
+		{"hybrid", []string{"signing key"}},
+		{"grep", []string{"--grep", "signing key"}},
+	} {
+		if p := recall(mode.args...); len(p.Results) != 20 || !p.HasMore || p.Limit == nil || *p.Limit != 20 {
+			t.Errorf("%s default: %d results, has_more %v, limit %v; want 20, true, 20", mode.name, len(p.Results), p.HasMore, p.Limit)
+		}
+
+		p := recall(append([]string{"--limit", "0"}, mode.args...)...)
+		seen := map[string]bool{}
+		for _, r := range p.Results {
+			seen[r.SessionID] = true
+		}
+		if len(p.Results) != n || len(seen) != n || p.Total != n || p.HasMore || p.Limit != nil {
+			t.Errorf("%s --limit 0: %d results (%d distinct), total %d, has_more %v, limit %v; want all %d and no limit",
+				mode.name, len(p.Results), len(seen), p.Total, p.HasMore, p.Limit, n)
+		}
+
+		if p := recall(append([]string{"--limit", "0", "--offset", "25"}, mode.args...)...); len(p.Results) != n-25 {
+			t.Errorf("%s --limit 0 --offset 25: %d results, want %d", mode.name, len(p.Results), n-25)
+		}
+	}
+
+	if _, _, err := env.RunCLI("--limit", "-1", "signing"); err == nil {
+		t.Error("expected error for negative --limit")
+	}
+}
+
+func TestRecall_ScopeSelf(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// seedData's sessions belong to teammates (alice, bob); add one of our
+	// own on the same topic as alice's.
+	seedData(t, env)
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "own-session", "", "hash-own", "human", "", "test@rekal.dev", "feature/auth", "", "2026-02-25T12:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-own", "own-session", 0, "human", "rotate the JWT signing key used by the auth middleware", "2026-02-25T12:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	dataDB.Close()
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	sessionIDs := func(args ...string) []string {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var output struct {
+			Results []struct {
+				SessionID string `json:"session_id"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		var ids []string
+		for _, r := range output.Results {
+			ids = append(ids, r.SessionID)
+		}
+		return ids
+	}
+
+	team := sessionIDs("--scope", "team", "JWT auth middleware")
+	if !strings.Contains(strings.Join(team, ","), "test-session-1") {
+		t.Errorf("--scope team should include teammates' sessions, got %v", team)
+	}
+
+	for _, args := range [][]string{
+		{"--scope", "self", "JWT auth middleware"},
+		{"--scope", "self"},
+	} {
+		got := sessionIDs(args...)
+		if strings.Join(got, ",") != "own-session" {
+			t.Errorf("%v: got sessions %v, want only own-session", args, got)
+		}
+	}
+
+	if _, _, err := env.RunCLI("--scope", "everyone", "JWT"); err == nil {
+		t.Error("expected error for invalid --scope")
+	}
+}
+
+func TestRecall_AutoRebuild(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	// Don't run index first — recall should auto-rebuild.
+	stdout, stderr, err := env.RunCLI("JWT")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v\nstderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stderr, "index not built") {
+		t.Errorf("expected auto-rebuild message, got stderr: %q", stderr)
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+}
+
+func TestRecall_Checkpoint(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// seedData links test-session-1 to cp-1 (abc123) and test-session-2 to
+	// cp-2 (def456). Ship a second session in cp-2.
+	seedData(t, env)
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "test-session-3", "", "hash3", "human", "", "bob@example.com", "feature/db", "", "2026-02-25T11:02:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-5", "test-session-3", 0, "human", "add an index on the sessions table", "2026-02-25T11:02:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	if err := db.InsertCheckpointSession(dataDB, "cp-2", "test-session-3"); err != nil {
+		t.Fatalf("insert checkpoint_session: %v", err)
+	}
+	dataDB.Close()
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	sessionIDs := func(args ...string) []string {
+		t.Helper()
+		stdout, _, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v", args, err)
+		}
+		var output struct {
+			Results []struct {
+				SessionID string `json:"session_id"`
+			} `json:"results"`
+			Filters map[string]string `json:"filters"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		if output.Filters["checkpoint"] != args[1] {
+			t.Errorf("filters.checkpoint = %q, want %q", output.Filters["checkpoint"], args[1])
+		}
+		var ids []string
+		for _, r := range output.Results {
+			ids = append(ids, r.SessionID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--checkpoint", "abc"}, "test-session-1"},
+		{[]string{"--checkpoint", "abc123", "database connection pooling"}, ""},
+		{[]string{"--checkpoint", "def4"}, "test-session-2,test-session-3"},
+		{[]string{"--checkpoint", "def456", "sessions table"}, "test-session-3"},
+		{[]string{"--checkpoint", "def456", "JWT expiry"}, ""},
+		{[]string{"--checkpoint", "999"}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(sessionIDs(tt.args...), ","); got != tt.want {
+			t.Errorf("recall %v: got sessions %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRecall_Tag(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	_, stderr, err := env.RunCLI("tag", "--session", "test-session-2", "bug", "spike")
+	if err != nil {
+		t.Fatalf("tag: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "tagged test-session-2: bug") {
+		t.Errorf("expected tag confirmation, got: %s", stderr)
+	}
+	_, stderr, err = env.RunCLI("tag", "--session", "test-session-2", "bug")
+	if err != nil {
+		t.Fatalf("retag: %v", err)
+	}
+	if !strings.Contains(stderr, "already tagged test-session-2: bug") {
+		t.Errorf("expected already-tagged note, got: %s", stderr)
+	}
+
+	sessionIDs := func(args ...string) string {
+		t.Helper()
+		stdout, _, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v", args, err)
+		}
+		var output struct {
+			Results []struct {
+				SessionID string `json:"session_id"`
+			} `json:"results"`
+			Filters map[string]string `json:"filters"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		if output.Filters["tag"] != args[1] {
+			t.Errorf("filters.tag = %q, want %q", output.Filters["tag"], args[1])
+		}
+		var ids []string
+		for _, r := range output.Results {
+			ids = append(ids, r.SessionID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if got := sessionIDs("--tag", "bug"); got != "test-session-2" {
+		t.Errorf("--tag bug: got %q, want test-session-2", got)
+	}
+	if got := sessionIDs("--tag", "spike", "database connection pooling"); got != "test-session-2" {
+		t.Errorf("--tag spike with query: got %q, want test-session-2", got)
+	}
+	if got := sessionIDs("--tag", "bug", "JWT expiry"); got != "" {
+		t.Errorf("--tag bug JWT expiry: got %q, want no results", got)
+	}
+
+	if _, _, err := env.RunCLI("untag", "--session", "test-session-2", "bug"); err != nil {
+		t.Fatalf("untag: %v", err)
+	}
+	if got := sessionIDs("--tag", "bug"); got != "" {
+		t.Errorf("--tag bug after untag: got %q, want no results", got)
+	}
+	if got := sessionIDs("--tag", "spike"); got != "test-session-2" {
+		t.Errorf("--tag spike after untagging bug: got %q, want test-session-2", got)
+	}
+}
+
+func TestTag_Errors(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"tag", "--session", "no-such-session", "bug"}, "session not found"},
+		{[]string{"tag", "--session", "test-session-1", "two words"}, "must not contain spaces"},
+		{[]string{"tag", "--session", "test-session-1", ""}, "must not be empty"},
+		{[]string{"tag", "bug"}, "required flag"},
+	}
+	for _, tt := range tests {
+		_, _, err := env.RunCLI(tt.args...)
+		if err == nil {
+			t.Errorf("%v: expected error", tt.args)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: error %q, want it to contain %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestRecall_ExpandCommit(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	// A second session shipped in the same checkpoint as test-session-1,
+	// on an unrelated topic.
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "test-session-3", "", "hash3", "human", "", "alice@example.com", "feature/auth", "", "2026-02-25T10:04:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-5", "test-session-3", 0, "human", "write the changelog entry for this release", "2026-02-25T10:04:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	if err := db.InsertCheckpointSession(dataDB, "cp-1", "test-session-3"); err != nil {
+		t.Fatalf("insert checkpoint_session: %v", err)
+	}
+	dataDB.Close()
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	stdout, _, err := env.RunCLI("--expand-commit", "-n", "1", "JWT expiry")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v", err)
+	}
+
+	var output struct {
+		Results []struct {
+			SessionID    string `json:"session_id"`
+			Snippet      string `json:"snippet"`
+			ExpandedFrom string `json:"expanded_from"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if len(output.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d\nstdout: %s", len(output.Results), stdout)
+	}
+	if output.Results[0].SessionID != "test-session-1" || output.Results[0].ExpandedFrom != "" {
+		t.Errorf("results[0] = %+v, want test-session-1 as a direct match", output.Results[0])
+	}
+	if output.Results[1].SessionID != "test-session-3" || output.Results[1].ExpandedFrom != "test-session-1" {
+		t.Errorf("results[1] = %+v, want test-session-3 expanded from test-session-1", output.Results[1])
+	}
+	if !strings.Contains(output.Results[1].Snippet, "changelog") {
+		t.Errorf("expected sibling's own snippet, got %q", output.Results[1].Snippet)
+	}
+
+	// Off by default.
+	stdout, _, err = env.RunCLI("-n", "1", "JWT expiry")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v", err)
+	}
+	if strings.Contains(stdout, "expanded_from") {
+		t.Errorf("expected no expansions without --expand-commit, got: %s", stdout)
+	}
+}
+
+func TestRecall_Fuzzy(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	type recallOutput struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+		Mode       string `json:"mode"`
+		FuzzyQuery string `json:"fuzzy_query"`
+	}
+	recall := func(args ...string) recallOutput {
+		t.Helper()
+		stdout, _, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v", args, err)
+		}
+		var out recallOutput
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		return out
+	}
+
+	// "conection poolling" misspells words only test-session-2 uses.
+	if out := recall("conection poolling"); len(out.Results) != 0 || out.Mode != "hybrid" {
+		t.Fatalf("without --fuzzy: got %d results in mode %q, want none in hybrid", len(out.Results), out.Mode)
+	}
+	out := recall("--fuzzy", "conection poolling")
+	if out.Mode != "fuzzy" {
+		t.Errorf("mode = %q, want fuzzy", out.Mode)
+	}
+	if out.FuzzyQuery != "connection pooling" {
+		t.Errorf("fuzzy_query = %q, want %q", out.FuzzyQuery, "connection pooling")
+	}
+	if len(out.Results) == 0 || out.Results[0].SessionID != "test-session-2" {
+		t.Errorf("expected test-session-2 first, got %+v", out.Results)
+	}
+
+	// Exact hits never fall back.
+	if out := recall("--fuzzy", "connection pooling"); out.Mode != "hybrid" || out.FuzzyQuery != "" {
+		t.Errorf("exact query: mode %q fuzzy_query %q, want hybrid and none", out.Mode, out.FuzzyQuery)
+	}
+	// Nothing close enough: still empty, still hybrid.
+	if out := recall("--fuzzy", "xylophone"); len(out.Results) != 0 || out.Mode != "hybrid" {
+		t.Errorf("unmatched query: got %d results in mode %q", len(out.Results), out.Mode)
+	}
 }
 
-func TestRecall_HybridSearch(t *testing.T) {
+func TestRecall_Grep(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
-	seedData(t, env)
-
-	// Build index first.
-	_, _, err := env.RunCLI("index")
+	// BM25 stems both "retrying" and "retry" to "retri", so a ranked search
+	// for one finds the other. --grep must only find the exact string.
+	dataDB, err := db.OpenData(env.RepoDir)
 	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	sessions := []struct{ id, ts, text string }{
+		{"grep-exact", "2026-02-25T10:00:00Z", "the upload failed\nwe kept Retrying the upload\nthen gave up"},
+		{"grep-stem", "2026-02-25T11:00:00Z", "add a retry to the upload"},
+	}
+	for _, s := range sessions {
+		if err := db.InsertSession(dataDB, s.id, "", "hash-"+s.id, "human", "", "alice@example.com", "main", "", s.ts, 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "turn-"+s.id, s.id, 0, "human", s.text, s.ts); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+	if _, _, err := env.RunCLI("index"); err != nil {
 		t.Fatalf("index failed: %v", err)
 	}
 
-	stdout, _, err := env.RunCLI("JWT auth")
-	if err != nil {
-		t.Fatalf("recall should succeed: %v", err)
+	type output struct {
+		Mode    string `json:"mode"`
+		Total   int    `json:"total"`
+		Results []struct {
+			SessionID string `json:"session_id"`
+			Snippet   string `json:"snippet"`
+			Matches   []struct {
+				TurnIndex int    `json:"turn_index"`
+				Role      string `json:"role"`
+				Line      int    `json:"line"`
+				Context   string `json:"context"`
+			} `json:"matches"`
+		} `json:"results"`
+	}
+	recall := func(args ...string) output {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var out output
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		return out
 	}
 
-	var output map[string]interface{}
-	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+	if out := recall("retrying"); out.Total != 2 {
+		t.Fatalf("ranked search should match both stems, got total %d", out.Total)
+	}
+
+	out := recall("--grep", "retrying")
+	if out.Mode != "grep" {
+		t.Errorf("mode = %q, want grep", out.Mode)
+	}
+	if out.Total != 1 || len(out.Results) != 1 || out.Results[0].SessionID != "grep-exact" {
+		t.Fatalf("--grep retrying: got %+v, want only grep-exact", out.Results)
+	}
+	m := out.Results[0].Matches
+	if len(m) != 1 || m[0].TurnIndex != 0 || m[0].Role != "human" || m[0].Line != 2 {
+		t.Fatalf("matches = %+v, want turn 0 line 2", m)
+	}
+	if want := "the upload failed\nwe kept Retrying the upload\nthen gave up"; m[0].Context != want {
+		t.Errorf("context = %q, want %q", m[0].Context, want)
+	}
+	if s := out.Results[0].Snippet; s != m[0].Context {
+		t.Errorf("snippet = %q, want the whole context without --snippet-len", s)
+	}
+	if s := recall("--grep", "--snippet-len", "20", "retrying").Results[0].Snippet; !strings.Contains(s, "Retrying") {
+		t.Errorf("--snippet-len 20: snippet %q should center on the pattern", s)
+	}
+
+	if out := recall("--grep", "a retry to"); out.Total != 1 || out.Results[0].SessionID != "grep-stem" {
+		t.Errorf("--grep should match a phrase as typed, got %+v", out.Results)
+	}
+
+	if _, _, err := env.RunCLI("--grep", "--actor", "human"); err == nil {
+		t.Error("expected error for --grep without a pattern")
+	}
+	if _, _, err := env.RunCLI("--grep", "--fuzzy", "retrying"); err == nil {
+		t.Error("expected error for --grep with --fuzzy")
+	}
+}
+
+func TestRecall_OutputTurns(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	// Both sessions mention "configuration"; test-session-2 matches more.
+	stdout, _, err := env.RunCLI("--output-turns", "1", "connection pool configuration")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	var out struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+			Snippet   string `json:"snippet"`
+			Turns     []struct {
+				Index   int    `json:"index"`
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"turns"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
 		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
 	}
+	if len(out.Results) < 2 {
+		t.Fatalf("expected 2 results, got: %s", stdout)
+	}
 
-	if output["mode"] != "hybrid" {
-		t.Errorf("expected mode=hybrid, got %v", output["mode"])
+	top := out.Results[0]
+	if top.SessionID != "test-session-2" {
+		t.Fatalf("expected test-session-2 first, got %s", top.SessionID)
+	}
+	if len(top.Turns) != 2 || top.Turns[0].Role != "human" || top.Turns[0].Content != "optimize the database connection pooling" || top.Turns[1].Index != 1 {
+		t.Errorf("top result should carry its full turns, got %+v", top.Turns)
+	}
+	if next := out.Results[1]; len(next.Turns) != 0 || next.Snippet == "" {
+		t.Errorf("lower-ranked result should have only a snippet, got %d turns, snippet %q", len(next.Turns), next.Snippet)
+	}
+
+	if _, _, err := env.RunCLI("--output-turns", "6", "connection"); err == nil {
+		t.Error("expected an error for --output-turns above the limit")
 	}
 }
 
-func TestRecall_FilterOnly(t *testing.T) {
+func TestRecall_NoLSA(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
 	seedData(t, env)
 
-	_, _, err := env.RunCLI("index")
+	// More sessions with varying keyword density, plus one that only shares
+	// vocabulary with the query through LSA.
+	dataDB, err := db.OpenData(env.RepoDir)
 	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	extra := map[string]string{
+		"test-session-3": "rotate the JWT signing key and shorten token expiry",
+		"test-session-4": "JWT JWT token token expiry expiry refresh",
+		"test-session-5": "the refresh endpoint returns a new access credential",
+	}
+	for i, sid := range []string{"test-session-3", "test-session-4", "test-session-5"} {
+		ts := fmt.Sprintf("2026-02-25T12:0%d:00Z", i)
+		if err := db.InsertSession(dataDB, sid, "", "hash-"+sid, "human", "", "carol@example.com", "feature/auth", "", ts, 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		if err := db.InsertTurn(dataDB, "turn-"+sid, sid, 0, "human", extra[sid], ts); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	if _, _, err := env.RunCLI("index"); err != nil {
 		t.Fatalf("index failed: %v", err)
 	}
 
-	stdout, _, err := env.RunCLI("--actor", "human")
+	const query = "JWT token expiry"
+	stdout, _, err := env.RunCLI("--no-lsa", query)
 	if err != nil {
-		t.Fatalf("recall should succeed: %v", err)
+		t.Fatalf("recall --no-lsa: %v", err)
+	}
+	var output struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
 	}
-
-	var output map[string]interface{}
 	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
 		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
 	}
+	var got []string
+	for _, r := range output.Results {
+		got = append(got, r.SessionID)
+	}
 
-	if output["mode"] != "filter" {
-		t.Errorf("expected mode=filter, got %v", output["mode"])
+	// Pure BM25: best turn per session, ties broken by session ID.
+	indexDB, err := db.OpenIndex(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open index db: %v", err)
+	}
+	defer indexDB.Close()
+	if err := db.LoadFTSExtension(indexDB); err != nil {
+		t.Fatalf("load fts: %v", err)
+	}
+	rows, err := indexDB.Query(`
+		SELECT session_id, max(score) AS best
+		FROM (SELECT session_id, fts_main_turns_ft.match_bm25(id, $1) AS score FROM turns_ft)
+		WHERE score IS NOT NULL
+		GROUP BY session_id
+		ORDER BY best DESC, session_id
+	`, query)
+	if err != nil {
+		t.Fatalf("bm25 query: %v", err)
+	}
+	defer rows.Close()
+	var want []string
+	for rows.Next() {
+		var sid string
+		var score float64
+		if err := rows.Scan(&sid, &score); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		want = append(want, sid)
+	}
+
+	if len(want) < 3 {
+		t.Fatalf("expected at least 3 BM25 matches, got %v", want)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("--no-lsa ordering:\n got %v\nwant %v (pure BM25)", got, want)
+	}
+
+	if _, _, err := env.RunCLI("--no-lsa", "--no-bm25", query); err == nil {
+		t.Error("expected error when both --no-lsa and --no-bm25 are set")
+	}
+	if _, _, err := env.RunCLI("--semantic", "--no-lsa", query); err == nil {
+		t.Error("expected error when --semantic and --no-lsa are set")
 	}
 }
 
-func TestRecall_AutoRebuild(t *testing.T) {
+func TestIndex_FileAccessCanonicalPaths(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
 
-	seedData(t, env)
+	if err := os.MkdirAll(filepath.Join(env.RepoDir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "src", "cookie.go"), []byte("package src\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "access-session", "", "hash-a", "human", "", "alice@example.com", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	for i, tc := range []struct{ tool, path string }{
+		{"Read", "docs/../docs/cookies.md"},           // relative, unclean
+		{"Read", env.RepoDir + "/docs/cookies.md"},    // the same file
+		{"Grep", env.RepoDir + "/src"},                // a directory searched, not read
+		{"Grep", env.RepoDir + "/src/cookie.go"},      // a file searched
+		{"Glob", "/etc/hosts"},                        // outside the repo
+		{"Read", env.RepoDir + "/src/" + env.RepoDir}, // the root again mid-path
+	} {
+		if err := db.InsertToolCall(dataDB, fmt.Sprintf("tc-%d", i), "access-session", i, tc.tool, "", tc.path, "", false, ""); err != nil {
+			t.Fatalf("insert tool_call: %v", err)
+		}
+	}
+	dataDB.Close()
 
-	// Don't run index first — recall should auto-rebuild.
-	stdout, stderr, err := env.RunCLI("JWT")
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+	stdout, stderr, err := env.RunCLI("query", "--index", "SELECT string_agg(tool || ' ' || file_path || ' ' || CAST(count AS VARCHAR), '; ' ORDER BY tool DESC, file_path) AS access FROM file_access")
 	if err != nil {
-		t.Fatalf("recall should succeed: %v\nstderr: %s", err, stderr)
+		t.Fatalf("query --index: %v (stderr: %s)", err, stderr)
+	}
+	if want := "Read docs/cookies.md 2; Read src" + env.RepoDir + " 1; Grep src/cookie.go 1"; !strings.Contains(stdout, want) {
+		t.Errorf("file_access = %s, want %q", stdout, want)
 	}
+}
 
-	if !strings.Contains(stderr, "index not built") {
-		t.Errorf("expected auto-rebuild message, got stderr: %q", stderr)
+func TestIndex_FileAccessSeparateFromChanges(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "access-session", "", "hash-a", "human", "", "alice@example.com", "main", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-a", "access-session", 0, "human", "tighten the session cookie flags", "2026-02-25T10:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	// Read a design doc for context, then edit the code.
+	if err := db.InsertToolCall(dataDB, "tc-a1", "access-session", 0, "Read", "", env.RepoDir+"/docs/cookies.md", "", false, ""); err != nil {
+		t.Fatalf("insert tool_call: %v", err)
+	}
+	if err := db.InsertToolCall(dataDB, "tc-a2", "access-session", 1, "Edit", "", env.RepoDir+"/src/cookie.go", "", false, ""); err != nil {
+		t.Fatalf("insert tool_call: %v", err)
+	}
+	if err := db.InsertCheckpoint(dataDB, "cp-a", "aaa111", "main", "alice@example.com", "2026-02-25T10:05:00Z", "human", ""); err != nil {
+		t.Fatalf("insert checkpoint: %v", err)
+	}
+	if err := db.InsertCheckpointSession(dataDB, "cp-a", "access-session"); err != nil {
+		t.Fatalf("insert checkpoint_session: %v", err)
+	}
+	if err := db.InsertFileTouched(dataDB, "ft-a", "cp-a", "src/cookie.go", "M"); err != nil {
+		t.Fatalf("insert file_touched: %v", err)
 	}
+	dataDB.Close()
 
-	var output map[string]interface{}
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	indexDB, err := db.OpenIndex(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open index db: %v", err)
+	}
+	var accessed, modified int
+	if err := indexDB.QueryRow("SELECT count(*) FROM file_access WHERE file_path = 'docs/cookies.md' AND tool = 'Read'").Scan(&accessed); err != nil {
+		t.Fatalf("query file_access: %v", err)
+	}
+	if err := indexDB.QueryRow("SELECT count(*) FROM files_index WHERE file_path = 'docs/cookies.md'").Scan(&modified); err != nil {
+		t.Fatalf("query files_index: %v", err)
+	}
+	indexDB.Close()
+	if accessed != 1 {
+		t.Errorf("expected docs/cookies.md recorded as a Read access, got %d rows", accessed)
+	}
+	if modified != 0 {
+		t.Errorf("expected docs/cookies.md not recorded as a modification, got %d rows", modified)
+	}
+
+	stdout, _, err := env.RunCLI("cookie flags")
+	if err != nil {
+		t.Fatalf("recall should succeed: %v", err)
+	}
+	var output struct {
+		Results []struct {
+			Session struct {
+				Files        []string `json:"files"`
+				ContextFiles []string `json:"context_files"`
+			} `json:"session"`
+		} `json:"results"`
+	}
 	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
 		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
 	}
+	if len(output.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d\nstdout: %s", len(output.Results), stdout)
+	}
+	got := output.Results[0].Session
+	if strings.Join(got.Files, ",") != "src/cookie.go" {
+		t.Errorf("files = %v, want [src/cookie.go]", got.Files)
+	}
+	if strings.Join(got.ContextFiles, ",") != "docs/cookies.md" {
+		t.Errorf("context_files = %v, want [docs/cookies.md]", got.ContextFiles)
+	}
 }
 
 func TestQuery_SessionDrilldown(t *testing.T) {
@@ -129,6 +1848,48 @@ func TestQuery_SessionDrilldown(t *testing.T) {
 	}
 }
 
+func TestQuery_AttachJoinsDataAndIndex(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+	if _, stderr, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v (stderr: %s)", err, stderr)
+	}
+
+	// Data DB with the index attached: raw turns per facet author.
+	stdout, _, err := env.RunCLI("query", "--attach", "--format", "csv",
+		"SELECT f.user_email, count(*) AS n FROM turns t JOIN index_db.session_facets f ON f.session_id = t.session_id WHERE t.session_id = 'test-session-1' GROUP BY 1")
+	if err != nil {
+		t.Fatalf("query --attach: %v", err)
+	}
+	if want := "user_email,n\nalice@example.com,4\n"; stdout != want {
+		t.Errorf("query --attach = %q, want %q", stdout, want)
+	}
+
+	// Index DB with the data DB attached.
+	stdout, _, err = env.RunCLI("query", "--index", "--attach", "--format", "csv",
+		"SELECT s.branch, count(*) AS n FROM turns_ft i JOIN data_db.sessions s ON s.id = i.session_id WHERE s.id = 'test-session-1' GROUP BY 1")
+	if err != nil {
+		t.Fatalf("query --index --attach: %v", err)
+	}
+	if want := "branch,n\nfeature/auth,4\n"; stdout != want {
+		t.Errorf("query --index --attach = %q, want %q", stdout, want)
+	}
+
+	// Without --attach the other DB is not there.
+	if _, _, err := env.RunCLI("query", "SELECT count(*) FROM index_db.session_facets"); err == nil {
+		t.Error("index_db should not be attached without --attach")
+	}
+
+	if err := os.Remove(filepath.Join(env.RepoDir, ".rekal", "index.db")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := env.RunCLI("query", "--attach", "SELECT 1"); err == nil || !strings.Contains(err.Error(), "rekal index") {
+		t.Errorf("--attach without an index DB: err %v, want a pointer to rekal index", err)
+	}
+}
+
 func TestQuery_SessionDrilldown_Full(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
@@ -288,7 +2049,7 @@ func seedData(t *testing.T, env *TestEnv) {
 	defer dataDB.Close()
 
 	// Session 1: JWT auth topic.
-	if err := db.InsertSession(dataDB, "test-session-1", "", "hash1", "human", "", "alice@example.com", "feature/auth", "2026-02-25T10:00:00Z"); err != nil {
+	if err := db.InsertSession(dataDB, "test-session-1", "", "hash1", "human", "", "alice@example.com", "feature/auth", "", "2026-02-25T10:00:00Z", 0, 0); err != nil {
 		t.Fatalf("insert session: %v", err)
 	}
 	if err := db.InsertTurn(dataDB, "turn-1", "test-session-1", 0, "human", "fix the JWT expiry bug in the auth middleware", "2026-02-25T10:00:00Z"); err != nil {
@@ -303,15 +2064,15 @@ func seedData(t *testing.T, env *TestEnv) {
 	if err := db.InsertTurn(dataDB, "turn-2c", "test-session-1", 3, "assistant", "I'll update the refresh endpoint to use the new expiry configuration.", "2026-02-25T10:03:00Z"); err != nil {
 		t.Fatalf("insert turn: %v", err)
 	}
-	if err := db.InsertToolCall(dataDB, "tc-1", "test-session-1", 0, "Read", "src/auth/middleware.go", ""); err != nil {
+	if err := db.InsertToolCall(dataDB, "tc-1", "test-session-1", 0, "Read", "", "src/auth/middleware.go", "", false, ""); err != nil {
 		t.Fatalf("insert tool_call: %v", err)
 	}
-	if err := db.InsertToolCall(dataDB, "tc-2", "test-session-1", 1, "Edit", "src/auth/jwt.go", ""); err != nil {
+	if err := db.InsertToolCall(dataDB, "tc-2", "test-session-1", 1, "Edit", "", "src/auth/jwt.go", "", false, ""); err != nil {
 		t.Fatalf("insert tool_call: %v", err)
 	}
 
 	// Session 2: DB topic.
-	if err := db.InsertSession(dataDB, "test-session-2", "", "hash2", "human", "", "bob@example.com", "feature/db", "2026-02-25T11:00:00Z"); err != nil {
+	if err := db.InsertSession(dataDB, "test-session-2", "", "hash2", "human", "", "bob@example.com", "feature/db", "", "2026-02-25T11:00:00Z", 0, 0); err != nil {
 		t.Fatalf("insert session: %v", err)
 	}
 	if err := db.InsertTurn(dataDB, "turn-3", "test-session-2", 0, "human", "optimize the database connection pooling", "2026-02-25T11:00:00Z"); err != nil {
@@ -343,3 +2104,182 @@ func seedData(t *testing.T, env *TestEnv) {
 		t.Fatalf("insert checkpoint_session: %v", err)
 	}
 }
+
+func TestRecall_Semantic(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// A single session is too few for LSA, so there is nothing to rank by.
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "lone-session", "", "hash-lone", "human", "", "alice@example.com", "main", "", "2026-02-25T09:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-lone", "lone-session", 0, "human", "tune the database connection pool", "2026-02-25T09:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	dataDB.Close()
+	if _, _, err := env.RunCLI("--semantic", "database pooling"); err == nil {
+		t.Fatal("expected --semantic to fail without embeddings")
+	} else if !strings.Contains(err.Error(), "run 'rekal index'") {
+		t.Errorf("error should point at rekal index, got: %v", err)
+	}
+
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	stdout, _, err := env.RunCLI("--semantic", "database pooling")
+	if err != nil {
+		t.Fatalf("recall --semantic: %v", err)
+	}
+	var out struct {
+		Mode    string `json:"mode"`
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if out.Mode != "semantic" {
+		t.Errorf("mode = %q, want semantic", out.Mode)
+	}
+	rank := map[string]int{}
+	for i, r := range out.Results {
+		rank[r.SessionID] = i + 1
+	}
+	if rank["test-session-2"] == 0 || rank["test-session-1"] != 0 && rank["test-session-1"] < rank["test-session-2"] {
+		t.Errorf("expected the pooling session above the JWT session, got: %s", stdout)
+	}
+
+	if _, _, err := env.RunCLI("--semantic", "--author", "alice@example.com"); err == nil {
+		t.Error("expected --semantic without a query to fail")
+	}
+}
+
+// lsaTestTerm is the k-th made-up term for TestRecall_SemanticAtConfiguredLSADim,
+// spelled in letters so the tokenizer keeps it whole.
+func lsaTestTerm(k int) string {
+	return "topic" + string(rune('a'+k/26)) + string(rune('a'+k%26))
+}
+
+func TestRecall_WarnsWhenIndexIsStale(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	seedData(t, env)
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if out, _, err := env.RunCLI("query", "--index", "SELECT value FROM index_state WHERE key = 'newest_captured_at'"); err != nil || !strings.Contains(out, `"value":"2026-02-25T11:00:00Z"`) {
+		t.Errorf("newest_captured_at = %q (%v), want test-session-2's capture time", out, err)
+	}
+
+	if _, stderr, err := env.RunCLI("JWT"); err != nil {
+		t.Fatalf("recall: %v", err)
+	} else if strings.Contains(stderr, "stale") {
+		t.Errorf("fresh index reported stale: %s", stderr)
+	}
+
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	if err := db.InsertSession(dataDB, "late-session", "", "hash-late", "human", "", "alice@example.com", "main", "", "2026-02-26T09:00:00Z", 0, 0); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if err := db.InsertTurn(dataDB, "turn-late", "late-session", 0, "human", "rotate the JWT signing key", "2026-02-26T09:00:00Z"); err != nil {
+		t.Fatalf("insert turn: %v", err)
+	}
+	dataDB.Close()
+
+	stdout, stderr, err := env.RunCLI("JWT")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if !strings.Contains(stderr, "index is stale: 1 session(s) captured since it was last updated; run 'rekal index'") {
+		t.Errorf("expected a stale index warning, stderr: %q", stderr)
+	}
+	if strings.Contains(stdout, "late-session") {
+		t.Errorf("recall should search the index as it is, without late-session: %s", stdout)
+	}
+
+	// Once indexed, the warning goes away.
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("JWT"); err != nil {
+		t.Fatalf("recall: %v", err)
+	} else if strings.Contains(stderr, "stale") {
+		t.Errorf("updated index reported stale: %s", stderr)
+	}
+}
+
+func TestRecall_SemanticAtConfiguredLSADim(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	// A ring of sessions, each sharing one term with the next, so LSA has
+	// enough sessions and terms for 64 dimensions.
+	const n = 70
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open data db: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		sid := fmt.Sprintf("ring-%02d", i)
+		if err := db.InsertSession(dataDB, sid, "", "hash-"+sid, "human", "", "alice@example.com", "main", "", "2026-02-25T09:00:00Z", 0, 0); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+		content := lsaTestTerm(i) + " " + lsaTestTerm((i+1)%n)
+		if err := db.InsertTurn(dataDB, "turn-"+sid, sid, 0, "human", content, "2026-02-25T09:00:00Z"); err != nil {
+			t.Fatalf("insert turn: %v", err)
+		}
+	}
+	dataDB.Close()
+
+	if err := exec.Command("git", "-C", env.RepoDir, "config", "rekal.lsaDim", "64").Run(); err != nil {
+		t.Fatalf("git config: %v", err)
+	}
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+	stdout, _, err := env.RunCLI("query", "--index", "SELECT DISTINCT len(embedding) AS dim FROM session_embeddings WHERE model = 'lsa-v1'")
+	if err != nil || strings.TrimSpace(stdout) != `{"dim":64}` {
+		t.Fatalf("stored LSA dimension: %q, %v; want 64", stdout, err)
+	}
+
+	// Without the saved model, recall rebuilds one; it must be at the
+	// stored vectors' 64 dimensions, not the default 128.
+	indexDB, err := db.OpenIndex(env.RepoDir)
+	if err != nil {
+		t.Fatalf("open index db: %v", err)
+	}
+	if _, err := indexDB.Exec("DELETE FROM lsa_model"); err != nil {
+		t.Fatalf("delete lsa model: %v", err)
+	}
+	indexDB.Close()
+
+	stdout, _, err = env.RunCLI("--semantic", lsaTestTerm(10))
+	if err != nil {
+		t.Fatalf("recall --semantic: %v", err)
+	}
+	var out struct {
+		Results []struct {
+			SessionID string `json:"session_id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+	}
+	if len(out.Results) < 2 {
+		t.Fatalf("expected semantic hits, got: %s", stdout)
+	}
+	top := map[string]bool{out.Results[0].SessionID: true, out.Results[1].SessionID: true}
+	if !top["ring-09"] || !top["ring-10"] {
+		t.Errorf("expected ring-09 and ring-10, which mention %s, ranked first, got: %s", lsaTestTerm(10), stdout)
+	}
+}
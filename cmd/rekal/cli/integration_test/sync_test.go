@@ -3,8 +3,14 @@
 package integration
 
 import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 )
 
 func TestSync_Team_NoRemote(t *testing.T) {
@@ -48,4 +54,397 @@ func TestSync_Team_RebuildIndex(t *testing.T) {
 	if !strings.Contains(stderr, "indexing local data") {
 		t.Errorf("expected index rebuild message, got: %q", stderr)
 	}
+
+	// The rebuild records its manifest, as rekal index does.
+	data, err := os.ReadFile(filepath.Join(env.RepoDir, ".rekal", "index.manifest.json"))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), `"build": "full"`) {
+		t.Errorf("manifest should record a full build:\n%s", data)
+	}
+}
+
+func TestPushSync_SecondRemote(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	// origin and a second remote, "mirror".
+	bareDirs := map[string]string{}
+	for _, name := range []string{"origin", "mirror"} {
+		dir := t.TempDir()
+		if err := exec.Command("git", "init", "--bare", dir).Run(); err != nil {
+			t.Fatalf("git init --bare: %v", err)
+		}
+		if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", name, dir).Run(); err != nil {
+			t.Fatalf("git remote add %s: %v", name, err)
+		}
+		bareDirs[name] = dir
+	}
+
+	branch := "rekal/test@rekal.dev"
+	_, stderr, err := env.RunCLI("push", "--remote", "mirror")
+	if err != nil {
+		t.Fatalf("push --remote mirror: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "pushed to mirror/"+branch) {
+		t.Errorf("expected push to mirror, got: %q", stderr)
+	}
+	if err := exec.Command("git", "--git-dir", bareDirs["mirror"], "rev-parse", "--verify", branch).Run(); err != nil {
+		t.Errorf("mirror should have %s: %v", branch, err)
+	}
+	if err := exec.Command("git", "--git-dir", bareDirs["origin"], "rev-parse", "--verify", branch).Run(); err == nil {
+		t.Errorf("origin should not have %s after pushing to mirror", branch)
+	}
+
+	// An unknown remote is an error, not a silent skip.
+	_, _, err = env.RunCLI("push", "--remote", "missing")
+	if err == nil || !strings.Contains(err.Error(), "no remote 'missing' configured") {
+		t.Errorf("push --remote missing: expected unknown remote error, got %v", err)
+	}
+	_, _, err = env.RunCLI("sync", "--remote", "missing")
+	if err == nil || !strings.Contains(err.Error(), "no remote 'missing' configured") {
+		t.Errorf("sync --remote missing: expected unknown remote error, got %v", err)
+	}
+
+	// Self sync reads the branch back from mirror.
+	_, stderr, err = env.RunCLI("sync", "--self", "--remote", "mirror")
+	if err != nil {
+		t.Fatalf("sync --self --remote mirror: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "from mirror/"+branch) {
+		t.Errorf("expected import from mirror, got: %q", stderr)
+	}
+
+	// Team sync through mirror fetches its rekal refs.
+	_, stderr, err = env.RunCLI("sync", "--remote", "mirror")
+	if err != nil {
+		t.Fatalf("sync --remote mirror: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "synced") {
+		t.Errorf("expected sync summary, got: %q", stderr)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "rev-parse", "--verify", "refs/remotes/mirror/"+branch).Run(); err != nil {
+		t.Errorf("team sync should fetch mirror/%s: %v", branch, err)
+	}
+}
+
+func TestSync_ProgressJSON(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	cleanup1 := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup1()
+	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	defer cleanup2()
+	gitCommit(t, env.RepoDir, "fix auth")
+
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	_, stderr, err := env.RunCLI("sync", "--progress", "json")
+	if err != nil {
+		t.Fatalf("sync --progress json: %v (stderr: %s)", err, stderr)
+	}
+
+	type event struct {
+		Phase  string `json:"phase"`
+		Status string `json:"status"`
+		Count  int    `json:"count"`
+	}
+	events := map[string]event{}
+	var order []string
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var ev event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("stderr line is not JSON: %q", line)
+		}
+		events[ev.Phase] = ev
+		order = append(order, ev.Phase)
+	}
+
+	want := []string{"checkpoint", "push", "fetch", "index-local", "import-remote", "fts", "lsa", "nomic", "sync"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("phases: got %v, want %v", order, want)
+	}
+	for phase, wantCount := range map[string]int{
+		"checkpoint":    2,
+		"push":          1,
+		"index-local":   2,
+		"import-remote": 0,
+		"sync":          2,
+	} {
+		ev := events[phase]
+		if ev.Status != "done" || ev.Count != wantCount {
+			t.Errorf("%s: got %+v, want done with count %d", phase, ev, wantCount)
+		}
+	}
+	if ev := events["fts"]; ev.Status != "done" || ev.Count == 0 {
+		t.Errorf("fts: got %+v, want done with turns", ev)
+	}
+	// Two tiny sessions may not give LSA enough terms to build a model.
+	if ev := events["lsa"]; ev.Status != "done" && ev.Status != "skipped" {
+		t.Errorf("lsa: got %+v", ev)
+	}
+
+	if _, _, err := env.RunCLI("sync", "--progress", "xml"); err == nil {
+		t.Error("sync --progress xml should fail")
+	}
+}
+
+// addBareOrigin adds a fresh bare repository as env's origin and returns
+// its path.
+func addBareOrigin(t *testing.T, env *TestEnv) string {
+	t.Helper()
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	return bareDir
+}
+
+// cloneTeammate clones bareDir as the user with email and runs init there.
+func cloneTeammate(t *testing.T, bareDir, email string) *TestEnv {
+	t.Helper()
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	if err := exec.Command("git", "clone", bareDir, dir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{{"user.email", email}, {"user.name", "Mate"}} {
+		if err := exec.Command("git", "-C", dir, "config", kv[0], kv[1]).Run(); err != nil {
+			t.Fatalf("git config: %v", err)
+		}
+	}
+	env := NewTestEnvAt(t, dir)
+	env.Init()
+	return env
+}
+
+func TestSync_SinceSkipsOlderTeamSessions(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	bareDir := addBareOrigin(t, env)
+
+	defer writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)()
+	defer writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)()
+	gitCommit(t, env.RepoDir, "fix auth")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	// Backdate the main-branch session so the pushed frames carry two capture dates.
+	dataDB, err := db.OpenData(env.RepoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := dataDB.Exec("UPDATE sessions SET captured_at = '2025-01-10T09:00:00Z' WHERE branch = 'main'")
+	if err != nil {
+		dataDB.Close()
+		t.Fatalf("backdate session: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		dataDB.Close()
+		t.Fatalf("backdate session: %d rows updated, want 1", n)
+	}
+	dataDB.Close()
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	mate := cloneTeammate(t, bareDir, "mate@rekal.dev")
+
+	teamSessions := func() string {
+		t.Helper()
+		stdout, _, err := mate.RunCLI("query", "--index",
+			"SELECT string_agg(git_branch, ',' ORDER BY git_branch) AS branches FROM session_facets WHERE user_email = 'test@rekal.dev'")
+		if err != nil {
+			t.Fatalf("query --index: %v", err)
+		}
+		return stdout
+	}
+
+	if _, stderr, err := mate.RunCLI("sync", "--since", "2026-01-01T00:00:00Z"); err != nil {
+		t.Fatalf("sync --since: %v (stderr: %s)", err, stderr)
+	}
+	if got := teamSessions(); !strings.Contains(got, `"branches":"feature/logging"`) {
+		t.Errorf("sync --since should import only the recent session, got %s", got)
+	}
+	// The checkpoint is recent, but the old session's files stay out with it.
+	stdout, _, err := mate.RunCLI("query", "--index", "SELECT count(DISTINCT session_id) AS n FROM files_index")
+	if err != nil || !strings.Contains(stdout, `"n":1`) {
+		t.Errorf("only the recent session's files should be indexed: %s, %v", stdout, err)
+	}
+
+	// Without --since the rebuilt index holds the whole history again.
+	if _, stderr, err := mate.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if got := teamSessions(); !strings.Contains(got, `"branches":"feature/logging,main"`) {
+		t.Errorf("sync should import every session, got %s", got)
+	}
+	stdout, _, err = mate.RunCLI("query", "--index", "SELECT count(DISTINCT session_id) AS n FROM files_index")
+	if err != nil || !strings.Contains(stdout, `"n":2`) {
+		t.Errorf("both sessions' files should be indexed: %s, %v", stdout, err)
+	}
+
+	if _, _, err := mate.RunCLI("sync", "--since", "yesterday"); err == nil {
+		t.Error("sync --since yesterday should fail")
+	}
+	if _, _, err := mate.RunCLI("sync", "--self", "--since", "7d"); err == nil {
+		t.Error("sync --self --since should fail")
+	}
+}
+
+func TestSync_CanonicalizesTeammateEmails(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	bareDir := addBareOrigin(t, env)
+
+	defer writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)()
+	gitCommit(t, env.RepoDir, "fix auth")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	// The teammate knows test@rekal.dev as an alias of tess@corp.com.
+	mate := cloneTeammate(t, bareDir, "mate@rekal.dev")
+	aliases := "test@rekal.dev = tess@corp.com\n"
+	if err := os.WriteFile(filepath.Join(mate.RepoDir, ".rekal", "aliases"), []byte(aliases), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := mate.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+
+	// Either email finds the session, filed under the canonical one.
+	for _, author := range []string{"tess@corp.com", "test@rekal.dev"} {
+		stdout, stderr, err := mate.RunCLI("--author", author, "auth bug")
+		if err != nil {
+			t.Fatalf("recall --author %s: %v\nstderr: %s", author, err, stderr)
+		}
+		var out struct {
+			Results []struct {
+				Session struct {
+					Author string `json:"author"`
+				} `json:"session"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			t.Fatalf("parse recall output: %v\n%s", err, stdout)
+		}
+		if len(out.Results) == 0 {
+			t.Fatalf("--author %s: no results\n%s", author, stdout)
+		}
+		for _, r := range out.Results {
+			if r.Session.Author != "tess@corp.com" {
+				t.Errorf("--author %s: result author %q, want the canonical tess@corp.com", author, r.Session.Author)
+			}
+		}
+	}
+}
+
+func TestTag_TeammateSession(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	bareDir := addBareOrigin(t, env)
+
+	defer writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)()
+	gitCommit(t, env.RepoDir, "fix auth")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	mate := cloneTeammate(t, bareDir, "mate@rekal.dev")
+	if _, stderr, err := mate.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+
+	// The session is only in the teammate's index, not their data DB.
+	stdout, _, err := mate.RunCLI("query", "--index", "SELECT session_id FROM session_facets")
+	if err != nil {
+		t.Fatalf("query --index: %v", err)
+	}
+	var row struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &row); err != nil {
+		t.Fatalf("index session: %s, %v", stdout, err)
+	}
+	id := row.SessionID
+
+	_, stderr, err := mate.RunCLI("tag", "--session", id, "bug")
+	if err != nil {
+		t.Fatalf("tag teammate session: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "tagged "+id+": bug") {
+		t.Errorf("tag output: got %q", stderr)
+	}
+	stdout, _, err = mate.RunCLI("--tag", "bug")
+	if err != nil || !strings.Contains(stdout, `"session_id": "`+id+`"`) {
+		t.Errorf("recall --tag bug should find the teammate session: %s, %v", stdout, err)
+	}
+}
+
+func TestRecall_CheckpointTeammateSession(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	bareDir := addBareOrigin(t, env)
+
+	defer writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)()
+	gitCommit(t, env.RepoDir, "fix auth")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	sha, err := exec.Command("git", "-C", env.RepoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+
+	mate := cloneTeammate(t, bareDir, "mate@rekal.dev")
+	if _, stderr, err := mate.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+
+	// The teammate's data DB has no checkpoint links; the index's facets do.
+	stdout, _, err := mate.RunCLI("--checkpoint", string(sha[:7]))
+	if err != nil {
+		t.Fatalf("recall --checkpoint: %v", err)
+	}
+	if !strings.Contains(stdout, `"total": 1`) {
+		t.Errorf("recall --checkpoint should find the teammate session: %s", stdout)
+	}
+	stdout, _, err = mate.RunCLI("--checkpoint", "0000000")
+	if err != nil || !strings.Contains(stdout, `"total": 0`) {
+		t.Errorf("recall --checkpoint for another commit: %s, %v", stdout, err)
+	}
 }
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// repoLockFile is the lock in .rekal/ held while a process writes the data
+// DB or the rekal branch: around checkpoint, push's export and merge, and
+// sync --self's import. The post-commit hook's checkpoint can otherwise
+// race a manual checkpoint, push or sync.
+const repoLockFile = "checkpoint.lock"
+
+// repoLockWait is how long lockRepo waits for another process to finish.
+const repoLockWait = 30 * time.Second
+
+// repoLockPoll is how often lockRepo retries a held lock.
+const repoLockPoll = 50 * time.Millisecond
+
+// errRepoLocked is returned when the lock is still held after repoLockWait.
+var errRepoLocked = errors.New("another rekal process is writing .rekal/; try again when it finishes")
+
+// lockRepo takes the advisory lock on .rekal/checkpoint.lock, waiting up to
+// repoLockWait for another process to release it. The lock is per open
+// file, so a process must not take it twice. It is released by the
+// returned func, or by the OS when the process exits.
+func lockRepo(gitRoot string) (unlock func(), err error) {
+	path := filepath.Join(gitRoot, ".rekal", repoLockFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", repoLockFile, err)
+	}
+	deadline := time.Now().Add(repoLockWait)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", repoLockFile, err)
+		}
+		if ok {
+			return func() {
+				_ = unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, errRepoLocked
+		}
+		time.Sleep(repoLockPoll)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// tryLockFile always succeeds: there is no flock here, so concurrent
+// rekal processes are not serialized.
+func tryLockFile(*os.File) (bool, error) { return true, nil }
+
+func unlockFile(*os.File) error { return nil }
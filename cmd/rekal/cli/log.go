@@ -1,14 +1,21 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/spf13/cobra"
 )
 
 func newLogCmd() *cobra.Command {
-	var limit int
+	var (
+		limit                  int
+		asJSON, files, verbose bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "log",
@@ -16,8 +23,21 @@ func newLogCmd() *cobra.Command {
 		Long: `Show recent checkpoints from the data DB, newest first.
 
 Each entry shows the checkpoint ID, timestamp, git commit SHA, branch,
-author email, and number of sessions captured. Use --limit to control
-how many entries are shown.`,
+author email, number of sessions captured, and their total cost when the
+transcripts recorded one. Use --limit to control how many entries are shown.
+
+--files also lists the files each checkpoint touched with their change
+type (A, M, D or R). --json prints one JSON object per checkpoint instead
+of the text format.
+
+--verbose also shows each checkpoint's footprint on your rekal branch: the
+compressed and decompressed size of its checkpoint frame and of the session
+frames it references, and the compression ratio. Checkpoints that have not
+been pushed have no frames yet.`,
+		Example: `  rekal log --limit 5
+  rekal log --files
+  rekal log --verbose --limit 3
+  rekal log --json --files | jq -r .git_sha`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -31,16 +51,61 @@ how many entries are shown.`,
 				return NewSilentError(err)
 			}
 
-			return runLog(cmd, gitRoot, limit)
+			return runLog(cmd, gitRoot, limit, logOptions{Files: files, JSON: asJSON, Verbose: verbose})
 		},
 	}
 
 	cmd.Flags().IntVar(&limit, "limit", 20, "Max entries to show")
+	cmd.Flags().BoolVar(&files, "files", false, "List the files each checkpoint touched")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print one JSON object per checkpoint")
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Show each checkpoint's compressed frame sizes on the rekal branch")
 	return cmd
 }
 
-func runLog(cmd *cobra.Command, gitRoot string, limit int) error {
-	dataDB, err := db.OpenData(gitRoot)
+// logEntry is one checkpoint as printed by rekal log.
+type logEntry struct {
+	ID        string         `json:"id"`
+	Ts        string         `json:"ts"`
+	GitSHA    string         `json:"git_sha"`
+	Branch    string         `json:"git_branch"`
+	Email     string         `json:"user_email"`
+	ActorType string         `json:"actor_type"`
+	NSessions int            `json:"n_sessions"`
+	TotalCost float64        `json:"total_cost,omitempty"`
+	Files     []exportedFile `json:"files,omitempty"`
+	Wire      *logWire       `json:"wire,omitempty"` // --verbose, once pushed
+}
+
+// logOptions selects what rekal log adds to each entry.
+type logOptions struct {
+	Files   bool
+	JSON    bool
+	Verbose bool
+}
+
+// logWire is a checkpoint's footprint on the rekal branch: its checkpoint
+// frame followed by the session frames it references.
+type logWire struct {
+	Frames       []wireFrame `json:"frames"`
+	Compressed   int         `json:"compressed_bytes"`
+	Uncompressed int         `json:"uncompressed_bytes"`
+	Ratio        float64     `json:"ratio"`
+}
+
+// wireFrame is one frame's size on the wire. Compressed counts the zstd
+// payload, not the 6-byte envelope.
+type wireFrame struct {
+	Type         string  `json:"type"` // "checkpoint" or "session"
+	ID           string  `json:"id"`
+	Shard        string  `json:"shard"`
+	Offset       int     `json:"offset"`
+	Compressed   int     `json:"compressed_bytes"`
+	Uncompressed int     `json:"uncompressed_bytes"`
+	Ratio        float64 `json:"ratio"`
+}
+
+func runLog(cmd *cobra.Command, gitRoot string, limit int, opts logOptions) error {
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open data DB: %w", err)
 	}
@@ -48,9 +113,11 @@ func runLog(cmd *cobra.Command, gitRoot string, limit int) error {
 
 	rows, err := dataDB.Query(
 		`SELECT c.id, c.git_sha, c.git_branch, c.user_email, c.ts, c.actor_type,
-		        count(cs.session_id) as n_sessions
+		        count(cs.session_id) as n_sessions,
+		        COALESCE(sum(s.total_cost), 0) as total_cost
 		 FROM checkpoints c
 		 LEFT JOIN checkpoint_sessions cs ON cs.checkpoint_id = c.id
+		 LEFT JOIN sessions s ON s.id = cs.session_id
 		 GROUP BY c.id, c.git_sha, c.git_branch, c.user_email, c.ts, c.actor_type
 		 ORDER BY c.ts DESC
 		 LIMIT $1`, limit,
@@ -60,21 +127,204 @@ func runLog(cmd *cobra.Command, gitRoot string, limit int) error {
 	}
 	defer rows.Close() //nolint:errcheck
 
+	var entries []logEntry
 	for rows.Next() {
-		var id, gitSHA, branch, email, ts, actorType string
-		var nSessions int
-		if err := rows.Scan(&id, &gitSHA, &branch, &email, &ts, &actorType, &nSessions); err != nil {
+		var e logEntry
+		if err := rows.Scan(&e.ID, &e.GitSHA, &e.Branch, &e.Email, &e.Ts, &e.ActorType, &e.NSessions, &e.TotalCost); err != nil {
 			return fmt.Errorf("scan checkpoint: %w", err)
 		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query checkpoints: %w", err)
+	}
+	rows.Close() //nolint:errcheck
+
+	if opts.Files {
+		for i := range entries {
+			files, err := db.QueryFilesTouched(dataDB, entries[i].ID)
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				entries[i].Files = append(entries[i].Files, exportedFile{Path: f.Path, ChangeType: f.ChangeType})
+			}
+		}
+	}
+
+	if opts.Verbose {
+		frames, err := loadBranchFrames(gitRoot, rekalBranchName())
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			entries[i].Wire = frames.checkpointWire(entries[i].ID)
+		}
+	}
+
+	w := cmd.OutOrStdout()
+	for _, e := range entries {
+		if opts.JSON {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("marshal: %w", err)
+			}
+			fmt.Fprintln(w, string(data))
+			continue
+		}
+		writeLogEntry(w, e, opts.Verbose)
+	}
+	return nil
+}
+
+// branchFrames indexes the checkpoint and session frames on a rekal branch
+// by checkpoint and session ID.
+type branchFrames struct {
+	checkpoints map[string]wireFrame
+	sessionsOf  map[string][]string // checkpoint ID -> session IDs of its frame
+	sessions    map[string]wireFrame
+}
+
+// loadBranchFrames scans every shard on ref and measures its checkpoint and
+// session frames. A ref that has not been pushed yet has no frames. Frames
+// that fail to decode or whose ref is not in dict.bin are skipped, as import
+// skips them.
+func loadBranchFrames(gitRoot, ref string) (*branchFrames, error) {
+	bf := &branchFrames{
+		checkpoints: make(map[string]wireFrame),
+		sessionsOf:  make(map[string][]string),
+		sessions:    make(map[string]wireFrame),
+	}
+	dictData := gitShowFile(gitRoot, ref, "dict.bin")
+	if len(dictData) == 0 {
+		return bf, nil
+	}
+	dict, err := codec.LoadDict(dictData)
+	if err != nil {
+		return nil, fmt.Errorf("load dict: %w", err)
+	}
+	manifest, err := loadManifest(gitRoot, ref)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+
+	dec, release, err := branchDecoder(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	for _, file := range manifest.Files() {
+		body := gitShowFile(gitRoot, ref, file)
+		if len(body) == 0 {
+			continue
+		}
+		frames, err := codec.ScanFrames(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, fs := range frames {
+			payload := codec.ExtractFramePayload(body, fs)
+			wf := wireFrame{Shard: file, Offset: fs.Offset, Compressed: fs.CompressedLen}
+			switch fs.Type {
+			case codec.FrameCheckpoint:
+				cf, err := dec.DecodeCheckpointFrame(payload)
+				if err != nil {
+					continue
+				}
+				if wf.ID, err = dict.Get(codec.NSSessions, cf.CheckpointRef); err != nil {
+					continue
+				}
+				for _, ref := range cf.SessionRefs {
+					if sid, err := dict.Get(codec.NSSessions, ref); err == nil {
+						bf.sessionsOf[wf.ID] = append(bf.sessionsOf[wf.ID], sid)
+					}
+				}
+				wf.Type = "checkpoint"
+			case codec.FrameSession:
+				sf, err := dec.DecodeSessionFrame(payload)
+				if err != nil {
+					continue
+				}
+				if wf.ID, err = dict.Get(codec.NSSessions, sf.SessionRef); err != nil {
+					continue
+				}
+				wf.Type = "session"
+			default:
+				continue
+			}
+			if wf.Uncompressed, err = dec.PayloadLen(payload); err != nil {
+				continue
+			}
+			wf.Ratio = compressionRatio(wf.Uncompressed, wf.Compressed)
+			if wf.Type == "checkpoint" {
+				bf.checkpoints[wf.ID] = wf
+			} else {
+				bf.sessions[wf.ID] = wf
+			}
+		}
+	}
+	return bf, nil
+}
+
+// checkpointWire returns the frames of checkpoint id, or nil if it is not on
+// the branch.
+func (bf *branchFrames) checkpointWire(id string) *logWire {
+	cf, ok := bf.checkpoints[id]
+	if !ok {
+		return nil
+	}
+	lw := &logWire{Frames: []wireFrame{cf}}
+	for _, sid := range bf.sessionsOf[id] {
+		if sf, ok := bf.sessions[sid]; ok {
+			lw.Frames = append(lw.Frames, sf)
+		}
+	}
+	for _, f := range lw.Frames {
+		lw.Compressed += f.Compressed
+		lw.Uncompressed += f.Uncompressed
+	}
+	lw.Ratio = compressionRatio(lw.Uncompressed, lw.Compressed)
+	return lw
+}
 
-		fmt.Fprintf(cmd.OutOrStdout(), "checkpoint %s\n", id)
-		fmt.Fprintf(cmd.OutOrStdout(), "Date:     %s\n", ts)
-		fmt.Fprintf(cmd.OutOrStdout(), "Commit:   %s\n", gitSHA)
-		fmt.Fprintf(cmd.OutOrStdout(), "Branch:   %s\n", branch)
-		fmt.Fprintf(cmd.OutOrStdout(), "Author:   %s\n", email)
-		fmt.Fprintf(cmd.OutOrStdout(), "Sessions: %d\n", nSessions)
-		fmt.Fprintln(cmd.OutOrStdout())
+// compressionRatio is uncompressed/compressed rounded to two decimals, or 0
+// for an empty frame.
+func compressionRatio(uncompressed, compressed int) float64 {
+	if compressed == 0 {
+		return 0
 	}
+	return math.Round(float64(uncompressed)/float64(compressed)*100) / 100
+}
 
-	return rows.Err()
+// writeLogEntry prints one checkpoint in the git-log style text format.
+// verbose adds its frame sizes on the rekal branch.
+func writeLogEntry(w io.Writer, e logEntry, verbose bool) {
+	fmt.Fprintf(w, "checkpoint %s\n", e.ID)
+	fmt.Fprintf(w, "Date:     %s\n", e.Ts)
+	fmt.Fprintf(w, "Commit:   %s\n", e.GitSHA)
+	fmt.Fprintf(w, "Branch:   %s\n", e.Branch)
+	fmt.Fprintf(w, "Author:   %s\n", e.Email)
+	fmt.Fprintf(w, "Sessions: %d\n", e.NSessions)
+	if e.TotalCost > 0 {
+		fmt.Fprintf(w, "Cost:     $%.2f\n", e.TotalCost)
+	}
+	if verbose {
+		if e.Wire == nil {
+			fmt.Fprintln(w, "Wire:     not pushed")
+		} else {
+			fmt.Fprintf(w, "Wire:     %d frame(s), %d bytes compressed, %d decompressed (%.2fx)\n",
+				len(e.Wire.Frames), e.Wire.Compressed, e.Wire.Uncompressed, e.Wire.Ratio)
+			for _, f := range e.Wire.Frames {
+				fmt.Fprintf(w, "    %-10s %-26s %7d / %7d bytes (%.2fx)\n", f.Type, f.ID, f.Compressed, f.Uncompressed, f.Ratio)
+			}
+		}
+	}
+	if len(e.Files) > 0 {
+		fmt.Fprintln(w)
+		for _, f := range e.Files {
+			fmt.Fprintf(w, "    %s  %s\n", f.ChangeType, f.Path)
+		}
+	}
+	fmt.Fprintln(w)
 }
@@ -0,0 +1,199 @@
+package lsa
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	modelMagic   = "RKLLSA"
+	modelVersion = 0x01
+)
+
+// MarshalBinary serializes the model so it can be stored in the index DB and
+// loaded by recall without rebuilding the SVD.
+//
+// Layout: 6-byte magic + version, tokenizer string, dim, terms in column
+// order, IDF, Uk (terms × dim, row-major), Sk, session IDs, Vk (sessions ×
+// dim, row-major). Strings and counts are uvarints; floats are little-endian
+// float64.
+func (m *Model) MarshalBinary() ([]byte, error) {
+	nTerms := len(m.Vocabulary)
+	if len(m.IDF) != nTerms || len(m.Sk) != m.Dim {
+		return nil, errors.New("lsa model: inconsistent dimensions")
+	}
+	if r, c := m.Uk.Dims(); r != nTerms || c != m.Dim {
+		return nil, fmt.Errorf("lsa model: Uk is %dx%d, want %dx%d", r, c, nTerms, m.Dim)
+	}
+	if r, c := m.Vk.Dims(); r != len(m.SessionIDs) || c != m.Dim {
+		return nil, fmt.Errorf("lsa model: Vk is %dx%d, want %dx%d", r, c, len(m.SessionIDs), m.Dim)
+	}
+
+	terms := make([]string, nTerms)
+	for term, col := range m.Vocabulary {
+		if col < 0 || col >= nTerms || terms[col] != "" {
+			return nil, fmt.Errorf("lsa model: bad vocabulary column %d for %q", col, term)
+		}
+		terms[col] = term
+	}
+
+	buf := make([]byte, 0, 8*(nTerms*(m.Dim+1)+len(m.SessionIDs)*m.Dim+m.Dim)+16*nTerms)
+	buf = append(buf, modelMagic...)
+	buf = append(buf, modelVersion)
+	buf = appendString(buf, m.Tokenizer.String())
+	buf = binary.AppendUvarint(buf, uint64(m.Dim))
+	buf = binary.AppendUvarint(buf, uint64(nTerms))
+	for _, term := range terms {
+		buf = appendString(buf, term)
+	}
+	buf = appendFloats(buf, m.IDF)
+	buf = appendDense(buf, m.Uk)
+	buf = appendFloats(buf, m.Sk)
+	buf = binary.AppendUvarint(buf, uint64(len(m.SessionIDs)))
+	for _, id := range m.SessionIDs {
+		buf = appendString(buf, id)
+	}
+	buf = appendDense(buf, m.Vk)
+	return buf, nil
+}
+
+// UnmarshalBinary loads a model written by MarshalBinary.
+func (m *Model) UnmarshalBinary(data []byte) error {
+	if len(data) < len(modelMagic)+1 || string(data[:len(modelMagic)]) != modelMagic {
+		return errors.New("lsa model: bad magic")
+	}
+	if v := data[len(modelMagic)]; v != modelVersion {
+		return fmt.Errorf("lsa model: unsupported version %d", v)
+	}
+	r := &modelReader{data: data, pos: len(modelMagic) + 1}
+
+	tok, err := ParseTokenizerConfig(r.string())
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return fmt.Errorf("lsa model: %w", err)
+	}
+	dim := r.count()
+	nTerms := r.count()
+	vocab := make(map[string]int, nTerms)
+	for i := 0; i < nTerms && r.err == nil; i++ {
+		vocab[r.string()] = i
+	}
+	idf := r.floats(nTerms)
+	uk := r.floats(nTerms * dim)
+	sk := r.floats(dim)
+	nSessions := r.count()
+	sessionIDs := make([]string, 0, nSessions)
+	for i := 0; i < nSessions && r.err == nil; i++ {
+		sessionIDs = append(sessionIDs, r.string())
+	}
+	vk := r.floats(nSessions * dim)
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos != len(data) {
+		return fmt.Errorf("lsa model: %d trailing bytes", len(data)-r.pos)
+	}
+	if len(vocab) != nTerms || nTerms == 0 || dim == 0 || nSessions == 0 {
+		return errors.New("lsa model: empty or duplicate terms")
+	}
+
+	*m = Model{
+		Vocabulary: vocab,
+		IDF:        idf,
+		Uk:         mat.NewDense(nTerms, dim, uk),
+		Sk:         sk,
+		Vk:         mat.NewDense(nSessions, dim, vk),
+		SessionIDs: sessionIDs,
+		Dim:        dim,
+		Tokenizer:  tok,
+	}
+	return nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendFloats(buf []byte, fs []float64) []byte {
+	for _, f := range fs {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+	return buf
+}
+
+// appendDense appends the matrix's elements in row-major order.
+func appendDense(buf []byte, d *mat.Dense) []byte {
+	raw := d.RawMatrix()
+	for i := 0; i < raw.Rows; i++ {
+		buf = appendFloats(buf, raw.Data[i*raw.Stride:i*raw.Stride+raw.Cols])
+	}
+	return buf
+}
+
+// modelReader decodes UnmarshalBinary's fields. The first error sticks and
+// later reads return zero values.
+type modelReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *modelReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = fmt.Errorf("lsa model: truncated varint at byte %d", r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+// count reads a length and rejects values that could not fit in the data.
+func (r *modelReader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.err = fmt.Errorf("lsa model: count %d exceeds data size", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (r *modelReader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+	if r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("lsa model: truncated string at byte %d", r.pos)
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func (r *modelReader) floats(n int) []float64 {
+	if r.err != nil {
+		return nil
+	}
+	if n > (len(r.data)-r.pos)/8 {
+		r.err = fmt.Errorf("lsa model: truncated floats at byte %d", r.pos)
+		return nil
+	}
+	fs := make([]float64, n)
+	for i := range fs {
+		fs[i] = math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+	}
+	return fs
+}
@@ -0,0 +1,92 @@
+package lsa
+
+import (
+	"testing"
+)
+
+func TestModel_BinaryRoundtrip(t *testing.T) {
+	t.Parallel()
+	sessions := map[string]string{
+		"s1": "JWT authentication token expiry refresh login security middleware",
+		"s2": "JWT token validation auth middleware bearer header claims expiry",
+		"s3": "database connection pooling query optimization index performance SQL",
+		"s4": "database schema migration table column index query performance tuning",
+	}
+	tok := TokenizerConfig{Stem: false, Stopwords: true, MinLength: 3}
+	model, err := BuildWith(sessions, 3, tok)
+	if err != nil || model == nil {
+		t.Fatalf("BuildWith: model=%v err=%v", model, err)
+	}
+
+	data, err := model.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var loaded Model
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if loaded.Dim != model.Dim || loaded.Tokenizer != tok {
+		t.Errorf("dim/tokenizer: got %d/%v, want %d/%v", loaded.Dim, loaded.Tokenizer, model.Dim, tok)
+	}
+	if len(loaded.Vocabulary) != len(model.Vocabulary) {
+		t.Fatalf("vocabulary: got %d terms, want %d", len(loaded.Vocabulary), len(model.Vocabulary))
+	}
+	for term, col := range model.Vocabulary {
+		if loaded.Vocabulary[term] != col {
+			t.Errorf("vocabulary[%q]: got %d, want %d", term, loaded.Vocabulary[term], col)
+		}
+	}
+
+	// The loaded model must project queries and sessions identically.
+	for _, q := range []string{"JWT authentication", "database index", ""} {
+		want, got := model.Embed(q), loaded.Embed(q)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Embed(%q)[%d]: got %v, want %v", q, i, got[i], want[i])
+			}
+		}
+	}
+	wantVecs, gotVecs := model.Vectors(), loaded.Vectors()
+	for id, want := range wantVecs {
+		got := gotVecs[id]
+		if len(got) != len(want) {
+			t.Fatalf("vector %s: got %d dims, want %d", id, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("vector %s[%d]: got %v, want %v", id, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestModel_UnmarshalBinary_Invalid(t *testing.T) {
+	t.Parallel()
+	model, err := Build(map[string]string{
+		"s1": "alpha beta gamma delta",
+		"s2": "alpha beta gamma epsilon",
+	}, 2)
+	if err != nil || model == nil {
+		t.Fatalf("Build: model=%v err=%v", model, err)
+	}
+	valid, err := model.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"bad magic": append([]byte("XXXXXX"), valid[6:]...),
+		"version":   append(append([]byte{}, valid[:6]...), 0x09),
+		"truncated": valid[:len(valid)-1],
+		"trailing":  append(append([]byte{}, valid...), 0x00),
+	}
+	for name, data := range tests {
+		var m Model
+		if err := m.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package lsa
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"strings"
@@ -32,11 +33,19 @@ type Model struct {
 	SessionIDs []string
 	// Dim is the actual dimensionality used (may be < DefaultDimension).
 	Dim int
+	// Tokenizer is the tokenizer the model was built with; Embed uses it too.
+	Tokenizer Tokenizer
 }
 
-// Build constructs an LSA model from session_id → concatenated content.
+// Build constructs an LSA model from session_id → concatenated content
+// using DefaultTokenizer.
 // Returns nil model if there are too few sessions or terms.
 func Build(sessions map[string]string, dim int) (*Model, error) {
+	return BuildWith(sessions, dim, DefaultTokenizer)
+}
+
+// BuildWith is Build with an explicit tokenizer.
+func BuildWith(sessions map[string]string, dim int, tok Tokenizer) (*Model, error) {
 	if len(sessions) < 2 {
 		return nil, nil
 	}
@@ -56,7 +65,7 @@ func Build(sessions map[string]string, dim int) (*Model, error) {
 	df := make(map[string]int)                              // document frequency
 
 	for i, id := range sessionIDs {
-		tokens := Tokenize(sessions[id])
+		tokens := tok.Tokenize(sessions[id])
 		tf := make(map[string]float64)
 		for _, tok := range tokens {
 			tf[tok]++
@@ -170,12 +179,13 @@ func Build(sessions map[string]string, dim int) (*Model, error) {
 		Vk:         vk,
 		SessionIDs: sessionIDs,
 		Dim:        actualDim,
+		Tokenizer:  tok,
 	}, nil
 }
 
 // Embed projects a query string into the LSA space, returning a k-dimensional vector.
 func (m *Model) Embed(text string) []float64 {
-	tokens := Tokenize(text)
+	tokens := m.Tokenizer.Tokenize(text)
 	if len(tokens) == 0 {
 		return make([]float64, m.Dim)
 	}
@@ -234,6 +244,49 @@ func (m *Model) Vectors() map[string][]float64 {
 	return result
 }
 
+// FoldIn projects sessions that were not part of training into the model's
+// space and appends them to SessionIDs and Vk, so Vectors and MarshalBinary
+// include them. The SVD is not recomputed: terms outside the trained
+// vocabulary are ignored, so fold-in drifts from a full rebuild as the corpus
+// grows. Sessions already in the model are skipped. Returns the new
+// sessions' embeddings, scaled like Vectors.
+func (m *Model) FoldIn(sessions map[string]string) map[string][]float64 {
+	known := make(map[string]bool, len(m.SessionIDs))
+	for _, id := range m.SessionIDs {
+		known[id] = true
+	}
+	var ids []string
+	for id := range sessions {
+		if !known[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+
+	oldRows := len(m.SessionIDs)
+	vk := mat.NewDense(oldRows+len(ids), m.Dim, nil)
+	vk.Slice(0, oldRows, 0, m.Dim).(*mat.Dense).Copy(m.Vk)
+
+	result := make(map[string][]float64, len(ids))
+	for i, id := range ids {
+		// A document's row of Vk is its term vector projected by U_k S_k^{-1},
+		// which is exactly what Embed computes for a query.
+		row := m.Embed(sessions[id])
+		vk.SetRow(oldRows+i, row)
+		vec := make([]float64, m.Dim)
+		for j := range vec {
+			vec[j] = row[j] * m.Sk[j]
+		}
+		result[id] = vec
+	}
+	m.Vk = vk
+	m.SessionIDs = append(m.SessionIDs, ids...)
+	return result
+}
+
 // CosineSimilarity computes the cosine similarity between two vectors.
 func CosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) || len(a) == 0 {
@@ -251,36 +304,139 @@ func CosineSimilarity(a, b []float64) float64 {
 	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
-// Tokenize lowercases, splits on non-alphanumeric, removes stopwords,
-// and applies simple stemming.
+// Tokenizer splits text into the terms a model is built from and queried
+// with. String identifies the tokenizer and its settings; it is stored with
+// each model so a query is tokenized the way the model was built.
+type Tokenizer interface {
+	Tokenize(text string) []string
+	String() string
+}
+
+// TokenizerConfig is the Tokenizer rekal ships. The FTS index is
+// built from the same settings (see db.CreateFTSIndex) so BM25 and LSA agree
+// on what counts as a term.
+type TokenizerConfig struct {
+	// Stem applies simple English suffix stripping.
+	Stem bool
+	// Stopwords drops the words returned by Stopwords.
+	Stopwords bool
+	// MinLength drops tokens shorter than this many bytes.
+	MinLength int
+}
+
+// DefaultTokenizer is used unless the index was built with other settings.
+var DefaultTokenizer = TokenizerConfig{Stem: true, Stopwords: true, MinLength: 2}
+
+// namedTokenizers are the presets NamedTokenizer accepts. "english" is the
+// default; "none" does no language-specific processing, so terms are kept
+// exactly as written (lowercased), which suits sessions in other languages.
+var namedTokenizers = map[string]TokenizerConfig{
+	"english": DefaultTokenizer,
+	"none":    {MinLength: 1},
+}
+
+// TokenizerNames returns the preset names in sorted order.
+func TokenizerNames() []string {
+	names := make([]string, 0, len(namedTokenizers))
+	for name := range namedTokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamedTokenizer returns the preset called name.
+func NamedTokenizer(name string) (TokenizerConfig, error) {
+	c, ok := namedTokenizers[name]
+	if !ok {
+		return TokenizerConfig{}, fmt.Errorf("unknown tokenizer %q (want %s)", name, strings.Join(TokenizerNames(), " or "))
+	}
+	return c, nil
+}
+
+// Name returns the name of the preset c matches, or "custom".
+func (c TokenizerConfig) Name() string {
+	for name, preset := range namedTokenizers {
+		if c == preset {
+			return name
+		}
+	}
+	return "custom"
+}
+
+// String encodes the config for storage in index_state,
+// e.g. "stem=1,stopwords=1,min=2".
+func (c TokenizerConfig) String() string {
+	return fmt.Sprintf("stem=%d,stopwords=%d,min=%d", boolInt(c.Stem), boolInt(c.Stopwords), c.MinLength)
+}
+
+// ParseTokenizerConfig parses the output of TokenizerConfig.String.
+func ParseTokenizerConfig(s string) (TokenizerConfig, error) {
+	var c TokenizerConfig
+	var stem, stop int
+	if _, err := fmt.Sscanf(s, "stem=%d,stopwords=%d,min=%d", &stem, &stop, &c.MinLength); err != nil {
+		return TokenizerConfig{}, fmt.Errorf("parse tokenizer config %q: %w", s, err)
+	}
+	c.Stem = stem != 0
+	c.Stopwords = stop != 0
+	return c, nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Tokenize splits text with DefaultTokenizer.
 func Tokenize(text string) []string {
+	return DefaultTokenizer.Tokenize(text)
+}
+
+// Tokenize lowercases, splits on non-alphanumeric, then drops short tokens
+// and stopwords and applies simple stemming as configured.
+func (c TokenizerConfig) Tokenize(text string) []string {
 	text = strings.ToLower(text)
 	var tokens []string
 	var current strings.Builder
 
+	emit := func() {
+		word := current.String()
+		current.Reset()
+		if len(word) < c.MinLength || (c.Stopwords && stopwords[word]) {
+			return
+		}
+		if c.Stem {
+			word = simpleStem(word)
+		}
+		tokens = append(tokens, word)
+	}
+
 	for _, r := range text {
 		if unicode.IsLetter(r) || unicode.IsDigit(r) {
 			current.WriteRune(r)
-		} else {
-			if current.Len() > 0 {
-				word := current.String()
-				current.Reset()
-				if len(word) >= 2 && !stopwords[word] {
-					tokens = append(tokens, simpleStem(word))
-				}
-			}
+		} else if current.Len() > 0 {
+			emit()
 		}
 	}
 	if current.Len() > 0 {
-		word := current.String()
-		if len(word) >= 2 && !stopwords[word] {
-			tokens = append(tokens, simpleStem(word))
-		}
+		emit()
 	}
 
 	return tokens
 }
 
+// Stopwords returns the stopword list in sorted order.
+func Stopwords() []string {
+	words := make([]string, 0, len(stopwords))
+	for w := range stopwords {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
+
 // simpleStem applies basic English suffix stripping.
 func simpleStem(word string) string {
 	// Very basic suffix removal — enough for LSA to group related terms.
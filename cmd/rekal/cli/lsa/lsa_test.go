@@ -56,6 +56,116 @@ func TestTokenize_Numbers(t *testing.T) {
 	}
 }
 
+func TestTokenizerConfig_Options(t *testing.T) {
+	t.Parallel()
+	text := "the failing tests in a package"
+
+	raw := TokenizerConfig{MinLength: 1}.Tokenize(text)
+	want := []string{"the", "failing", "tests", "in", "a", "package"}
+	if len(raw) != len(want) {
+		t.Fatalf("raw tokens: got %v, want %v", raw, want)
+	}
+	for i := range want {
+		if raw[i] != want[i] {
+			t.Errorf("raw token %d: got %q, want %q", i, raw[i], want[i])
+		}
+	}
+
+	noStem := TokenizerConfig{Stopwords: true, MinLength: 2}.Tokenize(text)
+	for _, tok := range noStem {
+		if tok == "the" || tok == "in" || tok == "a" {
+			t.Errorf("stopword or short token %q not dropped: %v", tok, noStem)
+		}
+	}
+	if len(noStem) != 3 || noStem[0] != "failing" {
+		t.Errorf("unstemmed tokens: got %v", noStem)
+	}
+
+	long := TokenizerConfig{MinLength: 6}.Tokenize(text)
+	if len(long) != 2 {
+		t.Errorf("min length 6: got %v", long)
+	}
+}
+
+func TestNamedTokenizer_None(t *testing.T) {
+	t.Parallel()
+	none, err := NamedTokenizer("none")
+	if err != nil {
+		t.Fatalf("NamedTokenizer: %v", err)
+	}
+	got := none.Tokenize("Retrying the flaky tests in a Überprüfung, x")
+	want := []string{"retrying", "the", "flaky", "tests", "in", "a", "überprüfung", "x"}
+	if len(got) != len(want) {
+		t.Fatalf("tokens: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if none.Name() != "none" {
+		t.Errorf("Name() = %q, want none", none.Name())
+	}
+
+	// Terms the english tokenizer would fold together stay apart.
+	english, _ := NamedTokenizer("english")
+	if english != DefaultTokenizer || english.Name() != "english" {
+		t.Errorf("english preset should be DefaultTokenizer, got %+v", english)
+	}
+	if a, b := english.Tokenize("retrying"), english.Tokenize("retry"); a[0] != b[0] {
+		t.Fatalf("test assumes english stems both alike, got %v and %v", a, b)
+	}
+	if a, b := none.Tokenize("retrying"), none.Tokenize("retry"); a[0] == b[0] {
+		t.Errorf("none tokenizer stemmed: %v and %v", a, b)
+	}
+
+	if (TokenizerConfig{MinLength: 3}).Name() != "custom" {
+		t.Error("settings matching no preset should be named custom")
+	}
+	if _, err := NamedTokenizer("klingon"); err == nil {
+		t.Error("expected error for unknown tokenizer")
+	}
+}
+
+func TestBuildWith_TokenizerNone(t *testing.T) {
+	t.Parallel()
+	sessions := map[string]string{
+		"s1": "retrying the upload retrying the upload",
+		"s2": "retries for the upload retries",
+		"s3": "retrying the download",
+	}
+	none, _ := NamedTokenizer("none")
+	model, err := BuildWith(sessions, 2, none)
+	if err != nil || model == nil {
+		t.Fatalf("BuildWith: model=%v err=%v", model, err)
+	}
+	if model.Tokenizer != none {
+		t.Errorf("model tokenizer: got %v, want %v", model.Tokenizer, none)
+	}
+	if _, ok := model.Vocabulary["retrying"]; !ok {
+		t.Errorf("exact term missing from vocabulary: %v", model.Vocabulary)
+	}
+	if _, ok := model.Vocabulary["retry"]; ok {
+		t.Error("vocabulary holds a stemmed term")
+	}
+}
+
+func TestTokenizerConfig_StringRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, c := range []TokenizerConfig{DefaultTokenizer, {MinLength: 3}, {Stem: true}} {
+		got, err := ParseTokenizerConfig(c.String())
+		if err != nil {
+			t.Fatalf("ParseTokenizerConfig(%q): %v", c.String(), err)
+		}
+		if got != c {
+			t.Errorf("round trip: got %+v, want %+v", got, c)
+		}
+	}
+	if _, err := ParseTokenizerConfig("bogus"); err == nil {
+		t.Error("expected error for malformed config")
+	}
+}
+
 func TestCosineSimilarity_Identical(t *testing.T) {
 	t.Parallel()
 	a := []float64{1, 2, 3}
@@ -155,6 +265,53 @@ func TestBuild_And_Embed(t *testing.T) {
 	}
 }
 
+func TestModel_FoldIn(t *testing.T) {
+	t.Parallel()
+	sessions := map[string]string{
+		"s1": "JWT authentication token expiry refresh login security middleware",
+		"s2": "JWT token validation auth middleware bearer header claims expiry",
+		"s3": "database connection pooling query optimization index performance SQL",
+		"s4": "database schema migration table column index query performance tuning",
+	}
+	model, err := Build(sessions, 3)
+	if err != nil || model == nil {
+		t.Fatalf("Build: model=%v err=%v", model, err)
+	}
+	before := model.Vectors()
+
+	added := model.FoldIn(map[string]string{
+		"s1": "already trained, must be skipped",
+		"s5": "JWT token refresh middleware expiry",
+	})
+	if len(added) != 1 || added["s5"] == nil {
+		t.Fatalf("FoldIn: got %v, want only s5", added)
+	}
+	if len(model.SessionIDs) != 5 || model.SessionIDs[4] != "s5" {
+		t.Errorf("SessionIDs after fold-in: %v", model.SessionIDs)
+	}
+
+	after := model.Vectors()
+	for id, vec := range before {
+		for j := range vec {
+			if after[id][j] != vec[j] {
+				t.Fatalf("fold-in changed trained vector %s", id)
+			}
+		}
+	}
+	for j := range added["s5"] {
+		if after["s5"][j] != added["s5"][j] {
+			t.Fatalf("Vectors()[s5] = %v, want %v", after["s5"], added["s5"])
+		}
+	}
+
+	// The folded-in auth session lands nearer the auth sessions than the DB ones.
+	auth := CosineSimilarity(after["s5"], after["s1"]) + CosineSimilarity(after["s5"], after["s2"])
+	dbSim := CosineSimilarity(after["s5"], after["s3"]) + CosineSimilarity(after["s5"], after["s4"])
+	if auth <= dbSim {
+		t.Errorf("folded-in session: auth similarity %f <= db similarity %f", auth, dbSim)
+	}
+}
+
 func TestBuild_EmptySessions(t *testing.T) {
 	t.Parallel()
 	sessions := map[string]string{}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultRemote is the git remote push and sync use when --remote is not given.
+const defaultRemote = "origin"
+
+// defaultNetworkTimeout bounds git fetch and push when neither --timeout nor
+// the rekal.timeout git config is set.
+const defaultNetworkTimeout = 2 * time.Minute
+
+// errNetworkTimeout is wrapped by errors from git network operations that
+// hit their deadline.
+var errNetworkTimeout = errors.New("timed out")
+
+// addTimeoutFlag registers --timeout on a network-touching command.
+func addTimeoutFlag(cmd *cobra.Command, timeout *time.Duration) {
+	cmd.Flags().DurationVar(timeout, "timeout", defaultNetworkTimeout,
+		"Abort git fetch/push after this long (0 disables; default from git config rekal.timeout)")
+}
+
+// addRemoteFlag registers --remote on a network-touching command.
+func addRemoteFlag(cmd *cobra.Command, remote *string) {
+	cmd.Flags().StringVar(remote, "remote", defaultRemote, "Git remote to push to and fetch from")
+}
+
+// checkRemote reports an error unless remote is configured in gitRoot.
+func checkRemote(gitRoot, remote string) error {
+	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", remote).Run(); err != nil {
+		return fmt.Errorf("no remote '%s' configured", remote)
+	}
+	return nil
+}
+
+// networkTimeout resolves the timeout for git network operations: the
+// --timeout flag if given, else git config rekal.timeout, else the default.
+func networkTimeout(cmd *cobra.Command, flag time.Duration) time.Duration {
+	if cmd.Flags().Changed("timeout") {
+		return flag
+	}
+	if v := gitConfigValue("rekal.timeout"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultNetworkTimeout
+}
+
+// runGitNetwork runs a git network operation (fetch, push) in gitRoot and
+// returns its combined output. A zero timeout means no deadline. When the
+// deadline passes git is killed and the error wraps errNetworkTimeout.
+func runGitNetwork(gitRoot string, timeout time.Duration, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", gitRoot}, args...)...)
+	cmd.Stdin = nil // disconnect stdin so git doesn't hang in hook context
+	// Don't wait on ssh or credential helpers that outlive a killed git.
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return out, fmt.Errorf("git %s %w after %s", args[0], errNetworkTimeout, timeout)
+	}
+	return out, err
+}
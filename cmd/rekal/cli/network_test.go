@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// installSlowGit puts a fake git on PATH that answers instantly except for
+// network operations, which hang.
+func installSlowGit(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in
+    fetch|push) exec sleep 30 ;;
+  esac
+done
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestFetchRemoteRekalRefs_Timeout(t *testing.T) {
+	installSlowGit(t)
+
+	start := time.Now()
+	err := fetchRemoteRekalRefs(t.TempDir(), defaultRemote, 200*time.Millisecond)
+	if !errors.Is(err, errNetworkTimeout) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("fetch took %s, timeout did not fire", elapsed)
+	}
+}
+
+func TestRunGitNetwork_NoTimeout(t *testing.T) {
+	t.Parallel()
+
+	// A zero timeout runs without a deadline.
+	if _, err := runGitNetwork(t.TempDir(), 0, "--version"); err != nil {
+		t.Fatalf("git --version: %v", err)
+	}
+}
@@ -22,7 +22,7 @@ func EnsureGitRoot() (string, error) {
 // EnsureInitDone checks that Rekal has been initialized in the given git root.
 // It verifies that .rekal/ exists and contains the expected database files.
 func EnsureInitDone(gitRoot string) error {
-	rekalDir := filepath.Join(gitRoot, ".rekal")
+	rekalDir := RekalDir(gitRoot)
 	info, err := os.Stat(rekalDir)
 	if err != nil || !info.IsDir() {
 		return fmt.Errorf("rekal not initialized; run 'rekal init' in a git repository")
@@ -34,7 +34,8 @@ func EnsureInitDone(gitRoot string) error {
 	return nil
 }
 
-// RekalDir returns the path to .rekal/ within the given git root.
+// RekalDir returns the path to .rekal/ within the given git root. Each
+// worktree has its own, at its top level.
 func RekalDir(gitRoot string) string {
 	return filepath.Join(gitRoot, ".rekal")
 }
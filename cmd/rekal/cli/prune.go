@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newPruneCmd() *cobra.Command {
+	var (
+		before   string
+		keepLast int
+		dryRun   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old sessions from the data DB",
+		Long: `Delete sessions captured before --before, or all but the newest --keep-last,
+from the data DB, with their turns, tool calls, tags and checkpoint links.
+Checkpoints left with no session are deleted too. The index is then rebuilt.
+
+With both flags, a session is pruned only if it is older than --before and
+not among the newest --keep-last: "--before 90d --keep-last 100" drops
+sessions older than 90 days but always keeps the last 100.
+
+Only the local data DB shrinks. The rekal branch is append-only, so pruned
+sessions that were pushed stay on it, and 'rekal sync --self' would import
+them again. Sessions whose checkpoint was never pushed are lost for good;
+run 'rekal push' first. The index rebuild drops teammates' sessions, which
+are only in the index, until the next 'rekal sync'.
+
+--dry-run prints the sessions and checkpoints that would be deleted as JSON
+to stderr and deletes nothing.`,
+		Example: `  rekal prune --before 90d --dry-run
+  rekal prune --keep-last 500
+  rekal prune --before 2026-01-01T00:00:00Z`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			opts := pruneOptions{KeepLast: -1, DryRun: dryRun}
+			if before == "" && !cmd.Flags().Changed("keep-last") {
+				return fmt.Errorf("need --before or --keep-last")
+			}
+			if before != "" {
+				if opts.Before, err = parseTimeBound(before, time.Now()); err != nil {
+					return fmt.Errorf("--before: %w", err)
+				}
+			}
+			if cmd.Flags().Changed("keep-last") {
+				if keepLast < 0 {
+					return fmt.Errorf("--keep-last must not be negative")
+				}
+				opts.KeepLast = keepLast
+			}
+			return runPrune(cmd, gitRoot, opts)
+		},
+	}
+	cmd.Flags().StringVar(&before, "before", "", "Prune sessions captured before this time (RFC3339 or relative, e.g. 90d)")
+	cmd.Flags().IntVar(&keepLast, "keep-last", 0, "Keep only the newest n sessions")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be pruned as JSON to stderr without deleting")
+	return cmd
+}
+
+// pruneOptions selects the sessions prune deletes.
+type pruneOptions struct {
+	Before   time.Time // zero: no age limit
+	KeepLast int       // -1: no count limit
+	DryRun   bool
+}
+
+// pruneReport is what prune --dry-run prints to stderr.
+type pruneReport struct {
+	Sessions         []prunedSession `json:"sessions"`
+	Checkpoints      []string        `json:"checkpoints"`
+	TotalSessions    int             `json:"total_sessions"`
+	TotalCheckpoints int             `json:"total_checkpoints"`
+	Unpushed         int             `json:"unpushed_sessions"`
+}
+
+type prunedSession struct {
+	SessionID  string `json:"session_id"`
+	CapturedAt string `json:"captured_at"`
+	Author     string `json:"author"`
+	Turns      int    `json:"turns"`
+}
+
+// selectPrune returns the sessions opts prunes from sessions, which are
+// newest first. A session must fail every limit that is set.
+func selectPrune(sessions []db.SessionAge, opts pruneOptions) []db.SessionAge {
+	var result []db.SessionAge
+	for i, s := range sessions {
+		if opts.KeepLast >= 0 && i < opts.KeepLast {
+			continue
+		}
+		if !opts.Before.IsZero() && !s.CapturedAt.Before(opts.Before) {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func runPrune(cmd *cobra.Command, gitRoot string, opts pruneOptions) error {
+	w := cmd.ErrOrStderr()
+
+	// Serialize with a concurrent checkpoint, push or sync, through the
+	// index rebuild too, since checkpoint updates the index under the lock.
+	unlock, err := lockRepo(gitRoot)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Open data DB; a dry run only reads it.
+	openData := db.OpenData
+	if opts.DryRun {
+		openData = db.OpenDataReadOnly
+	}
+	dataDB, err := openData(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data DB: %w", err)
+	}
+	defer dataDB.Close()
+
+	sessions, err := db.QuerySessionAges(dataDB)
+	if err != nil {
+		return err
+	}
+	pruned := selectPrune(sessions, opts)
+	ids := make([]string, len(pruned))
+	for i, s := range pruned {
+		ids[i] = s.ID
+	}
+
+	// Sessions of checkpoints push has not exported yet exist nowhere else.
+	unpushed := make(map[string]bool)
+	unexported, err := db.QueryUnexportedCheckpoints(dataDB)
+	if err != nil {
+		return err
+	}
+	for _, c := range unexported {
+		sids, err := db.QuerySessionsByCheckpoint(dataDB, c.ID)
+		if err != nil {
+			return err
+		}
+		for _, sid := range sids {
+			unpushed[sid] = true
+		}
+	}
+	nUnpushed := 0
+	for _, id := range ids {
+		if unpushed[id] {
+			nUnpushed++
+		}
+	}
+
+	if opts.DryRun {
+		checkpoints, err := db.QueryOrphanedCheckpoints(dataDB, ids)
+		if err != nil {
+			return err
+		}
+		report := pruneReport{
+			Sessions:         []prunedSession{},
+			Checkpoints:      checkpoints,
+			TotalSessions:    len(pruned),
+			TotalCheckpoints: len(checkpoints),
+			Unpushed:         nUnpushed,
+		}
+		if report.Checkpoints == nil {
+			report.Checkpoints = []string{}
+		}
+		for _, s := range pruned {
+			report.Sessions = append(report.Sessions, prunedSession{
+				SessionID:  s.ID,
+				CapturedAt: s.CapturedAt.UTC().Format(time.RFC3339),
+				Author:     s.Email,
+				Turns:      s.Turns,
+			})
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal dry run: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	if len(ids) == 0 {
+		if !isQuiet(cmd) {
+			fmt.Fprintln(w, "rekal: nothing to prune")
+		}
+		return nil
+	}
+	if nUnpushed > 0 {
+		fmt.Fprintf(w, "rekal: warning: %d pruned session(s) were never pushed and are gone for good\n", nUnpushed)
+	}
+
+	checkpoints, err := db.DeleteSessions(dataDB, ids)
+	if err != nil {
+		return err
+	}
+	dataDB.Close()
+	if !isQuiet(cmd) {
+		fmt.Fprintf(w, "rekal: pruned %d session(s), %d checkpoint(s)\n", len(ids), len(checkpoints))
+	}
+
+	return runIndex(cmd, gitRoot, indexOptions{})
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+func TestSelectPrune(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	sessions := []db.SessionAge{ // newest first
+		{ID: "s5", CapturedAt: day(5)},
+		{ID: "s4", CapturedAt: day(4)},
+		{ID: "s3", CapturedAt: day(3)},
+		{ID: "s2", CapturedAt: day(2)},
+		{ID: "s1", CapturedAt: day(1)},
+	}
+	tests := []struct {
+		name string
+		opts pruneOptions
+		want string
+	}{
+		{"before", pruneOptions{Before: day(3), KeepLast: -1}, "[s2 s1]"},
+		{"keep last", pruneOptions{KeepLast: 2}, "[s3 s2 s1]"},
+		{"keep none", pruneOptions{KeepLast: 0}, "[s5 s4 s3 s2 s1]"},
+		{"both", pruneOptions{Before: day(5), KeepLast: 3}, "[s2 s1]"},
+		{"nothing old enough", pruneOptions{Before: day(1), KeepLast: -1}, "[]"},
+	}
+	for _, tt := range tests {
+		var ids []string
+		for _, s := range selectPrune(sessions, tt.opts) {
+			ids = append(ids, s.ID)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,23 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/spf13/cobra"
 )
 
 func newPushCmd() *cobra.Command {
-	var force bool
+	var (
+		force   bool
+		remote  string
+		timeout time.Duration
+	)
 
 	cmd := &cobra.Command{
 		Use:   "push",
@@ -25,8 +32,16 @@ metadata anchored to git commits. They are encoded into a compact binary wire
 format (rekal.body + dict.bin) using zstd compression and string interning —
 a 2-10 MB session compresses to ~300 bytes on the wire.
 
-Use --force to overwrite the remote branch when it has diverged from local
-(e.g. after a rebuild or conflict).
+When the remote branch has diverged from local (e.g. you pushed from another
+machine), push merges the two: the frames only local has are re-appended after
+the remote's, and the branch is pushed again. Use --force to overwrite the
+remote branch instead, or when the two cannot be merged (e.g. after a rebuild).
+
+Use --remote to push to a remote other than origin (e.g. a fork or mirror).
+The remote must be configured ('git remote get-url <remote>' must succeed).
+
+git push is aborted after --timeout (default 2m, or git config rekal.timeout)
+so a hung remote cannot block the pre-push hook indefinitely.
 
 Normally runs automatically via the pre-push git hook installed by 'rekal init'.
 You do not need to run this manually.`,
@@ -43,42 +58,73 @@ You do not need to run this manually.`,
 				return NewSilentError(err)
 			}
 
-			return doPush(gitRoot, cmd.ErrOrStderr(), force)
+			if cmd.Flags().Changed("remote") {
+				if err := checkRemote(gitRoot, remote); err != nil {
+					return err
+				}
+			}
+
+			return doPush(gitRoot, cmd.ErrOrStderr(), pushOptions{
+				Remote:  remote,
+				Force:   force,
+				Quiet:   isQuiet(cmd),
+				Timeout: networkTimeout(cmd, timeout),
+			})
 		},
 	}
 
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force push (overwrite remote with local data)")
+	addRemoteFlag(cmd, &remote)
+	addTimeoutFlag(cmd, &timeout)
 	return cmd
 }
 
-// doPush pushes Rekal data to the remote orphan branch.
+// pushOptions configures doPush.
+type pushOptions struct {
+	// Remote is the git remote to push to.
+	Remote string
+	// Force overwrites the remote branch instead of merging a diverged one.
+	Force bool
+	// Quiet drops informational messages; failures are still written.
+	Quiet bool
+	// Timeout aborts git push with errNetworkTimeout once it elapses.
+	Timeout time.Duration
+}
+
+// doPush pushes Rekal data to the orphan branch on opts.Remote.
 // Extracted so sync can call it without a cobra.Command.
-func doPush(gitRoot string, w io.Writer, force bool) error {
+func doPush(gitRoot string, w io.Writer, opts pushOptions) error {
 	branch := rekalBranchName()
+	remote, timeout := opts.Remote, opts.Timeout
+	info := w
+	if opts.Quiet {
+		info = io.Discard
+	}
 
 	// Check if local branch exists — if not, nothing to push.
 	if err := exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", branch).Run(); err != nil {
-		fmt.Fprintln(w, "rekal: no data to push (run 'rekal checkpoint' first)")
+		fmt.Fprintln(info, "rekal: no data to push (run 'rekal checkpoint' first)")
 		return nil
 	}
 
+	// Refuse to build on a branch that is not valid wire format.
+	if err := validateBranchTree(gitRoot, branch); err != nil {
+		return err
+	}
+
 	// Check if remote is configured.
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
-		fmt.Fprintln(w, "rekal: no remote 'origin' configured — skipping push")
+	if err := checkRemote(gitRoot, remote); err != nil {
+		fmt.Fprintf(info, "rekal: %v — skipping push\n", err)
 		return nil
 	}
 
 	// Export unexported checkpoints from DuckDB → wire format → orphan branch.
-	body, dict, err := exportNewFrames(gitRoot)
+	exported, err := exportAndCommit(gitRoot)
 	if err != nil {
-		return fmt.Errorf("export: %w", err)
+		return err
 	}
-	if body != nil {
-		if _, err := commitWireFormat(gitRoot, body, dict); err != nil {
-			return fmt.Errorf("commit to rekal branch: %w", err)
-		}
-	} else {
-		fmt.Fprintln(w, "rekal: no new checkpoints to export")
+	if !exported {
+		fmt.Fprintln(info, "rekal: no new checkpoints to export")
 	}
 
 	// Compare local SHA vs remote tracking SHA — skip if identical.
@@ -86,44 +132,183 @@ func doPush(gitRoot string, w io.Writer, force bool) error {
 	if err != nil {
 		return nil
 	}
-	remoteSHA, err := exec.Command("git", "-C", gitRoot, "rev-parse", "origin/"+branch).Output()
+	remoteSHA, err := exec.Command("git", "-C", gitRoot, "rev-parse", remote+"/"+branch).Output()
 	if err == nil && strings.TrimSpace(string(localSHA)) == strings.TrimSpace(string(remoteSHA)) {
-		fmt.Fprintln(w, "rekal: already up to date")
+		fmt.Fprintln(info, "rekal: already up to date")
 		return nil
 	}
 
-	if force {
-		forceCmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", "--force", "origin", branch)
-		forceCmd.Stdin = nil
-		if output, err := forceCmd.CombinedOutput(); err != nil {
+	if opts.Force {
+		if output, err := runGitNetwork(gitRoot, timeout, "push", "--no-verify", "--force", remote, branch); err != nil {
+			if errors.Is(err, errNetworkTimeout) {
+				return err
+			}
 			fmt.Fprintf(w, "rekal: force push failed: %s\n", strings.TrimSpace(string(output)))
 			return nil
 		}
-		fmt.Fprintf(w, "rekal: force pushed to origin/%s\n", branch)
+		fmt.Fprintf(info, "rekal: force pushed to %s/%s\n", remote, branch)
 		return nil
 	}
 
 	// Push with --no-verify to prevent recursive pre-push hook.
-	pushCmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", "origin", branch)
-	pushCmd.Stdin = nil // disconnect stdin so git doesn't hang in hook context
-	output, err := pushCmd.CombinedOutput()
+	output, err := runGitNetwork(gitRoot, timeout, "push", "--no-verify", remote, branch)
 	if err != nil {
+		if errors.Is(err, errNetworkTimeout) {
+			return err
+		}
 		if isNonFastForward(string(output)) {
-			fmt.Fprintf(w, "rekal: push rejected (non-fast-forward) for origin/%s\n", branch)
-			fmt.Fprintln(w, "rekal: your remote branch has diverged from local — review and run 'rekal push --force' to overwrite remote with local data")
-			return nil
+			return pushMerged(gitRoot, w, info, remote, branch, timeout)
 		}
 		fmt.Fprintf(w, "rekal: push failed: %s\n", strings.TrimSpace(string(output)))
 		return nil
 	}
 
-	fmt.Fprintf(w, "rekal: pushed to origin/%s\n", branch)
+	fmt.Fprintf(info, "rekal: pushed to %s/%s\n", remote, branch)
 	return nil
 }
 
+// exportAndCommit exports unexported checkpoints and commits them to the
+// rekal branch under the repo lock, so a checkpoint from the post-commit hook
+// can't write the data DB meanwhile. It reports whether there was anything
+// to export.
+func exportAndCommit(gitRoot string) (bool, error) {
+	unlock, err := lockRepo(gitRoot)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	manifest, body, dict, trainedDicts, err := exportNewFrames(gitRoot)
+	if err != nil {
+		return false, fmt.Errorf("export: %w", err)
+	}
+	if body == nil {
+		return false, nil
+	}
+	if _, err := commitWireFormat(gitRoot, manifest, body, dict, trainedDicts); err != nil {
+		return false, fmt.Errorf("commit to rekal branch: %w", err)
+	}
+	return true, nil
+}
+
 // isNonFastForward checks if git push output indicates a non-fast-forward rejection.
 func isNonFastForward(output string) bool {
 	return strings.Contains(output, "non-fast-forward") ||
 		strings.Contains(output, "[rejected]") ||
 		strings.Contains(output, "fetch first")
 }
+
+// pushMerged handles a rejected push: it fetches the remote branch, merges it
+// into local with codec.MergeBodies and pushes the merge commit. Branches that
+// can't be merged get the --force advice instead. Progress goes to info and
+// failures to w.
+func pushMerged(gitRoot string, w, info io.Writer, remote, branch string, timeout time.Duration) error {
+	remoteBranch := remote + "/" + branch
+	fmt.Fprintf(info, "rekal: push rejected (non-fast-forward) for %s — merging\n", remoteBranch)
+
+	refspec := fmt.Sprintf("refs/heads/%s:refs/remotes/%s", branch, remoteBranch)
+	if output, err := runGitNetwork(gitRoot, timeout, "fetch", remote, refspec); err != nil {
+		if errors.Is(err, errNetworkTimeout) {
+			return err
+		}
+		fmt.Fprintf(w, "rekal: fetch failed: %s\n", strings.TrimSpace(string(output)))
+		return nil
+	}
+
+	unlock, err := lockRepo(gitRoot)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeRemoteBranch(gitRoot, remoteBranch)
+	unlock()
+	if err != nil {
+		fmt.Fprintf(w, "rekal: merge failed: %v\n", err)
+	}
+	if !merged {
+		fmt.Fprintln(w, "rekal: your remote branch has diverged from local — review and run 'rekal push --force' to overwrite remote with local data")
+		return nil
+	}
+
+	output, err := runGitNetwork(gitRoot, timeout, "push", "--no-verify", remote, branch)
+	if err != nil {
+		if errors.Is(err, errNetworkTimeout) {
+			return err
+		}
+		fmt.Fprintf(w, "rekal: push failed: %s\n", strings.TrimSpace(string(output)))
+		return nil
+	}
+	fmt.Fprintf(info, "rekal: merged and pushed to %s\n", remoteBranch)
+	return nil
+}
+
+// mergeRemoteBranch merges remoteBranch into the local rekal branch with a
+// two-parent commit whose active shard is codec.MergeBodies of both sides.
+// It reports false, leaving the branch untouched, when the two do not share
+// every shard but the active one, which includes differing shard counts.
+func mergeRemoteBranch(gitRoot, remoteBranch string) (bool, error) {
+	branch := rekalBranchName()
+	if err := validateBranchTree(gitRoot, remoteBranch); err != nil {
+		return false, err
+	}
+
+	localManifest, err := loadManifest(gitRoot, branch)
+	if err != nil {
+		return false, fmt.Errorf("load manifest: %w", err)
+	}
+	remoteManifest, err := loadManifest(gitRoot, remoteBranch)
+	if err != nil {
+		return false, fmt.Errorf("load manifest: %w", err)
+	}
+	if localManifest.Shards != remoteManifest.Shards {
+		return false, nil
+	}
+
+	localTree, err := lsTree(gitRoot, branch)
+	if err != nil {
+		return false, err
+	}
+	remoteTree, err := lsTree(gitRoot, remoteBranch)
+	if err != nil {
+		return false, err
+	}
+	for n := 0; n < localManifest.Active(); n++ {
+		if localTree[codec.ShardFile(n)].Hash != remoteTree[codec.ShardFile(n)].Hash {
+			return false, nil
+		}
+	}
+
+	dec, release, err := branchDecoder(gitRoot, branch, remoteBranch)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	shard := codec.ShardFile(localManifest.Active())
+	body, dict, err := codec.MergeBodiesWith(dec,
+		gitShowFile(gitRoot, branch, shard), gitShowFile(gitRoot, remoteBranch, shard),
+		gitShowFile(gitRoot, branch, "dict.bin"), gitShowFile(gitRoot, remoteBranch, "dict.bin"),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	// Remote frames are kept as they are, so the merge needs remote's
+	// trained dictionaries as well as local's.
+	trainedDicts, err := mergeTrainedDicts(gitRoot, branch, remoteBranch)
+	if err != nil {
+		return false, err
+	}
+
+	var parents []string
+	for _, ref := range []string{branch, remoteBranch} {
+		out, err := exec.Command("git", "-C", gitRoot, "rev-parse", ref).Output()
+		if err != nil {
+			return false, fmt.Errorf("resolve %s: %w", ref, err)
+		}
+		parents = append(parents, strings.TrimSpace(string(out)))
+	}
+	if _, err := commitWireTree(gitRoot, parents, localManifest, body, dict, trainedDicts); err != nil {
+		return false, fmt.Errorf("commit merge: %w", err)
+	}
+	return true, nil
+}
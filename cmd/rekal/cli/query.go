@@ -1,10 +1,18 @@
 package cli
 
 import (
+	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/spf13/cobra"
@@ -12,8 +20,9 @@ import (
 
 func newQueryCmd() *cobra.Command {
 	var (
-		useIndex  bool
+		sqlOpts   sqlQueryOptions
 		sessionID string
+		threadID  string
 		full      bool
 		offset    int
 		limit     int
@@ -21,39 +30,71 @@ func newQueryCmd() *cobra.Command {
 	)
 
 	cmd := &cobra.Command{
-		Use:   "query [<sql> | --session <id> [--full] [--offset N] [--limit N] [--role human|assistant]]",
+		Use:   "query [<sql> | --session <id> [--full] [--offset N] [--limit N] [--role human|assistant|thinking] | --thread <id>]",
 		Short: "Run raw SQL or drill into a session",
 		Long: `Run raw SQL against the data or index DB, or drill into a specific session.
 
 Session drill-down (--session) returns the full conversation as JSON. Add --full
 to include tool calls and files touched. Use --offset, --limit, and --role to
-paginate through turns or filter by role.
-
-Raw SQL mode accepts SELECT statements only. Output is one JSON object per row.
-Use --index to query the index DB instead of the data DB.
+paginate through turns or filter by role. The output's parent_session_id names
+the session it resumes, if any.
+
+Thread mode (--thread) follows parent_session_id from a session back to the
+session that started the conversation and returns every turn across them,
+oldest first. Turns a resumed session carried over from its parent are listed
+once.
+
+Raw SQL mode accepts one SELECT statement, optionally led by WITH, and opens
+the DB read-only. Output is one JSON object per row;
+list columns such as embeddings are JSON arrays of numbers. --format table
+prints aligned columns under a header for reading in a terminal, with NULL for
+nulls; --format csv prints a header row and comma-separated rows, with nulls
+as empty fields. JSON stays the default for scripts.
+Use --index to query the index DB instead of the data DB. --attach also makes
+the other DB readable in the same SELECT: the index DB as index_db with the
+data DB (index_db.session_facets), or the data DB as data_db with --index
+(data_db.turns). The attached DB is read-only. --count prints the
+total row count to stderr before the rows; --json ends the output with a
+{"_meta":{"rows":N}} line (plus "total" under --count) so scripts can tell
+they saw everything.
+
+At most --max-rows rows (default 10000) are printed; a capped result ends
+with a "result truncated" note on stderr, and "truncated":true in the
+--json line. --max-rows 0 prints every row. --timeout cancels a query that
+runs longer (default: no limit).
 
 DATA DB SCHEMA (.rekal/data.db):
 
   sessions        id, parent_session_id, session_hash, captured_at, actor_type,
-                  agent_id, user_email, branch
-  turns           id, session_id, turn_index, role, content, ts
-  tool_calls      id, session_id, call_order, tool, path, cmd_prefix
+                  agent_id, user_email, branch, total_cost, total_duration_ms,
+                  cwd (git-root-relative working directory),
+                  source_session_id (Claude Code's own session ID)
+  turns           id, session_id, turn_index, role, content, ts, content_hash
+  tool_calls      id, session_id, call_order, tool, path, cmd_prefix, failed,
+                  error_snippet, server (MCP server; tool is the bare name)
   checkpoints     id, git_sha, git_branch, user_email, ts, actor_type, agent_id,
                   exported
-  files_touched   id, checkpoint_id, file_path, change_type
+  files_touched   id, checkpoint_id, file_path, change_type,
+                  diff (hunks, with checkpoint --capture-diffs)
   checkpoint_sessions  checkpoint_id, session_id
+  session_tags    session_id, tag, tagged_at (local-only; see rekal tag)
+  schema_version  version, applied_at (one row per schema upgrade applied)
 
 INDEX DB SCHEMA (.rekal/index.db):
 
   turns_ft             id, session_id, turn_index, role, content, ts
-  tool_calls_index     id, session_id, call_order, tool, path, cmd_prefix
+  tool_calls_index     id, session_id, call_order, tool, path, cmd_prefix,
+                       failed, error_snippet, server
   files_index          checkpoint_id, session_id, file_path, change_type
+  file_access          session_id, file_path, tool, count
+                       (files read or searched via Read/Grep/Glob)
   session_facets       session_id, user_email, git_branch, actor_type, agent_id,
                        captured_at, turn_count, tool_call_count, file_count,
                        checkpoint_id, git_sha
   file_cooccurrence    file_a, file_b, count
   session_embeddings   session_id, embedding, model, generated_at
-                       PK: (session_id, model). Models: lsa-v1, nomic-v1.5`,
+                       PK: (session_id, model). Models: lsa-v1, nomic-v1.5
+  lsa_model            model, data (serialized LSA model from the last index build)`,
 		Example: `  # Drill into a session (turns only)
   rekal query --session 01JNQX...
 
@@ -68,6 +109,9 @@ INDEX DB SCHEMA (.rekal/index.db):
   rekal query --session 01JNQX... --role human
   rekal query --session 01JNQX... --role human --limit 5
 
+  # A conversation across resumed sessions
+  rekal query --thread 01JNQX...
+
   # Recent sessions
   rekal query "SELECT id, user_email, branch, captured_at FROM sessions ORDER BY captured_at DESC LIMIT 5"
 
@@ -77,9 +121,25 @@ INDEX DB SCHEMA (.rekal/index.db):
   # Most-edited files
   rekal query "SELECT path, count(*) as n FROM tool_calls WHERE tool IN ('Write','Edit') AND path IS NOT NULL GROUP BY path ORDER BY n DESC LIMIT 10"
 
+  # Sessions where a Bash command failed
+  rekal query "SELECT session_id, cmd_prefix, error_snippet FROM tool_calls WHERE failed AND tool = 'Bash'"
+
   # File co-occurrence (index DB)
   rekal query --index "SELECT * FROM file_cooccurrence WHERE file_a LIKE '%auth%' ORDER BY count DESC LIMIT 10"
 
+  # Aligned columns for reading, or CSV for a spreadsheet
+  rekal query --format table "SELECT tool, count(*) AS n FROM tool_calls GROUP BY tool ORDER BY n DESC"
+  rekal query --format csv "SELECT id, user_email, captured_at FROM sessions" > sessions.csv
+
+  # Join the index DB's facets to the data DB's turns
+  rekal query --attach "SELECT f.user_email, count(*) AS turns FROM turns t JOIN index_db.session_facets f ON f.session_id = t.session_id GROUP BY 1"
+
+  # Every row of a large result, giving up after a minute
+  rekal query --max-rows 0 --timeout 1m "SELECT * FROM turns"
+
+  # Row count before the rows, and a trailing _meta line
+  rekal query --count --json "SELECT id FROM sessions"
+
   # Embedding model counts
   rekal query --index "SELECT model, count(*) FROM session_embeddings GROUP BY model"`,
 		Args: cobra.MaximumNArgs(1),
@@ -100,15 +160,40 @@ INDEX DB SCHEMA (.rekal/index.db):
 			if sessionID != "" && len(args) > 0 {
 				return fmt.Errorf("--session and SQL argument are mutually exclusive")
 			}
+			if threadID != "" && (sessionID != "" || len(args) > 0) {
+				return fmt.Errorf("--thread cannot be combined with --session or a SQL argument")
+			}
+			if threadID != "" && (full || offset != 0 || limit != 0 || role != "" || sqlOnlyFlagsSet(cmd)) {
+				return fmt.Errorf("--thread takes no other flags")
+			}
+			if threadID != "" {
+				return runThreadDrilldown(cmd, gitRoot, threadID)
+			}
 
 			// --offset, --limit, --role require --session.
 			if sessionID == "" && (offset != 0 || limit != 0 || role != "") {
 				return fmt.Errorf("--offset, --limit, and --role require --session")
 			}
 
-			// --role must be "human" or "assistant" if set.
-			if role != "" && role != "human" && role != "assistant" {
-				return fmt.Errorf("--role must be \"human\" or \"assistant\"")
+			if sessionID != "" && sqlOnlyFlagsSet(cmd) {
+				return fmt.Errorf("--count, --json, --format, --attach, --max-rows and --timeout cannot be used with --session")
+			}
+			if f := sqlOpts.Format; f != "json" && f != "table" && f != "csv" {
+				return fmt.Errorf("--format must be json, table or csv, got %q", f)
+			}
+			if sqlOpts.Meta && sqlOpts.Format != "json" {
+				return fmt.Errorf("--json requires --format json")
+			}
+			if sqlOpts.MaxRows < 0 {
+				return fmt.Errorf("--max-rows must be 0 or more, got %d", sqlOpts.MaxRows)
+			}
+			if sqlOpts.Timeout < 0 {
+				return fmt.Errorf("--timeout must be 0 or more, got %s", sqlOpts.Timeout)
+			}
+
+			// --role must be "human", "assistant", or "thinking" if set.
+			if role != "" && role != "human" && role != "assistant" && role != "thinking" {
+				return fmt.Errorf("--role must be \"human\", \"assistant\", or \"thinking\"")
 			}
 
 			if sessionID != "" {
@@ -119,26 +204,42 @@ INDEX DB SCHEMA (.rekal/index.db):
 				return fmt.Errorf("provide a SQL query or use --session <id>")
 			}
 
-			return runQuery(cmd, gitRoot, args[0], useIndex)
+			return runQuery(cmd, gitRoot, args[0], sqlOpts)
 		},
 	}
 
-	cmd.Flags().BoolVar(&useIndex, "index", false, "Run SQL against the index DB instead of the data DB")
+	cmd.Flags().BoolVar(&sqlOpts.UseIndex, "index", false, "Run SQL against the index DB instead of the data DB")
+	cmd.Flags().BoolVar(&sqlOpts.Attach, "attach", false, "Also attach the other DB read-only, as index_db (or data_db with --index)")
 	cmd.Flags().StringVar(&sessionID, "session", "", "Show session conversation by ID")
+	cmd.Flags().StringVar(&threadID, "thread", "", "Show a session's conversation across the sessions it resumes")
 	cmd.Flags().BoolVar(&full, "full", false, "Include tool calls and files in session output")
 	cmd.Flags().IntVar(&offset, "offset", 0, "Skip first N turns (requires --session)")
 	cmd.Flags().IntVar(&limit, "limit", 0, "Max turns to return, 0 = no limit (requires --session)")
-	cmd.Flags().StringVar(&role, "role", "", "Filter turns by role: human or assistant (requires --session)")
+	cmd.Flags().StringVar(&role, "role", "", "Filter turns by role: human, assistant, or thinking (requires --session)")
+	cmd.Flags().BoolVar(&sqlOpts.Count, "count", false, "Print the total row count to stderr before the rows (SQL mode)")
+	cmd.Flags().BoolVar(&sqlOpts.Meta, "json", false, `End output with a {"_meta":{"rows":N}} line (SQL mode)`)
+	cmd.Flags().StringVar(&sqlOpts.Format, "format", "json", "Output format: json (one object per row), table or csv (SQL mode)")
+	cmd.Flags().IntVar(&sqlOpts.MaxRows, "max-rows", defaultQueryMaxRows, "Print at most this many rows, 0 for all (SQL mode)")
+	cmd.Flags().DurationVar(&sqlOpts.Timeout, "timeout", 0, "Cancel the query after this long, 0 for no limit (SQL mode)")
+
+	_ = cmd.RegisterFlagCompletionFunc("session", completeFromData("sessions", "id"))
+	_ = cmd.RegisterFlagCompletionFunc("thread", completeFromData("sessions", "id"))
+	_ = cmd.RegisterFlagCompletionFunc("role", cobra.FixedCompletions([]string{"human", "assistant", "thinking"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"json", "table", "csv"}, cobra.ShellCompDirectiveNoFileComp))
+
 	return cmd
 }
 
 // sessionOutput is the JSON structure for session drill-down.
 type sessionOutput struct {
 	SessionID  string           `json:"session_id"`
+	Parent     string           `json:"parent_session_id,omitempty"`
 	Author     string           `json:"author"`
 	Actor      string           `json:"actor"`
 	Branch     string           `json:"branch"`
 	CapturedAt string           `json:"captured_at"`
+	TotalCost  float64          `json:"total_cost,omitempty"`
+	DurationMs int64            `json:"total_duration_ms,omitempty"`
 	TotalTurns int              `json:"total_turns"`
 	Offset     int              `json:"offset,omitempty"`
 	Limit      int              `json:"limit,omitempty"`
@@ -156,13 +257,16 @@ type turnOutput struct {
 }
 
 type toolCallOutput struct {
-	Order int    `json:"order"`
-	Tool  string `json:"tool"`
-	Path  string `json:"path,omitempty"`
+	Order  int    `json:"order"`
+	Tool   string `json:"tool"`
+	Server string `json:"server,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Failed bool   `json:"failed,omitempty"`
+	Error  string `json:"error,omitempty"`
 }
 
 func runSessionDrilldown(cmd *cobra.Command, gitRoot, sessionID string, full bool, offset, limit int, role string) error {
-	dataDB, err := db.OpenData(gitRoot)
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open data db: %w", err)
 	}
@@ -184,10 +288,13 @@ func runSessionDrilldown(cmd *cobra.Command, gitRoot, sessionID string, full boo
 
 	output := sessionOutput{
 		SessionID:  session.ID,
+		Parent:     session.ParentSessionID,
 		Author:     session.Email,
 		Actor:      session.ActorType,
 		Branch:     session.Branch,
 		CapturedAt: session.CapturedAt,
+		TotalCost:  session.TotalCost,
+		DurationMs: session.TotalDurationMs,
 		TotalTurns: total,
 		Offset:     offset,
 		Limit:      limit,
@@ -214,9 +321,12 @@ func runSessionDrilldown(cmd *cobra.Command, gitRoot, sessionID string, full boo
 		}
 		for _, tc := range toolCalls {
 			output.ToolCalls = append(output.ToolCalls, toolCallOutput{
-				Order: tc.CallOrder,
-				Tool:  tc.Tool,
-				Path:  tc.Path,
+				Order:  tc.CallOrder,
+				Tool:   tc.Tool,
+				Server: tc.Server,
+				Path:   tc.Path,
+				Failed: tc.Failed,
+				Error:  tc.ErrorSnippet,
 			})
 		}
 
@@ -236,6 +346,85 @@ func runSessionDrilldown(cmd *cobra.Command, gitRoot, sessionID string, full boo
 	return nil
 }
 
+// threadOutput is the JSON structure for --thread.
+type threadOutput struct {
+	SessionID  string             `json:"session_id"`
+	Sessions   []string           `json:"sessions"` // root first, ending with session_id
+	TotalTurns int                `json:"total_turns"`
+	Turns      []threadTurnOutput `json:"turns"`
+}
+
+type threadTurnOutput struct {
+	SessionID string `json:"session_id"`
+	turnOutput
+}
+
+func runThreadDrilldown(cmd *cobra.Command, gitRoot, sessionID string) error {
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data db: %w", err)
+	}
+	defer dataDB.Close()
+
+	// Walk parent links up to the root. A parent that was never captured
+	// ends the walk; seen guards against a cycle.
+	var chain []string
+	seen := make(map[string]bool)
+	for id := sessionID; id != "" && !seen[id]; {
+		s, err := db.QuerySession(dataDB, id)
+		if err != nil {
+			if id == sessionID {
+				return fmt.Errorf("session not found: %w", err)
+			}
+			break
+		}
+		seen[id] = true
+		chain = append([]string{id}, chain...)
+		id = s.ParentSessionID
+	}
+
+	output := threadOutput{SessionID: sessionID, Sessions: chain}
+	threadHashes := make(map[string]bool)
+	for _, id := range chain {
+		turns, _, err := db.QueryTurnsPage(dataDB, id, db.TurnPageOptions{})
+		if err != nil {
+			return fmt.Errorf("query turns: %w", err)
+		}
+		// A resumed session opens with the turns it carried over; skip that
+		// prefix, but keep a later turn that happens to repeat an earlier one.
+		carried := true
+		var hashes []string
+		for _, t := range turns {
+			h := db.TurnContentHash(t.Role, t.Content)
+			hashes = append(hashes, h)
+			if carried && threadHashes[h] {
+				continue
+			}
+			carried = false
+			output.Turns = append(output.Turns, threadTurnOutput{
+				SessionID: id,
+				turnOutput: turnOutput{
+					Index:   t.TurnIndex,
+					Role:    t.Role,
+					Content: t.Content,
+					Ts:      t.Ts,
+				},
+			})
+		}
+		for _, h := range hashes {
+			threadHashes[h] = true
+		}
+	}
+	output.TotalTurns = len(output.Turns)
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return nil
+}
+
 func querySessionFilesFromData(dataDB *sql.DB, sessionID string) ([]string, error) {
 	rows, err := dataDB.Query(`
 		SELECT DISTINCT ft.file_path
@@ -260,26 +449,101 @@ func querySessionFilesFromData(dataDB *sql.DB, sessionID string) ([]string, erro
 	return files, rows.Err()
 }
 
-func runQuery(cmd *cobra.Command, gitRoot, query string, useIndex bool) error {
-	// Read-only: only allow SELECT statements.
-	normalized := strings.TrimSpace(strings.ToUpper(query))
-	if !strings.HasPrefix(normalized, "SELECT") {
-		return fmt.Errorf("only SELECT statements are allowed")
+// defaultQueryMaxRows caps SQL mode output so a SELECT over everything
+// cannot flood the terminal.
+const defaultQueryMaxRows = 10000
+
+// sqlQueryOptions are the SQL mode flags of `rekal query`.
+type sqlQueryOptions struct {
+	Format   string // json, table or csv
+	UseIndex bool
+	Attach   bool
+	Count    bool
+	Meta     bool
+	MaxRows  int           // 0 prints every row
+	Timeout  time.Duration // 0 never cancels
+}
+
+// sqlOnlyFlags are the query flags that apply to SQL mode only.
+var sqlOnlyFlags = []string{"count", "json", "format", "attach", "max-rows", "timeout"}
+
+// sqlOnlyFlagsSet reports whether any of sqlOnlyFlags was given.
+func sqlOnlyFlagsSet(cmd *cobra.Command) bool {
+	for _, name := range sqlOnlyFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryMeta is the trailing line written by `rekal query --json`.
+type queryMeta struct {
+	Rows      int    `json:"rows"`
+	Total     *int64 `json:"total,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+func runQuery(cmd *cobra.Command, gitRoot, query string, opts sqlQueryOptions) error {
+	if err := checkSelectOnly(query); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
 
 	var d *sql.DB
 	var err error
-	if useIndex {
-		d, err = db.OpenIndex(gitRoot)
+	if opts.UseIndex {
+		d, err = db.OpenIndexReadOnly(gitRoot)
 	} else {
-		d, err = db.OpenData(gitRoot)
+		d, err = db.OpenDataReadOnly(gitRoot)
 	}
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer d.Close()
 
-	rows, err := d.Query(query)
+	if opts.Attach {
+		if opts.UseIndex {
+			err = db.AttachData(d, gitRoot)
+		} else if err = db.AttachIndex(d, gitRoot); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no index DB to attach; run 'rekal index' first")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// The total is best effort: a query that can't be wrapped as a
+	// subquery still streams, just without a count.
+	var total *int64
+	if opts.Count {
+		n, err := countQueryRows(ctx, d, query)
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("query timed out after %s", opts.Timeout)
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "count unavailable: %v\n", err)
+		} else {
+			total = &n
+			if !opts.Meta {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d rows\n", n)
+			}
+		}
+	}
+
+	rows, err := d.QueryContext(ctx, query)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("query timed out after %s", opts.Timeout)
+	}
 	if err != nil {
 		return fmt.Errorf("query: %w", err)
 	}
@@ -291,9 +555,18 @@ func runQuery(cmd *cobra.Command, gitRoot, query string, useIndex bool) error {
 	}
 
 	out := cmd.OutOrStdout()
-	first := true
+	rw, err := newRowWriter(opts.Format, out, cols)
+	if err != nil {
+		return err
+	}
+	n := 0
+	truncated := false
 
 	for rows.Next() {
+		if opts.MaxRows > 0 && n == opts.MaxRows {
+			truncated = true
+			break
+		}
 		values := make([]interface{}, len(cols))
 		ptrs := make([]interface{}, len(cols))
 		for i := range values {
@@ -302,37 +575,376 @@ func runQuery(cmd *cobra.Command, gitRoot, query string, useIndex bool) error {
 		if err := rows.Scan(ptrs...); err != nil {
 			return fmt.Errorf("scan: %w", err)
 		}
+		for i, v := range values {
+			values[i] = jsonValue(v)
+		}
+		if err := rw.Row(values); err != nil {
+			return err
+		}
+		n++
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("query timed out after %s", opts.Timeout)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	if truncated {
+		fmt.Fprintf(cmd.ErrOrStderr(), "result truncated to %d rows; use --max-rows to raise the cap, or 0 for all\n", opts.MaxRows)
+	}
+
+	if opts.Meta {
+		data, err := json.Marshal(map[string]queryMeta{"_meta": {Rows: n, Total: total, Truncated: truncated}})
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	}
+
+	return nil
+}
+
+// rowWriter renders SQL mode rows in one --format. Row takes values
+// already converted by jsonValue, so every format sees []byte as a string
+// and NaN as nil.
+type rowWriter interface {
+	Row(values []interface{}) error
+	Flush() error
+}
+
+func newRowWriter(format string, out io.Writer, cols []string) (rowWriter, error) {
+	switch format {
+	case "table":
+		return &tableRowWriter{out: out, rows: [][]string{cols}}, nil
+	case "csv":
+		w := csv.NewWriter(out)
+		if err := w.Write(cols); err != nil {
+			return nil, fmt.Errorf("write csv: %w", err)
+		}
+		return &csvRowWriter{w: w}, nil
+	}
+	return &jsonRowWriter{out: out, cols: cols}, nil
+}
+
+// jsonRowWriter writes one JSON object per row, keyed by column.
+type jsonRowWriter struct {
+	out  io.Writer
+	cols []string
+}
 
-		row := make(map[string]interface{}, len(cols))
-		for i, col := range cols {
-			v := values[i]
-			// Convert []byte to string for JSON output.
-			if b, ok := v.([]byte); ok {
-				v = string(b)
+func (w *jsonRowWriter) Row(values []interface{}) error {
+	row := make(map[string]interface{}, len(w.cols))
+	for i, col := range w.cols {
+		row[col] = values[i]
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	fmt.Fprintln(w.out, string(data))
+	return nil
+}
+
+func (w *jsonRowWriter) Flush() error { return nil }
+
+// csvRowWriter writes RFC 4180 CSV under a header row. NULL is an empty
+// field.
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvRowWriter) Row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = textValue(v)
+	}
+	if err := w.w.Write(record); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+func (w *csvRowWriter) Flush() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+// tableRowWriter buffers rows to print them in aligned columns under a
+// header and a rule. NULL prints as NULL, and line breaks and tabs inside
+// a value are escaped so each row stays on one line.
+type tableRowWriter struct {
+	out  io.Writer
+	rows [][]string // header first
+}
+
+var tableEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func (w *tableRowWriter) Row(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		if v == nil {
+			row[i] = "NULL"
+			continue
+		}
+		row[i] = tableEscaper.Replace(textValue(v))
+	}
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+func (w *tableRowWriter) Flush() error {
+	widths := make([]int, len(w.rows[0]))
+	for _, row := range w.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	rule := make([]string, len(widths))
+	for i, n := range widths {
+		rule[i] = strings.Repeat("-", n)
+	}
+	lines := append([][]string{w.rows[0], rule}, w.rows[1:]...)
+
+	var b strings.Builder
+	for _, row := range lines {
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
 			}
-			row[col] = v
 		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w.out, b.String())
+	return err
+}
 
-		data, err := json.Marshal(row)
+// textValue renders a jsonValue-converted column value as text for the
+// table and CSV formats: nil is empty, strings are as-is, times are
+// RFC 3339 as in JSON output, and lists and structs are their JSON.
+func textValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(x)
 		if err != nil {
-			return fmt.Errorf("marshal: %w", err)
+			return fmt.Sprint(x)
 		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
 
-		if !first {
-			fmt.Fprintln(out)
+// jsonValue converts a scanned column value for JSON output. []byte becomes
+// a string, and LIST/ARRAY (e.g. FLOAT[] embeddings) and STRUCT values are
+// converted element by element, so a list of floats marshals as a JSON
+// number array. NaN and ±Inf, which JSON cannot represent, become null.
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case float32:
+		return jsonFloat(float64(x), x)
+	case float64:
+		return jsonFloat(x, x)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = jsonValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = jsonValue(e)
 		}
-		fmt.Fprint(out, string(data))
-		first = false
+		return out
 	}
+	return v
+}
 
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("rows: %w", err)
+// jsonFloat returns v unchanged, or nil if f is NaN or infinite.
+func jsonFloat(f float64, v interface{}) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
 	}
+	return v
+}
 
-	// Trailing newline if we printed anything.
-	if !first {
-		fmt.Fprintln(out)
+// checkSelectOnly rejects anything but a single SELECT, optionally led by
+// WITH common table expressions. Leading comments are skipped, and string
+// literals, quoted identifiers and parenthesized groups are stepped over,
+// so "/*x*/DELETE", "WITH x AS (...) DELETE" and "SELECT 1; DROP ..." are
+// all caught. The connection is read-only as well, but that alone would
+// not stop COPY ... TO writing a file.
+func checkSelectOnly(query string) error {
+	toks := sqlTopLevelTokens(query)
+	if len(toks) == 0 {
+		return fmt.Errorf("empty query")
+	}
+	for i, tok := range toks {
+		if tok == ";" && i < len(toks)-1 {
+			return fmt.Errorf("only one statement is allowed")
+		}
 	}
 
+	// WITH [RECURSIVE] name [(cols)] AS [NOT] [MATERIALIZED] (...) [, ...]
+	i := 0
+	if toks[0] == "WITH" {
+		i++
+		if i < len(toks) && toks[i] == "RECURSIVE" {
+			i++
+		}
+		for {
+			i++ // name
+			if i < len(toks) && toks[i] == "(" {
+				i++
+			}
+			if i >= len(toks) || toks[i] != "AS" {
+				return fmt.Errorf("only SELECT statements are allowed")
+			}
+			i++
+			for i < len(toks) && (toks[i] == "NOT" || toks[i] == "MATERIALIZED") {
+				i++
+			}
+			if i >= len(toks) || toks[i] != "(" {
+				return fmt.Errorf("only SELECT statements are allowed")
+			}
+			i++
+			if i >= len(toks) || toks[i] != "," {
+				break
+			}
+			i++
+		}
+	}
+	if i >= len(toks) || toks[i] != "SELECT" {
+		if i < len(toks) && isSQLWord(toks[i]) {
+			return fmt.Errorf("only SELECT statements are allowed, got %s", toks[i])
+		}
+		return fmt.Errorf("only SELECT statements are allowed")
+	}
 	return nil
 }
+
+// sqlTopLevelTokens splits query into the tokens outside any parentheses:
+// words uppercased, "(" for each parenthesized group, "'" for a string
+// literal, `"` for a quoted identifier, and other characters as
+// themselves. Comments and whitespace are dropped.
+func sqlTopLevelTokens(query string) []string {
+	var toks []string
+	depth := 0
+	emit := func(tok string) {
+		if depth == 0 {
+			toks = append(toks, tok)
+		}
+	}
+	// skipQuoted returns the index just past the literal opened at i.
+	// Doubled quotes escape a quote; so do backslashes in E'...' strings.
+	skipQuoted := func(i int, backslash bool) int {
+		q := query[i]
+		for i++; i < len(query); i++ {
+			switch {
+			case backslash && query[i] == '\\':
+				i++
+			case query[i] == q && i+1 < len(query) && query[i+1] == q:
+				i++
+			case query[i] == q:
+				return i + 1
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "$$"):
+			if end := strings.Index(query[i+2:], "$$"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+			emit("'")
+		case c == '\'' || c == '"':
+			i = skipQuoted(i, false)
+			emit(string(c))
+		case c == '(':
+			emit("(")
+			depth++
+			i++
+		case c == ')':
+			depth = max(depth-1, 0)
+			i++
+		case isSQLWordByte(c):
+			start := i
+			for i < len(query) && (isSQLWordByte(query[i]) || query[i] == '$') {
+				i++
+			}
+			word := strings.ToUpper(query[start:i])
+			if word == "E" && i < len(query) && query[i] == '\'' {
+				i = skipQuoted(i, true)
+				emit("'")
+				continue
+			}
+			emit(word)
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+	return toks
+}
+
+// isSQLWordByte reports whether c can be part of a keyword, identifier or
+// number. Bytes of multi-byte UTF-8 characters count as letters.
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// isSQLWord reports whether tok is a keyword or identifier token from
+// sqlTopLevelTokens.
+func isSQLWord(tok string) bool {
+	c := tok[0]
+	return c == '_' || 'A' <= c && c <= 'Z'
+}
+
+// countQueryRows returns how many rows query yields by wrapping it as
+// SELECT count(*) FROM (<query>). The newlines keep a trailing -- comment
+// from swallowing the closing parenthesis.
+func countQueryRows(ctx context.Context, d *sql.DB, query string) (int64, error) {
+	inner := strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	var n int64
+	if err := d.QueryRowContext(ctx, "SELECT count(*) FROM (\n"+inner+"\n)").Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSONValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"bytes", []byte("abc"), `"abc"`},
+		{"float list", []interface{}{float32(1.5), float32(-0.25)}, `[1.5,-0.25]`},
+		{"nan in list", []interface{}{float32(math.NaN()), float32(2)}, `[null,2]`},
+		{"inf", math.Inf(1), `null`},
+		{"nested", []interface{}{[]interface{}{[]byte("x")}}, `[["x"]]`},
+		{"struct", map[string]interface{}{"a": []byte("b")}, `{"a":"b"}`},
+		{"int", int64(3), `3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(jsonValue(tt.in))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowWriters_NullsAndBytes(t *testing.T) {
+	cols := []string{"id", "note", "embedding"}
+	rows := [][]interface{}{
+		{[]byte("a1"), nil, []interface{}{float32(0.5)}},
+		{"b22", "two\nlines", nil},
+	}
+	render := func(format string) string {
+		var out strings.Builder
+		rw, err := newRowWriter(format, &out, cols)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rows {
+			values := make([]interface{}, len(r))
+			for i, v := range r {
+				values[i] = jsonValue(v)
+			}
+			if err := rw.Row(values); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := rw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	wantTable := "id   note        embedding\n" +
+		"---  ----------  ---------\n" +
+		"a1   NULL        [0.5]\n" +
+		"b22  two\\nlines  NULL\n"
+	if got := render("table"); got != wantTable {
+		t.Errorf("table:\n%s\nwant:\n%s", got, wantTable)
+	}
+
+	wantCSV := "id,note,embedding\n" +
+		"a1,,[0.5]\n" +
+		"b22,\"two\nlines\",\n"
+	if got := render("csv"); got != wantCSV {
+		t.Errorf("csv:\n%q\nwant:\n%q", got, wantCSV)
+	}
+
+	wantJSON := `{"embedding":[0.5],"id":"a1","note":null}` + "\n" +
+		`{"embedding":null,"id":"b22","note":"two\nlines"}` + "\n"
+	if got := render("json"); got != wantJSON {
+		t.Errorf("json:\n%s\nwant:\n%s", got, wantJSON)
+	}
+}
+
+func TestCheckSelectOnly(t *testing.T) {
+	allowed := []string{
+		"SELECT 1",
+		"  select id FROM sessions;",
+		"-- newest first\nSELECT id FROM sessions ORDER BY captured_at DESC",
+		"/* count */ SELECT count(*) FROM turns",
+		"WITH t AS (SELECT 1 AS n) SELECT n FROM t",
+		"WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r WHERE n < 3), s AS MATERIALIZED (SELECT 2) SELECT * FROM r, s",
+		"SELECT 'a; DELETE FROM turns' AS s",
+		`SELECT "weird;name" FROM sessions`,
+		"SELECT E'it\\'s; DROP TABLE turns'",
+		"SELECT (SELECT 1); -- trailing comment",
+	}
+	for _, q := range allowed {
+		if err := checkSelectOnly(q); err != nil {
+			t.Errorf("checkSelectOnly(%q) = %v, want nil", q, err)
+		}
+	}
+
+	rejected := []string{
+		"",
+		"-- only a comment",
+		"DELETE FROM turns",
+		"/*x*/DELETE FROM turns",
+		"-- hi\n  /* there */ DROP TABLE turns",
+		"WITH x AS (SELECT id FROM sessions) DELETE FROM turns WHERE session_id IN (SELECT id FROM x)",
+		"WITH x AS (SELECT 1) INSERT INTO tags SELECT * FROM x",
+		"COPY (SELECT * FROM turns) TO '/tmp/turns.csv'",
+		"COPY turns TO '/tmp/turns.parquet' (FORMAT parquet)",
+		"PRAGMA enable_profiling",
+		"ATTACH '/tmp/other.db' AS other",
+		"SELECT 1; DELETE FROM turns",
+		"SELECT 'a'; DROP TABLE turns",
+		"SELECT E'\\''; DELETE FROM turns; --'",
+		"(SELECT 1)",
+		"FROM turns",
+	}
+	for _, q := range rejected {
+		if err := checkSelectOnly(q); err == nil {
+			t.Errorf("checkSelectOnly(%q) = nil, want an error", q)
+		}
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
@@ -16,7 +20,19 @@ import (
 
 const (
 	defaultSnippetSize = 300
-	defaultLimit       = 20
+
+	// minSnippetSize is the smallest non-zero --snippet-len, so a snippet
+	// can hold a few words around the match.
+	minSnippetSize = 20
+	defaultLimit   = 20
+
+	// wholeSnippet is the SnippetLen that keeps snippets untruncated, as
+	// --grep does unless --snippet-len is given.
+	wholeSnippet = -1
+
+	// maxOutputTurns bounds --output-turns so inlined turns can't turn a
+	// recall into a dump of the whole corpus.
+	maxOutputTurns = 5
 
 	// 2-way weights (fallback when nomic is unavailable).
 	bm25Weight2Way = 0.4
@@ -31,11 +47,106 @@ const (
 // RecallFilters holds the search parameters for the recall command.
 type RecallFilters struct {
 	Query  string
-	File   string // regex
-	Commit string // SHA prefix
-	Author string // email
-	Actor  string // "human" | "agent"
+	File   string    // regex
+	Commit string    // SHA prefix
+	Author string    // email
+	Actor  string    // "human" | "agent"
+	Since  time.Time // captured_at lower bound (inclusive); zero = unbounded
+	Until  time.Time // captured_at upper bound (inclusive); zero = unbounded
 	Limit  int
+	Offset int // matches to skip before the first result, for paging
+
+	// SnippetLen is the snippet window in bytes; 0 leaves snippets empty
+	// and wholeSnippet keeps them whole.
+	SnippetLen int
+
+	// ExpandCommit adds, after each result, the other sessions linked to
+	// the same checkpoint.
+	ExpandCommit bool
+
+	// Weights overrides the BM25/LSA blend. The zero value keeps the defaults.
+	Weights blendWeights
+
+	// NoBM25 and NoLSA drop that signal from the hybrid score for this
+	// query. Unlike Weights, the remaining weights are not renormalized.
+	// NoLSA drops nomic too, so what is left ranks as BM25 alone.
+	NoBM25 bool
+	NoLSA  bool
+
+	// Checkpoint is a git SHA prefix. Only sessions linked to a checkpoint
+	// at a matching commit are returned: what was known as of that commit.
+	Checkpoint string
+
+	// CheckpointSessions is the session set Checkpoint resolves to, filled
+	// in by runRecall from the data DB's checkpoint_sessions.
+	CheckpointSessions map[string]bool
+
+	// Tag restricts results to sessions tagged with `rekal tag`.
+	Tag string
+
+	// TagSessions is the session set Tag resolves to, filled in by
+	// runRecall from the data DB's session_tags.
+	TagSessions map[string]bool
+
+	// Dir restricts results to sessions whose working directory is this
+	// git-root-relative directory or below it.
+	Dir string
+
+	// DirSessions is the session set Dir resolves to, filled in by
+	// runRecall from the data DB's sessions.cwd.
+	DirSessions map[string]bool
+
+	// Fuzzy retries a query that found nothing with misspelled words
+	// replaced by close matches from the indexed turns.
+	Fuzzy bool
+
+	// Semantic ranks a query by LSA and nomic similarity alone, skipping
+	// BM25, for conceptual queries that share few words with the sessions.
+	Semantic bool
+
+	// Grep matches Query as a literal case-insensitive substring of turn
+	// content instead of ranking it, for exact strings the stemmer would
+	// mangle.
+	Grep bool
+
+	// OutputTurns inlines the full turns of the top OutputTurns results
+	// (at most maxOutputTurns), saving a `rekal query --session` per result.
+	OutputTurns int
+
+	// ScopeEmail is the current user's email under --scope self. When set,
+	// BM25, LSA and nomic only consider that user's sessions, and LSA builds
+	// its embedding space from them alone. Empty = team (everyone).
+	ScopeEmail string
+
+	// LSA is the team-wide LSA model and session vectors, loaded once by
+	// rekal repl so each query skips reloading them. Nil loads them per
+	// query.
+	LSA *lsaIndex
+}
+
+// blendWeights is the relative weight of BM25 and LSA in the hybrid score,
+// normalized to sum to 1. When nomic scores are available they keep
+// nomicWeight3Way and BM25 and LSA split the rest in this ratio.
+type blendWeights struct {
+	BM25 float64
+	LSA  float64
+}
+
+// newBlendWeights validates --bm25-weight and --lsa-weight and normalizes
+// them to sum to 1.
+func newBlendWeights(bm25, lsa float64) (blendWeights, error) {
+	// NaN fails every comparison, so it is rejected explicitly.
+	if math.IsNaN(bm25) || bm25 < 0 || bm25 > 1 {
+		return blendWeights{}, fmt.Errorf("--bm25-weight must be between 0 and 1, got %g", bm25)
+	}
+	if math.IsNaN(lsa) || lsa < 0 || lsa > 1 {
+		return blendWeights{}, fmt.Errorf("--lsa-weight must be between 0 and 1, got %g", lsa)
+	}
+	sum := bm25 + lsa
+	if sum == 0 {
+		return blendWeights{}, fmt.Errorf("--bm25-weight and --lsa-weight cannot both be 0")
+	}
+	return blendWeights{BM25: bm25 / sum, LSA: lsa / sum}, nil
 }
 
 // searchResult is a single search result for JSON output.
@@ -45,7 +156,11 @@ type searchResult struct {
 	Snippet        string        `json:"snippet"`
 	SnippetTurnIdx int           `json:"snippet_turn_index"`
 	SnippetRole    string        `json:"snippet_role"`
+	MatchRanges    [][2]int      `json:"match_ranges,omitempty"`  // byte offsets of query terms in Snippet
+	ExpandedFrom   string        `json:"expanded_from,omitempty"` // set on --expand-commit siblings
 	Session        sessionDetail `json:"session"`
+	Turns          []turnOutput  `json:"turns,omitempty"`   // set on the top --output-turns results
+	Matches        []grepMatch   `json:"matches,omitempty"` // set in --grep mode
 }
 
 type sessionDetail struct {
@@ -57,6 +172,7 @@ type sessionDetail struct {
 	TurnCount  int      `json:"turn_count"`
 	ToolCalls  int      `json:"tool_call_count"`
 	Files      []string `json:"files"`
+	Context    []string `json:"context_files,omitempty"` // read or searched, not modified
 }
 
 type searchOutput struct {
@@ -64,7 +180,17 @@ type searchOutput struct {
 	Query   string            `json:"query"`
 	Filters map[string]string `json:"filters"`
 	Mode    string            `json:"mode"`
-	Total   int               `json:"total"`
+	// Total counts every session that matched, not just this page.
+	Total  int `json:"total"`
+	Offset int `json:"offset,omitempty"`
+	// Limit is omitted when there is none (--limit 0).
+	Limit int `json:"limit,omitempty"`
+	// HasMore is true when there are matches beyond this page.
+	HasMore bool `json:"has_more,omitempty"`
+
+	// FuzzyQuery is the respelled query results were found with when mode
+	// is "fuzzy".
+	FuzzyQuery string `json:"fuzzy_query,omitempty"`
 }
 
 // bm25Hit represents a BM25 match from the FTS index.
@@ -77,64 +203,165 @@ type bm25Hit struct {
 	score     float64
 }
 
-func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error {
-	indexDB, err := db.OpenIndex(gitRoot)
+// openUpdatedIndex opens the index DB read-only with the FTS extension
+// loaded, building it first if it has never been built. A built index is
+// searched as it is, with a warning when the data DB has newer sessions:
+// checkpoint and sync keep it up to date, so a read never writes to it.
+func openUpdatedIndex(cmd *cobra.Command, gitRoot string) (*sql.DB, error) {
+	indexDB, err := db.OpenIndexReadOnly(gitRoot)
 	if err != nil {
-		return fmt.Errorf("open index db: %w", err)
+		return nil, fmt.Errorf("open index db: %w", err)
 	}
-	defer indexDB.Close()
 
-	// Load FTS extension.
-	if err := db.LoadFTSExtension(indexDB); err != nil {
-		return fmt.Errorf("load fts extension: %w", err)
-	}
-
-	// Auto-rebuild if index is empty.
 	if !db.IsIndexPopulated(indexDB) {
+		indexDB.Close()
 		fmt.Fprintln(cmd.ErrOrStderr(), "index not built, rebuilding...")
+		if err := runIndex(cmd, gitRoot, indexOptions{}); err != nil {
+			return nil, err
+		}
+		if indexDB, err = db.OpenIndexReadOnly(gitRoot); err != nil {
+			return nil, fmt.Errorf("reopen index db: %w", err)
+		}
+	} else if n, err := staleSessions(indexDB, gitRoot); err == nil && n > 0 {
+		// The staleness check is best effort: an unreadable data DB
+		// doesn't stop the search.
+		fmt.Fprintf(cmd.ErrOrStderr(), "rekal: warning: index is stale: %d session(s) captured since it was last updated; run 'rekal index'\n", n)
+	}
+
+	if err := db.LoadFTSExtension(indexDB); err != nil {
 		indexDB.Close()
-		if err := runIndex(cmd, gitRoot); err != nil {
+		return nil, fmt.Errorf("load fts extension: %w", err)
+	}
+	return indexDB, nil
+}
+
+// runRecall searches the index and writes the results to stdout in format,
+// "json" or "text".
+func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters, format string) error {
+	indexDB, err := openUpdatedIndex(cmd, gitRoot)
+	if err != nil {
+		return err
+	}
+	defer indexDB.Close()
+
+	return recallIndex(cmd, gitRoot, indexDB, filters, format)
+}
+
+// recallIndex runs one recall against an open index DB and writes the
+// results to stdout in format, "json" or "text".
+func recallIndex(cmd *cobra.Command, gitRoot string, indexDB *sql.DB, filters RecallFilters, format string) error {
+	var err error
+	if filters.Author != "" || filters.ScopeEmail != "" {
+		// session_facets holds canonical emails; match an alias as its owner.
+		var aliases map[string]string
+		if aliases, err = db.LoadEmailAliases(gitRoot); err != nil {
 			return err
 		}
-		indexDB, err = db.OpenIndex(gitRoot)
+		filters.Author = db.CanonicalEmail(aliases, filters.Author)
+		filters.ScopeEmail = db.CanonicalEmail(aliases, filters.ScopeEmail)
+	}
+	if filters.Checkpoint != "" {
+		// The index has only one checkpoint per session, so local links
+		// are read from the data DB; team sessions, which only the index
+		// has, match on their facets' checkpoint.
+		if filters.CheckpointSessions, err = dataSessions(gitRoot, func(d *sql.DB) (map[string]bool, error) {
+			return db.QuerySessionIDsByCheckpointSHA(d, filters.Checkpoint)
+		}); err != nil {
+			return err
+		}
+		team, err := db.QueryIndexSessionIDsByCheckpointSHA(indexDB, filters.Checkpoint)
 		if err != nil {
-			return fmt.Errorf("reopen index db: %w", err)
+			return err
 		}
-		defer indexDB.Close()
-		if err := db.LoadFTSExtension(indexDB); err != nil {
-			return fmt.Errorf("reload fts extension: %w", err)
+		for id := range team {
+			filters.CheckpointSessions[id] = true
 		}
 	}
-
-	limit := filters.Limit
-	if limit <= 0 {
-		limit = defaultLimit
+	if filters.Tag != "" {
+		if filters.TagSessions, err = dataSessions(gitRoot, func(d *sql.DB) (map[string]bool, error) {
+			return db.QuerySessionIDsByTag(d, filters.Tag)
+		}); err != nil {
+			return err
+		}
+	}
+	if filters.Dir != "" {
+		if filters.DirSessions, err = dataSessions(gitRoot, func(d *sql.DB) (map[string]bool, error) {
+			return db.QuerySessionIDsByDir(d, filters.Dir)
+		}); err != nil {
+			return err
+		}
 	}
 
+	limit := filters.Limit
 	var results []searchResult
+	var total int
 	mode := "filter"
+	query := filters.Query
+	var respelled string
 
-	if filters.Query != "" {
+	if filters.Grep {
+		mode = "grep"
+		results, total, err = grepSearch(indexDB, filters, limit)
+	} else if filters.Query != "" {
 		mode = "hybrid"
-		results, err = hybridSearch(indexDB, filters, limit)
+		if filters.Semantic {
+			mode = "semantic"
+			if err := ensureSemanticEmbeddings(indexDB, filters); err != nil {
+				return err
+			}
+		}
+		results, total, err = hybridSearch(indexDB, filters, limit)
+		if err == nil && total == 0 && filters.Fuzzy {
+			var fuzzy []searchResult
+			var fuzzyTotal int
+			if fuzzy, fuzzyTotal, respelled, err = fuzzyFallback(indexDB, filters, limit); respelled != "" {
+				results, total, mode, query = fuzzy, fuzzyTotal, "fuzzy", respelled
+			}
+		}
 	} else {
-		results, err = filterSearch(indexDB, filters, limit)
+		results, total, err = filterSearch(indexDB, filters, limit)
 	}
 	if err != nil {
 		return err
 	}
+	hasMore := filters.Offset+len(results) < total
+
+	if filters.ExpandCommit {
+		results, err = expandCommitSiblings(indexDB, results, query, filters.SnippetLen)
+		if err != nil {
+			return err
+		}
+	}
+	if err := attachTurns(indexDB, results, filters.OutputTurns); err != nil {
+		return err
+	}
 
 	output := searchOutput{
 		Results: results,
 		Query:   filters.Query,
 		Filters: map[string]string{
-			"file":   filters.File,
-			"actor":  filters.Actor,
-			"commit": filters.Commit,
-			"author": filters.Author,
+			"file":       filters.File,
+			"actor":      filters.Actor,
+			"commit":     filters.Commit,
+			"checkpoint": filters.Checkpoint,
+			"tag":        filters.Tag,
+			"dir":        filters.Dir,
+			"author":     filters.Author,
+			"since":      formatTimeBound(filters.Since),
+			"until":      formatTimeBound(filters.Until),
+			"scope":      recallScope(filters),
 		},
-		Mode:  mode,
-		Total: len(results),
+		Mode:       mode,
+		Total:      total,
+		Offset:     filters.Offset,
+		Limit:      limit,
+		HasMore:    hasMore,
+		FuzzyQuery: respelled,
+	}
+
+	if format == "text" {
+		writeRecallText(cmd.OutOrStdout(), output, colorEnabled(cmd.OutOrStdout()))
+		return nil
 	}
 
 	data, err := json.MarshalIndent(output, "", "  ")
@@ -145,22 +372,111 @@ func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error
 	return nil
 }
 
-func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, error) {
-	// Step 1: BM25 search.
-	bm25Hits, err := bm25Search(indexDB, filters.Query)
+// ensureSemanticEmbeddings fails if the index has no embeddings --semantic
+// could rank by, so an unbuilt model reads as an error rather than as no
+// matches.
+func ensureSemanticEmbeddings(indexDB *sql.DB, filters RecallFilters) error {
+	stats, err := db.QueryEmbeddingStats(indexDB)
 	if err != nil {
-		return nil, fmt.Errorf("bm25 search: %w", err)
+		return err
+	}
+	for _, s := range stats {
+		if s.Count == 0 {
+			continue
+		}
+		if s.Model == "lsa-v1" || (s.Model == nomic.ModelName && nomic.Supported()) {
+			return nil
+		}
 	}
+	return fmt.Errorf("--semantic: the index has no session embeddings; run 'rekal index' once there are at least two sessions")
+}
 
-	// Step 2: LSA search.
-	lsaScores, err := lsaSearch(indexDB, filters.Query)
+// attachTurns fills in the full turns of the first k ranked results.
+// --expand-commit siblings are not ranked and don't count toward k.
+func attachTurns(indexDB *sql.DB, results []searchResult, k int) error {
+	for i := range results {
+		if k <= 0 {
+			break
+		}
+		if results[i].ExpandedFrom != "" {
+			continue
+		}
+		turns, err := db.QueryIndexTurns(indexDB, results[i].SessionID)
+		if err != nil {
+			return err
+		}
+		results[i].Turns = make([]turnOutput, 0, len(turns))
+		for _, t := range turns {
+			results[i].Turns = append(results[i].Turns, turnOutput{
+				Index:   t.TurnIndex,
+				Role:    t.Role,
+				Content: t.Content,
+				Ts:      t.Ts,
+			})
+		}
+		k--
+	}
+	return nil
+}
+
+// dataSessions runs a session lookup against the data DB, which holds what
+// the index doesn't: tags, working directories and every checkpoint link.
+func dataSessions(gitRoot string, query func(*sql.DB) (map[string]bool, error)) (map[string]bool, error) {
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
 	if err != nil {
-		// LSA failure is non-fatal — fall back to BM25 only.
-		lsaScores = nil
+		return nil, fmt.Errorf("open data db: %w", err)
+	}
+	defer dataDB.Close()
+
+	return query(dataDB)
+}
+
+// recallScope returns the --scope value the filters were built from.
+func recallScope(filters RecallFilters) string {
+	if filters.ScopeEmail != "" {
+		return "self"
+	}
+	return "team"
+}
+
+// hybridSearch ranks sessions for filters.Query and returns the page of
+// limit results (all of them when limit is 0) after filters.Offset, plus
+// the number of sessions that matched in all.
+func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, int, error) {
+	// Under --scope self, every search only sees the user's own sessions.
+	var own map[string]bool
+	if filters.ScopeEmail != "" {
+		var err error
+		if own, err = db.QuerySessionIDsByEmail(indexDB, filters.ScopeEmail); err != nil {
+			return nil, 0, fmt.Errorf("scope self: %w", err)
+		}
 	}
 
-	// Step 3: Nomic deep semantic search (non-fatal).
-	nomicScores, _ := nomicSearch(indexDB, filters.Query)
+	// Step 1: BM25 search.
+	var bm25Hits []bm25Hit
+	if !filters.NoBM25 && !filters.Semantic {
+		var err error
+		if bm25Hits, err = bm25Search(indexDB, filters.Query, filters.ScopeEmail); err != nil {
+			return nil, 0, fmt.Errorf("bm25 search: %w", err)
+		}
+	}
+
+	// Step 2: LSA search.
+	var lsaScores map[string]float64
+	if !filters.NoLSA {
+		var err error
+		if lsaScores, err = lsaSearch(indexDB, filters.Query, own, filters.LSA); err != nil {
+			// LSA failure is non-fatal — fall back to BM25 only.
+			lsaScores = nil
+		}
+	}
+
+	// Step 3: Nomic deep semantic search (non-fatal). --no-lsa leaves it out
+	// as well, so that only BM25 ranks.
+	var nomicScores map[string]float64
+	if !filters.NoLSA {
+		nomicScores, _ = nomicSearch(indexDB, filters.Query, own)
+	}
 
 	// Step 4: Group by session, pick best turn per session.
 	sessions := make(map[string]*sessionHit)
@@ -177,14 +493,6 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 		}
 	}
 
-	// Normalize BM25 scores to [0,1].
-	var maxBM25 float64
-	for _, sh := range sessions {
-		if sh.bm25Max > maxBM25 {
-			maxBM25 = sh.bm25Max
-		}
-	}
-
 	// Add LSA scores.
 	for sid, score := range lsaScores {
 		sh, ok := sessions[sid]
@@ -196,14 +504,6 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 		sh.lsaScore = score
 	}
 
-	// Normalize LSA scores to [0,1].
-	var maxLSA float64
-	for _, sh := range sessions {
-		if sh.lsaScore > maxLSA {
-			maxLSA = sh.lsaScore
-		}
-	}
-
 	// Add nomic scores.
 	for sid, score := range nomicScores {
 		sh, ok := sessions[sid]
@@ -214,16 +514,36 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 		sh.nomicScore = score
 	}
 
-	// Normalize nomic scores to [0,1].
-	var maxNomic float64
+	// --semantic gives BM25's share of the weight to LSA.
+	weights := filters.Weights
+	if filters.Semantic {
+		weights = blendWeights{LSA: 1}
+	}
+	scoredResults := scoreSessions(sessions, len(nomicScores) > 0, weights)
+
+	// Apply filters and build results.
+	return buildResults(indexDB, scoredResults, filters, limit)
+}
+
+// scoreSessions normalizes each signal to [0,1] across sessions and blends
+// them into a hybrid score — 3-way when nomic is available, 2-way fallback.
+// Results are sorted by score, descending.
+func scoreSessions(sessions map[string]*sessionHit, useNomic bool, w blendWeights) []scored {
+	var maxBM25, maxLSA, maxNomic float64
 	for _, sh := range sessions {
-		if sh.nomicScore > maxNomic {
-			maxNomic = sh.nomicScore
-		}
+		maxBM25 = max(maxBM25, sh.bm25Max)
+		maxLSA = max(maxLSA, sh.lsaScore)
+		maxNomic = max(maxNomic, sh.nomicScore)
+	}
+
+	bm25W, lsaW, nomicW := bm25Weight2Way, lsaWeight2Way, 0.0
+	if useNomic {
+		bm25W, lsaW, nomicW = bm25Weight3Way, lsaWeight3Way, nomicWeight3Way
+	}
+	if w != (blendWeights{}) {
+		bm25W, lsaW = (1-nomicW)*w.BM25, (1-nomicW)*w.LSA
 	}
 
-	// Compute hybrid scores — 3-way when nomic available, 2-way fallback.
-	useNomic := len(nomicScores) > 0
 	var scoredResults []scored
 	for sid, sh := range sessions {
 		bm25Norm := 0.0
@@ -235,39 +555,40 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 			lsaNorm = sh.lsaScore / maxLSA
 		}
 
-		var hybrid float64
-		if useNomic {
-			nomicNorm := 0.0
-			if maxNomic > 0 {
-				nomicNorm = sh.nomicScore / maxNomic
-			}
-			hybrid = bm25Weight3Way*bm25Norm + lsaWeight3Way*lsaNorm + nomicWeight3Way*nomicNorm
-		} else {
-			hybrid = bm25Weight2Way*bm25Norm + lsaWeight2Way*lsaNorm
+		nomicNorm := 0.0
+		if useNomic && maxNomic > 0 {
+			nomicNorm = sh.nomicScore / maxNomic
 		}
+		hybrid := bm25W*bm25Norm + lsaW*lsaNorm + nomicW*nomicNorm
 		scoredResults = append(scoredResults, scored{sid, hybrid, sh})
 	}
 
 	// Sort by score descending.
 	sortScored(scoredResults)
-
-	// Apply filters and build results.
-	return buildResults(indexDB, scoredResults, filters, limit)
+	return scoredResults
 }
 
-func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, error) {
+// filterSearch lists the sessions matching filters, newest first, and
+// returns the page of limit results (all of them when limit is 0) after
+// filters.Offset plus the number of sessions that matched in all.
+func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, int, error) {
 	// Build WHERE clause from filters.
 	where, args := buildFilterWhere(filters)
-
-	query := "SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets"
 	if where != "" {
-		query += " WHERE " + where
+		where = " WHERE " + where
+	}
+
+	var total int
+	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("filter count: %w", err)
 	}
-	query += " ORDER BY captured_at DESC LIMIT " + fmt.Sprintf("%d", limit)
+
+	query := "SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets" + where
+	query += " ORDER BY captured_at DESC, session_id" + pageClause(limit, filters.Offset)
 
 	rows, err := indexDB.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("filter query: %w", err)
+		return nil, 0, fmt.Errorf("filter query: %w", err)
 	}
 	defer rows.Close() //nolint:errcheck
 
@@ -275,11 +596,12 @@ func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 	for rows.Next() {
 		var sf sessionFacetRow
 		if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA); err != nil {
-			return nil, fmt.Errorf("scan facet: %w", err)
+			return nil, 0, fmt.Errorf("scan facet: %w", err)
 		}
 
 		files, _ := querySessionFiles(indexDB, sf.sessionID)
-		snippet, turnIdx, role := firstTurnSnippet(indexDB, sf.sessionID)
+		contextFiles, _ := querySessionContextFiles(indexDB, sf.sessionID)
+		snippet, turnIdx, role := firstTurnSnippet(indexDB, sf.sessionID, filters.SnippetLen)
 
 		results = append(results, searchResult{
 			SessionID:      sf.sessionID,
@@ -296,10 +618,11 @@ func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 				TurnCount:  sf.turnCount,
 				ToolCalls:  sf.toolCallCount,
 				Files:      files,
+				Context:    contextFiles,
 			},
 		})
 	}
-	return results, rows.Err()
+	return results, total, rows.Err()
 }
 
 type sessionFacetRow struct {
@@ -330,11 +653,41 @@ func buildFilterWhere(filters RecallFilters) (string, []interface{}) {
 		args = append(args, filters.Author)
 		idx++
 	}
+	if filters.ScopeEmail != "" {
+		conditions = append(conditions, fmt.Sprintf("user_email = $%d", idx))
+		args = append(args, filters.ScopeEmail)
+		idx++
+	}
 	if filters.Commit != "" {
 		conditions = append(conditions, fmt.Sprintf("git_sha LIKE $%d", idx))
 		args = append(args, filters.Commit+"%")
 		idx++
 	}
+	if filters.Checkpoint != "" {
+		var cond string
+		cond, args, idx = sessionSetCondition(filters.CheckpointSessions, args, idx)
+		conditions = append(conditions, cond)
+	}
+	if filters.Tag != "" {
+		var cond string
+		cond, args, idx = sessionSetCondition(filters.TagSessions, args, idx)
+		conditions = append(conditions, cond)
+	}
+	if filters.Dir != "" {
+		var cond string
+		cond, args, idx = sessionSetCondition(filters.DirSessions, args, idx)
+		conditions = append(conditions, cond)
+	}
+	if !filters.Since.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("captured_at >= $%d", idx))
+		args = append(args, filters.Since.UTC())
+		idx++
+	}
+	if !filters.Until.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("captured_at <= $%d", idx))
+		args = append(args, filters.Until.UTC())
+		idx++
+	}
 	if filters.File != "" {
 		// File filter applied post-query via files_index.
 		conditions = append(conditions, fmt.Sprintf("session_id IN (SELECT DISTINCT session_id FROM files_index WHERE regexp_matches(file_path, $%d))", idx))
@@ -344,7 +697,24 @@ func buildFilterWhere(filters RecallFilters) (string, []interface{}) {
 	return strings.Join(conditions, " AND "), args
 }
 
-func bm25Search(indexDB *sql.DB, query string) ([]bm25Hit, error) {
+// sessionSetCondition returns a "session_id IN (...)" condition for ids,
+// numbering placeholders from idx, or FALSE when the set is empty.
+func sessionSetCondition(ids map[string]bool, args []interface{}, idx int) (string, []interface{}, int) {
+	if len(ids) == 0 {
+		return "FALSE", args, idx
+	}
+	placeholders := make([]string, 0, len(ids))
+	for id := range ids {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", idx))
+		args = append(args, id)
+		idx++
+	}
+	return "session_id IN (" + strings.Join(placeholders, ", ") + ")", args, idx
+}
+
+// bm25Search returns the top BM25 turn hits. A non-empty email restricts
+// hits to that author's sessions before the candidate limit is applied.
+func bm25Search(indexDB *sql.DB, query, email string) ([]bm25Hit, error) {
 	// Check if FTS index exists (it won't if there are no turns).
 	var count int
 	if err := indexDB.QueryRow("SELECT count(*) FROM turns_ft").Scan(&count); err != nil || count == 0 {
@@ -356,9 +726,10 @@ func bm25Search(indexDB *sql.DB, query string) ([]bm25Hit, error) {
 		       fts_main_turns_ft.match_bm25(ft.id, $1) AS score
 		FROM turns_ft ft
 		WHERE score IS NOT NULL
+		  AND ($2 = '' OR ft.session_id IN (SELECT session_id FROM session_facets WHERE user_email = $2))
 		ORDER BY score DESC
 		LIMIT 200
-	`, query)
+	`, query, email)
 	if err != nil {
 		// FTS index may not exist — return empty gracefully.
 		return nil, nil
@@ -376,8 +747,46 @@ func bm25Search(indexDB *sql.DB, query string) ([]bm25Hit, error) {
 	return hits, rows.Err()
 }
 
-func lsaSearch(indexDB *sql.DB, query string) (map[string]float64, error) {
-	// Load LSA embeddings only.
+// lsaIndex is an LSA model and the session vectors to score a query
+// against in its space.
+type lsaIndex struct {
+	model      *lsa.Model
+	embeddings map[string][]float64
+}
+
+// lsaSearch scores sessions by LSA similarity to the query. A non-nil own
+// set builds a separate embedding space from just those sessions, so other
+// authors' vocabulary doesn't shape the result. Otherwise cached is used
+// when set, else the team-wide model is loaded.
+func lsaSearch(indexDB *sql.DB, query string, own map[string]bool, cached *lsaIndex) (map[string]float64, error) {
+	idx := cached
+	if own != nil || idx == nil {
+		var err error
+		if idx, err = loadLSAIndex(indexDB, own); err != nil || idx == nil {
+			return nil, err
+		}
+	}
+
+	queryVec := idx.model.Embed(query)
+
+	scores := make(map[string]float64)
+	for sid, emb := range idx.embeddings {
+		sim := lsa.CosineSimilarity(queryVec, emb)
+		if sim > 0 {
+			scores[sid] = sim
+		}
+	}
+	return scores, nil
+}
+
+// loadLSAIndex loads the stored LSA session vectors and the model to project
+// queries with: the one saved by `rekal index` when it still matches, else
+// one rebuilt from session content at the stored vectors' dimension. A
+// non-nil own set always rebuilds from just those sessions and uses the
+// scoped model's vectors, as does a model whose dimension still differs
+// from the stored vectors', since cosine similarity across dimensions is
+// always 0. Returns nil if the index has no LSA embeddings.
+func loadLSAIndex(indexDB *sql.DB, own map[string]bool) (*lsaIndex, error) {
 	embeddings, err := db.QueryEmbeddings(indexDB, "lsa-v1")
 	if err != nil {
 		return nil, err
@@ -386,32 +795,77 @@ func lsaSearch(indexDB *sql.DB, query string) (map[string]float64, error) {
 		return nil, nil
 	}
 
-	// We need the LSA model to project the query. Rebuild from session content.
-	sessionContent, err := db.QuerySessionContent(indexDB)
-	if err != nil {
-		return nil, err
+	var model *lsa.Model
+	if own == nil {
+		model = loadLSAModel(indexDB)
 	}
-
-	model, err := lsa.Build(sessionContent, lsa.DefaultDimension)
-	if err != nil || model == nil {
-		return nil, err
+	if model == nil {
+		sessionContent, err := db.QuerySessionContent(indexDB)
+		if err != nil {
+			return nil, err
+		}
+		if own != nil {
+			for sid := range sessionContent {
+				if !own[sid] {
+					delete(sessionContent, sid)
+				}
+			}
+		}
+		model, err = lsa.BuildWith(sessionContent, indexEmbeddingDim(indexDB), indexTokenizer(indexDB))
+		if err != nil || model == nil {
+			return nil, err
+		}
 	}
+	if own != nil || !embeddingsHaveDim(embeddings, model.Dim) {
+		// Stored vectors live in the team-wide space or another dimension;
+		// use the model's own.
+		embeddings = model.Vectors()
+	}
+	return &lsaIndex{model: model, embeddings: embeddings}, nil
+}
 
-	queryVec := model.Embed(query)
+// embeddingsHaveDim reports whether every vector has dim components.
+func embeddingsHaveDim(embeddings map[string][]float64, dim int) bool {
+	for _, emb := range embeddings {
+		if len(emb) != dim {
+			return false
+		}
+	}
+	return true
+}
 
-	scores := make(map[string]float64)
-	for sid, emb := range embeddings {
-		sim := lsa.CosineSimilarity(queryVec, emb)
-		if sim > 0 {
-			scores[sid] = sim
+// loadLSAModel returns the model saved by the last index build, or nil if it
+// is missing, unreadable, or stale: built with another tokenizer, or over a
+// different set of sessions than turns_ft now holds (incremental indexing
+// adds sessions without retraining).
+func loadLSAModel(indexDB *sql.DB) *lsa.Model {
+	data, err := db.LoadLSAModel(indexDB, "lsa-v1")
+	if err != nil || data == nil {
+		return nil
+	}
+	model := &lsa.Model{}
+	if err := model.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	if model.Tokenizer != indexTokenizer(indexDB) {
+		return nil
+	}
+	indexed, err := db.QueryIndexedSessionIDs(indexDB)
+	if err != nil || len(indexed) != len(model.SessionIDs) {
+		return nil
+	}
+	for _, sid := range model.SessionIDs {
+		if !indexed[sid] {
+			return nil
 		}
 	}
-	return scores, nil
+	return model
 }
 
 // nomicSearch computes deep semantic similarity using nomic-embed-text embeddings.
 // Non-fatal: returns nil on any failure or when nomic is unavailable.
-func nomicSearch(indexDB *sql.DB, query string) (map[string]float64, error) {
+// A non-nil own set restricts scoring to those sessions.
+func nomicSearch(indexDB *sql.DB, query string, own map[string]bool) (map[string]float64, error) {
 	if !nomic.Supported() {
 		return nil, nil
 	}
@@ -436,6 +890,9 @@ func nomicSearch(indexDB *sql.DB, query string) (map[string]float64, error) {
 
 	scores := make(map[string]float64)
 	for sid, emb := range embeddings {
+		if own != nil && !own[sid] {
+			continue
+		}
 		sim := lsa.CosineSimilarity(queryVec, emb)
 		if sim > 0 {
 			scores[sid] = sim
@@ -444,30 +901,52 @@ func nomicSearch(indexDB *sql.DB, query string) (map[string]float64, error) {
 	return scores, nil
 }
 
-func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit int) ([]searchResult, error) {
+// pageClause returns the LIMIT and OFFSET clause for a page of limit rows
+// after offset. A limit of 0 leaves LIMIT out.
+func pageClause(limit, offset int) string {
+	if limit == 0 {
+		return fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+}
+
+// buildResults applies filters to scored sessions in rank order and builds
+// the results from filters.Offset up to limit, or all of them when limit is
+// 0. Every session is checked, so the returned total counts all matches,
+// not just this page.
+func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit int) ([]searchResult, int, error) {
 	// Compile file regex if present.
 	var fileRe *regexp.Regexp
 	if filters.File != "" {
 		var err error
 		fileRe, err = regexp.Compile(filters.File)
 		if err != nil {
-			return nil, fmt.Errorf("invalid file regex: %w", err)
+			return nil, 0, fmt.Errorf("invalid file regex: %w", err)
 		}
 	}
 
-	var results []searchResult
+	// Counting the total filters every scored session, so facets (and files,
+	// for --file) are loaded for all of them at once rather than per session.
+	ids := make(map[string]bool, len(scored))
 	for _, s := range scored {
-		if len(results) >= limit {
-			break
+		ids[s.sessionID] = true
+	}
+	facets, err := querySessionFacets(indexDB, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	var sessionFiles map[string][]string
+	if fileRe != nil {
+		if sessionFiles, err = querySessionFilesIn(indexDB, ids); err != nil {
+			return nil, 0, err
 		}
+	}
 
-		// Load session facets.
-		var sf sessionFacetRow
-		err := indexDB.QueryRow(
-			"SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets WHERE session_id = $1",
-			s.sessionID,
-		).Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA)
-		if err != nil {
+	var results []searchResult
+	total := 0
+	for _, s := range scored {
+		sf, ok := facets[s.sessionID]
+		if !ok {
 			continue // session not in facets (shouldn't happen)
 		}
 
@@ -478,11 +957,30 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 		if filters.Author != "" && nullStr(sf.email) != filters.Author {
 			continue
 		}
+		if filters.ScopeEmail != "" && nullStr(sf.email) != filters.ScopeEmail {
+			continue
+		}
 		if filters.Commit != "" && !strings.HasPrefix(nullStr(sf.gitSHA), filters.Commit) {
 			continue
 		}
+		if filters.Checkpoint != "" && !filters.CheckpointSessions[s.sessionID] {
+			continue
+		}
+		if filters.Tag != "" && !filters.TagSessions[s.sessionID] {
+			continue
+		}
+		if filters.Dir != "" && !filters.DirSessions[s.sessionID] {
+			continue
+		}
+		if !inTimeRange(sf.capturedAt, filters.Since, filters.Until) {
+			continue
+		}
 
-		files, _ := querySessionFiles(indexDB, s.sessionID)
+		files := sessionFiles[s.sessionID]
+		inPage := total >= filters.Offset && (limit == 0 || len(results) < limit)
+		if fileRe == nil && inPage {
+			files, _ = querySessionFiles(indexDB, s.sessionID)
+		}
 
 		if fileRe != nil {
 			matched := false
@@ -497,17 +995,23 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 			}
 		}
 
+		total++
+		if !inPage {
+			continue
+		}
+		contextFiles, _ := querySessionContextFiles(indexDB, s.sessionID)
+
 		// Build snippet.
 		var snippet string
 		var snippetIdx int
 		var snippetRole string
 
 		if s.hit != nil && s.hit.bestHit.content != "" {
-			snippet = extractSnippet(s.hit.bestHit.content, filters.Query)
+			snippet = extractSnippet(s.hit.bestHit.content, filters.Query, filters.SnippetLen)
 			snippetIdx = s.hit.bestHit.turnIndex
 			snippetRole = s.hit.bestHit.role
 		} else {
-			snippet, snippetIdx, snippetRole = firstTurnSnippet(indexDB, s.sessionID)
+			snippet, snippetIdx, snippetRole = firstTurnSnippet(indexDB, s.sessionID, filters.SnippetLen)
 		}
 
 		results = append(results, searchResult{
@@ -516,6 +1020,7 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 			Snippet:        snippet,
 			SnippetTurnIdx: snippetIdx,
 			SnippetRole:    snippetRole,
+			MatchRanges:    matchRanges(snippet, filters.Query),
 			Session: sessionDetail{
 				Author:     nullStr(sf.email),
 				Actor:      sf.actorType,
@@ -525,11 +1030,87 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 				TurnCount:  sf.turnCount,
 				ToolCalls:  sf.toolCallCount,
 				Files:      files,
+				Context:    contextFiles,
 			},
 		})
 	}
 
-	return results, nil
+	return results, total, nil
+}
+
+// expandCommitSiblings inserts, after each result, the sessions that share a
+// checkpoint with it. Siblings are marked with ExpandedFrom, are not filtered,
+// and do not count toward the limit. A session already in the results is
+// never repeated.
+func expandCommitSiblings(indexDB *sql.DB, results []searchResult, query string, snippetLen int) ([]searchResult, error) {
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		seen[r.SessionID] = true
+	}
+
+	var expanded []searchResult
+	for _, r := range results {
+		expanded = append(expanded, r)
+
+		rows, err := indexDB.Query(`
+			SELECT DISTINCT sib.session_id, sib.user_email, sib.git_branch, sib.actor_type, sib.captured_at,
+			       sib.turn_count, sib.tool_call_count, sib.file_count, sib.checkpoint_id, sib.git_sha
+			FROM session_facets hit
+			JOIN session_facets sib ON sib.checkpoint_id = hit.checkpoint_id
+			WHERE hit.session_id = $1 AND sib.session_id != $1
+			ORDER BY sib.captured_at
+		`, r.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("query commit siblings: %w", err)
+		}
+		var siblings []sessionFacetRow
+		for rows.Next() {
+			var sf sessionFacetRow
+			if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA); err != nil {
+				rows.Close() //nolint:errcheck
+				return nil, fmt.Errorf("scan sibling facet: %w", err)
+			}
+			siblings = append(siblings, sf)
+		}
+		err = rows.Err()
+		rows.Close() //nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sf := range siblings {
+			if seen[sf.sessionID] {
+				continue
+			}
+			seen[sf.sessionID] = true
+
+			files, _ := querySessionFiles(indexDB, sf.sessionID)
+			contextFiles, _ := querySessionContextFiles(indexDB, sf.sessionID)
+			snippet, turnIdx, role := bestTurnSnippet(indexDB, sf.sessionID, query, snippetLen)
+
+			expanded = append(expanded, searchResult{
+				SessionID:      sf.sessionID,
+				Score:          0,
+				Snippet:        snippet,
+				SnippetTurnIdx: turnIdx,
+				SnippetRole:    role,
+				MatchRanges:    matchRanges(snippet, query),
+				ExpandedFrom:   r.SessionID,
+				Session: sessionDetail{
+					Author:     nullStr(sf.email),
+					Actor:      sf.actorType,
+					Branch:     nullStr(sf.branch),
+					CapturedAt: sf.capturedAt,
+					Commit:     nullStr(sf.gitSHA),
+					TurnCount:  sf.turnCount,
+					ToolCalls:  sf.toolCallCount,
+					Files:      files,
+					Context:    contextFiles,
+				},
+			})
+		}
+	}
+	return expanded, nil
 }
 
 type scored struct {
@@ -545,13 +1126,15 @@ type sessionHit struct {
 	nomicScore float64
 }
 
+// sortScored sorts by score descending, breaking ties by session ID so the
+// order is deterministic regardless of map iteration upstream.
 func sortScored(s []scored) {
-	// Sort descending by score.
-	for i := 1; i < len(s); i++ {
-		for j := i; j > 0 && s[j].score > s[j-1].score; j-- {
-			s[j], s[j-1] = s[j-1], s[j]
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].score != s[j].score {
+			return s[i].score > s[j].score
 		}
-	}
+		return s[i].sessionID < s[j].sessionID
+	})
 }
 
 func querySessionFiles(indexDB *sql.DB, sessionID string) ([]string, error) {
@@ -572,7 +1155,77 @@ func querySessionFiles(indexDB *sql.DB, sessionID string) ([]string, error) {
 	return files, rows.Err()
 }
 
-func firstTurnSnippet(indexDB *sql.DB, sessionID string) (string, int, string) {
+// querySessionFacets loads the session_facets rows of ids, keyed by session.
+func querySessionFacets(indexDB *sql.DB, ids map[string]bool) (map[string]sessionFacetRow, error) {
+	cond, args, _ := sessionSetCondition(ids, nil, 1)
+	rows, err := indexDB.Query("SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets WHERE "+cond, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query facets: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	facets := make(map[string]sessionFacetRow, len(ids))
+	for rows.Next() {
+		var sf sessionFacetRow
+		if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA); err != nil {
+			return nil, fmt.Errorf("scan facet: %w", err)
+		}
+		facets[sf.sessionID] = sf
+	}
+	return facets, rows.Err()
+}
+
+// querySessionFilesIn is querySessionFiles for every session in ids at once.
+func querySessionFilesIn(indexDB *sql.DB, ids map[string]bool) (map[string][]string, error) {
+	cond, args, _ := sessionSetCondition(ids, nil, 1)
+	rows, err := indexDB.Query("SELECT DISTINCT session_id, file_path FROM files_index WHERE "+cond, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query files: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	files := make(map[string][]string)
+	for rows.Next() {
+		var id, f string
+		if err := rows.Scan(&id, &f); err != nil {
+			return nil, fmt.Errorf("scan file: %w", err)
+		}
+		files[id] = append(files[id], f)
+	}
+	return files, rows.Err()
+}
+
+// querySessionContextFiles returns files the session read or searched
+// (file_access) but did not modify (files_index).
+func querySessionContextFiles(indexDB *sql.DB, sessionID string) ([]string, error) {
+	rows, err := indexDB.Query(`
+		SELECT DISTINCT fa.file_path FROM file_access fa
+		WHERE fa.session_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM files_index fi
+			WHERE fi.session_id = fa.session_id AND fi.file_path = fa.file_path
+		  )
+		ORDER BY fa.file_path
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var files []string
+	for rows.Next() {
+		var f string
+		if err := rows.Scan(&f); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// firstTurnSnippet returns the first size bytes of the session's first turn,
+// or all of it for wholeSnippet.
+func firstTurnSnippet(indexDB *sql.DB, sessionID string, size int) (string, int, string) {
 	var content, role string
 	var turnIndex int
 	err := indexDB.QueryRow(
@@ -582,58 +1235,87 @@ func firstTurnSnippet(indexDB *sql.DB, sessionID string) (string, int, string) {
 	if err != nil {
 		return "", 0, ""
 	}
-	if len(content) > defaultSnippetSize {
-		content = content[:defaultSnippetSize] + "..."
+	if size == 0 {
+		content = ""
+	} else if size > 0 && len(content) > size {
+		content = content[:size] + "..."
 	}
 	return content, turnIndex, role
 }
 
-// extractSnippet extracts a window around the first query term match.
-func extractSnippet(content, query string) string {
-	if len(content) <= defaultSnippetSize {
-		return content
+// bestTurnSnippet returns a snippet from the session's best BM25 turn for
+// query, falling back to the first turn when nothing matches.
+func bestTurnSnippet(indexDB *sql.DB, sessionID, query string, size int) (string, int, string) {
+	if query != "" {
+		var content, role string
+		var turnIndex int
+		err := indexDB.QueryRow(`
+			SELECT turn_index, role, content
+			FROM (
+				SELECT ft.turn_index, ft.role, ft.content,
+				       fts_main_turns_ft.match_bm25(ft.id, $2) AS score
+				FROM turns_ft ft
+				WHERE ft.session_id = $1
+			)
+			WHERE score IS NOT NULL
+			ORDER BY score DESC
+			LIMIT 1
+		`, sessionID, query).Scan(&turnIndex, &role, &content)
+		if err == nil {
+			return extractSnippet(content, query, size), turnIndex, role
+		}
 	}
+	return firstTurnSnippet(indexDB, sessionID, size)
+}
 
-	lower := strings.ToLower(content)
-	terms := lsa.Tokenize(query)
-
-	bestPos := -1
-	for _, term := range terms {
-		pos := strings.Index(lower, term)
-		if pos >= 0 && (bestPos < 0 || pos < bestPos) {
-			bestPos = pos
-		}
+// extractSnippet extracts a window of at most size bytes around the densest
+// cluster of query terms: the span holding the most distinct terms. When no
+// span holds more than one, it centers on the first match. A size of 0
+// returns no snippet and wholeSnippet all of content.
+func extractSnippet(content, query string, size int) string {
+	if size == 0 {
+		return ""
 	}
+	if size < 0 || len(content) <= size {
+		return content
+	}
+	return snippetAround(content, termHits(content, lsa.Tokenize(query)), size)
+}
 
-	if bestPos < 0 {
+// snippetAround cuts a window of at most size bytes from content, which is
+// longer than size, around the densest cluster of hits, or from the start
+// when there are none.
+func snippetAround(content string, hits []termHit, size int) string {
+	if len(hits) == 0 {
 		// No term match — take first N chars.
-		return content[:defaultSnippetSize] + "..."
+		return content[:size] + "..."
 	}
+	center := densestCluster(hits, size)
 
-	half := defaultSnippetSize / 2
-	start := bestPos - half
+	half := size / 2
+	start := center - half
 	if start < 0 {
 		start = 0
 	}
-	end := start + defaultSnippetSize
+	end := start + size
 	if end > len(content) {
 		end = len(content)
-		start = end - defaultSnippetSize
+		start = end - size
 		if start < 0 {
 			start = 0
 		}
 	}
 
-	// Align to word boundaries.
+	// Align to word boundaries. A window without a space to align on is
+	// cut mid-word rather than emptied.
 	if start > 0 {
-		for start < end && content[start] != ' ' {
-			start++
+		if i := strings.IndexByte(content[start:end], ' '); i >= 0 && start+i+1 < end {
+			start += i + 1
 		}
-		start++ // skip the space
 	}
 	if end < len(content) {
-		for end > start && content[end-1] != ' ' {
-			end--
+		if i := strings.LastIndexByte(content[start:end], ' '); i > 0 {
+			end = start + i + 1
 		}
 	}
 
@@ -649,6 +1331,139 @@ func extractSnippet(content, query string) string {
 	return prefix + snippet + suffix
 }
 
+// matchRanges returns the [start, end) byte offsets in snippet of the query
+// terms extractSnippet matches on, for consumers to highlight.
+func matchRanges(snippet, query string) [][2]int {
+	if query == "" {
+		return nil
+	}
+	return termMatches(snippet, lsa.Tokenize(query))
+}
+
+// termMatches returns the [start, end) byte ranges of termHits in text, in
+// order and with overlaps merged.
+func termMatches(text string, terms []string) [][2]int {
+	var merged [][2]int
+	for _, h := range termHits(text, terms) {
+		if n := len(merged); n > 0 && h.start <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], h.end)
+			continue
+		}
+		merged = append(merged, [2]int{h.start, h.end})
+	}
+	return merged
+}
+
+// termHit is one occurrence of a query term in a turn's text.
+type termHit struct {
+	term       string
+	start, end int
+}
+
+// termHits returns every occurrence of terms in text, case-insensitively,
+// ordered by start. Terms are lowercase, as lsa.Tokenize returns them.
+// Text whose lowercase form has a different byte length cannot be mapped
+// back and gets no hits.
+func termHits(text string, terms []string) []termHit {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return nil
+	}
+	var hits []termHit
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		for from := 0; ; {
+			pos := strings.Index(lower[from:], term)
+			if pos < 0 {
+				break
+			}
+			start := from + pos
+			hits = append(hits, termHit{term, start, start + len(term)})
+			from = start + len(term)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+	return hits
+}
+
+// densestCluster returns the midpoint of the earliest span of at most size
+// bytes holding the most distinct terms among hits, which must be ordered
+// by start. If no span holds two distinct terms, it is the first hit's start.
+func densestCluster(hits []termHit, size int) int {
+	best, bestFrom, bestTo := 1, 0, 0
+	for i := range hits {
+		distinct := map[string]bool{}
+		for j := i; j < len(hits) && hits[j].end-hits[i].start <= size; j++ {
+			distinct[hits[j].term] = true
+			if len(distinct) > best {
+				best, bestFrom, bestTo = len(distinct), i, j
+			}
+		}
+	}
+	if best == 1 {
+		return hits[0].start
+	}
+	return (hits[bestFrom].start + hits[bestTo].end) / 2
+}
+
+// parseTimeBound parses a --since/--until value: an RFC3339 timestamp, or a
+// duration before now such as "7d" or "24h".
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 (2026-02-25T10:00:00Z) or a relative duration (7d, 24h)", value)
+}
+
+// inTimeRange reports whether capturedAt falls within [since, until]. Zero
+// bounds are open. An unparseable capturedAt only passes when unbounded.
+func inTimeRange(capturedAt string, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, capturedAt)
+	if err != nil {
+		return false
+	}
+	if !since.IsZero() && t.Before(since) {
+		return false
+	}
+	if !until.IsZero() && t.After(until) {
+		return false
+	}
+	return true
+}
+
+func formatTimeBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// normalizeRepoDir cleans a --dir value into the slash-separated,
+// git-root-relative form stored in sessions.cwd.
+func normalizeRepoDir(dir string) (string, error) {
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("--dir must be relative to the repository root, got %q", dir)
+	}
+	cleaned := filepath.ToSlash(filepath.Clean(dir))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("--dir must be inside the repository, got %q", dir)
+	}
+	return cleaned, nil
+}
+
 func nullStr(ns sql.NullString) string {
 	if ns.Valid {
 		return ns.String
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyMinWordLen is the shortest query word --fuzzy will respell. Shorter
+// words have too many neighbours within one edit to guess from.
+const fuzzyMinWordLen = 4
+
+// fuzzyMaxEdits is the Damerau-Levenshtein distance tolerated for a word:
+// one edit up to five letters, two beyond.
+func fuzzyMaxEdits(word string) int {
+	if utf8.RuneCountInString(word) <= 5 {
+		return 1
+	}
+	return 2
+}
+
+// fuzzyFallback reruns a query that found nothing with its words
+// respelled. Like hybridSearch it returns a page of results and the total
+// match count, then the respelled query, or "" when no word had a close match
+// and there was nothing to retry.
+func fuzzyFallback(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, int, string, error) {
+	respelled, changed, err := fuzzyQuery(indexDB, filters.Query)
+	if err != nil || !changed {
+		return nil, 0, "", err
+	}
+	filters.Query = respelled
+	results, total, err := hybridSearch(indexDB, filters, limit)
+	return results, total, respelled, err
+}
+
+// fuzzyQuery respells each query word that does not occur in turns_ft as
+// the closest word that does, within fuzzyMaxEdits. Ties go to the word
+// used more often. It returns the rewritten query and whether any word
+// changed; words with no close match are kept as typed.
+func fuzzyQuery(indexDB *sql.DB, query string) (string, bool, error) {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	changed := false
+	for i, word := range words {
+		if utf8.RuneCountInString(word) < fuzzyMinWordLen {
+			continue
+		}
+		match, err := closestIndexedWord(indexDB, word)
+		if err != nil {
+			return "", false, err
+		}
+		if match != "" && match != word {
+			words[i] = match
+			changed = true
+		}
+	}
+	return strings.Join(words, " "), changed, nil
+}
+
+// closestIndexedWord returns the word in turns_ft content nearest to word,
+// or "" if none is within fuzzyMaxEdits. An exact match returns word.
+func closestIndexedWord(indexDB *sql.DB, word string) (string, error) {
+	maxEdits := fuzzyMaxEdits(word)
+	n := utf8.RuneCountInString(word)
+
+	var match string
+	err := indexDB.QueryRow(`
+		WITH vocab AS (
+			SELECT w, count(*) AS uses
+			FROM (SELECT unnest(regexp_split_to_array(lower(content), '[^\p{L}\p{N}]+')) AS w FROM turns_ft)
+			WHERE length(w) BETWEEN $2 AND $3
+			GROUP BY w
+		)
+		SELECT w FROM vocab
+		WHERE damerau_levenshtein(w, $1) <= $4
+		ORDER BY damerau_levenshtein(w, $1), uses DESC, w
+		LIMIT 1
+	`, word, n-maxEdits, n+maxEdits, maxEdits).Scan(&match)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("fuzzy match %q: %w", word, err)
+	}
+	return match, nil
+}
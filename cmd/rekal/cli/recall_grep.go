@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// grepMaxMatches is the most matching lines --grep reports per session.
+const grepMaxMatches = 5
+
+// grepMaxLineLen caps each context line in bytes, so a minified file pasted
+// into a turn does not swamp the output.
+const grepMaxLineLen = 200
+
+// grepMatch is one line of a turn that contains the --grep pattern.
+type grepMatch struct {
+	TurnIndex int    `json:"turn_index"`
+	Role      string `json:"role"`
+	Line      int    `json:"line"`    // 1-based line within the turn's content
+	Context   string `json:"context"` // the line with one line either side
+}
+
+// grepSearch finds sessions with a turn containing filters.Query as a plain
+// case-insensitive substring, newest first. Nothing is tokenized or
+// stemmed, so exact error strings and identifiers match as typed. Like
+// filterSearch it returns the page of limit results after filters.Offset
+// and the number of sessions that matched in all.
+func grepSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, int, error) {
+	where, args := buildFilterWhere(filters)
+	if where != "" {
+		where += " AND "
+	}
+	args = append(args, filters.Query)
+	where = fmt.Sprintf(" WHERE %ssession_id IN (SELECT session_id FROM turns_ft WHERE contains(lower(content), lower($%d)))", where, len(args))
+
+	var total int
+	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("grep count: %w", err)
+	}
+
+	query := "SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets" + where
+	query += " ORDER BY captured_at DESC, session_id" + pageClause(limit, filters.Offset)
+
+	rows, err := indexDB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("grep query: %w", err)
+	}
+	var facets []sessionFacetRow
+	for rows.Next() {
+		var sf sessionFacetRow
+		if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA); err != nil {
+			rows.Close() //nolint:errcheck
+			return nil, 0, fmt.Errorf("scan facet: %w", err)
+		}
+		facets = append(facets, sf)
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("grep query: %w", err)
+	}
+
+	var results []searchResult
+	for _, sf := range facets {
+		matches, err := grepSessionMatches(indexDB, sf.sessionID, filters.Query)
+		if err != nil {
+			return nil, 0, err
+		}
+		files, _ := querySessionFiles(indexDB, sf.sessionID)
+		contextFiles, _ := querySessionContextFiles(indexDB, sf.sessionID)
+
+		r := searchResult{
+			SessionID: sf.sessionID,
+			Matches:   matches,
+			Session: sessionDetail{
+				Author:     nullStr(sf.email),
+				Actor:      sf.actorType,
+				Branch:     nullStr(sf.branch),
+				CapturedAt: sf.capturedAt,
+				Commit:     nullStr(sf.gitSHA),
+				TurnCount:  sf.turnCount,
+				ToolCalls:  sf.toolCallCount,
+				Files:      files,
+				Context:    contextFiles,
+			},
+		}
+		if len(matches) > 0 {
+			r.Snippet = grepSnippet(matches[0].Context, filters.Query, filters.SnippetLen)
+			r.SnippetTurnIdx = matches[0].TurnIndex
+			r.SnippetRole = matches[0].Role
+			r.MatchRanges = termMatches(r.Snippet, []string{strings.ToLower(filters.Query)})
+		}
+		results = append(results, r)
+	}
+	return results, total, nil
+}
+
+// grepSnippet returns a match's context as its result's snippet, windowed to
+// size bytes around the first occurrence of pattern when size is positive.
+// The pattern is matched as typed, not tokenized as a hybrid query is.
+func grepSnippet(context, pattern string, size int) string {
+	if size == 0 {
+		return ""
+	}
+	if size < 0 || len(context) <= size {
+		return context
+	}
+	return snippetAround(context, termHits(context, []string{strings.ToLower(pattern)}), size)
+}
+
+// grepSessionMatches returns up to grepMaxMatches matching lines from the
+// session's turns, in turn order.
+func grepSessionMatches(indexDB *sql.DB, sessionID, pattern string) ([]grepMatch, error) {
+	rows, err := indexDB.Query(
+		"SELECT turn_index, role, content FROM turns_ft WHERE session_id = $1 AND contains(lower(content), lower($2)) ORDER BY turn_index",
+		sessionID, pattern,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("grep turns: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var matches []grepMatch
+	for rows.Next() && len(matches) < grepMaxMatches {
+		var turnIndex int
+		var role, content string
+		if err := rows.Scan(&turnIndex, &role, &content); err != nil {
+			return nil, fmt.Errorf("scan turn: %w", err)
+		}
+		for _, m := range grepTurnMatches(content, pattern) {
+			if len(matches) == grepMaxMatches {
+				break
+			}
+			m.TurnIndex, m.Role = turnIndex, role
+			matches = append(matches, m)
+		}
+	}
+	return matches, rows.Err()
+}
+
+// grepTurnMatches finds each line of content holding pattern, ignoring
+// case. A match that spans lines is reported at the line it starts on.
+func grepTurnMatches(content, pattern string) []grepMatch {
+	lower, lowerPattern := strings.ToLower(content), strings.ToLower(pattern)
+	if lowerPattern == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+
+	var matches []grepMatch
+	lastLine := 0
+	for pos := 0; ; {
+		i := strings.Index(lower[pos:], lowerPattern)
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len(lowerPattern)
+
+		// Lowercasing can change byte lengths but never the newlines, so
+		// counting them in lower gives the line in content.
+		line := strings.Count(lower[:start], "\n") + 1
+		if line == lastLine || line > len(lines) {
+			continue
+		}
+		lastLine = line
+
+		col := start - (strings.LastIndex(lower[:start], "\n") + 1)
+		from, to := max(line-2, 0), min(line+1, len(lines))
+		context := make([]string, 0, to-from)
+		for l := from; l < to; l++ {
+			at := 0
+			if l == line-1 {
+				at = col
+			}
+			context = append(context, clipLine(lines[l], at))
+		}
+		matches = append(matches, grepMatch{Line: line, Context: strings.Join(context, "\n")})
+	}
+	return matches
+}
+
+// clipLine shortens line to about grepMaxLineLen bytes, keeping byte offset
+// at in view, and marks cut ends with "...".
+func clipLine(line string, at int) string {
+	if len(line) <= grepMaxLineLen {
+		return line
+	}
+	start := max(0, min(at-grepMaxLineLen/4, len(line)-grepMaxLineLen))
+	end := start + grepMaxLineLen
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end++
+	}
+	clipped := line[start:end]
+	if start > 0 {
+		clipped = "..." + clipped
+	}
+	if end < len(line) {
+		clipped += "..."
+	}
+	return clipped
+}
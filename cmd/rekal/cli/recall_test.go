@@ -1,13 +1,22 @@
 package cli
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/nomic"
 )
 
 func TestExtractSnippet_ShortContent(t *testing.T) {
 	t.Parallel()
 	content := "short content"
-	snippet := extractSnippet(content, "short")
+	snippet := extractSnippet(content, "short", defaultSnippetSize)
 	if snippet != content {
 		t.Errorf("expected %q, got %q", content, snippet)
 	}
@@ -20,7 +29,7 @@ func TestExtractSnippet_NoMatch(t *testing.T) {
 		content[i] = 'a' + byte(i%26)
 	}
 	contentStr := string(content)
-	snippet := extractSnippet(contentStr, "zzzznotfound")
+	snippet := extractSnippet(contentStr, "zzzznotfound", defaultSnippetSize)
 	if len(snippet) > defaultSnippetSize+10 { // +10 for "..."
 		t.Errorf("snippet too long: %d", len(snippet))
 	}
@@ -38,7 +47,7 @@ func TestExtractSnippet_MatchInMiddle(t *testing.T) {
 		suffix[i] = 'y'
 	}
 	content := string(prefix) + " authentication token " + string(suffix)
-	snippet := extractSnippet(content, "authentication")
+	snippet := extractSnippet(content, "authentication", defaultSnippetSize)
 	if len(snippet) == 0 {
 		t.Error("expected non-empty snippet")
 	}
@@ -47,6 +56,139 @@ func TestExtractSnippet_MatchInMiddle(t *testing.T) {
 	}
 }
 
+func TestExtractSnippet_Size(t *testing.T) {
+	t.Parallel()
+	content := strings.Repeat("lorem ipsum dolor ", 30) + "the flaky retry test " + strings.Repeat("sit amet ", 30)
+
+	snippet := extractSnippet(content, "retry", 60)
+	body := strings.TrimSuffix(strings.TrimPrefix(snippet, "..."), "...")
+	if len(body) > 60 || !strings.Contains(body, "retry") {
+		t.Errorf("--snippet-len 60: got %d bytes %q, want at most 60 around the match", len(body), snippet)
+	}
+
+	if got := extractSnippet(content, "retry", 0); got != "" {
+		t.Errorf("size 0: got %q, want no snippet", got)
+	}
+
+	// A window with no space to align on is cut mid-word, not emptied or
+	// sliced out of range.
+	dense := strings.Repeat("x", 200) + "retry" + strings.Repeat("y", 200)
+	got := extractSnippet(dense, "retry", minSnippetSize)
+	if body := strings.Trim(got, "."); len(body) != minSnippetSize || !strings.Contains(body, "retry") {
+		t.Errorf("spaceless content: got %q", got)
+	}
+}
+
+func TestExtractSnippet_DensestCluster(t *testing.T) {
+	t.Parallel()
+	// "deploy" alone early on; "rollback" and "migration" together later.
+	content := "we talked about the deploy yesterday. " +
+		strings.Repeat("unrelated chatter about lunch plans. ", 15) +
+		"the rollback of the schema migration failed halfway. " +
+		strings.Repeat("more unrelated chatter. ", 15)
+	snippet := extractSnippet(content, "deploy rollback migration", defaultSnippetSize)
+	if !strings.Contains(snippet, "rollback of the schema migration") {
+		t.Errorf("snippet misses the rollback+migration cluster: %q", snippet)
+	}
+	if strings.Contains(snippet, "deploy") {
+		t.Errorf("snippet centered on the lone early term: %q", snippet)
+	}
+	if !strings.HasPrefix(snippet, "...") || strings.HasPrefix(snippet, "... ") {
+		t.Errorf("snippet not aligned to a word boundary: %q", snippet)
+	}
+
+	// With one matching term the window still centers on its first match.
+	single := extractSnippet(content, "deploy nothingelse", defaultSnippetSize)
+	if !strings.Contains(single, "deploy yesterday") {
+		t.Errorf("single-term snippet misses the match: %q", single)
+	}
+}
+
+func TestMatchRanges_PointAtTermsInSnippet(t *testing.T) {
+	t.Parallel()
+	content := strings.Repeat("filler words here ", 20) +
+		"the Token refresh fails when the token expires " +
+		strings.Repeat("more filler text ", 20)
+	snippet := extractSnippet(content, "token expires", defaultSnippetSize)
+	if !strings.HasPrefix(snippet, "...") {
+		t.Fatalf("expected a windowed snippet, got %q", snippet)
+	}
+
+	ranges := matchRanges(snippet, "token expires")
+	var got []string
+	for _, r := range ranges {
+		got = append(got, snippet[r[0]:r[1]])
+	}
+	// Matching is case-insensitive, and "expires" is stemmed to "expir".
+	want := []string{"Token", "token", "expir"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ranges %v cover %q, want %q", ranges, got, want)
+	}
+
+	if r := matchRanges(snippet, ""); r != nil {
+		t.Errorf("empty query: ranges = %v, want none", r)
+	}
+	if r := termMatches("retry retrying", []string{"retr", "retry"}); len(r) != 2 || r[0] != [2]int{0, 5} || r[1] != [2]int{6, 11} {
+		t.Errorf("overlapping terms: ranges = %v, want [[0 5] [6 11]]", r)
+	}
+}
+
+func TestGrepTurnMatches(t *testing.T) {
+	t.Parallel()
+	content := "first line\nthe Retrying loop\nthird\nretrying again, retrying\nlast"
+	got := grepTurnMatches(content, "RETRYING")
+	want := []grepMatch{
+		{Line: 2, Context: "first line\nthe Retrying loop\nthird"},
+		{Line: 4, Context: "third\nretrying again, retrying\nlast"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d matches, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("match %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := grepTurnMatches(content, "retry loop"); len(got) != 0 {
+		t.Errorf("expected no matches, got %+v", got)
+	}
+}
+
+func TestGrepSnippet(t *testing.T) {
+	t.Parallel()
+	context := strings.Repeat("word ", 40) + "panic: x.Y() failed" + strings.Repeat(" word", 40)
+
+	if got := grepSnippet(context, "x.y()", wholeSnippet); got != context {
+		t.Errorf("without --snippet-len: got %q, want the whole context", got)
+	}
+	// The pattern tokenizes to nothing useful, but the window still finds it.
+	if got := grepSnippet(context, "x.y()", 40); !strings.Contains(got, "x.Y()") || len(strings.Trim(got, ".")) > 40 {
+		t.Errorf("--snippet-len 40: got %q, want a window around x.Y()", got)
+	}
+	if got := grepSnippet(context, "x.y()", 0); got != "" {
+		t.Errorf("--snippet-len 0: got %q, want none", got)
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("a", 1000) + "NEEDLE" + strings.Repeat("b", 1000)
+	clipped := clipLine(long, 1000)
+	if !strings.Contains(clipped, "NEEDLE") {
+		t.Errorf("clipped line lost the match: %q", clipped)
+	}
+	if !strings.HasPrefix(clipped, "...") || !strings.HasSuffix(clipped, "...") {
+		t.Errorf("clipped line should be marked at both ends: %q", clipped)
+	}
+	if len(clipped) > grepMaxLineLen+6 {
+		t.Errorf("clipped line too long: %d", len(clipped))
+	}
+	if got := clipLine("short", 0); got != "short" {
+		t.Errorf("short line changed: %q", got)
+	}
+}
+
 func TestNullStr(t *testing.T) {
 	t.Parallel()
 	// Test with zero-value NullString (not valid).
@@ -70,6 +212,246 @@ func TestSortScored(t *testing.T) {
 	}
 }
 
+func TestSortScored_Large(t *testing.T) {
+	t.Parallel()
+	// 10k entries over 50 distinct scores, inserted in scrambled order.
+	const n = 10000
+	s := make([]scored, n)
+	for i := range s {
+		k := (i * 7919) % n
+		s[i] = scored{sessionID: fmt.Sprintf("s%05d", k), score: float64(k%50) / 50}
+	}
+	sortScored(s)
+	for i := 1; i < n; i++ {
+		prev, cur := s[i-1], s[i]
+		if prev.score < cur.score {
+			t.Fatalf("index %d: score %v after %v, want descending", i, cur.score, prev.score)
+		}
+		if prev.score == cur.score && prev.sessionID >= cur.sessionID {
+			t.Fatalf("index %d: tie %s after %s, want session IDs ascending", i, cur.sessionID, prev.sessionID)
+		}
+	}
+}
+
+func TestScoreSessions_Weights(t *testing.T) {
+	t.Parallel()
+
+	// BM25 ranks a > b > c; LSA ranks them the other way round.
+	hits := map[string]*sessionHit{
+		"a": {bm25Max: 9, lsaScore: 0.1},
+		"b": {bm25Max: 5, lsaScore: 0.5},
+		"c": {bm25Max: 1, lsaScore: 0.9},
+	}
+	order := func(s []scored) string {
+		ids := ""
+		for _, r := range s {
+			ids += r.sessionID
+		}
+		return ids
+	}
+
+	pureBM25, err := newBlendWeights(1, 0)
+	if err != nil {
+		t.Fatalf("newBlendWeights: %v", err)
+	}
+	if got := order(scoreSessions(hits, false, pureBM25)); got != "abc" {
+		t.Errorf("lsa-weight 0: order = %s, want BM25 order abc", got)
+	}
+	pureLSA, _ := newBlendWeights(0, 1)
+	if got := order(scoreSessions(hits, false, pureLSA)); got != "cba" {
+		t.Errorf("bm25-weight 0: order = %s, want LSA order cba", got)
+	}
+
+	// Defaults (0.4/0.6): a = 0.4+0.067, b = 0.22+0.33, c = 0.044+0.6.
+	if got := order(scoreSessions(hits, false, blendWeights{})); got != "cba" {
+		t.Errorf("default weights: order = %s, want cba", got)
+	}
+}
+
+func TestNewBlendWeights_RejectsOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	for _, w := range [][2]float64{{-0.1, 0.5}, {0.5, 1.5}, {0, 0}, {math.NaN(), 0.5}, {0.5, math.NaN()}} {
+		if _, err := newBlendWeights(w[0], w[1]); err == nil {
+			t.Errorf("newBlendWeights(%g, %g) should fail", w[0], w[1])
+		}
+	}
+}
+
+func TestScoreSessions_PrefersNomic(t *testing.T) {
+	t.Parallel()
+
+	// LSA ranks a first; nomic ranks b first.
+	hits := map[string]*sessionHit{
+		"a": {lsaScore: 0.9, nomicScore: 0.1},
+		"b": {lsaScore: 0.1, nomicScore: 0.9},
+	}
+	if got := scoreSessions(hits, true, blendWeights{}); got[0].sessionID != "b" {
+		t.Errorf("with nomic: top = %s, want nomic's pick b", got[0].sessionID)
+	}
+	if got := scoreSessions(hits, false, blendWeights{}); got[0].sessionID != "a" {
+		t.Errorf("without nomic: top = %s, want LSA's pick a", got[0].sessionID)
+	}
+}
+
+func TestNomicSearch_UsesNomicVectors(t *testing.T) {
+	t.Parallel()
+	if !nomic.Supported() {
+		t.Skip("nomic embeddings not supported on this platform")
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	indexDB, err := db.OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	defer indexDB.Close()
+	if err := db.LoadFTSExtension(indexDB); err != nil {
+		t.Skipf("fts extension unavailable: %v", err)
+	}
+	if err := db.InitIndexSchema(indexDB); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+
+	embedder, err := nomic.NewEmbedder()
+	if err != nil {
+		t.Fatalf("NewEmbedder: %v", err)
+	}
+	vectors, err := embedder.EmbedSessions(map[string]string{
+		"pool": "tune the database connection pool size under load",
+		"jwt":  "fix the JWT expiry bug in the auth middleware",
+	})
+	embedder.Close()
+	if err != nil {
+		t.Fatalf("EmbedSessions: %v", err)
+	}
+	if len(vectors["pool"]) != nomic.EmbedDim {
+		t.Fatalf("nomic vector has %d dimensions, want %d", len(vectors["pool"]), nomic.EmbedDim)
+	}
+	if err := db.StoreEmbeddings(indexDB, vectors, nomic.ModelName); err != nil {
+		t.Fatalf("StoreEmbeddings: %v", err)
+	}
+	// Low-dimensional LSA vectors that rank the other way must be ignored.
+	lsaVectors := map[string][]float64{"pool": {0, 1}, "jwt": {1, 0}}
+	if err := db.StoreEmbeddings(indexDB, lsaVectors, "lsa-v1"); err != nil {
+		t.Fatalf("StoreEmbeddings lsa: %v", err)
+	}
+
+	scores, err := nomicSearch(indexDB, "database connection pooling", nil)
+	if err != nil {
+		t.Fatalf("nomicSearch: %v", err)
+	}
+	if scores["pool"] <= scores["jwt"] {
+		t.Errorf("scores = %v, want pool above jwt", scores)
+	}
+}
+
+func TestNewBlendWeights(t *testing.T) {
+	t.Parallel()
+
+	w, err := newBlendWeights(0.2, 0.2)
+	if err != nil {
+		t.Fatalf("newBlendWeights: %v", err)
+	}
+	if w.BM25 != 0.5 || w.LSA != 0.5 {
+		t.Errorf("weights should be normalized to sum to 1, got %+v", w)
+	}
+
+	for _, tt := range [][2]float64{{-0.1, 0.5}, {0.5, 1.5}, {0, 0}} {
+		if _, err := newBlendWeights(tt[0], tt[1]); err == nil {
+			t.Errorf("newBlendWeights(%g, %g) should fail", tt[0], tt[1])
+		}
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-02-25T10:00:00Z", time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)},
+		{"7d", time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"24h", time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)},
+		{"90m", time.Date(2026, 3, 10, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.value, now)
+		if err != nil {
+			t.Errorf("parseTimeBound(%q): %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeBound(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "yesterday", "-7d", "2026-02-25"} {
+		if _, err := parseTimeBound(bad, now); err == nil {
+			t.Errorf("parseTimeBound(%q) should fail", bad)
+		}
+	}
+}
+
+func TestNormalizeRepoDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value, want string
+	}{
+		{"services/api", "services/api"},
+		{"services/api/", "services/api"},
+		{"./services//api", "services/api"},
+		{"services/../web", "web"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRepoDir(tt.value)
+		if err != nil {
+			t.Errorf("normalizeRepoDir(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeRepoDir(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"/abs/path", "..", "../sibling", "services/../../x"} {
+		if _, err := normalizeRepoDir(bad); err == nil {
+			t.Errorf("normalizeRepoDir(%q) should fail", bad)
+		}
+	}
+}
+
+func TestInTimeRange(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 25, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		capturedAt string
+		want       bool
+	}{
+		{"2026-02-25T09:59:59Z", false},
+		{"2026-02-25T10:00:00Z", true},
+		{"2026-02-25T10:30:00Z", true},
+		{"2026-02-25T11:00:00Z", true},
+		{"2026-02-25T11:00:01Z", false},
+		{"not a time", false},
+	}
+	for _, tt := range tests {
+		if got := inTimeRange(tt.capturedAt, since, until); got != tt.want {
+			t.Errorf("inTimeRange(%q) = %v, want %v", tt.capturedAt, got, tt.want)
+		}
+	}
+	if !inTimeRange("not a time", time.Time{}, time.Time{}) {
+		t.Error("unbounded range should accept any capturedAt")
+	}
+}
+
 // nullableString mirrors sql.NullString for testing.
 type nullableString struct {
 	String string
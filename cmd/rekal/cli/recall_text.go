@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	ansiYellow = "\x1b[33m"
+	ansiBold   = "\x1b[1m"
+	ansiReset  = "\x1b[0m"
+)
+
+// isTerminal reports whether w is a character device, i.e. an interactive
+// terminal rather than a pipe or file.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled reports whether to colorize output written to w. Color is
+// off for non-terminals, under NO_COLOR, and on dumb terminals.
+func colorEnabled(w io.Writer) bool {
+	return isTerminal(w) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+}
+
+// writeRecallText renders recall results as a git-log style list for
+// people at a terminal. With color, session headers are yellow and query
+// terms in snippets are bold.
+func writeRecallText(w io.Writer, out searchOutput, color bool) {
+	if len(out.Results) == 0 {
+		fmt.Fprintln(w, "no results")
+		return
+	}
+
+	query := out.Query
+	if out.FuzzyQuery != "" {
+		query = out.FuzzyQuery
+		fmt.Fprintf(w, "no exact matches; showing results for %q\n\n", query)
+	}
+	highlight := queryTermPattern(query)
+	for _, r := range out.Results {
+		header := "session " + r.SessionID
+		if color {
+			header = ansiYellow + header + ansiReset
+		}
+		fmt.Fprintf(w, "%s  (score %.3f)\n", header, r.Score)
+		if r.ExpandedFrom != "" {
+			fmt.Fprintf(w, "From:     %s\n", r.ExpandedFrom)
+		}
+		fmt.Fprintf(w, "Author:   %s\n", r.Session.Author)
+		fmt.Fprintf(w, "Branch:   %s\n", r.Session.Branch)
+		fmt.Fprintf(w, "Date:     %s\n", r.Session.CapturedAt)
+		if r.Session.Commit != "" {
+			fmt.Fprintf(w, "Commit:   %s\n", r.Session.Commit)
+		}
+
+		if len(r.Turns) > 0 {
+			fmt.Fprintln(w)
+			for _, t := range r.Turns {
+				fmt.Fprintf(w, "    %s: %s\n", t.Role, strings.Join(strings.Fields(t.Content), " "))
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+
+		if len(r.Matches) > 0 {
+			writeGrepMatches(w, r.Matches, out.Query, color)
+			continue
+		}
+
+		snippet := strings.Join(strings.Fields(r.Snippet), " ")
+		if color && highlight != nil {
+			snippet = highlight.ReplaceAllString(snippet, ansiBold+"$0"+ansiReset)
+		}
+		if snippet != "" {
+			fmt.Fprintf(w, "\n    %s\n", snippet)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeGrepMatches prints each --grep match as a "turn N (role), line L"
+// heading over its context lines. With color, the pattern is bold.
+func writeGrepMatches(w io.Writer, matches []grepMatch, pattern string, color bool) {
+	highlight := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(pattern))
+	for _, m := range matches {
+		fmt.Fprintf(w, "\n    turn %d (%s), line %d:\n", m.TurnIndex, m.Role, m.Line)
+		for _, line := range strings.Split(m.Context, "\n") {
+			if color {
+				line = highlight.ReplaceAllString(line, ansiBold+"$0"+ansiReset)
+			}
+			fmt.Fprintf(w, "      %s\n", line)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// queryTermPattern matches words in a snippet that start with one of the
+// query's words, case-insensitively, so "token" also highlights "tokens".
+// It returns nil when the query has no words.
+func queryTermPattern(query string) *regexp.Regexp {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\w*`)
+}
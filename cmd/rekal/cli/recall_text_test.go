@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteRecallText(t *testing.T) {
+	t.Parallel()
+	out := searchOutput{
+		Query: "JWT token",
+		Mode:  "hybrid",
+		Results: []searchResult{
+			{
+				SessionID: "01JNSESSIONAAAA",
+				Score:     0.8731,
+				Snippet:   "fix the JWT expiry and refresh\n  tokens",
+				Session: sessionDetail{
+					Author:     "alice@example.com",
+					Branch:     "feature/auth",
+					CapturedAt: "2026-02-25 10:00:00",
+					Commit:     "abc123",
+				},
+			},
+			{
+				SessionID:    "01JNSESSIONBBBB",
+				Score:        0,
+				Snippet:      "write the changelog",
+				ExpandedFrom: "01JNSESSIONAAAA",
+				Session: sessionDetail{
+					Author: "bob@example.com",
+					Branch: "main",
+				},
+			},
+		},
+	}
+
+	var plain bytes.Buffer
+	writeRecallText(&plain, out, false)
+	got := plain.String()
+	for _, want := range []string{
+		"session 01JNSESSIONAAAA  (score 0.873)",
+		"session 01JNSESSIONBBBB  (score 0.000)",
+		"From:     01JNSESSIONAAAA",
+		"Author:   alice@example.com",
+		"Branch:   feature/auth",
+		"Commit:   abc123",
+		"    fix the JWT expiry and refresh tokens\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("text output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("uncolored output contains escape codes:\n%s", got)
+	}
+
+	var colored bytes.Buffer
+	writeRecallText(&colored, out, true)
+	got = colored.String()
+	for _, want := range []string{
+		ansiYellow + "session 01JNSESSIONAAAA" + ansiReset,
+		ansiBold + "JWT" + ansiReset,
+		ansiBold + "tokens" + ansiReset,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("colored output missing %q:\n%q", want, got)
+		}
+	}
+	if strings.Contains(got, ansiBold+"expiry") {
+		t.Errorf("non-query term highlighted:\n%q", got)
+	}
+}
+
+func TestWriteRecallText_NoResults(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	writeRecallText(&buf, searchOutput{Query: "nothing"}, false)
+	if buf.String() != "no results\n" {
+		t.Errorf("got %q, want %q", buf.String(), "no results\n")
+	}
+}
+
+func TestWriteRecallText_Fuzzy(t *testing.T) {
+	t.Parallel()
+	out := searchOutput{
+		Query:      "conection",
+		Mode:       "fuzzy",
+		FuzzyQuery: "connection",
+		Results:    []searchResult{{SessionID: "01JNSESSIONAAAA", Snippet: "check the connection pool"}},
+	}
+	var buf bytes.Buffer
+	writeRecallText(&buf, out, true)
+	got := buf.String()
+	if !strings.HasPrefix(got, "no exact matches; showing results for \"connection\"\n") {
+		t.Errorf("missing fuzzy note:\n%q", got)
+	}
+	if !strings.Contains(got, ansiBold+"connection"+ansiReset) {
+		t.Errorf("respelled term not highlighted:\n%q", got)
+	}
+}
+
+func TestWriteRecallText_Grep(t *testing.T) {
+	t.Parallel()
+	out := searchOutput{
+		Query: "ECONNRESET",
+		Mode:  "grep",
+		Results: []searchResult{{
+			SessionID: "01JNSESSIONAAAA",
+			Snippet:   "dial failed\nread: econnreset",
+			Matches:   []grepMatch{{TurnIndex: 2, Role: "human", Line: 2, Context: "dial failed\nread: econnreset"}},
+		}},
+	}
+	var buf bytes.Buffer
+	writeRecallText(&buf, out, true)
+	got := buf.String()
+	for _, want := range []string{
+		"    turn 2 (human), line 2:\n      dial failed\n",
+		"      read: " + ansiBold + "econnreset" + ansiReset + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("grep output missing %q:\n%q", want, got)
+		}
+	}
+}
+
+func TestIsTerminal_NotFile(t *testing.T) {
+	t.Parallel()
+	if isTerminal(&bytes.Buffer{}) {
+		t.Error("bytes.Buffer reported as a terminal")
+	}
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+// defaultRelatedLimit is how many files rekal related lists by default.
+const defaultRelatedLimit = 10
+
+func newRelatedCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "related <file>",
+		Short: "Show the files most often touched together with a file",
+		Long: `Show the files that sessions most often touched together with <file>,
+from the index's file co-occurrence counts: "when you touch auth.go you
+usually also touch jwt.go".
+
+<file> is relative to the current directory, as in git. Files are ranked by
+co-occurrence count, highest first; each count is the number of tool-call
+pairs (reads, edits, searches) on the two files within the same session,
+summed over all sessions. Output is JSON with paths relative to the
+repository root.
+
+The index is built or brought up to date first, as recall does.`,
+		Example: `  rekal related src/auth.go
+  rekal related -n 3 internal/db/schema.go`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			if limit < 1 {
+				return fmt.Errorf("--limit must be at least 1, got %d", limit)
+			}
+			file, err := repoFileArg(gitRoot, args[0])
+			if err != nil {
+				return err
+			}
+			return runRelated(cmd, gitRoot, file, limit)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", defaultRelatedLimit, "Max files to list")
+	return cmd
+}
+
+// repoFileArg resolves a file argument, relative to the working directory
+// unless absolute, to a path relative to gitRoot in slash form.
+func repoFileArg(gitRoot, arg string) (string, error) {
+	p := arg
+	if !filepath.IsAbs(p) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(wd, p)
+	}
+	rel := db.RepoRelativeDir(gitRoot, filepath.Clean(p))
+	if rel == "" || rel == "." {
+		return "", fmt.Errorf("%s is not a file inside the repository", arg)
+	}
+	return rel, nil
+}
+
+// relatedFile is one co-occurring file in rekal related output.
+type relatedFile struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+type relatedOutput struct {
+	File    string        `json:"file"`
+	Related []relatedFile `json:"related"`
+}
+
+func runRelated(cmd *cobra.Command, gitRoot, file string, limit int) error {
+	indexDB, err := openUpdatedIndex(cmd, gitRoot)
+	if err != nil {
+		return err
+	}
+	defer indexDB.Close()
+
+	related, err := queryRelatedFiles(indexDB, file, limit)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(relatedOutput{File: file, Related: related}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal output: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return nil
+}
+
+// queryRelatedFiles returns the files that co-occur with file (repo-relative,
+// slash form), highest count first, at most limit of them. Each pair is
+// stored once with file_a < file_b, so both sides are searched.
+func queryRelatedFiles(indexDB *sql.DB, file string, limit int) ([]relatedFile, error) {
+	rows, err := indexDB.Query(`
+		SELECT other, sum(count) AS n FROM (
+			SELECT file_b AS other, count FROM file_cooccurrence WHERE file_a = $1
+			UNION ALL
+			SELECT file_a AS other, count FROM file_cooccurrence WHERE file_b = $1
+		)
+		GROUP BY other
+		ORDER BY n DESC, other
+		LIMIT $2
+	`, file, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query file_cooccurrence: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	related := []relatedFile{}
+	for rows.Next() {
+		var r relatedFile
+		if err := rows.Scan(&r.File, &r.Count); err != nil {
+			return nil, fmt.Errorf("scan file_cooccurrence: %w", err)
+		}
+		related = append(related, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query file_cooccurrence: %w", err)
+	}
+	return related, nil
+}
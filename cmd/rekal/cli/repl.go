@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newReplCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "repl",
+		Short: "Run recall queries from stdin against an index opened once",
+		Long: `Read recall queries from stdin, one per line, and print each one's results,
+until EOF. The index is opened (and brought up to date) and the LSA model
+loaded once, so each query skips the setup a separate rekal "<query>" pays.
+
+A line is a query, optionally preceded by the same filter flags recall takes:
+
+  --file auth --actor agent token refresh
+  --since 7d --limit 3 -- --dry-run handling
+
+Flags apply to that line only. Flags must come before the query words; "--"
+ends them, so the rest of the line is the query even if it starts with "-".
+Words may be quoted with ' or ". A line that fails prints its error to stderr
+and the loop goes on; "--help" lists the flags. Sessions captured after the
+repl starts are not seen until it is restarted.`,
+		Example: `  rekal repl
+  printf 'JWT expiry\n--actor agent redis cache\n' | rekal repl`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			return runRepl(cmd, gitRoot)
+		},
+	}
+}
+
+// replPrompt is printed to stderr before each line when stdin is a terminal.
+const replPrompt = "rekal> "
+
+func runRepl(cmd *cobra.Command, gitRoot string) error {
+	indexDB, err := openUpdatedIndex(cmd, gitRoot)
+	if err != nil {
+		return err
+	}
+	defer indexDB.Close()
+
+	// Load the team-wide LSA model once. On failure each query loads it
+	// itself, as recall does.
+	lsaIdx, err := loadLSAIndex(indexDB, nil)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: load LSA model: %v\n", err)
+	}
+
+	in := cmd.InOrStdin()
+	interactive := false
+	if f, ok := in.(*os.File); ok {
+		interactive = isTerminal(f)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(cmd.ErrOrStderr(), replPrompt)
+		}
+		if !scanner.Scan() {
+			break
+		}
+		if err := replLine(cmd, gitRoot, indexDB, lsaIdx, scanner.Text()); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "error: %v\n", err)
+		}
+	}
+	if interactive {
+		fmt.Fprintln(cmd.ErrOrStderr())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stdin: %w", err)
+	}
+	return nil
+}
+
+// replLine parses one repl line as leading recall flags plus query words and
+// runs the recall. Blank lines are ignored.
+func replLine(cmd *cobra.Command, gitRoot string, indexDB *sql.DB, lsaIdx *lsaIndex, line string) error {
+	words, err := splitReplLine(line)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	fs := pflag.NewFlagSet("repl", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.SetInterspersed(false)
+	var rf recallFlags
+	rf.register(fs)
+	if err := fs.Parse(words); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			fmt.Fprint(cmd.ErrOrStderr(), fs.FlagUsages())
+			return nil
+		}
+		return err
+	}
+	if len(fs.Args()) == 0 && !rf.hasFilter(fs) {
+		return fmt.Errorf("need a query or a filter")
+	}
+
+	filters, format, err := rf.filters(fs, fs.Args(), cmd.OutOrStdout())
+	if err != nil {
+		return err
+	}
+	filters.LSA = lsaIdx
+	return recallIndex(cmd, gitRoot, indexDB, filters, format)
+}
+
+// splitReplLine splits a repl line into words at unquoted whitespace.
+// Single or double quotes group words and are removed; there are no escapes.
+func splitReplLine(line string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
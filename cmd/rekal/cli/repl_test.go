@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitReplLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"JWT expiry", []string{"JWT", "expiry"}},
+		{"  --file auth\ttoken  ", []string{"--file", "auth", "token"}},
+		{`--author "bob@example.com" 'token refresh'`, []string{"--author", "bob@example.com", "token refresh"}},
+		{`say "it's"`, []string{"say", "it's"}},
+		{`--file ""`, []string{"--file", ""}},
+		{`a"b c"d`, []string{"ab cd"}},
+	}
+	for _, tt := range tests {
+		got, err := splitReplLine(tt.line)
+		if err != nil {
+			t.Errorf("splitReplLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitReplLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	if _, err := splitReplLine(`"unterminated`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
@@ -2,11 +2,15 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/versioncheck"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const gettingStarted = `
@@ -28,12 +32,8 @@ Getting Started:
 // NewRootCmd returns the root command for the rekal CLI.
 func NewRootCmd() *cobra.Command {
 	var (
-		fileFilter       string
-		commitFilter     string
-		checkpointFilter string
-		authorFilter     string
-		actorFilter      string
-		limitFlag        int
+		rf           recallFlags
+		versionCheck *versioncheck.Check
 	)
 
 	cmd := &cobra.Command{
@@ -46,13 +46,19 @@ func NewRootCmd() *cobra.Command {
 		CompletionOptions: cobra.CompletionOptions{
 			HiddenDefaultCmd: true,
 		},
+		// The version check runs alongside the command; its notice, if any,
+		// follows the command's output.
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			if !isQuiet(cmd) {
+				versionCheck = versioncheck.Start(Version)
+			}
+		},
 		PersistentPostRun: func(cmd *cobra.Command, _ []string) {
-			versioncheck.CheckAndNotify(cmd.OutOrStdout(), Version)
+			versionCheck.Notify(cmd.OutOrStdout(), versionCheckGrace)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// If no args and no filters, show help.
-			if len(args) == 0 && fileFilter == "" && commitFilter == "" &&
-				checkpointFilter == "" && authorFilter == "" && actorFilter == "" {
+			if len(args) == 0 && !rf.hasFilter(cmd.Flags()) {
 				return cmd.Help()
 			}
 
@@ -67,28 +73,27 @@ func NewRootCmd() *cobra.Command {
 				return NewSilentError(err)
 			}
 
-			filters := RecallFilters{
-				Query:  strings.Join(args, " "),
-				File:   fileFilter,
-				Commit: commitFilter,
-				Author: authorFilter,
-				Actor:  actorFilter,
-				Limit:  limitFlag,
+			filters, format, err := rf.filters(cmd.Flags(), args, cmd.OutOrStdout())
+			if err != nil {
+				return err
 			}
-
-			_ = checkpointFilter // reserved for future use
-
-			return runRecall(cmd, gitRoot, filters)
+			return runRecall(cmd, gitRoot, filters, format)
 		},
 	}
 
+	cmd.PersistentFlags().Bool("quiet", false, "Only print warnings and errors (also set by "+quietEnv+"=1)")
+
 	// Recall filter flags on root command.
-	cmd.Flags().StringVar(&fileFilter, "file", "", "Filter by file path (regex)")
-	cmd.Flags().StringVar(&commitFilter, "commit", "", "Filter by git commit SHA")
-	cmd.Flags().StringVar(&checkpointFilter, "checkpoint", "", "Query as of checkpoint ref")
-	cmd.Flags().StringVar(&authorFilter, "author", "", "Filter by author email")
-	cmd.Flags().StringVar(&actorFilter, "actor", "", "Filter by actor type (human|agent)")
-	cmd.Flags().IntVarP(&limitFlag, "limit", "n", 0, "Max results (0 = no limit)")
+	rf.register(cmd.Flags())
+
+	_ = cmd.RegisterFlagCompletionFunc("checkpoint", completeFromData("checkpoints", "git_sha"))
+	_ = cmd.RegisterFlagCompletionFunc("commit", completeFromData("checkpoints", "git_sha"))
+	_ = cmd.RegisterFlagCompletionFunc("tag", completeFromData("session_tags", "tag"))
+	_ = cmd.RegisterFlagCompletionFunc("dir", completeFromData("sessions", "cwd"))
+	_ = cmd.RegisterFlagCompletionFunc("author", completeFromData("sessions", "user_email"))
+	_ = cmd.RegisterFlagCompletionFunc("actor", cobra.FixedCompletions([]string{"human", "agent"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"json", "text"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("scope", cobra.FixedCompletions([]string{"self", "team"}, cobra.ShellCompDirectiveNoFileComp))
 
 	cmd.SetVersionTemplate("rekal {{.Version}}\n")
 	cmd.Version = Version
@@ -105,6 +110,8 @@ func NewRootCmd() *cobra.Command {
 	cleanCmd.GroupID = "core"
 	versionCmd := newVersionCmd()
 	versionCmd.GroupID = "core"
+	completionsCmd := newCompletionsCmd()
+	completionsCmd.GroupID = "core"
 
 	checkpointCmd := newCheckpointCmd()
 	checkpointCmd.GroupID = "workflow"
@@ -114,19 +121,208 @@ func NewRootCmd() *cobra.Command {
 	syncCmd.GroupID = "workflow"
 	logCmd := newLogCmd()
 	logCmd.GroupID = "workflow"
+	relatedCmd := newRelatedCmd()
+	relatedCmd.GroupID = "workflow"
+	replCmd := newReplCmd()
+	replCmd.GroupID = "workflow"
+	tagCmd := newTagCmd()
+	tagCmd.GroupID = "workflow"
+	untagCmd := newUntagCmd()
+	untagCmd.GroupID = "workflow"
 
 	queryCmd := newQueryCmd()
 	queryCmd.GroupID = "advanced"
 	indexCmd := newIndexCmd()
 	indexCmd.GroupID = "advanced"
+	exportCmd := newExportCmd()
+	exportCmd.GroupID = "advanced"
+	verifyCmd := newVerifyCmd()
+	verifyCmd.GroupID = "advanced"
+	pruneCmd := newPruneCmd()
+	pruneCmd.GroupID = "advanced"
+	codecCmd := newCodecCmd()
+	codecCmd.GroupID = "advanced"
 
-	cmd.AddCommand(initCmd, cleanCmd, versionCmd)
-	cmd.AddCommand(checkpointCmd, pushCmd, syncCmd, logCmd)
-	cmd.AddCommand(queryCmd, indexCmd)
+	cmd.AddCommand(initCmd, cleanCmd, versionCmd, completionsCmd)
+	cmd.AddCommand(checkpointCmd, pushCmd, syncCmd, logCmd, relatedCmd, replCmd, tagCmd, untagCmd)
+	cmd.AddCommand(queryCmd, indexCmd, exportCmd, verifyCmd, pruneCmd, codecCmd)
+	cmd.AddCommand(newGenDocsCmd())
 
 	return cmd
 }
 
+// recallFlags holds the recall filter flags. The root command and each
+// rekal repl line parse them the same way.
+type recallFlags struct {
+	file         string
+	commit       string
+	checkpoint   string
+	tag          string
+	dir          string
+	author       string
+	actor        string
+	since        string
+	until        string
+	limit        int
+	offset       int
+	snippetLen   int
+	expandCommit bool
+	bm25Weight   float64
+	lsaWeight    float64
+	scope        string
+	noBM25       bool
+	noLSA        bool
+	fuzzy        bool
+	semantic     bool
+	grep         bool
+	outputTurns  int
+	format       string
+}
+
+// register defines the recall flags on fs, bound to rf.
+func (rf *recallFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&rf.file, "file", "", "Filter by file path (regex)")
+	fs.StringVar(&rf.commit, "commit", "", "Filter by git commit SHA")
+	fs.StringVar(&rf.checkpoint, "checkpoint", "", "Only sessions linked to a checkpoint at this git SHA (prefix)")
+	fs.StringVar(&rf.tag, "tag", "", "Only sessions tagged with this tag (see rekal tag)")
+	fs.StringVar(&rf.dir, "dir", "", "Only sessions started in this directory or below (git-root-relative)")
+	fs.StringVar(&rf.author, "author", "", "Filter by author email (any alias in .rekal/aliases)")
+	fs.StringVar(&rf.actor, "actor", "", "Filter by actor type (human|agent)")
+	fs.StringVar(&rf.scope, "scope", "team", "Search your own sessions (self) or everyone's (team)")
+	fs.StringVar(&rf.since, "since", "", "Only sessions captured at or after this time (RFC3339 or relative, e.g. 7d, 24h)")
+	fs.StringVar(&rf.until, "until", "", "Only sessions captured at or before this time (RFC3339 or relative, e.g. 7d, 24h)")
+	fs.IntVarP(&rf.limit, "limit", "n", defaultLimit, "Max results (0 = no limit)")
+	fs.IntVar(&rf.offset, "offset", 0, "Skip the first N results, to page through matches with --limit")
+	fs.IntVar(&rf.snippetLen, "snippet-len", defaultSnippetSize, fmt.Sprintf("Snippet length in bytes, at least %d (0 = no snippets)", minSnippetSize))
+	fs.BoolVar(&rf.expandCommit, "expand-commit", false, "Also return other sessions from the same checkpoint as each result")
+	fs.Float64Var(&rf.bm25Weight, "bm25-weight", bm25Weight2Way, "Weight of BM25 keyword scores in the hybrid ranking, 0-1")
+	fs.Float64Var(&rf.lsaWeight, "lsa-weight", lsaWeight2Way, "Weight of LSA semantic scores in the hybrid ranking, 0-1")
+	fs.StringVar(&rf.format, "format", "", "Output format: json or text (default: text on a terminal, json otherwise)")
+	fs.BoolVar(&rf.noBM25, "no-bm25", false, "Leave BM25 keyword scores out of the ranking for this query")
+	fs.BoolVar(&rf.noLSA, "no-lsa", false, "Leave LSA and nomic semantic scores out of the ranking for this query")
+	fs.BoolVar(&rf.fuzzy, "fuzzy", false, "If nothing matches, retry with misspelled words replaced by close matches")
+	fs.BoolVar(&rf.semantic, "semantic", false, "Rank by LSA and nomic similarity only, skipping BM25 keyword matching")
+	fs.BoolVar(&rf.grep, "grep", false, "Match the query as a literal, case-insensitive substring of turn content, skipping ranking")
+	fs.IntVar(&rf.outputTurns, "output-turns", 0, fmt.Sprintf("Include the full turns of the top k results (at most %d)", maxOutputTurns))
+}
+
+// hasFilter reports whether any filter flag that selects sessions by itself,
+// without a query, was given.
+func (rf *recallFlags) hasFilter(fs *pflag.FlagSet) bool {
+	return rf.file != "" || rf.commit != "" || rf.checkpoint != "" || rf.tag != "" ||
+		rf.dir != "" || rf.author != "" || rf.actor != "" || rf.since != "" || rf.until != "" ||
+		fs.Changed("scope")
+}
+
+// filters validates the parsed flags and turns them and the query words in
+// args into RecallFilters and an output format, "json" or "text". Without
+// --format, the format is text when out is a terminal and json otherwise.
+func (rf *recallFlags) filters(fs *pflag.FlagSet, args []string, out io.Writer) (RecallFilters, string, error) {
+	filters := RecallFilters{
+		Query:        strings.Join(args, " "),
+		File:         rf.file,
+		Commit:       rf.commit,
+		Checkpoint:   rf.checkpoint,
+		Tag:          rf.tag,
+		Author:       rf.author,
+		Actor:        rf.actor,
+		Limit:        rf.limit,
+		Offset:       rf.offset,
+		SnippetLen:   rf.snippetLen,
+		ExpandCommit: rf.expandCommit,
+		NoBM25:       rf.noBM25,
+		NoLSA:        rf.noLSA,
+		Fuzzy:        rf.fuzzy,
+		Semantic:     rf.semantic,
+		Grep:         rf.grep,
+		OutputTurns:  rf.outputTurns,
+	}
+	var err error
+	if rf.semantic {
+		if filters.Query == "" {
+			return filters, "", fmt.Errorf("--semantic needs a query")
+		}
+		if fs.Changed("bm25-weight") || fs.Changed("lsa-weight") {
+			return filters, "", fmt.Errorf("--semantic cannot be combined with --bm25-weight or --lsa-weight")
+		}
+		if rf.noLSA {
+			return filters, "", fmt.Errorf("--semantic cannot be combined with --no-lsa")
+		}
+	}
+	if rf.offset < 0 {
+		return filters, "", fmt.Errorf("--offset must not be negative, got %d", rf.offset)
+	}
+	if rf.limit < 0 {
+		return filters, "", fmt.Errorf("--limit must not be negative, got %d", rf.limit)
+	}
+	if rf.snippetLen != 0 && rf.snippetLen < minSnippetSize {
+		return filters, "", fmt.Errorf("--snippet-len must be 0 or at least %d, got %d", minSnippetSize, rf.snippetLen)
+	}
+	if rf.grep {
+		if filters.Query == "" {
+			return filters, "", fmt.Errorf("--grep needs a pattern")
+		}
+		if rf.semantic || rf.fuzzy {
+			return filters, "", fmt.Errorf("--grep cannot be combined with --semantic or --fuzzy")
+		}
+		if !fs.Changed("snippet-len") {
+			filters.SnippetLen = wholeSnippet
+		}
+	}
+	if rf.outputTurns < 0 || rf.outputTurns > maxOutputTurns {
+		return filters, "", fmt.Errorf("--output-turns must be between 0 and %d, got %d", maxOutputTurns, rf.outputTurns)
+	}
+	now := time.Now()
+	if rf.since != "" {
+		if filters.Since, err = parseTimeBound(rf.since, now); err != nil {
+			return filters, "", fmt.Errorf("--since: %w", err)
+		}
+	}
+	if rf.until != "" {
+		if filters.Until, err = parseTimeBound(rf.until, now); err != nil {
+			return filters, "", fmt.Errorf("--until: %w", err)
+		}
+	}
+
+	if rf.dir != "" {
+		if filters.Dir, err = normalizeRepoDir(rf.dir); err != nil {
+			return filters, "", err
+		}
+	}
+
+	if fs.Changed("bm25-weight") || fs.Changed("lsa-weight") {
+		if filters.Weights, err = newBlendWeights(rf.bm25Weight, rf.lsaWeight); err != nil {
+			return filters, "", err
+		}
+	}
+
+	if rf.noBM25 && rf.noLSA {
+		return filters, "", fmt.Errorf("--no-bm25 and --no-lsa cannot both be set")
+	}
+
+	switch rf.scope {
+	case "team":
+	case "self":
+		if filters.ScopeEmail = gitConfigValue("user.email"); filters.ScopeEmail == "" {
+			return filters, "", fmt.Errorf("--scope self: git user.email is not set")
+		}
+	default:
+		return filters, "", fmt.Errorf("--scope must be self or team, got %q", rf.scope)
+	}
+
+	format := rf.format
+	if !fs.Changed("format") {
+		format = "json"
+		if isTerminal(out) {
+			format = "text"
+		}
+	}
+	if format != "json" && format != "text" {
+		return filters, "", fmt.Errorf("--format must be json or text, got %q", format)
+	}
+	return filters, format, nil
+}
+
 func newVersionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -138,6 +334,23 @@ func newVersionCmd() *cobra.Command {
 	}
 }
 
+// versionCheckGrace is how long a command waits, after its own output, for
+// a version check that has not finished yet.
+const versionCheckGrace = 100 * time.Millisecond
+
+// quietEnv is the environment variable that turns on --quiet.
+const quietEnv = "REKAL_QUIET"
+
+// isQuiet reports whether informational messages should be suppressed:
+// --quiet was given or REKAL_QUIET is set to a true value.
+func isQuiet(cmd *cobra.Command) bool {
+	if q, err := cmd.Flags().GetBool("quiet"); err == nil && q {
+		return true
+	}
+	q, err := strconv.ParseBool(os.Getenv(quietEnv))
+	return err == nil && q
+}
+
 // Run executes the root command and exits with the appropriate code.
 func Run() {
 	rootCmd := NewRootCmd()
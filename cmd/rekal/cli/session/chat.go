@@ -0,0 +1,114 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// chatTranscript is a generic OpenAI-style chat transcript: a messages
+// array as sent to a chat completions API, optionally wrapped in an object
+// carrying the session's metadata.
+type chatTranscript struct {
+	ID       string        `json:"id"`
+	CWD      string        `json:"cwd"`
+	Branch   string        `json:"git_branch"`
+	Messages []chatMessage `json:"messages"`
+}
+
+// chatMessage is one message of a chatTranscript. Content is a string, an
+// array of {"type":"text","text":...} parts, or null on an assistant
+// message that only calls tools.
+type chatMessage struct {
+	Role      string          `json:"role"` // "system" | "user" | "assistant" | "tool"
+	Content   json.RawMessage `json:"content"`
+	ToolCalls []chatToolCall  `json:"tool_calls"`
+	Timestamp string          `json:"timestamp"` // optional, RFC 3339
+}
+
+// chatToolCall is a function call requested by an assistant message. Its
+// arguments are a JSON object encoded as a string.
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatParser reads generic OpenAI-style chat transcripts.
+type chatParser struct{}
+
+func (chatParser) Name() string { return FormatChat }
+func (chatParser) Ext() string  { return ".json" }
+
+// Detect reports whether data is one JSON document holding chat messages
+// with roles.
+func (chatParser) Detect(data []byte) bool {
+	t, err := decodeChatTranscript(data)
+	return err == nil && len(t.Messages) > 0 && t.Messages[0].Role != ""
+}
+
+func (chatParser) Parse(data []byte, opts ParseOptions) (*SessionPayload, error) {
+	return ParseChatTranscript(data, opts)
+}
+
+// SessionDir returns "": chat transcripts have no standard location, so
+// they are read with checkpoint --session-dir or --file.
+func (chatParser) SessionDir(string) string { return "" }
+
+// decodeChatTranscript accepts a chatTranscript object or a bare messages
+// array.
+func decodeChatTranscript(data []byte) (*chatTranscript, error) {
+	var t chatTranscript
+	if err := json.Unmarshal(data, &t); err == nil {
+		return &t, nil
+	}
+	if err := json.Unmarshal(data, &t.Messages); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ParseChatTranscript parses a generic OpenAI-style chat transcript into a
+// SessionPayload with the same turns and tool calls ParseTranscript gives
+// for a Claude Code session: user messages become "human" turns, assistant
+// text "assistant" turns, and each tool call a ToolCall whose path and
+// command come from the arguments' file_path or path and command. System
+// and tool messages are discarded.
+func ParseChatTranscript(data []byte, opts ParseOptions) (*SessionPayload, error) {
+	t, err := decodeChatTranscript(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse chat transcript: %w", err)
+	}
+
+	payload := &SessionPayload{
+		SessionID: t.ID,
+		Branch:    t.Branch,
+		CWD:       t.CWD,
+		ActorType: "human",
+	}
+	for _, m := range t.Messages {
+		ts := parseTimestamp(m.Timestamp)
+		switch m.Role {
+		case "user":
+			if text := extractTextContent(m.Content); text != "" {
+				payload.Turns = append(payload.Turns, Turn{Role: "human", Content: text, Timestamp: ts})
+			}
+		case "assistant":
+			if text := extractTextContent(m.Content); text != "" {
+				payload.Turns = append(payload.Turns, Turn{Role: "assistant", Content: text, Timestamp: ts})
+			}
+			for _, c := range m.ToolCalls {
+				b := contentBlock{Name: c.Function.Name, ID: c.ID}
+				if json.Valid([]byte(c.Function.Arguments)) {
+					b.Input = json.RawMessage(c.Function.Arguments)
+				}
+				payload.ToolCalls = append(payload.ToolCalls, extractToolCall(b, opts.cmdPrefixLen()))
+			}
+		}
+	}
+
+	payload.CapturedAt = time.Now().UTC()
+	return payload, nil
+}
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fixtureChat is fixtureJSONL's main session as an OpenAI-style chat
+// transcript.
+const fixtureChat = `{
+  "id": "sess-001",
+  "cwd": "/tmp/repo",
+  "git_branch": "main",
+  "messages": [
+    {"role": "system", "content": "You are a coding agent."},
+    {"role": "user", "content": "Add a login page", "timestamp": "2025-01-15T10:00:00Z"},
+    {"role": "assistant", "content": "I'll create a login page for you.", "timestamp": "2025-01-15T10:00:05Z",
+     "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "Write", "arguments": "{\"file_path\":\"src/login.tsx\",\"content\":\"export default function Login() { return <div>Login</div> }\"}"}}]},
+    {"role": "tool", "tool_call_id": "call_1", "content": "File written"},
+    {"role": "assistant", "content": [{"type": "text", "text": "Done. The login page is at src/login.tsx."}], "timestamp": "2025-01-15T10:00:15Z",
+     "tool_calls": [{"id": "call_2", "type": "function", "function": {"name": "Bash", "arguments": "{\"command\":\"cd /tmp/repo && npm run build && echo done\"}"}}]}
+  ]
+}`
+
+func TestParseChatTranscript_MatchesClaude(t *testing.T) {
+	t.Parallel()
+
+	want, err := ParseTranscript([]byte(fixtureJSONL), ParseOptions{CmdPrefixLen: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseChatTranscript([]byte(fixtureChat), ParseOptions{CmdPrefixLen: 20})
+	if err != nil {
+		t.Fatalf("ParseChatTranscript: %v", err)
+	}
+
+	if got.SessionID != want.SessionID || got.Branch != want.Branch || got.CWD != want.CWD || got.ActorType != want.ActorType {
+		t.Errorf("metadata = %q %q %q %q, want %q %q %q %q",
+			got.SessionID, got.Branch, got.CWD, got.ActorType, want.SessionID, want.Branch, want.CWD, want.ActorType)
+	}
+	if !reflect.DeepEqual(got.Turns, want.Turns) {
+		t.Errorf("turns:\n got %+v\nwant %+v", got.Turns, want.Turns)
+	}
+	for i := range got.ToolCalls {
+		got.ToolCalls[i].useID = ""
+	}
+	if !reflect.DeepEqual(got.ToolCalls, want.ToolCalls) {
+		t.Errorf("tool calls:\n got %+v\nwant %+v", got.ToolCalls, want.ToolCalls)
+	}
+}
+
+func TestParseChatTranscript_BareArray(t *testing.T) {
+	t.Parallel()
+
+	data := `[
+  {"role": "user", "content": "why does the build fail?"},
+  {"role": "assistant", "content": null, "tool_calls": [{"id": "c1", "function": {"name": "mcp__github__get_issue", "arguments": "{\"path\":\"issues/7\"}"}}]},
+  {"role": "assistant", "content": "The lockfile is stale."}
+]`
+	payload, err := ParseChatTranscript([]byte(data), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseChatTranscript: %v", err)
+	}
+	if len(payload.Turns) != 2 || payload.Turns[0].Role != "human" || payload.Turns[1].Content != "The lockfile is stale." {
+		t.Errorf("turns = %+v", payload.Turns)
+	}
+	if len(payload.ToolCalls) != 1 {
+		t.Fatalf("tool calls = %+v, want 1", payload.ToolCalls)
+	}
+	tc := payload.ToolCalls[0]
+	if tc.Server != "github" || tc.Tool != "get_issue" || tc.Path != "issues/7" {
+		t.Errorf("tool call = %+v, want github/get_issue on issues/7", tc)
+	}
+
+	if _, err := ParseChatTranscript([]byte(fixtureJSONL), ParseOptions{}); err == nil {
+		t.Error("expected an error for a JSONL transcript")
+	}
+}
+
+func TestDetectParser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"claude jsonl", fixtureJSONL, FormatClaude},
+		{"chat object", fixtureChat, FormatChat},
+		{"chat array", `[{"role":"user","content":"hi"}]`, FormatChat},
+		{"one claude line", `{"type":"summary","sessionId":"s"}`, FormatClaude},
+		{"unknown falls back to claude", `not json`, FormatClaude},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := DetectParser([]byte(tt.data)).Name(); got != tt.want {
+				t.Errorf("DetectParser = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if ParserFor(FormatChat) == nil || ParserFor(FormatAuto) != nil || ParserFor("gemini") != nil {
+		t.Error("ParserFor: want a parser for openai-chat only")
+	}
+}
@@ -1,10 +1,11 @@
 package session
 
 import (
+	"bufio"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
 )
 
 var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]`)
@@ -16,20 +17,128 @@ func SanitizeRepoPath(repoPath string) string {
 	return nonAlphanumeric.ReplaceAllString(repoPath, "-")
 }
 
-// FindSessionDir returns the Claude Code session directory for the given repo path.
-// Returns ~/.claude/projects/<sanitized-repo-path>/.
+// FindSessionDir returns the Claude Code session directory for the given repo path:
+// <config>/projects/<sanitized-repo-path>/. The config directory is the first
+// of $CLAUDE_CONFIG_DIR, $XDG_CONFIG_HOME/claude (default ~/.config/claude)
+// and ~/.claude that has a session directory for the repo. When none does,
+// it is $CLAUDE_CONFIG_DIR if set, else ~/.claude.
 func FindSessionDir(repoPath string) string {
-	home, err := os.UserHomeDir()
+	sanitized := SanitizeRepoPath(repoPath)
+	for _, root := range configRoots() {
+		dir := filepath.Join(root, "projects", sanitized)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	if envDir := os.Getenv("CLAUDE_CONFIG_DIR"); envDir != "" {
+		return filepath.Join(envDir, "projects", sanitized)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".claude", "projects", sanitized)
+	}
+	return ""
+}
+
+// configRoots returns the Claude Code config directories to search, in
+// order: $CLAUDE_CONFIG_DIR, $XDG_CONFIG_HOME/claude (default
+// ~/.config/claude) and ~/.claude.
+func configRoots() []string {
+	home, homeErr := os.UserHomeDir()
+
+	var roots []string
+	if envDir := os.Getenv("CLAUDE_CONFIG_DIR"); envDir != "" {
+		roots = append(roots, envDir)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		roots = append(roots, filepath.Join(xdg, "claude"))
+	} else if homeErr == nil {
+		roots = append(roots, filepath.Join(home, ".config", "claude"))
+	}
+	if homeErr == nil {
+		roots = append(roots, filepath.Join(home, ".claude"))
+	}
+	return roots
+}
+
+// FindSessionDirByCWD scans every <config>/projects/ directory for one whose
+// transcripts were recorded in repoPath, for repos whose session directory
+// name no longer matches SanitizeRepoPath (opened through a symlink,
+// renamed, or named differently by Claude Code). Paths are compared after
+// resolving symlinks. It returns "" if no directory matches.
+func FindSessionDirByCWD(repoPath string) string {
+	want := resolvePath(repoPath)
+	for _, root := range configRoots() {
+		entries, err := os.ReadDir(filepath.Join(root, "projects"))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			dir := filepath.Join(root, "projects", e.Name())
+			files, err := FindSessionFiles(dir)
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				if cwd := transcriptCWD(f); cwd != "" {
+					if resolvePath(cwd) == want {
+						return dir
+					}
+					break // one transcript is enough to tell the directory's cwd
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// transcriptCWDMaxLines bounds how far transcriptCWD reads into a file.
+// Claude Code records cwd on every message line, so it shows up early.
+const transcriptCWDMaxLines = 20
+
+// transcriptCWD returns the first cwd recorded in a transcript, or "".
+func transcriptCWD(path string) string {
+	f, err := os.Open(path)
 	if err != nil {
 		return ""
 	}
-	sanitized := SanitizeRepoPath(repoPath)
-	return filepath.Join(home, ".claude", "projects", sanitized)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for i := 0; i < transcriptCWDMaxLines; i++ {
+		line, err := r.ReadBytes('\n')
+		var raw struct {
+			CWD string `json:"cwd"`
+		}
+		if json.Unmarshal(line, &raw) == nil && raw.CWD != "" {
+			return raw.CWD
+		}
+		if err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// resolvePath returns path cleaned and with symlinks resolved when it exists.
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
 }
 
 // FindSessionFiles lists all .jsonl session files in the given directory.
 func FindSessionFiles(sessionDir string) ([]string, error) {
-	entries, err := os.ReadDir(sessionDir)
+	return FindTranscripts(sessionDir, claudeParser{})
+}
+
+// FindTranscripts lists the transcripts in dir with p's extension, or with
+// any known format's extension when p is nil.
+func FindTranscripts(dir string, p Parser) ([]string, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +148,8 @@ func FindSessionFiles(sessionDir string) ([]string, error) {
 		if e.IsDir() {
 			continue
 		}
-		if strings.HasSuffix(e.Name(), ".jsonl") {
-			files = append(files, filepath.Join(sessionDir, e.Name()))
+		if hasExt(e.Name(), p) {
+			files = append(files, filepath.Join(dir, e.Name()))
 		}
 	}
 	return files, nil
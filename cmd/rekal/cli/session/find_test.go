@@ -0,0 +1,100 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mkSessionDir creates <root>/projects/<sanitized repo> and returns it.
+func mkSessionDir(t *testing.T, root, repo string) string {
+	t.Helper()
+	dir := filepath.Join(root, "projects", SanitizeRepoPath(repo))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFindSessionDir_ClaudeConfigDir(t *testing.T) {
+	home, config := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CLAUDE_CONFIG_DIR", config)
+	repo := "/work/rekal"
+
+	// Not created yet: CLAUDE_CONFIG_DIR still wins over the default.
+	want := filepath.Join(config, "projects", "-work-rekal")
+	if got := FindSessionDir(repo); got != want {
+		t.Errorf("FindSessionDir = %q, want %q", got, want)
+	}
+
+	mkSessionDir(t, filepath.Join(home, ".claude"), repo)
+	want = mkSessionDir(t, config, repo)
+	if got := FindSessionDir(repo); got != want {
+		t.Errorf("FindSessionDir = %q, want %q", got, want)
+	}
+}
+
+func TestFindSessionDir_XDGConfigHome(t *testing.T) {
+	home, xdg := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv("CLAUDE_CONFIG_DIR", filepath.Join(t.TempDir(), "missing"))
+	repo := "/work/rekal"
+
+	mkSessionDir(t, filepath.Join(home, ".claude"), repo)
+	want := mkSessionDir(t, filepath.Join(xdg, "claude"), repo)
+	if got := FindSessionDir(repo); got != want {
+		t.Errorf("FindSessionDir = %q, want %q", got, want)
+	}
+}
+
+func TestFindSessionDir_Default(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+
+	want := filepath.Join(home, ".claude", "projects", "-work-rekal")
+	if got := FindSessionDir("/work/rekal"); got != want {
+		t.Errorf("FindSessionDir = %q, want %q", got, want)
+	}
+}
+
+func TestFindSessionDirByCWD(t *testing.T) {
+	home, config := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CLAUDE_CONFIG_DIR", config)
+
+	// The repo is opened through a symlink, so Claude Code named the
+	// session directory after the link, not the real path.
+	repo := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(repo, link); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTranscript := func(dir, cwd string) {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		data := `{"type":"summary","summary":"s"}` + "\n" +
+			`{"type":"user","cwd":"` + cwd + `","message":{"role":"user","content":"hi"}}` + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "s.jsonl"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTranscript(filepath.Join(config, "projects", "-elsewhere"), "/elsewhere")
+	want := filepath.Join(config, "projects", SanitizeRepoPath(link))
+	writeTranscript(want, link)
+
+	if got := FindSessionDirByCWD(repo); got != want {
+		t.Errorf("FindSessionDirByCWD = %q, want %q", got, want)
+	}
+	if got := FindSessionDirByCWD(filepath.Join(repo, "missing")); got != "" {
+		t.Errorf("FindSessionDirByCWD for an unknown repo = %q, want empty", got)
+	}
+}
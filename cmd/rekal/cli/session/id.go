@@ -0,0 +1,35 @@
+package session
+
+import (
+	"crypto/sha256"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// ContentID derives a deterministic session ID from the SHA-256 of the
+// normalized conversation content: turn roles and text, then tool calls, in
+// order. Transcript metadata (uuids, cwd, timestamps) is excluded, so the
+// same conversation yields the same ID on every machine.
+//
+// The ID is ULID-shaped (26 characters, Crockford base32) so it fits the
+// fixed-width session entries in dict.bin, but it carries no timestamp.
+func ContentID(p *SessionPayload) string {
+	// NUL separators keep field boundaries unambiguous.
+	var b strings.Builder
+	for _, t := range p.Turns {
+		b.WriteString("turn\x00" + t.Role + "\x00" + t.Content + "\x00")
+	}
+	for _, tc := range p.ToolCalls {
+		name := tc.Tool
+		if tc.Server != "" {
+			name = MCPToolName(tc.Server, tc.Tool)
+		}
+		b.WriteString("tool\x00" + name + "\x00" + tc.Path + "\x00" + tc.CmdPrefix + "\x00")
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+
+	var id ulid.ULID
+	copy(id[:], sum[:len(id)])
+	return id.String()
+}
@@ -7,33 +7,62 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // SessionPayload is the parsed, filtered representation of a Claude Code session.
 type SessionPayload struct {
-	SessionID  string     `json:"session_id"`
-	Turns      []Turn     `json:"turns"`
-	ToolCalls  []ToolCall `json:"tool_calls"`
-	Branch     string     `json:"branch"`
-	CapturedAt time.Time  `json:"captured_at"`
-	ActorType  string     `json:"actor_type"` // "human" | "agent"
-	AgentID    string     `json:"agent_id"`   // empty for human
+	SessionID       string     `json:"session_id"`
+	ParentSessionID string     `json:"parent_session_id"` // session this one resumes; empty for a fresh one
+	Turns           []Turn     `json:"turns"`
+	ToolCalls       []ToolCall `json:"tool_calls"`
+	Branch          string     `json:"branch"`
+	CWD             string     `json:"cwd"` // absolute working directory of the first line that has one
+	CapturedAt      time.Time  `json:"captured_at"`
+	ActorType       string     `json:"actor_type"` // "human" | "agent"
+	AgentID         string     `json:"agent_id"`   // empty for human
+
+	// From the transcript's summary line; zero when there is none.
+	TotalCost       float64 `json:"total_cost"`        // USD
+	TotalDurationMs int64   `json:"total_duration_ms"` // wall-clock session time
 }
 
-// Turn represents a single conversation turn (human prompt or assistant reply).
+// Turn represents a single conversation turn (human prompt, assistant reply,
+// or assistant thinking when ParseOptions.IncludeThinking is set).
 type Turn struct {
-	Role      string    `json:"role"` // "human" | "assistant"
+	Role      string    `json:"role"` // "human" | "assistant" | "thinking"
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
 // ToolCall represents a tool invocation extracted from assistant content.
 type ToolCall struct {
-	Tool      string `json:"tool"`       // Write, Edit, Read, Bash, etc.
-	Path      string `json:"path"`       // file path if applicable
-	CmdPrefix string `json:"cmd_prefix"` // first 100 chars of bash command if applicable
+	Tool         string `json:"tool"`          // Write, Edit, Read, Bash, etc.; the tool name alone for MCP tools
+	Server       string `json:"server"`        // MCP server name; empty for built-in tools
+	Path         string `json:"path"`          // file path if applicable
+	CmdPrefix    string `json:"cmd_prefix"`    // start of the bash command, up to ParseOptions.CmdPrefixLen bytes
+	Failed       bool   `json:"failed"`        // tool_result reported is_error
+	ErrorSnippet string `json:"error_snippet"` // first 200 chars of the error result, if Failed
+
+	useID string // tool_use block ID, matched against tool_result
 }
 
+// maxErrorSnippet is the length an error tool_result is truncated to.
+const maxErrorSnippet = 200
+
+// DefaultMaxToolResultBytes is the largest tool_result text kept when a
+// tool result is captured (plan file reads). Larger results are usually
+// minified bundles or encoded blobs that bloat the DB without being
+// searchable.
+const DefaultMaxToolResultBytes = 64 << 10
+
+// DefaultCmdPrefixLen is how many bytes of a tool's command input are kept
+// as ToolCall.CmdPrefix.
+const DefaultCmdPrefixLen = 100
+
+// mcpToolPrefix marks tool_use names that belong to an MCP server tool.
+const mcpToolPrefix = "mcp__"
+
 // rawLine is the top-level structure of a JSONL line from a Claude Code session.
 type rawLine struct {
 	UUID      string          `json:"uuid"`
@@ -44,8 +73,15 @@ type rawLine struct {
 	CWD       string          `json:"cwd"`
 	GitBranch string          `json:"gitBranch"`
 
-	// isSidechain lines are filtered out
-	IsSidechain bool `json:"isSidechain"`
+	// isSidechain lines are filtered out of main sessions. A transcript
+	// whose messages start on a sidechain belongs to a subagent (e.g. one
+	// started by the Task tool); agentId names it.
+	IsSidechain bool   `json:"isSidechain"`
+	AgentID     string `json:"agentId"`
+
+	// Set on "summary" lines only.
+	TotalCost     float64 `json:"totalCost"`
+	TotalDuration int64   `json:"totalDuration"`
 }
 
 // rawMessage is the message field within a JSONL line.
@@ -59,11 +95,13 @@ type rawMessage struct {
 type contentBlock struct {
 	Type      string          `json:"type"`
 	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"` // thinking block text
 	Name      string          `json:"name"`
 	ID        string          `json:"id"`          // tool_use block ID
 	ToolUseID string          `json:"tool_use_id"` // tool_result reference
 	Input     json.RawMessage `json:"input"`
-	Content   json.RawMessage `json:"content"` // tool_result content (string or array)
+	Content   json.RawMessage `json:"content"`  // tool_result content (string or array)
+	IsError   bool            `json:"is_error"` // tool_result status
 }
 
 // toolInput holds common fields from tool_use input blocks.
@@ -74,18 +112,67 @@ type toolInput struct {
 	Content  string `json:"content"`
 }
 
+// ParseOptions controls optional content captured by ParseTranscript.
+type ParseOptions struct {
+	// IncludeThinking emits assistant thinking blocks as "thinking" turns.
+	IncludeThinking bool
+	// MaxToolResultBytes caps captured tool_result text; longer results are
+	// replaced with a placeholder. Zero means DefaultMaxToolResultBytes.
+	MaxToolResultBytes int
+	// CmdPrefixLen caps ToolCall.CmdPrefix in bytes, cut back to a rune
+	// boundary. Zero means DefaultCmdPrefixLen; negative keeps the whole
+	// command.
+	CmdPrefixLen int
+}
+
+// cmdPrefixLen returns the effective CmdPrefix cap, or -1 for none.
+func (o ParseOptions) cmdPrefixLen() int {
+	switch {
+	case o.CmdPrefixLen > 0:
+		return o.CmdPrefixLen
+	case o.CmdPrefixLen < 0:
+		return -1
+	}
+	return DefaultCmdPrefixLen
+}
+
+// maxToolResultBytes returns the effective tool_result cap.
+func (o ParseOptions) maxToolResultBytes() int {
+	if o.MaxToolResultBytes > 0 {
+		return o.MaxToolResultBytes
+	}
+	return DefaultMaxToolResultBytes
+}
+
 // ParseTranscript parses raw JSONL bytes into a SessionPayload.
-// It extracts conversation turns and tool calls, discarding tool results,
-// thinking blocks, system content, file-history-snapshots, and sidechain messages.
-func ParseTranscript(data []byte) (*SessionPayload, error) {
+// It extracts conversation turns and tool calls, discarding tool results
+// (apart from their error status), thinking blocks (unless
+// opts.IncludeThinking), system content, file-history-snapshots, and
+// sidechain messages.
+//
+// A transcript whose first message is on a sidechain is a subagent's own
+// transcript rather than a main session with sidechain noise. Its sidechain
+// messages are kept and the payload is marked ActorType "agent", with
+// AgentID from the lines' agentId, or the first message's uuid when no line
+// carries one.
+func ParseTranscript(data []byte, opts ParseOptions) (*SessionPayload, error) {
 	payload := &SessionPayload{
 		ActorType: "human",
 	}
 
+	// Set from the first user or assistant line.
+	var seenMessage, agentTranscript bool
+	var firstUUID string
+
 	// pendingPlanReads tracks tool_use IDs for Read calls targeting .claude/plans/ files.
 	// When the corresponding tool_result arrives in a user message, we extract the plan text.
 	pendingPlanReads := make(map[string]bool)
 
+	// pendingToolCalls maps tool_use IDs to their index in payload.ToolCalls,
+	// so the matching tool_result can record success or failure.
+	pendingToolCalls := make(map[string]int)
+	seenSessionIDs := make(map[string]bool)
+
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	// Increase scanner buffer for large lines (tool results can be huge).
 	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
@@ -102,39 +189,69 @@ func ParseTranscript(data []byte) (*SessionPayload, error) {
 			continue
 		}
 
+		if !seenMessage && (raw.Type == "user" || raw.Type == "assistant") {
+			seenMessage = true
+			agentTranscript = raw.IsSidechain
+			firstUUID = raw.UUID
+		}
+
 		// Discard filtered line types.
-		if raw.IsSidechain {
+		if raw.IsSidechain && !agentTranscript {
 			continue
 		}
+		if agentTranscript && payload.AgentID == "" && raw.AgentID != "" {
+			payload.AgentID = raw.AgentID
+		}
 		if raw.Type == "file-history-snapshot" {
 			continue
 		}
 
-		// Capture session metadata from first line that has it.
-		if payload.SessionID == "" && raw.SessionID != "" {
+		// A resumed session's transcript starts with the lines it carried
+		// over, still under the ID of the session they came from. The last
+		// new ID is the file's own; the one before it is the parent.
+		if raw.SessionID != "" && !seenSessionIDs[raw.SessionID] {
+			seenSessionIDs[raw.SessionID] = true
+			payload.ParentSessionID = payload.SessionID
 			payload.SessionID = raw.SessionID
 		}
+
+		// Capture session metadata from first line that has it.
 		if payload.Branch == "" && raw.GitBranch != "" {
 			payload.Branch = raw.GitBranch
 		}
+		if payload.CWD == "" && raw.CWD != "" {
+			payload.CWD = raw.CWD
+		}
 
 		ts := parseTimestamp(raw.Timestamp)
 
 		switch raw.Type {
+		case "summary":
+			payload.TotalCost = raw.TotalCost
+			payload.TotalDurationMs = raw.TotalDuration
+
 		case "user":
-			turns, err := parseUserTurn(raw.Message, ts, pendingPlanReads)
+			turns, err := parseUserTurn(raw.Message, ts, pendingPlanReads, opts)
 			if err != nil {
 				continue
 			}
 			payload.Turns = append(payload.Turns, turns...)
+			if len(pendingToolCalls) > 0 {
+				recordToolResults(raw.Message, payload.ToolCalls, pendingToolCalls)
+			}
 
 		case "assistant":
-			turns, toolCalls, planReadIDs, err := parseAssistantMessage(raw.Message, ts)
+			turns, toolCalls, planReadIDs, err := parseAssistantMessage(raw.Message, ts, opts)
 			if err != nil {
 				continue
 			}
 			payload.Turns = append(payload.Turns, turns...)
-			payload.ToolCalls = append(payload.ToolCalls, toolCalls...)
+			for _, tc := range toolCalls {
+				if tc.useID != "" {
+					pendingToolCalls[tc.useID] = len(payload.ToolCalls)
+				}
+				payload.ToolCalls = append(payload.ToolCalls, tc)
+			}
 			for _, id := range planReadIDs {
 				pendingPlanReads[id] = true
 			}
@@ -145,6 +262,13 @@ func ParseTranscript(data []byte) (*SessionPayload, error) {
 		return nil, fmt.Errorf("scan JSONL: %w", err)
 	}
 
+	if agentTranscript {
+		payload.ActorType = "agent"
+		if payload.AgentID == "" {
+			payload.AgentID = firstUUID
+		}
+	}
+
 	payload.CapturedAt = time.Now().UTC()
 	return payload, nil
 }
@@ -153,7 +277,7 @@ func ParseTranscript(data []byte) (*SessionPayload, error) {
 // It skips tool_result blocks (which contain file bodies, command outputs),
 // except for tool_results matching pendingPlanReads — those contain plan file
 // content that should be indexed.
-func parseUserTurn(msgRaw json.RawMessage, ts time.Time, pendingPlanReads map[string]bool) ([]Turn, error) {
+func parseUserTurn(msgRaw json.RawMessage, ts time.Time, pendingPlanReads map[string]bool, opts ParseOptions) ([]Turn, error) {
 	if len(msgRaw) == 0 {
 		return nil, nil
 	}
@@ -171,7 +295,7 @@ func parseUserTurn(msgRaw json.RawMessage, ts time.Time, pendingPlanReads map[st
 
 	// Extract plan content from tool_result blocks matching pending plan reads.
 	if len(pendingPlanReads) > 0 {
-		planTurns := extractPlanToolResults(msg.Content, ts, pendingPlanReads)
+		planTurns := extractPlanToolResults(msg.Content, ts, pendingPlanReads, opts.maxToolResultBytes())
 		turns = append(turns, planTurns...)
 	}
 
@@ -188,10 +312,10 @@ func parseUserTurn(msgRaw json.RawMessage, ts time.Time, pendingPlanReads map[st
 }
 
 // parseAssistantMessage extracts text turns and tool calls from an assistant message.
-// It discards thinking blocks and tool results.
+// It discards tool results, and thinking blocks unless opts.IncludeThinking.
 // It also returns IDs of Read tool_use blocks targeting .claude/plans/ files,
 // so the caller can match them against subsequent tool_result blocks.
-func parseAssistantMessage(msgRaw json.RawMessage, ts time.Time) ([]Turn, []ToolCall, []string, error) {
+func parseAssistantMessage(msgRaw json.RawMessage, ts time.Time, opts ParseOptions) ([]Turn, []ToolCall, []string, error) {
 	if len(msgRaw) == 0 {
 		return nil, nil, nil, nil
 	}
@@ -236,8 +360,16 @@ func parseAssistantMessage(msgRaw json.RawMessage, ts time.Time) ([]Turn, []Tool
 			if b.Text != "" {
 				textParts = append(textParts, b.Text)
 			}
+		case "thinking":
+			if opts.IncludeThinking && b.Thinking != "" {
+				turns = append(turns, Turn{
+					Role:      "thinking",
+					Content:   b.Thinking,
+					Timestamp: ts,
+				})
+			}
 		case "tool_use":
-			tc := extractToolCall(b)
+			tc := extractToolCall(b, opts.cmdPrefixLen())
 			toolCalls = append(toolCalls, tc)
 			// Capture plan file content as an assistant turn so it's searchable.
 			if planText := extractPlanContent(b); planText != "" {
@@ -251,7 +383,7 @@ func parseAssistantMessage(msgRaw json.RawMessage, ts time.Time) ([]Turn, []Tool
 			if id := extractPlanReadID(b); id != "" {
 				planReadIDs = append(planReadIDs, id)
 			}
-			// Discard: "thinking", "tool_result", etc.
+			// Discard: "redacted_thinking", "tool_result", etc.
 		}
 	}
 
@@ -310,10 +442,16 @@ func extractTextContent(content json.RawMessage) string {
 	return combined
 }
 
-// extractToolCall builds a ToolCall from a tool_use content block.
-func extractToolCall(b contentBlock) ToolCall {
+// extractToolCall builds a ToolCall from a tool_use content block, keeping
+// at most cmdPrefixLen bytes of a command (all of it when negative).
+func extractToolCall(b contentBlock, cmdPrefixLen int) ToolCall {
 	tc := ToolCall{
-		Tool: b.Name,
+		Tool:  b.Name,
+		useID: b.ID,
+	}
+	if server, tool, ok := SplitMCPTool(b.Name); ok {
+		tc.Server = server
+		tc.Tool = tool
 	}
 
 	if len(b.Input) == 0 {
@@ -332,14 +470,36 @@ func extractToolCall(b contentBlock) ToolCall {
 		tc.Path = inp.Path
 	}
 
-	// For Bash tool, capture first 100 chars of command.
+	// For Bash (or any tool with a command input), capture its start.
 	if inp.Command != "" {
-		tc.CmdPrefix = truncate(inp.Command, 100)
+		tc.CmdPrefix = inp.Command
+		if cmdPrefixLen >= 0 {
+			tc.CmdPrefix = truncate(inp.Command, cmdPrefixLen)
+		}
 	}
 
 	return tc
 }
 
+// SplitMCPTool splits an MCP tool_use name of the form mcp__<server>__<tool>
+// into its server and tool. ok is false for built-in tools.
+func SplitMCPTool(name string) (server, tool string, ok bool) {
+	rest, found := strings.CutPrefix(name, mcpToolPrefix)
+	if !found {
+		return "", "", false
+	}
+	server, tool, found = strings.Cut(rest, "__")
+	if !found || server == "" || tool == "" {
+		return "", "", false
+	}
+	return server, tool, true
+}
+
+// MCPToolName is the inverse of SplitMCPTool.
+func MCPToolName(server, tool string) string {
+	return mcpToolPrefix + server + "__" + tool
+}
+
 // extractPlanContent returns the file content from a Write/Edit tool_use block
 // if the target path is a .claude/plans/ file. This captures plan text as a
 // searchable assistant turn.
@@ -397,8 +557,9 @@ func extractPlanReadID(b contentBlock) string {
 // extractPlanToolResults scans user message content blocks for tool_result
 // blocks whose tool_use_id matches a pending plan read. For each match, it
 // extracts the text and emits it as an assistant turn (the content originated
-// from the assistant's Read call). Matched IDs are removed from the map.
-func extractPlanToolResults(content json.RawMessage, ts time.Time, pending map[string]bool) []Turn {
+// from the assistant's Read call). Results over maxBytes or not valid text
+// are elided (see elideToolResult). Matched IDs are removed from the map.
+func extractPlanToolResults(content json.RawMessage, ts time.Time, pending map[string]bool, maxBytes int) []Turn {
 	if len(content) == 0 {
 		return nil
 	}
@@ -417,7 +578,7 @@ func extractPlanToolResults(content json.RawMessage, ts time.Time, pending map[s
 			continue
 		}
 
-		text := extractToolResultText(b.Content)
+		text := elideToolResult(extractToolResultText(b.Content), maxBytes)
 		if text != "" {
 			turns = append(turns, Turn{
 				Role:      "assistant",
@@ -430,6 +591,35 @@ func extractPlanToolResults(content json.RawMessage, ts time.Time, pending map[s
 	return turns
 }
 
+// recordToolResults marks tool calls as failed when their tool_result in a
+// user message has is_error set, keeping a snippet of the error text.
+// Matched IDs are removed from pending.
+func recordToolResults(msgRaw json.RawMessage, toolCalls []ToolCall, pending map[string]int) {
+	var msg rawMessage
+	if err := json.Unmarshal(msgRaw, &msg); err != nil {
+		return
+	}
+	var blocks []contentBlock
+	if err := json.Unmarshal(msg.Content, &blocks); err != nil {
+		return
+	}
+
+	for _, b := range blocks {
+		if b.Type != "tool_result" {
+			continue
+		}
+		i, ok := pending[b.ToolUseID]
+		if !ok {
+			continue
+		}
+		if b.IsError {
+			toolCalls[i].Failed = true
+			toolCalls[i].ErrorSnippet = truncate(elideToolResult(extractToolResultText(b.Content), 0), maxErrorSnippet)
+		}
+		delete(pending, b.ToolUseID)
+	}
+}
+
 // extractToolResultText extracts text from a tool_result content field,
 // which can be a plain string or an array of content blocks.
 func extractToolResultText(content json.RawMessage) string {
@@ -466,10 +656,29 @@ func extractToolResultText(content json.RawMessage) string {
 	return combined
 }
 
+// elideToolResult replaces tool_result text that is binary or longer than
+// maxBytes with a short placeholder giving its size. Text is binary if it
+// is not valid UTF-8 or holds NUL or U+FFFD, which encoding/json substitutes
+// for invalid bytes. maxBytes <= 0 skips the size check.
+func elideToolResult(text string, maxBytes int) string {
+	switch {
+	case !utf8.ValidString(text) || strings.ContainsAny(text, "\x00\uFFFD"):
+		return fmt.Sprintf("[binary tool result elided: %d bytes]", len(text))
+	case maxBytes > 0 && len(text) > maxBytes:
+		return fmt.Sprintf("[tool result elided: %d bytes]", len(text))
+	}
+	return text
+}
+
+// truncate cuts s to at most maxLen bytes, backing off to a rune boundary
+// so a multi-byte character is never split.
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
 	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
 	return s[:maxLen]
 }
 
@@ -1,7 +1,9 @@
 package session
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestSanitizeRepoPath(t *testing.T) {
@@ -40,7 +42,7 @@ const fixtureJSONL = `{"uuid":"a1","sessionId":"sess-001","timestamp":"2025-01-1
 func TestParseTranscript(t *testing.T) {
 	t.Parallel()
 
-	payload, err := ParseTranscript([]byte(fixtureJSONL))
+	payload, err := ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -51,6 +53,9 @@ func TestParseTranscript(t *testing.T) {
 	if payload.Branch != "main" {
 		t.Errorf("Branch = %q, want %q", payload.Branch, "main")
 	}
+	if payload.CWD != "/tmp/repo" {
+		t.Errorf("CWD = %q, want %q", payload.CWD, "/tmp/repo")
+	}
 	if payload.ActorType != "human" {
 		t.Errorf("ActorType = %q, want %q", payload.ActorType, "human")
 	}
@@ -103,10 +108,100 @@ func TestParseTranscript(t *testing.T) {
 	}
 }
 
+// agentFixtureJSONL is a Task subagent's own transcript: every message is on
+// a sidechain and carries the agent's ID.
+const agentFixtureJSONL = `{"uuid":"b1","sessionId":"sess-001","agentId":"a3f9c2e1","timestamp":"2025-01-15T10:01:00Z","type":"user","message":{"role":"user","content":"Find every caller of login() in src/"},"cwd":"/tmp/repo","gitBranch":"main","isSidechain":true}
+{"uuid":"b2","sessionId":"sess-001","agentId":"a3f9c2e1","timestamp":"2025-01-15T10:01:05Z","type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Searching for callers."},{"type":"tool_use","id":"tu-g","name":"Grep","input":{"pattern":"login\\(","path":"src"}}]},"cwd":"/tmp/repo","gitBranch":"main","isSidechain":true}
+{"uuid":"b3","sessionId":"sess-001","agentId":"a3f9c2e1","timestamp":"2025-01-15T10:01:06Z","type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu-g","content":"src/app.tsx:12"}]},"cwd":"/tmp/repo","gitBranch":"main","isSidechain":true}
+{"uuid":"b4","sessionId":"sess-001","agentId":"a3f9c2e1","timestamp":"2025-01-15T10:01:10Z","type":"assistant","message":{"role":"assistant","content":"login() is called once, from src/app.tsx:12."},"cwd":"/tmp/repo","gitBranch":"main","isSidechain":true}
+`
+
+func TestParseTranscript_AgentSession(t *testing.T) {
+	t.Parallel()
+
+	payload, err := ParseTranscript([]byte(agentFixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.ActorType != "agent" {
+		t.Errorf("ActorType = %q, want agent", payload.ActorType)
+	}
+	if payload.AgentID != "a3f9c2e1" {
+		t.Errorf("AgentID = %q, want a3f9c2e1", payload.AgentID)
+	}
+	// Sidechain lines are the conversation here, so they are kept.
+	if len(payload.Turns) != 3 {
+		t.Fatalf("len(Turns) = %d, want 3", len(payload.Turns))
+	}
+	if payload.Turns[0].Role != "human" || payload.Turns[0].Content != "Find every caller of login() in src/" {
+		t.Errorf("Turns[0] = %+v", payload.Turns[0])
+	}
+	if len(payload.ToolCalls) != 1 || payload.ToolCalls[0].Tool != "Grep" || payload.ToolCalls[0].Path != "src" {
+		t.Errorf("ToolCalls = %+v, want one Grep on src", payload.ToolCalls)
+	}
+	if payload.SessionID != "sess-001" || payload.Branch != "main" {
+		t.Errorf("SessionID/Branch = %q/%q, want sess-001/main", payload.SessionID, payload.Branch)
+	}
+
+	// Without agentId, the first message's uuid identifies the agent.
+	noID := strings.ReplaceAll(agentFixtureJSONL, `"agentId":"a3f9c2e1",`, "")
+	payload, err = ParseTranscript([]byte(noID), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript without agentId: %v", err)
+	}
+	if payload.ActorType != "agent" || payload.AgentID != "b1" {
+		t.Errorf("without agentId: ActorType/AgentID = %q/%q, want agent/b1", payload.ActorType, payload.AgentID)
+	}
+
+	// A main session with trailing sidechain lines stays human.
+	payload, err = ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.ActorType != "human" || payload.AgentID != "" {
+		t.Errorf("main session: ActorType/AgentID = %q/%q, want human/empty", payload.ActorType, payload.AgentID)
+	}
+}
+
+// resumedFixtureJSONL is a session resumed from sess-001: it opens with the
+// carried-over turns under the old ID, then continues under its own.
+const resumedFixtureJSONL = `{"type":"summary","summary":"Login page","leafUuid":"a4"}
+{"uuid":"a1","sessionId":"sess-001","timestamp":"2025-01-15T10:00:00Z","type":"user","message":{"role":"user","content":"Add a login page"},"cwd":"/tmp/repo","gitBranch":"main"}
+{"uuid":"a2","sessionId":"sess-001","timestamp":"2025-01-15T10:00:05Z","type":"assistant","message":{"role":"assistant","content":"I'll create a login page for you."},"cwd":"/tmp/repo","gitBranch":"main"}
+{"uuid":"c1","sessionId":"sess-002","timestamp":"2025-01-16T09:00:00Z","type":"user","message":{"role":"user","content":"Now add a logout button"},"cwd":"/tmp/repo","gitBranch":"main"}
+{"uuid":"c2","sessionId":"sess-002","timestamp":"2025-01-16T09:00:05Z","type":"assistant","message":{"role":"assistant","content":"Added a logout button to the header."},"cwd":"/tmp/repo","gitBranch":"main"}
+`
+
+func TestParseTranscript_ResumedSession(t *testing.T) {
+	t.Parallel()
+
+	payload, err := ParseTranscript([]byte(resumedFixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.SessionID != "sess-002" {
+		t.Errorf("SessionID = %q, want the resumed session's own ID sess-002", payload.SessionID)
+	}
+	if payload.ParentSessionID != "sess-001" {
+		t.Errorf("ParentSessionID = %q, want sess-001", payload.ParentSessionID)
+	}
+	if len(payload.Turns) != 4 {
+		t.Errorf("expected carried-over and new turns (4), got %d", len(payload.Turns))
+	}
+
+	fresh, err := ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if fresh.ParentSessionID != "" {
+		t.Errorf("fresh session: ParentSessionID = %q, want empty", fresh.ParentSessionID)
+	}
+}
+
 func TestParseTranscript_Empty(t *testing.T) {
 	t.Parallel()
 
-	payload, err := ParseTranscript([]byte(""))
+	payload, err := ParseTranscript([]byte(""), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript empty: %v", err)
 	}
@@ -125,7 +220,7 @@ func TestParseTranscript_MalformedLines(t *testing.T) {
 {"uuid":"b1","sessionId":"s1","timestamp":"2025-01-15T10:00:00Z","type":"user","message":{"role":"user","content":"hello"},"gitBranch":"dev"}
 also not json`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript with bad lines: %v", err)
 	}
@@ -138,6 +233,9 @@ also not json`
 	if payload.Branch != "dev" {
 		t.Errorf("Branch = %q, want dev", payload.Branch)
 	}
+	if payload.CWD != "" {
+		t.Errorf("CWD = %q, want empty when no line has cwd", payload.CWD)
+	}
 }
 
 func TestParseTranscript_PlanContentCaptured(t *testing.T) {
@@ -145,7 +243,7 @@ func TestParseTranscript_PlanContentCaptured(t *testing.T) {
 
 	input := `{"uuid":"p1","sessionId":"s3","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Let me write a plan."},{"type":"tool_use","name":"Write","input":{"file_path":"/home/user/.claude/plans/my-plan.md","content":"# Plan\n\n## Step 1\nDo the thing.\n\n## Step 2\nDo the other thing."}}]},"gitBranch":"main"}`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -182,7 +280,7 @@ func TestParseTranscript_PlanReadCaptured(t *testing.T) {
 	input := `{"uuid":"pr1","sessionId":"s5","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Let me read the plan."},{"type":"tool_use","id":"tu-read-plan","name":"Read","input":{"file_path":"/home/user/.claude/plans/my-plan.md"}}]},"gitBranch":"main"}
 {"uuid":"pr2","sessionId":"s5","timestamp":"2025-01-15T10:00:01Z","type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu-read-plan","content":"# Plan\n\n## Step 1\nDo the thing.\n\n## Step 2\nDo the other thing."}]},"gitBranch":"main"}`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -217,6 +315,54 @@ func TestParseTranscript_PlanReadCaptured(t *testing.T) {
 	}
 }
 
+func TestParseTranscript_OversizedToolResultElided(t *testing.T) {
+	t.Parallel()
+
+	line := func(uuid, ts, typ, msg string) string {
+		return `{"uuid":"` + uuid + `","sessionId":"s7","timestamp":"` + ts + `","type":"` + typ + `","message":` + msg + `}` + "\n"
+	}
+	readPlan := func(id string) string {
+		return `{"role":"assistant","content":[{"type":"tool_use","id":"` + id + `","name":"Read","input":{"file_path":"/home/user/.claude/plans/plan.md"}}]}`
+	}
+	result := func(id, content string) string {
+		return `{"role":"user","content":[{"type":"tool_result","tool_use_id":"` + id + `","content":"` + content + `"}]}`
+	}
+
+	huge := strings.Repeat("x", 2000)
+	input := line("o1", "2025-01-15T10:00:00Z", "assistant", readPlan("tu-big")) +
+		line("o2", "2025-01-15T10:00:01Z", "user", result("tu-big", huge)) +
+		line("o3", "2025-01-15T10:00:02Z", "assistant", readPlan("tu-bin")) +
+		line("o4", "2025-01-15T10:00:03Z", "user", result("tu-bin", `PK\u0003\u0004\u0000\u0000`)) +
+		line("o5", "2025-01-15T10:00:04Z", "assistant", readPlan("tu-small")) +
+		line("o6", "2025-01-15T10:00:05Z", "user", result("tu-small", "# Plan"))
+
+	payload, err := ParseTranscript([]byte(input), ParseOptions{MaxToolResultBytes: 1000})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	var got []string
+	for _, turn := range payload.Turns {
+		got = append(got, turn.Content)
+	}
+	want := []string{
+		"[tool result elided: 2000 bytes]",
+		"[binary tool result elided: 6 bytes]",
+		"# Plan",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Turns = %q, want %q", got, want)
+	}
+
+	// The default cap keeps a 2000-byte result.
+	payload, err = ParseTranscript([]byte(input), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.Turns[0].Content != huge {
+		t.Errorf("Turns[0] should be kept under the default cap, got %d bytes", len(payload.Turns[0].Content))
+	}
+}
+
 func TestParseTranscript_PlanReadNonPlanIgnored(t *testing.T) {
 	t.Parallel()
 
@@ -224,7 +370,7 @@ func TestParseTranscript_PlanReadNonPlanIgnored(t *testing.T) {
 	input := `{"uuid":"nr1","sessionId":"s6","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"tu-read-src","name":"Read","input":{"file_path":"src/main.go"}}]},"gitBranch":"main"}
 {"uuid":"nr2","sessionId":"s6","timestamp":"2025-01-15T10:00:01Z","type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu-read-src","content":"package main\nfunc main() {}"}]},"gitBranch":"main"}`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -240,7 +386,7 @@ func TestParseTranscript_NonPlanWriteNotCaptured(t *testing.T) {
 
 	input := `{"uuid":"np1","sessionId":"s4","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Write","input":{"file_path":"src/app.go","content":"package main"}}]},"gitBranch":"main"}`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -264,7 +410,7 @@ func TestParseTranscript_CmdPrefixTruncation(t *testing.T) {
 
 	input := `{"uuid":"c1","sessionId":"s2","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"` + longCmd + `"}}]},"gitBranch":"main"}`
 
-	payload, err := ParseTranscript([]byte(input))
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
 	if err != nil {
 		t.Fatalf("ParseTranscript: %v", err)
 	}
@@ -275,3 +421,239 @@ func TestParseTranscript_CmdPrefixTruncation(t *testing.T) {
 		t.Errorf("CmdPrefix length = %d, want 100", len(payload.ToolCalls[0].CmdPrefix))
 	}
 }
+
+func TestParseTranscript_CmdPrefixUTF8(t *testing.T) {
+	t.Parallel()
+
+	// 99 ASCII bytes then a 3-byte rune straddling the 100-byte cut.
+	cmd := strings.Repeat("x", 99) + "→ echo done"
+	input := `{"uuid":"c1","sessionId":"s2","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"` + cmd + `"}}]},"gitBranch":"main"}`
+
+	for _, tc := range []struct {
+		name string
+		opts ParseOptions
+		want string
+	}{
+		{"default", ParseOptions{}, strings.Repeat("x", 99)},
+		{"rune fits", ParseOptions{CmdPrefixLen: 102}, strings.Repeat("x", 99) + "→"},
+		{"whole command", ParseOptions{CmdPrefixLen: -1}, cmd},
+	} {
+		payload, err := ParseTranscript([]byte(input), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: ParseTranscript: %v", tc.name, err)
+		}
+		if len(payload.ToolCalls) != 1 {
+			t.Fatalf("%s: expected 1 tool call, got %d", tc.name, len(payload.ToolCalls))
+		}
+		got := payload.ToolCalls[0].CmdPrefix
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: CmdPrefix %q is not valid UTF-8", tc.name, got)
+		}
+		if got != tc.want {
+			t.Errorf("%s: CmdPrefix = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseTranscript_MCPToolCall(t *testing.T) {
+	t.Parallel()
+
+	input := `{"uuid":"m1","sessionId":"s3","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[` +
+		`{"type":"tool_use","id":"t1","name":"mcp__github__create_issue","input":{"title":"Flaky test","body":"fails on CI"}},` +
+		`{"type":"tool_use","id":"t2","name":"mcp__shell__run","input":{"command":"make test"}},` +
+		`{"type":"tool_use","id":"t3","name":"mcp__broken","input":{}}]},"gitBranch":"main"}`
+
+	payload, err := ParseTranscript([]byte(input), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if len(payload.ToolCalls) != 3 {
+		t.Fatalf("expected 3 tool calls, got %d", len(payload.ToolCalls))
+	}
+
+	issue := payload.ToolCalls[0]
+	if issue.Server != "github" || issue.Tool != "create_issue" {
+		t.Errorf("tool call 0 = %s/%s, want github/create_issue", issue.Server, issue.Tool)
+	}
+	run := payload.ToolCalls[1]
+	if run.Server != "shell" || run.Tool != "run" || run.CmdPrefix != "make test" {
+		t.Errorf("tool call 1 = %s/%s cmd %q, want shell/run cmd %q", run.Server, run.Tool, run.CmdPrefix, "make test")
+	}
+	// Names without a tool part are kept verbatim.
+	if bad := payload.ToolCalls[2]; bad.Server != "" || bad.Tool != "mcp__broken" {
+		t.Errorf("tool call 2 = %s/%s, want /mcp__broken", bad.Server, bad.Tool)
+	}
+}
+
+func TestParseTranscript_SummaryLine(t *testing.T) {
+	t.Parallel()
+
+	data := `{"type":"summary","sessionId":"s8","totalCost":0.42,"totalDuration":95000}
+{"uuid":"u1","sessionId":"s8","timestamp":"2025-01-15T10:00:00Z","type":"user","message":{"role":"user","content":"hello"},"gitBranch":"main"}`
+
+	payload, err := ParseTranscript([]byte(data), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.TotalCost != 0.42 {
+		t.Errorf("TotalCost = %v, want 0.42", payload.TotalCost)
+	}
+	if payload.TotalDurationMs != 95000 {
+		t.Errorf("TotalDurationMs = %d, want 95000", payload.TotalDurationMs)
+	}
+	if len(payload.Turns) != 1 {
+		t.Errorf("len(Turns) = %d, want 1", len(payload.Turns))
+	}
+
+	// No summary line leaves the fields zero.
+	payload, err = ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if payload.TotalCost != 0 || payload.TotalDurationMs != 0 {
+		t.Errorf("without summary: TotalCost = %v, TotalDurationMs = %d, want zero", payload.TotalCost, payload.TotalDurationMs)
+	}
+}
+
+func TestParseTranscript_ToolResultError(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		`{"uuid":"e1","sessionId":"s6","timestamp":"2025-01-15T10:00:00Z","type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_ok","name":"Read","input":{"file_path":"/repo/main.go"}},{"type":"tool_use","id":"toolu_fail","name":"Bash","input":{"command":"go test ./..."}}]},"gitBranch":"main"}`,
+		`{"uuid":"e2","sessionId":"s6","timestamp":"2025-01-15T10:00:05Z","type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_ok","content":"package main"},{"type":"tool_result","tool_use_id":"toolu_fail","is_error":true,"content":[{"type":"text","text":"--- FAIL: TestLogin (0.00s)\nExit code 1"}]}]},"gitBranch":"main"}`,
+	}
+
+	payload, err := ParseTranscript([]byte(strings.Join(lines, "\n")), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if len(payload.ToolCalls) != 2 {
+		t.Fatalf("len(ToolCalls) = %d, want 2", len(payload.ToolCalls))
+	}
+
+	if payload.ToolCalls[0].Failed {
+		t.Error("ToolCalls[0] (Read) should not be failed")
+	}
+	if !payload.ToolCalls[1].Failed {
+		t.Error("ToolCalls[1] (Bash) should be failed")
+	}
+	if want := "--- FAIL: TestLogin (0.00s)\nExit code 1"; payload.ToolCalls[1].ErrorSnippet != want {
+		t.Errorf("ToolCalls[1].ErrorSnippet = %q, want %q", payload.ToolCalls[1].ErrorSnippet, want)
+	}
+
+	// Tool results stay out of the turns.
+	if len(payload.Turns) != 0 {
+		t.Errorf("len(Turns) = %d, want 0", len(payload.Turns))
+	}
+}
+
+func TestParseTranscript_ThinkingBlock(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		`{"uuid":"t1","sessionId":"s7","timestamp":"2025-01-15T10:00:00Z","type":"user","message":{"role":"user","content":"why does login fail?"},"gitBranch":"main"}`,
+		`{"uuid":"t2","sessionId":"s7","timestamp":"2025-01-15T10:00:05Z","type":"assistant","message":{"role":"assistant","content":[{"type":"thinking","thinking":"The token expiry check uses local time."},{"type":"text","text":"The expiry check is off by a timezone."}]},"gitBranch":"main"}`,
+	}
+	data := []byte(strings.Join(lines, "\n"))
+
+	payload, err := ParseTranscript(data, ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	for _, turn := range payload.Turns {
+		if turn.Role == "thinking" {
+			t.Errorf("thinking turn captured without IncludeThinking: %q", turn.Content)
+		}
+	}
+	if len(payload.Turns) != 2 {
+		t.Fatalf("len(Turns) = %d, want 2", len(payload.Turns))
+	}
+
+	payload, err = ParseTranscript(data, ParseOptions{IncludeThinking: true})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if len(payload.Turns) != 3 {
+		t.Fatalf("len(Turns) = %d, want 3", len(payload.Turns))
+	}
+	if payload.Turns[1].Role != "thinking" {
+		t.Errorf("Turns[1].Role = %q, want thinking", payload.Turns[1].Role)
+	}
+	if want := "The token expiry check uses local time."; payload.Turns[1].Content != want {
+		t.Errorf("Turns[1].Content = %q, want %q", payload.Turns[1].Content, want)
+	}
+	if payload.Turns[2].Role != "assistant" {
+		t.Errorf("Turns[2].Role = %q, want assistant", payload.Turns[2].Role)
+	}
+}
+
+func TestContentID_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	first, err := ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	second, err := ParseTranscript([]byte(fixtureJSONL), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+
+	id := ContentID(first)
+	if len(id) != 26 {
+		t.Errorf("len(ContentID) = %d, want 26", len(id))
+	}
+	if got := ContentID(second); got != id {
+		t.Errorf("ContentID differs across runs: %q vs %q", got, id)
+	}
+
+	// Metadata-only differences (uuid, cwd, timestamps) must not change the ID.
+	relocated := strings.NewReplacer(`"cwd":"/tmp/repo"`, `"cwd":"/other/machine"`, `2025-01-15`, `2026-03-01`).Replace(fixtureJSONL)
+	moved, err := ParseTranscript([]byte(relocated), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if got := ContentID(moved); got != id {
+		t.Errorf("ContentID changed with metadata: %q vs %q", got, id)
+	}
+
+	// Different conversation content must produce a different ID.
+	edited, err := ParseTranscript([]byte(strings.Replace(fixtureJSONL, "Add a login page", "Add a signup page", 1)), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseTranscript: %v", err)
+	}
+	if got := ContentID(edited); got == id {
+		t.Errorf("ContentID should differ for different content, both %q", id)
+	}
+}
+
+func FuzzParseTranscript(f *testing.F) {
+	for _, seed := range []string{
+		fixtureJSONL,
+		agentFixtureJSONL,
+		resumedFixtureJSONL,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"tool_use","id":"t1","name":"mcp__github__create_issue","input":{"title":"x"}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":[{"type":"text","text":"boom"}]}]}}`,
+		`{"type":"user","message":{"role":"user","content":[[[[{"type":"text"}]]]]}}`,
+		`{"type":"summary","totalCost":"free","totalDuration":-1}`,
+		"not json\n{\n\x00\xff",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range []ParseOptions{{}, {IncludeThinking: true, MaxToolResultBytes: 8, CmdPrefixLen: 3}} {
+			payload, err := ParseTranscript(data, opts)
+			if err != nil {
+				continue
+			}
+			if payload == nil {
+				t.Fatal("nil payload with nil error")
+			}
+			for _, tc := range payload.ToolCalls {
+				if opts.CmdPrefixLen > 0 && len(tc.CmdPrefix) > opts.CmdPrefixLen {
+					t.Fatalf("CmdPrefix %q longer than %d bytes", tc.CmdPrefix, opts.CmdPrefixLen)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Transcript format names, as given to checkpoint --format.
+const (
+	FormatAuto   = "auto"
+	FormatClaude = "claude"
+	FormatChat   = "openai-chat"
+)
+
+// Parser reads one agent's transcript format into a SessionPayload.
+type Parser interface {
+	// Name is the format name that selects the parser.
+	Name() string
+	// Ext is the file extension of the format's transcripts, with the dot.
+	Ext() string
+	// Detect reports whether data looks like a transcript in this format.
+	Detect(data []byte) bool
+	// Parse extracts turns and tool calls from a transcript.
+	Parse(data []byte, opts ParseOptions) (*SessionPayload, error)
+	// SessionDir returns the directory where the agent keeps transcripts
+	// recorded in repoPath, or "" if it has no standard place.
+	SessionDir(repoPath string) string
+}
+
+// parsers are the known formats, in detection order. Claude Code's comes
+// first and is the fallback when no parser recognizes a transcript.
+var parsers = []Parser{claudeParser{}, chatParser{}}
+
+// Formats returns the format names checkpoint --format accepts, "auto"
+// first.
+func Formats() []string {
+	names := []string{FormatAuto}
+	for _, p := range parsers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// ParserFor returns the parser named format, or nil if there is none.
+// "auto" has no parser of its own; use DetectParser.
+func ParserFor(format string) Parser {
+	for _, p := range parsers {
+		if p.Name() == format {
+			return p
+		}
+	}
+	return nil
+}
+
+// DetectParser returns the first parser that recognizes data, or Claude
+// Code's when none does.
+func DetectParser(data []byte) Parser {
+	for _, p := range parsers {
+		if p.Detect(data) {
+			return p
+		}
+	}
+	return parsers[0]
+}
+
+// claudeParser reads Claude Code's JSONL transcripts.
+type claudeParser struct{}
+
+func (claudeParser) Name() string { return FormatClaude }
+func (claudeParser) Ext() string  { return ".jsonl" }
+
+// Detect reports whether the first non-empty line is a JSON object with a
+// Claude Code line type.
+func (claudeParser) Detect(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var raw struct {
+			Type string `json:"type"`
+		}
+		return json.Unmarshal(line, &raw) == nil && raw.Type != ""
+	}
+	return false
+}
+
+func (claudeParser) Parse(data []byte, opts ParseOptions) (*SessionPayload, error) {
+	return ParseTranscript(data, opts)
+}
+
+func (claudeParser) SessionDir(repoPath string) string { return FindSessionDir(repoPath) }
+
+// hasExt reports whether name ends in one of the parsers' extensions, or
+// in p's alone when p is not nil.
+func hasExt(name string, p Parser) bool {
+	if p != nil {
+		return strings.HasSuffix(name, p.Ext())
+	}
+	for _, p := range parsers {
+		if strings.HasSuffix(name, p.Ext()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package session
+
+import "os"
+
+// SessionRef names one transcript in a SessionSource.
+type SessionRef struct {
+	// Key identifies the transcript within its source. Checkpoint caches
+	// the transcript's state under it and names it in warnings. For a file
+	// it is the absolute path; other sources use keys that are not, such
+	// as a URI, so their cache entries are not pruned as deleted files.
+	Key string
+}
+
+// SessionSource is where an agent keeps its transcripts: a directory of
+// files for Claude Code, but it could as well be a SQLite database or one
+// log file holding every session.
+type SessionSource interface {
+	// List returns the transcripts the source holds.
+	List() ([]SessionRef, error)
+	// Read returns a transcript's raw bytes, for a Parser.
+	Read(ref SessionRef) ([]byte, error)
+}
+
+// DirSource is a directory of transcript files with Parser's extension,
+// or with any known format's when Parser is nil.
+type DirSource struct {
+	Dir    string
+	Parser Parser
+}
+
+// List returns the directory's transcripts, keyed by path.
+func (s DirSource) List() ([]SessionRef, error) {
+	files, err := FindTranscripts(s.Dir, s.Parser)
+	if err != nil {
+		return nil, err
+	}
+	return fileRefs(files), nil
+}
+
+// Read reads the transcript file.
+func (s DirSource) Read(ref SessionRef) ([]byte, error) {
+	return os.ReadFile(ref.Key)
+}
+
+// FileSource is a fixed list of transcript files.
+type FileSource []string
+
+// List returns the files, keyed by path.
+func (s FileSource) List() ([]SessionRef, error) {
+	return fileRefs(s), nil
+}
+
+// Read reads the transcript file.
+func (s FileSource) Read(ref SessionRef) ([]byte, error) {
+	return os.ReadFile(ref.Key)
+}
+
+func fileRefs(files []string) []SessionRef {
+	refs := make([]SessionRef, len(files))
+	for i, f := range files {
+		refs[i] = SessionRef{Key: f}
+	}
+	return refs
+}
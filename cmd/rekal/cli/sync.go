@@ -1,18 +1,29 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/nomic"
 	"github.com/spf13/cobra"
 )
 
 func newSyncCmd() *cobra.Command {
-	var selfOnly bool
+	var (
+		selfOnly bool
+		remote   string
+		progress string
+		timeout  time.Duration
+		since    string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "sync",
@@ -30,6 +41,20 @@ By default, fetches all rekal/* branches (whole team). Use --self to fetch
 only your own rekal branch — useful when syncing across your own machines
 (e.g. pulling context from your work laptop to your home machine).
 
+git fetch and push are aborted after --timeout (default 2m, or git config
+rekal.timeout) so a hung remote cannot block sync indefinitely.
+
+Use --remote to sync through a remote other than origin. The remote must be
+configured ('git remote get-url <remote>' must succeed).
+
+Use --since to import only teammates' sessions captured at or after a time,
+given as RFC3339 (2026-02-25T10:00:00Z) or relative (7d, 24h), so a long-lived
+team's full history does not slow every sync.
+
+Use --progress json to report team sync as one JSON object per phase on stderr
+({"phase":"fetch","status":"done","count":3}) instead of the usual lines, for
+editors and dashboards that wrap rekal.
+
 Typical usage:
   Developer:  Run 'rekal sync' at the start of the day
   Agent:      Run 'rekal sync' at the start of a session if team context matters
@@ -47,44 +72,86 @@ Typical usage:
 				return NewSilentError(err)
 			}
 
+			if cmd.Flags().Changed("remote") {
+				if err := checkRemote(gitRoot, remote); err != nil {
+					return err
+				}
+			}
+			if progress != "text" && progress != "json" {
+				return fmt.Errorf("--progress must be text or json, got %q", progress)
+			}
+			if progress == "json" && selfOnly {
+				return fmt.Errorf("--progress json is only supported for team sync, not --self")
+			}
+
+			var sinceTime time.Time
+			if cmd.Flags().Changed("since") {
+				if selfOnly {
+					return fmt.Errorf("--since is only supported for team sync, not --self")
+				}
+				if sinceTime, err = parseTimeBound(since, time.Now()); err != nil {
+					return fmt.Errorf("--since: %w", err)
+				}
+			}
+
+			timeout := networkTimeout(cmd, timeout)
 			if selfOnly {
-				return runSyncSelf(cmd, gitRoot)
+				return runSyncSelf(cmd, gitRoot, remote, timeout)
 			}
-			return runSyncTeam(cmd, gitRoot)
+			return runSyncTeam(cmd, gitRoot, remote, timeout, sinceTime, &syncProgress{w: cmd.ErrOrStderr(), json: progress == "json"})
 		},
 	}
 
 	cmd.Flags().BoolVar(&selfOnly, "self", false, "Only fetch your own rekal branch (not the whole team)")
+	cmd.Flags().StringVar(&since, "since", "", "Only import teammates' sessions captured at or after this time (RFC3339 or relative, e.g. 7d)")
+	cmd.Flags().StringVar(&progress, "progress", "text", "Progress output on stderr: text, or json for one object per phase")
+	addRemoteFlag(cmd, &remote)
+	addTimeoutFlag(cmd, &timeout)
+	_ = cmd.RegisterFlagCompletionFunc("progress", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
 
 	return cmd
 }
 
-// runSyncTeam checkpoints + pushes local data, fetches all remote rekal branches,
-// and rebuilds the index from local data.db plus decoded remote wire format.
-func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
-	w := cmd.ErrOrStderr()
+// runSyncTeam checkpoints + pushes local data, fetches all rekal branches from
+// remote, and rebuilds the index from local data.db plus decoded remote wire format.
+// Remote sessions captured before since are not imported; a zero since imports all.
+func runSyncTeam(cmd *cobra.Command, gitRoot, remote string, timeout time.Duration, since time.Time, p *syncProgress) error {
+	// In JSON mode checkpoint and push run quietly into a buffer, so anything
+	// they write is a problem to report on their phase.
+	quiet := isQuiet(cmd) || p.json
+	var out bytes.Buffer
 
 	// Step 1: Checkpoint (non-fatal).
-	if err := doCheckpoint(gitRoot, w); err != nil {
-		fmt.Fprintf(w, "rekal: warning: checkpoint failed: %v\n", err)
+	sessionsBefore, _ := dataCounts(gitRoot)
+	err := doCheckpoint(gitRoot, p.stepWriter(&out), checkpointOptions{Quiet: quiet})
+	if err != nil {
+		p.textf("rekal: warning: checkpoint failed: %v\n", err)
 	}
+	sessionsAfter, unexported := dataCounts(gitRoot)
+	p.step("checkpoint", sessionsAfter-sessionsBefore, err, &out)
 
-	// Step 2: Push (non-fatal).
-	if err := doPush(gitRoot, w, false); err != nil {
-		fmt.Fprintf(w, "rekal: warning: push failed: %v\n", err)
+	// Step 2: Push (non-fatal). Its count is the checkpoints it exported.
+	out.Reset()
+	err = doPush(gitRoot, p.stepWriter(&out), pushOptions{Remote: remote, Quiet: quiet, Timeout: timeout})
+	if err != nil {
+		p.textf("rekal: warning: push failed: %v\n", err)
 	}
+	_, stillUnexported := dataCounts(gitRoot)
+	p.step("push", unexported-stillUnexported, err, &out)
 
 	// Step 3: Fetch remote rekal refs (non-fatal).
-	fmt.Fprintln(w, "fetching remote rekal branches...")
-	if err := fetchRemoteRekalRefs(gitRoot); err != nil {
-		fmt.Fprintf(w, "rekal: warning: fetch failed: %v\n", err)
+	p.textf("fetching remote rekal branches...\n")
+	fetchErr := fetchRemoteRekalRefs(gitRoot, remote, timeout)
+	if fetchErr != nil {
+		p.textf("rekal: warning: fetch failed: %v\n", fetchErr)
 	}
 
 	// Step 4: List remote branches (excluding self).
-	remoteBranches, err := listRemoteRekalBranches(gitRoot)
+	remoteBranches, err := listRemoteRekalBranches(gitRoot, remote)
 	if err != nil {
-		fmt.Fprintf(w, "rekal: warning: listing remote branches failed: %v\n", err)
+		p.textf("rekal: warning: listing remote branches failed: %v\n", err)
 	}
+	p.step("fetch", len(remoteBranches), errors.Join(fetchErr, err), nil)
 
 	// Step 5: Rebuild index.
 	indexDB, err := db.OpenIndex(gitRoot)
@@ -97,6 +164,10 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 		return fmt.Errorf("load fts extension: %w", err)
 	}
 
+	// Keep the tokenizer and embedding models the index was built with.
+	tok := indexTokenizer(indexDB)
+	embeddings := resolveEmbeddings(indexEmbeddings(indexDB), p.infoWriter())
+
 	// Clean slate.
 	if err := db.DropIndexTables(indexDB); err != nil {
 		return fmt.Errorf("drop index tables: %w", err)
@@ -106,7 +177,7 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 	}
 
 	// 5a: Populate from local data.db.
-	fmt.Fprintln(w, "indexing local data...")
+	p.textf("indexing local data...\n")
 	if err := db.PopulateIndex(indexDB, gitRoot); err != nil {
 		return fmt.Errorf("populate index: %w", err)
 	}
@@ -116,15 +187,22 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&localSessions); err != nil {
 		return fmt.Errorf("count local sessions: %w", err)
 	}
+	p.step("index-local", localSessions, nil, nil)
 
-	// 5b: Import each remote branch into index.
+	// 5b: Import each remote branch into index, with teammates' emails
+	// canonicalized like local ones.
+	aliases, err := db.LoadEmailAliases(gitRoot)
+	if err != nil {
+		return err
+	}
 	var remoteSessions int
 	teamMembers := 0
 	for _, branch := range remoteBranches {
-		fmt.Fprintf(w, "importing %s...\n", branch)
-		n, err := importBranchToIndex(gitRoot, indexDB, branch)
+		p.textf("importing %s...\n", branch)
+		n, err := importBranchToIndex(gitRoot, indexDB, branch, since, aliases)
 		if err != nil {
-			fmt.Fprintf(w, "rekal: warning: import %s failed: %v\n", branch, err)
+			p.textf("rekal: warning: import %s failed: %v\n", branch, err)
+			p.emit(progressEvent{Phase: "import-remote", Status: "failed", Branch: branch, Error: err.Error()})
 			continue
 		}
 		if n > 0 {
@@ -133,6 +211,8 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 		}
 	}
 
+	p.step("import-remote", remoteSessions, nil, nil)
+
 	// Count totals.
 	var sessionCount, turnCount int
 	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&sessionCount); err != nil {
@@ -144,36 +224,62 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 
 	// 5c: Create FTS index.
 	if turnCount > 0 {
-		fmt.Fprintln(w, "creating full-text search index...")
-		if err := db.CreateFTSIndex(indexDB); err != nil {
+		p.textf("creating full-text search index...\n")
+		if _, err := db.EnsureFTSIndex(indexDB, tok); err != nil {
 			return fmt.Errorf("create fts index: %w", err)
 		}
+		p.step("fts", turnCount, nil, nil)
+	} else {
+		p.skip("fts")
 	}
 
 	// 5d: LSA pass.
 	embeddingDim := 0
 	if sessionCount >= 2 {
-		fmt.Fprintln(w, "building LSA embeddings...")
 		sessionContent, err := db.QuerySessionContent(indexDB)
 		if err != nil {
 			return fmt.Errorf("query session content: %w", err)
 		}
 
-		model, err := lsa.Build(sessionContent, lsa.DefaultDimension)
+		var model *lsa.Model
+		if embeddings != embeddingsNomic {
+			p.textf("building LSA embeddings...\n")
+			model, err = lsa.BuildWith(sessionContent, lsaDimension(), tok)
+		}
 		if err != nil {
-			fmt.Fprintf(w, "warning: LSA build failed: %v\n", err)
+			p.textf("warning: LSA build failed: %v\n", err)
+			p.step("lsa", 0, err, nil)
 		} else if model != nil {
 			vectors := model.Vectors()
 			if err := db.StoreEmbeddings(indexDB, vectors, "lsa-v1"); err != nil {
 				return fmt.Errorf("store embeddings: %w", err)
 			}
+			if err := storeLSAModel(indexDB, model); err != nil {
+				return err
+			}
 			embeddingDim = model.Dim
+			p.step("lsa", len(vectors), nil, nil)
+		} else {
+			p.skip("lsa")
 		}
 
 		// 5d-ii: Nomic pass (non-fatal).
-		if err := buildNomicEmbeddings(indexDB, sessionContent, w); err != nil {
-			fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
+		if embeddings == embeddingsLSA {
+			p.skip("nomic")
+		} else {
+			switch err := buildNomicEmbeddings(indexDB, sessionContent, p.infoWriter()); {
+			case err != nil:
+				p.textf("warning: nomic embeddings skipped: %v\n", err)
+				p.step("nomic", 0, err, nil)
+			case nomic.Supported():
+				p.step("nomic", len(sessionContent), nil, nil)
+			default:
+				p.skip("nomic")
+			}
 		}
+	} else {
+		p.skip("lsa")
+		p.skip("nomic")
 	}
 
 	// 5e: Write index state.
@@ -186,40 +292,147 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 	if err := db.WriteIndexState(indexDB, "embedding_dim", strconv.Itoa(embeddingDim)); err != nil {
 		return err
 	}
+	if err := db.WriteIndexState(indexDB, "tokenizer", tok.String()); err != nil {
+		return err
+	}
+	if err := writeEmbeddingState(indexDB, embeddings); err != nil {
+		return err
+	}
+	if err := writeIndexFreshness(indexDB); err != nil {
+		return err
+	}
 	if err := db.WriteIndexState(indexDB, "last_indexed_at", "now"); err != nil {
 		return err
 	}
+	if err := writeIndexManifest(indexDB, gitRoot, "full"); err != nil {
+		p.textf("rekal: warning: %v\n", err)
+	}
 
 	// Step 6: Summary.
-	fmt.Fprintf(w, "rekal: synced — %d local sessions", localSessions)
+	if p.json {
+		p.step("sync", sessionCount, nil, nil)
+		return nil
+	}
+	fmt.Fprintf(p.w, "rekal: synced — %d local sessions", localSessions)
 	if remoteSessions > 0 {
-		fmt.Fprintf(w, ", %d remote sessions from %d team member(s)", remoteSessions, teamMembers)
+		fmt.Fprintf(p.w, ", %d remote sessions from %d team member(s)", remoteSessions, teamMembers)
 	}
-	fmt.Fprintln(w)
+	fmt.Fprintln(p.w)
 
 	return nil
 }
 
-// runSyncSelf fetches the current user's remote branch, imports into data.db,
-// and performs a full index rebuild.
-func runSyncSelf(cmd *cobra.Command, gitRoot string) error {
+// syncProgress reports the phases of a team sync: as the free-form lines
+// sync has always printed or, with --progress json, as one progressEvent per
+// phase. Each method is a no-op in the other mode.
+type syncProgress struct {
+	w    io.Writer
+	json bool
+}
+
+// progressEvent is one line of --progress json output. Count is what the
+// phase produced: sessions captured, checkpoints pushed, remote branches
+// fetched, sessions indexed or imported, turns indexed, embeddings stored.
+type progressEvent struct {
+	Phase  string `json:"phase"`
+	Status string `json:"status"` // done, warning, failed or skipped
+	Count  int    `json:"count"`
+	Branch string `json:"branch,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// textf prints a progress line in text mode.
+func (p *syncProgress) textf(format string, args ...any) {
+	if !p.json {
+		fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+// emit writes ev in JSON mode.
+func (p *syncProgress) emit(ev progressEvent) {
+	if p.json {
+		_ = json.NewEncoder(p.w).Encode(ev)
+	}
+}
+
+// step reports a finished phase. A non-nil err marks it failed; output a
+// quiet step wrote to its buffer marks it done with a warning.
+func (p *syncProgress) step(phase string, count int, err error, out *bytes.Buffer) {
+	ev := progressEvent{Phase: phase, Status: "done", Count: count}
+	switch {
+	case err != nil:
+		ev.Status, ev.Error = "failed", err.Error()
+	case out != nil && out.Len() > 0:
+		ev.Status, ev.Error = "warning", strings.TrimSpace(out.String())
+	}
+	p.emit(ev)
+}
+
+// skip reports a phase that had nothing to do.
+func (p *syncProgress) skip(phase string) {
+	p.emit(progressEvent{Phase: phase, Status: "skipped"})
+}
+
+// stepWriter is where a step writes its own messages: stderr in text mode,
+// out in JSON mode.
+func (p *syncProgress) stepWriter(out *bytes.Buffer) io.Writer {
+	if p.json {
+		return out
+	}
+	return p.w
+}
+
+// infoWriter is where informational step output goes: stderr in text mode,
+// nowhere in JSON mode.
+func (p *syncProgress) infoWriter() io.Writer {
+	if p.json {
+		return io.Discard
+	}
+	return p.w
+}
+
+// dataCounts returns the number of sessions and of unexported checkpoints in
+// data.db, or zeros if it can't be read.
+func dataCounts(gitRoot string) (sessions, unexported int) {
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		return 0, 0
+	}
+	defer dataDB.Close()
+	_ = dataDB.QueryRow("SELECT count(*) FROM sessions").Scan(&sessions)
+	_ = dataDB.QueryRow("SELECT count(*) FROM checkpoints WHERE NOT exported").Scan(&unexported)
+	return sessions, unexported
+}
+
+// runSyncSelf fetches the current user's branch from remote, imports into
+// data.db, and performs a full index rebuild.
+func runSyncSelf(cmd *cobra.Command, gitRoot, remote string, timeout time.Duration) error {
 	w := cmd.ErrOrStderr()
 	branch := rekalBranchName()
 
 	// Step 1: Fetch own remote branch.
 	fmt.Fprintln(w, "fetching your remote branch...")
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
-		return fmt.Errorf("no remote 'origin' configured")
+	if err := checkRemote(gitRoot, remote); err != nil {
+		return err
 	}
 
-	fetchCmd := exec.Command("git", "-C", gitRoot, "fetch", "origin", branch)
-	fetchCmd.Stdin = nil
-	if output, err := fetchCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("fetch origin/%s failed: %s", branch, strings.TrimSpace(string(output)))
+	if output, err := runGitNetwork(gitRoot, timeout, "fetch", remote, branch); err != nil {
+		if errors.Is(err, errNetworkTimeout) {
+			return err
+		}
+		return fmt.Errorf("fetch %s/%s failed: %s", remote, branch, strings.TrimSpace(string(output)))
+	}
+
+	// Serialize with a concurrent checkpoint or push, through the index
+	// rebuild too, since checkpoint updates the index under the lock.
+	unlock, err := lockRepo(gitRoot)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	// Step 2: Import from remote branch into data.db.
-	remoteBranch := "origin/" + branch
+	remoteBranch := remote + "/" + branch
 	dataDB, err := db.OpenData(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open data db: %w", err)
@@ -233,5 +446,5 @@ func runSyncSelf(cmd *cobra.Command, gitRoot string) error {
 	fmt.Fprintf(w, "rekal: imported %d session(s) from %s\n", n, remoteBranch)
 
 	// Step 3: Full index rebuild.
-	return runIndex(cmd, gitRoot)
+	return runIndex(cmd, gitRoot, indexOptions{})
 }
@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"bufio"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os/exec"
 	"strings"
@@ -10,32 +13,37 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 )
 
-// fetchRemoteRekalRefs fetches all rekal/* branches from origin.
-// Non-fatal: returns nil if no remote or fetch fails.
-func fetchRemoteRekalRefs(gitRoot string) error {
+// fetchRemoteRekalRefs fetches all rekal/* branches from remote.
+// Non-fatal: returns nil if no remote or fetch fails, except for a timeout,
+// which is reported so sync doesn't silently work from stale refs.
+func fetchRemoteRekalRefs(gitRoot, remote string, timeout time.Duration) error {
 	// Check if remote is configured.
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
+	if err := checkRemote(gitRoot, remote); err != nil {
 		return nil // no remote configured
 	}
 
-	cmd := exec.Command("git", "-C", gitRoot, "fetch", "origin", "refs/heads/rekal/*:refs/remotes/origin/rekal/*")
-	cmd.Stdin = nil
-	_ = cmd.Run() // non-fatal
-	return nil
+	refspec := fmt.Sprintf("refs/heads/rekal/*:refs/remotes/%s/rekal/*", remote)
+	_, err := runGitNetwork(gitRoot, timeout, "fetch", remote, refspec)
+	if errors.Is(err, errNetworkTimeout) {
+		return err
+	}
+	return nil // other failures are non-fatal
 }
 
-// listRemoteRekalBranches returns remote rekal branch refs, excluding the current user's branch.
-func listRemoteRekalBranches(gitRoot string) ([]string, error) {
+// listRemoteRekalBranches returns the rekal branch refs fetched from remote,
+// excluding the current user's branch.
+func listRemoteRekalBranches(gitRoot, remote string) ([]string, error) {
 	out, err := exec.Command("git", "-C", gitRoot,
-		"for-each-ref", "--format=%(refname:short)", "refs/remotes/origin/rekal/",
+		"for-each-ref", "--format=%(refname:short)", "refs/remotes/"+remote+"/rekal/",
 	).Output()
 	if err != nil {
 		return nil, nil // no remote refs
 	}
 
-	selfBranch := "origin/" + rekalBranchName()
+	selfBranch := remote + "/" + rekalBranchName()
 
 	var branches []string
 	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
@@ -53,159 +61,203 @@ func listRemoteRekalBranches(gitRoot string) ([]string, error) {
 
 // importBranchToIndex decodes wire format from a remote branch and inserts
 // sessions and checkpoints directly into the index DB tables.
-// Tool calls are skipped for remote data.
+// Tool calls are skipped for remote data. When since is not zero, sessions
+// captured and checkpoints taken before it are skipped. Emails are mapped to
+// their canonical form under aliases, as PopulateIndex does for local ones.
 // Returns the number of sessions imported.
-func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (int, error) {
-	bodyData := gitShowFile(gitRoot, remoteBranch, "rekal.body")
-	if len(bodyData) <= 9 {
-		return 0, nil
-	}
-
-	dictData := gitShowFile(gitRoot, remoteBranch, "dict.bin")
-	if len(dictData) == 0 {
-		return 0, nil
+func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string, since time.Time, aliases map[string]string) (int, error) {
+	if err := validateBranchTree(gitRoot, remoteBranch); err != nil {
+		return 0, err
 	}
 
-	dict, err := codec.LoadDict(dictData)
+	dict, err := codec.LoadDict(gitShowFile(gitRoot, remoteBranch, "dict.bin"))
 	if err != nil {
 		return 0, fmt.Errorf("load dict: %w", err)
 	}
 
-	frames, err := codec.ScanFrames(bodyData)
+	manifest, err := loadManifest(gitRoot, remoteBranch)
 	if err != nil {
-		return 0, fmt.Errorf("scan frames: %w", err)
+		return 0, fmt.Errorf("load manifest: %w", err)
 	}
 
-	dec, err := codec.NewDecoder()
+	dec, release, err := branchDecoder(gitRoot, remoteBranch)
 	if err != nil {
-		return 0, fmt.Errorf("create decoder: %w", err)
+		return 0, err
 	}
-	defer dec.Close()
+	defer release()
 
 	entropy := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
 	newID := func() string {
 		return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 	}
 
-	// Track session → checkpoint mapping for updating facets.
+	// Facets are inserted once every checkpoint frame has been read, since
+	// DuckDB can't update the checkpoint columns of a row in session_facets
+	// (it rejects the update as a duplicate primary key).
+	type facetRow struct {
+		sessionID, email, branch, actorType, capturedAt string
+		turnCount                                       int
+	}
+	var facets []facetRow
+	// Track session → checkpoint mapping for the facets.
 	type cpInfo struct {
 		checkpointID string
 		gitSHA       string
 		fileCount    int
 	}
 	sessionCheckpoints := make(map[string]*cpInfo)
+	// Sessions skipped by since, so their checkpoints' files are too.
+	skipped := make(map[string]bool)
 
 	var imported int
 
-	for _, fs := range frames {
-		compressed := codec.ExtractFramePayload(bodyData, fs)
+	// Stream each shard so memory is bounded by the largest frame rather
+	// than the whole blob.
+	importShard := func(file string) error {
+		body, err := gitShowReader(gitRoot, remoteBranch, file)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file, err)
+		}
+		defer body.Close() //nolint:errcheck
+		br := bufio.NewReader(body)
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil // shard not on this branch
+		}
+		frames := codec.NewFrameReader(br)
 
-		switch fs.Type {
-		case codec.FrameSession:
-			sf, err := dec.DecodeSessionFrame(compressed)
+		for {
+			ft, compressed, err := frames.Next()
+			if err == io.EOF {
+				return nil
+			}
 			if err != nil {
-				continue
+				return fmt.Errorf("read frame: %w", err)
 			}
 
-			sessionID, err := dict.Get(codec.NSSessions, sf.SessionRef)
-			if err != nil {
+			// Malformed frames and frames with unknown refs are skipped.
+			f, ok := codec.DecodeFrame(dec, ft, compressed, dict)
+			if !ok {
 				continue
 			}
 
-			email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
-			actorType := "human"
-			if sf.ActorType == codec.ActorAgent {
-				actorType = "agent"
-			}
+			switch {
+			case f.Session != nil:
+				sf := f.Session
+				sessionID, _ := dict.Get(codec.NSSessions, sf.SessionRef)
+				if !since.IsZero() && sf.CapturedAt.Before(since) {
+					skipped[sessionID] = true
+					continue
+				}
 
-			branch := ""
-			if len(sf.Turns) > 0 {
-				branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
-			}
+				email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
+				email = db.CanonicalEmail(aliases, email)
+				actorType := "human"
+				if sf.ActorType == codec.ActorAgent {
+					actorType = "agent"
+				}
+
+				branch := ""
+				if len(sf.Turns) > 0 {
+					branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
+				}
 
-			capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
+				capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
 
-			// Insert turns into turns_ft.
-			for i, t := range sf.Turns {
-				role := "human"
-				if t.Role == codec.RoleAssistant {
-					role = "assistant"
+				// Insert the session's turns into turns_ft in one
+				// transaction.
+				batch, err := db.BeginBatch(indexDB)
+				if err != nil {
+					return err
 				}
-				if _, err := indexDB.Exec(
-					`INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
-					 VALUES ($1, $2, $3, $4, $5, $6)`,
-					newID(), sessionID, i, role, t.Text, "",
-				); err != nil {
-					return imported, fmt.Errorf("insert turn_ft: %w", err)
+				stamps := wireTurnTimestamps(sf)
+				for i, t := range sf.Turns {
+					role := codec.RoleName(t.Role)
+					if _, err := batch.Exec(
+						`INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
+						 VALUES ($1, $2, $3, $4, $5, $6)`,
+						newID(), sessionID, i, role, t.Text, stamps[i],
+					); err != nil {
+						batch.Rollback()
+						return fmt.Errorf("insert turn_ft: %w", err)
+					}
 				}
-			}
+				if err := batch.Commit(); err != nil {
+					return err
+				}
+				facets = append(facets, facetRow{sessionID, email, branch, actorType, capturedAt, len(sf.Turns)})
 
-			// Insert session_facets.
-			if _, err := indexDB.Exec(
-				`INSERT INTO session_facets (
-					session_id, user_email, git_branch, actor_type, agent_id,
-					captured_at, turn_count, tool_call_count, file_count
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-				sessionID, email, branch, actorType, "",
-				capturedAt, len(sf.Turns), 0, 0,
-			); err != nil {
-				return imported, fmt.Errorf("insert session_facet: %w", err)
-			}
+				imported++
 
-			imported++
+			case f.Checkpoint != nil:
+				cf := f.Checkpoint
+				if !since.IsZero() && cf.Timestamp.Before(since) {
+					continue
+				}
 
-		case codec.FrameCheckpoint:
-			cf, err := dec.DecodeCheckpointFrame(compressed)
-			if err != nil {
-				continue
-			}
+				checkpointID, _ := dict.Get(codec.NSSessions, cf.CheckpointRef)
 
-			checkpointID, err := dict.Get(codec.NSSessions, cf.CheckpointRef)
-			if err != nil {
-				continue
-			}
+				// Insert files_index.
+				for _, ref := range cf.SessionRefs {
+					sid, err := dict.Get(codec.NSSessions, ref)
+					if err != nil || skipped[sid] {
+						continue
+					}
+					for _, f := range cf.Files {
+						filePath, _ := f.Path(dict)
+						changeType := string(f.ChangeType)
+						if _, err := indexDB.Exec(
+							`INSERT INTO files_index (checkpoint_id, session_id, file_path, change_type)
+							 VALUES ($1, $2, $3, $4)`,
+							checkpointID, sid, filePath, changeType,
+						); err != nil {
+							return fmt.Errorf("insert files_index: %w", err)
+						}
+					}
 
-			// Insert files_index.
-			for _, ref := range cf.SessionRefs {
-				sid, err := dict.Get(codec.NSSessions, ref)
-				if err != nil {
-					continue
-				}
-				for _, f := range cf.Files {
-					filePath, _ := dict.Get(codec.NSPaths, f.PathRef)
-					changeType := string(f.ChangeType)
-					if _, err := indexDB.Exec(
-						`INSERT INTO files_index (checkpoint_id, session_id, file_path, change_type)
-						 VALUES ($1, $2, $3, $4)`,
-						checkpointID, sid, filePath, changeType,
-					); err != nil {
-						return imported, fmt.Errorf("insert files_index: %w", err)
+					sessionCheckpoints[sid] = &cpInfo{
+						checkpointID: checkpointID,
+						gitSHA:       cf.GitSHA,
+						fileCount:    len(cf.Files),
 					}
 				}
 
-				sessionCheckpoints[sid] = &cpInfo{
-					checkpointID: checkpointID,
-					gitSHA:       cf.GitSHA,
-					fileCount:    len(cf.Files),
-				}
 			}
+		}
+	}
 
-		case codec.FrameMeta:
-			continue
+	for _, file := range manifest.Files() {
+		if err := importShard(file); err != nil {
+			return imported, err
 		}
 	}
 
-	// Update session_facets with checkpoint info.
-	for sid, cp := range sessionCheckpoints {
-		if _, err := indexDB.Exec(
-			`UPDATE session_facets SET checkpoint_id = $1, git_sha = $2, file_count = $3
-			 WHERE session_id = $4`,
-			cp.checkpointID, cp.gitSHA, cp.fileCount, sid,
+	// Insert session_facets with each session's checkpoint info.
+	batch, err := db.BeginBatch(indexDB)
+	if err != nil {
+		return imported, err
+	}
+	for _, f := range facets {
+		var checkpointID, gitSHA any
+		fileCount := 0
+		if cp := sessionCheckpoints[f.sessionID]; cp != nil {
+			checkpointID, gitSHA, fileCount = cp.checkpointID, cp.gitSHA, cp.fileCount
+		}
+		if _, err := batch.Exec(
+			`INSERT INTO session_facets (
+				session_id, user_email, git_branch, actor_type, agent_id,
+				captured_at, turn_count, tool_call_count, file_count,
+				checkpoint_id, git_sha
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			f.sessionID, f.email, f.branch, f.actorType, "",
+			f.capturedAt, f.turnCount, 0, fileCount, checkpointID, gitSHA,
 		); err != nil {
-			// Non-fatal: session may not have been imported (already existed).
-			continue
+			batch.Rollback()
+			return imported, fmt.Errorf("insert session_facet: %w", err)
 		}
 	}
+	if err := batch.Commit(); err != nil {
+		return imported, err
+	}
 
 	return imported, nil
 }
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newTagCmd() *cobra.Command {
+	var sessionID string
+	cmd := &cobra.Command{
+		Use:   "tag --session <id> <tag>...",
+		Short: "Attach tags to a session",
+		Long: `Attach one or more tags to a session, e.g. "bug", "spike" or "reviewed",
+so recall can filter on them with --tag.
+
+The session may be your own or a teammate's imported by 'rekal sync'.
+Tags are local-only: they are stored in the data DB's session_tags table
+and never pushed. Tagging a session twice with the same tag is a no-op.`,
+		Example: `  rekal tag --session 01JNQX... bug reviewed
+  rekal --tag bug "auth middleware"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTagCmd(cmd, sessionID, args, true)
+		},
+	}
+	cmd.Flags().StringVar(&sessionID, "session", "", "Session ID to tag")
+	_ = cmd.MarkFlagRequired("session")
+	_ = cmd.RegisterFlagCompletionFunc("session", completeFromData("sessions", "id"))
+	cmd.ValidArgsFunction = completeFromData("session_tags", "tag")
+	return cmd
+}
+
+func newUntagCmd() *cobra.Command {
+	var sessionID string
+	cmd := &cobra.Command{
+		Use:   "untag --session <id> <tag>...",
+		Short: "Remove tags from a session",
+		Long: `Remove one or more tags added with 'rekal tag'. Removing a tag the
+session doesn't have is a no-op.`,
+		Example: `  rekal untag --session 01JNQX... bug`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTagCmd(cmd, sessionID, args, false)
+		},
+	}
+	cmd.Flags().StringVar(&sessionID, "session", "", "Session ID to untag")
+	_ = cmd.MarkFlagRequired("session")
+	_ = cmd.RegisterFlagCompletionFunc("session", completeFromData("sessions", "id"))
+	cmd.ValidArgsFunction = completeFromData("session_tags", "tag")
+	return cmd
+}
+
+// runTagCmd adds (add=true) or removes tags on a session in the data DB.
+func runTagCmd(cmd *cobra.Command, sessionID string, tags []string, add bool) error {
+	cmd.SilenceUsage = true
+
+	gitRoot, err := EnsureGitRoot()
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+	if err := EnsureInitDone(gitRoot); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+
+	for _, tag := range tags {
+		if err := validateTag(tag); err != nil {
+			return err
+		}
+	}
+
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data DB: %w", err)
+	}
+	defer dataDB.Close()
+
+	exists, err := db.SessionExistsByID(dataDB, sessionID)
+	if err == nil && !exists {
+		// A teammate's session is only in the index.
+		exists, err = indexSessionExists(gitRoot, sessionID)
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	w := cmd.ErrOrStderr()
+	for _, tag := range tags {
+		if add {
+			if err := reportTag(w, dataDB, db.TagSession, sessionID, tag, "tagged", "already tagged"); err != nil {
+				return err
+			}
+		} else if err := reportTag(w, dataDB, db.UntagSession, sessionID, tag, "untagged", "not tagged"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexSessionExists reports whether the index DB has the session.
+func indexSessionExists(gitRoot, sessionID string) (bool, error) {
+	indexDB, err := db.OpenIndexReadOnly(gitRoot)
+	if err != nil {
+		return false, fmt.Errorf("open index DB: %w", err)
+	}
+	defer indexDB.Close()
+
+	return db.IndexSessionExists(indexDB, sessionID)
+}
+
+// reportTag applies op and prints whether it changed anything.
+func reportTag(w io.Writer, d *sql.DB, op func(*sql.DB, string, string) (bool, error), sessionID, tag, changed, unchanged string) error {
+	ok, err := op(d, sessionID, tag)
+	if err != nil {
+		return err
+	}
+	if ok {
+		fmt.Fprintf(w, "%s %s: %s\n", changed, sessionID, tag)
+	} else {
+		fmt.Fprintf(w, "%s %s: %s\n", unchanged, sessionID, tag)
+	}
+	return nil
+}
+
+// validateTag rejects empty tags and tags containing whitespace or commas,
+// which would be ambiguous on the command line and in output.
+func validateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tag must not be empty")
+	}
+	if strings.ContainsFunc(tag, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		return fmt.Errorf("invalid tag %q: must not contain spaces or commas", tag)
+	}
+	return nil
+}
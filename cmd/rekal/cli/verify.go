@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var branch string
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the rekal branch's wire format for corruption",
+		Long: `Check that your rekal branch (or --branch) holds well-formed wire format:
+the tree has exactly the expected files, dict.bin, rekal.manifest and
+zstd.dicts load, every body shard scans and every frame decodes, and every
+dictionary ref in a frame (session, checkpoint, email, agent, branch, path,
+MCP tool name and diff hunk refs) resolves in dict.bin.
+
+For your own branch it also checks the append-only invariant against the
+data DB: every checkpoint the data DB records as exported must still have
+its frame on the branch.
+
+The first inconsistency is reported with the shard, frame index and byte
+offset, and verify exits non-zero. On success a one-line summary is printed
+to stderr. Nothing is written.`,
+		Example: `  rekal verify
+  rekal verify --branch rekal/alice@example.com`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			if branch == "" {
+				branch = rekalBranchName()
+			}
+			stats, err := verifyBranch(gitRoot, branch)
+			if err != nil {
+				return fmt.Errorf("verify %s: %w", branch, err)
+			}
+			if !isQuiet(cmd) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "rekal: %s ok: %d frame(s) in %d shard(s), %d session(s), %d checkpoint(s), %d dict entries\n",
+					branch, stats.Frames, stats.Shards, stats.Sessions, stats.Checkpoints, stats.DictEntries)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&branch, "branch", "", "Rekal branch to verify (default: your own, rekal/<email>)")
+	return cmd
+}
+
+// verifyStats counts what verifyBranch checked.
+type verifyStats struct {
+	Shards      int
+	Frames      int
+	Sessions    int
+	Checkpoints int
+	DictEntries int
+
+	checkpointIDs map[string]bool // checkpoint IDs with a frame on the branch
+}
+
+// verifyBranch loads the wire format from ref and checks it frame by frame,
+// returning the first inconsistency found. Unlike import, which skips
+// malformed frames and unresolvable refs, every problem is an error. For
+// your own branch it then checks that every checkpoint the data DB marks
+// exported has a frame: the branch is append-only, so one missing was lost.
+func verifyBranch(gitRoot, ref string) (*verifyStats, error) {
+	if err := validateBranchTree(gitRoot, ref); err != nil {
+		return nil, err
+	}
+
+	manifest, err := loadManifest(gitRoot, ref)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+
+	dict, err := codec.LoadDict(gitShowFile(gitRoot, ref, "dict.bin"))
+	if err != nil {
+		return nil, fmt.Errorf("load dict: %w", err)
+	}
+
+	dec, release, err := branchDecoder(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stats := &verifyStats{DictEntries: dict.TotalEntries(), checkpointIDs: make(map[string]bool)}
+	for _, file := range manifest.Files() {
+		stats.Shards++
+		body := gitShowFile(gitRoot, ref, file)
+		frames, err := codec.ScanFrames(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for i, fs := range frames {
+			if err := verifyFrame(dec, dict, codec.ExtractFramePayload(body, fs), fs.Type, stats); err != nil {
+				return nil, fmt.Errorf("%s: frame %d (%s) at offset %d: %w", file, i, frameTypeName(fs.Type), fs.Offset, err)
+			}
+			stats.Frames++
+		}
+	}
+
+	if ref == rekalBranchName() {
+		if err := verifyExported(gitRoot, stats.checkpointIDs); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// verifyExported checks that every checkpoint the data DB marks exported is
+// in onBranch.
+func verifyExported(gitRoot string, onBranch map[string]bool) error {
+	dataDB, err := db.OpenDataReadOnly(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data DB: %w", err)
+	}
+	defer dataDB.Close()
+
+	exported, err := db.QueryExportedCheckpointIDs(dataDB)
+	if err != nil {
+		return err
+	}
+	missing := 0
+	first := ""
+	for _, id := range exported {
+		if !onBranch[id] {
+			if missing == 0 {
+				first = id
+			}
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("checkpoint %s is exported in the data DB but has no frame on the branch (%d of %d exported checkpoints missing)", first, missing, len(exported))
+	}
+	return nil
+}
+
+// verifyFrame decodes one frame and checks that each of its dict refs
+// resolves.
+func verifyFrame(dec *codec.Decoder, dict *codec.Dict, compressed []byte, ft codec.FrameType, stats *verifyStats) error {
+	check := func(ns codec.Namespace, what string, ref uint64) error {
+		if _, err := dict.Get(ns, ref); err != nil {
+			return fmt.Errorf("%s ref %d not in dict.bin (%d %s entries)", what, ref, dict.Len(ns), namespaceName(ns))
+		}
+		return nil
+	}
+
+	switch ft {
+	case codec.FrameSession:
+		sf, err := dec.DecodeSessionFrame(compressed)
+		if err != nil {
+			return err
+		}
+		if err := check(codec.NSSessions, "session", sf.SessionRef); err != nil {
+			return err
+		}
+		if err := check(codec.NSEmails, "email", sf.EmailRef); err != nil {
+			return err
+		}
+		if sf.ActorType == codec.ActorAgent {
+			if err := check(codec.NSEmails, "agent ID", sf.AgentIDRef); err != nil {
+				return err
+			}
+		}
+		for i, t := range sf.Turns {
+			if err := check(codec.NSBranches, fmt.Sprintf("turn %d branch", i), t.BranchRef); err != nil {
+				return err
+			}
+		}
+		for i, tc := range sf.ToolCalls {
+			if tc.Tool == codec.ToolMCP && tc.NameFlag == codec.PathDictRef {
+				if err := check(codec.NSPaths, fmt.Sprintf("tool call %d MCP name", i), tc.NameRef); err != nil {
+					return err
+				}
+			}
+			if tc.PathFlag == codec.PathDictRef {
+				if err := check(codec.NSPaths, fmt.Sprintf("tool call %d path", i), tc.PathRef); err != nil {
+					return err
+				}
+			}
+		}
+		stats.Sessions++
+
+	case codec.FrameCheckpoint:
+		cf, err := dec.DecodeCheckpointFrame(compressed)
+		if err != nil {
+			return err
+		}
+		if err := check(codec.NSSessions, "checkpoint", cf.CheckpointRef); err != nil {
+			return err
+		}
+		id, _ := dict.Get(codec.NSSessions, cf.CheckpointRef)
+		stats.checkpointIDs[id] = true
+		if err := check(codec.NSBranches, "branch", cf.BranchRef); err != nil {
+			return err
+		}
+		if err := check(codec.NSEmails, "email", cf.EmailRef); err != nil {
+			return err
+		}
+		if cf.ActorType == codec.ActorAgent {
+			if err := check(codec.NSEmails, "agent ID", cf.AgentIDRef); err != nil {
+				return err
+			}
+		}
+		for _, ref := range cf.SessionRefs {
+			if err := check(codec.NSSessions, "session", ref); err != nil {
+				return err
+			}
+		}
+		for i, f := range cf.Files {
+			if f.PathFlag == codec.PathDictRef {
+				if err := check(codec.NSPaths, fmt.Sprintf("file %d path", i), f.PathRef); err != nil {
+					return err
+				}
+			}
+			for _, ref := range f.HunkRefs {
+				if err := check(codec.NSPaths, fmt.Sprintf("file %d diff hunk", i), ref); err != nil {
+					return err
+				}
+			}
+		}
+		stats.Checkpoints++
+
+	case codec.FrameMeta:
+		mf, err := dec.DecodeMetaFrame(compressed)
+		if err != nil {
+			return err
+		}
+		if err := check(codec.NSEmails, "email", mf.EmailRef); err != nil {
+			return err
+		}
+
+	case codec.FrameTombstone:
+		// No payload to check.
+
+	default:
+		return fmt.Errorf("unknown frame type 0x%02x", byte(ft))
+	}
+	return nil
+}
+
+func frameTypeName(ft codec.FrameType) string {
+	switch ft {
+	case codec.FrameSession:
+		return "session"
+	case codec.FrameCheckpoint:
+		return "checkpoint"
+	case codec.FrameMeta:
+		return "meta"
+	case codec.FrameTombstone:
+		return "tombstone"
+	default:
+		return fmt.Sprintf("0x%02x", byte(ft))
+	}
+}
+
+func namespaceName(ns codec.Namespace) string {
+	switch ns {
+	case codec.NSSessions:
+		return "sessions"
+	case codec.NSBranches:
+		return "branches"
+	case codec.NSEmails:
+		return "emails"
+	default:
+		return "paths"
+	}
+}
@@ -5,6 +5,10 @@ import "time"
 // VersionCache represents the cached version check data.
 type VersionCache struct {
 	LastCheckTime time.Time `json:"last_check_time"`
+	// ETag and LatestVersion are from the last successful fetch. The ETag is
+	// sent as If-None-Match, and a 304 reuses LatestVersion.
+	ETag          string `json:"etag,omitempty"`
+	LatestVersion string `json:"latest_version,omitempty"`
 }
 
 // GitHubRelease represents the GitHub API response for a release.
@@ -21,12 +25,23 @@ const (
 	// checkInterval is the duration between version checks.
 	checkInterval = 24 * time.Hour
 
-	// httpTimeout is the timeout for HTTP requests to the GitHub API.
+	// httpTimeout is the timeout for HTTP requests to the GitHub API,
+	// covering the retry.
 	httpTimeout = 2 * time.Second
 
+	// retryBackoff is how long to wait before retrying a transient failure.
+	retryBackoff = 200 * time.Millisecond
+
 	// cacheFileName is the name of the cache file stored in the global config directory.
 	cacheFileName = "version_check.json"
 
 	// globalConfigDirName is the name of the global config directory in the user's home.
 	globalConfigDirName = ".config/rekal"
+
+	// disableEnv turns the version check off when set to a true value.
+	disableEnv = "REKAL_NO_UPDATE_CHECK"
+
+	// disableConfigKey turns the version check off when set to false in
+	// git config, usually with --global.
+	disableConfigKey = "rekal.updateCheck"
 )
@@ -6,25 +6,78 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/mod/semver"
 )
 
+// fetchLatest and isOffline are vars so tests can replace them.
+var (
+	fetchLatest = fetchLatestVersion
+	isOffline   = noNetwork
+)
+
 // CheckAndNotify performs a version check and notifies the user if a newer version is available.
 // Silent on all errors to avoid interrupting CLI operations.
 func CheckAndNotify(w io.Writer, currentVersion string) {
-	if currentVersion == "dev" || currentVersion == "" {
-		return
+	if latest := newerVersion(currentVersion); latest != "" {
+		printNotification(w, currentVersion, latest)
 	}
+}
 
-	if err := ensureGlobalConfigDir(); err != nil {
+// Check is a version check running in the background; see Start.
+type Check struct {
+	done    chan struct{}
+	current string
+	latest  string // written before done is closed; "" if nothing to report
+}
+
+// Start begins a version check in a goroutine, so that it overlaps the
+// command instead of delaying it. Call Notify after the command's output.
+func Start(currentVersion string) *Check {
+	c := &Check{done: make(chan struct{}), current: currentVersion}
+	go func() {
+		defer close(c.done)
+		c.latest = newerVersion(currentVersion)
+	}()
+	return c
+}
+
+// Notify prints the update notice to w if the check found a newer version,
+// waiting at most grace for it to finish. A check still running after that
+// is abandoned and prints nothing. A nil Check does nothing.
+func (c *Check) Notify(w io.Writer, grace time.Duration) {
+	if c == nil {
+		return
+	}
+	select {
+	case <-c.done:
+	case <-time.After(grace):
 		return
 	}
+	if c.latest != "" {
+		printNotification(w, c.current, c.latest)
+	}
+}
+
+// newerVersion returns the latest release if it is newer than
+// currentVersion, or "" if it is not or the check failed. When the check is
+// not due, the latest release is the one the last completed check cached.
+//
+// The check is skipped when REKAL_NO_UPDATE_CHECK is true, when git config
+// rekal.updateCheck is false, and, without touching the cache, when no
+// network interface is up.
+func newerVersion(currentVersion string) string {
+	if currentVersion == "dev" || currentVersion == "" || disabledByEnv() {
+		return ""
+	}
 
 	cache, err := loadCache()
 	if err != nil {
@@ -32,21 +85,76 @@ func CheckAndNotify(w io.Writer, currentVersion string) {
 	}
 
 	if time.Since(cache.LastCheckTime) < checkInterval {
-		return
+		// A check abandoned after the grace period still caches its
+		// result when it finishes, for this run to report.
+		if cache.LatestVersion == "" || !isOutdated(currentVersion, cache.LatestVersion) || disabledByConfig() {
+			return ""
+		}
+		return cache.LatestVersion
 	}
 
-	latestVersion, err := fetchLatestVersion()
+	if disabledByConfig() || isOffline() {
+		return ""
+	}
+
+	if err := ensureGlobalConfigDir(); err != nil {
+		return ""
+	}
 
+	// Record the check before fetching: a fetch that outlives the command's
+	// grace period is abandoned, and would otherwise be retried every run.
+	// If the process is still running when it completes, the result and
+	// ETag are saved for the next run's notice.
 	cache.LastCheckTime = time.Now()
 	_ = saveCache(cache)
 
-	if err != nil {
-		return
+	latestVersion, err := fetchLatest(cache)
+	if err == nil {
+		cache.LatestVersion = latestVersion
+		_ = saveCache(cache)
 	}
 
-	if isOutdated(currentVersion, latestVersion) {
-		printNotification(w, currentVersion, latestVersion)
+	if err != nil || !isOutdated(currentVersion, latestVersion) {
+		return ""
 	}
+	return latestVersion
+}
+
+// disabledByEnv reports whether REKAL_NO_UPDATE_CHECK is set to true.
+func disabledByEnv() bool {
+	off, _ := strconv.ParseBool(os.Getenv(disableEnv))
+	return off
+}
+
+// disabledByConfig reports whether git config rekal.updateCheck is false.
+// Without git or the key, the check is enabled.
+func disabledByConfig() bool {
+	out, err := exec.Command("git", "config", "--type=bool", "--get", disableConfigKey).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "false"
+}
+
+// noNetwork reports whether no non-loopback interface is up with a routable
+// address, so a request could not succeed anyway.
+func noNetwork() bool {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func globalConfigDirPath() (string, error) {
@@ -121,40 +229,68 @@ func saveCache(cache *VersionCache) error {
 	return nil
 }
 
-func fetchLatestVersion() (string, error) {
+// fetchLatestVersion returns the latest release version, sending the
+// cached ETag so an unchanged release costs a 304. On success it records the
+// new ETag and version in cache. A network error or 5xx is retried once
+// after retryBackoff, within the same httpTimeout.
+func fetchLatestVersion(cache *VersionCache) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
 	defer cancel()
 
+	version, retry, err := requestLatestVersion(ctx, cache)
+	if err != nil && retry {
+		select {
+		case <-time.After(retryBackoff):
+			version, _, err = requestLatestVersion(ctx, cache)
+		case <-ctx.Done():
+		}
+	}
+	return version, err
+}
+
+// requestLatestVersion makes one request for the latest release. retry
+// reports whether the failure looks transient.
+func requestLatestVersion(ctx context.Context, cache *VersionCache) (version string, retry bool, err error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubAPIURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return "", false, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("User-Agent", "rekal-cli")
+	if cache.ETag != "" && cache.LatestVersion != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetching release info: %w", err)
+		return "", ctx.Err() == nil, fmt.Errorf("fetching release info: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cache.LatestVersion != "":
+		return cache.LatestVersion, false, nil
+	case resp.StatusCode >= 500:
+		return "", true, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
+		return "", true, fmt.Errorf("reading response: %w", err)
 	}
 
-	version, err := parseGitHubRelease(body)
+	version, err = parseGitHubRelease(body)
 	if err != nil {
-		return "", fmt.Errorf("parsing release: %w", err)
+		return "", false, fmt.Errorf("parsing release: %w", err)
 	}
 
-	return version, nil
+	cache.ETag = resp.Header.Get("ETag")
+	cache.LatestVersion = version
+	return version, false, nil
 }
 
 func parseGitHubRelease(body []byte) (string, error) {
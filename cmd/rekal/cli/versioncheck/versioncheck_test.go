@@ -0,0 +1,308 @@
+package versioncheck
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveRelease points githubAPIURL at handler for the rest of the test.
+func serveRelease(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	old := githubAPIURL
+	githubAPIURL = srv.URL
+	t.Cleanup(func() { githubAPIURL = old })
+}
+
+// stubOffline makes isOffline report offline for the rest of the test.
+func stubOffline(t *testing.T, offline bool) {
+	t.Helper()
+	old := isOffline
+	isOffline = func() bool { return offline }
+	t.Cleanup(func() { isOffline = old })
+}
+
+// countFetches replaces fetchLatest with a stub that counts its calls.
+func countFetches(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	old := fetchLatest
+	fetchLatest = func(*VersionCache) (string, error) {
+		calls.Add(1)
+		return "v99.0.0", nil
+	}
+	t.Cleanup(func() { fetchLatest = old })
+	return &calls
+}
+
+func TestFetchLatestVersion_NotModifiedReusesCache(t *testing.T) {
+	var hits, conditional atomic.Int32
+	serveRelease(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"tag_name":"v1.2.0","prerelease":false}`))
+	})
+
+	cache := &VersionCache{}
+	got, err := fetchLatestVersion(cache)
+	if err != nil || got != "v1.2.0" {
+		t.Fatalf("first fetch = %q, %v; want v1.2.0", got, err)
+	}
+	if cache.ETag != `"v1"` || cache.LatestVersion != "v1.2.0" {
+		t.Fatalf("cache after 200 = %+v", cache)
+	}
+
+	got, err = fetchLatestVersion(cache)
+	if err != nil || got != "v1.2.0" {
+		t.Fatalf("second fetch = %q, %v; want the cached v1.2.0", got, err)
+	}
+	if hits.Load() != 2 || conditional.Load() != 1 {
+		t.Errorf("hits = %d, conditional = %d; want 2 and 1", hits.Load(), conditional.Load())
+	}
+}
+
+func TestFetchLatestVersion_RetriesTransientFailure(t *testing.T) {
+	var hits atomic.Int32
+	serveRelease(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"tag_name":"v1.3.0"}`))
+	})
+
+	got, err := fetchLatestVersion(&VersionCache{})
+	if err != nil || got != "v1.3.0" {
+		t.Fatalf("fetch = %q, %v; want v1.3.0 after one retry", got, err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("hits = %d, want 2", hits.Load())
+	}
+}
+
+func TestFetchLatestVersion_NoRetryOnClientError(t *testing.T) {
+	var hits atomic.Int32
+	serveRelease(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	if _, err := fetchLatestVersion(&VersionCache{}); err == nil {
+		t.Fatal("expected an error for 404")
+	}
+	if hits.Load() != 1 {
+		t.Errorf("hits = %d, want 1", hits.Load())
+	}
+}
+
+func TestCheckAndNotify_UsesCachedVersionOn304(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, false)
+	serveRelease(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"etag-9"` {
+			t.Errorf("If-None-Match = %q, want the cached ETag", r.Header.Get("If-None-Match"))
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	if err := ensureGlobalConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+	stale := &VersionCache{
+		LastCheckTime: time.Now().Add(-2 * checkInterval),
+		ETag:          `"etag-9"`,
+		LatestVersion: "v9.9.9",
+	}
+	if err := saveCache(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	CheckAndNotify(&out, "1.0.0")
+	if !strings.Contains(out.String(), "v9.9.9") {
+		t.Errorf("expected a notification for the cached v9.9.9, got %q", out.String())
+	}
+
+	cache, err := loadCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.LatestVersion != "v9.9.9" || cache.ETag != `"etag-9"` || time.Since(cache.LastCheckTime) > time.Minute {
+		t.Errorf("cache after 304 = %+v", cache)
+	}
+}
+
+func TestCheckAndNotify_DisabledByEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(disableEnv, "1")
+	stubOffline(t, false)
+	calls := countFetches(t)
+
+	var out bytes.Buffer
+	CheckAndNotify(&out, "1.0.0")
+	if calls.Load() != 0 {
+		t.Errorf("fetchLatest called %d time(s) with %s=1", calls.Load(), disableEnv)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestCheckAndNotify_DisabledByGitConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	global := filepath.Join(home, ".gitconfig")
+	if err := os.WriteFile(global, []byte("[rekal]\n\tupdateCheck = false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+	stubOffline(t, false)
+	calls := countFetches(t)
+
+	CheckAndNotify(&bytes.Buffer{}, "1.0.0")
+	if calls.Load() != 0 {
+		t.Errorf("fetchLatest called %d time(s) with rekal.updateCheck = false", calls.Load())
+	}
+}
+
+func TestCheckAndNotify_OfflineLeavesCacheStale(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, true)
+	calls := countFetches(t)
+
+	CheckAndNotify(&bytes.Buffer{}, "1.0.0")
+	if calls.Load() != 0 {
+		t.Errorf("fetchLatest called %d time(s) while offline", calls.Load())
+	}
+	if _, err := loadCache(); err == nil {
+		t.Error("cache written while offline; the next online run would skip its check")
+	}
+
+	stubOffline(t, false)
+	var out bytes.Buffer
+	CheckAndNotify(&out, "1.0.0")
+	if calls.Load() != 1 || !strings.Contains(out.String(), "v99.0.0") {
+		t.Errorf("online: calls = %d, output %q; want one fetch and a notification", calls.Load(), out.String())
+	}
+}
+
+func TestStart_SlowFetchDoesNotDelayCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, false)
+	release := make(chan struct{})
+	old := fetchLatest
+	fetchLatest = func(*VersionCache) (string, error) {
+		<-release
+		return "v99.0.0", nil
+	}
+	t.Cleanup(func() { fetchLatest = old })
+
+	start := time.Now()
+	check := Start("1.0.0")
+	var out bytes.Buffer
+	out.WriteString("results\n")
+	check.Notify(&out, 50*time.Millisecond)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("command took %v behind a hung version check", elapsed)
+	}
+	if out.String() != "results\n" {
+		t.Errorf("output = %q, want only the results", out.String())
+	}
+
+	// Let the abandoned check finish before the test's HOME is removed.
+	close(release)
+	<-check.done
+}
+
+func TestStart_AbandonedCheckIsStillRecorded(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, false)
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	old := fetchLatest
+	fetchLatest = func(*VersionCache) (string, error) {
+		close(fetching)
+		<-release
+		return "v99.0.0", nil
+	}
+	t.Cleanup(func() { fetchLatest = old })
+
+	check := Start("1.0.0")
+	<-fetching
+	check.Notify(&bytes.Buffer{}, 0)
+
+	// The process would exit here; the check time must already be saved.
+	cache, err := loadCache()
+	if err != nil {
+		t.Fatalf("loadCache: %v", err)
+	}
+	if time.Since(cache.LastCheckTime) > time.Minute {
+		t.Errorf("LastCheckTime = %v, want the time of the abandoned check", cache.LastCheckTime)
+	}
+
+	close(release)
+	<-check.done
+}
+
+func TestStart_AbandonedCheckNotifiesNextRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, false)
+	release := make(chan struct{})
+	old := fetchLatest
+	fetchLatest = func(*VersionCache) (string, error) {
+		<-release
+		return "v99.0.0", nil
+	}
+	t.Cleanup(func() { fetchLatest = old })
+
+	check := Start("1.0.0")
+	var out bytes.Buffer
+	check.Notify(&out, 0)
+	if out.Len() != 0 {
+		t.Fatalf("abandoned check printed %q", out.String())
+	}
+	close(release)
+	<-check.done
+
+	// The next run is within checkInterval: no fetch, but the cached
+	// result is reported.
+	calls := countFetches(t)
+	CheckAndNotify(&out, "1.0.0")
+	if calls.Load() != 0 || !strings.Contains(out.String(), "v99.0.0") {
+		t.Errorf("next run: calls = %d, output %q; want the cached notice without a fetch", calls.Load(), out.String())
+	}
+	out.Reset()
+	CheckAndNotify(&out, "99.0.0")
+	if out.Len() != 0 {
+		t.Errorf("up to date: unexpected output %q", out.String())
+	}
+}
+
+func TestStart_NotifiesAfterCommandOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubOffline(t, false)
+	countFetches(t)
+
+	check := Start("1.0.0")
+	var out bytes.Buffer
+	out.WriteString("results\n")
+	check.Notify(&out, time.Second)
+
+	if !strings.HasPrefix(out.String(), "results\n") || !strings.Contains(out.String(), "v99.0.0") {
+		t.Errorf("output = %q, want the results then the notice", out.String())
+	}
+}
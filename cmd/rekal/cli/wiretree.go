@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// wireFileMode is the git mode of every file in a rekal branch tree.
+const wireFileMode = "100644"
+
+// treeEntry is one line of `git ls-tree` output.
+type treeEntry struct {
+	Mode string
+	Type string
+	Hash string
+}
+
+// lsTree lists the top-level entries of ref's tree by name.
+func lsTree(gitRoot, ref string) (map[string]treeEntry, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "ls-tree", ref).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ls-tree %s: %w", ref, err)
+	}
+	entries := make(map[string]treeEntry)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		meta, name, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		entries[name] = treeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2]}
+	}
+	return entries, nil
+}
+
+// validateBranchTree checks that ref's tree holds exactly the wire format
+// files: dict.bin and rekal.body, plus rekal.manifest and the shards it lists
+// when the body is sharded, and optionally zstd.dicts, all as regular
+// (100644) blobs. Anything missing, extra, or of the wrong type is reported
+// as a malformed branch, so callers fail before reading blobs that are not
+// there.
+func validateBranchTree(gitRoot, ref string) error {
+	entries, err := lsTree(gitRoot, ref)
+	if err != nil {
+		return err
+	}
+
+	want := []string{"dict.bin"}
+	if e, ok := entries[codec.ManifestFile]; ok {
+		if e.Type != "blob" || e.Mode != wireFileMode {
+			return fmt.Errorf("malformed rekal branch %s: %s is a %s with mode %s, want a %s blob",
+				ref, codec.ManifestFile, e.Type, e.Mode, wireFileMode)
+		}
+		manifest, err := loadManifest(gitRoot, ref)
+		if err != nil {
+			return fmt.Errorf("malformed rekal branch %s: %w", ref, err)
+		}
+		want = append(want, codec.ManifestFile)
+		want = append(want, manifest.Files()...)
+	} else {
+		want = append(want, codec.ShardFile(0))
+	}
+	if _, ok := entries[codec.TrainedDictsFile]; ok {
+		want = append(want, codec.TrainedDictsFile)
+	}
+
+	for _, name := range want {
+		e, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("malformed rekal branch %s: missing %s", ref, name)
+		}
+		if e.Type != "blob" || e.Mode != wireFileMode {
+			return fmt.Errorf("malformed rekal branch %s: %s is a %s with mode %s, want a %s blob",
+				ref, name, e.Type, e.Mode, wireFileMode)
+		}
+		delete(entries, name)
+	}
+
+	if len(entries) > 0 {
+		extra := make([]string, 0, len(entries))
+		for name := range entries {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return fmt.Errorf("malformed rekal branch %s: unexpected %s", ref, strings.Join(extra, ", "))
+	}
+	return nil
+}
+
+// branchDecoder returns a decoder for the frames on refs: a pooled one when
+// none of them has trained zstd dictionaries, else a new one that knows them
+// all. Call release when done with it.
+func branchDecoder(gitRoot string, refs ...string) (dec *codec.Decoder, release func(), err error) {
+	var dicts [][]byte
+	for _, ref := range refs {
+		d, err := codec.LoadTrainedDicts(gitShowFile(gitRoot, ref, codec.TrainedDictsFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		dicts = append(dicts, d...)
+	}
+	if len(dicts) == 0 {
+		if dec, err = codec.GetDecoder(); err != nil {
+			return nil, nil, fmt.Errorf("create decoder: %w", err)
+		}
+		return dec, func() { codec.PutDecoder(dec) }, nil
+	}
+	if dec, err = codec.NewDecoderDicts(dicts...); err != nil {
+		return nil, nil, fmt.Errorf("create decoder: %w", err)
+	}
+	return dec, dec.Close, nil
+}
+
+// mergeTrainedDicts returns local's zstd.dicts with the dictionaries only
+// remote has appended, or nil when remote has none to add.
+func mergeTrainedDicts(gitRoot, local, remote string) ([]byte, error) {
+	remoteDicts, err := codec.LoadTrainedDicts(gitShowFile(gitRoot, remote, codec.TrainedDictsFile))
+	if err != nil || len(remoteDicts) == 0 {
+		return nil, err
+	}
+	merged := gitShowFile(gitRoot, local, codec.TrainedDictsFile)
+	for _, d := range remoteDicts {
+		if merged, err = codec.AppendTrainedDict(merged, d); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// writeTestBranch commits a tree of the given files to refs/heads/rekal/test
+// in a fresh repo and returns the repo path. files maps name to {mode, content}.
+func writeTestBranch(t *testing.T, files map[string][2]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Fatalf("git init: %v", err)
+	}
+
+	var tree strings.Builder
+	for name, f := range files {
+		hash, err := gitHashObject(dir, []byte(f[1]))
+		if err != nil {
+			t.Fatalf("hash %s: %v", name, err)
+		}
+		fmt.Fprintf(&tree, "%s blob %s\t%s\n", f[0], hash, name)
+	}
+	mktree := exec.Command("git", "-C", dir, "mktree")
+	mktree.Stdin = strings.NewReader(tree.String())
+	treeOut, err := mktree.Output()
+	if err != nil {
+		t.Fatalf("mktree: %v", err)
+	}
+	commit := exec.Command("git", "-C", dir, "commit-tree", strings.TrimSpace(string(treeOut)), "-m", "test")
+	commit.Env = append(commit.Environ(),
+		"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+	commitOut, err := commit.Output()
+	if err != nil {
+		t.Fatalf("commit-tree: %v", err)
+	}
+	if err := exec.Command("git", "-C", dir, "update-ref", "refs/heads/rekal/test", strings.TrimSpace(string(commitOut))).Run(); err != nil {
+		t.Fatalf("update-ref: %v", err)
+	}
+	return dir
+}
+
+func TestValidateBranchTree(t *testing.T) {
+	t.Parallel()
+
+	body := string(codec.NewBody())
+	dict := string(codec.NewDict().Encode())
+	manifest := string((&codec.Manifest{Shards: 2}).Encode())
+
+	tests := []struct {
+		name    string
+		files   map[string][2]string
+		wantErr string
+	}{
+		{"valid", map[string][2]string{
+			"dict.bin":   {"100644", dict},
+			"rekal.body": {"100644", body},
+		}, ""},
+		{"valid sharded", map[string][2]string{
+			"dict.bin":       {"100644", dict},
+			"rekal.body":     {"100644", body},
+			"rekal.body.1":   {"100644", body},
+			"rekal.manifest": {"100644", manifest},
+		}, ""},
+		{"missing dict", map[string][2]string{
+			"rekal.body": {"100644", body},
+		}, "malformed rekal branch rekal/test: missing dict.bin"},
+		{"missing shard", map[string][2]string{
+			"dict.bin":       {"100644", dict},
+			"rekal.body":     {"100644", body},
+			"rekal.manifest": {"100644", manifest},
+		}, "missing rekal.body.1"},
+		{"executable", map[string][2]string{
+			"dict.bin":   {"100644", dict},
+			"rekal.body": {"100755", body},
+		}, "rekal.body is a blob with mode 100755"},
+		{"extra file", map[string][2]string{
+			"dict.bin":   {"100644", dict},
+			"rekal.body": {"100644", body},
+			"README":     {"100644", "hi"},
+		}, "unexpected README"},
+		{"bad manifest", map[string][2]string{
+			"dict.bin":       {"100644", dict},
+			"rekal.body":     {"100644", body},
+			"rekal.manifest": {"100644", "garbage!"},
+		}, "manifest: bad magic"},
+	}
+	for _, tt := range tests {
+		dir := writeTestBranch(t, tt.files)
+		err := validateBranchTree(dir, "rekal/test")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}